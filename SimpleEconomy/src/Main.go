@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	
+	"github.com/percocets100/SimpleEconomy/SimpleEconomy/src/economy"
+)
+
+func main() {
+	plugin := economy.NewEconomyPlugin()
+	
+	console := flag.Bool("console", false, "read commands from stdin instead of running the demo")
+	flag.Parse()
+	
+	// The first SIGINT or SIGTERM shuts down cleanly; stop restores the
+	// default handling, so a second one kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	
+	plugin.OnEnable()
+	
+	if *console {
+		done := make(chan error, 1)
+		go func() { done <- plugin.RunConsole(os.Stdin, os.Stdout) }()
+		
+		select {
+		case err := <-done:
+			if err != nil {
+				log.Printf("Console error: %v", err)
+			}
+		case <-ctx.Done():
+			stop()
+			log.Printf("Shutting down, signal again to force")
+		}
+		os.Exit(shutdown(plugin))
+	}
+	
+	plugin.OnPlayerJoin(economy.OfflineUUID("TestPlayer"), "TestPlayer")
+	
+	fmt.Println("\n=== Demo Commands ===")
+	fmt.Println(plugin.ExecuteCommand(economy.ConsoleSender, "balance", []string{"TestPlayer"}))
+	fmt.Println(plugin.ExecuteCommand(economy.ConsoleSender, "money", []string{"give", "TestPlayer", "500"}))
+	fmt.Println(plugin.ExecuteCommand(economy.ConsoleSender, "balance", []string{"TestPlayer"}))
+	fmt.Println(plugin.ExecuteCommand(economy.ConsoleSender, "money", []string{"give", "Player2", "2000"}))
+	fmt.Println(plugin.ExecuteCommand(economy.PlayerSender("TestPlayer", economy.OfflineUUID("TestPlayer")), "pay", []string{"Player2", "50"}))
+	fmt.Println(plugin.ExecuteCommand(economy.ConsoleSender, "top", []string{}))
+	fmt.Println(plugin.ExecuteCommand(economy.ConsoleSender, "economy", []string{"stats"}))
+	
+	os.Exit(shutdown(plugin))
+}
+
+// shutdown disables the plugin and returns the exit code, non-zero when
+// the final save did not complete.
+func shutdown(plugin *economy.EconomyPlugin) int {
+	if err := plugin.Disable(); err != nil {
+		log.Printf("Shutdown did not complete cleanly: %v", err)
+		return 1
+	}
+	return 0
+}
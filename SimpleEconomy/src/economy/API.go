@@ -0,0 +1,105 @@
+package economy
+
+import "time"
+
+// Economy is the API other plugins use to read and move money.
+type Economy interface {
+	GetBalance(username string, scope ...string) (Money, error)
+	Deposit(username string, amount Money, reason string, scope ...string) (string, error)
+	Withdraw(username string, amount Money, reason string, scope ...string) (string, error)
+	DepositWithCode(username string, amount Money, code ReasonCode, reason string) (string, error)
+	WithdrawWithCode(username string, amount Money, code ReasonCode, reason string) (string, error)
+	Transfer(from, to string, amount Money, reason string, scope ...string) (string, error)
+	HasAccount(username string, scope ...string) bool
+	FormatMoney(amount Money) string
+	GetBalanceHistory(username string, since time.Time) ([]BalanceSnapshot, error)
+	GetDebt(username string) (Money, error)
+	GetLoans(username string) []Loan
+	CreateEscrow(from, to string, amount Money, expiry time.Duration) (Escrow, error)
+	ReleaseEscrow(id int) (Escrow, error)
+	CancelEscrow(id int) (Escrow, error)
+	CreateVoucher(player string, amount Money) (string, error)
+	RedeemVoucher(player, code string) (Money, error)
+	GiveAll(amount Money, filter AccountFilter, reason string) (BatchResult, error)
+	TakeAll(amount Money, filter AccountFilter, reason string) (BatchResult, error)
+	MultiTransfer(requests []TransferRequest, reason string) (BatchResult, error)
+	ChargeForPurchase(buyer, seller string, amount Money, itemRef string) (string, error)
+	Refund(transactionID string) (Transaction, error)
+	GetTransaction(id string) (Transaction, error)
+	PlaceHold(player string, amount Money, reason string) (string, error)
+	ReleaseHold(player, id string) (Money, error)
+	CaptureHold(player, id, to string) (Money, error)
+	GetHolds(player string) []Hold
+	EarnFromJob(player, job string, amount Money) (Money, error)
+	GetEarnings(player string) (map[string]Money, error)
+	Flows(since time.Time) []MoneyFlow
+	CreateVirtualAccount(name string) error
+	CreateAccount(username string) error
+	VirtualAccounts() []PlayerAccount
+	GetLeaderboard(category LeaderboardCategory) ([]PlayerAccount, error)
+	Rules() []TransactionRule
+	MoneySupply(days int) []SupplyPoint
+	CurrentSupply() SupplyStatus
+	SearchTransactions(search TransactionSearch) []Transaction
+	HealthReport(day time.Time) HealthReport
+	WithdrawAsItems(player string, amount Money) ([]ItemStack, error)
+	DepositItems(player string, items []ItemStack) (Money, error)
+	GetRank(username string, category LeaderboardCategory) (int, error)
+	SetAccountHidden(username string, hidden bool) error
+	PenalizeOnDeath(player string) (Money, error)
+	GrantReward(player string, amount Money, questID string) (string, error)
+	HasClaimedReward(player, questID string) bool
+	RecordVote(vote Vote) (VoteReward, error)
+}
+
+var _ Economy = (*EconomyPlugin)(nil)
+
+// The mutating methods record reason in the ledger and return the ID of
+// the transaction recorded. An empty reason records a generic one instead.
+//
+// The methods taking a scope act on the players' balances in that scope,
+// or in this server's scope when none is given; see ScopeConfig.
+
+func (e *EconomyPlugin) GetBalance(username string, scope ...string) (Money, error) {
+	username = e.inScope(username, scope)
+	if !e.hasAccount(username) {
+		return 0, ErrAccountNotFound
+	}
+	
+	return e.getBalance(username), nil
+}
+
+func (e *EconomyPlugin) Deposit(username string, amount Money, reason string, scope ...string) (string, error) {
+	return e.addMoney(e.inScope(username, scope), e.roundAmount(amount), reason)
+}
+
+func (e *EconomyPlugin) Withdraw(username string, amount Money, reason string, scope ...string) (string, error) {
+	username = e.inScope(username, scope)
+	if !e.hasAccount(username) {
+		return "", ErrAccountNotFound
+	}
+	
+	return e.subtractMoney(username, e.roundAmount(amount), reason)
+}
+
+func (e *EconomyPlugin) Transfer(from, to string, amount Money, reason string, scope ...string) (string, error) {
+	from, to = e.inScope(from, scope), e.inScope(to, scope)
+	if !e.hasAccount(from) {
+		return "", ErrAccountNotFound
+	}
+	amount = e.roundAmount(amount)
+	if err := e.checkMinTransaction(amount); err != nil {
+		return "", err
+	}
+	
+	return e.transferMoney(from, to, amount, reason)
+}
+
+func (e *EconomyPlugin) HasAccount(username string, scope ...string) bool {
+	return e.hasAccount(e.inScope(username, scope))
+}
+
+func (e *EconomyPlugin) hasAccount(username string) bool {
+	_, exists := e.lookupAccount(username)
+	return exists
+}
@@ -0,0 +1,350 @@
+package economy
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIScope says what an API key may do. A transfer key can also read, and
+// an admin key can do everything, including giving and taking money.
+type APIScope string
+
+const (
+	ScopeRead     APIScope = "read"
+	ScopeTransfer APIScope = "transfer"
+	ScopeAdmin    APIScope = "admin"
+)
+
+const (
+	apiKeyPrefix        = "se_"
+	defaultAPIRateLimit = 60
+	
+	// legacyKeyName is how requests using the api_token from the config
+	// show up in the request log. That token has every scope and no rate
+	// limit.
+	legacyKeyName = "api_token"
+)
+
+// APIKey is a key for the HTTP and gRPC APIs. Only a hash of the key is
+// stored, so a copy of api_keys.json cannot be used to call the API.
+// RateLimit is in requests per minute; 0 means no limit.
+type APIKey struct {
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	Scope     APIScope  `json:"scope"`
+	RateLimit int       `json:"rate_limit"`
+	Created   time.Time `json:"created"`
+}
+
+func (k *APIKey) allows(scope APIScope) bool {
+	switch scope {
+	case ScopeRead:
+		return true
+	case ScopeTransfer:
+		return k.Scope == ScopeTransfer || k.Scope == ScopeAdmin
+	default:
+		return k.Scope == ScopeAdmin
+	}
+}
+
+// rateBucket is a token bucket refilled at the key's rate limit.
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take spends a token if one is left, or returns how long until one is.
+func (b *rateBucket) take(limit int, now time.Time) (bool, time.Duration) {
+	perSecond := float64(limit) / 60
+	b.tokens = math.Min(float64(limit), b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+}
+
+// apiKeyring holds every APIKey keyed by hash, with each key's rate
+// bucket keyed by name.
+type apiKeyring struct {
+	mutex   sync.Mutex
+	keys    map[string]*APIKey
+	buckets map[string]*rateBucket
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (e *EconomyPlugin) apiKeysPath() string {
+	return filepath.Join(e.dataFolder, "api_keys.json")
+}
+
+func (e *EconomyPlugin) loadAPIKeys() {
+	e.apiKeys.mutex.Lock()
+	defer e.apiKeys.mutex.Unlock()
+	
+	e.apiKeys.keys = make(map[string]*APIKey)
+	e.apiKeys.buckets = make(map[string]*rateBucket)
+	
+	data, err := ioutil.ReadFile(e.apiKeysPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Error("Failed to read API keys", "error", err)
+		return
+	}
+	
+	var keys []*APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		e.logger.Error("Failed to parse API keys", "error", err)
+		return
+	}
+	for _, key := range keys {
+		e.apiKeys.keys[key.Hash] = key
+	}
+}
+
+// saveAPIKeys must be called with e.apiKeys.mutex held.
+func (e *EconomyPlugin) saveAPIKeys() error {
+	keys := make([]*APIKey, 0, len(e.apiKeys.keys))
+	for _, key := range e.apiKeys.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Created.Before(keys[j].Created) })
+	
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(e.apiKeysPath(), data, 0600); err != nil {
+		e.logger.Error("Failed to write API keys", "error", err)
+		e.countStorageError("api_keys")
+		return err
+	}
+	return nil
+}
+
+// hasAPIKeys reports whether any API key exists, so the servers can start
+// without a shared api_token.
+func (e *EconomyPlugin) hasAPIKeys() bool {
+	e.apiKeys.mutex.Lock()
+	defer e.apiKeys.mutex.Unlock()
+	return len(e.apiKeys.keys) > 0
+}
+
+// CreateAPIKey creates a key and returns it. The key itself is not stored
+// and cannot be shown again.
+func (e *EconomyPlugin) CreateAPIKey(name string, scope APIScope, rateLimit int) (string, error) {
+	if name == "" || len(name) > 32 || strings.ContainsAny(name, " \t") {
+		return "", ErrInvalidAPIKeyName
+	}
+	if scope != ScopeRead && scope != ScopeTransfer && scope != ScopeAdmin {
+		return "", ErrInvalidAPIScope
+	}
+	if rateLimit < 0 {
+		return "", ErrInvalidAmount
+	}
+	
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+	
+	e.apiKeys.mutex.Lock()
+	defer e.apiKeys.mutex.Unlock()
+	
+	for _, existing := range e.apiKeys.keys {
+		if strings.EqualFold(existing.Name, name) {
+			return "", ErrAPIKeyExists
+		}
+	}
+	
+	hash := hashAPIKey(key)
+	e.apiKeys.keys[hash] = &APIKey{Name: name, Hash: hash, Scope: scope, RateLimit: rateLimit, Created: e.now()}
+	if err := e.saveAPIKeys(); err != nil {
+		delete(e.apiKeys.keys, hash)
+		return "", ErrStorage
+	}
+	
+	return key, nil
+}
+
+// RevokeAPIKey deletes the key with the given name. Requests using it are
+// refused from then on.
+func (e *EconomyPlugin) RevokeAPIKey(name string) error {
+	e.apiKeys.mutex.Lock()
+	defer e.apiKeys.mutex.Unlock()
+	
+	for hash, key := range e.apiKeys.keys {
+		if !strings.EqualFold(key.Name, name) {
+			continue
+		}
+		delete(e.apiKeys.keys, hash)
+		if err := e.saveAPIKeys(); err != nil {
+			e.apiKeys.keys[hash] = key
+			return ErrStorage
+		}
+		delete(e.apiKeys.buckets, strings.ToLower(key.Name))
+		return nil
+	}
+	return ErrAPIKeyNotFound
+}
+
+// APIKeys returns a copy of every API key, oldest first.
+func (e *EconomyPlugin) APIKeys() []APIKey {
+	e.apiKeys.mutex.Lock()
+	defer e.apiKeys.mutex.Unlock()
+	
+	keys := make([]APIKey, 0, len(e.apiKeys.keys))
+	for _, key := range e.apiKeys.keys {
+		keys = append(keys, *key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Created.Before(keys[j].Created) })
+	return keys
+}
+
+// authorizeAPI checks a request's token against the shared api_token and
+// the API keys, then the key's scope and rate limit. It returns the name of
+// the key used for the request log, and on ErrRateLimited how long the
+// caller should wait.
+func (e *EconomyPlugin) authorizeAPI(token, legacyToken string, scope APIScope) (string, time.Duration, error) {
+	if token == "" {
+		return "", 0, ErrInvalidAPIKey
+	}
+	if legacyToken != "" && tokenMatches(token, legacyToken) {
+		return legacyKeyName, 0, nil
+	}
+	
+	e.apiKeys.mutex.Lock()
+	defer e.apiKeys.mutex.Unlock()
+	
+	key, exists := e.apiKeys.keys[hashAPIKey(token)]
+	if !exists {
+		return "", 0, ErrInvalidAPIKey
+	}
+	if !key.allows(scope) {
+		return key.Name, 0, ErrAPIKeyScope
+	}
+	if key.RateLimit == 0 {
+		return key.Name, 0, nil
+	}
+	
+	now := e.now()
+	bucketKey := strings.ToLower(key.Name)
+	bucket, exists := e.apiKeys.buckets[bucketKey]
+	if !exists {
+		bucket = &rateBucket{tokens: float64(key.RateLimit), last: now}
+		e.apiKeys.buckets[bucketKey] = bucket
+	}
+	if ok, wait := bucket.take(key.RateLimit, now); !ok {
+		return key.Name, wait, ErrRateLimited
+	}
+	return key.Name, 0, nil
+}
+
+// apiResponseWriter records the status of an HTTP API response for the
+// request log. It passes hijacking through for the WebSocket endpoint.
+type apiResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *apiResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *apiResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+func (e *EconomyPlugin) logAPIRequest(api, key, method, status string, elapsed time.Duration) {
+	e.logger.Info("API request", "api", api, "key", key, "method", method, "status", status, "duration_ms", elapsed.Milliseconds())
+}
+
+func (e *EconomyPlugin) apiKeyCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) == 0 {
+		return e.message("apikey.usage")
+	}
+	
+	switch strings.ToLower(args[0]) {
+	case "create":
+		if len(args) < 3 || len(args) > 4 {
+			return e.message("apikey.usage")
+		}
+		rateLimit := defaultAPIRateLimit
+		if len(args) == 4 {
+			parsed, err := strconv.Atoi(args[3])
+			if err != nil || parsed < 0 {
+				return e.message("apikey.usage")
+			}
+			rateLimit = parsed
+		}
+		
+		key, err := e.CreateAPIKey(args[1], APIScope(strings.ToLower(args[2])), rateLimit)
+		if err != nil {
+			return e.message("apikey.failed", "error", e.describeError(err))
+		}
+		e.logger.Info("Created API key", "admin", ctx.Name(), "name", args[1], "scope", strings.ToLower(args[2]), "rate_limit", rateLimit)
+		e.audit(ctx, "apikey create", "", nil, nil, args[1])
+		return e.message("apikey.created", "name", args[1], "key", key)
+		
+	case "revoke":
+		if len(args) != 2 {
+			return e.message("apikey.usage")
+		}
+		if err := e.RevokeAPIKey(args[1]); err != nil {
+			return e.message("apikey.failed", "error", e.describeError(err))
+		}
+		e.logger.Info("Revoked API key", "admin", ctx.Name(), "name", args[1])
+		e.audit(ctx, "apikey revoke", "", nil, nil, args[1])
+		return e.message("apikey.revoked", "name", args[1])
+		
+	case "list":
+		keys := e.APIKeys()
+		if len(keys) == 0 {
+			return e.message("apikey.none")
+		}
+		result := e.message("apikey.header")
+		for _, key := range keys {
+			limit := e.message("apikey.unlimited")
+			if key.RateLimit > 0 {
+				limit = strconv.Itoa(key.RateLimit) + "/min"
+			}
+			result += "\n" + e.message("apikey.entry", "name", key.Name, "scope", string(key.Scope),
+				"limit", limit, "created", key.Created.Format("2006-01-02"))
+		}
+		return result
+		
+	default:
+		return e.message("apikey.usage")
+	}
+}
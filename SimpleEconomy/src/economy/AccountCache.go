@@ -0,0 +1,365 @@
+package economy
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AccountCacheConfig applies when accounts are loaded on demand: always
+// for storage_backend "files", and for the database backends when Enabled
+// is set. At most MaxAccounts are kept in memory, zero meaning no limit,
+// and a saved account unused for IdleMinutes is dropped even below that.
+// Accounts with unsaved changes stay until saved.
+type AccountCacheConfig struct {
+	Enabled     bool `json:"enabled"`
+	MaxAccounts int  `json:"max_accounts"`
+	IdleMinutes int  `json:"idle_minutes"`
+}
+
+const accountEvictionInterval = time.Minute
+
+// accountCache orders the loaded accounts by when they were last handed
+// out, most recent first, and counts how often a lookup found its account
+// in memory. It is only enabled when loading lazily.
+//
+// Lock ordering: cache.mutex is taken last, under e.mutex when needed, and
+// nothing else is acquired while holding it.
+type accountCache struct {
+	mutex   sync.Mutex
+	enabled bool
+	limit   int
+	order   *list.List
+	entries map[string]*list.Element
+	// full wakes the evictor when the cache grows past limit.
+	full chan struct{}
+	
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type cacheEntry struct {
+	uuid string
+	used time.Time
+}
+
+func (c *accountCache) reset(enabled bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	c.enabled = enabled
+	c.order = list.New()
+	c.entries = make(map[string]*list.Element)
+	if c.full == nil {
+		c.full = make(chan struct{}, 1)
+	}
+}
+
+func (c *accountCache) setLimit(limit int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	c.limit = limit
+}
+
+func (c *accountCache) touch(uuid string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	if !c.enabled {
+		return
+	}
+	if element, exists := c.entries[uuid]; exists {
+		element.Value.(*cacheEntry).used = time.Now()
+		c.order.MoveToFront(element)
+		return
+	}
+	
+	c.entries[uuid] = c.order.PushFront(&cacheEntry{uuid: uuid, used: time.Now()})
+	if c.limit > 0 && c.order.Len() > c.limit {
+		select {
+		case c.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *accountCache) forget(uuid string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	if element, exists := c.entries[uuid]; exists {
+		c.order.Remove(element)
+		delete(c.entries, uuid)
+	}
+}
+
+func (c *accountCache) size() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	if c.order == nil {
+		return 0
+	}
+	return c.order.Len()
+}
+
+// candidates lists, least recently used first, the accounts over limit
+// and those last used before cutoff, with when each was last used.
+func (c *accountCache) candidates(cutoff time.Time) []cacheEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	if c.order == nil {
+		return nil
+	}
+	over := 0
+	if c.limit > 0 {
+		over = c.order.Len() - c.limit
+	}
+	
+	var entries []cacheEntry
+	for element := c.order.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*cacheEntry)
+		if len(entries) >= over && !entry.used.Before(cutoff) {
+			break
+		}
+		entries = append(entries, *entry)
+	}
+	return entries
+}
+
+// unusedSince reports whether uuid has not been handed out since used.
+func (c *accountCache) unusedSince(uuid string, used time.Time) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	element, exists := c.entries[uuid]
+	return !exists || !element.Value.(*cacheEntry).used.After(used)
+}
+
+// lazyStorage returns the storage when accounts are loaded on demand.
+func (e *EconomyPlugin) lazyStorage() (LazyStorage, bool) {
+	return e.lazy, e.lazy != nil
+}
+
+// openLazily reports whether accounts in storage should be loaded on
+// demand. The files backend exists for that; the others opt in.
+func (e *EconomyPlugin) openLazily(storage Storage) LazyStorage {
+	lazy, ok := storage.(LazyStorage)
+	if !ok {
+		return nil
+	}
+	if _, files := storage.(*FileStorage); files || e.config.AccountCache.Enabled {
+		return lazy
+	}
+	return nil
+}
+
+// loadAccount returns the account keyed by uuid, reading it from storage
+// when loading lazily and it is not loaded yet.
+func (e *EconomyPlugin) loadAccount(uuid string) (*PlayerAccount, bool) {
+	e.mutex.RLock()
+	account, exists := e.playerData[uuid]
+	if exists {
+		e.cache.touch(uuid)
+	}
+	removed := e.removed[uuid]
+	e.mutex.RUnlock()
+	
+	lazy, ok := e.lazyStorage()
+	if !ok || removed {
+		return account, exists
+	}
+	if exists {
+		e.cache.hits.Add(1)
+		return account, true
+	}
+	
+	e.cache.misses.Add(1)
+	return e.fetchAccount(lazy, uuid)
+}
+
+// fetchAccount reads uuid's account from storage and starts tracking it,
+// unless someone else loaded or deleted it meanwhile.
+func (e *EconomyPlugin) fetchAccount(lazy LazyStorage, uuid string) (*PlayerAccount, bool) {
+	if !isUUID(uuid) {
+		return nil, false
+	}
+	
+	stored, err := lazy.GetAccount(uuid)
+	if err != nil {
+		e.logger.Error("Failed to load account", "uuid", uuid, "error", err)
+		e.countStorageError("get")
+		return nil, false
+	}
+	if stored == nil {
+		return nil, false
+	}
+	owner, err := lazy.FindAccount(stored.Username)
+	if err != nil {
+		owner = ""
+	}
+	
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	
+	if account, exists := e.playerData[uuid]; exists || e.removed[uuid] {
+		if exists {
+			e.cache.touch(uuid)
+		}
+		return account, exists
+	}
+	stored.shard = accountShard(uuid)
+	e.playerData[uuid] = stored
+	if key := strings.ToLower(stored.Username); owner == uuid && e.playerData[e.names[key]] == nil {
+		e.names[key] = uuid
+	}
+	e.cache.touch(uuid)
+	
+	return stored, true
+}
+
+// lookupLazy finds the account holding username in storage and loads it.
+// A loaded account renamed since it was last saved no longer answers to
+// the name storage still has for it.
+func (e *EconomyPlugin) lookupLazy(lazy LazyStorage, username string) (*PlayerAccount, bool) {
+	e.cache.misses.Add(1)
+	
+	uuid, err := lazy.FindAccount(username)
+	if err != nil {
+		e.logger.Error("Failed to look up account", "player", username, "error", err)
+		e.countStorageError("get")
+		return nil, false
+	}
+	if uuid == "" {
+		return nil, false
+	}
+	
+	account, exists := e.fetchAccount(lazy, uuid)
+	if !exists {
+		return nil, false
+	}
+	
+	key := strings.ToLower(username)
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	
+	if e.playerData[e.names[key]] == nil && strings.EqualFold(account.Username, username) {
+		e.names[key] = account.UUID
+	}
+	account, exists = e.playerData[e.names[key]]
+	return account, exists
+}
+
+// accountCount counts every account, including those not loaded.
+func (e *EconomyPlugin) accountCount() int {
+	if lazy, ok := e.lazyStorage(); ok {
+		if count, err := lazy.CountAccounts(); err == nil {
+			return count
+		}
+	}
+	
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	
+	return len(e.playerData)
+}
+
+// withStoredAccounts adds the stored accounts not loaded to snapshots.
+func (e *EconomyPlugin) withStoredAccounts(snapshots []PlayerAccount) []PlayerAccount {
+	stored, err := e.storage.ListAccounts()
+	if err != nil {
+		e.logger.Error("Failed to list player accounts", "error", err)
+		e.countStorageError("load")
+		return snapshots
+	}
+	
+	loaded := make(map[string]bool, len(snapshots))
+	for _, account := range snapshots {
+		loaded[account.UUID] = true
+	}
+	
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	
+	for _, account := range stored {
+		if !loaded[account.UUID] && !e.removed[account.UUID] {
+			snapshots = append(snapshots, *account)
+		}
+	}
+	return snapshots
+}
+
+// startAccountEviction evicts every minute, and straight away whenever the
+// cache outgrows account_cache.max_accounts.
+func (e *EconomyPlugin) startAccountEviction() {
+	if _, lazy := e.lazyStorage(); !lazy {
+		return
+	}
+	e.cache.setLimit(e.config.AccountCache.MaxAccounts)
+	
+	ctx := e.background.ctx
+	e.background.wg.Add(1)
+	go func() {
+		defer e.background.wg.Done()
+		
+		ticker := time.NewTicker(accountEvictionInterval)
+		defer ticker.Stop()
+		
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-e.cache.full:
+			}
+			e.evictAccounts()
+		}
+	}()
+}
+
+// evictAccounts drops saved accounts from memory, least recently used
+// first, until at most account_cache.max_accounts remain, and drops any
+// unused for account_cache.idle_minutes. Every lookup touches the account
+// it returns under e.mutex, so only a caller sitting on a pointer while
+// its account becomes the least recently used could see it evicted.
+func (e *EconomyPlugin) evictAccounts() {
+	e.saving.Lock()
+	defer e.saving.Unlock()
+	
+	cutoff := time.Now().Add(-time.Duration(e.config.AccountCache.IdleMinutes) * time.Minute)
+	evicted := 0
+	for _, entry := range e.cache.candidates(cutoff) {
+		e.mutex.RLock()
+		account, exists := e.playerData[entry.uuid]
+		e.mutex.RUnlock()
+		if !exists {
+			e.cache.forget(entry.uuid)
+			continue
+		}
+		
+		// The account lock keeps any mutation from running while the
+		// account leaves memory.
+		unlock := e.locks.lock(account)
+		e.mutex.Lock()
+		if e.playerData[entry.uuid] == account && !e.dirty[entry.uuid] && e.cache.unusedSince(entry.uuid, entry.used) {
+			delete(e.playerData, entry.uuid)
+			if key := strings.ToLower(account.Username); e.names[key] == entry.uuid {
+				delete(e.names, key)
+			}
+			e.cache.forget(entry.uuid)
+			evicted++
+		}
+		e.mutex.Unlock()
+		unlock()
+	}
+	
+	if evicted > 0 {
+		e.cache.evictions.Add(uint64(evicted))
+		e.logger.Debug("Evicted accounts from memory", "accounts", evicted)
+	}
+}
@@ -0,0 +1,53 @@
+package economy
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+const accountLockShards = 64
+
+// accountLocks guards the fields of individual PlayerAccounts. Accounts
+// hash onto a fixed set of shards so the lock table never grows with the
+// player count. The shard is fixed when an account is first tracked, so
+// renames and UUID adoption never move an account to another lock.
+//
+// Lock ordering: shard locks are taken in ascending shard index, all at
+// once through lock, and always before EconomyPlugin.mutex. Code holding
+// EconomyPlugin.mutex must never acquire a shard lock. This is what lets a
+// transfer A->B run concurrently with B->A without deadlocking.
+type accountLocks struct {
+	shards [accountLockShards]sync.Mutex
+}
+
+func accountShard(key string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return int(hash.Sum32() % accountLockShards)
+}
+
+// lock acquires the shards for every given account and returns the
+// function that releases them.
+func (l *accountLocks) lock(accounts ...*PlayerAccount) func() {
+	indices := make([]int, 0, len(accounts))
+	seen := make(map[int]bool, len(accounts))
+	for _, account := range accounts {
+		shard := account.shard
+		if !seen[shard] {
+			seen[shard] = true
+			indices = append(indices, shard)
+		}
+	}
+	sort.Ints(indices)
+	
+	for _, shard := range indices {
+		l.shards[shard].Lock()
+	}
+	
+	return func() {
+		for i := len(indices) - 1; i >= 0; i-- {
+			l.shards[indices[i]].Unlock()
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package economy
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestOppositeTransfers runs transfers both ways between two accounts while
+// other goroutines touch them, and checks no money is made or lost. Run it
+// with -race.
+func TestOppositeTransfers(t *testing.T) {
+	e := NewEconomyPlugin()
+	e.dataFolder = t.TempDir()
+	e.saveConfig()
+	e.OnEnable()
+	defer e.OnDisable()
+	
+	e.OnPlayerJoin("00000000-0000-0000-0000-000000000001", "steve")
+	e.OnPlayerJoin("00000000-0000-0000-0000-000000000002", "alex")
+	total := e.getBalance("steve") + e.getBalance("alex")
+	
+	const workers, rounds = 8, 200
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		from, to := "steve", "alex"
+		if i%2 == 1 {
+			from, to = to, from
+		}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				if _, err := e.Transfer(from, to, moneyScale, "race"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				e.getAccount(from)
+				e.getBalance(to)
+			}
+		}()
+	}
+	wg.Wait()
+	
+	if got := e.getBalance("steve") + e.getBalance("alex"); got != total {
+		t.Fatalf("balances add up to %s, want %s", got, total)
+	}
+}
@@ -0,0 +1,96 @@
+package economy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Account creation policies for the account_creation option. Virtual
+// accounts open on first use under all of them.
+const (
+	createAuto     = "auto"
+	createOnJoin   = "join"
+	createExisting = "existing"
+)
+
+// UnknownAccountError is returned for a name that has no account when the
+// creation policy will not open one for it. Suggestion is the closest
+// existing name, if suggest_names is on and one is close enough.
+type UnknownAccountError struct {
+	Name       string
+	Suggestion string
+}
+
+func (err *UnknownAccountError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrAccountNotFound.Error(), err.Name)
+}
+
+func (err *UnknownAccountError) Unwrap() error {
+	return ErrAccountNotFound
+}
+
+func (e *EconomyPlugin) creationPolicy() string {
+	switch policy := strings.ToLower(e.config.AccountCreation); policy {
+	case createOnJoin, createExisting:
+		return policy
+	default:
+		return createAuto
+	}
+}
+
+// mayCreateAccount reports whether using username opens an account for it
+// when it has none. A player with an account may be given one in any
+// scope.
+func (e *EconomyPlugin) mayCreateAccount(username string) bool {
+	if player, scope := splitScoped(username); scope != "" {
+		return e.mayCreateAccount(player) || e.hasAccount(player)
+	}
+	return IsVirtualAccount(username) || e.creationPolicy() == createAuto
+}
+
+// requireAccounts fails with an UnknownAccountError for the first of
+// usernames that has no account and may not be given one.
+func (e *EconomyPlugin) requireAccounts(usernames ...string) error {
+	for _, username := range usernames {
+		if !e.mayCreateAccount(username) && !e.hasAccount(username) {
+			err := &UnknownAccountError{Name: username}
+			if e.config.SuggestNames {
+				err.Suggestion = e.suggestAccount(username)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateAccount opens an account for a player known only by name, whatever
+// the creation policy. It is adopted when the player joins.
+func (e *EconomyPlugin) CreateAccount(username string) error {
+	if username == "" || strings.HasPrefix(username, virtualPrefix) || strings.ContainsAny(username, " #@") {
+		return ErrInvalidAccountName
+	}
+	if e.hasAccount(username) {
+		return ErrAccountExists
+	}
+	e.createAccount(username)
+	
+	return nil
+}
+
+func (e *EconomyPlugin) createCommand(ctx *CommandContext) string {
+	if len(ctx.Args) != 1 {
+		return e.message("create.usage")
+	}
+	
+	player := ctx.Args[0]
+	if err := e.CreateAccount(player); err != nil {
+		if errors.Is(err, ErrInvalidAccountName) {
+			return e.message("create.invalid_name")
+		}
+		return e.message("create.failed", "error", e.describeError(err))
+	}
+	
+	e.audit(ctx, "create", player, nil, nil, "")
+	return e.message("create.created", "player", player)
+}
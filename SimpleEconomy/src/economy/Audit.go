@@ -0,0 +1,240 @@
+package economy
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditPageSize is how many entries /eco audit shows per page.
+const auditPageSize = 10
+
+// AuditEntry records one admin command, separately from the transactions
+// it caused. Old and New hold the target's balance before and after when
+// the command changed a single account.
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Admin     string    `json:"admin"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"`
+	Old       *Money    `json:"old,omitempty"`
+	New       *Money    `json:"new,omitempty"`
+	Details   string    `json:"details,omitempty"`
+}
+
+func (a *AuditEntry) involves(name string) bool {
+	return name == "" || strings.EqualFold(a.Admin, name) || strings.EqualFold(a.Target, name)
+}
+
+// AuditStore persists the admin audit log. QueryAudit returns entries
+// where name is the admin or the target, or every entry when name is
+// empty, newest first.
+type AuditStore interface {
+	AppendAudit(entry *AuditEntry) error
+	QueryAudit(name string, offset, limit int) ([]AuditEntry, error)
+}
+
+// JSONLinesAuditLog appends one JSON object per entry to a file.
+type JSONLinesAuditLog struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewJSONLinesAuditLog(path string) *JSONLinesAuditLog {
+	return &JSONLinesAuditLog{path: path}
+}
+
+func (l *JSONLinesAuditLog) AppendAudit(entry *AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+func (l *JSONLinesAuditLog) QueryAudit(name string, offset, limit int) ([]AuditEntry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	
+	matched := make([]AuditEntry, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.involves(name) {
+			matched = append(matched, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].Timestamp.After(matched[j].Timestamp)
+	})
+	
+	if offset >= len(matched) {
+		return nil, nil
+	}
+	matched = matched[offset:]
+	if limit > 0 && len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched, nil
+}
+
+// buildAuditQuery selects audit entries for the SQL backends.
+func buildAuditQuery(name string, offset, limit int) (string, []interface{}) {
+	query := `SELECT timestamp, admin, action, target, old_balance, new_balance, details FROM audit_log`
+	args := make([]interface{}, 0)
+	
+	if name != "" {
+		query += ` WHERE admin = ? OR target = ?`
+		args = append(args, name, name)
+	}
+	
+	query += ` ORDER BY timestamp DESC, id DESC`
+	
+	if limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, offset)
+	} else if offset > 0 {
+		query += ` LIMIT 1000000000 OFFSET ?`
+		args = append(args, offset)
+	}
+	
+	return query, args
+}
+
+// audit records an admin command. A failure to write the audit log is
+// logged but never undoes the command.
+func (e *EconomyPlugin) audit(ctx *CommandContext, action, target string, before, after *Money, details string) {
+	if e.auditLog == nil {
+		return
+	}
+	
+	entry := &AuditEntry{
+		Timestamp: e.now(),
+		Admin:     ctx.Name(),
+		Action:    action,
+		Target:    target,
+		Old:       before,
+		New:       after,
+		Details:   details,
+	}
+	if err := e.auditLog.AppendAudit(entry); err != nil {
+		e.countStorageError("audit")
+		e.logger.Error("Failed to record admin action", "admin", entry.Admin, "action", action, "target", target, "error", err)
+	}
+}
+
+// auditBalance audits a command that changed one account, given its
+// balance beforehand.
+func (e *EconomyPlugin) auditBalance(ctx *CommandContext, action, target string, before Money, details string) {
+	after := e.peekBalance(target)
+	e.audit(ctx, action, target, &before, &after, details)
+}
+
+// GetAuditLog returns one page of audit entries involving name as admin
+// or target, or of every entry when name is empty.
+func (e *EconomyPlugin) GetAuditLog(name string, offset, limit int) []AuditEntry {
+	if e.auditLog == nil {
+		return nil
+	}
+	
+	entries, err := e.auditLog.QueryAudit(name, offset, limit)
+	if err != nil {
+		e.countStorageError("audit")
+		e.logger.Error("Failed to query audit log", "error", err)
+		return nil
+	}
+	
+	return entries
+}
+
+func (e *EconomyPlugin) auditCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) > 2 {
+		return e.message("audit.usage")
+	}
+	
+	name, page := "", 1
+	if len(args) > 0 {
+		if parsed, err := strconv.Atoi(args[len(args)-1]); err == nil {
+			if parsed < 1 {
+				return e.message("transactions.bad_page")
+			}
+			page = parsed
+			args = args[:len(args)-1]
+		} else if len(args) == 2 {
+			return e.message("transactions.bad_page")
+		}
+	}
+	if len(args) > 0 {
+		name = args[0]
+	}
+	
+	entries := e.GetAuditLog(name, (page-1)*auditPageSize, auditPageSize+1)
+	if len(entries) == 0 {
+		return e.message("audit.empty", "page", strconv.Itoa(page))
+	}
+	
+	hasMore := len(entries) > auditPageSize
+	if hasMore {
+		entries = entries[:auditPageSize]
+	}
+	
+	result := e.message("audit.header", "page", strconv.Itoa(page))
+	if name != "" {
+		result = e.message("audit.header_player", "player", name, "page", strconv.Itoa(page))
+	}
+	for _, entry := range entries {
+		line := strings.TrimSpace(e.message("audit.entry", "time", entry.Timestamp.Format("2006-01-02 15:04"),
+			"admin", entry.Admin, "action", entry.Action, "target", entry.Target))
+		if entry.Old != nil && entry.New != nil {
+			line += " " + e.message("audit.change", "old", e.FormatMoney(*entry.Old), "new", e.FormatMoney(*entry.New))
+		}
+		if entry.Details != "" {
+			line += " (" + entry.Details + ")"
+		}
+		result += "\n" + line
+	}
+	
+	if hasMore {
+		next := strconv.Itoa(page + 1)
+		if name != "" {
+			next = name + " " + next
+		}
+		result += "\n" + e.message("audit.next_page", "next", next)
+	}
+	
+	return result
+}
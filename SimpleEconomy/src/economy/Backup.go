@@ -0,0 +1,390 @@
+package economy
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type BackupConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Schedule string `json:"schedule"`
+	Keep     int    `json:"keep"`
+}
+
+const (
+	backupPrefix        = "economy-"
+	backupTimeLayout    = "20060102-150405.000"
+	backupPlayersEntry  = "players.json"
+	backupSharedEntry   = "shared_accounts.json"
+	restoreConfirmDelay = 30 * time.Second
+)
+
+// backupState remembers restores waiting for the sender to confirm them.
+type backupState struct {
+	mutex   sync.Mutex
+	pending map[string]pendingRestore
+}
+
+type pendingRestore struct {
+	file    string
+	expires time.Time
+}
+
+func (e *EconomyPlugin) backupFolder() string {
+	return filepath.Join(e.dataFolder, "backups")
+}
+
+func (e *EconomyPlugin) startBackupScheduler() {
+	if !e.config.Backup.Enabled {
+		return
+	}
+	
+	schedule, err := parseCronSchedule(e.config.Backup.Schedule)
+	if err != nil {
+		e.logger.Warn("Backups disabled", "error", err)
+		return
+	}
+	
+	e.runOnSchedule(schedule, func() {
+		if name, err := e.CreateBackup(); err != nil {
+			e.logger.Error("Failed to create backup", "error", err)
+		} else {
+			e.logger.Info("Created backup", "file", name)
+		}
+	})
+}
+
+// CreateBackup flushes pending changes and writes every stored account,
+// whatever the backend, to a zip archive in the backups folder. Archives
+// beyond backup.keep are pruned, oldest first. It returns the file name.
+func (e *EconomyPlugin) CreateBackup() (string, error) {
+	e.savePlayerData()
+	
+	accounts, err := e.storage.ListAccounts()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	
+	players := make(map[string]*PlayerAccount, len(accounts))
+	for _, account := range accounts {
+		players[account.UUID] = account
+	}
+	playersData, err := json.MarshalIndent(players, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	
+	e.shared.mutex.Lock()
+	sharedData, err := json.MarshalIndent(e.shared.accounts, "", "  ")
+	e.shared.mutex.Unlock()
+	if err != nil {
+		return "", err
+	}
+	
+	if err := os.MkdirAll(e.backupFolder(), 0755); err != nil {
+		return "", err
+	}
+	
+	name, file, err := e.createBackupFile()
+	if err != nil {
+		return "", err
+	}
+	path := file.Name()
+	if err := writeBackupArchive(file, map[string][]byte{
+		backupPlayersEntry: playersData,
+		backupSharedEntry:  sharedData,
+	}); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	
+	e.pruneBackups()
+	return name, nil
+}
+
+// createBackupFile creates a new archive named after the current time.
+// It never reuses a name, so the backup a restore takes first cannot
+// overwrite the archive being restored.
+func (e *EconomyPlugin) createBackupFile() (string, *os.File, error) {
+	for {
+		name := backupPrefix + time.Now().Format(backupTimeLayout) + ".zip"
+		file, err := os.OpenFile(filepath.Join(e.backupFolder(), name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if os.IsExist(err) {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		return name, file, err
+	}
+}
+
+func writeBackupArchive(file *os.File, entries map[string][]byte) error {
+	defer file.Close()
+	
+	archive := zip.NewWriter(file)
+	for name, data := range entries {
+		writer, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(data); err != nil {
+			return err
+		}
+	}
+	
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func (e *EconomyPlugin) pruneBackups() {
+	keep := e.config.Backup.Keep
+	if keep <= 0 {
+		return
+	}
+	
+	backups, err := e.ListBackups()
+	if err != nil || len(backups) <= keep {
+		return
+	}
+	
+	for _, name := range backups[keep:] {
+		if err := os.Remove(filepath.Join(e.backupFolder(), name)); err != nil {
+			e.logger.Warn("Failed to remove old backup", "file", name, "error", err)
+		}
+	}
+}
+
+// ListBackups returns the backup archives in the backups folder, newest
+// first.
+func (e *EconomyPlugin) ListBackups() ([]string, error) {
+	entries, err := ioutil.ReadDir(e.backupFolder())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	backups := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, ".zip") {
+			backups = append(backups, name)
+		}
+	}
+	
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// RestoreBackup replaces every stored account and shared account with the
+// contents of the named archive. A fresh backup is taken first so the
+// restore itself can be undone.
+func (e *EconomyPlugin) RestoreBackup(name string) error {
+	path, err := e.backupPath(name)
+	if err != nil {
+		return err
+	}
+	
+	entries, err := readBackupArchive(path)
+	if err != nil {
+		return err
+	}
+	
+	players := make(map[string]*PlayerAccount)
+	if err := json.Unmarshal(entries[backupPlayersEntry], &players); err != nil {
+		return fmt.Errorf("backup %s has no readable %s: %v", name, backupPlayersEntry, err)
+	}
+	
+	safety, err := e.CreateBackup()
+	if err != nil {
+		return fmt.Errorf("could not back up current data before restoring: %v", err)
+	}
+	e.logger.Info("Saved current data before restoring", "backup", safety, "file", name)
+	
+	if err := e.restoreAccounts(players); err != nil {
+		return fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	
+	if data, ok := entries[backupSharedEntry]; ok {
+		if err := writeFileAtomic(e.sharedAccountsPath(), data, 0644); err != nil {
+			return err
+		}
+		e.loadSharedAccounts()
+	}
+	
+	// Changes journaled since the safety backup predate the restore and
+	// must not be replayed over it.
+	if e.journal != nil {
+		e.journal.discard(e.journal.rotate())
+	}
+	
+	e.loadPlayerData()
+	e.logger.Info("Restored backup", "file", name, "accounts", len(players))
+	
+	if e.config.DoubleEntry {
+		if err := e.postOpeningBalances(openingReason + " restored from " + name); err != nil {
+			e.logger.Error("Failed to post opening balances", "error", err)
+		}
+	}
+	return nil
+}
+
+// backupPath resolves name, with or without its .zip extension, to an
+// existing archive in the backups folder.
+func (e *EconomyPlugin) backupPath(name string) (string, error) {
+	if name == "" || filepath.Base(name) != name {
+		return "", ErrBackupNotFound
+	}
+	if !strings.HasSuffix(name, ".zip") {
+		name += ".zip"
+	}
+	
+	path := filepath.Join(e.backupFolder(), name)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrBackupNotFound
+	}
+	
+	return path, nil
+}
+
+func readBackupArchive(path string) (map[string][]byte, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+	
+	entries := make(map[string][]byte)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		entries[file.Name] = data
+	}
+	
+	return entries, nil
+}
+
+// restoreAccounts makes storage hold exactly players. Shared backends only
+// take balances through UpdateAccounts, so those are written there.
+func (e *EconomyPlugin) restoreAccounts(players map[string]*PlayerAccount) error {
+	current, err := e.storage.ListAccounts()
+	if err != nil {
+		return err
+	}
+	for _, account := range current {
+		if _, kept := players[account.UUID]; !kept {
+			if err := e.storage.DeleteAccount(account.UUID); err != nil {
+				return err
+			}
+		}
+	}
+	
+	seeds := make([]PlayerAccount, 0, len(players))
+	for uuid, account := range players {
+		account.UUID = uuid
+		if err := e.storage.PutAccount(account); err != nil {
+			return err
+		}
+		seeds = append(seeds, *account)
+	}
+	
+	if err := e.storage.Save(); err != nil {
+		return err
+	}
+	
+	if shared, ok := e.storage.(AtomicStorage); ok && len(seeds) > 0 {
+		return shared.UpdateAccounts(seeds, func(accounts []*PlayerAccount) error {
+			for i, account := range accounts {
+				copyMoney(account, &seeds[i])
+			}
+			return nil
+		})
+	}
+	
+	return nil
+}
+
+func (e *EconomyPlugin) backupCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) > 0 && strings.EqualFold(args[0], "now") {
+		name, err := e.CreateBackup()
+		if err != nil {
+			return e.message("backup.failed", "error", e.describeError(err))
+		}
+		return e.message("backup.created", "file", name)
+	}
+	
+	backups, err := e.ListBackups()
+	if err != nil {
+		return e.message("backup.failed", "error", e.describeError(err))
+	}
+	if len(backups) == 0 {
+		return e.message("backup.none")
+	}
+	
+	result := e.message("backup.header", "count", strconv.Itoa(len(backups))) + "\n"
+	for _, name := range backups {
+		result += e.message("backup.entry", "file", name) + "\n"
+	}
+	
+	return result
+}
+
+// restoreCommand asks for the same command again with "confirm" appended
+// before restoring, since a restore overwrites every balance.
+func (e *EconomyPlugin) restoreCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) == 0 {
+		return e.message("restore.usage")
+	}
+	
+	file := args[0]
+	if _, err := e.backupPath(file); err != nil {
+		return e.message("restore.failed", "error", e.describeError(err))
+	}
+	key := strings.ToLower(ctx.Name())
+	
+	e.backups.mutex.Lock()
+	if e.backups.pending == nil {
+		e.backups.pending = make(map[string]pendingRestore)
+	}
+	pending, waiting := e.backups.pending[key]
+	confirmed := len(args) > 1 && strings.EqualFold(args[1], "confirm") &&
+		waiting && pending.file == file && e.now().Before(pending.expires)
+	if confirmed {
+		delete(e.backups.pending, key)
+	} else {
+		e.backups.pending[key] = pendingRestore{file: file, expires: e.now().Add(restoreConfirmDelay)}
+	}
+	e.backups.mutex.Unlock()
+	
+	if !confirmed {
+		return e.message("restore.confirm", "file", file, "seconds", strconv.Itoa(int(restoreConfirmDelay/time.Second)))
+	}
+	
+	if err := e.RestoreBackup(file); err != nil {
+		return e.message("restore.failed", "error", e.describeError(err))
+	}
+	e.audit(ctx, "restore", "", nil, nil, file)
+	
+	return e.message("restore.success", "file", file)
+}
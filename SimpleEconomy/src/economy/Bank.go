@@ -0,0 +1,181 @@
+package economy
+
+import "strings"
+
+// bankInterestReason marks INTEREST paid into the bank rather than the
+// wallet.
+const bankInterestReason = "Bank interest"
+
+func (e *EconomyPlugin) GetBankBalance(username string) (Money, error) {
+	if !e.hasAccount(username) {
+		return 0, ErrAccountNotFound
+	}
+	
+	account := e.getAccount(username)
+	
+	unlock := e.locks.lock(account)
+	defer unlock()
+	
+	return account.BankBalance, nil
+}
+
+// BankDeposit moves money from the wallet into the bank.
+func (e *EconomyPlugin) BankDeposit(username string, amount Money) error {
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	
+	err := e.mutateAccounts([]string{username}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if account.spendable() < amount {
+			return ErrInsufficientFunds
+		}
+		if account.BankBalance+amount > e.config.BankMaxBalance {
+			return ErrBankLimitExceeded
+		}
+		
+		account.Balance -= amount
+		account.BankBalance += amount
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	
+	e.invalidateTopPlayers()
+	e.recordTransaction(&Transaction{
+		From:      username,
+		To:        username,
+		Amount:    amount,
+		Type:      BANK_DEPOSIT,
+		Timestamp: e.now(),
+		Reason:    "Bank deposit",
+	})
+	
+	return nil
+}
+
+// BankWithdraw moves money from the bank back into the wallet.
+func (e *EconomyPlugin) BankWithdraw(username string, amount Money) error {
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	
+	err := e.mutateAccounts([]string{username}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if account.BankBalance < amount {
+			return ErrInsufficientFunds
+		}
+		if account.Balance+amount > e.config.MaxBalance {
+			return ErrMaxBalanceExceeded
+		}
+		
+		account.BankBalance -= amount
+		account.Balance += amount
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	
+	e.invalidateTopPlayers()
+	e.recordTransaction(&Transaction{
+		From:      username,
+		To:        username,
+		Amount:    amount,
+		Type:      BANK_WITHDRAW,
+		Timestamp: e.now(),
+		Reason:    "Bank withdrawal",
+	})
+	
+	return nil
+}
+
+// payBankInterest credits bank_interest_rate percent of a bank balance,
+// clamped to bank_max_balance.
+func (e *EconomyPlugin) payBankInterest(username string) Money {
+	balance, err := e.GetBankBalance(username)
+	if err != nil {
+		return 0
+	}
+	allowed, err := e.allowMint(username, "bank interest", e.bankInterestFor(balance))
+	if err != nil {
+		return 0
+	}
+	
+	var interest Money
+	err = e.mutateAccounts([]string{username}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if interest = e.bankInterestFor(account.BankBalance); interest > allowed {
+			interest = allowed
+		}
+		if interest <= 0 {
+			return ErrInvalidAmount
+		}
+		
+		account.BankBalance += interest
+		account.TotalEarned += interest
+		return nil
+	})
+	if err != nil {
+		return 0
+	}
+	
+	e.recordTransaction(&Transaction{
+		To:        username,
+		Amount:    interest,
+		Type:      INTEREST,
+		Timestamp: e.now(),
+		Reason:    bankInterestReason,
+	})
+	
+	return interest
+}
+
+func (e *EconomyPlugin) bankInterestFor(balance Money) Money {
+	interest := e.roundAmount(balance.MulRate(e.config.BankInterestRate / 100))
+	if headroom := e.config.BankMaxBalance - balance; interest > headroom {
+		interest = headroom
+	}
+	return interest
+}
+
+func (e *EconomyPlugin) bankCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) == 0 {
+		return e.message("bank.usage")
+	}
+	
+	player := ctx.Name()
+	
+	switch strings.ToLower(args[0]) {
+	case "balance":
+		e.getAccount(player)
+		balance, _ := e.GetBankBalance(player)
+		return e.message("bank.balance", "amount", e.FormatMoney(balance), "wallet", e.FormatMoney(e.getBalance(player)))
+		
+	case "deposit", "withdraw":
+		if len(args) < 2 {
+			return e.message("bank.amount_usage", "action", strings.ToLower(args[0]))
+		}
+		amount, err := e.parseAmount(args[1])
+		if err != nil {
+			return e.message("error.invalid_amount")
+		}
+		
+		if strings.ToLower(args[0]) == "deposit" {
+			if err := e.BankDeposit(player, amount); err != nil {
+				return e.message("bank.deposit_failed", "error", e.describeError(err))
+			}
+			return e.message("bank.deposit", "amount", e.FormatMoney(amount))
+		}
+		
+		if err := e.BankWithdraw(player, amount); err != nil {
+			return e.message("bank.withdraw_failed", "error", e.describeError(err))
+		}
+		return e.message("bank.withdraw", "amount", e.FormatMoney(amount))
+		
+	default:
+		return e.message("bank.invalid")
+	}
+}
@@ -0,0 +1,300 @@
+package economy
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	banNone         = "none"
+	banFreeze       = "freeze"
+	banConfiscate   = "confiscate"
+	banRedistribute = "redistribute"
+)
+
+// banFreezeReason marks the hold a freeze puts on a banned player's wallet,
+// so that lifting the ban releases only that hold.
+const banFreezeReason = "Frozen: banned"
+
+// BanPolicyConfig says what happens to a banned player's money, Permanent
+// for permanent bans and Temporary for the rest: none, freeze, confiscate
+// or redistribute. A freeze holds the wallet until the ban is lifted; the
+// bank is left alone. Confiscation takes wallet and bank to Recipient, and
+// redistribution shares them out evenly between the other players, giving
+// Recipient what does not divide. With no recipient that money is
+// destroyed. Hide keeps banned players off /top and out of /eco stats.
+type BanPolicyConfig struct {
+	Permanent string `json:"permanent"`
+	Temporary string `json:"temporary"`
+	Recipient string `json:"recipient"`
+	Hide      bool   `json:"hide"`
+}
+
+func validBanAction(action string) bool {
+	switch strings.ToLower(action) {
+	case banNone, banFreeze, banConfiscate, banRedistribute:
+		return true
+	}
+	return false
+}
+
+// OnPlayerBanned applies the ban policy to username's balance. Ban plugins
+// call it when a player is banned; whatever the policy does is recorded as
+// a CONFISCATE transaction.
+func (e *EconomyPlugin) OnPlayerBanned(username string, permanent bool) {
+	if IsVirtualAccount(username) || !e.hasAccount(username) {
+		return
+	}
+	
+	policy := e.config.BanPolicy
+	action := strings.ToLower(policy.Temporary)
+	if permanent {
+		action = strings.ToLower(policy.Permanent)
+	}
+	
+	var amount Money
+	var err error
+	switch action {
+	case banFreeze:
+		amount, err = e.freezeBalance(username)
+	case banConfiscate:
+		amount, err = e.confiscateBalance(username, false)
+	case banRedistribute:
+		amount, err = e.confiscateBalance(username, true)
+	}
+	if err != nil {
+		e.logger.Warn("Failed to apply ban policy", "player", username, "action", action, "error", err)
+	} else if amount > 0 {
+		e.logger.Info("Applied ban policy", "player", username, "action", action, "amount", amount.String())
+	}
+	
+	if policy.Hide {
+		if err := e.SetAccountHidden(username, true); err != nil {
+			e.logger.Warn("Failed to hide banned account", "player", username, "error", err)
+		}
+	}
+}
+
+// OnPlayerUnbanned undoes what can be undone of a ban: a frozen wallet is
+// released, and the account shown again when ban_policy.hide is set.
+// Confiscated money is not returned.
+func (e *EconomyPlugin) OnPlayerUnbanned(username string) {
+	if !e.hasAccount(username) {
+		return
+	}
+	
+	var released Money
+	err := e.mutateAccounts([]string{username}, func(accounts []*PlayerAccount) error {
+		accounts[0].Holds, released = withoutBanFreeze(accounts[0].Holds)
+		return nil
+	})
+	if err != nil {
+		e.logger.Warn("Failed to unfreeze unbanned account", "player", username, "error", err)
+	} else if released > 0 {
+		e.recordTransaction(&Transaction{
+			From:      username,
+			To:        username,
+			Amount:    released,
+			Type:      CONFISCATE,
+			Timestamp: e.now(),
+			Reason:    "Balance unfrozen on unban",
+		})
+	}
+	
+	if e.config.BanPolicy.Hide {
+		if err := e.SetAccountHidden(username, false); err != nil {
+			e.logger.Warn("Failed to show unbanned account", "player", username, "error", err)
+		}
+	}
+}
+
+// withoutBanFreeze returns holds minus any a ban freeze placed, and the
+// amount those held.
+func withoutBanFreeze(holds []Hold) ([]Hold, Money) {
+	var kept []Hold
+	var released Money
+	for _, hold := range holds {
+		if hold.Reason == banFreezeReason {
+			released += hold.Amount
+			continue
+		}
+		kept = append(kept, hold)
+	}
+	if released == 0 {
+		return holds, 0
+	}
+	return kept, released
+}
+
+// freezeBalance puts the spendable part of username's wallet on hold.
+func (e *EconomyPlugin) freezeBalance(username string) (Money, error) {
+	id, err := newHoldID()
+	if err != nil {
+		return 0, err
+	}
+	
+	var frozen Money
+	err = e.mutateAccounts([]string{username}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if frozen = account.spendable(); frozen <= 0 {
+			frozen = 0
+			return nil
+		}
+		hold := Hold{ID: id, Amount: frozen, Reason: banFreezeReason, Created: e.now()}
+		account.Holds = append(append([]Hold(nil), account.Holds...), hold)
+		return nil
+	})
+	if err != nil || frozen == 0 {
+		return 0, err
+	}
+	
+	e.recordTransaction(&Transaction{
+		From:      username,
+		To:        username,
+		Amount:    frozen,
+		Type:      CONFISCATE,
+		Timestamp: e.now(),
+		Reason:    "Balance frozen on ban",
+	})
+	return frozen, nil
+}
+
+// confiscateBalance takes username's wallet, apart from holds other than a
+// ban freeze, and bank balance. It goes to ban_policy.recipient, or when
+// redistribute is set is shared between every other visible player, with
+// the recipient getting the remainder. Nobody is paid past max_balance;
+// money with nowhere to go is destroyed.
+func (e *EconomyPlugin) confiscateBalance(username string, redistribute bool) (Money, error) {
+	recipient := e.config.BanPolicy.Recipient
+	if strings.EqualFold(recipient, username) {
+		recipient = ""
+	}
+	
+	usernames := []string{username}
+	if recipient != "" {
+		usernames = append(usernames, recipient)
+	}
+	if err := e.requireAccounts(usernames...); err != nil {
+		return 0, err
+	}
+	accounts := make([]*PlayerAccount, 0, len(usernames))
+	for _, name := range usernames {
+		accounts = append(accounts, e.getAccount(name))
+	}
+	
+	shared := len(accounts)
+	if redistribute {
+		others, err := e.filterAccounts(AccountFilter{})
+		if err != nil {
+			return 0, err
+		}
+		for _, other := range others {
+			snapshot := e.snapshotAccount(other)
+			if strings.EqualFold(snapshot.Username, username) || strings.EqualFold(snapshot.Username, recipient) || e.hiddenAccount(&snapshot) {
+				continue
+			}
+			accounts = append(accounts, other)
+		}
+	}
+	
+	var names []string
+	var balances, changes []Money
+	var total Money
+	err := e.mutateLoaded(accounts, func(locked []*PlayerAccount) error {
+		banned := locked[0]
+		holds, _ := withoutBanFreeze(banned.Holds)
+		banned.Holds = holds
+		
+		fromWallet := clampZero(banned.spendable())
+		fromBank := clampZero(banned.BankBalance)
+		if total = fromWallet + fromBank; total == 0 {
+			return nil
+		}
+		
+		names = make([]string, len(locked))
+		balances = make([]Money, len(locked))
+		changes = make([]Money, len(locked))
+		credit := func(i int, amount Money) Money {
+			if room := clampZero(e.config.MaxBalance - locked[i].Balance); amount > room {
+				amount = room
+			}
+			changes[i] = amount
+			return amount
+		}
+		
+		left := total
+		if players := len(locked) - shared; players > 0 {
+			share := (total / Money(players)).Truncate(e.decimalPlaces())
+			for i := shared; i < len(locked); i++ {
+				left -= credit(i, share)
+			}
+		}
+		if shared > 1 {
+			credit(1, left)
+		}
+		changes[0] = -fromWallet
+		
+		for i, account := range locked {
+			names[i], balances[i] = account.Username, account.Balance
+			account.Balance += changes[i]
+			account.TotalEarned += clampZero(changes[i])
+		}
+		banned.BankBalance -= fromBank
+		banned.TotalSpent += total
+		return nil
+	})
+	if err != nil || total == 0 {
+		return 0, err
+	}
+	
+	e.invalidateTopPlayers()
+	paid := 0
+	entries := make([]BatchEntry, 0, len(changes))
+	for i, change := range changes {
+		if change == 0 {
+			continue
+		}
+		e.fireBalanceChange(names[i], balances[i], balances[i]+change, CONFISCATE)
+		entries = append(entries, BatchEntry{Player: names[i], Amount: change})
+		if i >= shared {
+			paid++
+		}
+	}
+	
+	transaction := &Transaction{
+		From:      username,
+		Amount:    total,
+		Type:      CONFISCATE,
+		Timestamp: e.now(),
+		Reason:    "Balance confiscated on ban",
+		Batch:     entries,
+	}
+	if redistribute {
+		transaction.Reason = "Balance redistributed on ban (" + strconv.Itoa(paid) + " accounts)"
+	} else if shared > 1 && changes[1] > 0 {
+		transaction.To = names[1]
+	}
+	transaction.Postings = e.postings(confiscationLegs(transaction)...)
+	e.recordTransaction(transaction)
+	
+	return total, nil
+}
+
+// confiscationLegs are the postings of a CONFISCATE transaction: the wallet
+// changes in its batch, and the rest of Amount out of From's bank. A freeze
+// moves nothing.
+func confiscationLegs(transaction *Transaction) []Posting {
+	if transaction.From == transaction.To {
+		return nil
+	}
+	
+	fromBank := transaction.Amount
+	legs := make([]Posting, 0, len(transaction.Batch)+1)
+	for _, entry := range transaction.Batch {
+		legs = append(legs, Posting{Account: entry.Player, Amount: entry.Amount})
+		if strings.EqualFold(entry.Player, transaction.From) {
+			fromBank += entry.Amount
+		}
+	}
+	return append(legs, Posting{Account: bankRef(transaction.From), Amount: -fromBank})
+}
@@ -0,0 +1,313 @@
+package economy
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccountFilter picks the accounts GiveAll and TakeAll apply to. The zero
+// value picks every account.
+type AccountFilter struct {
+	OnlineOnly bool
+	SeenWithin time.Duration
+}
+
+// TransferRequest is one payment in a MultiTransfer.
+type TransferRequest struct {
+	From   string
+	To     string
+	Amount Money
+}
+
+// BatchEntry is one account's change within a BATCH transaction: positive
+// for money received, negative for money taken.
+type BatchEntry struct {
+	Player string `json:"player"`
+	Amount Money  `json:"amount"`
+}
+
+// BatchResult reports how many accounts a batch changed and how much money
+// it moved.
+type BatchResult struct {
+	Accounts int
+	Amount   Money
+}
+
+// GiveAll credits amount to every account the filter picks. Accounts that
+// would pass max_balance receive what fits.
+func (e *EconomyPlugin) GiveAll(amount Money, filter AccountFilter, reason string) (BatchResult, error) {
+	if amount <= 0 {
+		return BatchResult{}, ErrInvalidAmount
+	}
+	
+	accounts, err := e.filterAccounts(filter)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	
+	// Under a scaling supply cap everyone gets the same cut-down share.
+	wanted := amount * Money(len(accounts))
+	allowed, err := e.allowMint("all players", "give all", wanted)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	if allowed < wanted {
+		amount = (allowed / Money(len(accounts))).Truncate(e.decimalPlaces())
+		if amount <= 0 {
+			return BatchResult{}, ErrSupplyCapReached
+		}
+	}
+	
+	return e.applyBatch(accounts, reasonOr(reason, "Money given to all players"), func(locked []*PlayerAccount) ([]Money, error) {
+		changes := make([]Money, len(locked))
+		for i, account := range locked {
+			changes[i] = amount
+			if room := e.config.MaxBalance - account.Balance; room < amount {
+				changes[i] = clampZero(room)
+			}
+		}
+		return changes, nil
+	})
+}
+
+// TakeAll removes amount from every account the filter picks. Accounts
+// holding less give up what they have, though never money on hold.
+func (e *EconomyPlugin) TakeAll(amount Money, filter AccountFilter, reason string) (BatchResult, error) {
+	if amount <= 0 {
+		return BatchResult{}, ErrInvalidAmount
+	}
+	
+	accounts, err := e.filterAccounts(filter)
+	if err != nil {
+		return BatchResult{}, err
+	}
+	
+	return e.applyBatch(accounts, reasonOr(reason, "Money taken from all players"), func(locked []*PlayerAccount) ([]Money, error) {
+		changes := make([]Money, len(locked))
+		for i, account := range locked {
+			changes[i] = -amount
+			if account.spendable() < amount {
+				changes[i] = -clampZero(account.spendable())
+			}
+		}
+		return changes, nil
+	})
+}
+
+// MultiTransfer makes every payment or none of them. Payments are applied
+// in order, so a sender may spend money received earlier in the batch.
+func (e *EconomyPlugin) MultiTransfer(requests []TransferRequest, reason string) (BatchResult, error) {
+	accounts := make([]*PlayerAccount, 0, len(requests)*2)
+	positions := make(map[string]int)
+	position := func(username string) int {
+		key := strings.ToLower(username)
+		if i, exists := positions[key]; exists {
+			return i
+		}
+		positions[key] = len(accounts)
+		accounts = append(accounts, e.getAccount(username))
+		return positions[key]
+	}
+	
+	for _, request := range requests {
+		if request.Amount <= 0 {
+			return BatchResult{}, ErrInvalidAmount
+		}
+		if strings.EqualFold(request.From, request.To) {
+			return BatchResult{}, ErrSelfTransfer
+		}
+		if !e.hasAccount(request.From) {
+			return BatchResult{}, ErrAccountNotFound
+		}
+		if err := e.requireAccounts(request.To); err != nil {
+			return BatchResult{}, err
+		}
+	}
+	for _, request := range requests {
+		if e.fireTransfer(request.From, request.To, request.Amount) {
+			return BatchResult{}, ErrTransferCancelled
+		}
+	}
+	
+	from := make([]int, len(requests))
+	to := make([]int, len(requests))
+	for i, request := range requests {
+		from[i], to[i] = position(request.From), position(request.To)
+	}
+	
+	return e.applyBatch(accounts, reasonOr(reason, "Batch transfer"), func(locked []*PlayerAccount) ([]Money, error) {
+		balances := make([]Money, len(locked))
+		held := make([]Money, len(locked))
+		for i, account := range locked {
+			balances[i], held[i] = account.Balance, account.held()
+		}
+		
+		now := e.now()
+		for i, request := range requests {
+			if err := e.checkRules(&ruleEnv{
+				amount:      request.Amount,
+				reason:      reason,
+				from:        locked[from[i]],
+				to:          locked[to[i]],
+				fromBalance: balances[from[i]],
+				toBalance:   balances[to[i]],
+				now:         now,
+			}); err != nil {
+				return nil, err
+			}
+			if balances[from[i]]-held[from[i]] < request.Amount {
+				return nil, ErrInsufficientFunds
+			}
+			if balances[to[i]]+request.Amount > e.config.MaxBalance {
+				return nil, ErrMaxBalanceExceeded
+			}
+			balances[from[i]] -= request.Amount
+			balances[to[i]] += request.Amount
+		}
+		
+		changes := make([]Money, len(locked))
+		for i, account := range locked {
+			changes[i] = balances[i] - account.Balance
+		}
+		return changes, nil
+	})
+}
+
+// filterAccounts loads the player accounts a filter picks, including any
+// a lazy backend has left on disk. Virtual accounts are never picked.
+func (e *EconomyPlugin) filterAccounts(filter AccountFilter) ([]*PlayerAccount, error) {
+	var online map[string]bool
+	if filter.OnlineOnly {
+		players, ok := e.getOnlinePlayers()
+		if !ok {
+			return nil, ErrNoPlayerProvider
+		}
+		online = make(map[string]bool, len(players))
+		for _, player := range players {
+			online[strings.ToLower(player)] = true
+		}
+	}
+	cutoff := e.now().Add(-filter.SeenWithin)
+	
+	accounts := make([]*PlayerAccount, 0)
+	for _, snapshot := range e.snapshotAccounts() {
+		if snapshot.virtual() || online != nil && !online[strings.ToLower(snapshot.Username)] {
+			continue
+		}
+		if filter.SeenWithin > 0 && snapshot.LastSeen.Before(cutoff) {
+			continue
+		}
+		if account, exists := e.loadAccount(snapshot.UUID); exists {
+			accounts = append(accounts, account)
+		}
+	}
+	
+	return accounts, nil
+}
+
+// applyBatch changes each account by the amount plan returns for it, as a
+// single mutation recorded as one BATCH transaction. plan runs with the
+// accounts locked; an error from it aborts the whole batch.
+func (e *EconomyPlugin) applyBatch(accounts []*PlayerAccount, reason string, plan func(locked []*PlayerAccount) ([]Money, error)) (BatchResult, error) {
+	if len(accounts) == 0 {
+		return BatchResult{}, nil
+	}
+	
+	var names []string
+	var balances, changes []Money
+	err := e.mutateLoaded(accounts, func(locked []*PlayerAccount) error {
+		planned, err := plan(locked)
+		if err != nil {
+			return err
+		}
+		
+		names = make([]string, len(locked))
+		balances = make([]Money, len(locked))
+		for i, account := range locked {
+			names[i], balances[i] = account.Username, account.Balance
+			account.Balance += planned[i]
+			if planned[i] > 0 {
+				account.TotalEarned += planned[i]
+			} else {
+				account.TotalSpent -= planned[i]
+			}
+		}
+		changes = planned
+		return nil
+	})
+	if err != nil {
+		return BatchResult{}, err
+	}
+	
+	var result BatchResult
+	var credited, debited Money
+	entries := make([]BatchEntry, 0, len(changes))
+	for i, change := range changes {
+		if change == 0 {
+			continue
+		}
+		
+		e.fireBalanceChange(names[i], balances[i], balances[i]+change, BATCH)
+		entries = append(entries, BatchEntry{Player: names[i], Amount: change})
+		if change > 0 {
+			credited += change
+		} else {
+			debited -= change
+		}
+	}
+	if len(entries) == 0 {
+		return result, nil
+	}
+	
+	result.Accounts = len(entries)
+	result.Amount = credited
+	if debited > credited {
+		result.Amount = debited
+	}
+	
+	e.invalidateTopPlayers()
+	e.recordTransaction(&Transaction{
+		Amount:    result.Amount,
+		Type:      BATCH,
+		Timestamp: e.now(),
+		Reason:    reason + " (" + strconv.Itoa(result.Accounts) + " accounts)",
+		Batch:     entries,
+	})
+	
+	return result, nil
+}
+
+func (e *EconomyPlugin) giveAllCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) < 1 || len(args) > 2 {
+		return e.message("giveall.usage")
+	}
+	
+	amount, err := e.parseAmount(args[0])
+	if err != nil || amount <= 0 {
+		return e.message("error.invalid_amount")
+	}
+	
+	var filter AccountFilter
+	if len(args) > 1 {
+		if strings.EqualFold(args[1], "online") {
+			filter.OnlineOnly = true
+		} else if filter.SeenWithin, err = parseDuration(args[1]); err != nil || filter.SeenWithin <= 0 {
+			return e.message("giveall.usage")
+		}
+	}
+	
+	result, err := e.GiveAll(amount, filter, "Given to all players by "+ctx.Name())
+	if err != nil {
+		return e.message("giveall.failed", "error", e.describeError(err))
+	}
+	if result.Accounts == 0 {
+		return e.message("giveall.none")
+	}
+	
+	players := strconv.Itoa(result.Accounts) + " players"
+	e.notifyAdminAction("giveall", ctx.Name(), players, amount)
+	e.audit(ctx, "giveall", "", nil, nil, e.FormatMoney(amount)+" to "+players)
+	return e.message("giveall.done", "amount", e.FormatMoney(amount), "count", strconv.Itoa(result.Accounts), "total", e.FormatMoney(result.Amount))
+}
@@ -0,0 +1,219 @@
+package economy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BenchOptions describes the load Bench generates. Rate caps the
+// operations started per second across all workers; zero runs them as
+// fast as the workers can. ReadShare is the fraction of operations that
+// read the balance leaderboard instead of transferring.
+type BenchOptions struct {
+	Backend   string
+	Accounts  int
+	Workers   int
+	Rate      int
+	Duration  time.Duration
+	ReadShare float64
+}
+
+// BenchStats summarizes one kind of operation Bench ran. Throughput is
+// per second; the latencies are of the operations that succeeded.
+type BenchStats struct {
+	Count      int
+	Failed     int
+	Throughput float64
+	P50        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+}
+
+// BenchResult is what a Bench run measured. AllocsPerOp and BytesPerOp
+// cover the whole process while the load ran.
+type BenchResult struct {
+	Options     BenchOptions
+	Transfers   BenchStats
+	Reads       BenchStats
+	Elapsed     time.Duration
+	AllocsPerOp float64
+	BytesPerOp  float64
+}
+
+const benchAccountPrefix = "bench_"
+
+// Bench opens empty storage of options.Backend in a temporary folder with
+// the default config, creates options.Accounts accounts and has
+// options.Workers workers transfer small amounts between random pairs of
+// them, and read the leaderboard, for options.Duration. An empty backend
+// uses the active one; the database backends are refused so a configured
+// database never fills with bench accounts. Nothing in the data folder is
+// changed.
+func (e *EconomyPlugin) Bench(options BenchOptions) (BenchResult, error) {
+	if options.Backend == "" {
+		options.Backend = e.config.StorageBackend
+	}
+	backend, err := normalizeBackend(options.Backend)
+	if err != nil {
+		return BenchResult{}, err
+	}
+	if remoteBackend(backend) {
+		return BenchResult{}, fmt.Errorf("%w: bench cannot use %s", ErrWrongBackend, backend)
+	}
+	options.Backend = backend
+	if options.Accounts < 2 {
+		options.Accounts = 2
+	}
+	if options.Workers < 1 {
+		options.Workers = 1
+	}
+	if options.Duration <= 0 {
+		options.Duration = 10 * time.Second
+	}
+	
+	dir, err := ioutil.TempDir("", "economy-bench-")
+	if err != nil {
+		return BenchResult{}, err
+	}
+	defer os.RemoveAll(dir)
+	
+	bench := NewEconomyPlugin()
+	bench.dataFolder = dir
+	config := defaultConfig()
+	config.StorageBackend = backend
+	config.Backup.Enabled = false
+	bench.writeConfig(config)
+	if err := bench.openData(); err != nil {
+		return BenchResult{}, err
+	}
+	defer bench.Close()
+	
+	names := make([]string, options.Accounts)
+	for i := range names {
+		names[i] = benchAccountPrefix + strconv.Itoa(i+1)
+		if err := bench.CreateAccount(names[i]); err != nil {
+			return BenchResult{}, fmt.Errorf("create %s: %w", names[i], err)
+		}
+	}
+	if _, err := bench.savePlayerData(); err != nil {
+		return BenchResult{}, err
+	}
+	
+	var tokens chan struct{}
+	stop := make(chan struct{})
+	if options.Rate > 0 {
+		tokens = make(chan struct{}, options.Workers)
+		go func() {
+			ticker := time.NewTicker(time.Second / time.Duration(options.Rate))
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					select {
+					case tokens <- struct{}{}:
+					default:
+					}
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	
+	type sample struct {
+		read    bool
+		latency time.Duration
+		failed  bool
+	}
+	samples := make([][]sample, options.Workers)
+	deadline := time.Now().Add(options.Duration)
+	
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	started := time.Now()
+	
+	var workers sync.WaitGroup
+	for w := range samples {
+		workers.Add(1)
+		go func(w int) {
+			defer workers.Done()
+			random := rand.New(rand.NewSource(started.UnixNano() + int64(w)))
+			for time.Now().Before(deadline) {
+				if tokens != nil {
+					select {
+					case <-tokens:
+					case <-time.After(time.Until(deadline)):
+						return
+					}
+				}
+				
+				var s sample
+				begun := time.Now()
+				if s.read = random.Float64() < options.ReadShare; s.read {
+					_, err := bench.GetLeaderboard(TopBalance)
+					s.failed = err != nil
+				} else {
+					from := random.Intn(len(names))
+					to := (from + 1 + random.Intn(len(names)-1)) % len(names)
+					_, err := bench.Transfer(names[from], names[to], moneyScale/100, "Bench")
+					s.failed = err != nil
+				}
+				s.latency = time.Since(begun)
+				samples[w] = append(samples[w], s)
+			}
+		}(w)
+	}
+	workers.Wait()
+	close(stop)
+	
+	result := BenchResult{Options: options, Elapsed: time.Since(started)}
+	runtime.ReadMemStats(&after)
+	
+	var transfers, reads []time.Duration
+	for _, worker := range samples {
+		for _, s := range worker {
+			stats, latencies := &result.Transfers, &transfers
+			if s.read {
+				stats, latencies = &result.Reads, &reads
+			}
+			stats.Count++
+			if s.failed {
+				stats.Failed++
+			} else {
+				*latencies = append(*latencies, s.latency)
+			}
+		}
+	}
+	result.Transfers.summarize(transfers, result.Elapsed)
+	result.Reads.summarize(reads, result.Elapsed)
+	
+	if ops := result.Transfers.Count + result.Reads.Count; ops > 0 {
+		result.AllocsPerOp = float64(after.Mallocs-before.Mallocs) / float64(ops)
+		result.BytesPerOp = float64(after.TotalAlloc-before.TotalAlloc) / float64(ops)
+	}
+	
+	e.logger.Info("Bench finished", "backend", backend, "transfers", result.Transfers.Count,
+		"reads", result.Reads.Count, "elapsed", result.Elapsed)
+	return result, nil
+}
+
+func (s *BenchStats) summarize(latencies []time.Duration, elapsed time.Duration) {
+	s.Throughput = float64(s.Count) / elapsed.Seconds()
+	if len(latencies) == 0 {
+		return
+	}
+	
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+	s.P50, s.P99, s.Max = percentile(0.50), percentile(0.99), latencies[len(latencies)-1]
+}
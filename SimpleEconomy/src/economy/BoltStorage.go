@@ -0,0 +1,435 @@
+package economy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	
+	"go.etcd.io/bbolt"
+)
+
+var (
+	boltAccounts       = []byte("accounts")
+	boltNames          = []byte("names")
+	boltTransactions   = []byte("transactions")
+	boltTransactionIDs = []byte("transaction_ids")
+)
+
+// boltCompactMinSize is the smallest file compaction is considered for;
+// below it the free pages are not worth a rewrite.
+const boltCompactMinSize = 1 << 20
+
+// BoltStorage keeps accounts and the transaction ledger in one bbolt file.
+// Accounts are JSON values under their UUID in the accounts bucket, and
+// names indexes them by lowercased username. Transactions are keyed by
+// timestamp then sequence, so a cursor walks them in time order. Save
+// writes every staged change in a single bbolt transaction.
+type BoltStorage struct {
+	path    string
+	config  BoltConfig
+	db      *bbolt.DB
+	pending map[string]PlayerAccount
+	deleted map[string]bool
+	mutex   sync.Mutex
+}
+
+func NewBoltStorage(path string, config BoltConfig) *BoltStorage {
+	return &BoltStorage{
+		path:    path,
+		config:  config,
+		pending: make(map[string]PlayerAccount),
+		deleted: make(map[string]bool),
+	}
+}
+
+// Load compacts the file first when at least bolt.compact_free_percent of
+// it is free pages, since bbolt never gives freed space back on its own.
+func (s *BoltStorage) Load() error {
+	if s.config.CompactFreePercent > 0 {
+		if err := compactBolt(s.path, s.config.CompactFreePercent); err != nil {
+			return err
+		}
+	}
+	
+	db, err := bbolt.Open(s.path, 0644, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range [][]byte{boltAccounts, boltNames, boltTransactions, boltTransactionIDs} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+	
+	s.db = db
+	return nil
+}
+
+// compactBolt rewrites the file at path without its free pages if they
+// make up at least percent of it.
+func compactBolt(path string, percent int) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && info.Size() < boltCompactMinSize) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	
+	src, err := bbolt.Open(path, 0644, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	stats := src.Stats()
+	free := int64(stats.FreePageN+stats.PendingPageN) * int64(src.Info().PageSize)
+	if free*100 < info.Size()*int64(percent) {
+		return src.Close()
+	}
+	
+	compacted := path + ".compact"
+	os.Remove(compacted)
+	dst, err := bbolt.Open(compacted, 0644, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		src.Close()
+		return err
+	}
+	err = bbolt.Compact(dst, src, 64<<20)
+	src.Close()
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(compacted)
+		return err
+	}
+	if err := os.Rename(compacted, path); err != nil {
+		os.Remove(compacted)
+		return err
+	}
+	
+	after, err := os.Stat(path)
+	if err == nil {
+		slog.Info("Compacted bolt storage", "path", path, "before", info.Size(), "after", after.Size())
+	}
+	return nil
+}
+
+func boltNameKey(username, uuid string) []byte {
+	return []byte(strings.ToLower(username) + "\x00" + uuid)
+}
+
+func boltTime(t time.Time) []byte {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(t.UnixNano()))
+	return value
+}
+
+func (s *BoltStorage) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if len(s.pending) == 0 && len(s.deleted) == 0 {
+		return nil
+	}
+	
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		accounts, names := tx.Bucket(boltAccounts), tx.Bucket(boltNames)
+		unindex := func(key string) error {
+			stored, err := decodeBoltAccount(accounts.Get([]byte(key)))
+			if err != nil || stored == nil {
+				return err
+			}
+			return names.Delete(boltNameKey(stored.Username, key))
+		}
+		
+		for key := range s.deleted {
+			if err := unindex(key); err != nil {
+				return err
+			}
+			if err := accounts.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		for key, account := range s.pending {
+			data, err := json.Marshal(account)
+			if err != nil {
+				return err
+			}
+			if err := unindex(key); err != nil {
+				return err
+			}
+			if err := accounts.Put([]byte(key), data); err != nil {
+				return err
+			}
+			if err := names.Put(boltNameKey(account.Username, key), boltTime(account.LastSeen)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	
+	s.pending = make(map[string]PlayerAccount)
+	s.deleted = make(map[string]bool)
+	return nil
+}
+
+func decodeBoltAccount(data []byte) (*PlayerAccount, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var account PlayerAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (s *BoltStorage) GetAccount(uuid string) (*PlayerAccount, error) {
+	s.mutex.Lock()
+	if account, exists := s.pending[uuid]; exists {
+		s.mutex.Unlock()
+		return &account, nil
+	}
+	deleted := s.deleted[uuid]
+	s.mutex.Unlock()
+	
+	if deleted {
+		return nil, nil
+	}
+	
+	var account *PlayerAccount
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		account, err = decodeBoltAccount(tx.Bucket(boltAccounts).Get([]byte(uuid)))
+		return err
+	})
+	return account, err
+}
+
+func (s *BoltStorage) PutAccount(account *PlayerAccount) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	s.pending[account.UUID] = *account
+	delete(s.deleted, account.UUID)
+	return nil
+}
+
+func (s *BoltStorage) DeleteAccount(uuid string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	delete(s.pending, uuid)
+	s.deleted[uuid] = true
+	return nil
+}
+
+func (s *BoltStorage) ListAccounts() ([]*PlayerAccount, error) {
+	accounts := make(map[string]*PlayerAccount)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(boltAccounts).ForEach(func(key, value []byte) error {
+			account, err := decodeBoltAccount(value)
+			if err != nil {
+				return err
+			}
+			accounts[string(key)] = account
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	s.mutex.Lock()
+	for key := range s.deleted {
+		delete(accounts, key)
+	}
+	for key, account := range s.pending {
+		pending := account
+		accounts[key] = &pending
+	}
+	s.mutex.Unlock()
+	
+	result := make([]*PlayerAccount, 0, len(accounts))
+	for _, account := range accounts {
+		result = append(result, account)
+	}
+	
+	return result, nil
+}
+
+// FindAccount also sees accounts staged since the last Save.
+func (s *BoltStorage) FindAccount(username string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	found, foundSeen := "", int64(0)
+	consider := func(uuid string, lastSeen int64) {
+		if found == "" || lastSeen > foundSeen {
+			found, foundSeen = uuid, lastSeen
+		}
+	}
+	
+	prefix := []byte(strings.ToLower(username) + "\x00")
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket(boltNames).Cursor()
+		for key, value := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+			uuid := string(key[len(prefix):])
+			if _, staged := s.pending[uuid]; !staged && !s.deleted[uuid] {
+				consider(uuid, int64(binary.BigEndian.Uint64(value)))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	for uuid, account := range s.pending {
+		if strings.EqualFold(account.Username, username) {
+			consider(uuid, account.LastSeen.UnixNano())
+		}
+	}
+	
+	return found, nil
+}
+
+func (s *BoltStorage) CountAccounts() (int, error) {
+	var count int
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		count = tx.Bucket(boltAccounts).Stats().KeyN
+		return nil
+	})
+	return count, err
+}
+
+func (s *BoltStorage) AppendTransaction(transaction *Transaction) error {
+	data, err := json.Marshal(transaction)
+	if err != nil {
+		return err
+	}
+	
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		transactions := tx.Bucket(boltTransactions)
+		sequence, err := transactions.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 16)
+		copy(key, boltTime(transaction.Timestamp))
+		binary.BigEndian.PutUint64(key[8:], sequence)
+		
+		if err := transactions.Put(key, data); err != nil {
+			return err
+		}
+		if transaction.ID != "" {
+			return tx.Bucket(boltTransactionIDs).Put([]byte(transaction.ID), key)
+		}
+		return nil
+	})
+}
+
+func (s *BoltStorage) ReassignTransactions(from, into string) (int, error) {
+	changed := 0
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		changed = 0
+		transactions := tx.Bucket(boltTransactions)
+		rewritten := make(map[string][]byte)
+		err := transactions.ForEach(func(key, value []byte) error {
+			var transaction Transaction
+			if err := json.Unmarshal(value, &transaction); err != nil {
+				return err
+			}
+			if !transaction.reassign(from, into) {
+				return nil
+			}
+			data, err := json.Marshal(&transaction)
+			if err != nil {
+				return err
+			}
+			rewritten[string(key)] = data
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		
+		// Changing values while ForEach walks the bucket is not allowed.
+		for key, data := range rewritten {
+			if err := transactions.Put([]byte(key), data); err != nil {
+				return err
+			}
+		}
+		changed = len(rewritten)
+		return nil
+	})
+	return changed, err
+}
+
+func (s *BoltStorage) QueryTransactions(username string, filter TransactionFilter) ([]Transaction, error) {
+	transactions := make([]Transaction, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(boltTransactions)
+		
+		if filter.ID != "" {
+			key := tx.Bucket(boltTransactionIDs).Get([]byte(filter.ID))
+			if key == nil {
+				return nil
+			}
+			var transaction Transaction
+			if err := json.Unmarshal(bucket.Get(key), &transaction); err != nil {
+				return err
+			}
+			if filter.matches(username, &transaction) && filter.Offset == 0 {
+				transactions = append(transactions, transaction)
+			}
+			return nil
+		}
+		
+		skipped := 0
+		cursor := bucket.Cursor()
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			var transaction Transaction
+			if err := json.Unmarshal(value, &transaction); err != nil {
+				return err
+			}
+			if !filter.Since.IsZero() && transaction.Timestamp.Before(filter.Since) {
+				break
+			}
+			if !filter.matches(username, &transaction) {
+				continue
+			}
+			if skipped < filter.Offset {
+				skipped++
+				continue
+			}
+			transactions = append(transactions, transaction)
+			if filter.Limit > 0 && len(transactions) >= filter.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	return transactions, err
+}
+
+func (s *BoltStorage) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,19 @@
+package economy_test
+
+import (
+	"path/filepath"
+	"testing"
+	
+	"github.com/percocets100/SimpleEconomy/SimpleEconomy/src/economy"
+	"github.com/percocets100/SimpleEconomy/SimpleEconomy/src/economy/storagetest"
+)
+
+// TestBoltStorage compacts whenever half the file is free, so reopening
+// in the persistence tests goes through compaction too.
+func TestBoltStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Opener {
+		path := filepath.Join(t.TempDir(), "players.db")
+		config := economy.BoltConfig{CompactFreePercent: 50}
+		return func() (economy.Storage, error) { return economy.NewBoltStorage(path, config), nil }
+	})
+}
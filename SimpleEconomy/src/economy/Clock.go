@@ -0,0 +1,69 @@
+package economy
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the plugin the time for everything it records or schedules
+// by: account timestamps, transactions, interest, expiries, cooldowns and
+// daily rewards. Durations it measures, such as request latencies, always
+// use the system clock.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SetClock replaces the system clock, so tests can move time forward
+// without waiting. It must be called before OnEnable; nil restores the
+// system clock.
+func (e *EconomyPlugin) SetClock(clock Clock) {
+	if clock == nil {
+		clock = systemClock{}
+	}
+	e.clock = clock
+}
+
+func (e *EconomyPlugin) now() time.Time {
+	return e.clock.Now()
+}
+
+// ManualClock is a Clock that only moves when told to.
+type ManualClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewManualClock returns a ManualClock reading start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	return c.now
+}
+
+// Set moves the clock to now.
+func (c *ManualClock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	c.now = now
+}
+
+// Advance moves the clock forward by d and returns the new time.
+func (c *ManualClock) Advance(d time.Duration) time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	c.now = c.now.Add(d)
+	return c.now
+}
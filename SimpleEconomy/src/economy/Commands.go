@@ -0,0 +1,183 @@
+package economy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CommandContext describes one command invocation.
+type CommandContext struct {
+	Sender CommandSender
+	Label  string
+	Args   []string
+}
+
+func (c *CommandContext) Name() string    { return c.Sender.Name() }
+func (c *CommandContext) UUID() string    { return c.Sender.UUID() }
+func (c *CommandContext) IsConsole() bool { return c.Sender.IsConsole() }
+
+// withArgs returns a context for a nested handler that receives args.
+func (c *CommandContext) withArgs(label string, args []string) *CommandContext {
+	return &CommandContext{Sender: c.Sender, Label: label, Args: args}
+}
+
+// Command is a command registered with a CommandDispatcher. Permission is
+// checked before Handler runs; handlers check finer-grained nodes
+// themselves. PlayerOnly commands act on the sender's own account and are
+// refused for the console, and for players without one that
+// account_creation will not open. Complete, if set, offers tab completions
+// for the last of ctx.Args.
+type Command struct {
+	Name        string
+	Aliases     []string
+	Description string
+	Usage       string
+	Permission  string
+	PlayerOnly  bool
+	Handler     func(ctx *CommandContext) string
+	Complete    func(ctx *CommandContext) []string
+}
+
+// CommandDispatcher routes invocations to registered commands. A host
+// server registers each entry of Commands with its own command map and
+// forwards invocations to Execute; RunConsole drives it from a terminal.
+type CommandDispatcher struct {
+	plugin    *EconomyPlugin
+	commands  map[string]*Command
+	cooldowns commandCooldowns
+	mutex     sync.RWMutex
+}
+
+func newCommandDispatcher(plugin *EconomyPlugin) *CommandDispatcher {
+	return &CommandDispatcher{
+		plugin:   plugin,
+		commands: make(map[string]*Command),
+	}
+}
+
+// Register adds cmd under its name and aliases.
+func (d *CommandDispatcher) Register(cmd *Command) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	
+	labels := append([]string{cmd.Name}, cmd.Aliases...)
+	for _, label := range labels {
+		if _, exists := d.commands[strings.ToLower(label)]; exists {
+			return fmt.Errorf("command /%s is already registered", label)
+		}
+	}
+	for _, label := range labels {
+		d.commands[strings.ToLower(label)] = cmd
+	}
+	
+	return nil
+}
+
+// Commands returns every registered command once, sorted by name.
+func (d *CommandDispatcher) Commands() []*Command {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	
+	seen := make(map[*Command]bool, len(d.commands))
+	commands := make([]*Command, 0, len(d.commands))
+	for _, cmd := range d.commands {
+		if !seen[cmd] {
+			seen[cmd] = true
+			commands = append(commands, cmd)
+		}
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	
+	return commands
+}
+
+// Execute runs the command registered under label for sender.
+func (d *CommandDispatcher) Execute(sender CommandSender, label string, args []string) string {
+	d.mutex.RLock()
+	cmd, exists := d.commands[strings.ToLower(label)]
+	d.mutex.RUnlock()
+	
+	if !exists {
+		return d.plugin.message("command.unknown", "command", label)
+	}
+	if cmd.PlayerOnly && sender.IsConsole() {
+		return d.plugin.message("command.player_only")
+	}
+	if !d.plugin.hasPermission(sender, cmd.Permission) {
+		return d.plugin.message("command.no_permission")
+	}
+	if cmd.PlayerOnly && !d.plugin.mayCreateAccount(sender.Name()) && !d.plugin.hasAccount(sender.Name()) {
+		return d.plugin.message("command.no_account")
+	}
+	if wait := d.enterCooldown(sender, cmd); wait > 0 {
+		return d.plugin.message("command.cooldown", "command", cmd.Name, "time", formatWait(wait))
+	}
+	
+	done, running := d.plugin.shutdown.enter()
+	if !running {
+		return d.plugin.message("error.shutting_down")
+	}
+	defer done()
+	
+	return cmd.Handler(&CommandContext{Sender: sender, Label: label, Args: args})
+}
+
+// Complete returns the tab completions for the word being typed, the last
+// of args, or nil if the command offers none to sender. Player names come
+// from the OnlinePlayerProvider, so the host server feeds completions by
+// registering one.
+func (d *CommandDispatcher) Complete(sender CommandSender, label string, args []string) []string {
+	d.mutex.RLock()
+	cmd, exists := d.commands[strings.ToLower(label)]
+	d.mutex.RUnlock()
+	
+	if !exists || cmd.Complete == nil || len(args) == 0 {
+		return nil
+	}
+	if cmd.PlayerOnly && sender.IsConsole() {
+		return nil
+	}
+	if !d.plugin.hasPermission(sender, cmd.Permission) {
+		return nil
+	}
+	
+	return cmd.Complete(&CommandContext{Sender: sender, Label: label, Args: args})
+}
+
+// Dispatch parses a command line such as "/pay Steve 10" and executes it.
+func (d *CommandDispatcher) Dispatch(sender CommandSender, line string) string {
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), "/"))
+	if len(fields) == 0 {
+		return ""
+	}
+	
+	return d.Execute(sender, fields[0], fields[1:])
+}
+
+// RunConsole reads command lines from in and executes them as the console,
+// writing responses to out, until in is exhausted or "stop" is entered.
+func (e *EconomyPlugin) RunConsole(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			break
+		}
+		
+		line := strings.TrimSpace(scanner.Text())
+		if line == "stop" || line == "exit" {
+			break
+		}
+		if response := e.commands.Dispatch(ConsoleSender, line); response != "" {
+			fmt.Fprintln(out, strings.TrimRight(response, "\n"))
+		}
+	}
+	
+	return scanner.Err()
+}
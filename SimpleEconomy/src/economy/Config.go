@@ -0,0 +1,636 @@
+package economy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errInvalidConfig wraps the errors Validate returns, which stop the
+// config being used at all.
+var errInvalidConfig = errors.New("invalid config")
+
+// ConfigCorrection records one value Validate replaced, or one key
+// readConfig filled in because the config file left it out.
+type ConfigCorrection struct {
+	Key     string
+	Problem string
+}
+
+func (c ConfigCorrection) String() string {
+	return c.Key + ": " + c.Problem
+}
+
+// configFixer collects the corrections made while validating.
+type configFixer struct {
+	corrections []ConfigCorrection
+}
+
+func (f *configFixer) note(key, format string, args ...interface{}) {
+	f.corrections = append(f.corrections, ConfigCorrection{Key: key, Problem: fmt.Sprintf(format, args...)})
+}
+
+func (f *configFixer) money(key string, value *Money, fallback Money, problem string) {
+	f.note(key, "%s %s, using %s", value.String(), problem, fallback.String())
+	*value = fallback
+}
+
+func (f *configFixer) int(key string, value *int, fallback int, problem string) {
+	f.note(key, "%d %s, using %d", *value, problem, fallback)
+	*value = fallback
+}
+
+func (f *configFixer) float(key string, value *float64, fallback float64, problem string) {
+	f.note(key, "%g %s, using %g", *value, problem, fallback)
+	*value = fallback
+}
+
+func (f *configFixer) string(key string, value *string, fallback, problem string) {
+	f.note(key, "%q %s, using %q", *value, problem, fallback)
+	*value = fallback
+}
+
+// Validate replaces values that cannot work with the defaults, or clamps
+// them into range, and returns what it changed. Problems that have no safe
+// replacement, such as an unknown storage backend, are returned as an
+// error instead and leave c untouched.
+func (c *Config) Validate() ([]ConfigCorrection, error) {
+	switch strings.ToLower(c.StorageBackend) {
+	case "", "json", "files", "sqlite", "bolt", "mysql", "postgres", "mongodb":
+	default:
+		return nil, fmt.Errorf("storage_backend: unknown backend %q", c.StorageBackend)
+	}
+	
+	defaults := defaultConfig()
+	f := &configFixer{}
+	
+	if c.MaxBalance <= 0 {
+		f.money("max_balance", &c.MaxBalance, defaults.MaxBalance, "is not positive")
+	}
+	if c.MinBalance > 0 {
+		f.money("min_balance", &c.MinBalance, 0, "is above zero")
+	}
+	if c.DefaultBalance < 0 {
+		f.money("default_balance", &c.DefaultBalance, 0, "is negative")
+	}
+	if c.DefaultBalance > c.MaxBalance {
+		f.money("default_balance", &c.DefaultBalance, c.MaxBalance, "is above max_balance")
+	}
+	groups := make([]string, 0, len(c.StartingBalances))
+	for group := range c.StartingBalances {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		balance := c.StartingBalances[group]
+		if balance < 0 {
+			f.money("starting_balances."+group, &balance, 0, "is negative")
+		}
+		if balance > c.MaxBalance {
+			f.money("starting_balances."+group, &balance, c.MaxBalance, "is above max_balance")
+		}
+		c.StartingBalances[group] = balance
+	}
+	
+	if c.DecimalPlaces < 0 {
+		f.int("decimal_places", &c.DecimalPlaces, 0, "is negative")
+	}
+	if c.DecimalPlaces > moneyDigits {
+		f.int("decimal_places", &c.DecimalPlaces, moneyDigits, "is more than Money can hold")
+	}
+	switch strings.ToLower(c.RoundingMode) {
+	case roundFloor, roundHalf, roundCeil:
+	default:
+		f.string("rounding_mode", &c.RoundingMode, defaults.RoundingMode, "is not floor, round or ceil")
+	}
+	if c.MinTransaction < 0 {
+		f.money("min_transaction_amount", &c.MinTransaction, 0, "is negative")
+	}
+	if c.TopPlayersLimit <= 0 {
+		f.int("top_players_limit", &c.TopPlayersLimit, defaults.TopPlayersLimit, "is not positive")
+	}
+	if c.TopPageSize <= 0 {
+		f.int("top_page_size", &c.TopPageSize, defaults.TopPageSize, "is not positive")
+	}
+	if c.AutoSaveSeconds < 0 {
+		f.int("auto_save_interval_seconds", &c.AutoSaveSeconds, defaults.AutoSaveSeconds, "is negative")
+	}
+	switch strings.ToLower(c.AccountCreation) {
+	case createAuto, createOnJoin, createExisting:
+	default:
+		f.string("account_creation", &c.AccountCreation, defaults.AccountCreation,
+			"is not "+createAuto+", "+createOnJoin+" or "+createExisting)
+	}
+	if c.IdempotencyKeyHours < 0 {
+		f.int("idempotency_key_hours", &c.IdempotencyKeyHours, defaults.IdempotencyKeyHours, "is negative")
+	}
+	switch strings.ToLower(c.Format.NegativeStyle) {
+	case "", negativeMinus, negativeParentheses:
+	default:
+		f.string("money_format.negative_style", &c.Format.NegativeStyle, defaults.Format.NegativeStyle,
+			"is not "+negativeMinus+" or "+negativeParentheses)
+	}
+	if _, err := parseLogLevel(c.Logging.Level); err != nil {
+		f.string("logging.level", &c.Logging.Level, defaults.Logging.Level, "is not a log level")
+	}
+	if c.TransactionLog.MaxSizeMB < 0 {
+		f.int("transaction_log.max_size_mb", &c.TransactionLog.MaxSizeMB, defaults.TransactionLog.MaxSizeMB, "is negative")
+	}
+	
+	if c.Bolt.CompactFreePercent < 0 || c.Bolt.CompactFreePercent > 100 {
+		f.int("bolt.compact_free_percent", &c.Bolt.CompactFreePercent, defaults.Bolt.CompactFreePercent, "is not between 0 and 100")
+	}
+	
+	if c.MySQL.Port <= 0 || c.MySQL.Port > 65535 {
+		f.int("mysql.port", &c.MySQL.Port, defaults.MySQL.Port, "is not a port")
+	}
+	if c.MySQL.PoolSize <= 0 {
+		f.int("mysql.pool_size", &c.MySQL.PoolSize, defaults.MySQL.PoolSize, "is not positive")
+	}
+	if c.Postgres.Port <= 0 || c.Postgres.Port > 65535 {
+		f.int("postgres.port", &c.Postgres.Port, defaults.Postgres.Port, "is not a port")
+	}
+	if c.Postgres.PoolSize <= 0 {
+		f.int("postgres.pool_size", &c.Postgres.PoolSize, defaults.Postgres.PoolSize, "is not positive")
+	}
+	if c.Postgres.SSLMode == "" {
+		f.string("postgres.sslmode", &c.Postgres.SSLMode, defaults.Postgres.SSLMode, "is empty")
+	}
+	
+	if c.Mongo.URI == "" {
+		f.string("mongo.uri", &c.Mongo.URI, defaults.Mongo.URI, "is empty")
+	}
+	if c.Mongo.Database == "" {
+		f.string("mongo.database", &c.Mongo.Database, defaults.Mongo.Database, "is empty")
+	}
+	
+	if c.InterestRate < 0 {
+		f.float("interest_rate", &c.InterestRate, 0, "is negative")
+	}
+	if c.InterestInterval <= 0 {
+		f.int("interest_interval", &c.InterestInterval, defaults.InterestInterval, "is not positive")
+	}
+	if c.SupplyCap.Max < 0 {
+		f.money("supply_cap.max", &c.SupplyCap.Max, 0, "is negative")
+	}
+	if c.BankMaxBalance < 0 {
+		f.money("bank_max_balance", &c.BankMaxBalance, defaults.BankMaxBalance, "is negative")
+	}
+	if c.BankInterestRate < 0 {
+		f.float("bank_interest_rate", &c.BankInterestRate, 0, "is negative")
+	}
+	commands := make([]string, 0, len(c.CommandCooldowns))
+	for command := range c.CommandCooldowns {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	for _, command := range commands {
+		seconds := c.CommandCooldowns[command]
+		if seconds < 0 {
+			f.int("command_cooldowns."+command, &seconds, 0, "is negative")
+		}
+		delete(c.CommandCooldowns, command)
+		c.CommandCooldowns[strings.ToLower(command)] = seconds
+	}
+	
+	if c.TransferFees.FlatFee < 0 {
+		f.money("transfer_fees.flat_fee", &c.TransferFees.FlatFee, 0, "is negative")
+	}
+	
+	if c.LeaderboardRefresh != leaderboardOnChange && c.LeaderboardRefresh != leaderboardInterval {
+		f.string("leaderboard_refresh", &c.LeaderboardRefresh, defaults.LeaderboardRefresh,
+			"is not "+leaderboardOnChange+" or "+leaderboardInterval)
+	}
+	if c.LeaderboardRefreshSeconds <= 0 {
+		f.int("leaderboard_refresh_seconds", &c.LeaderboardRefreshSeconds, defaults.LeaderboardRefreshSeconds, "is not positive")
+	}
+	if c.BalanceHistoryDays < 0 {
+		f.int("balance_history_days", &c.BalanceHistoryDays, defaults.BalanceHistoryDays, "is negative")
+	}
+	if c.BalanceHistorySeconds <= 0 {
+		f.int("balance_history_interval_seconds", &c.BalanceHistorySeconds, defaults.BalanceHistorySeconds, "is not positive")
+	}
+	
+	if _, err := parseCronSchedule(c.Backup.Schedule); err != nil {
+		f.string("backup.schedule", &c.Backup.Schedule, defaults.Backup.Schedule, "is not a cron schedule")
+	}
+	if c.Backup.Keep < 0 {
+		f.int("backup.keep", &c.Backup.Keep, defaults.Backup.Keep, "is negative")
+	}
+	
+	if c.DailyReward.Amount < 0 {
+		f.money("daily_reward.amount", &c.DailyReward.Amount, defaults.DailyReward.Amount, "is negative")
+	}
+	if c.VoteRewards.Reward < 0 {
+		f.money("vote_rewards.reward", &c.VoteRewards.Reward, defaults.VoteRewards.Reward, "is negative")
+	}
+	multipliers := c.VoteRewards.DailyMultipliers[:0:0]
+	for i, multiplier := range c.VoteRewards.DailyMultipliers {
+		if multiplier < 0 {
+			f.note("vote_rewards.daily_multipliers."+strconv.Itoa(i), "%v is negative, ignoring it", multiplier)
+			continue
+		}
+		multipliers = append(multipliers, multiplier)
+	}
+	c.VoteRewards.DailyMultipliers = multipliers
+	if c.Jobs.DailyCap < 0 {
+		f.money("jobs.daily_cap", &c.Jobs.DailyCap, 0, "is negative")
+	}
+	if c.Jobs.DefaultJobCap < 0 {
+		f.money("jobs.default_job_cap", &c.Jobs.DefaultJobCap, 0, "is negative")
+	}
+	jobs := make([]string, 0, len(c.Jobs.JobCaps))
+	for job := range c.Jobs.JobCaps {
+		jobs = append(jobs, job)
+	}
+	sort.Strings(jobs)
+	for _, job := range jobs {
+		if limit := c.Jobs.JobCaps[job]; limit < 0 {
+			f.money("jobs.job_caps."+job, &limit, 0, "is negative")
+			c.Jobs.JobCaps[job] = limit
+		}
+	}
+	if c.Notifications.MinAmount < 0 {
+		f.money("notifications.min_amount", &c.Notifications.MinAmount, 0, "is negative")
+	}
+	if _, err := parseCronSchedule(c.HealthReport.Schedule); err != nil {
+		f.string("health_report.schedule", &c.HealthReport.Schedule, defaults.HealthReport.Schedule, "is not a cron schedule")
+	}
+	if c.HealthReport.Keep < 0 {
+		f.int("health_report.keep", &c.HealthReport.Keep, defaults.HealthReport.Keep, "is negative")
+	}
+	denominations := c.Denominations.Items[:0:0]
+	for i, denomination := range c.Denominations.Items {
+		key := "denominations.items." + strconv.Itoa(i)
+		switch {
+		case strings.TrimSpace(denomination.Item) == "":
+			f.note(key, "has no item, ignoring it")
+		case denomination.Value <= 0:
+			f.note(key, "%s is not worth a positive amount, ignoring it", denomination.Item)
+		default:
+			duplicate := false
+			for _, kept := range denominations {
+				duplicate = duplicate || strings.EqualFold(kept.Item, denomination.Item)
+			}
+			if duplicate {
+				f.note(key, "%s is listed twice, ignoring it", denomination.Item)
+				continue
+			}
+			denominations = append(denominations, denomination)
+		}
+	}
+	c.Denominations.Items = denominations
+	if _, err := parseCronSchedule(c.WealthTax.Schedule); err != nil {
+		f.string("wealth_tax.schedule", &c.WealthTax.Schedule, defaults.WealthTax.Schedule, "is not a cron schedule")
+	}
+	if c.WealthTax.Threshold < 0 {
+		f.money("wealth_tax.threshold", &c.WealthTax.Threshold, 0, "is negative")
+	}
+	if c.WealthTax.Percent < 0 || c.WealthTax.Percent > 100 {
+		f.float("wealth_tax.percent", &c.WealthTax.Percent, defaults.WealthTax.Percent, "is not between 0 and 100")
+	}
+	if c.WealthTax.WarningHours < 0 {
+		f.int("wealth_tax.warning_hours", &c.WealthTax.WarningHours, 0, "is negative")
+	}
+	if !validBanAction(c.BanPolicy.Permanent) {
+		f.string("ban_policy.permanent", &c.BanPolicy.Permanent, defaults.BanPolicy.Permanent, "is not none, freeze, confiscate or redistribute")
+	}
+	if !validBanAction(c.BanPolicy.Temporary) {
+		f.string("ban_policy.temporary", &c.BanPolicy.Temporary, defaults.BanPolicy.Temporary, "is not none, freeze, confiscate or redistribute")
+	}
+	if c.BanPolicy.Recipient != "" && !validVirtualName(c.BanPolicy.Recipient) {
+		f.string("ban_policy.recipient", &c.BanPolicy.Recipient, defaults.BanPolicy.Recipient, "is not a virtual account name")
+	}
+	switch strings.ToLower(c.DeathPenalty.Mode) {
+	case deathPercent, deathFlat:
+	default:
+		f.string("death_penalty.mode", &c.DeathPenalty.Mode, defaults.DeathPenalty.Mode, "is not "+deathPercent+" or "+deathFlat)
+	}
+	if c.DeathPenalty.Percent < 0 || c.DeathPenalty.Percent > 100 {
+		f.float("death_penalty.percent", &c.DeathPenalty.Percent, defaults.DeathPenalty.Percent, "is not between 0 and 100")
+	}
+	if c.DeathPenalty.Amount < 0 {
+		f.money("death_penalty.amount", &c.DeathPenalty.Amount, 0, "is negative")
+	}
+	if c.DeathPenalty.Min < 0 {
+		f.money("death_penalty.min", &c.DeathPenalty.Min, 0, "is negative")
+	}
+	if c.DeathPenalty.Max < 0 {
+		f.money("death_penalty.max", &c.DeathPenalty.Max, 0, "is negative")
+	}
+	if c.DeathPenalty.Max > 0 && c.DeathPenalty.Min > c.DeathPenalty.Max {
+		f.money("death_penalty.min", &c.DeathPenalty.Min, 0, "is more than death_penalty.max")
+	}
+	if c.Payday.IntervalMinutes <= 0 {
+		f.int("payday.interval_minutes", &c.Payday.IntervalMinutes, defaults.Payday.IntervalMinutes, "is not positive")
+	}
+	if c.Payday.Salary < 0 {
+		f.money("payday.salary", &c.Payday.Salary, 0, "is negative")
+	}
+	if c.Loans.MaxInterest < 0 {
+		f.float("loans.max_interest_percent", &c.Loans.MaxInterest, defaults.Loans.MaxInterest, "is negative")
+	}
+	if c.Loans.MaxDays <= 0 {
+		f.int("loans.max_days", &c.Loans.MaxDays, defaults.Loans.MaxDays, "is not positive")
+	}
+	if c.Links.MaxChildren < 0 {
+		f.int("account_links.max_children", &c.Links.MaxChildren, defaults.Links.MaxChildren, "is negative")
+	}
+	if c.Links.OfferExpiryMinutes <= 0 {
+		f.int("account_links.offer_expiry_minutes", &c.Links.OfferExpiryMinutes, defaults.Links.OfferExpiryMinutes, "is not positive")
+	}
+	if c.Scopes.Server = strings.ToLower(c.Scopes.Server); c.Scopes.Server != "" && !validScopeName(c.Scopes.Server) {
+		f.string("scopes.server", &c.Scopes.Server, "", "is not a valid scope name")
+	}
+	scopeNames := make([]string, 0, len(c.Scopes.Worlds))
+	for scope := range c.Scopes.Worlds {
+		scopeNames = append(scopeNames, scope)
+	}
+	sort.Strings(scopeNames)
+	scopedWorlds := make(map[string]string)
+	worlds := make(map[string][]string, len(scopeNames))
+	for _, key := range scopeNames {
+		scope := strings.ToLower(key)
+		if !validScopeName(scope) {
+			f.note("scopes.worlds."+key, "is not a valid scope name, ignoring it")
+			continue
+		}
+		for _, world := range c.Scopes.Worlds[key] {
+			if other, listed := scopedWorlds[strings.ToLower(world)]; listed {
+				f.note("scopes.worlds."+key, "%s is already in scope %s, ignoring it", world, other)
+				continue
+			}
+			scopedWorlds[strings.ToLower(world)] = scope
+			worlds[scope] = append(worlds[scope], world)
+		}
+	}
+	c.Scopes.Worlds = worlds
+	if c.Loans.OfferExpiryMinutes <= 0 {
+		f.int("loans.offer_expiry_minutes", &c.Loans.OfferExpiryMinutes, defaults.Loans.OfferExpiryMinutes, "is not positive")
+	}
+	if c.Escrow.DefaultExpiryMinutes <= 0 {
+		f.int("escrow.default_expiry_minutes", &c.Escrow.DefaultExpiryMinutes, defaults.Escrow.DefaultExpiryMinutes, "is not positive")
+	}
+	terms := c.Savings.Terms[:0:0]
+	for i, term := range c.Savings.Terms {
+		key := "savings.terms." + strconv.Itoa(i)
+		switch {
+		case term.Days <= 0:
+			f.note(key, "does not last a positive number of days, ignoring it")
+		case term.Rate < 0:
+			f.note(key, "has a negative rate, ignoring it")
+		default:
+			terms = append(terms, term)
+		}
+	}
+	c.Savings.Terms = terms
+	if c.Savings.EarlyPenalty < 0 || c.Savings.EarlyPenalty > 100 {
+		f.float("savings.early_withdraw_penalty", &c.Savings.EarlyPenalty, defaults.Savings.EarlyPenalty, "is not between 0 and 100")
+	}
+	if c.Savings.MinDeposit < 0 {
+		f.money("savings.min_deposit", &c.Savings.MinDeposit, defaults.Savings.MinDeposit, "is negative")
+	}
+	if c.Savings.MaxDeposits < 0 {
+		f.int("savings.max_deposits", &c.Savings.MaxDeposits, defaults.Savings.MaxDeposits, "is negative")
+	}
+	if c.Gifts.ExpiryMinutes <= 0 {
+		f.int("gifts.expiry_minutes", &c.Gifts.ExpiryMinutes, defaults.Gifts.ExpiryMinutes, "is not positive")
+	}
+	if c.Gifts.MaxMessage < 0 {
+		f.int("gifts.max_message_length", &c.Gifts.MaxMessage, defaults.Gifts.MaxMessage, "is negative")
+	}
+	if c.Vouchers.ExpiryDays < 0 {
+		f.int("vouchers.expiry_days", &c.Vouchers.ExpiryDays, defaults.Vouchers.ExpiryDays, "is negative")
+	}
+	if c.Vouchers.MinAmount < 0 {
+		f.money("vouchers.min_amount", &c.Vouchers.MinAmount, defaults.Vouchers.MinAmount, "is negative")
+	}
+	if c.Fraud.KeepAlerts < 0 {
+		f.int("fraud.keep_alerts", &c.Fraud.KeepAlerts, defaults.Fraud.KeepAlerts, "is negative")
+	}
+	if c.Discord.MaxPerMinute < 0 {
+		f.int("discord.max_per_minute", &c.Discord.MaxPerMinute, defaults.Discord.MaxPerMinute, "is negative")
+	}
+	if c.AccountCache.MaxAccounts < 0 {
+		f.int("account_cache.max_accounts", &c.AccountCache.MaxAccounts, defaults.AccountCache.MaxAccounts, "is negative")
+	}
+	if c.AccountCache.IdleMinutes <= 0 {
+		f.int("account_cache.idle_minutes", &c.AccountCache.IdleMinutes, defaults.AccountCache.IdleMinutes, "is not positive")
+	}
+	if c.Discord.SummarySchedule != "" {
+		if _, err := parseCronSchedule(c.Discord.SummarySchedule); err != nil {
+			f.string("discord.summary_schedule", &c.Discord.SummarySchedule, defaults.Discord.SummarySchedule, "is not a cron schedule")
+		}
+	}
+	
+	return f.corrections, nil
+}
+
+var userKeyedConfig = map[string]bool{
+	"starting_balances": true,
+	"command_cooldowns": true,
+	"discord.templates": true,
+}
+
+// missingConfigKeys lists the keys, dotted for nested sections, that full
+// has and raw leaves out. Both are JSON config documents.
+func missingConfigKeys(raw, full []byte) []string {
+	var rawKeys, fullKeys map[string]interface{}
+	if json.Unmarshal(raw, &rawKeys) != nil || json.Unmarshal(full, &fullKeys) != nil {
+		return nil
+	}
+	
+	var missing []string
+	var walk func(prefix string, have, want map[string]interface{})
+	walk = func(prefix string, have, want map[string]interface{}) {
+		for key, value := range want {
+			present, exists := have[key]
+			if !exists {
+				missing = append(missing, prefix+key)
+				continue
+			}
+			wantSection, isSection := value.(map[string]interface{})
+			haveSection, hasSection := present.(map[string]interface{})
+			// starting_balances, command_cooldowns and discord.templates
+			// are keyed by the user, so defaults missing from them are not
+			// worth a warning.
+			if isSection && hasSection && len(wantSection) > 0 && !userKeyedConfig[prefix+key] {
+				walk(prefix+key+".", haveSection, wantSection)
+			}
+		}
+	}
+	walk("", rawKeys, fullKeys)
+	sort.Strings(missing)
+	
+	return missing
+}
+
+// ConfigChange is one key whose value differs between the running config
+// and the config file. Restart is set for keys only read while enabling, whose
+// new value is kept for the next start.
+type ConfigChange struct {
+	Key     string
+	Old     string
+	New     string
+	Restart bool
+}
+
+// restartConfigKeys are the keys, with everything under them, that open
+// files, connections or listeners. keepStartupConfig must copy the same
+// fields.
+var restartConfigKeys = []string{
+	"storage_backend", "bolt", "mysql", "postgres", "mongo", "http", "grpc", "journal",
+	"enable_logging", "logging", "transaction_log", "account_cache.enabled",
+}
+
+func needsRestart(key string) bool {
+	for _, prefix := range restartConfigKeys {
+		if key == prefix || strings.HasPrefix(key, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// keepStartupConfig copies the restartConfigKeys fields of running into
+// loaded.
+func keepStartupConfig(running, loaded *Config) {
+	loaded.StorageBackend = running.StorageBackend
+	loaded.Bolt = running.Bolt
+	loaded.MySQL = running.MySQL
+	loaded.Postgres = running.Postgres
+	loaded.Mongo = running.Mongo
+	loaded.HTTP = running.HTTP
+	loaded.GRPC = running.GRPC
+	loaded.Journal = running.Journal
+	loaded.EnableLogging = running.EnableLogging
+	loaded.Logging = running.Logging
+	loaded.TransactionLog = running.TransactionLog
+	loaded.AccountCache.Enabled = running.AccountCache.Enabled
+}
+
+// diffConfig lists the keys whose values differ between old and new,
+// dotted for nested sections and sorted. Lists are compared whole, and
+// secrets are reported as changed without showing them.
+func diffConfig(old, new *Config) []ConfigChange {
+	oldValues, newValues := flattenConfig(old), flattenConfig(new)
+	
+	keys := make([]string, 0, len(newValues))
+	for key := range newValues {
+		keys = append(keys, key)
+	}
+	for key := range oldValues {
+		if _, exists := newValues[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	
+	var changes []ConfigChange
+	for _, key := range keys {
+		before, after := oldValues[key], newValues[key]
+		if before == after {
+			continue
+		}
+		if isSecretConfigKey(key) {
+			before, after = "****", "****"
+		}
+		changes = append(changes, ConfigChange{Key: key, Old: before, New: after, Restart: needsRestart(key)})
+	}
+	
+	return changes
+}
+
+func isSecretConfigKey(key string) bool {
+	return strings.HasSuffix(key, "password") || key == "mongo.uri" || strings.HasSuffix(key, "token") || strings.HasSuffix(key, "webhook_url")
+}
+
+// flattenConfig renders every value in config as it appears in
+// the config file, keyed by its dotted path.
+func flattenConfig(config *Config) map[string]string {
+	values := make(map[string]string)
+	data, err := json.Marshal(config)
+	if err != nil {
+		return values
+	}
+	var document map[string]interface{}
+	if json.Unmarshal(data, &document) != nil {
+		return values
+	}
+	
+	var walk func(prefix string, section map[string]interface{})
+	walk = func(prefix string, section map[string]interface{}) {
+		for key, value := range section {
+			if nested, isSection := value.(map[string]interface{}); isSection {
+				walk(prefix+key+".", nested)
+				continue
+			}
+			if text, isString := value.(string); isString {
+				values[prefix+key] = text
+				continue
+			}
+			rendered, _ := json.Marshal(value)
+			values[prefix+key] = string(rendered)
+		}
+	}
+	walk("", document)
+	
+	return values
+}
+
+// reloadConfig re-reads the config file and applies it to the running plugin
+// without touching player data. Keys that need a restart keep their running
+// values; the others, including schedules and intervals, take effect at
+// once. It returns every key that changed.
+func (e *EconomyPlugin) reloadConfig() ([]ConfigChange, error) {
+	loaded, err := e.readConfig()
+	if err != nil {
+		return nil, err
+	}
+	
+	changes := diffConfig(e.config, loaded)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	
+	applied := *loaded
+	keepStartupConfig(e.config, &applied)
+	
+	running := e.background.cancel != nil
+	e.stopBackgroundTasks()
+	e.config = &applied
+	if running {
+		e.startBackgroundTasks()
+	}
+	e.invalidateTopPlayers()
+	
+	for _, change := range changes {
+		if change.Restart {
+			e.logger.Warn("Config change takes effect after a restart", "key", change.Key)
+		} else {
+			e.logger.Info("Applied config change", "key", change.Key, "old", change.Old, "new", change.New)
+		}
+	}
+	
+	return changes, nil
+}
+
+func (e *EconomyPlugin) reloadReport(changes []ConfigChange) string {
+	if len(changes) == 0 {
+		return e.message("economy.reload_unchanged")
+	}
+	
+	result := e.message("economy.reloaded")
+	for _, change := range changes {
+		key := "economy.reload_change"
+		if change.Restart {
+			key = "economy.reload_restart"
+		}
+		result += "\n" + e.message(key, "key", change.Key, "old", change.Old, "new", change.New)
+	}
+	
+	return result
+}
@@ -0,0 +1,313 @@
+package economy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	
+	"gopkg.in/yaml.v3"
+)
+
+// configExtensions are the formats the config and message files may be
+// written in, in the order they are looked for.
+var configExtensions = []string{".yml", ".yaml", ".json"}
+
+// findConfigFile returns base, a path without its extension, in the first
+// format that exists, or "" when there is none.
+func findConfigFile(base string) string {
+	for _, extension := range configExtensions {
+		if _, err := os.Stat(base + extension); err == nil {
+			return base + extension
+		}
+	}
+	return ""
+}
+
+func isYAMLFile(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+	return extension == ".yml" || extension == ".yaml"
+}
+
+// configPath is the data folder's config.yml, config.yaml or config.json,
+// whichever it has first. A folder with none gets config.yml.
+func (e *EconomyPlugin) configPath() string {
+	if path := findConfigFile(filepath.Join(e.dataFolder, "config")); path != "" {
+		return path
+	}
+	return filepath.Join(e.dataFolder, "config.yml")
+}
+
+// readConfigFile reads path and returns it as a JSON document, converting
+// YAML files first, so the json tags decide how either format is read.
+func readConfigFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil || !isYAMLFile(path) {
+		return data, err
+	}
+	
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if document == nil {
+		document = map[string]interface{}{}
+	}
+	return json.Marshal(jsonValue(document))
+}
+
+// jsonValue turns the maps YAML decodes into, whose keys may be numbers
+// or booleans, into maps JSON can encode.
+func jsonValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = jsonValue(item)
+		}
+		return value
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			converted[fmt.Sprint(key)] = jsonValue(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range value {
+			value[i] = jsonValue(item)
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// writeConfigFile writes document, a JSON document, to path in the format
+// its extension names. A YAML file that exists already keeps its comments,
+// layout and key order: only the values that changed are rewritten, keys
+// new to the document are added at the end of their section and keys it
+// no longer has are removed.
+func writeConfigFile(path string, document []byte) error {
+	if !isYAMLFile(path) {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, document, "", "  "); err != nil {
+			return err
+		}
+		return writeFileAtomic(path, indented.Bytes(), 0644)
+	}
+	
+	want, err := yamlNodeFromJSON(json.NewDecoder(bytes.NewReader(document)))
+	if err != nil {
+		return err
+	}
+	
+	root := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{want}}
+	var spaced map[string]bool
+	if existing, err := ioutil.ReadFile(path); err == nil {
+		var parsed yaml.Node
+		if yaml.Unmarshal(existing, &parsed) == nil && len(parsed.Content) == 1 && parsed.Content[0].Kind == yaml.MappingNode {
+			mergeYAMLNode(parsed.Content[0], want)
+			root = &parsed
+			spaced = spacedYAMLKeys(existing)
+		}
+	}
+	
+	var encoded bytes.Buffer
+	encoder := yaml.NewEncoder(&encoded)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, respaceYAML(encoded.Bytes(), spaced), 0644)
+}
+
+// yamlTopKey returns the key a top-level "key: value" line sets, or "".
+func yamlTopKey(line string) string {
+	if line == "" || strings.ContainsAny(line[:1], " \t#-") {
+		return ""
+	}
+	if colon := strings.Index(line, ":"); colon > 0 {
+		return strings.Trim(line[:colon], `"'`)
+	}
+	return ""
+}
+
+// spacedYAMLKeys lists the top-level keys that data separates from the
+// lines above them, comments included, with a blank line. The YAML encoder
+// drops blank lines, and respaceYAML uses this to put them back.
+func spacedYAMLKeys(data []byte) map[string]bool {
+	spaced := make(map[string]bool)
+	blank := false
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		switch {
+		case strings.TrimSpace(line) == "":
+			blank = true
+		case strings.HasPrefix(line, "#"):
+		default:
+			if key := yamlTopKey(line); key != "" && blank {
+				spaced[key] = true
+			}
+			blank = false
+		}
+	}
+	return spaced
+}
+
+func respaceYAML(data []byte, spaced map[string]bool) []byte {
+	if len(spaced) == 0 {
+		return data
+	}
+	lines := strings.Split(string(data), "\n")
+	result := make([]string, 0, len(lines)+len(spaced))
+	for _, line := range lines {
+		if spaced[yamlTopKey(line)] {
+			// A blank line goes above the key's own comment.
+			at := len(result)
+			for at > 0 && strings.HasPrefix(result[at-1], "#") {
+				at--
+			}
+			if at > 0 && result[at-1] != "" {
+				result = append(result[:at], append([]string{""}, result[at:]...)...)
+			}
+		}
+		result = append(result, line)
+	}
+	return []byte(strings.Join(result, "\n"))
+}
+
+// yamlNodeFromJSON builds the node for the next JSON value, keeping the
+// order of object keys, which is the order of the Config fields.
+func yamlNodeFromJSON(decoder *json.Decoder) (*yaml.Node, error) {
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	
+	switch token := token.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if token == '[' {
+			node.Kind, node.Tag = yaml.SequenceNode, "!!seq"
+		}
+		for decoder.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.(string)})
+			}
+			item, err := yamlNodeFromJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, item)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		if len(node.Content) == 0 {
+			node.Style = yaml.FlowStyle
+		}
+		return node, nil
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: token, Style: yaml.DoubleQuotedStyle}, nil
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(token.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: token.String()}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(token)}, nil
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+}
+
+// mergeYAMLNode makes have hold want's values while keeping have's
+// comments and, for mappings, the order of the keys it already had.
+func mergeYAMLNode(have, want *yaml.Node) {
+	switch {
+	case have.Kind == yaml.MappingNode && want.Kind == yaml.MappingNode:
+		wanted := make(map[string]*yaml.Node, len(want.Content)/2)
+		for i := 0; i+1 < len(want.Content); i += 2 {
+			wanted[want.Content[i].Value] = want.Content[i+1]
+		}
+		
+		content := make([]*yaml.Node, 0, len(want.Content))
+		kept := make(map[string]bool, len(wanted))
+		for i := 0; i+1 < len(have.Content); i += 2 {
+			key, value := have.Content[i], have.Content[i+1]
+			target, exists := wanted[key.Value]
+			if !exists || kept[key.Value] {
+				continue
+			}
+			mergeYAMLNode(value, target)
+			content = append(content, key, value)
+			kept[key.Value] = true
+		}
+		for i := 0; i+1 < len(want.Content); i += 2 {
+			if !kept[want.Content[i].Value] {
+				content = append(content, want.Content[i], want.Content[i+1])
+			}
+		}
+		if len(have.Content) == 0 {
+			have.Style = want.Style
+		}
+		have.Content = content
+		
+	case have.Kind == yaml.SequenceNode && want.Kind == yaml.SequenceNode:
+		// Items are matched by position, so the ones still there keep
+		// their comments.
+		if len(have.Content) == 0 || len(want.Content) == 0 {
+			have.Style = want.Style
+		}
+		for i := 0; i < len(have.Content) && i < len(want.Content); i++ {
+			mergeYAMLNode(have.Content[i], want.Content[i])
+		}
+		if len(have.Content) > len(want.Content) {
+			have.Content = have.Content[:len(want.Content)]
+		} else {
+			have.Content = append(have.Content, want.Content[len(have.Content):]...)
+		}
+		
+	case have.Kind == yaml.ScalarNode && want.Kind == yaml.ScalarNode:
+		if sameYAMLScalar(have, want) {
+			return
+		}
+		if have.ShortTag() != want.ShortTag() {
+			have.Style = want.Style
+		}
+		have.Tag, have.Value = want.Tag, want.Value
+		
+	default:
+		head, line, foot := have.HeadComment, have.LineComment, have.FootComment
+		*have = *want
+		have.HeadComment, have.LineComment, have.FootComment = head, line, foot
+	}
+}
+
+// sameYAMLScalar compares numbers by value, so 1000 and 1000.0 in the
+// file are left as they were written.
+func sameYAMLScalar(have, want *yaml.Node) bool {
+	if have.ShortTag() == want.ShortTag() && have.Value == want.Value {
+		return true
+	}
+	numeric := func(node *yaml.Node) bool {
+		return node.ShortTag() == "!!int" || node.ShortTag() == "!!float"
+	}
+	if !numeric(have) || !numeric(want) {
+		return false
+	}
+	a, errA := strconv.ParseFloat(have.Value, 64)
+	b, errB := strconv.ParseFloat(want.Value, 64)
+	return errA == nil && errB == nil && a == b
+}
@@ -0,0 +1,58 @@
+package economy
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// commandCooldownSweep is how many entries commandCooldowns holds before
+// it drops the expired ones.
+const commandCooldownSweep = 1024
+
+type cooldownKey struct {
+	player  string
+	command string
+}
+
+// commandCooldowns remembers until when each player must wait before
+// running a command again. Like the transfer limits it is kept in memory
+// only.
+type commandCooldowns struct {
+	mutex sync.Mutex
+	until map[cooldownKey]time.Time
+}
+
+// enterCooldown starts the command_cooldowns wait for sender running cmd,
+// or returns how long is left of the current one. The console and players
+// with economy.cooldown.bypass never wait.
+func (d *CommandDispatcher) enterCooldown(sender CommandSender, cmd *Command) time.Duration {
+	seconds := d.plugin.config.CommandCooldowns[strings.ToLower(cmd.Name)]
+	if seconds <= 0 || sender.IsConsole() || d.plugin.hasPermission(sender, "economy.cooldown.bypass") {
+		return 0
+	}
+	
+	key := cooldownKey{player: strings.ToLower(sender.Name()), command: cmd.Name}
+	now := d.plugin.now()
+	
+	d.cooldowns.mutex.Lock()
+	defer d.cooldowns.mutex.Unlock()
+	
+	if wait := d.cooldowns.until[key].Sub(now); wait > 0 {
+		return wait
+	}
+	
+	if d.cooldowns.until == nil {
+		d.cooldowns.until = make(map[cooldownKey]time.Time)
+	}
+	if len(d.cooldowns.until) >= commandCooldownSweep {
+		for other, until := range d.cooldowns.until {
+			if !until.After(now) {
+				delete(d.cooldowns.until, other)
+			}
+		}
+	}
+	d.cooldowns.until[key] = now.Add(time.Duration(seconds) * time.Second)
+	
+	return 0
+}
@@ -0,0 +1,164 @@
+package economy
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// dashboardPage is the admin dashboard. It holds no data itself and is
+// served without a token; everything it shows comes from the API, using
+// the key the admin enters.
+//
+//go:embed dashboard.html
+var dashboardPage []byte
+
+type apiKeyNameKey struct{}
+
+// apiKeyName returns the name of the key that authorized a request.
+func apiKeyName(ctx context.Context) string {
+	name, _ := ctx.Value(apiKeyNameKey{}).(string)
+	return name
+}
+
+// apiSender stands in for the admin behind an API key, so actions taken
+// through the API are audited under the key's name.
+type apiSender struct {
+	key string
+}
+
+func (s apiSender) Name() string    { return "api:" + s.key }
+func (s apiSender) UUID() string    { return "" }
+func (s apiSender) IsConsole() bool { return false }
+
+type apiSupplyPoint struct {
+	Day   string `json:"day"`
+	Total Money  `json:"total"`
+}
+
+type apiAdminRequest struct {
+	Player string `json:"player"`
+	Amount Money  `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+func (e *EconomyPlugin) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Write(dashboardPage)
+}
+
+func (e *EconomyPlugin) handleSupply(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 366 {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid days"})
+			return
+		}
+		days = parsed
+	}
+	
+	points := e.MoneySupply(days)
+	result := make([]apiSupplyPoint, len(points))
+	for i, point := range points {
+		result[i] = apiSupplyPoint{
+			Day:   point.Day.Format(historyDayLayout),
+			Total: point.Total,
+		}
+	}
+	
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleAdminAction gives or takes money like /money give and /money take.
+func (e *EconomyPlugin) handleAdminAction(w http.ResponseWriter, r *http.Request) {
+	action := r.PathValue("action")
+	if action != "give" && action != "take" {
+		writeJSON(w, http.StatusNotFound, apiError{Error: "unknown action"})
+		return
+	}
+	
+	var request apiAdminRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	if request.Player == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "player is required"})
+		return
+	}
+	
+	if action == "take" && !e.hasAccount(request.Player) {
+		writeAPIError(w, ErrAccountNotFound)
+		return
+	}
+	if err := e.requireAccounts(request.Player); err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	
+	ctx := &CommandContext{Sender: apiSender{key: apiKeyName(r.Context())}, Label: "api"}
+	before := e.peekBalance(request.Player)
+	
+	var id string
+	var err error
+	if action == "give" {
+		request.Amount, id, err = e.grant(request.Player, request.Amount, reasonOr(request.Reason, "Given by "+ctx.Name()))
+	} else {
+		id, err = e.subtractMoney(request.Player, request.Amount, reasonOr(request.Reason, "Taken by "+ctx.Name()))
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	
+	e.notifyAdminAction(action, ctx.Name(), request.Player, request.Amount)
+	e.auditBalance(ctx, action, request.Player, before, "via HTTP API")
+	
+	balance := e.getBalance(request.Player)
+	writeJSON(w, http.StatusOK, apiBalance{
+		Player:        request.Player,
+		Balance:       balance,
+		Formatted:     e.FormatMoney(balance),
+		TransactionID: id,
+	})
+}
+
+// SupplyPoint is the money in circulation at the end of a day.
+type SupplyPoint struct {
+	Day   time.Time
+	Total Money
+}
+
+// MoneySupply returns the money held in wallets and banks at the end of
+// each of the last days days, oldest first. Earlier days are worked back
+// from today's total through the ledger.
+func (e *EconomyPlugin) MoneySupply(days int) []SupplyPoint {
+	if days < 1 {
+		return nil
+	}
+	
+	total := e.circulatingSupply()
+	
+	now := e.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	start := today.AddDate(0, 0, 1-days)
+	
+	created := make(map[string]Money)
+	for _, transaction := range e.GetTransactions("", TransactionFilter{Since: start}) {
+		created[transaction.Timestamp.In(now.Location()).Format(historyDayLayout)] += moneyCreated(&transaction)
+	}
+	
+	points := make([]SupplyPoint, days)
+	for i := days - 1; i >= 0; i-- {
+		day := start.AddDate(0, 0, i)
+		points[i] = SupplyPoint{Day: day, Total: total}
+		total -= created[day.Format(historyDayLayout)]
+	}
+	return points
+}
@@ -0,0 +1,92 @@
+package economy
+
+import "strings"
+
+const (
+	deathPercent = "percent"
+	deathFlat    = "flat"
+)
+
+// DeathPenaltyConfig takes money from a player's wallet when they die, for
+// the server to drop as loot: Percent of what they can spend, or Amount
+// when Mode is flat, kept between Min and Max. A Max of 0 sets no limit.
+// Players with economy.death.exempt, or in one of ExemptGroups, keep
+// everything, like keep-inventory.
+type DeathPenaltyConfig struct {
+	Enabled      bool     `json:"enabled"`
+	Mode         string   `json:"mode"`
+	Percent      float64  `json:"percent"`
+	Amount       Money    `json:"amount"`
+	Min          Money    `json:"min"`
+	Max          Money    `json:"max"`
+	ExemptGroups []string `json:"exempt_groups"`
+}
+
+// PenalizeOnDeath takes the death penalty from player's wallet and returns
+// how much was taken, so the server plugin can spawn it as loot. Money on
+// hold is never taken, and nothing is when death_penalty is off or the
+// player is exempt.
+func (e *EconomyPlugin) PenalizeOnDeath(player string) (Money, error) {
+	config := e.config.DeathPenalty
+	if !e.hasAccount(player) {
+		return 0, ErrAccountNotFound
+	}
+	if !config.Enabled || IsVirtualAccount(player) || e.deathExempt(player) {
+		return 0, nil
+	}
+	
+	var oldBalance, lost Money
+	err := e.mutateAccounts([]string{player}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		spendable := clampZero(account.spendable())
+		
+		lost = config.Amount
+		if strings.ToLower(config.Mode) == deathPercent {
+			lost = e.roundAmount(spendable.MulRate(config.Percent / 100))
+		}
+		if lost < config.Min {
+			lost = config.Min
+		}
+		if config.Max > 0 && lost > config.Max {
+			lost = config.Max
+		}
+		if lost > spendable {
+			lost = spendable
+		}
+		if lost <= 0 {
+			lost = 0
+			return nil
+		}
+		
+		oldBalance = account.Balance
+		account.Balance -= lost
+		account.TotalSpent += lost
+		return nil
+	})
+	if err != nil || lost == 0 {
+		return 0, err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(player, oldBalance, oldBalance-lost, DEATH_PENALTY)
+	e.recordTransaction(&Transaction{
+		From:      player,
+		Amount:    lost,
+		Type:      DEATH_PENALTY,
+		Timestamp: e.now(),
+		Reason:    "Death penalty",
+	})
+	
+	if messenger := e.getMessenger(); messenger != nil {
+		messenger.SendMessage(player, e.message("death.penalty", "amount", e.FormatMoney(lost)))
+	}
+	return lost, nil
+}
+
+func (e *EconomyPlugin) deathExempt(player string) bool {
+	uuid, _ := e.GetUUID(player)
+	if e.hasPermission(PlayerSender(player, uuid), "economy.death.exempt") {
+		return true
+	}
+	return e.inAnyGroup(uuid, player, e.config.DeathPenalty.ExemptGroups)
+}
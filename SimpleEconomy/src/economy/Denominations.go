@@ -0,0 +1,154 @@
+package economy
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DenominationConfig lets balance be taken out as items, such as gold
+// ingots, and paid back in. Which item is worth what is up to Items.
+type DenominationConfig struct {
+	Enabled bool           `json:"enabled"`
+	Items   []Denomination `json:"items"`
+}
+
+// Denomination is an item worth Value each.
+type Denomination struct {
+	Item  string `json:"item"`
+	Value Money  `json:"value"`
+}
+
+// ItemStack is Count of one denomination's item. Count may be more than
+// the game fits in one stack; splitting it up is left to the server.
+type ItemStack struct {
+	Item  string `json:"item"`
+	Count int    `json:"count"`
+}
+
+// denominations returns the configured denominations, most valuable
+// first.
+func (e *EconomyPlugin) denominations() []Denomination {
+	denominations := append([]Denomination(nil), e.config.Denominations.Items...)
+	sort.SliceStable(denominations, func(i, j int) bool {
+		return denominations[i].Value > denominations[j].Value
+	})
+	return denominations
+}
+
+func (e *EconomyPlugin) denomination(item string) (Denomination, bool) {
+	for _, denomination := range e.config.Denominations.Items {
+		if strings.EqualFold(denomination.Item, item) {
+			return denomination, true
+		}
+	}
+	return Denomination{}, false
+}
+
+// splitDenominations makes amount from as few of the most valuable items
+// as it can, taking each in turn. That is exact for the usual sets such as
+// 1, 10 and 100; an amount the items cannot add up to is rejected.
+func splitDenominations(amount Money, denominations []Denomination) ([]ItemStack, error) {
+	stacks := make([]ItemStack, 0, len(denominations))
+	for _, denomination := range denominations {
+		if count := amount / denomination.Value; count > 0 {
+			stacks = append(stacks, ItemStack{Item: denomination.Item, Count: int(count)})
+			amount -= count * denomination.Value
+		}
+	}
+	if amount != 0 {
+		return nil, ErrNoDenomination
+	}
+	return stacks, nil
+}
+
+func describeItems(stacks []ItemStack) string {
+	parts := make([]string, len(stacks))
+	for i, stack := range stacks {
+		parts[i] = strconv.Itoa(stack.Count) + "x " + stack.Item
+	}
+	return strings.Join(parts, ", ")
+}
+
+// WithdrawAsItems takes amount from player's wallet and returns the items
+// to hand them for it. The server must give the player every stack; the
+// money is already gone. Unlike a debit, it never takes a wallet below
+// zero, so items cannot be made out of debt.
+func (e *EconomyPlugin) WithdrawAsItems(player string, amount Money) ([]ItemStack, error) {
+	if !e.config.Denominations.Enabled || len(e.config.Denominations.Items) == 0 {
+		return nil, ErrDenominationsDisabled
+	}
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if !e.hasAccount(player) {
+		return nil, ErrAccountNotFound
+	}
+	
+	stacks, err := splitDenominations(amount, e.denominations())
+	if err != nil {
+		return nil, err
+	}
+	
+	var oldBalance Money
+	err = e.mutateAccounts([]string{player}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if account.spendable() < amount {
+			return ErrInsufficientFunds
+		}
+		
+		oldBalance = account.Balance
+		account.Balance -= amount
+		account.TotalSpent += amount
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(player, oldBalance, oldBalance-amount, ITEM_WITHDRAW)
+	e.recordTransaction(&Transaction{
+		From:      player,
+		Amount:    amount,
+		Type:      ITEM_WITHDRAW,
+		Timestamp: e.now(),
+		Reason:    clipReference("Withdrawn as " + describeItems(stacks)),
+	})
+	
+	return stacks, nil
+}
+
+// DepositItems pays player the value of items, which the server must take
+// from them first, and returns the amount credited. Every item must be a
+// configured denomination; nothing is paid otherwise.
+func (e *EconomyPlugin) DepositItems(player string, items []ItemStack) (Money, error) {
+	if !e.config.Denominations.Enabled || len(e.config.Denominations.Items) == 0 {
+		return 0, ErrDenominationsDisabled
+	}
+	if !e.hasAccount(player) {
+		return 0, ErrAccountNotFound
+	}
+	
+	var total Money
+	deposited := make([]ItemStack, 0, len(items))
+	for _, stack := range items {
+		denomination, known := e.denomination(stack.Item)
+		if !known {
+			return 0, ErrUnknownDenomination
+		}
+		if stack.Count <= 0 {
+			return 0, ErrInvalidAmount
+		}
+		if Money(stack.Count) > (e.config.MaxBalance-total)/denomination.Value {
+			return 0, ErrMaxBalanceExceeded
+		}
+		total += Money(stack.Count) * denomination.Value
+		deposited = append(deposited, ItemStack{Item: denomination.Item, Count: stack.Count})
+	}
+	
+	if _, err := e.credit(player, total, ITEM_DEPOSIT, "", clipReference("Deposited "+describeItems(deposited))); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
@@ -0,0 +1,292 @@
+package economy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiscordConfig sends notifications to a webhook that accepts Discord's
+// message format. LargeTransfer is the smallest transfer announced, zero
+// announcing none; SummarySchedule is a cron expression, empty for no
+// daily summary. At most MaxPerMinute messages are sent, the rest queue.
+type DiscordConfig struct {
+	Enabled         bool              `json:"enabled"`
+	WebhookURL      string            `json:"webhook_url"`
+	Username        string            `json:"username"`
+	LargeTransfer   Money             `json:"large_transfer"`
+	AdminActions    bool              `json:"admin_actions"`
+	TopPlayer       bool              `json:"top_player"`
+	SummarySchedule string            `json:"summary_schedule"`
+	MaxPerMinute    int               `json:"max_per_minute"`
+	Templates       map[string]string `json:"templates"`
+}
+
+const (
+	discordQueueSize   = 100
+	discordSendTimeout = 10 * time.Second
+)
+
+// defaultDiscordTemplates are used for any event missing from
+// discord.templates.
+var defaultDiscordTemplates = map[string]string{
+	"large_transfer": ":moneybag: **{from}** paid **{to}** {amount}",
+	"admin_give":     ":shield: **{admin}** gave **{player}** {amount}",
+	"admin_take":     ":shield: **{admin}** took {amount} from **{player}**",
+	"admin_set":      ":shield: **{admin}** set **{player}**'s balance to {amount}",
+	"admin_giveall":  ":shield: **{admin}** gave {amount} to **{player}**",
+	"top_player":     ":crown: **{player}** is now the richest player with {balance}, overtaking **{previous}**",
+	"daily_summary":  ":bar_chart: **Daily summary:** {players} players hold {total} (average {average}). {transactions} transactions moved {volume} since the last summary.",
+	"health_report":  ":chart_with_upwards_trend: **Economy health for {day}:** {supply} in circulation ({change}, {inflation}), {new_accounts} new accounts, {transactions} transactions. Top gainer: {top_gainer}",
+}
+
+// discordNotifier queues webhook messages for a single sender goroutine.
+// The queue outlives restarts of the background tasks so hooks can always
+// post to it; messages are dropped when it is full.
+type discordNotifier struct {
+	hooks        sync.Once
+	queue        chan string
+	client       *http.Client
+	mutex        sync.Mutex
+	sent         []time.Time
+	topPlayer    string
+	transactions int
+	volume       Money
+}
+
+func (e *EconomyPlugin) registerDiscordHooks() {
+	e.discord.hooks.Do(func() {
+		e.discord.queue = make(chan string, discordQueueSize)
+		e.discord.client = &http.Client{Timeout: discordSendTimeout}
+		
+		e.OnTransaction(func(transaction Transaction) {
+			config := e.config.Discord
+			if !config.Enabled {
+				return
+			}
+			
+			e.discord.mutex.Lock()
+			e.discord.transactions++
+			if transaction.Type == TRANSFER {
+				e.discord.volume += transaction.Amount
+			}
+			e.discord.mutex.Unlock()
+			
+			if transaction.Type == TRANSFER && config.LargeTransfer > 0 && transaction.Amount >= config.LargeTransfer {
+				e.notifyDiscord("large_transfer", "from", transaction.From, "to", transaction.To,
+					"amount", e.FormatMoney(transaction.Amount))
+			}
+		})
+	})
+}
+
+// notifyDiscord renders the template for event and queues it.
+func (e *EconomyPlugin) notifyDiscord(event string, pairs ...string) {
+	config := e.config.Discord
+	if !config.Enabled || config.WebhookURL == "" || e.discord.queue == nil {
+		return
+	}
+	
+	template, exists := config.Templates[event]
+	if !exists {
+		template = defaultDiscordTemplates[event]
+	}
+	if template == "" {
+		return
+	}
+	
+	replacements := []string{"{currency}", e.config.CurrencyName, "{symbol}", e.config.CurrencySymbol}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		replacements = append(replacements, "{"+pairs[i]+"}", pairs[i+1])
+	}
+	
+	select {
+	case e.discord.queue <- strings.NewReplacer(replacements...).Replace(template):
+	default:
+		e.logger.Warn("Discord queue full, dropping notification", "event", event)
+	}
+}
+
+func (e *EconomyPlugin) notifyAdminAction(action, admin, player string, amount Money) {
+	if e.config.Discord.AdminActions {
+		e.notifyDiscord("admin_"+action, "admin", admin, "player", player, "amount", e.FormatMoney(amount))
+	}
+}
+
+func (e *EconomyPlugin) startDiscordNotifier() {
+	config := e.config.Discord
+	if !config.Enabled || config.WebhookURL == "" || e.discord.queue == nil {
+		return
+	}
+	
+	ctx := e.background.ctx
+	e.background.wg.Add(1)
+	go func() {
+		defer e.background.wg.Done()
+		
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case content := <-e.discord.queue:
+				if !e.waitForDiscordSlot(ctx) {
+					return
+				}
+				if err := e.postDiscord(ctx, content); err != nil {
+					e.logger.Warn("Failed to send Discord notification", "error", err)
+				}
+			}
+		}
+	}()
+	
+	if config.TopPlayer {
+		e.discord.mutex.Lock()
+		e.discord.topPlayer = ""
+		e.discord.mutex.Unlock()
+		
+		e.checkTopPlayer()
+		e.runPeriodically(time.Minute, e.checkTopPlayer)
+	}
+	
+	if config.SummarySchedule != "" {
+		schedule, err := parseCronSchedule(config.SummarySchedule)
+		if err != nil {
+			e.logger.Warn("Discord daily summary disabled", "error", err)
+			return
+		}
+		e.runOnSchedule(schedule, e.sendDailySummary)
+	}
+}
+
+// waitForDiscordSlot blocks until sending another message keeps within
+// discord.max_per_minute. It returns false if ctx is cancelled first.
+func (e *EconomyPlugin) waitForDiscordSlot(ctx context.Context) bool {
+	limit := e.config.Discord.MaxPerMinute
+	if limit <= 0 {
+		return true
+	}
+	
+	for {
+		now := e.now()
+		e.discord.mutex.Lock()
+		kept := e.discord.sent[:0]
+		for _, at := range e.discord.sent {
+			if now.Sub(at) < time.Minute {
+				kept = append(kept, at)
+			}
+		}
+		e.discord.sent = kept
+		
+		if len(kept) < limit {
+			e.discord.sent = append(e.discord.sent, now)
+			e.discord.mutex.Unlock()
+			return true
+		}
+		wait := time.Minute - now.Sub(kept[0])
+		e.discord.mutex.Unlock()
+		
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(wait):
+		}
+	}
+}
+
+// postDiscord sends one message, retrying once if the webhook asks us to
+// slow down.
+func (e *EconomyPlugin) postDiscord(ctx context.Context, content string) error {
+	payload := map[string]interface{}{
+		"content":          content,
+		"allowed_mentions": map[string]interface{}{"parse": []string{}},
+	}
+	if username := e.config.Discord.Username; username != "" {
+		payload["username"] = username
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	
+	for attempt := 0; ; attempt++ {
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Discord.WebhookURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("User-Agent", "SimpleEconomy/"+e.version)
+		
+		response, err := e.discord.client.Do(request)
+		if err != nil {
+			return err
+		}
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+		
+		if response.StatusCode == http.StatusTooManyRequests && attempt == 0 {
+			wait := time.Second
+			if seconds, err := strconv.ParseFloat(response.Header.Get("Retry-After"), 64); err == nil && seconds > 0 {
+				wait = time.Duration(seconds * float64(time.Second))
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+		if response.StatusCode/100 != 2 {
+			return fmt.Errorf("webhook returned %s", response.Status)
+		}
+		return nil
+	}
+}
+
+// checkTopPlayer announces a new richest player. The first check after
+// starting only records who is on top.
+func (e *EconomyPlugin) checkTopPlayer() {
+	topPlayers := e.getTopPlayers()
+	if len(topPlayers) == 0 {
+		return
+	}
+	top := topPlayers[0]
+	
+	e.discord.mutex.Lock()
+	previous := e.discord.topPlayer
+	e.discord.topPlayer = top.Username
+	e.discord.mutex.Unlock()
+	
+	if previous != "" && !strings.EqualFold(previous, top.Username) {
+		e.notifyDiscord("top_player", "player", top.Username, "balance", e.FormatMoney(top.Balance), "previous", previous)
+	}
+}
+
+func (e *EconomyPlugin) sendDailySummary() {
+	var total, average Money
+	players := 0
+	for _, account := range e.snapshotAccounts() {
+		if !account.virtual() {
+			total += account.Balance
+			players++
+		}
+	}
+	if players > 0 {
+		average = total / Money(players)
+	}
+	
+	e.discord.mutex.Lock()
+	transactions, volume := e.discord.transactions, e.discord.volume
+	e.discord.transactions, e.discord.volume = 0, 0
+	e.discord.mutex.Unlock()
+	
+	e.notifyDiscord("daily_summary", "players", strconv.Itoa(players), "total", e.FormatMoney(total),
+		"average", e.FormatMoney(average), "transactions", strconv.Itoa(transactions), "volume", e.FormatMoney(volume))
+}
@@ -0,0 +1,396 @@
+package economy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The mint and burn accounts are where money created and destroyed comes
+// from and goes to in double-entry mode. Their names cannot be taken by a
+// player or virtual account.
+const (
+	mintAccount = "ledger#mint"
+	burnAccount = "ledger#burn"
+)
+
+const openingReason = "Opening balances"
+
+// Posting is one leg of a double-entry transaction: Amount is added to
+// the ledger account named Account, and a negative Amount is taken from
+// it. A transaction's postings add up to zero.
+type Posting struct {
+	Account string `json:"account"`
+	Amount  Money  `json:"amount"`
+}
+
+// bankRef is the ledger account for a player's bank balance.
+func bankRef(username string) string {
+	return "bank#" + username
+}
+
+// postings balances legs against the mint or burn account, or returns nil
+// when double_entry is off. Callers pass legs when the transaction's From,
+// To and Amount do not say how money moved, such as a tax taken from both
+// wallet and bank.
+func (e *EconomyPlugin) postings(legs ...Posting) []Posting {
+	if !e.config.DoubleEntry {
+		return nil
+	}
+	return balancePostings(legs)
+}
+
+// balancePostings drops empty legs and adds the mint or burn leg that
+// makes the rest add up to zero.
+func balancePostings(legs []Posting) []Posting {
+	var postings []Posting
+	var net Money
+	for _, leg := range legs {
+		if leg.Amount != 0 {
+			postings = append(postings, leg)
+			net += leg.Amount
+		}
+	}
+	
+	switch {
+	case net > 0:
+		postings = append(postings, Posting{Account: mintAccount, Amount: -net})
+	case net < 0:
+		postings = append(postings, Posting{Account: burnAccount, Amount: -net})
+	}
+	return postings
+}
+
+// derivePostings works out the postings of a transaction recorded without
+// any: From pays Amount to To, with an empty name standing for the mint
+// or burn account, except for the types that say otherwise.
+func derivePostings(transaction *Transaction) []Posting {
+	from, to, amount := transaction.From, transaction.To, transaction.Amount
+	var legs []Posting
+	
+	switch {
+	case (transaction.Type == SET || transaction.Type == ROLLBACK) && transaction.Previous != nil:
+		legs = append(legs, Posting{Account: to, Amount: amount - *transaction.Previous})
+	case transaction.Type == BATCH:
+		for _, entry := range transaction.Batch {
+			legs = append(legs, Posting{Account: entry.Player, Amount: entry.Amount})
+		}
+	case transaction.Type == CONFISCATE:
+		legs = confiscationLegs(transaction)
+	case transaction.Type == BANK_DEPOSIT:
+		legs = append(legs, Posting{Account: from, Amount: -amount}, Posting{Account: bankRef(from), Amount: amount})
+	case transaction.Type == BANK_WITHDRAW:
+		legs = append(legs, Posting{Account: bankRef(to), Amount: -amount}, Posting{Account: to, Amount: amount})
+	case transaction.Type == INTEREST && transaction.Reason == bankInterestReason:
+		legs = append(legs, Posting{Account: bankRef(to), Amount: amount})
+	default:
+		if from != "" {
+			legs = append(legs, Posting{Account: from, Amount: -amount})
+		}
+		if to != "" {
+			legs = append(legs, Posting{Account: to, Amount: amount})
+		}
+	}
+	
+	return balancePostings(legs)
+}
+
+func (e *EconomyPlugin) doubleEntryPath() string {
+	return filepath.Join(e.dataFolder, "double_entry.json")
+}
+
+// openDoubleEntry posts the opening balances the first time the plugin
+// starts with double_entry on, so that money held from before is accounted
+// for. Turning it off forgets that, and turning it on again opens anew.
+func (e *EconomyPlugin) openDoubleEntry() {
+	path := e.doubleEntryPath()
+	_, err := os.Stat(path)
+	opened := err == nil
+	
+	if !e.config.DoubleEntry {
+		if opened {
+			if err := os.Remove(path); err != nil {
+				e.logger.Warn("Failed to remove double-entry marker", "error", err)
+			}
+		}
+		return
+	}
+	if opened {
+		return
+	}
+	
+	if err := e.postOpeningBalances(openingReason); err != nil {
+		e.logger.Error("Failed to post opening balances", "error", err)
+		return
+	}
+	data, err := json.Marshal(map[string]time.Time{"opened": e.now()})
+	if err == nil {
+		err = writeFileAtomic(path, data, 0644)
+	}
+	if err != nil {
+		e.logger.Error("Failed to write double-entry marker", "error", err)
+	}
+}
+
+// postOpeningBalances records everything held right now as minted, which
+// is where reconciling starts from. A restored backup is opened this way
+// too, since it replaces balances without posting the difference.
+func (e *EconomyPlugin) postOpeningBalances(reason string) error {
+	resume := e.shutdown.pauseMutations()
+	defer resume(false)
+	
+	holdings, err := e.ledgerHoldings()
+	if err != nil {
+		return err
+	}
+	var total Money
+	for _, holding := range holdings {
+		total += holding.Amount
+	}
+	
+	e.recordTransaction(&Transaction{
+		Amount:    total,
+		Type:      OPENING,
+		Timestamp: e.now(),
+		Reason:    reason,
+		Postings:  balancePostings(holdings),
+	})
+	e.logger.Info("Posted opening balances", "accounts", len(holdings), "total", total.String())
+	return nil
+}
+
+// postAccountOpening mints a new account's starting balance.
+func (e *EconomyPlugin) postAccountOpening(account *PlayerAccount) {
+	if !e.config.DoubleEntry || account.Balance == 0 {
+		return
+	}
+	
+	e.recordTransaction(&Transaction{
+		To:        account.Username,
+		Amount:    account.Balance,
+		Type:      OPENING,
+		Timestamp: e.now(),
+		Reason:    "Starting balance",
+	})
+}
+
+// ledgerHoldings lists what every ledger account holds right now: wallets
+// and banks, shared accounts, open escrows and gifts, unredeemed vouchers,
+// pending payments and savings deposits. Callers pause mutations so it all adds up. Loaded accounts are
+// saved first, so the storage has every account.
+func (e *EconomyPlugin) ledgerHoldings() ([]Posting, error) {
+	if _, err := e.savePlayerData(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	accounts, err := e.storage.ListAccounts()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	
+	holdings := make([]Posting, 0, len(accounts))
+	hold := func(account string, amount Money) {
+		if amount != 0 {
+			holdings = append(holdings, Posting{Account: account, Amount: amount})
+		}
+	}
+	for _, account := range accounts {
+		hold(account.Username, account.Balance)
+		hold(bankRef(account.Username), account.BankBalance)
+	}
+	
+	e.shared.mutex.Lock()
+	for _, account := range e.shared.accounts {
+		hold(sharedAccountRef(account.Name), account.Balance)
+	}
+	e.shared.mutex.Unlock()
+	
+	e.escrows.mutex.Lock()
+	for _, escrow := range e.escrows.escrows {
+		hold(escrowRef(escrow.ID), escrow.Amount)
+	}
+	e.escrows.mutex.Unlock()
+	
+	e.gifts.mutex.Lock()
+	for _, gift := range e.gifts.gifts {
+		hold(giftRef(gift.ID), gift.Amount)
+	}
+	e.gifts.mutex.Unlock()
+	
+	e.vouchers.mutex.Lock()
+	for _, voucher := range e.vouchers.vouchers {
+		if voucher.RedeemedBy == "" {
+			hold(voucherRef(voucher.ID), voucher.Amount)
+		}
+	}
+	e.vouchers.mutex.Unlock()
+	
+	e.pending.mutex.Lock()
+	for _, payment := range e.pending.payments {
+		hold(pendingRef(payment.ID), payment.Amount)
+	}
+	e.pending.mutex.Unlock()
+	
+	e.savings.mutex.Lock()
+	for _, deposit := range e.savings.deposits {
+		hold(savingsRef(deposit.ID), deposit.Amount)
+	}
+	e.savings.mutex.Unlock()
+	
+	return holdings, nil
+}
+
+// Reconciliation is what Reconcile found. Held is everything the ledger
+// accounts hold, which must equal Minted less Burned.
+type Reconciliation struct {
+	Opened       time.Time
+	Transactions int
+	Minted       Money
+	Burned       Money
+	Held         Money
+	
+	// Unbalanced counts transactions whose postings do not add up to
+	// zero, and Unposted those that moved money without postings.
+	Unbalanced int
+	Unposted   int
+	
+	Mismatches []LedgerMismatch
+}
+
+// LedgerMismatch is a ledger account whose postings add up to something
+// other than what it holds.
+type LedgerMismatch struct {
+	Account string
+	Posted  Money
+	Actual  Money
+}
+
+// Reconciled reports whether the ledger accounts for every bit of money.
+func (r *Reconciliation) Reconciled() bool {
+	return r.Held == r.Minted-r.Burned && r.Unbalanced == 0 && r.Unposted == 0 && len(r.Mismatches) == 0
+}
+
+// Reconcile replays the postings since the latest opening balances and
+// compares each ledger account with what it holds.
+func (e *EconomyPlugin) Reconcile() (Reconciliation, error) {
+	if !e.config.DoubleEntry {
+		return Reconciliation{}, ErrDoubleEntryDisabled
+	}
+	if e.ledger == nil {
+		return Reconciliation{}, ErrStorage
+	}
+	
+	resume := e.shutdown.pauseMutations()
+	defer resume(false)
+	
+	holdings, err := e.ledgerHoldings()
+	if err != nil {
+		return Reconciliation{}, err
+	}
+	transactions, err := e.ledger.QueryTransactions("", TransactionFilter{})
+	if err != nil {
+		return Reconciliation{}, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	
+	start := -1
+	for i := range transactions {
+		if transactions[i].Type == OPENING && transactions[i].From == "" && transactions[i].To == "" {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return Reconciliation{}, ErrNoOpeningBalances
+	}
+	
+	var result Reconciliation
+	result.Opened = transactions[start].Timestamp
+	posted := make(map[string]Money)
+	names := make(map[string]string)
+	post := func(balances map[string]Money, account string, amount Money) {
+		key := strings.ToLower(account)
+		if _, exists := names[key]; !exists {
+			names[key] = account
+		}
+		balances[key] += amount
+	}
+	
+	for i := start; i >= 0; i-- {
+		transaction := &transactions[i]
+		result.Transactions++
+		if len(transaction.Postings) == 0 {
+			if len(derivePostings(transaction)) > 0 {
+				result.Unposted++
+			}
+			continue
+		}
+		
+		var sum Money
+		for _, posting := range transaction.Postings {
+			post(posted, posting.Account, posting.Amount)
+			sum += posting.Amount
+		}
+		if sum != 0 {
+			result.Unbalanced++
+		}
+	}
+	
+	result.Minted, result.Burned = -posted[mintAccount], posted[burnAccount]
+	delete(posted, mintAccount)
+	delete(posted, burnAccount)
+	
+	actual := make(map[string]Money)
+	for _, holding := range holdings {
+		post(actual, holding.Account, holding.Amount)
+		result.Held += holding.Amount
+	}
+	
+	for key := range names {
+		if key == mintAccount || key == burnAccount || posted[key] == actual[key] {
+			continue
+		}
+		result.Mismatches = append(result.Mismatches, LedgerMismatch{Account: names[key], Posted: posted[key], Actual: actual[key]})
+	}
+	sort.Slice(result.Mismatches, func(i, j int) bool {
+		return strings.ToLower(result.Mismatches[i].Account) < strings.ToLower(result.Mismatches[j].Account)
+	})
+	
+	return result, nil
+}
+
+func (e *EconomyPlugin) reconcileCommand(ctx *CommandContext) string {
+	if len(ctx.Args) > 0 {
+		return e.message("reconcile.usage")
+	}
+	
+	result, err := e.Reconcile()
+	if err != nil {
+		return e.message("reconcile.failed", "error", e.describeError(err))
+	}
+	
+	args := []string{"held", e.FormatMoney(result.Held), "minted", e.FormatMoney(result.Minted),
+		"burned", e.FormatMoney(result.Burned), "expected", e.FormatMoney(result.Minted - result.Burned),
+		"count", strconv.Itoa(result.Transactions), "since", result.Opened.Format("2006-01-02 15:04")}
+	if result.Reconciled() {
+		return e.message("reconcile.ok", args...)
+	}
+	
+	report := e.message("reconcile.header", args...)
+	if result.Unbalanced > 0 {
+		report += "\n" + e.message("reconcile.unbalanced", "count", strconv.Itoa(result.Unbalanced))
+	}
+	if result.Unposted > 0 {
+		report += "\n" + e.message("reconcile.unposted", "count", strconv.Itoa(result.Unposted))
+	}
+	for _, mismatch := range result.Mismatches {
+		report += "\n" + e.message("reconcile.entry", "account", mismatch.Account,
+			"posted", e.FormatMoney(mismatch.Posted), "actual", e.FormatMoney(mismatch.Actual),
+			"difference", (mismatch.Actual-mismatch.Posted).String())
+	}
+	
+	return report
+}
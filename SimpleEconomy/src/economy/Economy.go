@@ -0,0 +1,2177 @@
+package economy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	
+	"google.golang.org/grpc"
+)
+
+type EconomyPlugin struct {
+	name            string
+	version         string
+	dataFolder      string
+	playerData      map[string]*PlayerAccount
+	names           map[string]string
+	dirty           map[string]bool
+	removed         map[string]bool
+	mutex           sync.RWMutex
+	saving          sync.Mutex
+	locks           accountLocks
+	cache           accountCache
+	config          *Config
+	storage         Storage
+	lazy            LazyStorage
+	watching        bool
+	ledger          TransactionStore
+	history         BalanceHistoryStore
+	rewards         RewardStore
+	earnings        EarningsStore
+	auditLog        AuditStore
+	notifications   NotificationStore
+	journal         *accountJournal
+	logger          *slog.Logger
+	logFile         *os.File
+	rankings        map[LeaderboardCategory]ranking
+	dataVersion     uint64
+	commands        *CommandDispatcher
+	messages        map[string]string
+	hooks           eventHooks
+	transactionLog  *slog.Logger
+	transactionFile *rotatingLog
+	metrics         economyMetrics
+	httpServer      *http.Server
+	events          eventHub
+	grpcServer      *grpc.Server
+	background      backgroundTasks
+	shutdown        shutdownGate
+	backups         backupState
+	
+	onlinePlayers OnlinePlayerProvider
+	messenger     PlayerMessenger
+	permissions   PermissionProvider
+	groups        GroupResolver
+	limiter       transferLimiter
+	fraud         fraudDetector
+	discord       discordNotifier
+	shared        sharedAccounts
+	loans         loanBook
+	escrows       escrowBook
+	gifts         giftBook
+	vouchers      voucherBook
+	pending       pendingBook
+	refunds       refundBook
+	supply        supplyTracker
+	links         linkBook
+	savings       savingsBook
+	quests        questBook
+	votes         voteBook
+	apiKeys       apiKeyring
+	idempotency   idempotencyCache
+	notifier      receiptNotifier
+	rules         ruleSet
+	clock         Clock
+}
+
+type PlayerAccount struct {
+	UUID        string    `json:"uuid"`
+	Username    string    `json:"username"`
+	Balance     Money     `json:"balance"`
+	LastSeen    time.Time `json:"last_seen"`
+	Created     time.Time `json:"created,omitempty"`
+	TotalEarned Money     `json:"total_earned"`
+	TotalSpent  Money     `json:"total_spent"`
+	BankBalance Money     `json:"bank_balance"`
+	Holds       []Hold    `json:"holds,omitempty"`
+	
+	// Hidden accounts are left off /top and out of /eco stats.
+	// PrivateBalance refuses other players' /balance <name>.
+	Hidden         bool `json:"hidden,omitempty"`
+	PrivateBalance bool `json:"private_balance,omitempty"`
+	
+	shard int
+}
+
+type Config struct {
+	DefaultBalance   Money            `json:"default_balance"`
+	StartingBalances map[string]Money `json:"starting_balances"`
+	MaxBalance       Money            `json:"max_balance"`
+	
+	// AccountCreation decides what opens an account for a player: any
+	// command or plugin naming them (auto), only their joining (join), or
+	// only /eco create (existing). SuggestNames offers the closest existing
+	// name when one given has no account.
+	AccountCreation string `json:"account_creation"`
+	SuggestNames    bool   `json:"suggest_names"`
+	
+	// With AllowNegativeBalance, fines and payments may take a wallet down
+	// to MinBalance, which is zero or below. BlockPayInDebt stops players
+	// below zero from using /pay.
+	AllowNegativeBalance bool  `json:"allow_negative_balance"`
+	MinBalance           Money `json:"min_balance"`
+	BlockPayInDebt       bool  `json:"block_pay_in_debt"`
+	
+	// MinTransaction is the smallest amount players may pay or gift, so
+	// the ledger cannot be flooded with tiny payments.
+	MinTransaction Money `json:"min_transaction_amount"`
+	
+	CurrencySymbol    string               `json:"currency_symbol"`
+	CurrencyName      string               `json:"currency_name"`
+	DecimalPlaces     int                  `json:"decimal_places"`
+	RoundingMode      string               `json:"rounding_mode"`
+	Format            MoneyFormatConfig    `json:"money_format"`
+	Language          string               `json:"language"`
+	EnableLogging     bool                 `json:"enable_logging"`
+	Logging           LoggingConfig        `json:"logging"`
+	TransactionLog    TransactionLogConfig `json:"transaction_log"`
+	TopPlayersLimit   int                  `json:"top_players_limit"`
+	TopPageSize       int                  `json:"top_page_size"`
+	TopIncludeVirtual bool                 `json:"top_include_virtual"`
+	HiddenAccounts    []string             `json:"hidden_accounts"`
+	StorageBackend    string               `json:"storage_backend"`
+	MySQL             MySQLConfig          `json:"mysql"`
+	Mongo             MongoConfig          `json:"mongo"`
+	Bolt              BoltConfig           `json:"bolt"`
+	Postgres          PostgresConfig       `json:"postgres"`
+	HTTP              HTTPConfig           `json:"http"`
+	GRPC              GRPCConfig           `json:"grpc"`
+	AutoSaveSeconds   int                  `json:"auto_save_interval_seconds"`
+	Journal           bool                 `json:"journal"`
+	
+	// DoubleEntry records every balance change as postings that add up to
+	// zero, with created money drawn from a mint account, so /eco
+	// reconcile can account for all of it.
+	DoubleEntry bool `json:"double_entry"`
+	
+	// API transfers sent with an idempotency key are remembered for
+	// IdempotencyKeyHours, so retrying one cannot pay twice. Zero turns
+	// keys off.
+	IdempotencyKeyHours int `json:"idempotency_key_hours"`
+	
+	InterestRate       float64 `json:"interest_rate"`
+	InterestInterval   int     `json:"interest_interval"`
+	InterestMaxBalance Money   `json:"interest_max_balance"`
+	InterestOnlineOnly bool    `json:"interest_online_only"`
+	
+	BankMaxBalance   Money   `json:"bank_max_balance"`
+	BankInterestRate float64 `json:"bank_interest_rate"`
+	
+	TransferFees   TransferFeeConfig   `json:"transfer_fees"`
+	TransferLimits TransferLimitConfig `json:"transfer_limits"`
+	
+	// CommandCooldowns is how many seconds a player must wait between
+	// uses of each command, keyed by command name.
+	CommandCooldowns map[string]int `json:"command_cooldowns"`
+	
+	LeaderboardRefresh        string `json:"leaderboard_refresh"`
+	LeaderboardRefreshSeconds int    `json:"leaderboard_refresh_seconds"`
+	
+	BalanceHistoryDays    int `json:"balance_history_days"`
+	BalanceHistorySeconds int `json:"balance_history_interval_seconds"`
+	
+	Backup BackupConfig `json:"backup"`
+	
+	DailyReward DailyRewardConfig `json:"daily_reward"`
+	VoteRewards VoteRewardConfig  `json:"vote_rewards"`
+	Payday      PaydayConfig      `json:"payday"`
+	Loans       LoanConfig        `json:"loans"`
+	Escrow      EscrowConfig      `json:"escrow"`
+	Gifts       GiftConfig        `json:"gifts"`
+	Pending     PendingConfig     `json:"pending_payments"`
+	SupplyCap   SupplyCapConfig   `json:"supply_cap"`
+	Links       LinkConfig        `json:"account_links"`
+	Scopes      ScopeConfig       `json:"scopes"`
+	Savings     SavingsConfig     `json:"savings"`
+	Vouchers    VoucherConfig     `json:"vouchers"`
+	Fraud       FraudConfig       `json:"fraud"`
+	Discord     DiscordConfig     `json:"discord"`
+	
+	AccountCache AccountCacheConfig `json:"account_cache"`
+	Jobs         JobsConfig         `json:"jobs"`
+	
+	Notifications NotificationConfig `json:"notifications"`
+	HealthReport  HealthReportConfig `json:"health_report"`
+	Denominations DenominationConfig `json:"denominations"`
+	WealthTax     WealthTaxConfig    `json:"wealth_tax"`
+	BanPolicy     BanPolicyConfig    `json:"ban_policy"`
+	DeathPenalty  DeathPenaltyConfig `json:"death_penalty"`
+	
+	// ReasonCodes are extra reason codes plugins may record besides the
+	// built-in ones.
+	ReasonCodes []string `json:"reason_codes"`
+}
+
+type MySQLConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+	PoolSize int    `json:"pool_size"`
+}
+
+type MongoConfig struct {
+	URI      string `json:"uri"`
+	Database string `json:"database"`
+}
+
+type PostgresConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Database string `json:"database"`
+	SSLMode  string `json:"sslmode"`
+	PoolSize int    `json:"pool_size"`
+}
+
+// BoltConfig tunes the bolt backend. CompactFreePercent is how much of the
+// file must be free pages for it to be compacted on startup; 0 never
+// compacts.
+type BoltConfig struct {
+	CompactFreePercent int `json:"compact_free_percent"`
+}
+
+type TransactionType int
+
+const (
+	ADD TransactionType = iota
+	SUBTRACT
+	SET
+	TRANSFER
+	INTEREST
+	FEE
+	BANK_DEPOSIT
+	BANK_WITHDRAW
+	SHARED_DEPOSIT
+	SHARED_WITHDRAW
+	ROLLBACK
+	REWARD
+	SALARY
+	LOAN
+	LOAN_REPAYMENT
+	ESCROW_HOLD
+	ESCROW_RELEASE
+	ESCROW_REFUND
+	VOUCHER_CREATE
+	VOUCHER_REDEEM
+	VOUCHER_REFUND
+	BATCH
+	PURCHASE
+	HOLD_CAPTURE
+	JOB
+	ITEM_WITHDRAW
+	ITEM_DEPOSIT
+	TAX
+	MERGE
+	OPENING
+	PENDING_PAYMENT
+	PENDING_DELIVERY
+	SAVINGS_DEPOSIT
+	SAVINGS_WITHDRAW
+	SAVINGS_MATURED
+	GIFT_SEND
+	GIFT_ACCEPT
+	GIFT_RETURN
+	REFUND
+	CONFISCATE
+	DEATH_PENALTY
+	
+	transactionTypeCount
+)
+
+func (t TransactionType) String() string {
+	switch t {
+	case ADD:
+		return "ADD"
+	case SUBTRACT:
+		return "SUBTRACT"
+	case SET:
+		return "SET"
+	case TRANSFER:
+		return "TRANSFER"
+	case INTEREST:
+		return "INTEREST"
+	case FEE:
+		return "FEE"
+	case BANK_DEPOSIT:
+		return "BANK_DEPOSIT"
+	case BANK_WITHDRAW:
+		return "BANK_WITHDRAW"
+	case SHARED_DEPOSIT:
+		return "SHARED_DEPOSIT"
+	case SHARED_WITHDRAW:
+		return "SHARED_WITHDRAW"
+	case ROLLBACK:
+		return "ROLLBACK"
+	case REWARD:
+		return "REWARD"
+	case SALARY:
+		return "SALARY"
+	case LOAN:
+		return "LOAN"
+	case LOAN_REPAYMENT:
+		return "LOAN_REPAYMENT"
+	case ESCROW_HOLD:
+		return "ESCROW_HOLD"
+	case ESCROW_RELEASE:
+		return "ESCROW_RELEASE"
+	case ESCROW_REFUND:
+		return "ESCROW_REFUND"
+	case VOUCHER_CREATE:
+		return "VOUCHER_CREATE"
+	case VOUCHER_REDEEM:
+		return "VOUCHER_REDEEM"
+	case VOUCHER_REFUND:
+		return "VOUCHER_REFUND"
+	case BATCH:
+		return "BATCH"
+	case PURCHASE:
+		return "PURCHASE"
+	case HOLD_CAPTURE:
+		return "HOLD_CAPTURE"
+	case JOB:
+		return "JOB"
+	case ITEM_WITHDRAW:
+		return "ITEM_WITHDRAW"
+	case ITEM_DEPOSIT:
+		return "ITEM_DEPOSIT"
+	case TAX:
+		return "TAX"
+	case MERGE:
+		return "MERGE"
+	case OPENING:
+		return "OPENING"
+	case PENDING_PAYMENT:
+		return "PENDING_PAYMENT"
+	case PENDING_DELIVERY:
+		return "PENDING_DELIVERY"
+	case SAVINGS_DEPOSIT:
+		return "SAVINGS_DEPOSIT"
+	case SAVINGS_WITHDRAW:
+		return "SAVINGS_WITHDRAW"
+	case SAVINGS_MATURED:
+		return "SAVINGS_MATURED"
+	case GIFT_SEND:
+		return "GIFT_SEND"
+	case GIFT_ACCEPT:
+		return "GIFT_ACCEPT"
+	case GIFT_RETURN:
+		return "GIFT_RETURN"
+	case REFUND:
+		return "REFUND"
+	case CONFISCATE:
+		return "CONFISCATE"
+	case DEATH_PENALTY:
+		return "DEATH_PENALTY"
+	default:
+		return fmt.Sprintf("TransactionType(%d)", int(t))
+	}
+}
+
+func parseTransactionType(name string) (TransactionType, bool) {
+	for t := ADD; t < transactionTypeCount; t++ {
+		if strings.EqualFold(t.String(), name) {
+			return t, true
+		}
+	}
+	
+	return 0, false
+}
+
+type Transaction struct {
+	// ID is unique to the transaction. Transactions recorded before IDs
+	// were assigned have none.
+	ID        string          `json:"id,omitempty"`
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Amount    Money           `json:"amount"`
+	Type      TransactionType `json:"type"`
+	Code      ReasonCode      `json:"code,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+	Reason    string          `json:"reason"`
+	
+	// Previous is the balance a SET replaced, so it can be rolled back.
+	// It is nil for other types and for SETs recorded before it existed.
+	Previous *Money `json:"previous,omitempty"`
+	
+	// Batch lists each account's change for a BATCH, which has no From
+	// or To of its own.
+	Batch []BatchEntry `json:"batch,omitempty"`
+	
+	// Postings are the double-entry legs, recorded when double_entry is
+	// on.
+	Postings []Posting `json:"postings,omitempty"`
+}
+
+// defaultConfig is the configuration used for every key the config file
+// leaves out.
+func defaultConfig() *Config {
+	return &Config{
+		DefaultBalance:  1000 * moneyScale,
+		MaxBalance:      1000000 * moneyScale,
+		AccountCreation: createAuto,
+		SuggestNames:    true,
+		CurrencySymbol:  "$",
+		CurrencyName:    "Coins",
+		DecimalPlaces:   2,
+		RoundingMode:    roundFloor,
+		Language:        "en",
+		Format: MoneyFormatConfig{
+			ThousandsSeparator: ",",
+			DecimalSeparator:   ".",
+			SymbolPosition:     "prefix",
+			NegativeStyle:      negativeMinus,
+		},
+		EnableLogging: true,
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		TransactionLog: TransactionLogConfig{
+			MaxSizeMB:  10,
+			Compress:   true,
+			MaxFiles:   10,
+			MaxAgeDays: 90,
+		},
+		TopPlayersLimit: 100,
+		TopPageSize:     10,
+		HiddenAccounts:  []string{},
+		StorageBackend:  "json",
+		MySQL: MySQLConfig{
+			Host:     "localhost",
+			Port:     3306,
+			User:     "root",
+			Database: "simpleeconomy",
+			PoolSize: 10,
+		},
+		Mongo: MongoConfig{
+			URI:      "mongodb://localhost:27017",
+			Database: "simpleeconomy",
+		},
+		Bolt: BoltConfig{
+			CompactFreePercent: 50,
+		},
+		Postgres: PostgresConfig{
+			Host:     "localhost",
+			Port:     5432,
+			User:     "postgres",
+			Database: "simpleeconomy",
+			SSLMode:  "disable",
+			PoolSize: 10,
+		},
+		HTTP: HTTPConfig{
+			BindAddress: "127.0.0.1:8080",
+		},
+		GRPC: GRPCConfig{
+			BindAddress: "127.0.0.1:50051",
+		},
+		IdempotencyKeyHours:       24,
+		AutoSaveSeconds:           300,
+		Journal:                   true,
+		InterestInterval:          3600,
+		BankMaxBalance:            10000000 * moneyScale,
+		CommandCooldowns:          map[string]int{"top": 10, "pay": 3},
+		LeaderboardRefresh:        leaderboardOnChange,
+		LeaderboardRefreshSeconds: 60,
+		BalanceHistoryDays:        30,
+		BalanceHistorySeconds:     3600,
+		Backup: BackupConfig{
+			Enabled:  true,
+			Schedule: "0 4 * * *",
+			Keep:     7,
+		},
+		DailyReward: DailyRewardConfig{
+			Amount:      100 * moneyScale,
+			StreakBonus: 10,
+			MaxStreak:   7,
+		},
+		VoteRewards: VoteRewardConfig{
+			Reward:           100 * moneyScale,
+			DailyMultipliers: []float64{1, 1.25, 1.5, 2},
+		},
+		Payday: PaydayConfig{
+			IntervalMinutes: 30,
+			Salary:          50 * moneyScale,
+			Groups: []PaydayGroup{
+				{Name: "vip", Permission: "economy.payday.vip", Salary: 100 * moneyScale},
+			},
+		},
+		Links: LinkConfig{
+			MaxChildren:        3,
+			OfferExpiryMinutes: 10,
+		},
+		Loans: LoanConfig{
+			MaxInterest:        25,
+			MaxDays:            30,
+			OfferExpiryMinutes: 10,
+		},
+		Escrow: EscrowConfig{
+			DefaultExpiryMinutes: 1440,
+		},
+		Gifts: GiftConfig{
+			ExpiryMinutes: 10,
+			MaxMessage:    100,
+		},
+		Scopes: ScopeConfig{
+			Worlds: map[string][]string{},
+		},
+		Savings: SavingsConfig{
+			Terms: []SavingsTerm{
+				{Days: 7, Rate: 1},
+				{Days: 30, Rate: 5},
+			},
+			EarlyPenalty: 10,
+			MinDeposit:   100 * moneyScale,
+			MaxDeposits:  5,
+		},
+		Vouchers: VoucherConfig{
+			ExpiryDays: 30,
+			MinAmount:  1 * moneyScale,
+		},
+		Fraud: FraudConfig{
+			WindowMinutes:     60,
+			CircularDepth:     3,
+			FreshAccountHours: 24,
+			FreshSenders:      10,
+			BalanceJump:       100000 * moneyScale,
+			KeepAlerts:        100,
+		},
+		Discord: DiscordConfig{
+			Username:        "SimpleEconomy",
+			LargeTransfer:   10000 * moneyScale,
+			AdminActions:    true,
+			TopPlayer:       true,
+			SummarySchedule: "0 0 * * *",
+			MaxPerMinute:    10,
+			Templates:       map[string]string{},
+		},
+		AccountCache: AccountCacheConfig{
+			MaxAccounts: 10000,
+			IdleMinutes: 30,
+		},
+		Jobs: JobsConfig{
+			DailyCap: 10000 * moneyScale,
+			JobCaps:  map[string]Money{},
+		},
+		ReasonCodes: []string{},
+		Notifications: NotificationConfig{
+			Enabled: true,
+		},
+		HealthReport: HealthReportConfig{
+			Schedule: "5 0 * * *",
+			Discord:  true,
+			Keep:     90,
+		},
+		Denominations: DenominationConfig{
+			Items: []Denomination{
+				{Item: "minecraft:gold_block", Value: 90 * moneyScale},
+				{Item: "minecraft:gold_ingot", Value: 10 * moneyScale},
+				{Item: "minecraft:gold_nugget", Value: 1 * moneyScale},
+			},
+		},
+		WealthTax: WealthTaxConfig{
+			Schedule:     "0 0 1 * *",
+			Threshold:    100000 * moneyScale,
+			Percent:      1,
+			ExemptGroups: []string{},
+			WarningHours: 24,
+		},
+		BanPolicy: BanPolicyConfig{
+			Permanent: banFreeze,
+			Temporary: banNone,
+			Recipient: "#server",
+			Hide:      true,
+		},
+		DeathPenalty: DeathPenaltyConfig{
+			Mode:         deathPercent,
+			Percent:      10,
+			Amount:       100 * moneyScale,
+			ExemptGroups: []string{},
+		},
+	}
+}
+
+func NewEconomyPlugin() *EconomyPlugin {
+	e := &EconomyPlugin{
+		name:       "EconomyPocketmine",
+		version:    "1.0.0",
+		dataFolder: "plugins/EconomyPocketmine",
+		playerData: make(map[string]*PlayerAccount),
+		names:      make(map[string]string),
+		dirty:      make(map[string]bool),
+		removed:    make(map[string]bool),
+		config:     defaultConfig(),
+		clock:      systemClock{},
+	}
+	// Used until OnEnable has read the logging config.
+	e.logger = slog.New(slog.NewTextHandler(os.Stderr, nil)).With("plugin", e.name)
+	
+	return e
+}
+
+func (e *EconomyPlugin) OnEnable() {
+	e.logger.Info("Enabling plugin", "version", e.version)
+	e.shutdown.open()
+	
+	if err := e.openData(); err != nil {
+		e.logger.Error("Failed to enable plugin", "error", err)
+		return
+	}
+	
+	e.registerFraudHooks()
+	e.registerNotificationHooks()
+	e.registerDiscordHooks()
+	e.registerCommands()
+	e.startHTTPServer()
+	e.startGRPCServer()
+	e.startBackgroundTasks()
+	
+	e.logger.Info("Plugin enabled")
+}
+
+// openData loads the config and opens storage and every data file in the
+// data folder, without starting servers or background tasks.
+func (e *EconomyPlugin) openData() error {
+	if err := os.MkdirAll(e.dataFolder, 0755); err != nil {
+		return fmt.Errorf("create data folder %s: %w", e.dataFolder, err)
+	}
+	
+	if err := e.loadConfig(); err != nil {
+		return err
+	}
+	e.setupLogger()
+	e.loadMessages()
+	
+	storage, err := newStorage(e.config, e.dataFolder)
+	if err != nil {
+		return fmt.Errorf("open %s storage: %w", e.config.StorageBackend, err)
+	}
+	e.storage = storage
+	e.lazy = e.openLazily(storage)
+	
+	if ledger, ok := storage.(TransactionStore); ok {
+		e.ledger = ledger
+	} else {
+		e.ledger = NewJSONLinesLedger(filepath.Join(e.dataFolder, "transactions.jsonl"))
+	}
+	
+	if history, ok := storage.(BalanceHistoryStore); ok {
+		e.history = history
+	} else {
+		e.history = NewJSONBalanceHistory(filepath.Join(e.dataFolder, "balance_history.json"))
+	}
+	
+	if rewards, ok := storage.(RewardStore); ok {
+		e.rewards = rewards
+	} else {
+		e.rewards = NewJSONRewardStore(filepath.Join(e.dataFolder, "rewards.json"))
+	}
+	
+	if earnings, ok := storage.(EarningsStore); ok {
+		e.earnings = earnings
+	} else {
+		e.earnings = NewJSONEarningsStore(filepath.Join(e.dataFolder, "earnings.json"))
+	}
+	
+	if auditLog, ok := storage.(AuditStore); ok {
+		e.auditLog = auditLog
+	} else {
+		e.auditLog = NewJSONLinesAuditLog(filepath.Join(e.dataFolder, "audit.jsonl"))
+	}
+	
+	if notifications, ok := storage.(NotificationStore); ok {
+		e.notifications = notifications
+	} else {
+		e.notifications = NewJSONNotificationStore(filepath.Join(e.dataFolder, "notifications.json"))
+	}
+	
+	if notifier, ok := storage.(ChangeNotifier); ok {
+		if err := notifier.WatchChanges(e.accountChanged); err != nil {
+			e.logger.Warn("Failed to watch for account changes, refreshing accounts on every access instead", "error", err)
+		} else {
+			e.watching = true
+		}
+	}
+	
+	// Shared backends commit every mutation to the database directly, so
+	// only local backends need the journal.
+	if _, shared := storage.(AtomicStorage); !shared && e.config.Journal {
+		if e.journal, err = openAccountJournal(e.dataFolder, e.logger); err != nil {
+			e.logger.Warn("Failed to open journal, unsaved changes will not survive a crash", "error", err)
+		}
+	}
+	
+	e.loadPlayerData()
+	e.loadSharedAccounts()
+	e.loadLoans()
+	e.loadEscrows()
+	e.loadGifts()
+	e.loadVouchers()
+	e.loadPendingPayments()
+	e.loadRefunds()
+	e.loadLinks()
+	e.loadSavings()
+	e.loadQuests()
+	e.loadVotes()
+	e.loadAPIKeys()
+	if err := e.loadRules(); err != nil {
+		e.logger.Error("Failed to load transaction rules", "error", err)
+	}
+	e.openDoubleEntry()
+	
+	return nil
+}
+
+func (e *EconomyPlugin) OnDisable() {
+	e.Disable()
+}
+
+// Disable is OnDisable for callers that need to know whether the final
+// save succeeded, such as a process exiting on a signal. Servers and new
+// commands are stopped first, then running commands and background tasks
+// are waited for, so the save sees every change made before it.
+func (e *EconomyPlugin) Disable() error {
+	e.logger.Info("Disabling plugin")
+	e.stopHTTPServer()
+	e.stopGRPCServer()
+	if !e.shutdown.drain(shutdownTimeout) {
+		e.logger.Warn("Commands still running at shutdown, saving without waiting for them", "timeout", shutdownTimeout)
+	}
+	e.stopBackgroundTasks()
+	e.shutdown.closeMutations()
+	
+	var err error
+	if e.storage != nil {
+		var flushed int
+		if flushed, err = e.savePlayerData(); err != nil {
+			e.logger.Error("Final save failed, recent changes may be lost", "error", err)
+		} else {
+			e.logger.Info("Final save complete", "accounts", flushed)
+		}
+		e.recordBalanceHistory()
+		if e.journal != nil {
+			e.journal.close()
+		}
+		if closeErr := e.storage.Close(); closeErr != nil {
+			e.logger.Error("Failed to close storage", "error", closeErr)
+			if err == nil {
+				err = closeErr
+			}
+		}
+	}
+	e.logger.Info("Plugin disabled")
+	e.closeLogFile()
+	
+	return err
+}
+
+// loadConfig replaces the running config with the config file. It fails only
+// when Validate rejects the file; a file that cannot be read or parsed is
+// logged and the running config kept.
+func (e *EconomyPlugin) loadConfig() error {
+	config, err := e.readConfig()
+	if errors.Is(err, errInvalidConfig) {
+		return err
+	}
+	if err != nil {
+		e.logger.Error("Failed to load config", "error", err)
+		return nil
+	}
+	
+	e.config = config
+	return nil
+}
+
+// readConfig reads the config file over the defaults and validates it. Any
+// value corrected or key filled in is logged and written back, so the file
+// always shows the configuration actually in use. A missing file is
+// written from the running config.
+func (e *EconomyPlugin) readConfig() (*Config, error) {
+	configPath := e.configPath()
+	
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		e.saveConfig()
+		return e.config, nil
+	}
+	
+	data, err := readConfigFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	
+	config := defaultConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		// The file is left alone so the mistake can be fixed by hand.
+		return nil, fmt.Errorf("parse %s: %w", configPath, err)
+	}
+	
+	corrections, err := config.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", errInvalidConfig, configPath, err)
+	}
+	for _, correction := range corrections {
+		e.logger.Warn("Corrected config value", "key", correction.Key, "problem", correction.Problem)
+	}
+	
+	normalized, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("marshal config: %w", err)
+	}
+	missing := missingConfigKeys(data, normalized)
+	for _, key := range missing {
+		e.logger.Info("Added missing config key with its default", "key", key)
+	}
+	
+	if len(corrections) > 0 || len(missing) > 0 {
+		e.writeConfig(config)
+	}
+	
+	return config, nil
+}
+
+func (e *EconomyPlugin) saveConfig() {
+	e.writeConfig(e.config)
+}
+
+func (e *EconomyPlugin) writeConfig(config *Config) {
+	configPath := e.configPath()
+	
+	data, err := json.Marshal(config)
+	if err != nil {
+		e.logger.Error("Failed to marshal config", "error", err)
+		return
+	}
+	
+	if err := writeConfigFile(configPath, data); err != nil {
+		e.logger.Error("Failed to write config", "path", configPath, "error", err)
+	}
+}
+
+func (e *EconomyPlugin) loadPlayerData() {
+	if err := e.storage.Load(); err != nil {
+		e.logger.Error("Failed to load player data", "error", err)
+		e.countStorageError("load")
+		return
+	}
+	
+	e.replayJournal()
+	
+	if _, lazy := e.lazyStorage(); lazy {
+		e.mutex.Lock()
+		e.playerData = make(map[string]*PlayerAccount)
+		e.names = make(map[string]string)
+		e.dirty = make(map[string]bool)
+		e.removed = make(map[string]bool)
+		e.cache.reset(true)
+		e.mutex.Unlock()
+		
+		e.invalidateTopPlayers()
+		return
+	}
+	
+	accounts, err := e.storage.ListAccounts()
+	if err != nil {
+		e.logger.Error("Failed to list player accounts", "error", err)
+		e.countStorageError("load")
+		return
+	}
+	
+	// When two accounts carry the same name, the most recently seen one
+	// keeps it.
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].LastSeen.Before(accounts[j].LastSeen)
+	})
+	
+	e.mutex.Lock()
+	e.playerData = make(map[string]*PlayerAccount, len(accounts))
+	e.names = make(map[string]string, len(accounts))
+	for _, account := range accounts {
+		e.trackAccount(account)
+	}
+	e.dirty = make(map[string]bool)
+	e.removed = make(map[string]bool)
+	e.cache.reset(false)
+	e.mutex.Unlock()
+	
+	e.invalidateTopPlayers()
+}
+
+// savePlayerData writes every changed account to storage and returns how
+// many it wrote. Accounts that could not be stored stay dirty for the next
+// save.
+func (e *EconomyPlugin) savePlayerData() (int, error) {
+	start := time.Now()
+	defer func() { e.metrics.saves.observe(time.Since(start)) }()
+	
+	e.saving.Lock()
+	defer e.saving.Unlock()
+	
+	e.mutex.Lock()
+	accounts := make([]*PlayerAccount, 0, len(e.dirty))
+	for key := range e.dirty {
+		if account, exists := e.playerData[key]; exists {
+			accounts = append(accounts, account)
+		}
+	}
+	e.dirty = make(map[string]bool)
+	removed := e.removed
+	e.removed = make(map[string]bool)
+	// Rotating under e.mutex means any change this save misses is
+	// journaled to the new segment, which is kept.
+	segment := 0
+	if e.journal != nil {
+		segment = e.journal.rotate()
+	}
+	e.mutex.Unlock()
+	
+	flushed, failed := 0, 0
+	for _, account := range accounts {
+		snapshot := e.snapshotAccount(account)
+		if err := e.storage.PutAccount(&snapshot); err != nil {
+			e.logger.Error("Failed to store account", "player", snapshot.Username, "error", err)
+			e.countStorageError("put")
+			e.markDirty(account)
+			failed++
+			continue
+		}
+		flushed++
+	}
+	
+	for uuid := range removed {
+		if err := e.storage.DeleteAccount(uuid); err != nil {
+			e.logger.Error("Failed to delete account", "uuid", uuid, "error", err)
+			e.countStorageError("delete")
+			e.mutex.Lock()
+			e.removed[uuid] = true
+			e.mutex.Unlock()
+		}
+	}
+	
+	if err := e.storage.Save(); err != nil {
+		e.logger.Error("Failed to save player data", "error", err)
+		e.countStorageError("save")
+		return 0, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	
+	if failed > 0 {
+		return flushed, fmt.Errorf("%w: %d accounts not stored", ErrStorage, failed)
+	}
+	if e.journal != nil {
+		e.journal.discard(segment)
+	}
+	
+	return flushed, nil
+}
+
+// createAccount creates an account for a player known only by name. It is
+// keyed by the name's offline UUID until the player joins and OnPlayerJoin
+// adopts it.
+func (e *EconomyPlugin) createAccount(username string) *PlayerAccount {
+	if account, exists := e.lookupAccount(username); exists {
+		return account
+	}
+	
+	uuid := e.accountUUID(username)
+	balance := e.startingBalance(uuid, username)
+	
+	e.mutex.Lock()
+	account, exists := e.playerData[e.names[strings.ToLower(username)]]
+	var created PlayerAccount
+	if !exists {
+		account = e.newAccount(uuid, username, balance)
+		created = *account
+	}
+	e.mutex.Unlock()
+	
+	if !exists {
+		e.invalidateTopPlayers()
+		e.fireAccountCreated(&created)
+		e.postAccountOpening(&created)
+	}
+	
+	return account
+}
+
+// newAccount creates and tracks a fresh account opening with balance.
+// Callers hold e.mutex.
+func (e *EconomyPlugin) newAccount(uuid, username string, balance Money) *PlayerAccount {
+	account := &PlayerAccount{
+		UUID:        uuid,
+		Username:    username,
+		Balance:     balance,
+		LastSeen:    e.now(),
+		Created:     e.now(),
+		TotalEarned: balance,
+		TotalSpent:  0,
+	}
+	
+	e.trackAccount(account)
+	e.dirty[uuid] = true
+	e.cache.touch(uuid)
+	
+	return account
+}
+
+// trackAccount indexes account by UUID and display name. Callers hold
+// e.mutex.
+func (e *EconomyPlugin) trackAccount(account *PlayerAccount) {
+	account.shard = accountShard(account.UUID)
+	e.playerData[account.UUID] = account
+	e.names[strings.ToLower(account.Username)] = account.UUID
+}
+
+func (e *EconomyPlugin) lookupAccount(username string) (*PlayerAccount, bool) {
+	e.mutex.RLock()
+	account, exists := e.playerData[e.names[strings.ToLower(username)]]
+	if exists {
+		e.cache.touch(account.UUID)
+	}
+	e.mutex.RUnlock()
+	
+	if lazy, ok := e.lazyStorage(); ok {
+		if !exists {
+			return e.lookupLazy(lazy, username)
+		}
+		e.cache.hits.Add(1)
+	}
+	
+	return account, exists
+}
+
+// GetUUID returns the UUID of the account currently holding username.
+func (e *EconomyPlugin) GetUUID(username string) (string, bool) {
+	account, exists := e.lookupAccount(username)
+	if !exists {
+		return "", false
+	}
+	
+	return e.accountKey(account), true
+}
+
+// accountKey reads account's UUID, which only changes under e.mutex.
+func (e *EconomyPlugin) accountKey(account *PlayerAccount) string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	
+	return account.UUID
+}
+
+// OnPlayerJoin records that the player with uuid joined as username. A
+// changed name renames their account, and a placeholder account created
+// for the name before their UUID was known is adopted under uuid. New
+// players get an account unless account_creation is existing.
+func (e *EconomyPlugin) OnPlayerJoin(uuid, username string) {
+	uuid = normalizeUUID(uuid)
+	if !isUUID(uuid) || username == "" || IsVirtualAccount(username) {
+		e.logger.Warn("Ignoring join with invalid identity", "player", username, "uuid", uuid)
+		return
+	}
+	
+	account := e.joinTarget(uuid, username)
+	if account == nil && e.creationPolicy() == createExisting {
+		e.logger.Info("Joining player has no account", "player", username, "uuid", uuid)
+		return
+	}
+	if account == nil {
+		balance := e.startingBalance(uuid, username)
+		
+		e.mutex.Lock()
+		_, exists := e.playerData[uuid]
+		var created PlayerAccount
+		if !exists {
+			created = *e.newAccount(uuid, username, balance)
+		}
+		e.mutex.Unlock()
+		
+		e.invalidateTopPlayers()
+		if !exists {
+			e.fireAccountCreated(&created)
+			e.postAccountOpening(&created)
+		}
+		e.joinScopes(uuid, username)
+		e.autoClaimReward(username)
+		return
+	}
+	
+	placeholder, oldName := "", ""
+	unlock := e.locks.lock(account)
+	e.mutex.Lock()
+	if account.UUID != uuid && e.playerData[uuid] == nil {
+		e.logger.Info("Linked account to UUID", "player", account.Username, "uuid", uuid)
+		placeholder = account.UUID
+		delete(e.playerData, account.UUID)
+		delete(e.dirty, account.UUID)
+		e.removed[account.UUID] = true
+		account.UUID = uuid
+		e.playerData[uuid] = account
+		e.cache.touch(uuid)
+	}
+	if account.UUID == uuid {
+		if account.Username != username {
+			e.logger.Info("Player renamed", "old_name", account.Username, "player", username, "uuid", uuid)
+			if !strings.EqualFold(account.Username, username) {
+				oldName = account.Username
+			}
+		}
+		if e.names[strings.ToLower(account.Username)] == uuid {
+			delete(e.names, strings.ToLower(account.Username))
+		}
+		account.Username = username
+		account.LastSeen = e.now()
+		e.names[strings.ToLower(username)] = uuid
+		e.dirty[uuid] = true
+	}
+	e.mutex.Unlock()
+	unlock()
+	
+	if oldName != "" {
+		e.reassignPlayerBooks(oldName, username)
+	}
+	e.joinScopes(uuid, username)
+	e.invalidateTopPlayers()
+	e.autoClaimReward(username)
+	e.deliverNotifications(username, uuid, placeholder)
+}
+
+// joinTarget finds the account a joining player owns: the one keyed by
+// their UUID, or else a placeholder created for their name.
+func (e *EconomyPlugin) joinTarget(uuid, username string) *PlayerAccount {
+	if account, exists := e.loadAccount(uuid); exists {
+		return account
+	}
+	
+	account, exists := e.lookupAccount(username)
+	if !exists {
+		return nil
+	}
+	
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	
+	if isPlaceholder(account) {
+		return account
+	}
+	
+	return nil
+}
+
+func (e *EconomyPlugin) getAccount(username string) *PlayerAccount {
+	account, exists := e.lookupAccount(username)
+	
+	if !exists {
+		account = e.createAccount(username)
+	} else {
+		unlock := e.locks.lock(account)
+		account.LastSeen = e.now()
+		unlock()
+		e.markDirty(account)
+	}
+	
+	if _, shared := e.storage.(AtomicStorage); shared && exists && !e.watching {
+		e.refreshAccount(account)
+	}
+	
+	return account
+}
+
+func (e *EconomyPlugin) refreshAccount(account *PlayerAccount) {
+	stored, err := e.storage.GetAccount(e.accountKey(account))
+	if err != nil {
+		e.logger.Error("Failed to refresh account", "player", account.Username, "error", err)
+		return
+	}
+	if stored == nil {
+		return
+	}
+	
+	unlock := e.locks.lock(account)
+	copyMoney(account, stored)
+	unlock()
+}
+
+// accountChanged refreshes the loaded account another server changed, or
+// every loaded account when uuid is "".
+func (e *EconomyPlugin) accountChanged(uuid string) {
+	e.mutex.RLock()
+	accounts := make([]*PlayerAccount, 0, 1)
+	if uuid == "" {
+		for _, account := range e.playerData {
+			accounts = append(accounts, account)
+		}
+	} else if account, exists := e.playerData[uuid]; exists {
+		accounts = append(accounts, account)
+	}
+	e.mutex.RUnlock()
+	
+	for _, account := range accounts {
+		e.refreshAccount(account)
+	}
+	if len(accounts) > 0 {
+		e.invalidateTopPlayers()
+	}
+}
+
+// copyMoney copies the fields that only ever change through mutateAccounts.
+func copyMoney(dst, src *PlayerAccount) {
+	dst.Balance = src.Balance
+	dst.TotalEarned = src.TotalEarned
+	dst.TotalSpent = src.TotalSpent
+	dst.BankBalance = src.BankBalance
+	dst.Holds = src.Holds
+}
+
+func (e *EconomyPlugin) markDirty(accounts ...*PlayerAccount) {
+	e.mutex.Lock()
+	for _, account := range accounts {
+		e.dirty[account.UUID] = true
+	}
+	e.mutex.Unlock()
+}
+
+// snapshotAccount copies an account's fields under its lock so the copy
+// can be read or persisted without racing mutations.
+func (e *EconomyPlugin) snapshotAccount(account *PlayerAccount) PlayerAccount {
+	unlock := e.locks.lock(account)
+	defer unlock()
+	
+	return *account
+}
+
+func (e *EconomyPlugin) snapshotAccounts() []PlayerAccount {
+	e.mutex.RLock()
+	accounts := make([]*PlayerAccount, 0, len(e.playerData))
+	for _, account := range e.playerData {
+		accounts = append(accounts, account)
+	}
+	e.mutex.RUnlock()
+	
+	snapshots := make([]PlayerAccount, len(accounts))
+	for i, account := range accounts {
+		snapshots[i] = e.snapshotAccount(account)
+	}
+	
+	// Accounts left on disk by a lazy backend count too.
+	if _, lazy := e.lazyStorage(); lazy {
+		snapshots = e.withStoredAccounts(snapshots)
+	}
+	
+	return snapshots
+}
+
+// mutateAccounts applies fn to the named accounts as one unit, failing if
+// one has no account and account_creation will not open it. Shared
+// backends run fn against freshly locked rows; otherwise it runs against
+// the in-memory accounts with their account locks held. An error from fn
+// aborts the mutation and is returned unchanged.
+func (e *EconomyPlugin) mutateAccounts(usernames []string, fn func(accounts []*PlayerAccount) error) error {
+	if err := e.requireAccounts(usernames...); err != nil {
+		return err
+	}
+	
+	accounts := make([]*PlayerAccount, len(usernames))
+	for i, username := range usernames {
+		accounts[i] = e.getAccount(username)
+	}
+	
+	return e.mutateLoaded(accounts, fn)
+}
+
+// mutateLoaded is mutateAccounts for accounts already looked up.
+func (e *EconomyPlugin) mutateLoaded(accounts []*PlayerAccount, fn func(accounts []*PlayerAccount) error) error {
+	if !e.shutdown.beginMutation() {
+		return ErrShuttingDown
+	}
+	defer e.shutdown.endMutation()
+	
+	if shared, ok := e.storage.(AtomicStorage); ok {
+		return e.mutateShared(shared, accounts, fn)
+	}
+	
+	unlock := e.locks.lock(accounts...)
+	err := fn(accounts)
+	if err == nil {
+		e.markDirty(accounts...)
+		e.journalAccounts(accounts)
+	}
+	unlock()
+	
+	return err
+}
+
+func (e *EconomyPlugin) mutateShared(storage AtomicStorage, accounts []*PlayerAccount, fn func(accounts []*PlayerAccount) error) error {
+	seeds := make([]PlayerAccount, len(accounts))
+	for i, account := range accounts {
+		seeds[i] = e.snapshotAccount(account)
+	}
+	
+	var rejected error
+	var updated []*PlayerAccount
+	err := storage.UpdateAccounts(seeds, func(locked []*PlayerAccount) error {
+		if rejected = fn(locked); rejected != nil {
+			return rejected
+		}
+		updated = locked
+		return nil
+	})
+	if rejected != nil {
+		return rejected
+	}
+	if err != nil {
+		e.logger.Error("Failed to update accounts", "error", err)
+		e.countStorageError("update")
+		return fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	
+	unlock := e.locks.lock(accounts...)
+	for i, account := range accounts {
+		copyMoney(account, updated[i])
+	}
+	unlock()
+	
+	return nil
+}
+
+func (e *EconomyPlugin) getBalance(username string) Money {
+	account := e.getAccount(username)
+	
+	unlock := e.locks.lock(account)
+	defer unlock()
+	
+	return account.Balance
+}
+
+// peekBalance is getBalance for names that may have no account: it reads 0
+// for them rather than creating one.
+func (e *EconomyPlugin) peekBalance(username string) Money {
+	account, exists := e.lookupAccount(username)
+	if !exists {
+		return 0
+	}
+	
+	unlock := e.locks.lock(account)
+	defer unlock()
+	
+	return account.Balance
+}
+
+func (e *EconomyPlugin) setBalance(username string, amount Money, reason string) (string, error) {
+	return e.assignBalance(username, amount, reasonOr(reason, "Balance set by admin"))
+}
+
+// assignBalance replaces an account's balance, recording the old one so
+// the SET can be rolled back, and returns the transaction ID.
+func (e *EconomyPlugin) assignBalance(username string, amount Money, reason string) (string, error) {
+	if amount < e.minBalance() {
+		return "", ErrInvalidAmount
+	}
+	if amount > e.config.MaxBalance {
+		return "", ErrMaxBalanceExceeded
+	}
+	
+	var oldBalance Money
+	err := e.mutateAccounts([]string{username}, func(accounts []*PlayerAccount) error {
+		oldBalance = accounts[0].Balance
+		accounts[0].Balance = amount
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(username, oldBalance, amount, SET)
+	
+	return e.recordTransaction(&Transaction{
+		To:        username,
+		Amount:    amount,
+		Type:      SET,
+		Timestamp: e.now(),
+		Reason:    reason,
+		Previous:  &oldBalance,
+	}), nil
+}
+
+func (e *EconomyPlugin) addMoney(username string, amount Money, reason string) (string, error) {
+	return e.credit(username, amount, ADD, ReasonAdmin, reasonOr(reason, "Money added"))
+}
+
+// credit adds amount to an account and records it under transactionType
+// and code, returning the transaction ID.
+func (e *EconomyPlugin) credit(username string, amount Money, transactionType TransactionType, code ReasonCode, reason string) (string, error) {
+	if amount <= 0 {
+		return "", ErrInvalidAmount
+	}
+	
+	var oldBalance, newBalance Money
+	err := e.mutateAccounts([]string{username}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		oldBalance = account.Balance
+		newBalance = account.Balance + amount
+		
+		if newBalance > e.config.MaxBalance {
+			return ErrMaxBalanceExceeded
+		}
+		
+		account.Balance = newBalance
+		account.TotalEarned += amount
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(username, oldBalance, newBalance, transactionType)
+	
+	return e.recordTransaction(&Transaction{
+		To:        username,
+		Amount:    amount,
+		Type:      transactionType,
+		Code:      code,
+		Timestamp: e.now(),
+		Reason:    reason,
+	}), nil
+}
+
+// minBalance is the lowest a wallet may be taken to by debits and
+// payments.
+func (e *EconomyPlugin) minBalance() Money {
+	if e.config.AllowNegativeBalance {
+		return e.config.MinBalance
+	}
+	return 0
+}
+
+func (e *EconomyPlugin) subtractMoney(username string, amount Money, reason string) (string, error) {
+	return e.debit(username, amount, SUBTRACT, ReasonAdmin, reasonOr(reason, "Money subtracted"))
+}
+
+// reasonOr returns reason, clipped to fit the ledger, or fallback when
+// the caller gave none.
+func reasonOr(reason, fallback string) string {
+	if reason = strings.TrimSpace(reason); reason == "" {
+		return fallback
+	}
+	return clipReference(reason)
+}
+
+// debit removes amount from an account and records it under
+// transactionType and code, returning the transaction ID.
+func (e *EconomyPlugin) debit(username string, amount Money, transactionType TransactionType, code ReasonCode, reason string) (string, error) {
+	if amount <= 0 {
+		return "", ErrInvalidAmount
+	}
+	
+	var oldBalance Money
+	err := e.mutateAccounts([]string{username}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if account.spendable()-amount < e.minBalance() {
+			return ErrInsufficientFunds
+		}
+		
+		oldBalance = account.Balance
+		account.Balance -= amount
+		account.TotalSpent += amount
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(username, oldBalance, oldBalance-amount, transactionType)
+	
+	return e.recordTransaction(&Transaction{
+		From:      username,
+		Amount:    amount,
+		Type:      transactionType,
+		Code:      code,
+		Timestamp: e.now(),
+		Reason:    reason,
+	}), nil
+}
+
+func (e *EconomyPlugin) transferMoney(from, to string, amount Money, reason string) (string, error) {
+	id, _, err := e.transfer(from, to, amount, 0, reasonOr(reason, "Money transfer"))
+	return id, err
+}
+
+// transfer moves amount from one account to another, charging the sender
+// an extra fee that is routed to the configured fee account or destroyed.
+// With pending_payments on, whatever would take the recipient past
+// max_balance is queued for them instead, and returned. The ID returned
+// is that of the TRANSFER, or of the PENDING_PAYMENT if it was all queued.
+func (e *EconomyPlugin) transfer(from, to string, amount, fee Money, reason string) (string, Money, error) {
+	if amount <= 0 || fee < 0 {
+		return "", 0, ErrInvalidAmount
+	}
+	if strings.ToLower(from) == strings.ToLower(to) {
+		return "", 0, ErrSelfTransfer
+	}
+	
+	if e.fireTransfer(from, to, amount) {
+		return "", 0, ErrTransferCancelled
+	}
+	
+	usernames := []string{from, to}
+	feeAccount := e.config.TransferFees.Recipient
+	if fee > 0 && feeAccount != "" {
+		usernames = append(usernames, feeAccount)
+	}
+	
+	if err := e.chargeSpend(from, amount+fee); err != nil {
+		return "", 0, err
+	}
+	
+	var fromOld, toOld, feeOld, feeCollected, credited, queued Money
+	var pending PendingPayment
+	err := e.mutateAccounts(usernames, func(accounts []*PlayerAccount) error {
+		fromAccount, toAccount := accounts[0], accounts[1]
+		if err := e.checkRules(&ruleEnv{
+			amount:      amount,
+			fee:         fee,
+			reason:      reason,
+			from:        fromAccount,
+			to:          toAccount,
+			fromBalance: fromAccount.Balance,
+			toBalance:   toAccount.Balance,
+			now:         e.now(),
+		}); err != nil {
+			return err
+		}
+		if fromAccount.spendable()-amount-fee < e.minBalance() {
+			return ErrInsufficientFunds
+		}
+		
+		credited = amount
+		if toAccount.Balance+amount > e.config.MaxBalance {
+			if !e.config.Pending.Enabled {
+				return ErrMaxBalanceExceeded
+			}
+			credited = clampZero(e.config.MaxBalance - toAccount.Balance)
+		}
+		
+		fromOld, toOld = fromAccount.Balance, toAccount.Balance
+		fromAccount.Balance -= amount + fee
+		fromAccount.TotalSpent += amount + fee
+		toAccount.Balance += credited
+		toAccount.TotalEarned += credited
+		queued = amount - credited
+		
+		if len(accounts) > 2 {
+			collector := accounts[2]
+			feeOld = collector.Balance
+			feeCollected = fee
+			if room := e.config.MaxBalance - collector.Balance; room < feeCollected {
+				feeCollected = room
+			}
+			if feeCollected < 0 {
+				feeCollected = 0
+			}
+			collector.Balance += feeCollected
+			collector.TotalEarned += feeCollected
+		}
+		return nil
+	})
+	if err != nil {
+		e.refundSpend(from, amount+fee)
+		return "", 0, err
+	}
+	if queued > 0 {
+		pending = e.queuePendingPayment(from, to, queued, reason)
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(from, fromOld, fromOld-amount-fee, TRANSFER)
+	if credited > 0 {
+		e.fireBalanceChange(to, toOld, toOld+credited, TRANSFER)
+	}
+	if feeCollected > 0 {
+		e.fireBalanceChange(feeAccount, feeOld, feeOld+feeCollected, FEE)
+	}
+	
+	var id string
+	if credited > 0 {
+		id = e.recordTransaction(&Transaction{
+			From:      from,
+			To:        to,
+			Amount:    credited,
+			Type:      TRANSFER,
+			Timestamp: e.now(),
+			Reason:    reason,
+		})
+	}
+	if queued > 0 {
+		queuedID := e.recordTransaction(&Transaction{
+			From:      from,
+			To:        pendingRef(pending.ID),
+			Amount:    queued,
+			Type:      PENDING_PAYMENT,
+			Timestamp: e.now(),
+			Reason:    "Held until " + to + " has room",
+		})
+		if id == "" {
+			id = queuedID
+		}
+	}
+	
+	if fee > 0 {
+		feeTransaction := &Transaction{
+			From:      from,
+			Amount:    fee,
+			Type:      FEE,
+			Timestamp: e.now(),
+			Reason:    "Transfer fee",
+		}
+		if feeCollected > 0 {
+			feeTransaction.To = feeAccount
+		}
+		feeTransaction.Postings = e.postings(Posting{Account: from, Amount: -fee}, Posting{Account: feeAccount, Amount: feeCollected})
+		e.recordTransaction(feeTransaction)
+	}
+	
+	return id, queued, nil
+}
+
+// invalidateTopPlayers marks the cached leaderboard stale. Ranking is
+// deferred to the next getTopPlayers call so transactions never pay for it.
+func (e *EconomyPlugin) invalidateTopPlayers() {
+	e.mutex.Lock()
+	e.dataVersion++
+	e.mutex.Unlock()
+}
+
+func (e *EconomyPlugin) getTopPlayers() []*PlayerAccount {
+	return e.getRanking(TopBalance)
+}
+
+func (e *EconomyPlugin) getRanking(category LeaderboardCategory) []*PlayerAccount {
+	e.mutex.RLock()
+	cached, stale := e.rankings[category], e.rankings[category].version != e.dataVersion
+	e.mutex.RUnlock()
+	
+	players := cached.players
+	if players == nil || (stale && e.leaderboardPolicy() == leaderboardOnChange) {
+		players = e.updateRanking(category)
+	}
+	
+	return players
+}
+
+func (e *EconomyPlugin) updateRanking(category LeaderboardCategory) []*PlayerAccount {
+	start := time.Now()
+	defer func() { e.metrics.topRecompute.observe(time.Since(start)) }()
+	
+	e.mutex.RLock()
+	version := e.dataVersion
+	e.mutex.RUnlock()
+	
+	snapshots := e.snapshotAccounts()
+	
+	players := make([]*PlayerAccount, 0, len(snapshots))
+	for i := range snapshots {
+		if e.ranked(&snapshots[i], category) {
+			players = append(players, &snapshots[i])
+		}
+	}
+	
+	sort.Slice(players, func(i, j int) bool {
+		return category.before(players[i], players[j])
+	})
+	
+	limit := e.config.TopPlayersLimit
+	if limit <= 0 || len(players) < limit {
+		limit = len(players)
+	}
+	players = players[:limit]
+	
+	e.mutex.Lock()
+	if e.rankings == nil {
+		e.rankings = make(map[LeaderboardCategory]ranking)
+	}
+	if cached, exists := e.rankings[category]; !exists || version >= cached.version {
+		e.rankings[category] = ranking{players: players, version: version}
+	}
+	e.mutex.Unlock()
+	
+	return players
+}
+
+// recordTransaction writes transaction to the ledger, giving it an ID if
+// it has none, and returns the ID.
+func (e *EconomyPlugin) recordTransaction(transaction *Transaction) string {
+	if transaction.Timestamp.IsZero() {
+		transaction.Timestamp = e.now()
+	}
+	if transaction.ID == "" {
+		transaction.ID = newTransactionID(transaction.Timestamp)
+	}
+	if transaction.Code == "" {
+		transaction.Code = transaction.Type.reasonCode()
+	}
+	if e.config.DoubleEntry && transaction.Postings == nil {
+		transaction.Postings = derivePostings(transaction)
+	}
+	if e.ledger != nil {
+		if err := e.ledger.AppendTransaction(transaction); err != nil {
+			e.logger.Error("Failed to record transaction", "type", transaction.Type.String(), "error", err)
+			e.countStorageError("ledger")
+		}
+	}
+	e.countTransaction(transaction.Type)
+	
+	if e.config.EnableLogging {
+		e.logTransaction(transaction)
+	}
+	
+	e.fireTransaction(transaction)
+	return transaction.ID
+}
+
+func (e *EconomyPlugin) registerCommands() {
+	e.logger.Debug("Registering commands")
+	
+	commands := []*Command{
+		{Name: "balance", Aliases: []string{"bal"}, Usage: "/balance [player|private <on|off>]", Permission: "economy.command.balance", Handler: e.balanceCommand, Complete: e.completeBalance},
+		{Name: "money", Usage: "/money <give|take|set> <player> <amount>", Handler: e.moneyCommand, Complete: e.completeMoney},
+		{Name: "pay", Usage: "/pay <player> <amount>", Permission: "economy.command.pay", PlayerOnly: true, Handler: e.payCommand, Complete: e.completePay},
+		{Name: "economy", Aliases: []string{"eco"}, Usage: "/economy <reload|save|stats|import|rollback|backup|restore|alerts|verify|giveall|reset|resetall|prune|flow|virtual|audit|apikey|rules|search|merge|migrate|reconcile|create|hide>", Handler: e.economyCommand},
+		{Name: "top", Aliases: []string{"baltop"}, Usage: "/top [earned|spent] [page|history <player> [days]]", Permission: "economy.command.top", Handler: e.topCommand},
+		{Name: "stats", Usage: "/stats [player]", Permission: "economy.command.stats", Handler: e.playerStatsCommand},
+		{Name: "transactions", Usage: "/transactions <player> [page]", Permission: "economy.command.transactions", Handler: e.transactionsCommand},
+		{Name: "receipt", Usage: "/receipt <id>", Permission: "economy.command.receipt", Handler: e.receiptCommand},
+		{Name: "bank", Usage: "/bank <deposit|withdraw|balance> [amount]", Permission: "economy.command.bank", PlayerOnly: true, Handler: e.bankCommand},
+		{Name: "daily", Usage: "/daily", Permission: "economy.command.daily", PlayerOnly: true, Handler: e.dailyCommand},
+		{Name: "earnings", Usage: "/earnings [player]", Permission: "economy.command.earnings", Handler: e.earningsCommand},
+		{Name: "loan", Usage: "/loan <offer|accept|decline|repay|list> [args]", Permission: "economy.command.loan", PlayerOnly: true, Handler: e.loanCommand},
+		{Name: "escrow", Usage: "/escrow <release|cancel|list> [id]", Permission: "economy.command.escrow", PlayerOnly: true, Handler: e.escrowCommand},
+		{Name: "gift", Usage: "/gift <player> <amount> [message] or /gift <accept|decline> [id] or /gift list", Permission: "economy.command.gift", PlayerOnly: true, Handler: e.giftCommand, Complete: e.completePay},
+		{Name: "pending", Usage: "/pending <list|claim>", Permission: "economy.command.pending", PlayerOnly: true, Handler: e.pendingCommand},
+		{Name: "savings", Usage: "/savings <deposit <amount> [days]|withdraw <id>|list>", Permission: "economy.command.savings", PlayerOnly: true, Handler: e.savingsCommand},
+		{Name: "voucher", Usage: "/voucher <create <amount>|redeem <code>>", Permission: "economy.command.voucher", PlayerOnly: true, Handler: e.voucherCommand},
+		{Name: "account", Usage: "/account <create|invite|deposit|withdraw|log|info|link> <name> [args]", Permission: "economy.command.account", PlayerOnly: true, Handler: e.accountCommand},
+	}
+	
+	dispatcher := newCommandDispatcher(e)
+	for _, cmd := range commands {
+		if err := dispatcher.Register(cmd); err != nil {
+			e.logger.Error("Failed to register command", "command", cmd.Name, "error", err)
+			continue
+		}
+		e.logger.Debug("Registered command", "command", cmd.Name)
+	}
+	
+	e.commands = dispatcher
+}
+
+// Commands returns the dispatcher the plugin's commands are registered
+// with, for wiring into a host server.
+func (e *EconomyPlugin) Commands() *CommandDispatcher {
+	return e.commands
+}
+
+func (e *EconomyPlugin) ExecuteCommand(sender CommandSender, name string, args []string) string {
+	return e.commands.Execute(sender, name, args)
+}
+
+func (e *EconomyPlugin) balanceCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) == 0 && ctx.IsConsole() {
+		return e.message("balance.usage")
+	}
+	if len(args) == 2 && strings.EqualFold(args[0], "private") {
+		return e.balancePrivacyCommand(ctx.withArgs("private", args[1:]))
+	}
+	
+	username := ctx.Name()
+	if len(args) > 0 && !strings.EqualFold(args[0], username) {
+		if !e.hasPermission(ctx.Sender, "economy.command.balance.others") {
+			return e.message("command.no_permission")
+		}
+		resolved, err := e.ResolveName(args[0])
+		if err != nil {
+			return e.describeError(err)
+		}
+		username = resolved
+		if e.balancePrivate(ctx.Sender, username) {
+			return e.message("balance.private", "player", username)
+		}
+	}
+	account := ScopedName(username, e.senderScope(ctx.Sender))
+	balance := e.getBalance(account)
+	
+	result := e.message("balance.show", "player", username, "amount", e.FormatMoney(balance))
+	if !ctx.IsConsole() && strings.EqualFold(username, ctx.Name()) {
+		if uuid, exists := e.GetUUID(username); exists {
+			for _, notification := range e.takeNotifications(uuid) {
+				result = notification + "\n" + result
+			}
+		}
+	}
+	if balance < 0 {
+		result += "\n" + e.message("balance.in_debt", "amount", e.FormatMoney(-balance))
+	}
+	if held := e.getHeld(account); held > 0 {
+		result += "\n" + e.message("balance.held", "amount", e.FormatMoney(held))
+	}
+	return result
+}
+
+func (e *EconomyPlugin) completeBalance(ctx *CommandContext) []string {
+	if len(ctx.Args) != 1 || !e.hasPermission(ctx.Sender, "economy.command.balance.others") {
+		return nil
+	}
+	return e.completePlayers(ctx)
+}
+
+func (e *EconomyPlugin) moneyCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) < 3 {
+		return e.message("money.usage")
+	}
+	
+	action := strings.ToLower(args[0])
+	if !e.hasPermission(ctx.Sender, "economy.admin."+action) {
+		return e.message("command.no_permission")
+	}
+	
+	amount, err := e.parseAmount(args[2])
+	if err != nil {
+		return e.message("error.invalid_amount")
+	}
+	username, err := e.ResolveName(args[1])
+	if err != nil {
+		return e.describeError(err)
+	}
+	username = ScopedName(username, e.senderScope(ctx.Sender))
+	
+	before := e.peekBalance(username)
+	switch action {
+	case "give":
+		given, id, err := e.grant(username, amount, "Given by "+ctx.Name())
+		if err != nil {
+			return e.message("money.give_failed", "error", e.describeError(err))
+		}
+		e.notifyAdminAction("give", ctx.Name(), username, given)
+		e.auditBalance(ctx, "give", username, before, "")
+		result := e.message("money.give", "amount", e.FormatMoney(given), "player", username)
+		if given < amount {
+			result += "\n" + e.message("money.give_capped", "amount", e.FormatMoney(amount))
+		}
+		return result + "\n" + e.message("receipt.issued", "id", id)
+		
+	case "take":
+		id, err := e.subtractMoney(username, amount, "Taken by "+ctx.Name())
+		if err != nil {
+			return e.message("money.take_failed", "error", e.describeError(err))
+		}
+		e.notifyAdminAction("take", ctx.Name(), username, amount)
+		e.auditBalance(ctx, "take", username, before, "")
+		return e.message("money.take", "amount", e.FormatMoney(amount), "player", username) + "\n" + e.message("receipt.issued", "id", id)
+		
+	case "set":
+		id, err := e.setBalance(username, amount, "Set by "+ctx.Name())
+		if err != nil {
+			return e.message("money.set_failed", "error", e.describeError(err))
+		}
+		e.notifyAdminAction("set", ctx.Name(), username, amount)
+		e.auditBalance(ctx, "set", username, before, "")
+		return e.message("money.set", "player", username, "amount", e.FormatMoney(amount)) + "\n" + e.message("receipt.issued", "id", id)
+		
+	default:
+		return e.message("money.invalid_action")
+	}
+}
+
+func (e *EconomyPlugin) completeMoney(ctx *CommandContext) []string {
+	switch len(ctx.Args) {
+	case 1:
+		completions := make([]string, 0, 3)
+		for _, action := range []string{"give", "take", "set"} {
+			if strings.HasPrefix(action, strings.ToLower(ctx.Args[0])) && e.hasPermission(ctx.Sender, "economy.admin."+action) {
+				completions = append(completions, action)
+			}
+		}
+		return completions
+	case 2:
+		return e.completePlayers(ctx)
+	default:
+		return nil
+	}
+}
+
+func (e *EconomyPlugin) completePay(ctx *CommandContext) []string {
+	if len(ctx.Args) != 1 {
+		return nil
+	}
+	return e.completePlayers(ctx)
+}
+
+func (e *EconomyPlugin) payCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) < 2 {
+		return e.message("pay.usage")
+	}
+	
+	scope := e.senderScope(ctx.Sender)
+	sender := ScopedName(ctx.Name(), scope)
+	amount, err := e.parseAmount(args[1])
+	if err != nil {
+		return e.message("error.invalid_amount")
+	}
+	recipient, err := e.ResolveName(args[0])
+	if err != nil {
+		return e.message("pay.failed", "error", e.describeError(err))
+	}
+	account := ScopedName(recipient, scope)
+	
+	if err := e.checkMinTransaction(amount); err != nil {
+		return e.message("pay.failed", "error", e.describeError(err))
+	}
+	if e.config.BlockPayInDebt && e.getBalance(sender) < 0 {
+		return e.message("pay.failed", "error", e.describeError(ErrInDebt))
+	}
+	
+	cancel, err := e.reservePayment(ctx.Sender, amount)
+	if err != nil {
+		return e.message("pay.failed", "error", e.describeError(err))
+	}
+	
+	fee := e.transferFee(amount)
+	id, queued, err := e.transfer(sender, account, amount, fee, "Payment from "+ctx.Name())
+	if err != nil {
+		cancel()
+		return e.message("pay.failed", "error", e.describeError(err))
+	}
+	
+	result := e.message("pay.success", "amount", e.FormatMoney(amount), "player", recipient)
+	if fee > 0 {
+		result = e.message("pay.with_fee", "amount", e.FormatMoney(amount), "player", recipient, "fee", e.FormatMoney(fee))
+	}
+	if queued > 0 {
+		result += "\n" + e.message("pay.queued", "amount", e.FormatMoney(queued), "player", recipient)
+	}
+	
+	return result + "\n" + e.message("receipt.issued", "id", id)
+}
+
+func (e *EconomyPlugin) economyCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	subcommand := "info"
+	if len(args) > 0 {
+		subcommand = strings.ToLower(args[0])
+	}
+	if !e.hasPermission(ctx.Sender, "economy.admin."+subcommand) {
+		return e.message("command.no_permission")
+	}
+	
+	if len(args) == 0 {
+		return e.message("economy.info", "version", e.version, "players", strconv.Itoa(e.accountCount()))
+	}
+	
+	switch subcommand {
+	case "reload":
+		changes, err := e.reloadConfig()
+		if err != nil {
+			return e.message("economy.reload_failed", "error", err.Error())
+		}
+		e.loadMessages()
+		e.openDoubleEntry()
+		result := e.reloadReport(changes)
+		if err := e.loadRules(); err != nil {
+			result += "\n" + e.message("rules.invalid", "error", err.Error())
+		}
+		return result
+		
+	case "save":
+		if _, err := e.savePlayerData(); err != nil {
+			return e.describeError(err)
+		}
+		return e.message("economy.saved")
+		
+	case "import":
+		return e.importCommand(ctx.withArgs("import", args[1:]))
+		
+	case "rollback":
+		return e.rollbackCommand(ctx.withArgs("rollback", args[1:]))
+		
+	case "backup":
+		return e.backupCommand(ctx.withArgs("backup", args[1:]))
+		
+	case "restore":
+		return e.restoreCommand(ctx.withArgs("restore", args[1:]))
+		
+	case "alerts":
+		return e.alertsCommand(ctx.withArgs("alerts", args[1:]))
+		
+	case "verify":
+		return e.verifyCommand(ctx.withArgs("verify", args[1:]))
+		
+	case "giveall":
+		return e.giveAllCommand(ctx.withArgs("giveall", args[1:]))
+		
+	case "reset":
+		return e.resetCommand(ctx.withArgs("reset", args[1:]))
+		
+	case "resetall":
+		return e.resetAllCommand(ctx.withArgs("resetall", args[1:]))
+		
+	case "prune":
+		return e.pruneCommand(ctx.withArgs("prune", args[1:]))
+		
+	case "stats":
+		return e.statsReport()
+		
+	case "flow":
+		return e.flowCommand(ctx.withArgs("flow", args[1:]))
+		
+	case "virtual":
+		return e.virtualCommand(ctx.withArgs("virtual", args[1:]))
+		
+	case "audit":
+		return e.auditCommand(ctx.withArgs("audit", args[1:]))
+		
+	case "apikey":
+		return e.apiKeyCommand(ctx.withArgs("apikey", args[1:]))
+		
+	case "rules":
+		return e.rulesCommand(ctx.withArgs("rules", args[1:]))
+		
+	case "search":
+		return e.searchCommand(ctx.withArgs("search", args[1:]))
+		
+	case "merge":
+		return e.mergeCommand(ctx.withArgs("merge", args[1:]))
+		
+	case "migrate":
+		return e.migrateCommand(ctx.withArgs("migrate", args[1:]))
+		
+	case "reconcile":
+		return e.reconcileCommand(ctx.withArgs("reconcile", args[1:]))
+		
+	case "create":
+		return e.createCommand(ctx.withArgs("create", args[1:]))
+		
+	case "hide":
+		return e.hideCommand(ctx.withArgs("hide", args[1:]))
+		
+	default:
+		return e.message("economy.invalid")
+	}
+}
+
+func (e *EconomyPlugin) topCommand(ctx *CommandContext) string {
+	page := 1
+	category := TopBalance
+	if len(ctx.Args) > 0 {
+		if parsed, ok := parseLeaderboardCategory(ctx.Args[0]); ok {
+			category = parsed
+			ctx = ctx.withArgs("top", ctx.Args[1:])
+		}
+	}
+	if len(ctx.Args) > 0 {
+		if strings.EqualFold(ctx.Args[0], "refresh") {
+			if !e.hasPermission(ctx.Sender, "economy.command.top.refresh") {
+				return e.message("command.no_permission")
+			}
+			e.RefreshLeaderboard()
+			return e.message("top.refreshed")
+		}
+		if strings.EqualFold(ctx.Args[0], "history") {
+			return e.balanceHistoryCommand(ctx.withArgs("history", ctx.Args[1:]))
+		}
+		
+		parsed, err := strconv.Atoi(ctx.Args[0])
+		if err != nil || parsed < 1 {
+			return e.message("top.bad_page")
+		}
+		page = parsed
+	}
+	
+	topPlayers, firstRank, pages := e.topPage(category, page)
+	if len(topPlayers) == 0 {
+		return e.message("top.empty")
+	}
+	if page > pages {
+		page = pages
+	}
+	
+	header := "top.header"
+	if category != TopBalance {
+		header += "_" + string(category)
+	}
+	result := e.message(header, "page", strconv.Itoa(page), "pages", strconv.Itoa(pages)) + "\n"
+	for i, player := range topPlayers {
+		balance := e.FormatMoney(category.value(player))
+		if e.config.Format.AbbreviateTop {
+			balance = e.FormatMoneyShort(category.value(player))
+		}
+		result += e.message("top.entry", "rank", strconv.Itoa(firstRank+i), "player", player.Username, "amount", balance) + "\n"
+	}
+	
+	return result
+}
+
+func (e *EconomyPlugin) transactionsCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) == 0 {
+		return e.message("transactions.usage")
+	}
+	
+	username := args[0]
+	if e.balancePrivate(ctx.Sender, username) {
+		return e.message("balance.private", "player", username)
+	}
+	page := 1
+	if len(args) > 1 {
+		parsed, err := strconv.Atoi(args[1])
+		if err != nil || parsed < 1 {
+			return e.message("transactions.bad_page")
+		}
+		page = parsed
+	}
+	
+	const pageSize = 10
+	transactions := e.GetTransactions(username, TransactionFilter{
+		Offset: (page - 1) * pageSize,
+		Limit:  pageSize + 1,
+	})
+	
+	if len(transactions) == 0 {
+		return e.message("transactions.empty", "player", username, "page", strconv.Itoa(page))
+	}
+	
+	hasMore := len(transactions) > pageSize
+	if hasMore {
+		transactions = transactions[:pageSize]
+	}
+	
+	result := e.message("transactions.header", "player", username, "page", strconv.Itoa(page)) + "\n"
+	for _, transaction := range transactions {
+		result += e.message("transactions.entry",
+			"time", transaction.Timestamp.Format("2006-01-02 15:04"),
+			"type", transaction.Type.String(),
+			"from", transaction.From,
+			"to", transaction.To,
+			"amount", e.FormatMoney(transaction.Amount),
+			"reason", transaction.Reason,
+			"id", transaction.ID) + "\n"
+	}
+	
+	if hasMore {
+		result += e.message("transactions.next_page", "player", username, "next", strconv.Itoa(page+1)) + "\n"
+	}
+	
+	return result
+}
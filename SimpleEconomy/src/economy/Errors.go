@@ -0,0 +1,261 @@
+package economy
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInsufficientFunds  = errors.New("economy: insufficient funds")
+	ErrInDebt             = errors.New("economy: account is in debt")
+	ErrMaxBalanceExceeded = errors.New("economy: max balance exceeded")
+	ErrInvalidAmount      = errors.New("economy: invalid amount")
+	ErrBelowMinimum       = errors.New("economy: amount below the minimum transaction")
+	ErrAccountNotFound    = errors.New("economy: account not found")
+	ErrSelfTransfer       = errors.New("economy: cannot transfer to the same account")
+	ErrTransferCancelled  = errors.New("economy: transfer cancelled by listener")
+	ErrPurchaseCancelled  = errors.New("economy: purchase cancelled by listener")
+	ErrStorage            = errors.New("economy: storage failure")
+	ErrShuttingDown       = errors.New("economy: shutting down")
+	ErrNoPlayerProvider   = errors.New("economy: no online player provider registered")
+	ErrBankLimitExceeded  = errors.New("economy: bank limit exceeded")
+	
+	ErrPaymentTooLarge       = errors.New("economy: payment exceeds the per-payment limit")
+	ErrTransferLimitExceeded = errors.New("economy: transfer limit exceeded")
+	ErrPaymentCooldown       = errors.New("economy: payment cooldown active")
+	
+	ErrInvalidAccountName    = errors.New("economy: invalid account name")
+	ErrAccountHasLoans       = errors.New("economy: account has open loans")
+	ErrAccountExists         = errors.New("economy: account already exists")
+	ErrSharedAccountExists   = errors.New("economy: shared account already exists")
+	ErrSharedAccountNotFound = errors.New("economy: shared account not found")
+	ErrNotAuthorized         = errors.New("economy: not authorized")
+	ErrWithdrawLimitExceeded = errors.New("economy: withdraw limit exceeded")
+	
+	ErrBackupNotFound = errors.New("economy: backup not found")
+	
+	ErrRewardsDisabled = errors.New("economy: daily rewards are disabled")
+	ErrRewardClaimed   = errors.New("economy: daily reward already claimed")
+	
+	ErrInvalidLeaderboard = errors.New("economy: unknown leaderboard")
+	
+	ErrInvalidReasonCode = errors.New("economy: unknown reason code")
+	ErrInvalidJob        = errors.New("economy: invalid job name")
+	ErrEarningCapReached = errors.New("economy: job earning cap reached")
+	
+	ErrLoansDisabled    = errors.New("economy: loans are disabled")
+	ErrLoanNotFound     = errors.New("economy: loan not found")
+	ErrLoanExists       = errors.New("economy: loan already offered")
+	ErrInvalidLoanTerms = errors.New("economy: invalid loan terms")
+	
+	ErrEscrowNotFound = errors.New("economy: escrow not found")
+	ErrHoldNotFound   = errors.New("economy: hold not found")
+	
+	ErrVouchersDisabled = errors.New("economy: vouchers are disabled")
+	ErrVoucherInvalid   = errors.New("economy: invalid voucher code")
+	ErrVoucherRedeemed  = errors.New("economy: voucher already redeemed")
+	ErrVoucherExpired   = errors.New("economy: voucher expired")
+	
+	ErrInvalidAPIKey     = errors.New("economy: invalid API key")
+	ErrAPIKeyScope       = errors.New("economy: API key lacks the required scope")
+	ErrRateLimited       = errors.New("economy: API rate limit exceeded")
+	ErrInvalidAPIKeyName = errors.New("economy: invalid API key name")
+	ErrInvalidAPIScope   = errors.New("economy: invalid API key scope")
+	ErrAPIKeyExists      = errors.New("economy: API key already exists")
+	ErrAPIKeyNotFound    = errors.New("economy: API key not found")
+	
+	ErrBlockedByRule = errors.New("economy: transfer blocked by rule")
+	
+	ErrInvalidIdempotencyKey = errors.New("economy: idempotency key is too long")
+	ErrIdempotencyKeyReused  = errors.New("economy: idempotency key was used for a different request")
+	
+	ErrDenominationsDisabled = errors.New("economy: item denominations are disabled")
+	ErrUnknownDenomination   = errors.New("economy: item is not a denomination")
+	ErrNoDenomination        = errors.New("economy: amount cannot be made of denominations")
+	
+	ErrUnknownBackend          = errors.New("economy: unknown storage backend")
+	ErrWrongBackend            = errors.New("economy: wrong storage backend")
+	ErrMigrationTargetNotEmpty = errors.New("economy: migration target already holds data")
+	ErrMigrationMismatch       = errors.New("economy: migrated data does not match")
+	ErrSchemaTooNew            = errors.New("economy: stored data is from a newer version")
+	
+	ErrDoubleEntryDisabled = errors.New("economy: double-entry mode is off")
+	ErrNoOpeningBalances   = errors.New("economy: no opening balances have been posted")
+	ErrAmbiguousName       = errors.New("economy: name matches several players")
+	ErrSupplyCapReached    = errors.New("economy: money supply cap reached")
+	ErrInvalidLink         = errors.New("economy: accounts cannot be linked")
+	ErrLinkExists          = errors.New("economy: account already has a parent")
+	ErrLinkNotFound        = errors.New("economy: account link not found")
+	ErrLinkLimit           = errors.New("economy: too many linked accounts")
+	ErrSpendLimitExceeded  = errors.New("economy: daily spend limit exceeded")
+	ErrTransactionNotFound = errors.New("economy: transaction not found")
+	ErrNotRefundable       = errors.New("economy: transaction cannot be refunded")
+	ErrAlreadyRefunded     = errors.New("economy: transaction already refunded")
+	ErrGiftNotFound        = errors.New("economy: gift not found")
+	ErrSavingsDisabled     = errors.New("economy: savings are disabled")
+	ErrSavingsNotFound     = errors.New("economy: savings deposit not found")
+	ErrSavingsLimit        = errors.New("economy: too many savings deposits")
+	ErrInvalidSavingsTerm  = errors.New("economy: no savings term of that length")
+	ErrInvalidQuest        = errors.New("economy: invalid quest ID")
+	ErrQuestClaimed        = errors.New("economy: quest reward already claimed")
+	ErrVotesDisabled       = errors.New("economy: vote rewards are disabled")
+	ErrInvalidVote         = errors.New("economy: vote needs a service, player and timestamp")
+	ErrVoteDuplicate       = errors.New("economy: vote already counted")
+)
+
+// describeError turns an operation error into a message fit for players.
+func (e *EconomyPlugin) describeError(err error) string {
+	var limitErr *TransferLimitError
+	if errors.As(err, &limitErr) {
+		return e.describeTransferLimit(limitErr)
+	}
+	var unknown *UnknownAccountError
+	if errors.As(err, &unknown) {
+		if unknown.Suggestion != "" {
+			return e.message("error.unknown_account_suggest", "player", unknown.Name, "suggestion", unknown.Suggestion)
+		}
+		return e.message("error.unknown_account", "player", unknown.Name)
+	}
+	var ambiguous *AmbiguousNameError
+	if errors.As(err, &ambiguous) {
+		return e.message("error.ambiguous_name", "player", ambiguous.Name, "matches", strings.Join(ambiguous.Matches, ", "))
+	}
+	var violation *RuleViolation
+	if errors.As(err, &violation) {
+		if violation.Message != "" {
+			return violation.Message
+		}
+		return e.message("error.blocked_by_rule", "rule", violation.Rule)
+	}
+	
+	switch {
+	case errors.Is(err, ErrInsufficientFunds):
+		return e.message("error.insufficient_funds")
+	case errors.Is(err, ErrInDebt):
+		return e.message("error.in_debt")
+	case errors.Is(err, ErrMaxBalanceExceeded):
+		return e.message("error.max_balance", "max", e.FormatMoney(e.config.MaxBalance))
+	case errors.Is(err, ErrInvalidAmount):
+		return e.message("error.non_positive_amount")
+	case errors.Is(err, ErrBelowMinimum):
+		return e.message("error.below_minimum", "min", e.FormatMoney(e.config.MinTransaction))
+	case errors.Is(err, ErrAccountNotFound):
+		return e.message("error.account_not_found")
+	case errors.Is(err, ErrSelfTransfer):
+		return e.message("error.self_transfer")
+	case errors.Is(err, ErrTransferCancelled):
+		return e.message("error.transfer_cancelled")
+	case errors.Is(err, ErrPurchaseCancelled):
+		return e.message("error.purchase_cancelled")
+	case errors.Is(err, ErrBankLimitExceeded):
+		return e.message("error.bank_limit", "max", e.FormatMoney(e.config.BankMaxBalance))
+	case errors.Is(err, ErrInvalidAccountName):
+		return e.message("error.invalid_account_name")
+	case errors.Is(err, ErrAccountHasLoans):
+		return e.message("error.account_has_loans")
+	case errors.Is(err, ErrAccountExists):
+		return e.message("error.account_exists")
+	case errors.Is(err, ErrSharedAccountExists):
+		return e.message("error.shared_account_exists")
+	case errors.Is(err, ErrSharedAccountNotFound):
+		return e.message("error.shared_account_missing")
+	case errors.Is(err, ErrNotAuthorized):
+		return e.message("error.not_authorized")
+	case errors.Is(err, ErrWithdrawLimitExceeded):
+		return e.message("error.withdraw_limit")
+	case errors.Is(err, ErrBackupNotFound):
+		return e.message("error.backup_not_found")
+	case errors.Is(err, ErrRewardsDisabled):
+		return e.message("error.rewards_disabled")
+	case errors.Is(err, ErrRewardClaimed):
+		return e.message("error.reward_claimed")
+	case errors.Is(err, ErrInvalidReasonCode):
+		return e.message("error.invalid_reason_code")
+	case errors.Is(err, ErrInvalidJob):
+		return e.message("error.invalid_job")
+	case errors.Is(err, ErrEarningCapReached):
+		return e.message("error.earning_cap")
+	case errors.Is(err, ErrLoansDisabled):
+		return e.message("error.loans_disabled")
+	case errors.Is(err, ErrLoanNotFound):
+		return e.message("error.loan_not_found")
+	case errors.Is(err, ErrLoanExists):
+		return e.message("error.loan_exists")
+	case errors.Is(err, ErrInvalidLoanTerms):
+		return e.message("error.invalid_loan_terms", "interest", strconv.FormatFloat(e.config.Loans.MaxInterest, 'f', -1, 64),
+			"days", strconv.Itoa(e.config.Loans.MaxDays))
+	case errors.Is(err, ErrEscrowNotFound):
+		return e.message("error.escrow_not_found")
+	case errors.Is(err, ErrHoldNotFound):
+		return e.message("error.hold_not_found")
+	case errors.Is(err, ErrVouchersDisabled):
+		return e.message("error.vouchers_disabled")
+	case errors.Is(err, ErrVoucherInvalid):
+		return e.message("error.voucher_invalid")
+	case errors.Is(err, ErrVoucherRedeemed):
+		return e.message("error.voucher_redeemed")
+	case errors.Is(err, ErrVoucherExpired):
+		return e.message("error.voucher_expired")
+	case errors.Is(err, ErrInvalidAPIKeyName):
+		return e.message("error.invalid_api_key_name")
+	case errors.Is(err, ErrInvalidAPIScope):
+		return e.message("error.invalid_api_scope")
+	case errors.Is(err, ErrAPIKeyExists):
+		return e.message("error.api_key_exists")
+	case errors.Is(err, ErrAPIKeyNotFound):
+		return e.message("error.api_key_not_found")
+	case errors.Is(err, ErrDenominationsDisabled):
+		return e.message("error.denominations_disabled")
+	case errors.Is(err, ErrUnknownDenomination):
+		return e.message("error.unknown_denomination")
+	case errors.Is(err, ErrNoDenomination):
+		return e.message("error.no_denomination")
+	case errors.Is(err, ErrInvalidLink):
+		return e.message("error.invalid_link")
+	case errors.Is(err, ErrLinkExists):
+		return e.message("error.link_exists")
+	case errors.Is(err, ErrLinkNotFound):
+		return e.message("error.link_not_found")
+	case errors.Is(err, ErrLinkLimit):
+		return e.message("error.link_limit", "max", strconv.Itoa(e.config.Links.MaxChildren))
+	case errors.Is(err, ErrSpendLimitExceeded):
+		return e.message("error.spend_limit")
+	case errors.Is(err, ErrTransactionNotFound):
+		return e.message("error.transaction_not_found")
+	case errors.Is(err, ErrNotRefundable):
+		return e.message("error.not_refundable")
+	case errors.Is(err, ErrAlreadyRefunded):
+		return e.message("error.already_refunded")
+	case errors.Is(err, ErrGiftNotFound):
+		return e.message("error.gift_not_found")
+	case errors.Is(err, ErrSavingsDisabled):
+		return e.message("error.savings_disabled")
+	case errors.Is(err, ErrSavingsNotFound):
+		return e.message("error.savings_not_found")
+	case errors.Is(err, ErrSavingsLimit):
+		return e.message("error.savings_limit", "max", strconv.Itoa(e.config.Savings.MaxDeposits))
+	case errors.Is(err, ErrInvalidSavingsTerm):
+		return e.message("error.savings_term")
+	case errors.Is(err, ErrInvalidQuest):
+		return e.message("error.invalid_quest")
+	case errors.Is(err, ErrQuestClaimed):
+		return e.message("error.quest_claimed")
+	case errors.Is(err, ErrVotesDisabled):
+		return e.message("error.votes_disabled")
+	case errors.Is(err, ErrInvalidVote):
+		return e.message("error.invalid_vote")
+	case errors.Is(err, ErrVoteDuplicate):
+		return e.message("error.vote_duplicate")
+	case errors.Is(err, ErrSupplyCapReached):
+		return e.message("error.supply_cap", "max", e.FormatMoney(e.config.SupplyCap.Max))
+	case errors.Is(err, ErrStorage):
+		return e.message("error.storage")
+	case errors.Is(err, ErrShuttingDown):
+		return e.message("error.shutting_down")
+	case errors.Is(err, ErrNoPlayerProvider):
+		return e.message("error.no_player_provider")
+	default:
+		return err.Error()
+	}
+}
@@ -0,0 +1,347 @@
+package economy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type EscrowConfig struct {
+	DefaultExpiryMinutes int `json:"default_expiry_minutes"`
+}
+
+// Escrow is money taken out of a payer's wallet and held until it is
+// released to the payee or cancelled back to the payer. Escrows that are
+// neither by Expires are refunded.
+type Escrow struct {
+	ID      int       `json:"id"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Amount  Money     `json:"amount"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+const escrowCheckInterval = time.Minute
+
+// escrowBook holds every open Escrow. settling marks escrows being
+// released or cancelled. Its mutex is never held while accounts are
+// locked.
+type escrowBook struct {
+	mutex    sync.Mutex
+	escrows  map[int]*Escrow
+	settling map[int]bool
+	nextID   int
+}
+
+func escrowRef(id int) string {
+	return "escrow#" + strconv.Itoa(id)
+}
+
+func (e *EconomyPlugin) escrowsPath() string {
+	return filepath.Join(e.dataFolder, "escrows.json")
+}
+
+func (e *EconomyPlugin) loadEscrows() {
+	e.escrows.mutex.Lock()
+	defer e.escrows.mutex.Unlock()
+	
+	e.escrows.escrows = make(map[int]*Escrow)
+	e.escrows.settling = make(map[int]bool)
+	e.escrows.nextID = 1
+	
+	data, err := ioutil.ReadFile(e.escrowsPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Error("Failed to read escrows", "error", err)
+		return
+	}
+	
+	if err := json.Unmarshal(data, &e.escrows.escrows); err != nil {
+		e.logger.Error("Failed to parse escrows", "error", err)
+	}
+	for id := range e.escrows.escrows {
+		if id >= e.escrows.nextID {
+			e.escrows.nextID = id + 1
+		}
+	}
+}
+
+// saveEscrows must be called with e.escrows.mutex held.
+func (e *EconomyPlugin) saveEscrows() {
+	data, err := json.MarshalIndent(e.escrows.escrows, "", "  ")
+	if err != nil {
+		e.logger.Error("Failed to marshal escrows", "error", err)
+		return
+	}
+	
+	if err := writeFileAtomic(e.escrowsPath(), data, 0644); err != nil {
+		e.logger.Error("Failed to write escrows", "error", err)
+		e.countStorageError("escrow")
+	}
+}
+
+// CreateEscrow moves amount out of from's wallet and holds it for to. A
+// zero expiry uses escrow.default_expiry_minutes.
+func (e *EconomyPlugin) CreateEscrow(from, to string, amount Money, expiry time.Duration) (Escrow, error) {
+	if amount <= 0 || expiry < 0 {
+		return Escrow{}, ErrInvalidAmount
+	}
+	if strings.EqualFold(from, to) {
+		return Escrow{}, ErrSelfTransfer
+	}
+	if !e.hasAccount(from) || !e.hasAccount(to) {
+		return Escrow{}, ErrAccountNotFound
+	}
+	if expiry == 0 {
+		expiry = time.Duration(e.config.Escrow.DefaultExpiryMinutes) * time.Minute
+	}
+	
+	var oldBalance Money
+	err := e.mutateAccounts([]string{from}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if account.spendable() < amount {
+			return ErrInsufficientFunds
+		}
+		
+		oldBalance = account.Balance
+		account.Balance -= amount
+		return nil
+	})
+	if err != nil {
+		return Escrow{}, err
+	}
+	
+	e.escrows.mutex.Lock()
+	now := e.now()
+	escrow := &Escrow{
+		ID:      e.escrows.nextID,
+		From:    from,
+		To:      to,
+		Amount:  amount,
+		Created: now,
+		Expires: now.Add(expiry),
+	}
+	e.escrows.nextID++
+	e.escrows.escrows[escrow.ID] = escrow
+	e.saveEscrows()
+	created := *escrow
+	e.escrows.mutex.Unlock()
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(from, oldBalance, oldBalance-amount, ESCROW_HOLD)
+	e.recordTransaction(&Transaction{
+		From:      from,
+		To:        escrowRef(created.ID),
+		Amount:    amount,
+		Type:      ESCROW_HOLD,
+		Timestamp: e.now(),
+		Reason:    "Held in escrow for " + to,
+	})
+	
+	return created, nil
+}
+
+// ReleaseEscrow pays a held amount out to its payee.
+func (e *EconomyPlugin) ReleaseEscrow(id int) (Escrow, error) {
+	return e.settleEscrow(id, true)
+}
+
+// CancelEscrow returns a held amount to its payer.
+func (e *EconomyPlugin) CancelEscrow(id int) (Escrow, error) {
+	return e.settleEscrow(id, false)
+}
+
+func (e *EconomyPlugin) GetEscrow(id int) (Escrow, error) {
+	e.escrows.mutex.Lock()
+	defer e.escrows.mutex.Unlock()
+	
+	escrow, exists := e.escrows.escrows[id]
+	if !exists {
+		return Escrow{}, ErrEscrowNotFound
+	}
+	return *escrow, nil
+}
+
+// GetEscrows returns the open escrows username pays into or is owed,
+// oldest first.
+func (e *EconomyPlugin) GetEscrows(username string) []Escrow {
+	e.escrows.mutex.Lock()
+	defer e.escrows.mutex.Unlock()
+	
+	escrows := make([]Escrow, 0)
+	for _, escrow := range e.escrows.escrows {
+		if strings.EqualFold(escrow.From, username) || strings.EqualFold(escrow.To, username) {
+			escrows = append(escrows, *escrow)
+		}
+	}
+	sort.Slice(escrows, func(i, j int) bool {
+		return escrows[i].ID < escrows[j].ID
+	})
+	
+	return escrows
+}
+
+// settleEscrow closes an escrow, crediting the payee when release is set
+// and the payer otherwise. If the payee cannot take the money without
+// passing max_balance the release fails and the escrow stays open.
+func (e *EconomyPlugin) settleEscrow(id int, release bool) (Escrow, error) {
+	e.escrows.mutex.Lock()
+	open, exists := e.escrows.escrows[id]
+	if !exists || e.escrows.settling[id] {
+		e.escrows.mutex.Unlock()
+		return Escrow{}, ErrEscrowNotFound
+	}
+	escrow := *open
+	e.escrows.settling[id] = true
+	e.escrows.mutex.Unlock()
+	
+	// The payer's account is locked on release too, so the money counts as
+	// spent only once it has actually left escrow.
+	usernames := []string{escrow.From}
+	recipient, transactionType, reason := escrow.From, ESCROW_REFUND, "Escrow refunded"
+	if release {
+		usernames = append(usernames, escrow.To)
+		recipient, transactionType, reason = escrow.To, ESCROW_RELEASE, "Escrow released by "+escrow.From
+	}
+	
+	var oldBalance, credited Money
+	err := e.mutateAccounts(usernames, func(accounts []*PlayerAccount) error {
+		account := accounts[len(accounts)-1]
+		credited = escrow.Amount
+		if account.Balance+credited > e.config.MaxBalance {
+			if release {
+				return ErrMaxBalanceExceeded
+			}
+			// A refund must always close the escrow; anything above
+			// max_balance is lost, as with fees.
+			credited = e.config.MaxBalance - account.Balance
+		}
+		
+		oldBalance = account.Balance
+		account.Balance += credited
+		if release {
+			accounts[0].TotalSpent += credited
+			account.TotalEarned += credited
+		}
+		return nil
+	})
+	
+	e.escrows.mutex.Lock()
+	delete(e.escrows.settling, id)
+	if err == nil {
+		delete(e.escrows.escrows, id)
+		e.saveEscrows()
+	}
+	e.escrows.mutex.Unlock()
+	if err != nil {
+		return Escrow{}, err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(recipient, oldBalance, oldBalance+credited, transactionType)
+	e.recordTransaction(&Transaction{
+		From:      escrowRef(id),
+		To:        recipient,
+		Amount:    credited,
+		Type:      transactionType,
+		Timestamp: e.now(),
+		Reason:    reason,
+	})
+	
+	return escrow, nil
+}
+
+func (e *EconomyPlugin) startEscrowExpiry() {
+	e.runPeriodically(escrowCheckInterval, e.refundExpiredEscrows)
+}
+
+func (e *EconomyPlugin) refundExpiredEscrows() {
+	now := e.now()
+	expired := make([]int, 0)
+	
+	e.escrows.mutex.Lock()
+	for id, escrow := range e.escrows.escrows {
+		if !now.Before(escrow.Expires) {
+			expired = append(expired, id)
+		}
+	}
+	e.escrows.mutex.Unlock()
+	
+	for _, id := range expired {
+		escrow, err := e.CancelEscrow(id)
+		if err != nil {
+			e.logger.Warn("Failed to refund expired escrow", "escrow", id, "error", err)
+			continue
+		}
+		e.logger.Info("Refunded expired escrow", "escrow", id, "player", escrow.From, "amount", escrow.Amount.String())
+	}
+}
+
+// escrowCommand lets the payer release an escrow and the payee cancel
+// one, so neither side can take back money the other is owed.
+func (e *EconomyPlugin) escrowCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) == 0 {
+		return e.message("escrow.usage")
+	}
+	
+	player := ctx.Name()
+	action := strings.ToLower(args[0])
+	
+	switch action {
+	case "release", "cancel":
+		if len(args) < 2 {
+			return e.message("escrow.id_usage", "action", action)
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+		if err != nil {
+			return e.message("escrow.bad_id")
+		}
+		escrow, err := e.GetEscrow(id)
+		if err != nil {
+			return e.message("escrow.failed", "error", e.describeError(err))
+		}
+		
+		if action == "release" {
+			if !strings.EqualFold(escrow.From, player) {
+				return e.message("escrow.failed", "error", e.describeError(ErrNotAuthorized))
+			}
+			if _, err := e.ReleaseEscrow(id); err != nil {
+				return e.message("escrow.failed", "error", e.describeError(err))
+			}
+			return e.message("escrow.released", "id", strconv.Itoa(id), "amount", e.FormatMoney(escrow.Amount), "player", escrow.To)
+		}
+		
+		if !strings.EqualFold(escrow.To, player) {
+			return e.message("escrow.failed", "error", e.describeError(ErrNotAuthorized))
+		}
+		if _, err := e.CancelEscrow(id); err != nil {
+			return e.message("escrow.failed", "error", e.describeError(err))
+		}
+		return e.message("escrow.cancelled", "id", strconv.Itoa(id), "amount", e.FormatMoney(escrow.Amount), "player", escrow.From)
+		
+	case "list":
+		escrows := e.GetEscrows(player)
+		if len(escrows) == 0 {
+			return e.message("escrow.none")
+		}
+		result := e.message("escrow.header") + "\n"
+		for _, escrow := range escrows {
+			result += e.message("escrow.entry", "id", strconv.Itoa(escrow.ID), "from", escrow.From, "to", escrow.To,
+				"amount", e.FormatMoney(escrow.Amount), "expires", escrow.Expires.Format("2006-01-02 15:04")) + "\n"
+		}
+		return result
+		
+	default:
+		return e.message("escrow.invalid")
+	}
+}
@@ -0,0 +1,267 @@
+package economy
+
+import (
+	"sync"
+)
+
+// BalanceChangeEvent is delivered after an account balance has changed.
+type BalanceChangeEvent struct {
+	Username   string
+	OldBalance Money
+	NewBalance Money
+	Type       TransactionType
+}
+
+// TransferEvent is delivered before money moves between two accounts.
+type TransferEvent struct {
+	From   string
+	To     string
+	Amount Money
+}
+
+// AccountCreatedEvent is delivered after a new account has been opened.
+type AccountCreatedEvent struct {
+	Username string
+	UUID     string
+	Balance  Money
+}
+
+// PurchaseEvent is delivered before and after a shop purchase is charged.
+// Seller is empty for purchases from the server.
+type PurchaseEvent struct {
+	Buyer  string
+	Seller string
+	Amount Money
+	Item   string
+}
+
+// RewardGrantedEvent is delivered after a quest or achievement reward has
+// been paid by GrantReward.
+type RewardGrantedEvent struct {
+	Player        string
+	QuestID       string
+	Amount        Money
+	TransactionID string
+}
+
+type eventHooks struct {
+	mutex          sync.RWMutex
+	balanceChange  []func(ev BalanceChangeEvent)
+	transfer       []func(ev TransferEvent) bool
+	accountCreated []func(ev AccountCreatedEvent)
+	fraudAlert     []func(alert FraudAlert)
+	purchase       []func(ev PurchaseEvent) bool
+	purchased      []func(ev PurchaseEvent)
+	rewardGranted  []func(ev RewardGrantedEvent)
+	transaction    map[int]func(transaction Transaction)
+	nextID         int
+}
+
+// OnBalanceChange registers a listener that runs after every successful
+// add, subtract, set or transfer.
+func (e *EconomyPlugin) OnBalanceChange(handler func(ev BalanceChangeEvent)) {
+	e.hooks.mutex.Lock()
+	defer e.hooks.mutex.Unlock()
+	
+	e.hooks.balanceChange = append(e.hooks.balanceChange, handler)
+}
+
+// OnTransfer registers a listener that runs before a transfer is applied.
+// Returning true cancels the transfer.
+func (e *EconomyPlugin) OnTransfer(handler func(ev TransferEvent) (cancel bool)) {
+	e.hooks.mutex.Lock()
+	defer e.hooks.mutex.Unlock()
+	
+	e.hooks.transfer = append(e.hooks.transfer, handler)
+}
+
+// OnAccountCreated registers a listener that runs after an account is
+// created, either for a joining player or for a name seen for the first
+// time.
+func (e *EconomyPlugin) OnAccountCreated(handler func(ev AccountCreatedEvent)) {
+	e.hooks.mutex.Lock()
+	defer e.hooks.mutex.Unlock()
+	
+	e.hooks.accountCreated = append(e.hooks.accountCreated, handler)
+}
+
+// OnFraudAlert registers a listener that runs whenever the fraud checks
+// flag something, for example to notify online admins.
+func (e *EconomyPlugin) OnFraudAlert(handler func(alert FraudAlert)) {
+	e.hooks.mutex.Lock()
+	defer e.hooks.mutex.Unlock()
+	
+	e.hooks.fraudAlert = append(e.hooks.fraudAlert, handler)
+}
+
+// OnPurchase registers a listener that runs before a shop purchase is
+// charged. Returning true cancels the purchase.
+func (e *EconomyPlugin) OnPurchase(handler func(ev PurchaseEvent) (cancel bool)) {
+	e.hooks.mutex.Lock()
+	defer e.hooks.mutex.Unlock()
+	
+	e.hooks.purchase = append(e.hooks.purchase, handler)
+}
+
+// OnPurchaseCompleted registers a listener that runs after a shop purchase
+// has been charged.
+func (e *EconomyPlugin) OnPurchaseCompleted(handler func(ev PurchaseEvent)) {
+	e.hooks.mutex.Lock()
+	defer e.hooks.mutex.Unlock()
+	
+	e.hooks.purchased = append(e.hooks.purchased, handler)
+}
+
+// OnRewardGranted registers a listener that runs after a quest reward has
+// been paid.
+func (e *EconomyPlugin) OnRewardGranted(handler func(ev RewardGrantedEvent)) {
+	e.hooks.mutex.Lock()
+	defer e.hooks.mutex.Unlock()
+	
+	e.hooks.rewardGranted = append(e.hooks.rewardGranted, handler)
+}
+
+// OnTransaction registers a listener that runs after every recorded
+// transaction. Calling the returned function removes it again, so
+// short-lived subscribers such as API streams do not pile up.
+func (e *EconomyPlugin) OnTransaction(handler func(transaction Transaction)) (remove func()) {
+	e.hooks.mutex.Lock()
+	defer e.hooks.mutex.Unlock()
+	
+	if e.hooks.transaction == nil {
+		e.hooks.transaction = make(map[int]func(transaction Transaction))
+	}
+	e.hooks.nextID++
+	id := e.hooks.nextID
+	e.hooks.transaction[id] = handler
+	
+	return func() {
+		e.hooks.mutex.Lock()
+		defer e.hooks.mutex.Unlock()
+		
+		delete(e.hooks.transaction, id)
+	}
+}
+
+func (e *EconomyPlugin) fireBalanceChange(username string, oldBalance, newBalance Money, transactionType TransactionType) {
+	e.hooks.mutex.RLock()
+	handlers := e.hooks.balanceChange
+	e.hooks.mutex.RUnlock()
+	
+	ev := BalanceChangeEvent{
+		Username:   username,
+		OldBalance: oldBalance,
+		NewBalance: newBalance,
+		Type:       transactionType,
+	}
+	
+	for _, handler := range handlers {
+		e.runHook(func() { handler(ev) })
+	}
+}
+
+func (e *EconomyPlugin) fireTransfer(from, to string, amount Money) (cancelled bool) {
+	e.hooks.mutex.RLock()
+	handlers := e.hooks.transfer
+	e.hooks.mutex.RUnlock()
+	
+	ev := TransferEvent{
+		From:   from,
+		To:     to,
+		Amount: amount,
+	}
+	
+	for _, handler := range handlers {
+		e.runHook(func() { cancelled = handler(ev) })
+		if cancelled {
+			return true
+		}
+	}
+	
+	return false
+}
+
+func (e *EconomyPlugin) fireAccountCreated(account *PlayerAccount) {
+	e.hooks.mutex.RLock()
+	handlers := e.hooks.accountCreated
+	e.hooks.mutex.RUnlock()
+	
+	ev := AccountCreatedEvent{
+		Username: account.Username,
+		UUID:     account.UUID,
+		Balance:  account.Balance,
+	}
+	
+	for _, handler := range handlers {
+		e.runHook(func() { handler(ev) })
+	}
+}
+
+func (e *EconomyPlugin) fireFraudAlert(alert FraudAlert) {
+	e.hooks.mutex.RLock()
+	handlers := e.hooks.fraudAlert
+	e.hooks.mutex.RUnlock()
+	
+	for _, handler := range handlers {
+		e.runHook(func() { handler(alert) })
+	}
+}
+
+func (e *EconomyPlugin) firePurchase(ev PurchaseEvent) (cancelled bool) {
+	e.hooks.mutex.RLock()
+	handlers := e.hooks.purchase
+	e.hooks.mutex.RUnlock()
+	
+	for _, handler := range handlers {
+		e.runHook(func() { cancelled = handler(ev) })
+		if cancelled {
+			return true
+		}
+	}
+	
+	return false
+}
+
+func (e *EconomyPlugin) firePurchaseCompleted(ev PurchaseEvent) {
+	e.hooks.mutex.RLock()
+	handlers := e.hooks.purchased
+	e.hooks.mutex.RUnlock()
+	
+	for _, handler := range handlers {
+		e.runHook(func() { handler(ev) })
+	}
+}
+
+func (e *EconomyPlugin) fireRewardGranted(ev RewardGrantedEvent) {
+	e.hooks.mutex.RLock()
+	handlers := e.hooks.rewardGranted
+	e.hooks.mutex.RUnlock()
+	
+	for _, handler := range handlers {
+		e.runHook(func() { handler(ev) })
+	}
+}
+
+func (e *EconomyPlugin) fireTransaction(transaction *Transaction) {
+	e.hooks.mutex.RLock()
+	handlers := make([]func(transaction Transaction), 0, len(e.hooks.transaction))
+	for _, handler := range e.hooks.transaction {
+		handlers = append(handlers, handler)
+	}
+	e.hooks.mutex.RUnlock()
+	
+	for _, handler := range handlers {
+		e.runHook(func() { handler(*transaction) })
+	}
+}
+
+// runHook keeps a panicking listener from taking down the transaction path.
+func (e *EconomyPlugin) runHook(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			e.logger.Error("Event listener panicked", "panic", r)
+		}
+	}()
+	
+	fn()
+}
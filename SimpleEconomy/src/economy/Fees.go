@@ -0,0 +1,42 @@
+package economy
+
+import (
+	"sort"
+)
+
+// TransferFeeConfig describes what /pay charges on top of the amount sent.
+// The fee is a flat part plus the percentage of the first tier whose UpTo
+// bound covers the amount; a tier with UpTo 0 matches everything above the
+// others. Fees go to Recipient, or are destroyed when it is empty.
+type TransferFeeConfig struct {
+	FlatFee   Money     `json:"flat_fee"`
+	Tiers     []FeeTier `json:"tiers"`
+	Recipient string    `json:"recipient"`
+}
+
+type FeeTier struct {
+	UpTo    Money   `json:"up_to"`
+	Percent float64 `json:"percent"`
+}
+
+func (e *EconomyPlugin) transferFee(amount Money) Money {
+	config := e.config.TransferFees
+	fee := config.FlatFee
+	
+	tiers := append([]FeeTier(nil), config.Tiers...)
+	sort.SliceStable(tiers, func(i, j int) bool {
+		if tiers[i].UpTo == 0 || tiers[j].UpTo == 0 {
+			return tiers[j].UpTo == 0 && tiers[i].UpTo != 0
+		}
+		return tiers[i].UpTo < tiers[j].UpTo
+	})
+	
+	for _, tier := range tiers {
+		if tier.UpTo == 0 || amount < tier.UpTo {
+			fee += amount.MulRate(tier.Percent / 100)
+			break
+		}
+	}
+	
+	return e.roundAmount(fee)
+}
@@ -0,0 +1,309 @@
+package economy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fileStorageIndex  = "index.json"
+	fileStorageSchema = "schema.json"
+)
+
+// FileStorage keeps each account in its own <uuid>.json file, so Load only
+// reads the name index and accounts are read when first asked for. Like
+// SQLiteStorage it stages changes and Save writes only what changed.
+type FileStorage struct {
+	dir     string
+	index   map[string]fileIndexEntry
+	names   map[string]string
+	pending map[string]PlayerAccount
+	deleted map[string]bool
+	mutex   sync.Mutex
+}
+
+// fileIndexEntry is what index.json records about every stored account.
+type fileIndexEntry struct {
+	Username string    `json:"username"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+func NewFileStorage(dir string) *FileStorage {
+	return &FileStorage{
+		dir:     dir,
+		index:   make(map[string]fileIndexEntry),
+		names:   make(map[string]string),
+		pending: make(map[string]PlayerAccount),
+		deleted: make(map[string]bool),
+	}
+}
+
+func (s *FileStorage) Load() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	if err := s.migrateSchema(); err != nil {
+		return err
+	}
+	s.pending = make(map[string]PlayerAccount)
+	s.deleted = make(map[string]bool)
+	s.index = make(map[string]fileIndexEntry)
+	
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, fileStorageIndex))
+	if err == nil && json.Unmarshal(data, &s.index) == nil {
+		s.indexNames()
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	
+	// A missing or damaged index is rebuilt from the account files, which
+	// are the source of truth.
+	s.index = make(map[string]fileIndexEntry)
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		uuid := strings.TrimSuffix(file.Name(), ".json")
+		if file.IsDir() || uuid == file.Name() || !isUUID(uuid) {
+			continue
+		}
+		account, err := s.readAccount(uuid)
+		if err != nil {
+			return err
+		}
+		if account != nil {
+			s.index[uuid] = fileIndexEntry{Username: account.Username, LastSeen: account.LastSeen}
+		}
+	}
+	s.indexNames()
+	if len(s.index) > 0 {
+		slog.Info("Rebuilt the player file index", "accounts", len(s.index), "path", s.dir)
+	}
+	
+	return s.writeIndex()
+}
+
+// migrateSchema checks and records the schema version in schema.json.
+// Player files have been keyed by UUID from the start and their amounts
+// are rounded as they are parsed, so no step has anything to change in
+// them and a folder without a version is taken to be current.
+func (s *FileStorage) migrateSchema() error {
+	path := filepath.Join(s.dir, fileStorageSchema)
+	version, err := readSchemaFile(path)
+	if err != nil {
+		return err
+	}
+	if version == storageSchemaVersion {
+		return nil
+	}
+	if version == 0 {
+		version = storageSchemaVersion
+	}
+	
+	reached, err := migrateSchema(s.dir, version, nil, func(schemaMigration) error { return nil })
+	if err != nil {
+		return err
+	}
+	return writeSchemaFile(path, reached)
+}
+
+// indexNames maps every name to the account most recently seen with it,
+// the same account loadPlayerData would pick. Callers hold s.mutex.
+func (s *FileStorage) indexNames() {
+	s.names = make(map[string]string, len(s.index))
+	for uuid := range s.index {
+		s.claimName(uuid)
+	}
+}
+
+// claimName points uuid's indexed name at it unless a more recently seen
+// account holds that name. Callers hold s.mutex.
+func (s *FileStorage) claimName(uuid string) {
+	entry := s.index[uuid]
+	key := strings.ToLower(entry.Username)
+	if holder, taken := s.names[key]; taken && holder != uuid && s.index[holder].LastSeen.After(entry.LastSeen) {
+		return
+	}
+	s.names[key] = uuid
+}
+
+// releaseName drops uuid's hold on its indexed name. Callers hold s.mutex.
+func (s *FileStorage) releaseName(uuid string) {
+	key := strings.ToLower(s.index[uuid].Username)
+	if s.names[key] == uuid {
+		delete(s.names, key)
+	}
+}
+
+func (s *FileStorage) accountPath(uuid string) string {
+	return filepath.Join(s.dir, uuid+".json")
+}
+
+// readAccount reads uuid's file, returning nil if it has none.
+func (s *FileStorage) readAccount(uuid string) (*PlayerAccount, error) {
+	data, err := ioutil.ReadFile(s.accountPath(uuid))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	account := &PlayerAccount{}
+	if err := json.Unmarshal(data, account); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.accountPath(uuid), err)
+	}
+	account.UUID = uuid
+	
+	return account, nil
+}
+
+func (s *FileStorage) writeIndex() error {
+	data, err := json.Marshal(s.index)
+	if err != nil {
+		return err
+	}
+	
+	return writeFileAtomic(filepath.Join(s.dir, fileStorageIndex), data, 0644)
+}
+
+func (s *FileStorage) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if len(s.pending) == 0 && len(s.deleted) == 0 {
+		return nil
+	}
+	
+	for uuid := range s.deleted {
+		if err := os.Remove(s.accountPath(uuid)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(s.deleted, uuid)
+	}
+	
+	for uuid, account := range s.pending {
+		data, err := json.MarshalIndent(&account, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(s.accountPath(uuid), data, 0644); err != nil {
+			return err
+		}
+		delete(s.pending, uuid)
+	}
+	
+	return s.writeIndex()
+}
+
+func (s *FileStorage) GetAccount(uuid string) (*PlayerAccount, error) {
+	s.mutex.Lock()
+	if account, exists := s.pending[uuid]; exists {
+		s.mutex.Unlock()
+		return &account, nil
+	}
+	_, indexed := s.index[uuid]
+	s.mutex.Unlock()
+	
+	if !indexed || !isUUID(uuid) {
+		return nil, nil
+	}
+	
+	return s.readAccount(uuid)
+}
+
+func (s *FileStorage) PutAccount(account *PlayerAccount) error {
+	if !isUUID(account.UUID) {
+		return fmt.Errorf("cannot store account %q under invalid uuid %q", account.Username, account.UUID)
+	}
+	
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	s.pending[account.UUID] = *account
+	delete(s.deleted, account.UUID)
+	s.releaseName(account.UUID)
+	s.index[account.UUID] = fileIndexEntry{Username: account.Username, LastSeen: account.LastSeen}
+	s.claimName(account.UUID)
+	return nil
+}
+
+func (s *FileStorage) DeleteAccount(uuid string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if _, indexed := s.index[uuid]; !indexed {
+		delete(s.pending, uuid)
+		return nil
+	}
+	
+	name := s.index[uuid].Username
+	held := s.names[strings.ToLower(name)] == uuid
+	s.releaseName(uuid)
+	delete(s.index, uuid)
+	delete(s.pending, uuid)
+	s.deleted[uuid] = true
+	// Hand the name to the next most recently seen account sharing it.
+	if held {
+		for other, entry := range s.index {
+			if strings.EqualFold(entry.Username, name) {
+				s.claimName(other)
+			}
+		}
+	}
+	return nil
+}
+
+// ListAccounts reads every account file, so it costs a full scan.
+func (s *FileStorage) ListAccounts() ([]*PlayerAccount, error) {
+	s.mutex.Lock()
+	uuids := make([]string, 0, len(s.index))
+	for uuid := range s.index {
+		uuids = append(uuids, uuid)
+	}
+	s.mutex.Unlock()
+	
+	accounts := make([]*PlayerAccount, 0, len(uuids))
+	for _, uuid := range uuids {
+		account, err := s.GetAccount(uuid)
+		if err != nil {
+			return nil, err
+		}
+		if account != nil {
+			accounts = append(accounts, account)
+		}
+	}
+	
+	return accounts, nil
+}
+
+func (s *FileStorage) FindAccount(username string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	return s.names[strings.ToLower(username)], nil
+}
+
+func (s *FileStorage) CountAccounts() (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	return len(s.index), nil
+}
+
+func (s *FileStorage) Close() error {
+	return nil
+}
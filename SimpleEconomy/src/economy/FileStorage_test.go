@@ -0,0 +1,16 @@
+package economy_test
+
+import (
+	"path/filepath"
+	"testing"
+	
+	"github.com/percocets100/SimpleEconomy/SimpleEconomy/src/economy"
+	"github.com/percocets100/SimpleEconomy/SimpleEconomy/src/economy/storagetest"
+)
+
+func TestFileStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Opener {
+		dir := filepath.Join(t.TempDir(), "players")
+		return func() (economy.Storage, error) { return economy.NewFileStorage(dir), nil }
+	})
+}
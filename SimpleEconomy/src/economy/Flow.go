@@ -0,0 +1,177 @@
+package economy
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReasonCode says where money recorded in a transaction came from or went
+// to, so flows in and out of the economy can be reported by category.
+type ReasonCode string
+
+const (
+	ReasonShop     ReasonCode = "shop"
+	ReasonJob      ReasonCode = "job"
+	ReasonAdmin    ReasonCode = "admin"
+	ReasonTax      ReasonCode = "tax"
+	ReasonInterest ReasonCode = "interest"
+	ReasonPay      ReasonCode = "pay"
+	ReasonReward   ReasonCode = "reward"
+)
+
+// reasonCodes are the built-in codes. Servers can allow more through
+// reason_codes in the config.
+var reasonCodes = []ReasonCode{ReasonShop, ReasonJob, ReasonAdmin, ReasonTax, ReasonInterest, ReasonPay, ReasonReward}
+
+// reasonCode is the code recorded when the caller gave none.
+func (t TransactionType) reasonCode() ReasonCode {
+	switch t {
+	case ADD, SUBTRACT, SET, ROLLBACK, BATCH, OPENING, CONFISCATE:
+		return ReasonAdmin
+	case INTEREST:
+		return ReasonInterest
+	case FEE, TAX, DEATH_PENALTY:
+		return ReasonTax
+	case REWARD, SALARY:
+		return ReasonReward
+	case PURCHASE:
+		return ReasonShop
+	case JOB:
+		return ReasonJob
+	default:
+		return ReasonPay
+	}
+}
+
+// reasonCode returns the transaction's code, working it out from the type
+// for transactions recorded before codes existed.
+func (t *Transaction) reasonCode() ReasonCode {
+	if t.Code != "" {
+		return t.Code
+	}
+	return t.Type.reasonCode()
+}
+
+// reasonCode normalises code and reports whether it is built in or
+// allowed by the config.
+func (e *EconomyPlugin) reasonCode(code ReasonCode) (ReasonCode, bool) {
+	code = ReasonCode(strings.ToLower(strings.TrimSpace(string(code))))
+	for _, known := range reasonCodes {
+		if code == known {
+			return code, true
+		}
+	}
+	for _, custom := range e.config.ReasonCodes {
+		if strings.EqualFold(custom, string(code)) {
+			return code, true
+		}
+	}
+	return code, false
+}
+
+// DepositWithCode credits amount to username, recording where the money
+// came from.
+func (e *EconomyPlugin) DepositWithCode(username string, amount Money, code ReasonCode, reason string) (string, error) {
+	code, ok := e.reasonCode(code)
+	if !ok {
+		return "", ErrInvalidReasonCode
+	}
+	
+	return e.credit(username, e.roundAmount(amount), ADD, code, reasonOr(reason, "Money added"))
+}
+
+// WithdrawWithCode debits amount from username, recording where the money
+// went.
+func (e *EconomyPlugin) WithdrawWithCode(username string, amount Money, code ReasonCode, reason string) (string, error) {
+	code, ok := e.reasonCode(code)
+	if !ok {
+		return "", ErrInvalidReasonCode
+	}
+	if !e.hasAccount(username) {
+		return "", ErrAccountNotFound
+	}
+	
+	return e.debit(username, e.roundAmount(amount), SUBTRACT, code, reasonOr(reason, "Money subtracted"))
+}
+
+// MoneyFlow totals one reason code's money over a period. Entered and
+// Left count money created and destroyed; Moved counts money that only
+// changed hands.
+type MoneyFlow struct {
+	Code    ReasonCode
+	Entered Money
+	Left    Money
+	Moved   Money
+}
+
+// Flows totals the ledger since the given time by reason code, largest
+// flows first.
+func (e *EconomyPlugin) Flows(since time.Time) []MoneyFlow {
+	totals := make(map[ReasonCode]*MoneyFlow)
+	for _, transaction := range e.GetTransactions("", TransactionFilter{Since: since}) {
+		code := transaction.reasonCode()
+		flow, exists := totals[code]
+		if !exists {
+			flow = &MoneyFlow{Code: code}
+			totals[code] = flow
+		}
+		
+		switch created := moneyCreated(&transaction); {
+		case created > 0:
+			flow.Entered += created
+		case created < 0:
+			flow.Left -= created
+		case transaction.Type != SET && transaction.Type != ROLLBACK && transaction.Type != OPENING:
+			flow.Moved += transaction.Amount
+		}
+	}
+	
+	flows := make([]MoneyFlow, 0, len(totals))
+	for _, flow := range totals {
+		flows = append(flows, *flow)
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		a, b := flows[i].Entered+flows[i].Left, flows[j].Entered+flows[j].Left
+		if a != b {
+			return a > b
+		}
+		return flows[i].Code < flows[j].Code
+	})
+	return flows
+}
+
+func (e *EconomyPlugin) flowCommand(ctx *CommandContext) string {
+	if len(ctx.Args) > 1 {
+		return e.message("flow.usage")
+	}
+	
+	period, label := statsWindow, "24h"
+	if len(ctx.Args) == 1 {
+		parsed, err := parseDuration(strings.ToLower(ctx.Args[0]))
+		if err != nil || parsed <= 0 {
+			return e.message("flow.usage")
+		}
+		period, label = parsed, ctx.Args[0]
+	}
+	
+	flows := e.Flows(e.now().Add(-period))
+	if len(flows) == 0 {
+		return e.message("flow.none")
+	}
+	
+	var entered, left Money
+	for _, flow := range flows {
+		entered += flow.Entered
+		left += flow.Left
+	}
+	
+	result := e.message("flow.header", "period", label, "entered", e.FormatMoney(entered),
+		"left", e.FormatMoney(left), "net", e.FormatMoney(entered-left))
+	for _, flow := range flows {
+		result += "\n" + e.message("flow.entry", "code", string(flow.Code), "entered", e.FormatMoney(flow.Entered),
+			"left", e.FormatMoney(flow.Left), "moved", e.FormatMoney(flow.Moved))
+	}
+	
+	return result
+}
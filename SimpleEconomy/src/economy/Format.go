@@ -0,0 +1,168 @@
+package economy
+
+import (
+	"strings"
+)
+
+// MoneyFormatConfig controls how amounts are displayed. When Locale is set
+// its separators replace ThousandsSeparator and DecimalSeparator.
+type MoneyFormatConfig struct {
+	Locale             string `json:"locale"`
+	ThousandsSeparator string `json:"thousands_separator"`
+	DecimalSeparator   string `json:"decimal_separator"`
+	SymbolPosition     string `json:"symbol_position"`
+	SymbolSpace        bool   `json:"symbol_space"`
+	AbbreviateTop      bool   `json:"abbreviate_top"`
+	NegativeStyle      string `json:"negative_style"`
+}
+
+// Styles for the negative_style option: -$5.00 or ($5.00).
+const (
+	negativeMinus       = "minus"
+	negativeParentheses = "parentheses"
+)
+
+// localeSeparators maps a language code to its thousands and decimal
+// separators.
+var localeSeparators = map[string][2]string{
+	"en": {",", "."},
+	"de": {".", ","},
+	"es": {".", ","},
+	"it": {".", ","},
+	"nl": {".", ","},
+	"pt": {".", ","},
+	"tr": {".", ","},
+	"id": {".", ","},
+	"fr": {" ", ","},
+	"pl": {" ", ","},
+	"ru": {" ", ","},
+	"uk": {" ", ","},
+	"cs": {" ", ","},
+	"sv": {" ", ","},
+	"ja": {",", "."},
+	"ko": {",", "."},
+	"zh": {",", "."},
+}
+
+var abbreviations = []struct {
+	threshold float64
+	suffix    string
+}{
+	{1e12, "T"},
+	{1e9, "B"},
+	{1e6, "M"},
+	{1e3, "K"},
+}
+
+// FormatMoney renders amount with the configured symbol, separators and
+// decimal places.
+func (e *EconomyPlugin) FormatMoney(amount Money) string {
+	return e.withSign(e.withSymbol(e.formatNumber(amount, e.decimalPlaces())))
+}
+
+// FormatMoneyShort renders amount abbreviated to one decimal place with a
+// K, M, B or T suffix, such as $1.2K. Amounts below a thousand are
+// rendered as by FormatMoney.
+func (e *EconomyPlugin) FormatMoneyShort(amount Money) string {
+	value := amount.Float64()
+	if value < 0 {
+		value = -value
+	}
+	
+	for _, abbreviation := range abbreviations {
+		if value < abbreviation.threshold {
+			continue
+		}
+		
+		scaled := MoneyFromFloat(amount.Float64() / abbreviation.threshold).Truncate(1)
+		number := e.formatNumber(scaled, 1)
+		number = strings.TrimSuffix(number, e.separators()[1]+"0")
+		return e.withSign(e.withSymbol(number + abbreviation.suffix))
+	}
+	
+	return e.FormatMoney(amount)
+}
+
+func (e *EconomyPlugin) separators() [2]string {
+	format := e.config.Format
+	if format.Locale != "" {
+		language := strings.ToLower(format.Locale)
+		if cut := strings.IndexAny(language, "_-"); cut >= 0 {
+			language = language[:cut]
+		}
+		if separators, ok := localeSeparators[language]; ok {
+			return separators
+		}
+	}
+	
+	decimal := format.DecimalSeparator
+	if decimal == "" {
+		decimal = "."
+	}
+	return [2]string{format.ThousandsSeparator, decimal}
+}
+
+func (e *EconomyPlugin) formatNumber(amount Money, places int) string {
+	separators := e.separators()
+	
+	text := amount.Format(places)
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	
+	whole, fraction := text, ""
+	if dot := strings.IndexByte(text, '.'); dot >= 0 {
+		whole, fraction = text[:dot], text[dot+1:]
+	}
+	
+	if separators[0] != "" && len(whole) > 3 {
+		var grouped strings.Builder
+		lead := len(whole) % 3
+		if lead > 0 {
+			grouped.WriteString(whole[:lead])
+		}
+		for i := lead; i < len(whole); i += 3 {
+			if grouped.Len() > 0 {
+				grouped.WriteString(separators[0])
+			}
+			grouped.WriteString(whole[i : i+3])
+		}
+		whole = grouped.String()
+	}
+	
+	if fraction != "" {
+		return sign + whole + separators[1] + fraction
+	}
+	return sign + whole
+}
+
+func (e *EconomyPlugin) withSymbol(number string) string {
+	format := e.config.Format
+	symbol := e.config.CurrencySymbol
+	if symbol == "" {
+		return number
+	}
+	
+	space := ""
+	if format.SymbolSpace {
+		space = " "
+	}
+	
+	if strings.ToLower(format.SymbolPosition) == "suffix" {
+		return number + space + symbol
+	}
+	
+	if strings.HasPrefix(number, "-") {
+		return "-" + symbol + space + number[1:]
+	}
+	return symbol + space + number
+}
+
+// withSign renders a leading minus in the configured negative_style.
+func (e *EconomyPlugin) withSign(text string) string {
+	if rest, negative := strings.CutPrefix(text, "-"); negative && strings.ToLower(e.config.Format.NegativeStyle) == negativeParentheses {
+		return "(" + rest + ")"
+	}
+	return text
+}
@@ -0,0 +1,302 @@
+package economy
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FraudConfig tunes the anomaly checks run on every transaction. Transfers
+// are remembered for WindowMinutes; within that window a chain of at most
+// CircularDepth players paying each other round in a circle is flagged, as
+// is one player receiving from FreshSenders or more accounts created in
+// the last FreshAccountHours. Any single credit of at least BalanceJump is
+// flagged on its own. Zero disables a check.
+type FraudConfig struct {
+	Enabled           bool  `json:"enabled"`
+	WindowMinutes     int   `json:"window_minutes"`
+	CircularDepth     int   `json:"circular_depth"`
+	FreshAccountHours int   `json:"fresh_account_hours"`
+	FreshSenders      int   `json:"fresh_senders"`
+	BalanceJump       Money `json:"balance_jump"`
+	KeepAlerts        int   `json:"keep_alerts"`
+}
+
+const (
+	AlertCircular    = "circular"
+	AlertFreshFunnel = "fresh_funnel"
+	AlertBalanceJump = "balance_jump"
+	AlertSupplyCap   = "supply_cap"
+)
+
+// FraudAlert is one flagged pattern. Detail is already rendered for
+// display.
+type FraudAlert struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Players []string  `json:"players"`
+	Detail  string    `json:"detail"`
+}
+
+type fraudEdge struct {
+	from, to string
+	at       time.Time
+}
+
+// fraudDetector holds the recent activity the checks look at. It lives in
+// memory only.
+type fraudDetector struct {
+	mutex   sync.Mutex
+	hooks   sync.Once
+	edges   []fraudEdge
+	created map[string]time.Time
+	funnels map[string]map[string]time.Time
+	flagged map[string]time.Time
+	alerts  []FraudAlert
+}
+
+// registerFraudHooks subscribes the detector to the plugin's events. Like
+// the event hub it only ever subscribes once per plugin.
+func (e *EconomyPlugin) registerFraudHooks() {
+	e.fraud.hooks.Do(func() {
+		e.OnAccountCreated(func(ev AccountCreatedEvent) {
+			if !e.config.Fraud.Enabled {
+				return
+			}
+			e.fraud.mutex.Lock()
+			if e.fraud.created == nil {
+				e.fraud.created = make(map[string]time.Time)
+			}
+			e.fraud.created[strings.ToLower(ev.Username)] = e.now()
+			e.fraud.mutex.Unlock()
+		})
+		
+		e.OnTransaction(func(transaction Transaction) {
+			if e.config.Fraud.Enabled {
+				e.inspectTransaction(&transaction)
+			}
+		})
+	})
+}
+
+func (e *EconomyPlugin) inspectTransaction(transaction *Transaction) {
+	config := e.config.Fraud
+	window := time.Duration(config.WindowMinutes) * time.Minute
+	
+	var alerts []FraudAlert
+	e.fraud.mutex.Lock()
+	if e.fraud.flagged == nil {
+		e.fraud.funnels = make(map[string]map[string]time.Time)
+		e.fraud.flagged = make(map[string]time.Time)
+	}
+	if e.fraud.created == nil {
+		e.fraud.created = make(map[string]time.Time)
+	}
+	e.fraud.prune(transaction.Timestamp, window, time.Duration(config.FreshAccountHours)*time.Hour)
+	
+	if transaction.Type == TRANSFER && window > 0 {
+		from, to := strings.ToLower(transaction.From), strings.ToLower(transaction.To)
+		if config.CircularDepth > 1 {
+			if cycle := e.fraud.findCycle(from, to, config.CircularDepth); cycle != nil {
+				key := AlertCircular + ":" + cycleKey(cycle)
+				if e.fraud.flag(key, transaction.Timestamp) {
+					alerts = append(alerts, FraudAlert{Kind: AlertCircular, Players: cycle,
+						Detail: e.message("alerts.circular", "players", strings.Join(cycle, " -> ")+" -> "+cycle[0])})
+				}
+			}
+		}
+		e.fraud.edges = append(e.fraud.edges, fraudEdge{from: from, to: to, at: transaction.Timestamp})
+		
+		if created, fresh := e.fraud.created[from]; fresh && config.FreshSenders > 0 && transaction.Timestamp.Sub(created) < time.Duration(config.FreshAccountHours)*time.Hour {
+			senders := e.fraud.funnels[to]
+			if senders == nil {
+				senders = make(map[string]time.Time)
+				e.fraud.funnels[to] = senders
+			}
+			senders[from] = transaction.Timestamp
+			if len(senders) >= config.FreshSenders && e.fraud.flag(AlertFreshFunnel+":"+to, transaction.Timestamp) {
+				alerts = append(alerts, FraudAlert{Kind: AlertFreshFunnel, Players: []string{transaction.To},
+					Detail: e.message("alerts.fresh_funnel", "player", transaction.To, "count", strconv.Itoa(len(senders)),
+						"minutes", strconv.Itoa(config.WindowMinutes))})
+			}
+		}
+	}
+	e.fraud.mutex.Unlock()
+	
+	if gain := creditedAmount(transaction); config.BalanceJump > 0 && gain >= config.BalanceJump {
+		alerts = append(alerts, FraudAlert{Kind: AlertBalanceJump, Players: []string{transaction.To},
+			Detail: e.message("alerts.balance_jump", "player", transaction.To, "amount", e.FormatMoney(gain),
+				"type", transaction.Type.String())})
+	}
+	
+	for i := range alerts {
+		alerts[i].Time = transaction.Timestamp
+		e.raiseAlert(alerts[i])
+	}
+}
+
+// creditedAmount is how much a transaction added to a player's wallet.
+// Money moving into shared accounts, escrows or vouchers is not counted.
+func creditedAmount(transaction *Transaction) Money {
+	if transaction.To == "" || strings.HasPrefix(transaction.To, "@") || strings.Contains(transaction.To, "#") {
+		return 0
+	}
+	if transaction.Type == SET {
+		if transaction.Previous == nil {
+			return 0
+		}
+		return transaction.Amount - *transaction.Previous
+	}
+	return transaction.Amount
+}
+
+// prune drops activity that has fallen out of the windows. Callers hold
+// the detector's mutex.
+func (d *fraudDetector) prune(now time.Time, window, fresh time.Duration) {
+	kept := d.edges[:0]
+	for _, edge := range d.edges {
+		if now.Sub(edge.at) < window {
+			kept = append(kept, edge)
+		}
+	}
+	d.edges = kept
+	
+	for to, senders := range d.funnels {
+		for from, at := range senders {
+			if now.Sub(at) >= window {
+				delete(senders, from)
+			}
+		}
+		if len(senders) == 0 {
+			delete(d.funnels, to)
+		}
+	}
+	for key, at := range d.flagged {
+		if now.Sub(at) >= window {
+			delete(d.flagged, key)
+		}
+	}
+	for name, at := range d.created {
+		if now.Sub(at) >= fresh {
+			delete(d.created, name)
+		}
+	}
+}
+
+// findCycle looks for a chain of recent transfers leading from to back to
+// from, which the new transfer from -> to would close. It returns the
+// players in the circle starting with from, or nil.
+func (d *fraudDetector) findCycle(from, to string, depth int) []string {
+	next := make(map[string][]string)
+	for _, edge := range d.edges {
+		next[edge.from] = append(next[edge.from], edge.to)
+	}
+	
+	previous := map[string]string{to: ""}
+	frontier := []string{to}
+	for step := 1; step < depth && len(frontier) > 0; step++ {
+		var upcoming []string
+		for _, player := range frontier {
+			for _, target := range next[player] {
+				if target == from {
+					cycle := []string{player}
+					for at := previous[player]; at != ""; at = previous[at] {
+						cycle = append(cycle, at)
+					}
+					cycle = append(cycle, from)
+					for i, j := 0, len(cycle)-1; i < j; i, j = i+1, j-1 {
+						cycle[i], cycle[j] = cycle[j], cycle[i]
+					}
+					return cycle
+				}
+				if _, seen := previous[target]; !seen {
+					previous[target] = player
+					upcoming = append(upcoming, target)
+				}
+			}
+		}
+		frontier = upcoming
+	}
+	
+	return nil
+}
+
+func cycleKey(cycle []string) string {
+	sorted := append([]string(nil), cycle...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// flag reports whether key has not been raised within the window yet and
+// remembers it. Callers hold the detector's mutex.
+func (d *fraudDetector) flag(key string, now time.Time) bool {
+	if _, raised := d.flagged[key]; raised {
+		return false
+	}
+	d.flagged[key] = now
+	return true
+}
+
+func (e *EconomyPlugin) raiseAlert(alert FraudAlert) {
+	e.logger.Warn("Suspicious activity", "kind", alert.Kind, "players", alert.Players, "detail", alert.Detail)
+	
+	e.fraud.mutex.Lock()
+	e.fraud.alerts = append(e.fraud.alerts, alert)
+	if keep := e.config.Fraud.KeepAlerts; keep > 0 && len(e.fraud.alerts) > keep {
+		e.fraud.alerts = append([]FraudAlert(nil), e.fraud.alerts[len(e.fraud.alerts)-keep:]...)
+	}
+	e.fraud.mutex.Unlock()
+	
+	e.fireFraudAlert(alert)
+}
+
+// GetAlerts returns the recent fraud alerts, newest first.
+func (e *EconomyPlugin) GetAlerts() []FraudAlert {
+	e.fraud.mutex.Lock()
+	defer e.fraud.mutex.Unlock()
+	
+	alerts := make([]FraudAlert, len(e.fraud.alerts))
+	for i, alert := range e.fraud.alerts {
+		alerts[len(alerts)-1-i] = alert
+	}
+	return alerts
+}
+
+func (e *EconomyPlugin) alertsCommand(ctx *CommandContext) string {
+	page := 1
+	if len(ctx.Args) > 0 {
+		parsed, err := strconv.Atoi(ctx.Args[0])
+		if err != nil || parsed < 1 {
+			return e.message("top.bad_page")
+		}
+		page = parsed
+	}
+	
+	alerts := e.GetAlerts()
+	if len(alerts) == 0 {
+		return e.message("alerts.none")
+	}
+	
+	pageSize := e.config.TopPageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	pages := (len(alerts) + pageSize - 1) / pageSize
+	if page > pages {
+		return e.message("top.bad_page")
+	}
+	
+	result := e.message("alerts.header", "page", strconv.Itoa(page), "pages", strconv.Itoa(pages)) + "\n"
+	end := page * pageSize
+	if end > len(alerts) {
+		end = len(alerts)
+	}
+	for _, alert := range alerts[(page-1)*pageSize : end] {
+		result += e.message("alerts.entry", "time", alert.Time.Format("2006-01-02 15:04"), "kind", alert.Kind,
+			"detail", alert.Detail) + "\n"
+	}
+	
+	return result
+}
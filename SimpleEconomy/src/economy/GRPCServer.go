@@ -0,0 +1,297 @@
+package economy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/percocets100/SimpleEconomy/SimpleEconomy/src/economy/economypb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPCConfig configures the gRPC API. TLS is enabled when both a
+// certificate and a key file are set.
+type GRPCConfig struct {
+	Enabled     bool   `json:"enabled"`
+	BindAddress string `json:"bind_address"`
+	APIToken    string `json:"api_token"`
+	TLSCertFile string `json:"tls_cert_file"`
+	TLSKeyFile  string `json:"tls_key_file"`
+	Reflection  bool   `json:"reflection"`
+}
+
+// grpcStreamBuffer is how many transactions a stream may fall behind
+// before it is closed rather than slowing down the transaction path.
+const grpcStreamBuffer = 256
+
+type grpcService struct {
+	economypb.UnimplementedEconomyServer
+	plugin *EconomyPlugin
+}
+
+func (e *EconomyPlugin) startGRPCServer() {
+	config := e.config.GRPC
+	if !config.Enabled {
+		return
+	}
+	
+	if config.APIToken == "" && !e.hasAPIKeys() {
+		e.logger.Warn("gRPC API enabled but no api_token or API key is set, refusing to start")
+		return
+	}
+	
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(e.grpcUnaryAuth),
+		grpc.StreamInterceptor(e.grpcStreamAuth),
+	}
+	if config.TLSCertFile != "" || config.TLSKeyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			e.logger.Error("Failed to load gRPC TLS certificate, refusing to start", "error", err)
+			return
+		}
+		options = append(options, grpc.Creds(creds))
+	}
+	
+	listener, err := net.Listen("tcp", config.BindAddress)
+	if err != nil {
+		e.logger.Error("Failed to start gRPC API", "address", config.BindAddress, "error", err)
+		return
+	}
+	
+	server := grpc.NewServer(options...)
+	economypb.RegisterEconomyServer(server, &grpcService{plugin: e})
+	if config.Reflection {
+		reflection.Register(server)
+	}
+	e.grpcServer = server
+	
+	go func() {
+		e.logger.Info("gRPC API listening", "address", listener.Addr().String())
+		if err := server.Serve(listener); err != nil {
+			e.logger.Error("gRPC API stopped", "error", err)
+		}
+	}()
+}
+
+// stopGRPCServer lets unary calls finish but cuts transaction streams,
+// which would otherwise keep GracefulStop waiting forever.
+func (e *EconomyPlugin) stopGRPCServer() {
+	if e.grpcServer == nil {
+		return
+	}
+	
+	stopped := make(chan struct{})
+	go func(server *grpc.Server) {
+		server.GracefulStop()
+		close(stopped)
+	}(e.grpcServer)
+	
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		e.grpcServer.Stop()
+	}
+	e.grpcServer = nil
+}
+
+// checkGRPCToken authenticates a call and enforces the key's scope and rate
+// limit, returning the key's name for the request log. Transfer needs the
+// transfer scope.
+func (e *EconomyPlugin) checkGRPCToken(ctx context.Context, method string) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	
+	token := ""
+	if values := md.Get("authorization"); len(values) > 0 {
+		token = strings.TrimPrefix(values[0], "Bearer ")
+	}
+	if values := md.Get("x-api-token"); token == "" && len(values) > 0 {
+		token = values[0]
+	}
+	
+	scope := ScopeRead
+	if method == economypb.Economy_Transfer_FullMethodName {
+		scope = ScopeTransfer
+	}
+	
+	name, wait, err := e.authorizeAPI(token, e.config.GRPC.APIToken, scope)
+	switch {
+	case errors.Is(err, ErrInvalidAPIKey):
+		return name, status.Error(codes.Unauthenticated, "invalid API token")
+	case errors.Is(err, ErrAPIKeyScope):
+		return name, status.Error(codes.PermissionDenied, "API key lacks the "+string(scope)+" scope")
+	case errors.Is(err, ErrRateLimited):
+		return name, status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %s", wait.Round(time.Second))
+	}
+	return name, nil
+}
+
+func (e *EconomyPlugin) grpcUnaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	name, err := e.checkGRPCToken(ctx, info.FullMethod)
+	var resp interface{}
+	if err == nil {
+		resp, err = handler(context.WithValue(ctx, apiKeyNameKey{}, name), req)
+	}
+	e.logAPIRequest("grpc", name, info.FullMethod, status.Code(err).String(), time.Since(start))
+	return resp, err
+}
+
+func (e *EconomyPlugin) grpcStreamAuth(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	name, err := e.checkGRPCToken(stream.Context(), info.FullMethod)
+	if err == nil {
+		err = handler(srv, stream)
+	}
+	e.logAPIRequest("grpc", name, info.FullMethod, status.Code(err).String(), time.Since(start))
+	return err
+}
+
+// grpcError maps economy errors to gRPC status codes, following the HTTP
+// API's choice of status.
+func grpcError(err error) error {
+	code := codes.InvalidArgument
+	switch {
+	case errors.Is(err, ErrAccountNotFound):
+		code = codes.NotFound
+	case errors.Is(err, ErrInsufficientFunds), errors.Is(err, ErrMaxBalanceExceeded):
+		code = codes.FailedPrecondition
+	case errors.Is(err, ErrTransferCancelled), errors.Is(err, ErrBlockedByRule), errors.Is(err, ErrIdempotencyKeyReused):
+		code = codes.Aborted
+	case errors.Is(err, ErrStorage):
+		code = codes.Internal
+	}
+	
+	return status.Error(code, err.Error())
+}
+
+func (s *grpcService) GetBalance(ctx context.Context, req *economypb.GetBalanceRequest) (*economypb.Balance, error) {
+	balance, err := s.plugin.GetBalance(req.GetPlayer(), req.GetScope())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	
+	return &economypb.Balance{
+		Player:    req.GetPlayer(),
+		Balance:   balance.String(),
+		Formatted: s.plugin.FormatMoney(balance),
+	}, nil
+}
+
+func (s *grpcService) Transfer(ctx context.Context, req *economypb.TransferRequest) (*economypb.TransferResponse, error) {
+	if req.GetFrom() == "" || req.GetTo() == "" {
+		return nil, status.Error(codes.InvalidArgument, "from and to are required")
+	}
+	
+	amount, err := ParseMoney(req.GetAmount())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid amount: %v", err)
+	}
+	
+	reason := reasonOr(req.GetReason(), "Transfer via gRPC")
+	scope := []string{req.GetScope()}
+	from, to := s.plugin.inScope(req.GetFrom(), scope), s.plugin.inScope(req.GetTo(), scope)
+	id, replayed, err := s.plugin.idempotent(apiKeyName(ctx), req.GetIdempotencyKey(),
+		transferRequestKey(from, to, amount, reason), func() (string, error) {
+			return s.plugin.Transfer(from, to, amount, reason)
+		})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	
+	return &economypb.TransferResponse{Replayed: replayed, TransactionId: id}, nil
+}
+
+func (s *grpcService) Top(ctx context.Context, req *economypb.TopRequest) (*economypb.TopResponse, error) {
+	if req.GetLimit() < 0 || req.GetOffset() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit and offset must not be negative")
+	}
+	
+	players := s.plugin.getTopPlayers()
+	offset := int(req.GetOffset())
+	if offset > len(players) {
+		offset = len(players)
+	}
+	players = players[offset:]
+	if limit := int(req.GetLimit()); limit > 0 && limit < len(players) {
+		players = players[:limit]
+	}
+	
+	response := &economypb.TopResponse{Entries: make([]*economypb.TopEntry, len(players))}
+	for i, player := range players {
+		response.Entries[i] = &economypb.TopEntry{
+			Rank:    int32(offset + i + 1),
+			Player:  player.Username,
+			Balance: player.Balance.String(),
+		}
+	}
+	
+	return response, nil
+}
+
+func (s *grpcService) StreamTransactions(req *economypb.StreamTransactionsRequest, stream economypb.Economy_StreamTransactionsServer) error {
+	// Subscribe before reading the backlog so nothing recorded in between
+	// is missed; anything seen twice is skipped by timestamp below.
+	live := make(chan Transaction, grpcStreamBuffer)
+	overflow := make(chan struct{})
+	var once sync.Once
+	remove := s.plugin.OnTransaction(func(transaction Transaction) {
+		if !(TransactionFilter{}).matches(req.GetPlayer(), &transaction) {
+			return
+		}
+		select {
+		case live <- transaction:
+		default:
+			once.Do(func() { close(overflow) })
+		}
+	})
+	defer remove()
+	
+	var last time.Time
+	if req.GetSince() != nil {
+		backlog := s.plugin.GetTransactions(req.GetPlayer(), TransactionFilter{Since: req.GetSince().AsTime()})
+		for i := len(backlog) - 1; i >= 0; i-- {
+			if err := stream.Send(grpcTransaction(&backlog[i])); err != nil {
+				return err
+			}
+			last = backlog[i].Timestamp
+		}
+	}
+	
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-overflow:
+			return status.Error(codes.ResourceExhausted, "client is not keeping up with the transaction stream")
+		case transaction := <-live:
+			if !transaction.Timestamp.After(last) {
+				continue
+			}
+			if err := stream.Send(grpcTransaction(&transaction)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func grpcTransaction(transaction *Transaction) *economypb.Transaction {
+	return &economypb.Transaction{
+		From:      transaction.From,
+		To:        transaction.To,
+		Amount:    transaction.Amount.String(),
+		Type:      transaction.Type.String(),
+		Timestamp: timestamppb.New(transaction.Timestamp),
+		Reason:    transaction.Reason,
+		Id:        transaction.ID,
+	}
+}
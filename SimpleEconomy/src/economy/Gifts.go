@@ -0,0 +1,406 @@
+package economy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type GiftConfig struct {
+	ExpiryMinutes int `json:"expiry_minutes"`
+	MaxMessage    int `json:"max_message_length"`
+}
+
+// Gift is money sent with an optional message that waits for the
+// recipient to accept it. A gift declined, or not accepted by Expires,
+// goes back to the sender.
+type Gift struct {
+	ID      int       `json:"id"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Amount  Money     `json:"amount"`
+	Message string    `json:"message,omitempty"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+const giftCheckInterval = time.Minute
+
+// giftBook holds every unanswered Gift. settling marks gifts being
+// accepted or returned. Its mutex is never held while accounts are locked.
+type giftBook struct {
+	mutex    sync.Mutex
+	gifts    map[int]*Gift
+	settling map[int]bool
+	nextID   int
+}
+
+func giftRef(id int) string {
+	return "gift#" + strconv.Itoa(id)
+}
+
+func (e *EconomyPlugin) giftsPath() string {
+	return filepath.Join(e.dataFolder, "gifts.json")
+}
+
+func (e *EconomyPlugin) loadGifts() {
+	e.gifts.mutex.Lock()
+	defer e.gifts.mutex.Unlock()
+	
+	e.gifts.gifts = make(map[int]*Gift)
+	e.gifts.settling = make(map[int]bool)
+	e.gifts.nextID = 1
+	
+	data, err := ioutil.ReadFile(e.giftsPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Error("Failed to read gifts", "error", err)
+		return
+	}
+	
+	if err := json.Unmarshal(data, &e.gifts.gifts); err != nil {
+		e.logger.Error("Failed to parse gifts", "error", err)
+	}
+	for id := range e.gifts.gifts {
+		if id >= e.gifts.nextID {
+			e.gifts.nextID = id + 1
+		}
+	}
+}
+
+// saveGifts must be called with e.gifts.mutex held.
+func (e *EconomyPlugin) saveGifts() {
+	data, err := json.MarshalIndent(e.gifts.gifts, "", "  ")
+	if err != nil {
+		e.logger.Error("Failed to marshal gifts", "error", err)
+		return
+	}
+	
+	if err := writeFileAtomic(e.giftsPath(), data, 0644); err != nil {
+		e.logger.Error("Failed to write gifts", "error", err)
+		e.countStorageError("gifts")
+	}
+}
+
+// SendGift moves amount out of from's wallet and holds it until to accepts
+// or declines it, or gifts.expiry_minutes pass.
+func (e *EconomyPlugin) SendGift(from, to string, amount Money, message string) (Gift, error) {
+	if amount <= 0 {
+		return Gift{}, ErrInvalidAmount
+	}
+	if err := e.checkMinTransaction(amount); err != nil {
+		return Gift{}, err
+	}
+	if strings.EqualFold(from, to) {
+		return Gift{}, ErrSelfTransfer
+	}
+	if !e.hasAccount(from) || !e.hasAccount(to) {
+		return Gift{}, ErrAccountNotFound
+	}
+	message = strings.TrimSpace(message)
+	if runes := []rune(message); len(runes) > e.config.Gifts.MaxMessage {
+		message = string(runes[:e.config.Gifts.MaxMessage])
+	}
+	
+	if err := e.chargeSpend(from, amount); err != nil {
+		return Gift{}, err
+	}
+	
+	var oldBalance Money
+	err := e.mutateAccounts([]string{from}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if account.spendable()-amount < e.minBalance() {
+			return ErrInsufficientFunds
+		}
+		
+		oldBalance = account.Balance
+		account.Balance -= amount
+		return nil
+	})
+	if err != nil {
+		e.refundSpend(from, amount)
+		return Gift{}, err
+	}
+	
+	e.gifts.mutex.Lock()
+	now := e.now()
+	gift := &Gift{
+		ID:      e.gifts.nextID,
+		From:    from,
+		To:      to,
+		Amount:  amount,
+		Message: message,
+		Created: now,
+		Expires: now.Add(time.Duration(e.config.Gifts.ExpiryMinutes) * time.Minute),
+	}
+	e.gifts.nextID++
+	e.gifts.gifts[gift.ID] = gift
+	e.saveGifts()
+	created := *gift
+	e.gifts.mutex.Unlock()
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(from, oldBalance, oldBalance-amount, GIFT_SEND)
+	e.recordTransaction(&Transaction{
+		From:      from,
+		To:        giftRef(created.ID),
+		Amount:    amount,
+		Type:      GIFT_SEND,
+		Timestamp: e.now(),
+		Reason:    giftReason("Gift for "+to, message),
+	})
+	
+	return created, nil
+}
+
+func giftReason(reason, message string) string {
+	if message == "" {
+		return reason
+	}
+	return reason + ": " + message
+}
+
+// GetGifts returns the unanswered gifts username sent or was sent, oldest
+// first.
+func (e *EconomyPlugin) GetGifts(username string) []Gift {
+	e.gifts.mutex.Lock()
+	defer e.gifts.mutex.Unlock()
+	
+	gifts := make([]Gift, 0)
+	for _, gift := range e.gifts.gifts {
+		if strings.EqualFold(gift.From, username) || strings.EqualFold(gift.To, username) {
+			gifts = append(gifts, *gift)
+		}
+	}
+	sort.Slice(gifts, func(i, j int) bool {
+		return gifts[i].ID < gifts[j].ID
+	})
+	
+	return gifts
+}
+
+// AcceptGift pays gift id out to player, who must be its recipient.
+func (e *EconomyPlugin) AcceptGift(player string, id int) (Gift, error) {
+	return e.settleGift(player, id, true)
+}
+
+// DeclineGift returns gift id, sent to player, to its sender.
+func (e *EconomyPlugin) DeclineGift(player string, id int) (Gift, error) {
+	return e.settleGift(player, id, false)
+}
+
+// settleGift closes a gift, crediting the recipient when accept is set and
+// the sender otherwise. An empty player skips the recipient check, for
+// expired gifts.
+func (e *EconomyPlugin) settleGift(player string, id int, accept bool) (Gift, error) {
+	e.gifts.mutex.Lock()
+	stored, exists := e.gifts.gifts[id]
+	if !exists || e.gifts.settling[id] || (player != "" && !strings.EqualFold(stored.To, player)) {
+		e.gifts.mutex.Unlock()
+		return Gift{}, ErrGiftNotFound
+	}
+	gift := *stored
+	e.gifts.settling[id] = true
+	e.gifts.mutex.Unlock()
+	
+	recipient, transactionType, reason := gift.From, GIFT_RETURN, "Gift returned by "+gift.To
+	if accept {
+		recipient, transactionType, reason = gift.To, GIFT_ACCEPT, giftReason("Gift from "+gift.From, gift.Message)
+	} else if player == "" {
+		reason = "Gift to " + gift.To + " expired"
+	}
+	
+	var oldBalance, credited Money
+	err := e.mutateAccounts([]string{recipient}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		credited = gift.Amount
+		if account.Balance+credited > e.config.MaxBalance {
+			if accept {
+				return ErrMaxBalanceExceeded
+			}
+			// A returned gift must always close; anything above
+			// max_balance is lost, as with escrow refunds.
+			credited = clampZero(e.config.MaxBalance - account.Balance)
+		}
+		
+		oldBalance = account.Balance
+		account.Balance += credited
+		if accept {
+			account.TotalEarned += credited
+		}
+		return nil
+	})
+	
+	e.gifts.mutex.Lock()
+	delete(e.gifts.settling, id)
+	if err == nil {
+		delete(e.gifts.gifts, id)
+		e.saveGifts()
+	}
+	e.gifts.mutex.Unlock()
+	if err != nil {
+		return Gift{}, err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(recipient, oldBalance, oldBalance+credited, transactionType)
+	e.recordTransaction(&Transaction{
+		From:      giftRef(id),
+		To:        recipient,
+		Amount:    credited,
+		Type:      transactionType,
+		Timestamp: e.now(),
+		Reason:    reason,
+		Postings: e.postings(
+			Posting{Account: giftRef(id), Amount: -gift.Amount},
+			Posting{Account: recipient, Amount: credited},
+		),
+	})
+	
+	return gift, nil
+}
+
+func (e *EconomyPlugin) startGiftExpiry() {
+	e.runPeriodically(giftCheckInterval, e.returnExpiredGifts)
+}
+
+func (e *EconomyPlugin) returnExpiredGifts() {
+	now := e.now()
+	expired := make([]int, 0)
+	
+	e.gifts.mutex.Lock()
+	for id, gift := range e.gifts.gifts {
+		if !now.Before(gift.Expires) {
+			expired = append(expired, id)
+		}
+	}
+	e.gifts.mutex.Unlock()
+	
+	for _, id := range expired {
+		gift, err := e.settleGift("", id, false)
+		if err != nil {
+			e.logger.Warn("Failed to return expired gift", "gift", id, "error", err)
+			continue
+		}
+		e.logger.Info("Returned expired gift", "gift", id, "player", gift.From, "amount", gift.Amount.String())
+	}
+}
+
+// answerGifts accepts or declines gift id, or with no id every gift
+// waiting for player.
+func (e *EconomyPlugin) answerGifts(player string, args []string, accept bool) string {
+	ids := make([]int, 0)
+	if len(args) > 0 {
+		id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		if err != nil {
+			return e.message("gift.bad_id")
+		}
+		ids = append(ids, id)
+	} else {
+		for _, gift := range e.GetGifts(player) {
+			if strings.EqualFold(gift.To, player) {
+				ids = append(ids, gift.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return e.message("gift.none")
+		}
+	}
+	
+	lines := make([]string, 0, len(ids))
+	for _, id := range ids {
+		var gift Gift
+		var err error
+		if accept {
+			gift, err = e.AcceptGift(player, id)
+		} else {
+			gift, err = e.DeclineGift(player, id)
+		}
+		if err != nil {
+			lines = append(lines, e.message("gift.failed", "error", e.describeError(err)))
+			continue
+		}
+		
+		key, notice := "gift.accepted", "gift.accepted_sender"
+		if !accept {
+			key, notice = "gift.declined", "gift.declined_sender"
+		}
+		lines = append(lines, e.message(key, "id", strconv.Itoa(id), "amount", e.FormatMoney(gift.Amount), "player", gift.From))
+		if messenger := e.getMessenger(); messenger != nil && e.isOnline(gift.From) {
+			messenger.SendMessage(gift.From, e.message(notice, "amount", e.FormatMoney(gift.Amount), "player", player))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *EconomyPlugin) giftCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) == 0 {
+		return e.message("gift.usage")
+	}
+	player := ctx.Name()
+	
+	switch strings.ToLower(args[0]) {
+	case "accept":
+		return e.answerGifts(player, args[1:], true)
+		
+	case "decline":
+		return e.answerGifts(player, args[1:], false)
+		
+	case "list":
+		gifts := e.GetGifts(player)
+		if len(gifts) == 0 {
+			return e.message("gift.none")
+		}
+		result := e.message("gift.header")
+		for _, gift := range gifts {
+			result += "\n" + e.message("gift.entry", "id", strconv.Itoa(gift.ID), "from", gift.From, "to", gift.To,
+				"amount", e.FormatMoney(gift.Amount), "expires", gift.Expires.Format("2006-01-02 15:04"))
+		}
+		return result
+	}
+	
+	if len(args) < 2 {
+		return e.message("gift.usage")
+	}
+	amount, err := e.parseAmount(args[1])
+	if err != nil {
+		return e.message("error.invalid_amount")
+	}
+	recipient, err := e.ResolveName(args[0])
+	if err != nil {
+		return e.message("gift.failed", "error", e.describeError(err))
+	}
+	if e.config.BlockPayInDebt && e.getBalance(player) < 0 {
+		return e.message("gift.failed", "error", e.describeError(ErrInDebt))
+	}
+	
+	cancel, err := e.reservePayment(ctx.Sender, amount)
+	if err != nil {
+		return e.message("gift.failed", "error", e.describeError(err))
+	}
+	gift, err := e.SendGift(player, recipient, amount, strings.Join(args[2:], " "))
+	if err != nil {
+		cancel()
+		return e.message("gift.failed", "error", e.describeError(err))
+	}
+	
+	if messenger := e.getMessenger(); messenger != nil && e.isOnline(recipient) {
+		notice := e.message("gift.received", "id", strconv.Itoa(gift.ID), "amount", e.FormatMoney(amount), "player", player,
+			"minutes", strconv.Itoa(e.config.Gifts.ExpiryMinutes))
+		if gift.Message != "" {
+			notice += "\n" + e.message("gift.note", "message", gift.Message)
+		}
+		messenger.SendMessage(recipient, notice)
+	}
+	return e.message("gift.sent", "id", strconv.Itoa(gift.ID), "amount", e.FormatMoney(amount), "player", recipient,
+		"minutes", strconv.Itoa(e.config.Gifts.ExpiryMinutes))
+}
@@ -0,0 +1,72 @@
+package economy
+
+import "strings"
+
+// GroupResolver reports the permission groups or tags a player belongs
+// to, such as "donor" or "vip". The host server wires one in with
+// SetGroupResolver; starting_balances is keyed by the names it returns.
+type GroupResolver interface {
+	PlayerGroups(uuid, username string) []string
+}
+
+func (e *EconomyPlugin) SetGroupResolver(resolver GroupResolver) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	
+	e.groups = resolver
+}
+
+// startingBalance returns the balance a new account opens with: the
+// highest starting_balances entry among the player's groups, or
+// default_balance when none match or no resolver is registered. It must
+// not be called with e.mutex held, since the resolver may call back in.
+func (e *EconomyPlugin) startingBalance(uuid, username string) Money {
+	if IsVirtualAccount(username) {
+		return 0
+	}
+	// A scoped account starts with what its player's own account would.
+	if player, scope := splitScoped(username); scope != "" {
+		uuid, username = strings.TrimSuffix(uuid, scopeSeparator+scope), player
+	}
+	
+	e.mutex.RLock()
+	resolver := e.groups
+	e.mutex.RUnlock()
+	
+	balance, matched := e.config.DefaultBalance, false
+	if resolver == nil || len(e.config.StartingBalances) == 0 {
+		return balance
+	}
+	
+	for _, group := range resolver.PlayerGroups(uuid, username) {
+		if amount, ok := e.config.StartingBalances[group]; ok && (!matched || amount > balance) {
+			balance, matched = amount, true
+		}
+	}
+	
+	return balance
+}
+
+// inAnyGroup reports whether the group resolver puts the player in any of
+// groups. It is always false without a resolver.
+func (e *EconomyPlugin) inAnyGroup(uuid, username string, groups []string) bool {
+	if len(groups) == 0 {
+		return false
+	}
+	
+	e.mutex.RLock()
+	resolver := e.groups
+	e.mutex.RUnlock()
+	if resolver == nil {
+		return false
+	}
+	
+	for _, group := range resolver.PlayerGroups(uuid, username) {
+		for _, wanted := range groups {
+			if strings.EqualFold(group, wanted) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,386 @@
+package economy
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type HTTPConfig struct {
+	Enabled     bool   `json:"enabled"`
+	BindAddress string `json:"bind_address"`
+	APIToken    string `json:"api_token"`
+	WebSocket   bool   `json:"websocket"`
+	Metrics     bool   `json:"metrics"`
+	Dashboard   bool   `json:"dashboard"`
+}
+
+type apiBalance struct {
+	Player        string `json:"player"`
+	Balance       Money  `json:"balance"`
+	Formatted     string `json:"formatted"`
+	TransactionID string `json:"transaction_id,omitempty"`
+}
+
+type apiTransferRequest struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount Money  `json:"amount"`
+	Reason string `json:"reason"`
+	Scope  string `json:"scope,omitempty"`
+}
+
+type apiTransferResponse struct {
+	OK            bool   `json:"ok"`
+	TransactionID string `json:"transaction_id"`
+}
+
+type apiTopEntry struct {
+	Rank    int    `json:"rank"`
+	Player  string `json:"player"`
+	Balance Money  `json:"balance"`
+}
+
+type apiTransaction struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Amount    Money     `json:"amount"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Reason    string    `json:"reason"`
+}
+
+type apiHistoryEntry struct {
+	Day     string `json:"day"`
+	Balance Money  `json:"balance"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func (e *EconomyPlugin) startHTTPServer() {
+	if !e.config.HTTP.Enabled {
+		return
+	}
+	
+	if e.config.HTTP.APIToken == "" && !e.hasAPIKeys() {
+		e.logger.Warn("HTTP API enabled but no api_token or API key is set, refusing to start")
+		return
+	}
+	
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/balance/{player}", e.handleBalance)
+	mux.HandleFunc("POST /api/v1/transfer", e.handleTransfer)
+	mux.HandleFunc("GET /api/v1/top", e.handleTop)
+	mux.HandleFunc("GET /api/v1/transactions", e.handleTransactions)
+	mux.HandleFunc("GET /api/v1/history/{player}", e.handleHistory)
+	mux.HandleFunc("GET /api/v1/supply", e.handleSupply)
+	mux.HandleFunc("POST /api/v1/vote", e.handleVote)
+	mux.HandleFunc("POST /api/v1/admin/{action}", e.handleAdminAction)
+	if e.config.HTTP.Metrics {
+		mux.HandleFunc("GET /metrics", e.handleMetrics)
+	}
+	if e.config.HTTP.WebSocket {
+		e.registerEventHooks()
+		mux.HandleFunc("GET /api/v1/events", e.handleEvents)
+	}
+	
+	handler := e.requireToken(mux)
+	if e.config.HTTP.Dashboard {
+		root := http.NewServeMux()
+		root.HandleFunc("GET /dashboard", e.handleDashboard)
+		root.Handle("/", handler)
+		handler = root
+	}
+	
+	e.httpServer = &http.Server{
+		Addr:         e.config.HTTP.BindAddress,
+		Handler:      handler,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	
+	go func(server *http.Server) {
+		e.logger.Info("HTTP API listening", "address", server.Addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			e.logger.Error("HTTP API stopped", "error", err)
+		}
+	}(e.httpServer)
+}
+
+func (e *EconomyPlugin) stopHTTPServer() {
+	if e.httpServer == nil {
+		return
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	
+	if err := e.httpServer.Shutdown(ctx); err != nil {
+		e.logger.Error("Failed to stop HTTP API", "error", err)
+	}
+	// Shutdown does not track hijacked connections.
+	e.events.closeAll()
+	e.httpServer = nil
+}
+
+// requireToken authenticates every request, enforces the key's scope and
+// rate limit and logs the request. Anything but a GET needs the transfer
+// scope, and the admin endpoints need the admin scope.
+func (e *EconomyPlugin) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &apiResponseWriter{ResponseWriter: w}
+		
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.Header.Get("X-API-Token")
+		}
+		// Browsers cannot set headers on a WebSocket handshake.
+		if token == "" && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			token = r.URL.Query().Get("token")
+		}
+		
+		scope := ScopeRead
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/v1/admin/"):
+			scope = ScopeAdmin
+		case r.Method != http.MethodGet:
+			scope = ScopeTransfer
+		}
+		
+		name, wait, err := e.authorizeAPI(token, e.config.HTTP.APIToken, scope)
+		switch {
+		case errors.Is(err, ErrInvalidAPIKey):
+			writeJSON(recorder, http.StatusUnauthorized, apiError{Error: "invalid API token"})
+		case errors.Is(err, ErrAPIKeyScope):
+			writeJSON(recorder, http.StatusForbidden, apiError{Error: "API key lacks the " + string(scope) + " scope"})
+		case errors.Is(err, ErrRateLimited):
+			recorder.Header().Set("Retry-After", strconv.Itoa(int(wait/time.Second)+1))
+			writeJSON(recorder, http.StatusTooManyRequests, apiError{Error: "rate limit exceeded"})
+		default:
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), apiKeyNameKey{}, name)))
+		}
+		
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		e.logAPIRequest("http", name, r.Method+" "+r.URL.Path, strconv.Itoa(recorder.status), time.Since(start))
+	})
+}
+
+func tokenMatches(token, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+func (e *EconomyPlugin) handleBalance(w http.ResponseWriter, r *http.Request) {
+	player := r.PathValue("player")
+	
+	balance, err := e.GetBalance(player, r.URL.Query().Get("scope"))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	
+	writeJSON(w, http.StatusOK, apiBalance{
+		Player:    player,
+		Balance:   balance,
+		Formatted: e.FormatMoney(balance),
+	})
+}
+
+func (e *EconomyPlugin) handleTransfer(w http.ResponseWriter, r *http.Request) {
+	var request apiTransferRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	
+	if request.From == "" || request.To == "" {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "from and to are required"})
+		return
+	}
+	
+	reason := reasonOr(request.Reason, "Transfer via HTTP API")
+	from, to := e.inScope(request.From, []string{request.Scope}), e.inScope(request.To, []string{request.Scope})
+	id, replayed, err := e.idempotent(apiKeyName(r.Context()), r.Header.Get("Idempotency-Key"),
+		transferRequestKey(from, to, request.Amount, reason), func() (string, error) {
+			return e.Transfer(from, to, request.Amount, reason)
+		})
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	
+	writeJSON(w, http.StatusOK, apiTransferResponse{OK: true, TransactionID: id})
+}
+
+func (e *EconomyPlugin) handleVote(w http.ResponseWriter, r *http.Request) {
+	var vote Vote
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&vote); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request body"})
+		return
+	}
+	
+	reward, err := e.RecordVote(vote)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	
+	writeJSON(w, http.StatusOK, reward)
+}
+
+func (e *EconomyPlugin) handleTop(w http.ResponseWriter, r *http.Request) {
+	topPlayers := e.getTopPlayers()
+	
+	entries := make([]apiTopEntry, len(topPlayers))
+	for i, player := range topPlayers {
+		entries[i] = apiTopEntry{
+			Rank:    i + 1,
+			Player:  player.Username,
+			Balance: player.Balance,
+		}
+	}
+	
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (e *EconomyPlugin) handleTransactions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	
+	filter, err := parseTransactionFilter(query.Get)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+	
+	transactions := e.GetTransactions(query.Get("player"), filter)
+	
+	result := make([]apiTransaction, len(transactions))
+	for i, transaction := range transactions {
+		result[i] = apiTransaction{
+			From:      transaction.From,
+			To:        transaction.To,
+			Amount:    transaction.Amount,
+			Type:      transaction.Type.String(),
+			Timestamp: transaction.Timestamp,
+			Reason:    transaction.Reason,
+		}
+	}
+	
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (e *EconomyPlugin) handleHistory(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if value := r.URL.Query().Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid days"})
+			return
+		}
+		days = parsed
+	}
+	
+	snapshots, err := e.GetBalanceHistory(r.PathValue("player"), e.now().AddDate(0, 0, 1-days))
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	
+	result := make([]apiHistoryEntry, len(snapshots))
+	for i, snapshot := range snapshots {
+		result[i] = apiHistoryEntry{
+			Day:     snapshot.Day.Format(historyDayLayout),
+			Balance: snapshot.Balance,
+		}
+	}
+	
+	writeJSON(w, http.StatusOK, result)
+}
+
+func parseTransactionFilter(get func(string) string) (TransactionFilter, error) {
+	var filter TransactionFilter
+	var err error
+	
+	if value := get("since"); value != "" {
+		if filter.Since, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, fmt.Errorf("invalid since: %v", err)
+		}
+	}
+	
+	if value := get("until"); value != "" {
+		if filter.Until, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, fmt.Errorf("invalid until: %v", err)
+		}
+	}
+	
+	if value := get("type"); value != "" {
+		for _, name := range strings.Split(value, ",") {
+			t, ok := parseTransactionType(name)
+			if !ok {
+				return filter, fmt.Errorf("unknown transaction type %q", name)
+			}
+			filter.Types = append(filter.Types, t)
+		}
+	}
+	
+	for key, target := range map[string]*Money{"min": &filter.MinAmount, "max": &filter.MaxAmount} {
+		if value := get(key); value != "" {
+			if *target, err = ParseMoney(value); err != nil {
+				return filter, fmt.Errorf("invalid %s: %v", key, err)
+			}
+		}
+	}
+	
+	filter.Reason = get("reason")
+	
+	for key, target := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := get(key); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil || *target < 0 {
+				return filter, fmt.Errorf("invalid %s", key)
+			}
+		}
+	}
+	
+	return filter, nil
+}
+
+func writeAPIError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, ErrAccountNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrInsufficientFunds), errors.Is(err, ErrMaxBalanceExceeded):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, ErrTransferCancelled), errors.Is(err, ErrBlockedByRule), errors.Is(err, ErrIdempotencyKeyReused), errors.Is(err, ErrVoteDuplicate):
+		status = http.StatusConflict
+	case errors.Is(err, ErrStorage):
+		status = http.StatusInternalServerError
+	}
+	
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Warn("Failed to write HTTP response", "error", err)
+	}
+}
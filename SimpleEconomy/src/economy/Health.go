@@ -0,0 +1,223 @@
+package economy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HealthReportConfig writes a report on the previous day to the reports
+// folder on Schedule, a cron expression, and with Discord set posts it to
+// the Discord webhook too. Only the newest Keep reports are kept, zero
+// keeping all of them.
+type HealthReportConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Schedule string `json:"schedule"`
+	Discord  bool   `json:"discord"`
+	Keep     int    `json:"keep"`
+}
+
+const healthReportPrefix = "health-"
+
+// HealthReport sums up one day of the economy. Supply is the money held
+// in wallets and banks at the end of the day and PreviousSupply at the
+// end of the day before. TopGainer is the player whose wallet grew the
+// most through the day's transactions.
+type HealthReport struct {
+	Day            time.Time
+	Supply         Money
+	PreviousSupply Money
+	NewAccounts    int
+	Transactions   int
+	TopGainer      string
+	Gained         Money
+}
+
+// Inflation returns how much the money supply grew over the day, as a
+// fraction of the day before's. It is false when there was no money the
+// day before to compare with.
+func (r *HealthReport) Inflation() (float64, bool) {
+	if r.PreviousSupply <= 0 {
+		return 0, false
+	}
+	return float64(r.Supply-r.PreviousSupply) / float64(r.PreviousSupply), true
+}
+
+func (e *EconomyPlugin) reportFolder() string {
+	return filepath.Join(e.dataFolder, "reports")
+}
+
+func (e *EconomyPlugin) startHealthReports() {
+	if !e.config.HealthReport.Enabled {
+		return
+	}
+	
+	schedule, err := parseCronSchedule(e.config.HealthReport.Schedule)
+	if err != nil {
+		e.logger.Warn("Health reports disabled", "error", err)
+		return
+	}
+	
+	e.runOnSchedule(schedule, func() {
+		report := e.HealthReport(e.now().AddDate(0, 0, -1))
+		if name, err := e.writeHealthReport(&report); err != nil {
+			e.logger.Error("Failed to write health report", "error", err)
+		} else {
+			e.logger.Info("Wrote health report", "file", name)
+		}
+		if e.config.HealthReport.Discord {
+			e.notifyHealthReport(&report)
+		}
+	})
+}
+
+// HealthReport works out the report for the day containing day, in the
+// server's time zone. Earlier supplies are worked back from today's
+// through the ledger, as for MoneySupply.
+func (e *EconomyPlugin) HealthReport(day time.Time) HealthReport {
+	now := e.now()
+	day = day.In(now.Location())
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, now.Location())
+	end := start.AddDate(0, 0, 1)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	
+	report := HealthReport{Day: start}
+	if start.After(today) {
+		return report
+	}
+	
+	days := 2
+	for d := start; d.Before(today); d = d.AddDate(0, 0, 1) {
+		days++
+	}
+	supply := e.MoneySupply(days)
+	report.PreviousSupply, report.Supply = supply[0].Total, supply[1].Total
+	
+	for _, account := range e.snapshotAccounts() {
+		if !account.virtual() && !account.Created.Before(start) && account.Created.Before(end) {
+			report.NewAccounts++
+		}
+	}
+	
+	flows := newPlayerFlows()
+	transactions := e.GetTransactions("", TransactionFilter{Since: start, Until: end.Add(-time.Nanosecond)})
+	for i := range transactions {
+		flows.add(&transactions[i])
+	}
+	report.Transactions = len(transactions)
+	
+	net := make(map[string]Money, len(flows.earned))
+	for key, earned := range flows.earned {
+		net[key] = earned - flows.spent[key]
+	}
+	gainer, gained := largest(net)
+	report.TopGainer, report.Gained = flows.names[gainer], gained
+	
+	return report
+}
+
+func (e *EconomyPlugin) formatInflation(report *HealthReport) string {
+	inflation, ok := report.Inflation()
+	if !ok {
+		return e.message("health.no_inflation")
+	}
+	text := strconv.FormatFloat(inflation*100, 'f', 2, 64) + "%"
+	if inflation >= 0 {
+		text = "+" + text
+	}
+	return text
+}
+
+// formatChange formats an amount with its sign, even when positive.
+func (e *EconomyPlugin) formatChange(amount Money) string {
+	if amount >= 0 {
+		return "+" + e.FormatMoney(amount)
+	}
+	return e.FormatMoney(amount)
+}
+
+func (e *EconomyPlugin) formatHealthReport(report *HealthReport) string {
+	result := e.message("health.header", "day", report.Day.Format(historyDayLayout))
+	result += "\n" + e.message("health.supply", "supply", e.FormatMoney(report.Supply),
+		"change", e.formatChange(report.Supply-report.PreviousSupply), "inflation", e.formatInflation(report))
+	result += "\n" + e.message("health.new_accounts", "count", strconv.Itoa(report.NewAccounts))
+	result += "\n" + e.message("health.transactions", "count", strconv.Itoa(report.Transactions))
+	if report.TopGainer != "" {
+		result += "\n" + e.message("health.top_gainer", "player", report.TopGainer, "amount", e.formatChange(report.Gained))
+	}
+	return result
+}
+
+// writeHealthReport saves a report as health-<day>.txt in the reports
+// folder, replacing any earlier report for that day, and prunes reports
+// beyond health_report.keep. It returns the file name.
+func (e *EconomyPlugin) writeHealthReport(report *HealthReport) (string, error) {
+	if err := os.MkdirAll(e.reportFolder(), 0755); err != nil {
+		return "", err
+	}
+	
+	name := healthReportPrefix + report.Day.Format(historyDayLayout) + ".txt"
+	if err := writeFileAtomic(filepath.Join(e.reportFolder(), name), []byte(e.formatHealthReport(report)+"\n"), 0644); err != nil {
+		return "", err
+	}
+	
+	e.pruneHealthReports()
+	return name, nil
+}
+
+func (e *EconomyPlugin) pruneHealthReports() {
+	keep := e.config.HealthReport.Keep
+	if keep <= 0 {
+		return
+	}
+	
+	reports, err := e.ListHealthReports()
+	if err != nil || len(reports) <= keep {
+		return
+	}
+	
+	for _, name := range reports[keep:] {
+		if err := os.Remove(filepath.Join(e.reportFolder(), name)); err != nil {
+			e.logger.Warn("Failed to remove old health report", "file", name, "error", err)
+		}
+	}
+}
+
+// ListHealthReports returns the health reports in the reports folder,
+// newest first.
+func (e *EconomyPlugin) ListHealthReports() ([]string, error) {
+	entries, err := ioutil.ReadDir(e.reportFolder())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	reports := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, healthReportPrefix) && strings.HasSuffix(name, ".txt") {
+			reports = append(reports, name)
+		}
+	}
+	// The dates in the names sort in time order.
+	sort.Sort(sort.Reverse(sort.StringSlice(reports)))
+	return reports, nil
+}
+
+func (e *EconomyPlugin) notifyHealthReport(report *HealthReport) {
+	gainer := e.message("health.no_gainer")
+	if report.TopGainer != "" {
+		gainer = report.TopGainer + " (" + e.formatChange(report.Gained) + ")"
+	}
+	
+	e.notifyDiscord("health_report", "day", report.Day.Format(historyDayLayout),
+		"supply", e.FormatMoney(report.Supply), "change", e.formatChange(report.Supply-report.PreviousSupply),
+		"inflation", e.formatInflation(report), "new_accounts", strconv.Itoa(report.NewAccounts),
+		"transactions", strconv.Itoa(report.Transactions), "top_gainer", gainer)
+}
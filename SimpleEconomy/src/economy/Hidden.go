@@ -0,0 +1,91 @@
+package economy
+
+import (
+	"sort"
+	"strings"
+)
+
+// hiddenAccount reports whether account is kept off /top and out of
+// /eco stats, either by its own flag or by being named in
+// hidden_accounts.
+func (e *EconomyPlugin) hiddenAccount(account *PlayerAccount) bool {
+	if account.Hidden {
+		return true
+	}
+	for _, name := range e.config.HiddenAccounts {
+		if strings.EqualFold(name, account.Username) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAccountHidden sets whether username's account is left off the
+// leaderboards and out of the economy statistics. Its balance and
+// history are unaffected.
+func (e *EconomyPlugin) SetAccountHidden(username string, hidden bool) error {
+	account, exists := e.lookupAccount(username)
+	if !exists {
+		return ErrAccountNotFound
+	}
+	
+	unlock := e.locks.lock(account)
+	account.Hidden = hidden
+	unlock()
+	
+	e.markDirty(account)
+	e.invalidateTopPlayers()
+	return nil
+}
+
+// HiddenAccounts returns the names of every hidden account, sorted,
+// including those only hidden by hidden_accounts.
+func (e *EconomyPlugin) HiddenAccounts() []string {
+	names := make([]string, 0)
+	for _, account := range e.snapshotAccounts() {
+		if e.hiddenAccount(&account) {
+			names = append(names, account.Username)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return strings.ToLower(names[i]) < strings.ToLower(names[j])
+	})
+	return names
+}
+
+func (e *EconomyPlugin) hideCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) == 1 && strings.EqualFold(args[0], "list") {
+		names := e.HiddenAccounts()
+		if len(names) == 0 {
+			return e.message("hide.none")
+		}
+		return e.message("hide.list", "players", strings.Join(names, ", "))
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return e.message("hide.usage")
+	}
+	
+	hidden := true
+	if len(args) == 2 {
+		switch strings.ToLower(args[1]) {
+		case "on":
+		case "off":
+			hidden = false
+		default:
+			return e.message("hide.usage")
+		}
+	}
+	
+	player := args[0]
+	if err := e.SetAccountHidden(player, hidden); err != nil {
+		return e.message("hide.failed", "error", e.describeError(err))
+	}
+	
+	if hidden {
+		e.audit(ctx, "hide", player, nil, nil, "")
+		return e.message("hide.hidden", "player", player)
+	}
+	e.audit(ctx, "unhide", player, nil, nil, "")
+	return e.message("hide.shown", "player", player)
+}
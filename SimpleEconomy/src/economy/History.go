@@ -0,0 +1,308 @@
+package economy
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyDayLayout is how snapshot days are keyed in every backend, so
+// days compare correctly as plain strings.
+const historyDayLayout = "2006-01-02"
+
+// BalanceSnapshot is an account's closing balance for one day.
+type BalanceSnapshot struct {
+	Day     time.Time `json:"day"`
+	Balance Money     `json:"balance"`
+}
+
+// BalanceHistoryStore persists one closing balance per account per day.
+// RecordBalances overwrites the day's previous snapshot, so the last one
+// recorded becomes the closing balance. Storage backends that also
+// implement it keep history next to the accounts.
+type BalanceHistoryStore interface {
+	RecordBalances(day string, balances map[string]Money) error
+	QueryBalances(uuid string, since string) ([]BalanceSnapshot, error)
+	PruneBalances(before string) error
+}
+
+// JSONBalanceHistory keeps every snapshot in one file, keyed by UUID and
+// then by day.
+type JSONBalanceHistory struct {
+	path  string
+	days  map[string]map[string]Money
+	mutex sync.Mutex
+}
+
+func NewJSONBalanceHistory(path string) *JSONBalanceHistory {
+	return &JSONBalanceHistory{path: path}
+}
+
+func (h *JSONBalanceHistory) load() error {
+	if h.days != nil {
+		return nil
+	}
+	
+	h.days = make(map[string]map[string]Money)
+	data, err := ioutil.ReadFile(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	
+	return json.Unmarshal(data, &h.days)
+}
+
+func (h *JSONBalanceHistory) write() error {
+	data, err := json.Marshal(h.days)
+	if err != nil {
+		return err
+	}
+	
+	return writeFileAtomic(h.path, data, 0644)
+}
+
+func (h *JSONBalanceHistory) RecordBalances(day string, balances map[string]Money) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	
+	if err := h.load(); err != nil {
+		return err
+	}
+	
+	for uuid, balance := range balances {
+		if h.days[uuid] == nil {
+			h.days[uuid] = make(map[string]Money)
+		}
+		h.days[uuid][day] = balance
+	}
+	
+	return h.write()
+}
+
+func (h *JSONBalanceHistory) QueryBalances(uuid string, since string) ([]BalanceSnapshot, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+	
+	snapshots := make([]BalanceSnapshot, 0)
+	for day, balance := range h.days[uuid] {
+		if day < since {
+			continue
+		}
+		snapshot, err := newBalanceSnapshot(day, balance)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Day.Before(snapshots[j].Day)
+	})
+	
+	return snapshots, nil
+}
+
+func (h *JSONBalanceHistory) PruneBalances(before string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	
+	if err := h.load(); err != nil {
+		return err
+	}
+	
+	for uuid, days := range h.days {
+		for day := range days {
+			if day < before {
+				delete(days, day)
+			}
+		}
+		if len(days) == 0 {
+			delete(h.days, uuid)
+		}
+	}
+	
+	return h.write()
+}
+
+func newBalanceSnapshot(day string, balance Money) (BalanceSnapshot, error) {
+	parsed, err := time.ParseInLocation(historyDayLayout, day, time.Local)
+	if err != nil {
+		return BalanceSnapshot{}, err
+	}
+	
+	return BalanceSnapshot{Day: parsed, Balance: balance}, nil
+}
+
+func (e *EconomyPlugin) startBalanceHistory() {
+	if e.config.BalanceHistoryDays <= 0 || e.config.BalanceHistorySeconds <= 0 {
+		return
+	}
+	
+	e.runPeriodically(time.Duration(e.config.BalanceHistorySeconds)*time.Second, e.recordBalanceHistory)
+}
+
+// recordBalanceHistory stores every account's current balance as today's
+// closing balance and drops snapshots older than balance_history_days.
+func (e *EconomyPlugin) recordBalanceHistory() {
+	if e.history == nil || e.config.BalanceHistoryDays <= 0 {
+		return
+	}
+	
+	accounts := e.snapshotAccounts()
+	balances := make(map[string]Money, len(accounts))
+	for _, account := range accounts {
+		balances[account.UUID] = account.Balance
+	}
+	
+	now := e.now()
+	if err := e.history.RecordBalances(now.Format(historyDayLayout), balances); err != nil {
+		e.logger.Error("Failed to record balance history", "error", err)
+		e.countStorageError("history")
+		return
+	}
+	
+	cutoff := now.AddDate(0, 0, -e.config.BalanceHistoryDays)
+	if err := e.history.PruneBalances(cutoff.Format(historyDayLayout)); err != nil {
+		e.logger.Error("Failed to prune balance history", "error", err)
+		e.countStorageError("history")
+	}
+}
+
+// GetBalanceHistory returns a player's daily closing balances from since
+// onwards, oldest first. Today's entry holds the balance at the most
+// recent snapshot.
+func (e *EconomyPlugin) GetBalanceHistory(username string, since time.Time) ([]BalanceSnapshot, error) {
+	uuid, exists := e.GetUUID(username)
+	if !exists {
+		return nil, ErrAccountNotFound
+	}
+	if e.history == nil {
+		return nil, nil
+	}
+	
+	snapshots, err := e.history.QueryBalances(uuid, since.Format(historyDayLayout))
+	if err != nil {
+		e.logger.Error("Failed to query balance history", "error", err)
+		return nil, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	
+	return snapshots, nil
+}
+
+func (e *EconomyPlugin) balanceHistoryCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) == 0 && ctx.IsConsole() {
+		return e.message("history.usage")
+	}
+	
+	username := ctx.Name()
+	if len(args) > 0 {
+		username = args[0]
+	}
+	if !strings.EqualFold(username, ctx.Name()) && !e.hasPermission(ctx.Sender, "economy.command.balance.others") {
+		return e.message("command.no_permission")
+	}
+	if e.balancePrivate(ctx.Sender, username) {
+		return e.message("balance.private", "player", username)
+	}
+	
+	days := 7
+	if len(args) > 1 {
+		parsed, err := strconv.Atoi(args[1])
+		if err != nil || parsed < 1 {
+			return e.message("history.bad_days")
+		}
+		days = parsed
+	}
+	
+	since := e.now().AddDate(0, 0, 1-days)
+	snapshots, err := e.GetBalanceHistory(username, since)
+	if err != nil {
+		return e.message("history.failed", "error", e.describeError(err))
+	}
+	if len(snapshots) == 0 {
+		return e.message("history.empty", "player", username)
+	}
+	
+	result := e.message("history.header", "player", username, "days", strconv.Itoa(days)) + "\n"
+	for _, snapshot := range snapshots {
+		result += e.message("history.entry", "day", snapshot.Day.Format(historyDayLayout), "amount", e.FormatMoney(snapshot.Balance)) + "\n"
+	}
+	
+	change := snapshots[len(snapshots)-1].Balance - snapshots[0].Balance
+	formatted := e.FormatMoney(change)
+	if change >= 0 {
+		formatted = "+" + formatted
+	}
+	result += e.message("history.change", "change", formatted) + "\n"
+	
+	return result
+}
+
+// The helpers below serve the SQL backends, whose balance_history tables
+// share a layout and differ only in their upsert statement.
+func queryBalanceHistory(db *sql.DB, uuid string, since string) ([]BalanceSnapshot, error) {
+	rows, err := db.Query(`SELECT day, balance FROM balance_history WHERE uuid = ? AND day >= ? ORDER BY day`, uuid, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	snapshots := make([]BalanceSnapshot, 0)
+	for rows.Next() {
+		var day string
+		var balance Money
+		if err := rows.Scan(&day, &balance); err != nil {
+			return nil, err
+		}
+		snapshot, err := newBalanceSnapshot(day, balance)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	
+	return snapshots, rows.Err()
+}
+
+func upsertBalanceHistory(db *sql.DB, upsert string, day string, balances map[string]Money) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	
+	stmt, err := tx.Prepare(upsert)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	
+	for uuid, balance := range balances {
+		if _, err := stmt.Exec(uuid, day, balance); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	
+	return tx.Commit()
+}
+
+func pruneBalanceHistory(db *sql.DB, before string) error {
+	_, err := db.Exec(`DELETE FROM balance_history WHERE day < ?`, before)
+	return err
+}
@@ -0,0 +1,224 @@
+package economy
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Hold reserves part of a wallet, for example for an auction bid. The
+// money stays in the balance but cannot be spent until the hold is
+// released or captured.
+type Hold struct {
+	ID      string    `json:"id"`
+	Amount  Money     `json:"amount"`
+	Reason  string    `json:"reason"`
+	Created time.Time `json:"created"`
+}
+
+func (a *PlayerAccount) held() Money {
+	var held Money
+	for _, hold := range a.Holds {
+		held += hold.Amount
+	}
+	return held
+}
+
+// spendable is the part of the balance not on hold.
+func (a *PlayerAccount) spendable() Money {
+	return a.Balance - a.held()
+}
+
+// withoutHold returns the account's holds minus id. Holds are never
+// changed in place, since snapshots share the slice.
+func (a *PlayerAccount) withoutHold(id string) ([]Hold, Hold, bool) {
+	for i, hold := range a.Holds {
+		if hold.ID == id {
+			remaining := make([]Hold, 0, len(a.Holds)-1)
+			remaining = append(remaining, a.Holds[:i]...)
+			return append(remaining, a.Holds[i+1:]...), hold, true
+		}
+	}
+	return a.Holds, Hold{}, false
+}
+
+func newHoldID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func (e *EconomyPlugin) getSpendable(username string) Money {
+	account := e.getAccount(username)
+	
+	unlock := e.locks.lock(account)
+	defer unlock()
+	
+	return account.spendable()
+}
+
+func (e *EconomyPlugin) getHeld(username string) Money {
+	account := e.getAccount(username)
+	
+	unlock := e.locks.lock(account)
+	defer unlock()
+	
+	return account.held()
+}
+
+// PlaceHold puts amount of player's balance on hold and returns the hold's
+// ID. It fails with ErrInsufficientFunds unless that much is spendable.
+func (e *EconomyPlugin) PlaceHold(player string, amount Money, reason string) (string, error) {
+	if amount <= 0 {
+		return "", ErrInvalidAmount
+	}
+	if !e.hasAccount(player) {
+		return "", ErrAccountNotFound
+	}
+	
+	id, err := newHoldID()
+	if err != nil {
+		return "", err
+	}
+	hold := Hold{ID: id, Amount: amount, Reason: clipReference(reason), Created: e.now()}
+	
+	err = e.mutateAccounts([]string{player}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if account.spendable() < amount {
+			return ErrInsufficientFunds
+		}
+		account.Holds = append(append([]Hold(nil), account.Holds...), hold)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	
+	e.logger.Debug("Placed hold", "player", player, "id", hold.ID, "amount", amount.String(), "reason", reason)
+	return hold.ID, nil
+}
+
+// ReleaseHold lifts a hold, for example when a bid is outbid, and returns
+// the amount made spendable again.
+func (e *EconomyPlugin) ReleaseHold(player, id string) (Money, error) {
+	if !e.hasAccount(player) {
+		return 0, ErrAccountNotFound
+	}
+	
+	var released Hold
+	err := e.mutateAccounts([]string{player}, func(accounts []*PlayerAccount) error {
+		remaining, hold, found := accounts[0].withoutHold(id)
+		if !found {
+			return ErrHoldNotFound
+		}
+		accounts[0].Holds, released = remaining, hold
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	
+	e.logger.Debug("Released hold", "player", player, "id", id, "amount", released.Amount.String())
+	return released.Amount, nil
+}
+
+// CaptureHold lifts a hold and pays its amount from player to to, for
+// example to the seller of a won auction. An empty to takes the money out
+// of the economy.
+func (e *EconomyPlugin) CaptureHold(player, id, to string) (Money, error) {
+	if strings.EqualFold(player, to) {
+		return 0, ErrSelfTransfer
+	}
+	if !e.hasAccount(player) {
+		return 0, ErrAccountNotFound
+	}
+	
+	usernames := []string{player}
+	if to != "" {
+		usernames = append(usernames, to)
+	}
+	
+	var captured Hold
+	var fromOld, toOld Money
+	err := e.mutateAccounts(usernames, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		remaining, hold, found := account.withoutHold(id)
+		if !found {
+			return ErrHoldNotFound
+		}
+		if account.Balance < hold.Amount {
+			return ErrInsufficientFunds
+		}
+		if len(accounts) > 1 && accounts[1].Balance+hold.Amount > e.config.MaxBalance {
+			return ErrMaxBalanceExceeded
+		}
+		
+		fromOld = account.Balance
+		account.Holds = remaining
+		account.Balance -= hold.Amount
+		account.TotalSpent += hold.Amount
+		if len(accounts) > 1 {
+			toOld = accounts[1].Balance
+			accounts[1].Balance += hold.Amount
+			accounts[1].TotalEarned += hold.Amount
+		}
+		captured = hold
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(player, fromOld, fromOld-captured.Amount, HOLD_CAPTURE)
+	if to != "" {
+		e.fireBalanceChange(to, toOld, toOld+captured.Amount, HOLD_CAPTURE)
+	}
+	
+	e.recordTransaction(&Transaction{
+		From:      player,
+		To:        to,
+		Amount:    captured.Amount,
+		Type:      HOLD_CAPTURE,
+		Timestamp: e.now(),
+		Reason:    "Hold captured: " + captured.Reason,
+	})
+	
+	return captured.Amount, nil
+}
+
+// GetHolds lists the holds on player's balance, oldest first.
+func (e *EconomyPlugin) GetHolds(player string) []Hold {
+	account, exists := e.lookupAccount(player)
+	if !exists {
+		return nil
+	}
+	
+	return append([]Hold(nil), e.snapshotAccount(account).Holds...)
+}
+
+// holdsColumn encodes holds for the SQL backends, which keep them as JSON
+// next to the balance.
+func holdsColumn(holds []Hold) (interface{}, error) {
+	if len(holds) == 0 {
+		return nil, nil
+	}
+	
+	data, err := json.Marshal(holds)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func scanHolds(column sql.NullString, account *PlayerAccount) error {
+	if !column.Valid || column.String == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(column.String), &account.Holds)
+}
@@ -0,0 +1,93 @@
+package economy
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxIdempotencyKey bounds the keys clients may send.
+const maxIdempotencyKey = 255
+
+// idempotencyCache remembers the outcome of API transfers by idempotency
+// key, so a client retrying a request whose response it never received
+// gets the first outcome instead of paying twice.
+type idempotencyCache struct {
+	mutex   sync.Mutex
+	entries map[string]*idempotentCall
+}
+
+// idempotentCall is one keyed request. done is closed once result and err
+// hold its outcome; request identifies what was asked, so a reused key can
+// be told apart from a retry.
+type idempotentCall struct {
+	request string
+	done    chan struct{}
+	result  string
+	err     error
+	expires time.Time
+}
+
+// idempotent runs call at most once for each client and key within
+// idempotency_key_hours and returns its result. A retry waits for the first
+// call if it is still running, and replayed reports that call was not run
+// again. A key reused for a different request fails with
+// ErrIdempotencyKeyReused. Without a key call simply runs.
+//
+// Storage failures and shutdowns are not remembered, since the transfer
+// did not happen and a retry should try again.
+func (e *EconomyPlugin) idempotent(client, key, request string, call func() (string, error)) (result string, replayed bool, err error) {
+	if key == "" || e.config.IdempotencyKeyHours <= 0 {
+		result, err = call()
+		return result, false, err
+	}
+	if len(key) > maxIdempotencyKey {
+		return "", false, ErrInvalidIdempotencyKey
+	}
+	
+	cache := &e.idempotency
+	id := client + "\x00" + key
+	now := e.now()
+	
+	cache.mutex.Lock()
+	if cache.entries == nil {
+		cache.entries = make(map[string]*idempotentCall)
+	}
+	for id, entry := range cache.entries {
+		if !entry.expires.IsZero() && now.After(entry.expires) {
+			delete(cache.entries, id)
+		}
+	}
+	
+	if entry, seen := cache.entries[id]; seen {
+		cache.mutex.Unlock()
+		if entry.request != request {
+			return "", false, ErrIdempotencyKeyReused
+		}
+		<-entry.done
+		return entry.result, true, entry.err
+	}
+	
+	entry := &idempotentCall{request: request, done: make(chan struct{})}
+	cache.entries[id] = entry
+	cache.mutex.Unlock()
+	
+	entry.result, entry.err = call()
+	
+	cache.mutex.Lock()
+	if errors.Is(entry.err, ErrStorage) || errors.Is(entry.err, ErrShuttingDown) {
+		delete(cache.entries, id)
+	} else {
+		entry.expires = e.now().Add(time.Duration(e.config.IdempotencyKeyHours) * time.Hour)
+	}
+	cache.mutex.Unlock()
+	close(entry.done)
+	
+	return entry.result, false, entry.err
+}
+
+// transferRequestKey identifies a transfer for idempotency checks.
+func transferRequestKey(from, to string, amount Money, reason string) string {
+	return strings.Join([]string{strings.ToLower(from), strings.ToLower(to), amount.String(), reason}, "\x00")
+}
@@ -0,0 +1,82 @@
+package economy
+
+import (
+	"time"
+)
+
+func (e *EconomyPlugin) startInterestScheduler() {
+	if (e.config.InterestRate <= 0 && e.config.BankInterestRate <= 0) || e.config.InterestInterval <= 0 {
+		return
+	}
+	
+	e.runPeriodically(time.Duration(e.config.InterestInterval)*time.Second, e.payInterest)
+}
+
+// payInterest credits interest_rate percent of each eligible balance.
+// Only the part of a balance up to interest_max_balance earns interest,
+// and payouts are clamped so nobody is pushed past max_balance or the
+// supply past supply_cap. Bank balances earn bank_interest_rate on the
+// same schedule.
+func (e *EconomyPlugin) payInterest() {
+	var usernames []string
+	if e.config.InterestOnlineOnly {
+		online, ok := e.getOnlinePlayers()
+		if !ok {
+			e.logger.Warn("interest_online_only is set but no online player provider is registered")
+			return
+		}
+		usernames = online
+	} else {
+		for _, account := range e.snapshotAccounts() {
+			if !account.virtual() {
+				usernames = append(usernames, account.Username)
+			}
+		}
+	}
+	
+	paid, total := 0, Money(0)
+	for _, username := range usernames {
+		if e.config.BankInterestRate > 0 {
+			if interest := e.payBankInterest(username); interest > 0 {
+				total += interest
+			}
+		}
+		
+		if e.config.InterestRate <= 0 {
+			continue
+		}
+		
+		interest := e.interestFor(e.getBalance(username))
+		if interest <= 0 {
+			continue
+		}
+		interest, err := e.allowMint(username, "interest", interest)
+		if err != nil {
+			continue
+		}
+		
+		if _, err := e.credit(username, interest, INTEREST, ReasonInterest, "Interest payout"); err != nil {
+			continue
+		}
+		paid++
+		total += interest
+	}
+	
+	if paid > 0 {
+		e.logger.Info("Paid interest", "amount", total.String(), "accounts", paid)
+	}
+}
+
+func (e *EconomyPlugin) interestFor(balance Money) Money {
+	base := balance
+	if e.config.InterestMaxBalance > 0 && base > e.config.InterestMaxBalance {
+		base = e.config.InterestMaxBalance
+	}
+	
+	interest := e.roundAmount(base.MulRate(e.config.InterestRate / 100))
+	if headroom := e.config.MaxBalance - balance; interest > headroom {
+		interest = headroom
+	}
+	
+	return interest
+}
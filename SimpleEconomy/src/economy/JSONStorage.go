@@ -0,0 +1,173 @@
+package economy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// jsonPlayersFile is the layout of players.json. Files written before
+// schema versioning hold just the accounts map.
+type jsonPlayersFile struct {
+	SchemaVersion *int                      `json:"schema_version"`
+	Accounts      map[string]*PlayerAccount `json:"accounts"`
+}
+
+type JSONStorage struct {
+	path     string
+	accounts map[string]*PlayerAccount
+	mutex    sync.RWMutex
+}
+
+func NewJSONStorage(path string) *JSONStorage {
+	return &JSONStorage{
+		path:     path,
+		accounts: make(map[string]*PlayerAccount),
+	}
+}
+
+func (s *JSONStorage) Load() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	s.accounts = make(map[string]*PlayerAccount)
+	
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	
+	// An old file whose accounts map happens to hold a player called
+	// schema_version fails to parse as a header and is read as a map.
+	version := 0
+	var header struct {
+		SchemaVersion *int `json:"schema_version"`
+	}
+	if json.Unmarshal(data, &header) == nil && header.SchemaVersion != nil {
+		version = *header.SchemaVersion
+		if err := checkSchemaVersion(s.path, version); err != nil {
+			return err
+		}
+		file := jsonPlayersFile{Accounts: s.accounts}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return err
+		}
+		if file.Accounts != nil {
+			s.accounts = file.Accounts
+		}
+	} else if err := json.Unmarshal(data, &s.accounts); err != nil {
+		return err
+	}
+	
+	reached, err := migrateSchema(s.path, version, func(version int) error {
+		return ioutil.WriteFile(schemaBackupPath(s.path, version), data, 0644)
+	}, s.migrate)
+	if err != nil {
+		return err
+	}
+	if reached != version {
+		return s.write()
+	}
+	return nil
+}
+
+// migrate applies one schema step to the loaded accounts. Amounts need
+// nothing for version 2: they are rounded as they are parsed.
+func (s *JSONStorage) migrate(step schemaMigration) error {
+	if step.version == 1 {
+		s.migrateKeys()
+	}
+	return nil
+}
+
+// migrateKeys rekeys accounts saved before accounts had UUIDs, when the map
+// was keyed by lowercased name.
+func (s *JSONStorage) migrateKeys() {
+	migrated := make(map[string]*PlayerAccount, len(s.accounts))
+	legacy := 0
+	for key, account := range s.accounts {
+		if account.UUID == "" {
+			account.UUID = OfflineUUID(account.Username)
+		}
+		if key != account.UUID {
+			legacy++
+		}
+		migrated[account.UUID] = account
+	}
+	if legacy == 0 {
+		return
+	}
+	
+	s.accounts = migrated
+	slog.Info("Migrated name-keyed accounts to UUIDs", "accounts", legacy, "path", s.path)
+}
+
+func (s *JSONStorage) Save() error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	return s.write()
+}
+
+// write saves the accounts. Callers hold s.mutex.
+func (s *JSONStorage) write() error {
+	version := storageSchemaVersion
+	data, err := json.MarshalIndent(jsonPlayersFile{SchemaVersion: &version, Accounts: s.accounts}, "", "  ")
+	if err != nil {
+		return err
+	}
+	
+	return writeFileAtomic(s.path, data, 0644)
+}
+
+func (s *JSONStorage) GetAccount(uuid string) (*PlayerAccount, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	account, exists := s.accounts[uuid]
+	if !exists {
+		return nil, nil
+	}
+	
+	stored := *account
+	return &stored, nil
+}
+
+func (s *JSONStorage) PutAccount(account *PlayerAccount) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	stored := *account
+	s.accounts[account.UUID] = &stored
+	return nil
+}
+
+func (s *JSONStorage) DeleteAccount(uuid string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	delete(s.accounts, uuid)
+	return nil
+}
+
+func (s *JSONStorage) ListAccounts() ([]*PlayerAccount, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	
+	accounts := make([]*PlayerAccount, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		stored := *account
+		accounts = append(accounts, &stored)
+	}
+	
+	return accounts, nil
+}
+
+func (s *JSONStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,346 @@
+package economy
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// JobsConfig caps what EarnFromJob pays a player each calendar day:
+// DailyCap across every job, and for each job its entry in JobCaps or
+// else DefaultJobCap. A cap of 0 means no limit.
+type JobsConfig struct {
+	DailyCap      Money            `json:"daily_cap"`
+	DefaultJobCap Money            `json:"default_job_cap"`
+	JobCaps       map[string]Money `json:"job_caps"`
+}
+
+func (c JobsConfig) jobCap(job string) Money {
+	for name, limit := range c.JobCaps {
+		if strings.EqualFold(name, job) {
+			return limit
+		}
+	}
+	return c.DefaultJobCap
+}
+
+// EarningsStore tracks what each account earned from jobs on the current
+// day. RecordEarnings must be atomic: it adds as much of amount to job as
+// the caps allow and returns what it added. A negative amount takes back
+// earnings recorded earlier the same day. Earnings from any day but the
+// latest may be forgotten.
+type EarningsStore interface {
+	RecordEarnings(uuid, day, job string, amount, jobCap, dailyCap Money) (Money, error)
+	Earnings(uuid, day string) (map[string]Money, error)
+}
+
+type jobEarnings struct {
+	Day  string           `json:"day"`
+	Jobs map[string]Money `json:"jobs"`
+}
+
+func (j jobEarnings) total() Money {
+	var total Money
+	for _, earned := range j.Jobs {
+		total += earned
+	}
+	return total
+}
+
+// earn returns the earnings after adding up to amount to job on day,
+// along with how much was added. j itself is left unchanged.
+func (j jobEarnings) earn(day, job string, amount, jobCap, dailyCap Money) (jobEarnings, Money) {
+	next := jobEarnings{Day: day, Jobs: make(map[string]Money)}
+	if j.Day == day {
+		for name, earned := range j.Jobs {
+			next.Jobs[name] = earned
+		}
+	}
+	
+	earned := next.Jobs[job]
+	if amount < 0 {
+		if -amount > earned {
+			amount = -earned
+		}
+	} else {
+		if jobCap > 0 && earned+amount > jobCap {
+			amount = clampZero(jobCap - earned)
+		}
+		if total := next.total(); dailyCap > 0 && total+amount > dailyCap {
+			amount = clampZero(dailyCap - total)
+		}
+	}
+	
+	if earned += amount; earned > 0 {
+		next.Jobs[job] = earned
+	} else {
+		delete(next.Jobs, job)
+	}
+	return next, amount
+}
+
+// JSONEarningsStore keeps every account's earnings in one file keyed by
+// UUID.
+type JSONEarningsStore struct {
+	path     string
+	earnings map[string]jobEarnings
+	mutex    sync.Mutex
+}
+
+func NewJSONEarningsStore(path string) *JSONEarningsStore {
+	return &JSONEarningsStore{path: path}
+}
+
+func (s *JSONEarningsStore) load() error {
+	if s.earnings != nil {
+		return nil
+	}
+	
+	earnings := make(map[string]jobEarnings)
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &earnings); err != nil {
+			return err
+		}
+	}
+	s.earnings = earnings
+	return nil
+}
+
+func (s *JSONEarningsStore) RecordEarnings(uuid, day, job string, amount, jobCap, dailyCap Money) (Money, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+	
+	previous, existed := s.earnings[uuid]
+	next, added := previous.earn(day, job, amount, jobCap, dailyCap)
+	if added == 0 {
+		return 0, nil
+	}
+	
+	// Only today's earnings matter, so older days are dropped as the
+	// file is rewritten.
+	for key, earnings := range s.earnings {
+		if earnings.Day != day {
+			delete(s.earnings, key)
+		}
+	}
+	s.earnings[uuid] = next
+	data, err := json.Marshal(s.earnings)
+	if err == nil {
+		err = writeFileAtomic(s.path, data, 0644)
+	}
+	if err != nil {
+		if existed {
+			s.earnings[uuid] = previous
+		} else {
+			delete(s.earnings, uuid)
+		}
+		return 0, err
+	}
+	
+	return added, nil
+}
+
+func (s *JSONEarningsStore) Earnings(uuid, day string) (map[string]Money, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	
+	earnings := s.earnings[uuid]
+	if earnings.Day != day {
+		return map[string]Money{}, nil
+	}
+	result := make(map[string]Money, len(earnings.Jobs))
+	for job, earned := range earnings.Jobs {
+		result[job] = earned
+	}
+	return result, nil
+}
+
+// EarnFromJob pays player for work done in job, keeping within the
+// earning caps in the jobs config. It returns what was actually paid,
+// which may be less than amount, or ErrEarningCapReached once the player
+// can earn nothing more from job today.
+func (e *EconomyPlugin) EarnFromJob(player, job string, amount Money) (Money, error) {
+	amount = e.roundAmount(amount)
+	if amount <= 0 {
+		return 0, ErrInvalidAmount
+	}
+	job = strings.ToLower(strings.TrimSpace(job))
+	if job == "" {
+		return 0, ErrInvalidJob
+	}
+	
+	uuid, exists := e.GetUUID(player)
+	if !exists {
+		return 0, ErrAccountNotFound
+	}
+	
+	if headroom := e.config.MaxBalance - e.getBalance(player); amount > headroom {
+		amount = headroom
+	}
+	if amount <= 0 {
+		return 0, ErrMaxBalanceExceeded
+	}
+	amount, err := e.allowMint(player, "job "+job, amount)
+	if err != nil {
+		return 0, err
+	}
+	
+	config := e.config.Jobs
+	day := e.now().Format(historyDayLayout)
+	paid, err := e.earnings.RecordEarnings(uuid, day, job, amount, config.jobCap(job), config.DailyCap)
+	if err != nil {
+		e.countStorageError("earnings")
+		return 0, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	if paid <= 0 {
+		return 0, ErrEarningCapReached
+	}
+	
+	if _, err := e.credit(player, paid, JOB, ReasonJob, "Job: "+job); err != nil {
+		if _, undoErr := e.earnings.RecordEarnings(uuid, day, job, -paid, 0, 0); undoErr != nil {
+			e.countStorageError("earnings")
+			e.logger.Warn("Failed to take back unpaid job earnings", "player", player, "job", job, "error", undoErr)
+		}
+		return 0, err
+	}
+	
+	return paid, nil
+}
+
+// GetEarnings returns what player has earned from each job today.
+func (e *EconomyPlugin) GetEarnings(player string) (map[string]Money, error) {
+	uuid, exists := e.GetUUID(player)
+	if !exists {
+		return nil, ErrAccountNotFound
+	}
+	
+	earnings, err := e.earnings.Earnings(uuid, e.now().Format(historyDayLayout))
+	if err != nil {
+		e.countStorageError("earnings")
+		return nil, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	return earnings, nil
+}
+
+func (e *EconomyPlugin) earningsCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) > 1 || len(args) == 0 && ctx.IsConsole() {
+		return e.message("earnings.usage")
+	}
+	
+	username := ctx.Name()
+	if len(args) > 0 {
+		username = args[0]
+	}
+	if !strings.EqualFold(username, ctx.Name()) && !e.hasPermission(ctx.Sender, "economy.command.earnings.others") {
+		return e.message("command.no_permission")
+	}
+	
+	earnings, err := e.GetEarnings(username)
+	if err != nil {
+		return e.message("earnings.failed", "error", e.describeError(err))
+	}
+	
+	config := e.config.Jobs
+	describe := func(limit Money) string {
+		if limit <= 0 {
+			return e.message("earnings.unlimited")
+		}
+		return e.FormatMoney(limit)
+	}
+	
+	var total Money
+	jobs := make([]string, 0, len(earnings))
+	for job, earned := range earnings {
+		jobs = append(jobs, job)
+		total += earned
+	}
+	sort.Strings(jobs)
+	
+	result := e.message("earnings.header", "player", username, "amount", e.FormatMoney(total), "cap", describe(config.DailyCap))
+	if len(jobs) == 0 {
+		return result + "\n" + e.message("earnings.none")
+	}
+	for _, job := range jobs {
+		result += "\n" + e.message("earnings.entry", "job", job, "amount", e.FormatMoney(earnings[job]), "cap", describe(config.jobCap(job)))
+	}
+	
+	return result
+}
+
+// recordEarningsSQL serves the SQL backends. The row is created first so
+// the locking read always has a row to lock.
+func recordEarningsSQL(db *sql.DB, insertIgnore, lock string, uuid, day, job string, amount, jobCap, dailyCap Money) (Money, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	
+	if _, err := tx.Exec(insertIgnore, uuid); err != nil {
+		return 0, err
+	}
+	
+	var current jobEarnings
+	var data string
+	if err := tx.QueryRow(lock, uuid).Scan(&current.Day, &data); err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal([]byte(data), &current.Jobs); err != nil {
+		return 0, err
+	}
+	
+	next, added := current.earn(day, job, amount, jobCap, dailyCap)
+	if added == 0 {
+		return 0, nil
+	}
+	
+	encoded, err := json.Marshal(next.Jobs)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE job_earnings SET day = ?, earnings = ? WHERE uuid = ?`, next.Day, string(encoded), uuid); err != nil {
+		return 0, err
+	}
+	
+	return added, tx.Commit()
+}
+
+func earningsSQL(db *sql.DB, uuid, day string) (map[string]Money, error) {
+	var current jobEarnings
+	var data string
+	err := db.QueryRow(`SELECT day, earnings FROM job_earnings WHERE uuid = ?`, uuid).Scan(&current.Day, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return map[string]Money{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	earnings := make(map[string]Money)
+	if current.Day != day {
+		return earnings, nil
+	}
+	if err := json.Unmarshal([]byte(data), &earnings); err != nil {
+		return nil, err
+	}
+	return earnings, nil
+}
@@ -0,0 +1,231 @@
+package economy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	journalPrefix = "journal-"
+	journalSuffix = ".log"
+)
+
+// accountJournal is an append-only log of account states, written as each
+// mutation commits so changes made since the last save survive a crash.
+// It is split into numbered segments: a save starts a new segment and
+// deletes the older ones once the accounts they cover are stored.
+type accountJournal struct {
+	mutex   sync.Mutex
+	folder  string
+	segment int
+	file    *os.File
+	logger  *slog.Logger
+}
+
+func openAccountJournal(folder string, logger *slog.Logger) (*accountJournal, error) {
+	segments, err := journalSegments(folder)
+	if err != nil {
+		return nil, err
+	}
+	
+	journal := &accountJournal{folder: folder, segment: 1, logger: logger}
+	if len(segments) > 0 {
+		journal.segment = segments[len(segments)-1] + 1
+	}
+	
+	return journal, nil
+}
+
+func journalSegments(folder string) ([]int, error) {
+	entries, err := os.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+	
+	segments := make([]int, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, journalPrefix) || !strings.HasSuffix(name, journalSuffix) {
+			continue
+		}
+		segment, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, journalPrefix), journalSuffix))
+		if err == nil {
+			segments = append(segments, segment)
+		}
+	}
+	
+	sort.Ints(segments)
+	return segments, nil
+}
+
+func (j *accountJournal) segmentPath(segment int) string {
+	return filepath.Join(j.folder, fmt.Sprintf("%s%06d%s", journalPrefix, segment, journalSuffix))
+}
+
+// append writes accounts to the current segment and syncs it before
+// returning, so a mutation is durable once it has been journaled.
+func (j *accountJournal) append(accounts []PlayerAccount) error {
+	var data []byte
+	for i := range accounts {
+		line, err := json.Marshal(&accounts[i])
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	
+	if j.file == nil {
+		file, err := os.OpenFile(j.segmentPath(j.segment), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		j.file = file
+	}
+	
+	if _, err := j.file.Write(data); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// rotate starts a new segment and returns the last one that is complete.
+func (j *accountJournal) rotate() int {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	
+	j.closeFile()
+	j.segment++
+	return j.segment - 1
+}
+
+// discard removes every segment up to and including upTo.
+func (j *accountJournal) discard(upTo int) {
+	segments, err := journalSegments(j.folder)
+	if err != nil {
+		j.logger.Error("Failed to list journal segments", "error", err)
+		return
+	}
+	
+	for _, segment := range segments {
+		if segment > upTo {
+			break
+		}
+		if err := os.Remove(j.segmentPath(segment)); err != nil {
+			j.logger.Error("Failed to remove journal segment", "segment", segment, "error", err)
+		}
+	}
+}
+
+// entries reads the account states in the segments up to upTo, oldest
+// first. A torn final line from a crash mid-append is ignored.
+func (j *accountJournal) entries(upTo int) ([]PlayerAccount, error) {
+	segments, err := journalSegments(j.folder)
+	if err != nil {
+		return nil, err
+	}
+	
+	accounts := make([]PlayerAccount, 0)
+	for _, segment := range segments {
+		if segment > upTo {
+			break
+		}
+		
+		file, err := os.Open(j.segmentPath(segment))
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var account PlayerAccount
+			if err := json.Unmarshal(scanner.Bytes(), &account); err != nil || account.UUID == "" {
+				continue
+			}
+			accounts = append(accounts, account)
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	
+	return accounts, nil
+}
+
+func (j *accountJournal) close() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	
+	j.closeFile()
+}
+
+func (j *accountJournal) closeFile() {
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+}
+
+// journalAccounts records the committed state of accounts. Callers hold
+// the accounts' locks and have already marked them dirty, which orders the
+// entry after any save that missed the change.
+func (e *EconomyPlugin) journalAccounts(accounts []*PlayerAccount) {
+	if e.journal == nil {
+		return
+	}
+	
+	snapshots := make([]PlayerAccount, len(accounts))
+	for i, account := range accounts {
+		snapshots[i] = *account
+	}
+	
+	if err := e.journal.append(snapshots); err != nil {
+		e.logger.Error("Failed to journal account changes", "error", err)
+	}
+}
+
+// replayJournal stores every account state journaled since the last
+// successful save. Entries are whole account states, so replaying one the
+// storage already has is harmless.
+func (e *EconomyPlugin) replayJournal() {
+	if e.journal == nil {
+		return
+	}
+	
+	segment := e.journal.rotate()
+	accounts, err := e.journal.entries(segment)
+	if err != nil {
+		e.logger.Error("Failed to read the journal", "error", err)
+		return
+	}
+	if len(accounts) == 0 {
+		e.journal.discard(segment)
+		return
+	}
+	
+	for i := range accounts {
+		if err := e.storage.PutAccount(&accounts[i]); err != nil {
+			e.logger.Error("Failed to replay journaled account", "player", accounts[i].Username, "error", err)
+			return
+		}
+	}
+	if err := e.storage.Save(); err != nil {
+		e.logger.Error("Failed to save replayed journal", "error", err)
+		return
+	}
+	
+	e.journal.discard(segment)
+	e.logger.Info("Recovered account changes from the journal", "changes", len(accounts))
+}
@@ -0,0 +1,183 @@
+package economy
+
+import (
+	"strings"
+	"time"
+)
+
+// LeaderboardCategory is what a leaderboard ranks accounts by.
+type LeaderboardCategory string
+
+const (
+	TopBalance LeaderboardCategory = "balance"
+	TopEarned  LeaderboardCategory = "earned"
+	TopSpent   LeaderboardCategory = "spent"
+)
+
+var leaderboardCategories = []LeaderboardCategory{TopBalance, TopEarned, TopSpent}
+
+func parseLeaderboardCategory(name string) (LeaderboardCategory, bool) {
+	for _, category := range leaderboardCategories {
+		if strings.EqualFold(name, string(category)) {
+			return category, true
+		}
+	}
+	return "", false
+}
+
+func (c LeaderboardCategory) value(account *PlayerAccount) Money {
+	switch c {
+	case TopEarned:
+		return account.TotalEarned
+	case TopSpent:
+		return account.TotalSpent
+	default:
+		return account.Balance
+	}
+}
+
+// before orders accounts by value, largest first, then by name.
+func (c LeaderboardCategory) before(a, b *PlayerAccount) bool {
+	if va, vb := c.value(a), c.value(b); va != vb {
+		return va > vb
+	}
+	return strings.ToLower(a.Username) < strings.ToLower(b.Username)
+}
+
+// ranked reports whether account appears on the category's leaderboard.
+// Hidden accounts never do, and the earned and spent boards leave out
+// accounts with nothing to show.
+func (e *EconomyPlugin) ranked(account *PlayerAccount, category LeaderboardCategory) bool {
+	if account.virtual() && !e.config.TopIncludeVirtual || e.hiddenAccount(account) {
+		return false
+	}
+	return category == TopBalance || category.value(account) > 0
+}
+
+// ranking is a cached leaderboard and the data version it was built from.
+type ranking struct {
+	players []*PlayerAccount
+	version uint64
+}
+
+// Leaderboard refresh policies for the leaderboard_refresh option.
+const (
+	leaderboardOnChange = "on_change"
+	leaderboardInterval = "interval"
+	leaderboardOnDemand = "on_demand"
+)
+
+func (e *EconomyPlugin) leaderboardPolicy() string {
+	switch policy := strings.ToLower(e.config.LeaderboardRefresh); policy {
+	case leaderboardInterval, leaderboardOnDemand:
+		return policy
+	default:
+		return leaderboardOnChange
+	}
+}
+
+// startLeaderboardRefresher recomputes a stale leaderboard every
+// leaderboard_refresh_seconds when the interval policy is selected, so
+// busy servers pay for ranking on a timer rather than on every /top.
+func (e *EconomyPlugin) startLeaderboardRefresher() {
+	if e.leaderboardPolicy() != leaderboardInterval || e.config.LeaderboardRefreshSeconds <= 0 {
+		return
+	}
+	
+	e.runPeriodically(time.Duration(e.config.LeaderboardRefreshSeconds)*time.Second, func() {
+		// Only leaderboards someone has asked for are kept up to date.
+		for _, category := range e.staleRankings() {
+			e.updateRanking(category)
+		}
+	})
+}
+
+// staleRankings lists the cached leaderboards older than the data.
+func (e *EconomyPlugin) staleRankings() []LeaderboardCategory {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	
+	stale := make([]LeaderboardCategory, 0, len(e.rankings))
+	for category, cached := range e.rankings {
+		if cached.version != e.dataVersion {
+			stale = append(stale, category)
+		}
+	}
+	return stale
+}
+
+// RefreshLeaderboard recomputes every ranking immediately, whatever the
+// refresh policy.
+func (e *EconomyPlugin) RefreshLeaderboard() {
+	for _, category := range leaderboardCategories {
+		e.updateRanking(category)
+	}
+}
+
+// GetLeaderboard returns a copy of the ranking for category, best first,
+// up to top_players_limit accounts.
+func (e *EconomyPlugin) GetLeaderboard(category LeaderboardCategory) ([]PlayerAccount, error) {
+	if _, ok := parseLeaderboardCategory(string(category)); !ok {
+		return nil, ErrInvalidLeaderboard
+	}
+	
+	players := e.getRanking(category)
+	result := make([]PlayerAccount, len(players))
+	for i, player := range players {
+		result[i] = *player
+	}
+	return result, nil
+}
+
+// GetRank returns username's place in category, counting from 1, or 0
+// when the account is not ranked there. Unlike the cached leaderboards
+// it is not cut off at top_players_limit.
+func (e *EconomyPlugin) GetRank(username string, category LeaderboardCategory) (int, error) {
+	if _, ok := parseLeaderboardCategory(string(category)); !ok {
+		return 0, ErrInvalidLeaderboard
+	}
+	account, exists := e.lookupAccount(username)
+	if !exists {
+		return 0, ErrAccountNotFound
+	}
+	
+	target := e.snapshotAccount(account)
+	if !e.ranked(&target, category) {
+		return 0, nil
+	}
+	
+	rank := 1
+	for _, other := range e.snapshotAccounts() {
+		if other.UUID != target.UUID && e.ranked(&other, category) && category.before(&other, &target) {
+			rank++
+		}
+	}
+	return rank, nil
+}
+
+// topPage returns one page of the leaderboard, the rank of its first entry
+// and the number of pages. page is clamped to the valid range.
+func (e *EconomyPlugin) topPage(category LeaderboardCategory, page int) ([]*PlayerAccount, int, int) {
+	players := e.getRanking(category)
+	
+	pageSize := e.config.TopPageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	
+	pages := (len(players) + pageSize - 1) / pageSize
+	if page > pages {
+		page = pages
+	}
+	if page < 1 {
+		page = 1
+	}
+	
+	start := (page - 1) * pageSize
+	end := start + pageSize
+	if end > len(players) {
+		end = len(players)
+	}
+	
+	return players[start:end], start + 1, pages
+}
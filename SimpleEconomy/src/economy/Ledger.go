@@ -0,0 +1,488 @@
+package economy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crockfordAlphabet is Crockford's base32, whose IDs sort in the order
+// their bytes do.
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newTransactionID returns a ULID: the millisecond timestamp followed by
+// random bits, so IDs sort by the time they were made. timestamp comes
+// from the plugin's clock, e.now().
+func newTransactionID(timestamp time.Time) string {
+	id := make([]byte, 16)
+	binary.BigEndian.PutUint64(id, uint64(timestamp.UnixMilli())<<16)
+	if _, err := rand.Read(id[6:]); err != nil {
+		panic(err)
+	}
+	
+	// The 128 bits are written as 26 characters of 5 bits, so the first
+	// character carries two leading zero bits.
+	var encoded [26]byte
+	for i := range encoded {
+		var digit byte
+		for bit := 5*i - 2; bit < 5*i+3; bit++ {
+			digit <<= 1
+			if bit >= 0 && id[bit/8]&(0x80>>uint(bit%8)) != 0 {
+				digit |= 1
+			}
+		}
+		encoded[i] = crockfordAlphabet[digit]
+	}
+	return string(encoded[:])
+}
+
+// TransactionStore persists the structured transaction ledger. Storage
+// backends that also implement it keep transactions next to the accounts.
+type TransactionStore interface {
+	AppendTransaction(transaction *Transaction) error
+	QueryTransactions(username string, filter TransactionFilter) ([]Transaction, error)
+}
+
+// TransactionRenamer is implemented by ledgers that can move history from
+// one name to another. ReassignTransactions rewrites every transaction
+// naming from, as sender, recipient or batch entry, to name into instead
+// and returns how many it changed.
+type TransactionRenamer interface {
+	ReassignTransactions(from, into string) (int, error)
+}
+
+// reassign renames from to into throughout the transaction and reports
+// whether anything changed.
+func (t *Transaction) reassign(from, into string) bool {
+	changed := false
+	if strings.EqualFold(t.From, from) {
+		t.From, changed = into, true
+	}
+	if strings.EqualFold(t.To, from) {
+		t.To, changed = into, true
+	}
+	for i := range t.Batch {
+		if strings.EqualFold(t.Batch[i].Player, from) {
+			t.Batch[i].Player, changed = into, true
+		}
+	}
+	return changed
+}
+
+// TransactionFilter narrows a ledger query. Zero values disable a
+// criterion; results are returned newest first. Reason matches any
+// transaction whose reason contains it, ignoring case.
+type TransactionFilter struct {
+	ID        string
+	Since     time.Time
+	Until     time.Time
+	Types     []TransactionType
+	MinAmount Money
+	MaxAmount Money
+	Reason    string
+	Offset    int
+	Limit     int
+}
+
+func (f TransactionFilter) matches(username string, transaction *Transaction) bool {
+	if username != "" && !transaction.involves(username) {
+		return false
+	}
+	
+	if f.ID != "" && transaction.ID != f.ID {
+		return false
+	}
+	
+	if !f.Since.IsZero() && transaction.Timestamp.Before(f.Since) {
+		return false
+	}
+	
+	if !f.Until.IsZero() && transaction.Timestamp.After(f.Until) {
+		return false
+	}
+	
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == transaction.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	
+	if f.MinAmount > 0 && transaction.Amount < f.MinAmount {
+		return false
+	}
+	
+	if f.MaxAmount > 0 && transaction.Amount > f.MaxAmount {
+		return false
+	}
+	
+	if f.Reason != "" && !strings.Contains(strings.ToLower(transaction.Reason), strings.ToLower(f.Reason)) {
+		return false
+	}
+	
+	return true
+}
+
+func (t *Transaction) involves(username string) bool {
+	if strings.EqualFold(t.From, username) || strings.EqualFold(t.To, username) {
+		return true
+	}
+	for _, entry := range t.Batch {
+		if strings.EqualFold(entry.Player, username) {
+			return true
+		}
+	}
+	return false
+}
+
+// JSONLinesLedger appends one JSON object per transaction to a file.
+type JSONLinesLedger struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewJSONLinesLedger(path string) *JSONLinesLedger {
+	return &JSONLinesLedger{path: path}
+}
+
+func (l *JSONLinesLedger) AppendTransaction(transaction *Transaction) error {
+	data, err := json.Marshal(transaction)
+	if err != nil {
+		return err
+	}
+	
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+func (l *JSONLinesLedger) QueryTransactions(username string, filter TransactionFilter) ([]Transaction, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	
+	matched := make([]Transaction, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var transaction Transaction
+		if err := json.Unmarshal(scanner.Bytes(), &transaction); err != nil {
+			continue
+		}
+		if filter.matches(username, &transaction) {
+			matched = append(matched, transaction)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].Timestamp.After(matched[j].Timestamp)
+	})
+	
+	return paginateTransactions(matched, filter), nil
+}
+
+// ReassignTransactions rewrites the whole file, keeping lines it cannot
+// parse as they are.
+func (l *JSONLinesLedger) ReassignTransactions(from, into string) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	
+	var rewritten bytes.Buffer
+	changed := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var transaction Transaction
+		if json.Unmarshal(line, &transaction) == nil && transaction.reassign(from, into) {
+			data, err := json.Marshal(&transaction)
+			if err != nil {
+				file.Close()
+				return 0, err
+			}
+			line = data
+			changed++
+		}
+		rewritten.Write(line)
+		rewritten.WriteByte('\n')
+	}
+	file.Close()
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	
+	return changed, writeFileAtomic(l.path, rewritten.Bytes(), 0644)
+}
+
+func paginateTransactions(transactions []Transaction, filter TransactionFilter) []Transaction {
+	if filter.Offset >= len(transactions) {
+		return nil
+	}
+	transactions = transactions[filter.Offset:]
+	
+	if filter.Limit > 0 && len(transactions) > filter.Limit {
+		transactions = transactions[:filter.Limit]
+	}
+	
+	return transactions
+}
+
+// transactionDialect is what the SQL backends do differently when
+// querying the ledger: how they store timestamps and how they search
+// reasons through their full-text index.
+type transactionDialect struct {
+	timeArg     func(time.Time) interface{}
+	reasonMatch func(text string) (string, []interface{})
+}
+
+// likePattern matches text anywhere in a column, with '!' as the escape
+// character.
+func likePattern(text string) string {
+	return "%" + strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(text) + "%"
+}
+
+// buildTransactionQuery turns a filter into a WHERE clause for the SQL
+// backends. timeArg converts timestamps to the column representation.
+func buildTransactionQuery(username string, filter TransactionFilter, dialect transactionDialect) (string, []interface{}) {
+	query := `SELECT tx_id, from_user, to_user, amount, type, timestamp, reason, previous, batch, code, postings FROM transactions WHERE 1 = 1`
+	args := make([]interface{}, 0)
+	
+	if filter.ID != "" {
+		query += ` AND tx_id = ?`
+		args = append(args, filter.ID)
+	}
+	
+	if username != "" {
+		query += ` AND (from_user = ? OR to_user = ? OR batch LIKE ? ESCAPE '!')`
+		args = append(args, username, username, batchPattern(username))
+	}
+	
+	if !filter.Since.IsZero() {
+		query += ` AND timestamp >= ?`
+		args = append(args, dialect.timeArg(filter.Since))
+	}
+	
+	if !filter.Until.IsZero() {
+		query += ` AND timestamp <= ?`
+		args = append(args, dialect.timeArg(filter.Until))
+	}
+	
+	if len(filter.Types) > 0 {
+		query += ` AND type IN (?` + strings.Repeat(`, ?`, len(filter.Types)-1) + `)`
+		for _, t := range filter.Types {
+			args = append(args, int(t))
+		}
+	}
+	
+	if filter.MinAmount > 0 {
+		query += ` AND amount >= ?`
+		args = append(args, filter.MinAmount)
+	}
+	
+	if filter.MaxAmount > 0 {
+		query += ` AND amount <= ?`
+		args = append(args, filter.MaxAmount)
+	}
+	
+	if filter.Reason != "" {
+		clause, clauseArgs := dialect.reasonMatch(filter.Reason)
+		query += ` AND ` + clause
+		args = append(args, clauseArgs...)
+	}
+	
+	query += ` ORDER BY timestamp DESC, id DESC`
+	
+	if filter.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.Limit, filter.Offset)
+	} else if filter.Offset > 0 {
+		query += ` LIMIT 1000000000 OFFSET ?`
+		args = append(args, filter.Offset)
+	}
+	
+	return query, args
+}
+
+// batchColumn encodes a transaction's batch entries for the SQL backends,
+// which keep them as JSON in a nullable column.
+func batchColumn(transaction *Transaction) (interface{}, error) {
+	if len(transaction.Batch) == 0 {
+		return nil, nil
+	}
+	
+	data, err := json.Marshal(transaction.Batch)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func scanBatch(column sql.NullString, transaction *Transaction) error {
+	if !column.Valid || column.String == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(column.String), &transaction.Batch)
+}
+
+// postingsColumn and scanPostings keep double-entry postings the same way.
+func postingsColumn(transaction *Transaction) (interface{}, error) {
+	if len(transaction.Postings) == 0 {
+		return nil, nil
+	}
+	
+	data, err := json.Marshal(transaction.Postings)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func scanPostings(column sql.NullString, transaction *Transaction) error {
+	if !column.Valid || column.String == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(column.String), &transaction.Postings)
+}
+
+// batchPattern matches the batch column of transactions listing username.
+// Both backends compare it case-insensitively, like the name columns.
+func batchPattern(username string) string {
+	escaped := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(username)
+	return `%"player":"` + escaped + `"%`
+}
+
+// reassignTransactionsSQL serves the SQL backends. Both compare names
+// case-insensitively, so plain equality finds every spelling.
+func reassignTransactionsSQL(db *sql.DB, from, into string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	
+	result, err := tx.Exec(`UPDATE transactions SET
+		from_user = CASE WHEN from_user = ? THEN ? ELSE from_user END,
+		to_user = CASE WHEN to_user = ? THEN ? ELSE to_user END
+		WHERE from_user = ? OR to_user = ?`, from, into, from, into, from, from)
+	if err != nil {
+		return 0, err
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	
+	rows, err := tx.Query(`SELECT id, batch FROM transactions WHERE batch LIKE ? ESCAPE '!'`, batchPattern(from))
+	if err != nil {
+		return 0, err
+	}
+	batches := make(map[int64]interface{})
+	for rows.Next() {
+		var id int64
+		var column sql.NullString
+		if err := rows.Scan(&id, &column); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		var transaction Transaction
+		if err := scanBatch(column, &transaction); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if transaction.reassign(from, into) {
+			if batches[id], err = batchColumn(&transaction); err != nil {
+				rows.Close()
+				return 0, err
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for id, batch := range batches {
+		if _, err := tx.Exec(`UPDATE transactions SET batch = ? WHERE id = ?`, batch, id); err != nil {
+			return 0, err
+		}
+	}
+	
+	return int(changed) + len(batches), tx.Commit()
+}
+
+func (e *EconomyPlugin) GetTransactions(username string, filter TransactionFilter) []Transaction {
+	if e.ledger == nil {
+		return nil
+	}
+	
+	transactions, err := e.ledger.QueryTransactions(username, filter)
+	if err != nil {
+		e.logger.Error("Failed to query transactions", "error", err)
+		return nil
+	}
+	
+	return transactions
+}
+
+// GetTransaction looks a transaction up by its ID.
+func (e *EconomyPlugin) GetTransaction(id string) (Transaction, error) {
+	if id == "" {
+		return Transaction{}, ErrTransactionNotFound
+	}
+	if e.ledger == nil {
+		return Transaction{}, ErrStorage
+	}
+	
+	transactions, err := e.ledger.QueryTransactions("", TransactionFilter{ID: strings.ToUpper(id), Limit: 1})
+	if err != nil {
+		return Transaction{}, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	if len(transactions) == 0 {
+		return Transaction{}, ErrTransactionNotFound
+	}
+	return transactions[0], nil
+}
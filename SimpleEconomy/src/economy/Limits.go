@@ -0,0 +1,177 @@
+package economy
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransferLimits bound what a player may send with /pay. Zero disables a
+// limit.
+type TransferLimits struct {
+	MaxPerPayment   Money `json:"max_per_payment"`
+	MaxPerHour      Money `json:"max_per_hour"`
+	MaxPerDay       Money `json:"max_per_day"`
+	CooldownSeconds int   `json:"cooldown_seconds"`
+}
+
+// TransferLimitConfig holds the default limits and per-group overrides.
+// Groups are checked in order and the first whose permission the player
+// holds replaces the defaults entirely. Players with
+// economy.limits.bypass are never limited.
+type TransferLimitConfig struct {
+	TransferLimits
+	Groups []TransferLimitGroup `json:"groups"`
+}
+
+// TransferLimitGroup is an override tier. Permission defaults to
+// economy.limits.<name>.
+type TransferLimitGroup struct {
+	Name       string `json:"name"`
+	Permission string `json:"permission"`
+	TransferLimits
+}
+
+func (g TransferLimitGroup) permission() string {
+	if g.Permission != "" {
+		return g.Permission
+	}
+	return "economy.limits." + g.Name
+}
+
+// TransferLimitError explains which limit a payment ran into. It unwraps
+// to ErrPaymentTooLarge, ErrTransferLimitExceeded or ErrPaymentCooldown.
+type TransferLimitError struct {
+	Err       error
+	Limit     Money
+	Remaining Money
+	Period    string
+	Wait      time.Duration
+}
+
+func (e *TransferLimitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransferLimitError) Unwrap() error {
+	return e.Err
+}
+
+type transferUsage struct {
+	at     time.Time
+	amount Money
+}
+
+// transferLimiter remembers each player's payments over the last day. It
+// is kept in memory only, so limits start over when the plugin restarts.
+type transferLimiter struct {
+	mutex    sync.Mutex
+	payments map[string][]transferUsage
+}
+
+func (e *EconomyPlugin) transferLimitsFor(sender CommandSender) TransferLimits {
+	for _, group := range e.config.TransferLimits.Groups {
+		if e.hasPermission(sender, group.permission()) {
+			return group.TransferLimits
+		}
+	}
+	
+	return e.config.TransferLimits.TransferLimits
+}
+
+// reservePayment checks amount against the sender's limits and counts it
+// straight away, so two payments racing each other cannot both slip under
+// a limit. The returned cancel function uncounts it if the payment then
+// fails.
+func (e *EconomyPlugin) reservePayment(sender CommandSender, amount Money) (func(), error) {
+	if e.hasPermission(sender, "economy.limits.bypass") {
+		return func() {}, nil
+	}
+	
+	limits := e.transferLimitsFor(sender)
+	if limits == (TransferLimits{}) {
+		return func() {}, nil
+	}
+	if limits.MaxPerPayment > 0 && amount > limits.MaxPerPayment {
+		return nil, &TransferLimitError{Err: ErrPaymentTooLarge, Limit: limits.MaxPerPayment}
+	}
+	
+	key := strings.ToLower(sender.Name())
+	now := e.now()
+	
+	e.limiter.mutex.Lock()
+	defer e.limiter.mutex.Unlock()
+	
+	if e.limiter.payments == nil {
+		e.limiter.payments = make(map[string][]transferUsage)
+	}
+	
+	payments := e.limiter.payments[key]
+	for len(payments) > 0 && now.Sub(payments[0].at) >= 24*time.Hour {
+		payments = payments[1:]
+	}
+	
+	if len(payments) > 0 && limits.CooldownSeconds > 0 {
+		cooldown := time.Duration(limits.CooldownSeconds) * time.Second
+		if wait := cooldown - now.Sub(payments[len(payments)-1].at); wait > 0 {
+			e.limiter.payments[key] = payments
+			return nil, &TransferLimitError{Err: ErrPaymentCooldown, Wait: wait}
+		}
+	}
+	
+	var hour, day Money
+	for _, payment := range payments {
+		day += payment.amount
+		if now.Sub(payment.at) < time.Hour {
+			hour += payment.amount
+		}
+	}
+	
+	var exceeded *TransferLimitError
+	switch {
+	case limits.MaxPerHour > 0 && hour+amount > limits.MaxPerHour:
+		exceeded = &TransferLimitError{Err: ErrTransferLimitExceeded, Limit: limits.MaxPerHour, Remaining: limits.MaxPerHour - hour, Period: "hour"}
+	case limits.MaxPerDay > 0 && day+amount > limits.MaxPerDay:
+		exceeded = &TransferLimitError{Err: ErrTransferLimitExceeded, Limit: limits.MaxPerDay, Remaining: limits.MaxPerDay - day, Period: "day"}
+	}
+	if exceeded != nil {
+		if exceeded.Remaining < 0 {
+			exceeded.Remaining = 0
+		}
+		e.limiter.payments[key] = payments
+		return nil, exceeded
+	}
+	
+	usage := transferUsage{at: now, amount: amount}
+	e.limiter.payments[key] = append(payments, usage)
+	
+	return func() {
+		e.limiter.mutex.Lock()
+		defer e.limiter.mutex.Unlock()
+		
+		payments := e.limiter.payments[key]
+		for i := range payments {
+			if payments[i] == usage {
+				e.limiter.payments[key] = append(payments[:i:i], payments[i+1:]...)
+				break
+			}
+		}
+	}, nil
+}
+
+func (e *EconomyPlugin) describeTransferLimit(err *TransferLimitError) string {
+	switch err.Err {
+	case ErrPaymentTooLarge:
+		return e.message("error.payment_too_large", "max", e.FormatMoney(err.Limit))
+	case ErrPaymentCooldown:
+		return e.message("error.payment_cooldown", "time", formatWait(err.Wait))
+	default:
+		return e.message("error.transfer_limit", "max", e.FormatMoney(err.Limit),
+			"remaining", e.FormatMoney(err.Remaining), "period", err.Period)
+	}
+}
+
+// formatWait rounds a wait up to whole seconds, e.g. 1m5s.
+func formatWait(wait time.Duration) string {
+	return ((wait + time.Second - 1) / time.Second * time.Second).String()
+}
@@ -0,0 +1,456 @@
+package economy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LinkConfig limits how many child accounts a parent may link, and how
+// long a child has to accept a link. Links are one level deep: a child
+// cannot be a parent too.
+type LinkConfig struct {
+	MaxChildren        int `json:"max_children"`
+	OfferExpiryMinutes int `json:"offer_expiry_minutes"`
+}
+
+// AccountLink ties a child account, such as an alt character, to its
+// parent. The parent may pay it an allowance every AllowanceHours and cap
+// what it spends on payments and purchases a day. A zero Allowance or
+// DailyLimit is none.
+type AccountLink struct {
+	Parent         string    `json:"parent"`
+	Child          string    `json:"child"`
+	Allowance      Money     `json:"allowance"`
+	AllowanceHours int       `json:"allowance_hours"`
+	NextAllowance  time.Time `json:"next_allowance"`
+	DailyLimit     Money     `json:"daily_limit"`
+	SpentToday     Money     `json:"spent_today"`
+	SpendDay       time.Time `json:"spend_day"`
+	Created        time.Time `json:"created"`
+}
+
+const allowanceCheckInterval = time.Minute
+
+type linkOffer struct {
+	parent  string
+	expires time.Time
+}
+
+// linkBook holds every AccountLink by child, and the link offers waiting
+// for a child to accept. Its mutex is only ever taken after account shard
+// locks, never before.
+type linkBook struct {
+	mutex  sync.Mutex
+	links  map[string]*AccountLink
+	offers map[string]linkOffer
+}
+
+func (e *EconomyPlugin) linksPath() string {
+	return filepath.Join(e.dataFolder, "account_links.json")
+}
+
+func (e *EconomyPlugin) loadLinks() {
+	e.links.mutex.Lock()
+	defer e.links.mutex.Unlock()
+	
+	e.links.links = make(map[string]*AccountLink)
+	e.links.offers = make(map[string]linkOffer)
+	
+	data, err := ioutil.ReadFile(e.linksPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Error("Failed to read account links", "error", err)
+		return
+	}
+	
+	if err := json.Unmarshal(data, &e.links.links); err != nil {
+		e.logger.Error("Failed to parse account links", "error", err)
+	}
+}
+
+// saveLinks must be called with e.links.mutex held.
+func (e *EconomyPlugin) saveLinks() {
+	data, err := json.MarshalIndent(e.links.links, "", "  ")
+	if err != nil {
+		e.logger.Error("Failed to marshal account links", "error", err)
+		return
+	}
+	
+	if err := writeFileAtomic(e.linksPath(), data, 0644); err != nil {
+		e.logger.Error("Failed to write account links", "error", err)
+		e.countStorageError("links")
+	}
+}
+
+// childCount counts username's child accounts. Callers hold
+// e.links.mutex.
+func (e *EconomyPlugin) childCount(username string) int {
+	count := 0
+	for _, link := range e.links.links {
+		if strings.EqualFold(link.Parent, username) {
+			count++
+		}
+	}
+	return count
+}
+
+// OfferLink asks child to become parent's child account. The link is made
+// once the child accepts with AcceptLink.
+func (e *EconomyPlugin) OfferLink(parent, child string) error {
+	if strings.EqualFold(parent, child) || IsVirtualAccount(parent) || IsVirtualAccount(child) {
+		return ErrInvalidLink
+	}
+	if !e.hasAccount(parent) || !e.hasAccount(child) {
+		return ErrAccountNotFound
+	}
+	
+	e.links.mutex.Lock()
+	defer e.links.mutex.Unlock()
+	
+	childKey := strings.ToLower(child)
+	if _, linked := e.links.links[childKey]; linked {
+		return ErrLinkExists
+	}
+	if _, linked := e.links.links[strings.ToLower(parent)]; linked || e.childCount(child) > 0 {
+		return ErrInvalidLink
+	}
+	if e.childCount(parent) >= e.config.Links.MaxChildren {
+		return ErrLinkLimit
+	}
+	
+	expiry := time.Duration(e.config.Links.OfferExpiryMinutes) * time.Minute
+	e.links.offers[childKey] = linkOffer{parent: parent, expires: e.now().Add(expiry)}
+	return nil
+}
+
+// AcceptLink makes child the child account of parent, who must have
+// offered the link.
+func (e *EconomyPlugin) AcceptLink(child, parent string) (AccountLink, error) {
+	e.links.mutex.Lock()
+	defer e.links.mutex.Unlock()
+	
+	childKey := strings.ToLower(child)
+	offer, exists := e.links.offers[childKey]
+	if !exists || !strings.EqualFold(offer.parent, parent) || e.now().After(offer.expires) {
+		return AccountLink{}, ErrLinkNotFound
+	}
+	delete(e.links.offers, childKey)
+	
+	// Either side may have been linked elsewhere since the offer.
+	if _, linked := e.links.links[childKey]; linked {
+		return AccountLink{}, ErrLinkExists
+	}
+	if _, linked := e.links.links[strings.ToLower(offer.parent)]; linked || e.childCount(child) > 0 {
+		return AccountLink{}, ErrInvalidLink
+	}
+	if e.childCount(offer.parent) >= e.config.Links.MaxChildren {
+		return AccountLink{}, ErrLinkLimit
+	}
+	
+	link := &AccountLink{Parent: offer.parent, Child: child, Created: e.now()}
+	e.links.links[childKey] = link
+	e.saveLinks()
+	
+	return *link, nil
+}
+
+// Unlink removes the link between player and other, whichever of them is
+// the parent.
+func (e *EconomyPlugin) Unlink(player, other string) error {
+	e.links.mutex.Lock()
+	defer e.links.mutex.Unlock()
+	
+	for _, key := range []string{strings.ToLower(other), strings.ToLower(player)} {
+		link, exists := e.links.links[key]
+		if exists && (strings.EqualFold(link.Parent, player) || strings.EqualFold(link.Parent, other)) {
+			delete(e.links.links, key)
+			e.saveLinks()
+			return nil
+		}
+	}
+	return ErrLinkNotFound
+}
+
+// parentLink returns parent's link to child. Callers hold e.links.mutex.
+func (e *EconomyPlugin) parentLink(parent, child string) (*AccountLink, error) {
+	link, exists := e.links.links[strings.ToLower(child)]
+	if !exists {
+		return nil, ErrLinkNotFound
+	}
+	if !strings.EqualFold(link.Parent, parent) {
+		return nil, ErrNotAuthorized
+	}
+	return link, nil
+}
+
+// SetAllowance has parent pay child amount every hours hours, starting
+// now. A zero amount stops the allowance.
+func (e *EconomyPlugin) SetAllowance(parent, child string, amount Money, hours int) error {
+	if amount < 0 || (amount > 0 && hours <= 0) {
+		return ErrInvalidAmount
+	}
+	
+	e.links.mutex.Lock()
+	defer e.links.mutex.Unlock()
+	
+	link, err := e.parentLink(parent, child)
+	if err != nil {
+		return err
+	}
+	link.Allowance, link.AllowanceHours = amount, hours
+	link.NextAllowance = e.now()
+	e.saveLinks()
+	
+	return nil
+}
+
+// SetSpendLimit caps what child may spend a day. A zero limit removes
+// the cap.
+func (e *EconomyPlugin) SetSpendLimit(parent, child string, limit Money) error {
+	if limit < 0 {
+		return ErrInvalidAmount
+	}
+	
+	e.links.mutex.Lock()
+	defer e.links.mutex.Unlock()
+	
+	link, err := e.parentLink(parent, child)
+	if err != nil {
+		return err
+	}
+	link.DailyLimit = limit
+	e.saveLinks()
+	
+	return nil
+}
+
+// GetLinks returns the links username is the parent or child of, by
+// child.
+func (e *EconomyPlugin) GetLinks(username string) []AccountLink {
+	e.links.mutex.Lock()
+	defer e.links.mutex.Unlock()
+	
+	links := make([]AccountLink, 0)
+	for _, link := range e.links.links {
+		if strings.EqualFold(link.Parent, username) || strings.EqualFold(link.Child, username) {
+			links = append(links, *link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return strings.ToLower(links[i].Child) < strings.ToLower(links[j].Child)
+	})
+	
+	return links
+}
+
+// chargeSpend counts amount against username's daily spend limit if they
+// are a child account, failing with ErrSpendLimitExceeded past it. A
+// payment that fails after being charged gives it back with refundSpend.
+func (e *EconomyPlugin) chargeSpend(username string, amount Money) error {
+	e.links.mutex.Lock()
+	defer e.links.mutex.Unlock()
+	
+	link, exists := e.links.links[strings.ToLower(username)]
+	if !exists || link.DailyLimit <= 0 {
+		return nil
+	}
+	
+	today := e.now().Truncate(24 * time.Hour)
+	if !link.SpendDay.Equal(today) {
+		link.SpendDay = today
+		link.SpentToday = 0
+	}
+	if link.SpentToday+amount > link.DailyLimit {
+		return ErrSpendLimitExceeded
+	}
+	link.SpentToday += amount
+	e.saveLinks()
+	
+	return nil
+}
+
+// refundSpend takes back a chargeSpend of amount. Nothing is given back
+// once the day it was charged on is over.
+func (e *EconomyPlugin) refundSpend(username string, amount Money) {
+	e.links.mutex.Lock()
+	defer e.links.mutex.Unlock()
+	
+	link, exists := e.links.links[strings.ToLower(username)]
+	if !exists || link.DailyLimit <= 0 || !link.SpendDay.Equal(e.now().Truncate(24*time.Hour)) {
+		return
+	}
+	link.SpentToday = clampZero(link.SpentToday - amount)
+	e.saveLinks()
+}
+
+// reassignLinks moves from's links to into, dropping any that would link
+// into to itself.
+func (e *EconomyPlugin) reassignLinks(from, into string) {
+	e.links.mutex.Lock()
+	defer e.links.mutex.Unlock()
+	
+	changed := false
+	for key, link := range e.links.links {
+		if strings.EqualFold(link.Parent, from) {
+			link.Parent, changed = into, true
+		}
+		if strings.EqualFold(link.Child, from) {
+			delete(e.links.links, key)
+			link.Child, changed = into, true
+			e.links.links[strings.ToLower(into)] = link
+		}
+		if strings.EqualFold(link.Parent, link.Child) {
+			delete(e.links.links, strings.ToLower(link.Child))
+		}
+	}
+	if changed {
+		e.saveLinks()
+	}
+}
+
+func (e *EconomyPlugin) startAllowances() {
+	e.runPeriodically(allowanceCheckInterval, e.payAllowances)
+}
+
+// payAllowances pays every allowance that is due. One the parent cannot
+// afford is skipped until the next is due.
+func (e *EconomyPlugin) payAllowances() {
+	now := e.now()
+	due := make([]AccountLink, 0)
+	
+	e.links.mutex.Lock()
+	for _, link := range e.links.links {
+		if link.Allowance > 0 && !now.Before(link.NextAllowance) {
+			due = append(due, *link)
+			for !now.Before(link.NextAllowance) {
+				link.NextAllowance = link.NextAllowance.Add(time.Duration(link.AllowanceHours) * time.Hour)
+			}
+		}
+	}
+	if len(due) > 0 {
+		e.saveLinks()
+	}
+	e.links.mutex.Unlock()
+	
+	for _, link := range due {
+		if _, _, err := e.transfer(link.Parent, link.Child, link.Allowance, 0, "Allowance from "+link.Parent); err != nil {
+			e.logger.Warn("Failed to pay allowance", "parent", link.Parent, "child", link.Child, "error", err)
+			continue
+		}
+		e.logger.Info("Paid allowance", "parent", link.Parent, "child", link.Child, "amount", link.Allowance.String())
+	}
+}
+
+func (e *EconomyPlugin) linkCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) == 0 {
+		return e.message("link.usage")
+	}
+	player := ctx.Name()
+	
+	switch action := strings.ToLower(args[0]); action {
+	case "add":
+		if len(args) != 2 {
+			return e.message("link.usage")
+		}
+		child, err := e.ResolveName(args[1])
+		if err == nil {
+			err = e.OfferLink(player, child)
+		}
+		if err != nil {
+			return e.message("link.failed", "error", e.describeError(err))
+		}
+		if messenger := e.getMessenger(); messenger != nil && e.isOnline(child) {
+			messenger.SendMessage(child, e.message("link.offer", "player", player))
+		}
+		return e.message("link.offered", "player", child)
+		
+	case "accept":
+		if len(args) != 2 {
+			return e.message("link.usage")
+		}
+		link, err := e.AcceptLink(player, args[1])
+		if err != nil {
+			return e.message("link.failed", "error", e.describeError(err))
+		}
+		return e.message("link.accepted", "player", link.Parent)
+		
+	case "remove":
+		if len(args) != 2 {
+			return e.message("link.usage")
+		}
+		if err := e.Unlink(player, args[1]); err != nil {
+			return e.message("link.failed", "error", e.describeError(err))
+		}
+		return e.message("link.removed", "player", args[1])
+		
+	case "allowance":
+		if len(args) != 3 && len(args) != 4 {
+			return e.message("link.allowance_usage")
+		}
+		amount, err := e.parseAmount(args[2])
+		if err != nil {
+			return e.message("error.invalid_amount")
+		}
+		hours := 24
+		if len(args) == 4 {
+			if hours, err = strconv.Atoi(args[3]); err != nil || hours <= 0 {
+				return e.message("link.bad_hours")
+			}
+		}
+		if err := e.SetAllowance(player, args[1], amount, hours); err != nil {
+			return e.message("link.failed", "error", e.describeError(err))
+		}
+		if amount == 0 {
+			return e.message("link.allowance_stopped", "player", args[1])
+		}
+		return e.message("link.allowance_set", "player", args[1], "amount", e.FormatMoney(amount), "hours", strconv.Itoa(hours))
+		
+	case "limit":
+		if len(args) != 3 {
+			return e.message("link.limit_usage")
+		}
+		limit, err := e.parseAmount(args[2])
+		if err != nil {
+			return e.message("error.invalid_amount")
+		}
+		if err := e.SetSpendLimit(player, args[1], limit); err != nil {
+			return e.message("link.failed", "error", e.describeError(err))
+		}
+		if limit == 0 {
+			return e.message("link.limit_removed", "player", args[1])
+		}
+		return e.message("link.limit_set", "player", args[1], "amount", e.FormatMoney(limit))
+		
+	case "list":
+		links := e.GetLinks(player)
+		if len(links) == 0 {
+			return e.message("link.none")
+		}
+		result := e.message("link.header")
+		for _, link := range links {
+			allowance, limit := e.message("link.no_allowance"), e.message("link.no_limit")
+			if link.Allowance > 0 {
+				allowance = e.message("link.allowance", "amount", e.FormatMoney(link.Allowance), "hours", strconv.Itoa(link.AllowanceHours))
+			}
+			if link.DailyLimit > 0 {
+				limit = e.message("link.limit", "amount", e.FormatMoney(link.DailyLimit))
+			}
+			result += "\n" + e.message("link.entry", "parent", link.Parent, "child", link.Child,
+				"allowance", allowance, "limit", limit)
+		}
+		return result
+		
+	default:
+		return e.message("link.usage")
+	}
+}
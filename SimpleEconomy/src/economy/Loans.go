@@ -0,0 +1,547 @@
+package economy
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoanConfig bounds the terms players may offer each other. Offers not
+// accepted within OfferExpiryMinutes are withdrawn.
+type LoanConfig struct {
+	Enabled            bool    `json:"enabled"`
+	MaxInterest        float64 `json:"max_interest_percent"`
+	MaxDays            int     `json:"max_days"`
+	OfferExpiryMinutes int     `json:"offer_expiry_minutes"`
+}
+
+type LoanStatus string
+
+const (
+	LoanOffered   LoanStatus = "offered"
+	LoanActive    LoanStatus = "active"
+	LoanDefaulted LoanStatus = "defaulted"
+)
+
+const loanCheckInterval = time.Minute
+
+// Loan is money one player lends another. Owed is the principal plus flat
+// interest for the whole term. A loan still outstanding when it falls due
+// is collected from the borrower's wallet; whatever cannot be collected
+// leaves it defaulted, and collection keeps trying until it is repaid.
+// Repaid loans are removed.
+type Loan struct {
+	ID        int        `json:"id"`
+	Lender    string     `json:"lender"`
+	Borrower  string     `json:"borrower"`
+	Principal Money      `json:"principal"`
+	Interest  float64    `json:"interest_percent"`
+	Days      int        `json:"days"`
+	Owed      Money      `json:"owed"`
+	Repaid    Money      `json:"repaid"`
+	Status    LoanStatus `json:"status"`
+	Offered   time.Time  `json:"offered"`
+	Due       time.Time  `json:"due,omitempty"`
+}
+
+func (l *Loan) Outstanding() Money {
+	return l.Owed - l.Repaid
+}
+
+// loanBook holds every Loan. busy marks loans being paid out or repaid.
+// Its mutex is never held while accounts are locked.
+type loanBook struct {
+	mutex  sync.Mutex
+	loans  map[int]*Loan
+	busy   map[int]bool
+	nextID int
+}
+
+func (e *EconomyPlugin) loansPath() string {
+	return filepath.Join(e.dataFolder, "loans.json")
+}
+
+func (e *EconomyPlugin) loadLoans() {
+	e.loans.mutex.Lock()
+	defer e.loans.mutex.Unlock()
+	
+	e.loans.loans = make(map[int]*Loan)
+	e.loans.busy = make(map[int]bool)
+	e.loans.nextID = 1
+	
+	data, err := ioutil.ReadFile(e.loansPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Error("Failed to read loans", "error", err)
+		return
+	}
+	
+	if err := json.Unmarshal(data, &e.loans.loans); err != nil {
+		e.logger.Error("Failed to parse loans", "error", err)
+	}
+	for id := range e.loans.loans {
+		if id >= e.loans.nextID {
+			e.loans.nextID = id + 1
+		}
+	}
+}
+
+// saveLoans must be called with e.loans.mutex held.
+func (e *EconomyPlugin) saveLoans() {
+	data, err := json.MarshalIndent(e.loans.loans, "", "  ")
+	if err != nil {
+		e.logger.Error("Failed to marshal loans", "error", err)
+		return
+	}
+	
+	if err := writeFileAtomic(e.loansPath(), data, 0644); err != nil {
+		e.logger.Error("Failed to write loans", "error", err)
+		e.countStorageError("loans")
+	}
+}
+
+// pendingOffer must be called with e.loans.mutex held.
+func (e *EconomyPlugin) pendingOffer(lender, borrower string) *Loan {
+	for _, loan := range e.loans.loans {
+		if loan.Status == LoanOffered && strings.EqualFold(loan.Lender, lender) && strings.EqualFold(loan.Borrower, borrower) {
+			return loan
+		}
+	}
+	return nil
+}
+
+// OfferLoan offers borrower amount for days at a flat interest percent.
+// No money moves until the borrower accepts.
+func (e *EconomyPlugin) OfferLoan(lender, borrower string, amount Money, interest float64, days int) (Loan, error) {
+	config := e.config.Loans
+	if !config.Enabled {
+		return Loan{}, ErrLoansDisabled
+	}
+	if amount <= 0 {
+		return Loan{}, ErrInvalidAmount
+	}
+	if interest < 0 || interest > config.MaxInterest || days < 1 || (config.MaxDays > 0 && days > config.MaxDays) {
+		return Loan{}, ErrInvalidLoanTerms
+	}
+	if strings.EqualFold(lender, borrower) {
+		return Loan{}, ErrSelfTransfer
+	}
+	if !e.hasAccount(borrower) {
+		return Loan{}, ErrAccountNotFound
+	}
+	if e.getSpendable(lender) < amount {
+		return Loan{}, ErrInsufficientFunds
+	}
+	
+	e.loans.mutex.Lock()
+	defer e.loans.mutex.Unlock()
+	
+	if e.pendingOffer(lender, borrower) != nil {
+		return Loan{}, ErrLoanExists
+	}
+	
+	loan := &Loan{
+		ID:        e.loans.nextID,
+		Lender:    lender,
+		Borrower:  borrower,
+		Principal: amount,
+		Interest:  interest,
+		Days:      days,
+		Owed:      amount + e.roundAmount(amount.MulRate(interest/100)),
+		Status:    LoanOffered,
+		Offered:   e.now(),
+	}
+	e.loans.nextID++
+	e.loans.loans[loan.ID] = loan
+	e.saveLoans()
+	
+	return *loan, nil
+}
+
+// AcceptLoan pays out the loan lender offered borrower and starts its
+// term.
+func (e *EconomyPlugin) AcceptLoan(borrower, lender string) (Loan, error) {
+	if !e.config.Loans.Enabled {
+		return Loan{}, ErrLoansDisabled
+	}
+	
+	e.loans.mutex.Lock()
+	offer := e.pendingOffer(lender, borrower)
+	if offer == nil || e.offerExpired(offer) {
+		e.loans.mutex.Unlock()
+		return Loan{}, ErrLoanNotFound
+	}
+	e.loans.busy[offer.ID] = true
+	accepted := *offer
+	e.loans.mutex.Unlock()
+	
+	var lenderOld, borrowerOld Money
+	err := e.mutateAccounts([]string{lender, borrower}, func(accounts []*PlayerAccount) error {
+		lenderAccount, borrowerAccount := accounts[0], accounts[1]
+		if lenderAccount.spendable() < accepted.Principal {
+			return ErrInsufficientFunds
+		}
+		if borrowerAccount.Balance+accepted.Principal > e.config.MaxBalance {
+			return ErrMaxBalanceExceeded
+		}
+		
+		lenderOld, borrowerOld = lenderAccount.Balance, borrowerAccount.Balance
+		lenderAccount.Balance -= accepted.Principal
+		lenderAccount.TotalSpent += accepted.Principal
+		borrowerAccount.Balance += accepted.Principal
+		borrowerAccount.TotalEarned += accepted.Principal
+		return nil
+	})
+	
+	e.loans.mutex.Lock()
+	delete(e.loans.busy, accepted.ID)
+	if loan, exists := e.loans.loans[accepted.ID]; exists && err == nil {
+		loan.Status = LoanActive
+		loan.Due = e.now().AddDate(0, 0, loan.Days)
+		accepted = *loan
+		e.saveLoans()
+	}
+	e.loans.mutex.Unlock()
+	if err != nil {
+		return Loan{}, err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(lender, lenderOld, lenderOld-accepted.Principal, LOAN)
+	e.fireBalanceChange(borrower, borrowerOld, borrowerOld+accepted.Principal, LOAN)
+	e.recordTransaction(&Transaction{
+		From:      lender,
+		To:        borrower,
+		Amount:    accepted.Principal,
+		Type:      LOAN,
+		Timestamp: e.now(),
+		Reason:    "Loan #" + strconv.Itoa(accepted.ID),
+	})
+	
+	return accepted, nil
+}
+
+// DeclineLoan withdraws the offer lender made borrower.
+func (e *EconomyPlugin) DeclineLoan(borrower, lender string) error {
+	e.loans.mutex.Lock()
+	defer e.loans.mutex.Unlock()
+	
+	loan := e.pendingOffer(lender, borrower)
+	if loan == nil || e.loans.busy[loan.ID] {
+		return ErrLoanNotFound
+	}
+	
+	delete(e.loans.loans, loan.ID)
+	e.saveLoans()
+	return nil
+}
+
+// RepayLoan pays amount towards one of borrower's loans, or everything
+// still owed when amount is zero. It returns what was paid and the loan as
+// it stands after the payment.
+func (e *EconomyPlugin) RepayLoan(borrower string, id int, amount Money) (Money, Loan, error) {
+	if amount < 0 {
+		return 0, Loan{}, ErrInvalidAmount
+	}
+	
+	return e.collectLoan(id, borrower, amount, false)
+}
+
+// collectLoan moves up to limit, or everything owed when limit is zero,
+// from the borrower to the lender. A partial collection takes whatever
+// the borrower has and the lender has room for instead of failing.
+func (e *EconomyPlugin) collectLoan(id int, borrower string, limit Money, partial bool) (Money, Loan, error) {
+	e.loans.mutex.Lock()
+	loan, exists := e.loans.loans[id]
+	if exists && (loan.Status == LoanOffered || e.loans.busy[id] || !strings.EqualFold(loan.Borrower, borrower)) {
+		exists = false
+	}
+	var snapshot Loan
+	if exists {
+		snapshot = *loan
+		e.loans.busy[id] = true
+	}
+	e.loans.mutex.Unlock()
+	if !exists {
+		return 0, Loan{}, ErrLoanNotFound
+	}
+	lender := snapshot.Lender
+	
+	var amount, borrowerOld, lenderOld Money
+	err := e.mutateAccounts([]string{borrower, lender}, func(accounts []*PlayerAccount) error {
+		borrowerAccount, lenderAccount := accounts[0], accounts[1]
+		amount = snapshot.Outstanding()
+		if limit > 0 && limit < amount {
+			amount = limit
+		}
+		
+		headroom := e.config.MaxBalance - lenderAccount.Balance
+		if partial {
+			if amount > borrowerAccount.spendable() {
+				amount = borrowerAccount.spendable()
+			}
+			if amount > headroom {
+				amount = headroom
+			}
+			if amount <= 0 {
+				return ErrInsufficientFunds
+			}
+		} else {
+			if borrowerAccount.spendable() < amount {
+				return ErrInsufficientFunds
+			}
+			if amount > headroom {
+				return ErrMaxBalanceExceeded
+			}
+		}
+		
+		borrowerOld, lenderOld = borrowerAccount.Balance, lenderAccount.Balance
+		borrowerAccount.Balance -= amount
+		borrowerAccount.TotalSpent += amount
+		lenderAccount.Balance += amount
+		lenderAccount.TotalEarned += amount
+		return nil
+	})
+	
+	result := snapshot
+	e.loans.mutex.Lock()
+	delete(e.loans.busy, id)
+	if loan, exists := e.loans.loans[id]; exists && err == nil {
+		loan.Repaid += amount
+		result = *loan
+		if loan.Outstanding() <= 0 {
+			delete(e.loans.loans, id)
+		}
+		e.saveLoans()
+	}
+	e.loans.mutex.Unlock()
+	if err != nil {
+		return 0, Loan{}, err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(borrower, borrowerOld, borrowerOld-amount, LOAN_REPAYMENT)
+	e.fireBalanceChange(lender, lenderOld, lenderOld+amount, LOAN_REPAYMENT)
+	e.recordTransaction(&Transaction{
+		From:      borrower,
+		To:        lender,
+		Amount:    amount,
+		Type:      LOAN_REPAYMENT,
+		Timestamp: e.now(),
+		Reason:    "Loan #" + strconv.Itoa(id) + " repayment",
+	})
+	
+	return amount, result, nil
+}
+
+// offerExpired must be called with e.loans.mutex held.
+func (e *EconomyPlugin) offerExpired(loan *Loan) bool {
+	expiry := e.config.Loans.OfferExpiryMinutes
+	return expiry > 0 && e.now().Sub(loan.Offered) > time.Duration(expiry)*time.Minute
+}
+
+// GetLoans returns the loans username has given or taken, oldest first,
+// including offers still waiting for an answer.
+func (e *EconomyPlugin) GetLoans(username string) []Loan {
+	e.loans.mutex.Lock()
+	defer e.loans.mutex.Unlock()
+	
+	loans := make([]Loan, 0)
+	for _, loan := range e.loans.loans {
+		if strings.EqualFold(loan.Lender, username) || strings.EqualFold(loan.Borrower, username) {
+			loans = append(loans, *loan)
+		}
+	}
+	sort.Slice(loans, func(i, j int) bool {
+		return loans[i].ID < loans[j].ID
+	})
+	
+	return loans
+}
+
+// GetDebt returns what username still owes on accepted loans.
+func (e *EconomyPlugin) GetDebt(username string) (Money, error) {
+	if !e.hasAccount(username) {
+		return 0, ErrAccountNotFound
+	}
+	
+	var debt Money
+	for _, loan := range e.GetLoans(username) {
+		if loan.Status != LoanOffered && strings.EqualFold(loan.Borrower, username) {
+			debt += loan.Outstanding()
+		}
+	}
+	for _, receivable := range e.GetReceivables(username) {
+		if strings.EqualFold(receivable.Debtor, username) {
+			debt += receivable.Amount
+		}
+	}
+	
+	return debt, nil
+}
+
+func (e *EconomyPlugin) startLoanCollector() {
+	if !e.config.Loans.Enabled {
+		return
+	}
+	
+	e.runPeriodically(loanCheckInterval, e.processLoans)
+}
+
+// processLoans withdraws expired offers and collects loans that have
+// fallen due. A loan that cannot be collected in full is marked defaulted
+// and collected from again on every later run.
+func (e *EconomyPlugin) processLoans() {
+	now := e.now()
+	due := make([]Loan, 0)
+	
+	e.loans.mutex.Lock()
+	expired := 0
+	for id, loan := range e.loans.loans {
+		switch {
+		case loan.Status == LoanOffered && e.offerExpired(loan) && !e.loans.busy[id]:
+			delete(e.loans.loans, id)
+			expired++
+		case loan.Status != LoanOffered && !now.Before(loan.Due) && !e.loans.busy[id]:
+			due = append(due, *loan)
+		}
+	}
+	if expired > 0 {
+		e.saveLoans()
+	}
+	e.loans.mutex.Unlock()
+	
+	for _, loan := range due {
+		collected, _, err := e.collectLoan(loan.ID, loan.Borrower, 0, true)
+		if err != nil && !errors.Is(err, ErrInsufficientFunds) {
+			e.logger.Warn("Failed to collect loan", "loan", loan.ID, "player", loan.Borrower, "error", err)
+		}
+		if collected > 0 {
+			e.logger.Info("Collected loan repayment", "loan", loan.ID, "player", loan.Borrower, "amount", collected.String())
+		}
+		if collected < loan.Outstanding() && loan.Status == LoanActive {
+			e.defaultLoan(loan.ID)
+		}
+	}
+}
+
+func (e *EconomyPlugin) defaultLoan(id int) {
+	e.loans.mutex.Lock()
+	defer e.loans.mutex.Unlock()
+	
+	loan, exists := e.loans.loans[id]
+	if !exists || loan.Status != LoanActive {
+		return
+	}
+	
+	loan.Status = LoanDefaulted
+	e.saveLoans()
+	e.logger.Warn("Loan defaulted", "loan", id, "player", loan.Borrower, "lender", loan.Lender, "outstanding", loan.Outstanding().String())
+}
+
+func (e *EconomyPlugin) loanCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) == 0 {
+		return e.message("loan.usage")
+	}
+	
+	player := ctx.Name()
+	action := strings.ToLower(args[0])
+	
+	switch action {
+	case "offer":
+		if len(args) < 5 {
+			return e.message("loan.offer_usage")
+		}
+		amount, err := e.parseAmount(args[2])
+		if err != nil {
+			return e.message("error.invalid_amount")
+		}
+		interest, err := strconv.ParseFloat(strings.TrimSuffix(args[3], "%"), 64)
+		if err != nil {
+			return e.message("loan.offer_usage")
+		}
+		days, err := strconv.Atoi(args[4])
+		if err != nil {
+			return e.message("loan.offer_usage")
+		}
+		
+		loan, err := e.OfferLoan(player, args[1], amount, interest, days)
+		if err != nil {
+			return e.message("loan.offer_failed", "error", e.describeError(err))
+		}
+		return e.message("loan.offered", "player", loan.Borrower, "amount", e.FormatMoney(loan.Principal),
+			"interest", strconv.FormatFloat(loan.Interest, 'f', -1, 64), "days", strconv.Itoa(loan.Days),
+			"owed", e.FormatMoney(loan.Owed))
+		
+	case "accept", "decline":
+		if len(args) < 2 {
+			return e.message("loan.answer_usage", "action", action)
+		}
+		
+		if action == "decline" {
+			if err := e.DeclineLoan(player, args[1]); err != nil {
+				return e.message("loan.decline_failed", "error", e.describeError(err))
+			}
+			return e.message("loan.declined", "player", args[1])
+		}
+		
+		loan, err := e.AcceptLoan(player, args[1])
+		if err != nil {
+			return e.message("loan.accept_failed", "error", e.describeError(err))
+		}
+		return e.message("loan.accepted", "player", loan.Lender, "amount", e.FormatMoney(loan.Principal),
+			"owed", e.FormatMoney(loan.Owed), "due", loan.Due.Format("2006-01-02 15:04"))
+		
+	case "repay":
+		if len(args) < 2 {
+			return e.message("loan.repay_usage")
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+		if err != nil {
+			return e.message("loan.bad_id")
+		}
+		var amount Money
+		if len(args) > 2 {
+			if amount, err = e.parseAmount(args[2]); err != nil {
+				return e.message("error.invalid_amount")
+			}
+		}
+		
+		paid, loan, err := e.RepayLoan(player, id, amount)
+		if err != nil {
+			return e.message("loan.repay_failed", "error", e.describeError(err))
+		}
+		return e.message("loan.repaid", "id", strconv.Itoa(id), "amount", e.FormatMoney(paid),
+			"remaining", e.FormatMoney(loan.Outstanding()))
+		
+	case "list":
+		loans := e.GetLoans(player)
+		if len(loans) == 0 {
+			return e.message("loan.none")
+		}
+		result := e.message("loan.header") + "\n"
+		for _, loan := range loans {
+			due := "-"
+			if loan.Status != LoanOffered {
+				due = loan.Due.Format("2006-01-02 15:04")
+			}
+			result += e.message("loan.entry", "id", strconv.Itoa(loan.ID), "lender", loan.Lender, "borrower", loan.Borrower,
+				"remaining", e.FormatMoney(loan.Outstanding()), "owed", e.FormatMoney(loan.Owed),
+				"due", due, "status", string(loan.Status)) + "\n"
+		}
+		return result
+		
+	default:
+		return e.message("loan.invalid")
+	}
+}
@@ -0,0 +1,261 @@
+package economy
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransactionLogConfig controls rotation of transactions.log. A file is
+// rotated once it would pass MaxSizeMB, or on the first write of a new day
+// with RotateDaily. MaxFiles and MaxAgeDays bound the rotated files kept;
+// zero disables the limit.
+type TransactionLogConfig struct {
+	MaxSizeMB   int  `json:"max_size_mb"`
+	RotateDaily bool `json:"rotate_daily"`
+	Compress    bool `json:"compress"`
+	MaxFiles    int  `json:"max_files"`
+	MaxAgeDays  int  `json:"max_age_days"`
+}
+
+const (
+	rotatedLogLayout = "20060102-150405.000"
+	logFlushInterval = time.Second
+)
+
+// rotatingLog is a buffered append-only file that rotates itself. The
+// handle stays open between writes; Flush is called periodically and on
+// Close. Rotated files are compressed and pruned in the background.
+type rotatingLog struct {
+	mutex  sync.Mutex
+	path   string
+	config TransactionLogConfig
+	logger *slog.Logger
+	file   *os.File
+	buffer *bufio.Writer
+	size   int64
+	day    string
+	jobs   sync.WaitGroup
+}
+
+func newRotatingLog(path string, config TransactionLogConfig, logger *slog.Logger) *rotatingLog {
+	return &rotatingLog{path: path, config: config, logger: logger}
+}
+
+func (l *rotatingLog) Write(data []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	
+	if l.file == nil {
+		if err := l.open(); err != nil {
+			return 0, err
+		}
+	}
+	
+	if l.shouldRotate(len(data)) {
+		if err := l.rotate(); err != nil {
+			l.logger.Error("Failed to rotate transaction log", "error", err)
+		}
+		if l.file == nil {
+			if err := l.open(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	
+	n, err := l.buffer.Write(data)
+	l.size += int64(n)
+	return n, err
+}
+
+func (l *rotatingLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	
+	l.file = file
+	l.buffer = bufio.NewWriterSize(file, 64*1024)
+	l.size = info.Size()
+	l.day = info.ModTime().Format(historyDayLayout)
+	if l.size == 0 {
+		l.day = time.Now().Format(historyDayLayout)
+	}
+	return nil
+}
+
+func (l *rotatingLog) shouldRotate(incoming int) bool {
+	if l.size == 0 {
+		return false
+	}
+	if l.config.MaxSizeMB > 0 && l.size+int64(incoming) > int64(l.config.MaxSizeMB)<<20 {
+		return true
+	}
+	return l.config.RotateDaily && time.Now().Format(historyDayLayout) != l.day
+}
+
+// rotate moves the current file aside under a timestamped name and starts
+// a fresh one. Callers hold l.mutex.
+func (l *rotatingLog) rotate() error {
+	if err := l.closeFile(); err != nil {
+		return err
+	}
+	
+	ext := filepath.Ext(l.path)
+	rotated := strings.TrimSuffix(l.path, ext) + "-" + time.Now().Format(rotatedLogLayout) + ext
+	if err := os.Rename(l.path, rotated); err != nil {
+		return err
+	}
+	
+	l.jobs.Add(1)
+	go func() {
+		defer l.jobs.Done()
+		l.archive(rotated)
+	}()
+	
+	return l.open()
+}
+
+// archive compresses a rotated file when configured and then applies the
+// retention limits to every rotated file.
+func (l *rotatingLog) archive(rotated string) {
+	if l.config.Compress {
+		if err := gzipFile(rotated); err != nil {
+			l.logger.Error("Failed to compress rotated transaction log", "path", rotated, "error", err)
+		}
+	}
+	
+	l.prune()
+}
+
+func gzipFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	
+	target, err := os.OpenFile(path+".gz.tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(target)
+	_, err = io.Copy(writer, source)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		err = target.Sync()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(path+".gz.tmp", path+".gz")
+	}
+	if err != nil {
+		os.Remove(path + ".gz.tmp")
+		return err
+	}
+	
+	return os.Remove(path)
+}
+
+// rotatedFiles lists the rotated copies of the log, newest first. Their
+// timestamped names sort in age order.
+func (l *rotatingLog) rotatedFiles() ([]string, error) {
+	ext := filepath.Ext(l.path)
+	prefix := strings.TrimSuffix(filepath.Base(l.path), ext) + "-"
+	
+	entries, err := os.ReadDir(filepath.Dir(l.path))
+	if err != nil {
+		return nil, err
+	}
+	
+	files := make([]string, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if strings.HasSuffix(name, ext) || strings.HasSuffix(name, ext+".gz") {
+			files = append(files, name)
+		}
+	}
+	
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files, nil
+}
+
+func (l *rotatingLog) prune() {
+	files, err := l.rotatedFiles()
+	if err != nil {
+		l.logger.Error("Failed to list rotated transaction logs", "error", err)
+		return
+	}
+	
+	cutoff := time.Now().AddDate(0, 0, -l.config.MaxAgeDays)
+	folder := filepath.Dir(l.path)
+	for i, name := range files {
+		path := filepath.Join(folder, name)
+		expired := l.config.MaxFiles > 0 && i >= l.config.MaxFiles
+		if !expired && l.config.MaxAgeDays > 0 {
+			if info, err := os.Stat(path); err == nil && info.ModTime().Before(cutoff) {
+				expired = true
+			}
+		}
+		if !expired {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			l.logger.Warn("Failed to remove old transaction log", "path", path, "error", err)
+		}
+	}
+}
+
+func (l *rotatingLog) Flush() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	
+	if l.buffer != nil {
+		if err := l.buffer.Flush(); err != nil {
+			l.logger.Error("Failed to flush transaction log", "error", err)
+		}
+	}
+}
+
+// Close flushes and closes the file and waits for pending compression.
+func (l *rotatingLog) Close() error {
+	l.mutex.Lock()
+	err := l.closeFile()
+	l.mutex.Unlock()
+	
+	l.jobs.Wait()
+	return err
+}
+
+// closeFile is called with l.mutex held.
+func (l *rotatingLog) closeFile() error {
+	if l.file == nil {
+		return nil
+	}
+	
+	err := l.buffer.Flush()
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file, l.buffer, l.size = nil, nil, 0
+	return err
+}
@@ -0,0 +1,112 @@
+package economy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoggingConfig selects the level, format and destination of the plugin's
+// log. File is relative to the data folder; empty logs to stderr.
+type LoggingConfig struct {
+	Level  string `json:"level"`
+	Format string `json:"format"`
+	File   string `json:"file"`
+}
+
+func parseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q", name)
+	}
+}
+
+func newLogHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	options := &slog.HandlerOptions{Level: level}
+	if strings.EqualFold(format, "json") {
+		return slog.NewJSONHandler(w, options)
+	}
+	return slog.NewTextHandler(w, options)
+}
+
+// setupLogger replaces the startup logger with one built from the loaded
+// config. Problems are logged and leave the current logger in place.
+func (e *EconomyPlugin) setupLogger() {
+	config := e.config.Logging
+	
+	level, err := parseLogLevel(config.Level)
+	if err != nil {
+		e.logger.Warn("Invalid logging.level, using info", "error", err)
+	}
+	
+	var out io.Writer = os.Stderr
+	if config.File != "" {
+		path := config.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(e.dataFolder, path)
+		}
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			e.logger.Error("Failed to open log file, logging to stderr", "path", path, "error", err)
+		} else {
+			e.closeLogFile()
+			e.logFile = file
+			out = file
+		}
+	}
+	
+	e.logger = slog.New(newLogHandler(out, config.Format, level)).With("plugin", e.name)
+	if e.transactionFile != nil {
+		e.transactionFile.Close()
+	}
+	e.transactionFile = newRotatingLog(filepath.Join(e.dataFolder, "transactions.log"), e.config.TransactionLog, e.logger)
+	e.transactionLog = slog.New(newLogHandler(e.transactionFile, config.Format, slog.LevelInfo))
+}
+
+func (e *EconomyPlugin) closeLogFile() {
+	if e.transactionFile != nil {
+		if err := e.transactionFile.Close(); err != nil {
+			e.logger.Error("Failed to close transaction log", "error", err)
+		}
+		e.transactionFile = nil
+		e.transactionLog = nil
+	}
+	if e.logFile != nil {
+		e.logFile.Close()
+		e.logFile = nil
+	}
+}
+
+// logTransaction writes transaction to transactions.log as a structured
+// record stamped with the transaction's own time.
+func (e *EconomyPlugin) logTransaction(transaction *Transaction) {
+	if e.transactionLog == nil {
+		return
+	}
+	
+	record := slog.NewRecord(transaction.Timestamp, slog.LevelInfo, "transaction", 0)
+	record.AddAttrs(
+		slog.String("type", transaction.Type.String()),
+		slog.String("code", string(transaction.Code)),
+		slog.String("from", transaction.From),
+		slog.String("to", transaction.To),
+		slog.String("amount", transaction.Amount.String()),
+		slog.String("reason", transaction.Reason),
+	)
+	
+	if err := e.transactionLog.Handler().Handle(context.Background(), record); err != nil {
+		e.logger.Error("Failed to write transaction log", "error", err)
+	}
+}
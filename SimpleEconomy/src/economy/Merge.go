@@ -0,0 +1,157 @@
+package economy
+
+import "strings"
+
+// MergeAccounts moves everything from's account holds into into's and
+// deletes from's account. Either may be a name or a UUID. It is meant for
+// players who ended up with two accounts, e.g. under two spellings of
+// their name from the old name-keyed storage: from's transaction history,
+// pending payments, receivables, savings, account links and queued
+// notifications move to into as well. An account with open
+// loans under another name cannot be merged away.
+func (e *EconomyPlugin) MergeAccounts(from, into string) error {
+	source, sourceExists := e.findAccount(from)
+	target, targetExists := e.findAccount(into)
+	if !sourceExists || !targetExists {
+		return ErrAccountNotFound
+	}
+	if source == target {
+		return ErrSelfTransfer
+	}
+	if source.virtual() || target.virtual() {
+		return ErrInvalidAccountName
+	}
+	
+	sourceSnapshot, targetSnapshot := e.snapshotAccount(source), e.snapshotAccount(target)
+	sourceName, targetName := sourceSnapshot.Username, targetSnapshot.Username
+	renamed := !strings.EqualFold(sourceName, targetName)
+	if renamed && len(e.GetLoans(sourceName)) > 0 {
+		return ErrAccountHasLoans
+	}
+	
+	var targetOld, targetNew, moved, movedBank Money
+	err := e.mutateLoaded([]*PlayerAccount{source, target}, func(accounts []*PlayerAccount) error {
+		source, target := accounts[0], accounts[1]
+		if target.Balance+source.Balance > e.config.MaxBalance {
+			return ErrMaxBalanceExceeded
+		}
+		if target.BankBalance+source.BankBalance > e.config.BankMaxBalance {
+			return ErrBankLimitExceeded
+		}
+		
+		targetOld = target.Balance
+		moved, movedBank = source.Balance+source.BankBalance, source.BankBalance
+		target.Balance += source.Balance
+		target.BankBalance += source.BankBalance
+		target.Holds = append(append([]Hold(nil), target.Holds...), source.Holds...)
+		target.TotalEarned += source.TotalEarned
+		target.TotalSpent += source.TotalSpent
+		if source.LastSeen.After(target.LastSeen) {
+			target.LastSeen = source.LastSeen
+		}
+		if !source.Created.IsZero() && (target.Created.IsZero() || source.Created.Before(target.Created)) {
+			target.Created = source.Created
+		}
+		targetNew = target.Balance
+		source.Balance, source.BankBalance, source.Holds = 0, 0, nil
+		source.TotalEarned, source.TotalSpent = 0, 0
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	
+	unlock := e.locks.lock(source, target)
+	e.mutex.Lock()
+	e.forgetAccount(source)
+	e.names[strings.ToLower(targetName)] = targetSnapshot.UUID
+	e.mutex.Unlock()
+	unlock()
+	
+	e.invalidateTopPlayers()
+	if targetNew != targetOld {
+		e.fireBalanceChange(targetName, targetOld, targetNew, MERGE)
+	}
+	
+	if renamed {
+		e.reassignPlayerBooks(sourceName, targetName)
+	}
+	e.recordTransaction(&Transaction{
+		From:      sourceName,
+		To:        targetName,
+		Amount:    moved,
+		Type:      MERGE,
+		Timestamp: e.now(),
+		Reason:    "Accounts merged",
+		Postings: e.postings(
+			Posting{Account: sourceName, Amount: movedBank - moved},
+			Posting{Account: targetName, Amount: moved - movedBank},
+			Posting{Account: bankRef(sourceName), Amount: -movedBank},
+			Posting{Account: bankRef(targetName), Amount: movedBank},
+		),
+	})
+	
+	notifications, err := e.notifications.TakeNotifications(sourceSnapshot.UUID)
+	if err != nil {
+		e.countStorageError("notifications")
+		e.logger.Warn("Failed to move queued notifications", "from", sourceName, "error", err)
+	}
+	for _, notification := range notifications {
+		if err := e.notifications.QueueNotification(targetSnapshot.UUID, notification); err != nil {
+			e.countStorageError("notifications")
+			e.logger.Warn("Failed to move queued notification", "into", targetName, "error", err)
+		}
+	}
+	
+	return nil
+}
+
+// reassignPlayerBooks moves everything recorded under the username from
+// to into: pending payments, links, savings deposits, receivables and, when
+// the ledger supports it, transaction history. Merges and renames use it.
+func (e *EconomyPlugin) reassignPlayerBooks(from, into string) {
+	e.reassignPendingPayments(from, into)
+	e.reassignLinks(from, into)
+	e.reassignSavings(from, into)
+	e.reassignReceivables(from, into)
+	if renamer, ok := e.ledger.(TransactionRenamer); ok {
+		if _, err := renamer.ReassignTransactions(from, into); err != nil {
+			e.countStorageError("ledger")
+			e.logger.Error("Failed to move transaction history", "from", from, "into", into, "error", err)
+		}
+	}
+}
+
+func (e *EconomyPlugin) mergeCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	confirmed := len(args) == 3 && args[2] == "--confirm"
+	if len(args) != 2 && !confirmed {
+		return e.message("merge.usage")
+	}
+	from, into := args[0], args[1]
+	
+	if !confirmed {
+		source, exists := e.findAccount(from)
+		if !exists {
+			return e.message("merge.failed", "error", e.describeError(ErrAccountNotFound))
+		}
+		snapshot := e.snapshotAccount(source)
+		return e.message("merge.confirm", "from", from, "into", into,
+			"amount", e.FormatMoney(snapshot.Balance+snapshot.BankBalance))
+	}
+	
+	target, exists := e.findAccount(into)
+	if !exists {
+		return e.message("merge.failed", "error", e.describeError(ErrAccountNotFound))
+	}
+	before := e.snapshotAccount(target).Balance
+	if err := e.MergeAccounts(from, into); err != nil {
+		return e.message("merge.failed", "error", e.describeError(err))
+	}
+	merged := e.snapshotAccount(target)
+	e.auditBalance(ctx, "merge", merged.Username, before, "merged "+from)
+	
+	return e.message("merge.done", "from", from, "into", merged.Username,
+		"balance", e.FormatMoney(merged.Balance), "bank", e.FormatMoney(merged.BankBalance),
+		"earned", e.FormatMoney(merged.TotalEarned), "spent", e.FormatMoney(merged.TotalSpent))
+}
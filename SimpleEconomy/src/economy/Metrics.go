@@ -0,0 +1,186 @@
+package economy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// durationBuckets are the histogram bounds, in seconds, for saves and
+// leaderboard recomputes.
+var durationBuckets = [...]float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// economyMetrics holds the counters behind /metrics. Gauges such as the
+// money supply are computed when scraped instead.
+type economyMetrics struct {
+	transactions [transactionTypeCount]atomic.Uint64
+	saves        durationHistogram
+	topRecompute durationHistogram
+	
+	mutex         sync.Mutex
+	storageErrors map[string]uint64
+}
+
+type durationHistogram struct {
+	mutex  sync.Mutex
+	counts [len(durationBuckets)]uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *durationHistogram) observe(duration time.Duration) {
+	seconds := duration.Seconds()
+	
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+func (h *durationHistogram) write(w io.Writer, name, help string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for i, bound := range durationBuckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, bound, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %g\n%s_count %d\n", name, h.sum, name, h.count)
+}
+
+// countStorageError records a failed storage operation such as "save" or
+// "ledger".
+func (e *EconomyPlugin) countStorageError(operation string) {
+	e.metrics.mutex.Lock()
+	defer e.metrics.mutex.Unlock()
+	
+	if e.metrics.storageErrors == nil {
+		e.metrics.storageErrors = make(map[string]uint64)
+	}
+	e.metrics.storageErrors[operation]++
+}
+
+func (e *EconomyPlugin) countTransaction(transactionType TransactionType) {
+	if transactionType >= 0 && transactionType < transactionTypeCount {
+		e.metrics.transactions[transactionType].Add(1)
+	}
+}
+
+// handleMetrics serves the metrics in the Prometheus text format.
+func (e *EconomyPlugin) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.writeMetrics(w)
+}
+
+func (e *EconomyPlugin) writeMetrics(w io.Writer) {
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	
+	metric("economy_transactions_total", "counter", "Transactions recorded since the plugin started, by type.")
+	for t := ADD; t < transactionTypeCount; t++ {
+		fmt.Fprintf(w, "economy_transactions_total{type=%q} %d\n", t.String(), e.metrics.transactions[t].Load())
+	}
+	
+	accounts := e.snapshotAccounts()
+	var wallets, banks, shared, virtual Money
+	virtualCount := 0
+	for _, account := range accounts {
+		if account.virtual() {
+			virtual += account.Balance
+			virtualCount++
+			continue
+		}
+		wallets += account.Balance
+		banks += account.BankBalance
+	}
+	e.shared.mutex.Lock()
+	sharedCount := len(e.shared.accounts)
+	for _, account := range e.shared.accounts {
+		shared += account.Balance
+	}
+	e.shared.mutex.Unlock()
+	
+	var escrowed Money
+	e.escrows.mutex.Lock()
+	for _, escrow := range e.escrows.escrows {
+		escrowed += escrow.Amount
+	}
+	e.escrows.mutex.Unlock()
+	
+	var gifted Money
+	e.gifts.mutex.Lock()
+	for _, gift := range e.gifts.gifts {
+		gifted += gift.Amount
+	}
+	e.gifts.mutex.Unlock()
+	
+	var pending Money
+	e.pending.mutex.Lock()
+	for _, payment := range e.pending.payments {
+		pending += payment.Amount
+	}
+	e.pending.mutex.Unlock()
+	
+	var saved Money
+	e.savings.mutex.Lock()
+	for _, deposit := range e.savings.deposits {
+		saved += deposit.Amount
+	}
+	e.savings.mutex.Unlock()
+	
+	metric("economy_money_supply", "gauge", "Money held in accounts, by where it is held.")
+	fmt.Fprintf(w, "economy_money_supply{holder=\"wallet\"} %s\n", wallets)
+	fmt.Fprintf(w, "economy_money_supply{holder=\"bank\"} %s\n", banks)
+	fmt.Fprintf(w, "economy_money_supply{holder=\"shared\"} %s\n", shared)
+	fmt.Fprintf(w, "economy_money_supply{holder=\"virtual\"} %s\n", virtual)
+	fmt.Fprintf(w, "economy_money_supply{holder=\"escrow\"} %s\n", escrowed)
+	fmt.Fprintf(w, "economy_money_supply{holder=\"gift\"} %s\n", gifted)
+	fmt.Fprintf(w, "economy_money_supply{holder=\"pending\"} %s\n", pending)
+	fmt.Fprintf(w, "economy_money_supply{holder=\"savings\"} %s\n", saved)
+	if limit := e.config.SupplyCap.Max; limit > 0 {
+		metric("economy_money_supply_cap", "gauge", "The cap on money held in wallets and banks.")
+		fmt.Fprintf(w, "economy_money_supply_cap %s\n", limit)
+	}
+	
+	metric("economy_accounts", "gauge", "Number of accounts, by kind.")
+	fmt.Fprintf(w, "economy_accounts{kind=\"player\"} %d\n", len(accounts)-virtualCount)
+	fmt.Fprintf(w, "economy_accounts{kind=\"virtual\"} %d\n", virtualCount)
+	fmt.Fprintf(w, "economy_accounts{kind=\"shared\"} %d\n", sharedCount)
+	
+	if _, lazy := e.lazyStorage(); lazy {
+		metric("economy_account_cache_lookups_total", "counter", "Account lookups, by whether the account was already in memory.")
+		fmt.Fprintf(w, "economy_account_cache_lookups_total{result=\"hit\"} %d\n", e.cache.hits.Load())
+		fmt.Fprintf(w, "economy_account_cache_lookups_total{result=\"miss\"} %d\n", e.cache.misses.Load())
+		metric("economy_account_cache_evictions_total", "counter", "Accounts dropped from memory.")
+		fmt.Fprintf(w, "economy_account_cache_evictions_total %d\n", e.cache.evictions.Load())
+		metric("economy_account_cache_size", "gauge", "Accounts currently in memory.")
+		fmt.Fprintf(w, "economy_account_cache_size %d\n", e.cache.size())
+	}
+	
+	e.metrics.saves.write(w, "economy_save_duration_seconds", "Time taken to flush player data to storage.")
+	e.metrics.topRecompute.write(w, "economy_top_players_recompute_seconds", "Time taken to rebuild the leaderboard.")
+	
+	e.metrics.mutex.Lock()
+	operations := make([]string, 0, len(e.metrics.storageErrors))
+	for operation := range e.metrics.storageErrors {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	metric("economy_storage_errors_total", "counter", "Failed storage operations, by operation.")
+	for _, operation := range operations {
+		fmt.Fprintf(w, "economy_storage_errors_total{operation=%q} %d\n", operation, e.metrics.storageErrors[operation])
+	}
+	e.metrics.mutex.Unlock()
+}
@@ -0,0 +1,251 @@
+package economy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// migrateBatchSize is how many accounts are saved, and transactions
+// read, at a time while migrating.
+const migrateBatchSize = 500
+
+// MigrationResult describes a finished migration. Supply is the money in
+// wallets and banks that was moved.
+type MigrationResult struct {
+	From         string
+	To           string
+	Accounts     int
+	Transactions int
+	Supply       Money
+}
+
+// storageBackends are the storage_backend values newStorage accepts.
+var storageBackends = []string{"json", "files", "sqlite", "bolt", "mysql", "postgres", "mongodb"}
+
+// remoteBackend reports whether backend keeps its data on a database
+// server rather than in the data folder.
+func remoteBackend(backend string) bool {
+	return backend == "mysql" || backend == "postgres" || backend == "mongodb"
+}
+
+func normalizeBackend(backend string) (string, error) {
+	backend = strings.ToLower(backend)
+	if backend == "" {
+		backend = "json"
+	}
+	for _, known := range storageBackends {
+		if backend == known {
+			return backend, nil
+		}
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownBackend, backend)
+}
+
+// MigrateStorage copies every account and the transaction ledger from the
+// active storage backend, which from must name, into backend to, reporting
+// progress as it goes, and checks the copy's counts and sums against the
+// original. The target must be empty; the mysql, postgres and mongo sections
+// of the config are used for those backends. On success the config file is
+// switched to the new backend.
+//
+// Mutations wait while the copy is made and are refused afterwards, so
+// nothing is written to the old backend that the new one would miss: the
+// server must be restarted to use the new backend. Balance history, audit
+// entries and the other records kept next to the accounts are not moved.
+func (e *EconomyPlugin) MigrateStorage(from, to string, progress func(stage string, done int)) (MigrationResult, error) {
+	from, err := normalizeBackend(from)
+	if err != nil {
+		return MigrationResult{}, err
+	}
+	to, err = normalizeBackend(to)
+	if err != nil {
+		return MigrationResult{}, err
+	}
+	active, _ := normalizeBackend(e.config.StorageBackend)
+	if from != active {
+		return MigrationResult{}, fmt.Errorf("%w: %s is in use, not %s", ErrWrongBackend, active, from)
+	}
+	if from == to {
+		return MigrationResult{}, fmt.Errorf("%w: already using %s", ErrWrongBackend, to)
+	}
+	if progress == nil {
+		progress = func(string, int) {}
+	}
+	
+	resume := e.shutdown.pauseMutations()
+	migrated := false
+	defer func() { resume(migrated) }()
+	
+	if _, err := e.savePlayerData(); err != nil {
+		return MigrationResult{}, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	
+	config := *e.config
+	config.StorageBackend = to
+	target, err := newStorage(&config, e.dataFolder)
+	if err != nil {
+		return MigrationResult{}, fmt.Errorf("open %s storage: %w", to, err)
+	}
+	defer target.Close()
+	if err := target.Load(); err != nil {
+		return MigrationResult{}, fmt.Errorf("load %s storage: %w", to, err)
+	}
+	
+	result := MigrationResult{From: from, To: to}
+	if result.Accounts, result.Supply, err = e.migrateAccounts(target, progress); err != nil {
+		return result, err
+	}
+	if result.Transactions, err = e.migrateTransactions(target, progress); err != nil {
+		return result, err
+	}
+	
+	saved, err := e.readConfig()
+	if err != nil {
+		return result, err
+	}
+	switched := *saved
+	switched.StorageBackend = to
+	e.writeConfig(&switched)
+	
+	migrated = true
+	e.logger.Info("Migrated storage", "from", from, "to", to, "accounts", result.Accounts,
+		"transactions", result.Transactions, "supply", result.Supply.String())
+	return result, nil
+}
+
+func accountTotals(accounts []*PlayerAccount) (int, Money) {
+	supply := Money(0)
+	for _, account := range accounts {
+		supply += account.Balance + account.BankBalance
+	}
+	return len(accounts), supply
+}
+
+func (e *EconomyPlugin) migrateAccounts(target Storage, progress func(string, int)) (int, Money, error) {
+	existing, err := target.ListAccounts()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(existing) > 0 {
+		return 0, 0, fmt.Errorf("%w: %d accounts", ErrMigrationTargetNotEmpty, len(existing))
+	}
+	
+	accounts, err := e.storage.ListAccounts()
+	if err != nil {
+		return 0, 0, err
+	}
+	for i, account := range accounts {
+		if err := target.PutAccount(account); err != nil {
+			return 0, 0, err
+		}
+		if done := i + 1; done%migrateBatchSize == 0 || done == len(accounts) {
+			if err := target.Save(); err != nil {
+				return 0, 0, err
+			}
+			progress("accounts", done)
+		}
+	}
+	
+	copied, err := target.ListAccounts()
+	if err != nil {
+		return 0, 0, err
+	}
+	count, supply := accountTotals(accounts)
+	copiedCount, copiedSupply := accountTotals(copied)
+	if copiedCount != count || copiedSupply != supply {
+		return 0, 0, fmt.Errorf("%w: %d accounts holding %s became %d holding %s", ErrMigrationMismatch,
+			count, supply, copiedCount, copiedSupply)
+	}
+	return count, supply, nil
+}
+
+// migrateTransactions copies the ledger into target's, or into
+// transactions.jsonl for a backend without one. The json and files
+// backends share that file, so between them there is nothing to copy.
+func (e *EconomyPlugin) migrateTransactions(target Storage, progress func(string, int)) (int, error) {
+	ledger, ok := target.(TransactionStore)
+	if !ok {
+		if _, shared := e.ledger.(*JSONLinesLedger); shared {
+			return 0, nil
+		}
+		ledger = NewJSONLinesLedger(filepath.Join(e.dataFolder, "transactions.jsonl"))
+	}
+	
+	existing, err := ledger.QueryTransactions("", TransactionFilter{Limit: 1})
+	if err != nil {
+		return 0, err
+	}
+	if len(existing) > 0 {
+		return 0, fmt.Errorf("%w: the ledger has transactions", ErrMigrationTargetNotEmpty)
+	}
+	
+	count, sum := 0, Money(0)
+	for {
+		page, err := e.ledger.QueryTransactions("", TransactionFilter{Offset: count, Limit: migrateBatchSize})
+		if err != nil {
+			return count, err
+		}
+		// Pages come newest first; each is written oldest first so
+		// transactions sharing a timestamp keep their order.
+		for i := len(page) - 1; i >= 0; i-- {
+			if err := ledger.AppendTransaction(&page[i]); err != nil {
+				return count, err
+			}
+			sum += page[i].Amount
+		}
+		count += len(page)
+		if len(page) > 0 {
+			progress("transactions", count)
+		}
+		if len(page) < migrateBatchSize {
+			break
+		}
+	}
+	
+	copiedCount, copiedSum := 0, Money(0)
+	for {
+		page, err := ledger.QueryTransactions("", TransactionFilter{Offset: copiedCount, Limit: migrateBatchSize})
+		if err != nil {
+			return count, err
+		}
+		for i := range page {
+			copiedSum += page[i].Amount
+		}
+		copiedCount += len(page)
+		if len(page) < migrateBatchSize {
+			break
+		}
+	}
+	if copiedCount != count || copiedSum != sum {
+		return count, fmt.Errorf("%w: %d transactions of %s became %d of %s", ErrMigrationMismatch,
+			count, sum, copiedCount, copiedSum)
+	}
+	return count, nil
+}
+
+func (e *EconomyPlugin) migrateCommand(ctx *CommandContext) string {
+	if len(ctx.Args) != 2 {
+		return e.message("migrate.usage")
+	}
+	from, to := ctx.Args[0], ctx.Args[1]
+	
+	messenger := e.getMessenger()
+	progress := func(stage string, done int) {
+		e.logger.Info("Migrating storage", "stage", stage, "copied", done)
+		if messenger != nil && !ctx.IsConsole() {
+			messenger.SendMessage(ctx.Name(), e.message("migrate.progress", "stage", stage, "count", strconv.Itoa(done)))
+		}
+	}
+	
+	result, err := e.MigrateStorage(from, to, progress)
+	if err != nil {
+		return e.message("migrate.failed", "error", e.describeError(err))
+	}
+	e.audit(ctx, "migrate", result.To, nil, nil, "from "+result.From)
+	
+	return e.message("migrate.done", "from", result.From, "to", result.To,
+		"accounts", strconv.Itoa(result.Accounts), "transactions", strconv.Itoa(result.Transactions),
+		"supply", e.FormatMoney(result.Supply))
+}
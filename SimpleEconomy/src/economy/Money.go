@@ -0,0 +1,287 @@
+package economy
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount of currency in fixed-point ten-thousandths of a unit,
+// so adding and subtracting balances is exact. It reads and writes as a
+// plain JSON number, which keeps files written before Money existed
+// loadable: their float values are rounded to the nearest ten-thousandth.
+// SQL backends store it as a float of whole units, which round-trips
+// exactly below about 900 billion.
+type Money int64
+
+const (
+	moneyDigits = 4
+	moneyScale  = 10000
+)
+
+var errInvalidMoney = errors.New("invalid amount")
+
+// invalidMoney stands in for a stored amount Money cannot hold, such as
+// NaN from a hand-edited file, so /eco verify can find it instead of the
+// whole file failing to load.
+const invalidMoney = Money(math.MinInt64)
+
+// MoneyFromFloat converts a float amount to Money, rounding to the nearest
+// representable value.
+func MoneyFromFloat(value float64) Money {
+	return Money(math.Round(value * moneyScale))
+}
+
+// ParseMoney parses a decimal amount such as "12.50" exactly. It rejects
+// exponents and more than four decimal places.
+func ParseMoney(text string) (Money, error) {
+	text = strings.TrimSpace(text)
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+	
+	whole, fraction := text, ""
+	if dot := strings.IndexByte(text, '.'); dot >= 0 {
+		whole, fraction = text[:dot], text[dot+1:]
+	}
+	if (whole == "" && fraction == "") || len(fraction) > moneyDigits || !isDigits(whole) || !isDigits(fraction) {
+		return 0, errInvalidMoney
+	}
+	
+	units := int64(0)
+	if whole != "" {
+		parsed, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil || parsed > math.MaxInt64/moneyScale {
+			return 0, errInvalidMoney
+		}
+		units = parsed * moneyScale
+	}
+	if fraction != "" {
+		parsed, _ := strconv.ParseInt(fraction+strings.Repeat("0", moneyDigits-len(fraction)), 10, 64)
+		units += parsed
+	}
+	
+	if negative {
+		units = -units
+	}
+	return Money(units), nil
+}
+
+func isDigits(text string) bool {
+	for _, r := range text {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Money) Float64() float64 {
+	return float64(m) / moneyScale
+}
+
+// Round rounds m half away from zero to the given number of decimal places.
+func (m Money) Round(places int) Money {
+	if places >= moneyDigits {
+		return m
+	}
+	if places < 0 {
+		places = 0
+	}
+	
+	unit := Money(math.Pow10(moneyDigits - places))
+	half := unit / 2
+	if m < 0 {
+		return -((-m + half) / unit * unit)
+	}
+	return (m + half) / unit * unit
+}
+
+// Floor rounds m down to the given number of decimal places.
+func (m Money) Floor(places int) Money {
+	truncated := m.Truncate(places)
+	if truncated > m {
+		truncated -= Money(math.Pow10(moneyDigits - clampPlaces(places)))
+	}
+	return truncated
+}
+
+// Ceil rounds m up to the given number of decimal places.
+func (m Money) Ceil(places int) Money {
+	truncated := m.Truncate(places)
+	if truncated < m {
+		truncated += Money(math.Pow10(moneyDigits - clampPlaces(places)))
+	}
+	return truncated
+}
+
+func clampPlaces(places int) int {
+	if places < 0 {
+		return 0
+	}
+	if places > moneyDigits {
+		return moneyDigits
+	}
+	return places
+}
+
+// Truncate drops any digits beyond the given number of decimal places,
+// rounding toward zero.
+func (m Money) Truncate(places int) Money {
+	if places >= moneyDigits {
+		return m
+	}
+	if places < 0 {
+		places = 0
+	}
+	
+	unit := Money(math.Pow10(moneyDigits - places))
+	return m / unit * unit
+}
+
+// MulRate returns m scaled by rate, rounded to the nearest representable
+// value. It is used for percentages such as interest and fees.
+func (m Money) MulRate(rate float64) Money {
+	return Money(math.Round(float64(m) * rate))
+}
+
+// Format renders m with exactly the given number of decimal places.
+func (m Money) Format(places int) string {
+	if places < 0 {
+		places = 0
+	}
+	if places > moneyDigits {
+		places = moneyDigits
+	}
+	
+	rounded := m.Round(places)
+	sign := ""
+	if rounded < 0 {
+		sign = "-"
+		rounded = -rounded
+	}
+	
+	whole := int64(rounded) / moneyScale
+	if places == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	fraction := (int64(rounded) % moneyScale) / int64(math.Pow10(moneyDigits-places))
+	return fmt.Sprintf("%s%d.%0*d", sign, whole, places, fraction)
+}
+
+// String renders m exactly, without trailing zeros.
+func (m Money) String() string {
+	if m == invalidMoney {
+		return "NaN"
+	}
+	text := m.Format(moneyDigits)
+	text = strings.TrimRight(text, "0")
+	return strings.TrimSuffix(text, ".")
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	if m == invalidMoney {
+		return []byte(`"NaN"`), nil
+	}
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "null" {
+		return nil
+	}
+	
+	if parsed, err := ParseMoney(text); err == nil {
+		*m = parsed
+		return nil
+	}
+	
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+	if math.IsNaN(value) || math.Abs(value) >= math.MaxInt64/moneyScale {
+		*m = invalidMoney
+		return nil
+	}
+	*m = MoneyFromFloat(value)
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.Float64(), nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case float64:
+		*m = MoneyFromFloat(value)
+	case int64:
+		*m = Money(value * moneyScale)
+	case []byte:
+		return m.UnmarshalJSON(value)
+	case string:
+		return m.UnmarshalJSON([]byte(value))
+	case nil:
+		*m = 0
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+// decimalPlaces is the number of decimal places amounts are rounded to.
+func (e *EconomyPlugin) decimalPlaces() int {
+	places := e.config.DecimalPlaces
+	if places < 0 {
+		return 0
+	}
+	if places > moneyDigits {
+		return moneyDigits
+	}
+	return places
+}
+
+// Rounding modes for the rounding_mode option.
+const (
+	roundFloor = "floor"
+	roundHalf  = "round"
+	roundCeil  = "ceil"
+)
+
+// roundAmount rounds an amount to decimal_places as rounding_mode says.
+// It is applied to every amount the plugin computes, such as interest and
+// fees, and to amounts other plugins pass in.
+func (e *EconomyPlugin) roundAmount(amount Money) Money {
+	places := e.decimalPlaces()
+	switch strings.ToLower(e.config.RoundingMode) {
+	case roundHalf:
+		return amount.Round(places)
+	case roundCeil:
+		return amount.Ceil(places)
+	default:
+		return amount.Floor(places)
+	}
+}
+
+// checkMinTransaction fails with ErrBelowMinimum for an amount smaller
+// than min_transaction_amount.
+func (e *EconomyPlugin) checkMinTransaction(amount Money) error {
+	if amount < e.config.MinTransaction {
+		return ErrBelowMinimum
+	}
+	return nil
+}
+
+// parseAmount parses a player-entered amount, rejecting more decimal places
+// than the currency allows.
+func (e *EconomyPlugin) parseAmount(text string) (Money, error) {
+	amount, err := ParseMoney(text)
+	if err != nil || amount.Round(e.decimalPlaces()) != amount {
+		return 0, ErrInvalidAmount
+	}
+	return amount, nil
+}
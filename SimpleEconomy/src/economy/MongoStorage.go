@@ -0,0 +1,482 @@
+package economy
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoTimeout bounds every call made to the server.
+const mongoTimeout = 10 * time.Second
+
+// mongoAccount is an accounts document, keyed by the account's UUID.
+// username_key is the lowercased name FindAccount looks accounts up by.
+type mongoAccount struct {
+	UUID        string    `bson:"_id"`
+	Username    string    `bson:"username"`
+	UsernameKey string    `bson:"username_key"`
+	Balance     Money     `bson:"balance"`
+	LastSeen    time.Time `bson:"last_seen"`
+	Created     time.Time `bson:"created,omitempty"`
+	TotalEarned Money     `bson:"total_earned"`
+	TotalSpent  Money     `bson:"total_spent"`
+	BankBalance Money     `bson:"bank_balance"`
+	Holds       []Hold    `bson:"holds,omitempty"`
+	Hidden      bool      `bson:"hidden,omitempty"`
+	Private     bool      `bson:"private_balance,omitempty"`
+}
+
+// mongoTransaction is a transactions document. players lists the
+// lowercased names of everyone the transaction involves, so a player's
+// history is one indexed lookup.
+type mongoTransaction struct {
+	ObjectID  primitive.ObjectID `bson:"_id,omitempty"`
+	ID        string             `bson:"tx_id"`
+	From      string             `bson:"from"`
+	To        string             `bson:"to"`
+	Amount    Money              `bson:"amount"`
+	Type      TransactionType    `bson:"type"`
+	Code      ReasonCode         `bson:"code,omitempty"`
+	Timestamp time.Time          `bson:"timestamp"`
+	Reason    string             `bson:"reason"`
+	Previous  *Money             `bson:"previous,omitempty"`
+	Batch     []BatchEntry       `bson:"batch,omitempty"`
+	Postings  []Posting          `bson:"postings,omitempty"`
+	Players   []string           `bson:"players"`
+}
+
+var mongoIndexes = map[string][]mongo.IndexModel{
+	"accounts": {
+		{Keys: bson.D{{Key: "username_key", Value: 1}, {Key: "last_seen", Value: -1}}},
+	},
+	"transactions": {
+		{Keys: bson.D{{Key: "players", Value: 1}, {Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "tx_id", Value: 1}}},
+	},
+}
+
+// MongoStorage keeps accounts and the transaction ledger in a MongoDB
+// database. Like SQLiteStorage it writes only the accounts that changed
+// since the last Save.
+type MongoStorage struct {
+	client       *mongo.Client
+	database     *mongo.Database
+	accounts     *mongo.Collection
+	transactions *mongo.Collection
+	pending      map[string]PlayerAccount
+	deleted      map[string]bool
+	mutex        sync.Mutex
+}
+
+func NewMongoStorage(config MongoConfig) (*MongoStorage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	
+	// Connect only validates the URI; the server is first reached in Load.
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.URI))
+	if err != nil {
+		return nil, err
+	}
+	
+	database := client.Database(config.Database)
+	return &MongoStorage{
+		client:       client,
+		database:     database,
+		accounts:     database.Collection("accounts"),
+		transactions: database.Collection("transactions"),
+		pending:      make(map[string]PlayerAccount),
+		deleted:      make(map[string]bool),
+	}, nil
+}
+
+func (s *MongoStorage) Load() error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	
+	if err := s.client.Ping(ctx, nil); err != nil {
+		return err
+	}
+	
+	for name, indexes := range mongoIndexes {
+		if _, err := s.database.Collection(name).Indexes().CreateMany(ctx, indexes); err != nil {
+			return err
+		}
+	}
+	
+	return nil
+}
+
+func (s *MongoStorage) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if len(s.pending) == 0 && len(s.deleted) == 0 {
+		return nil
+	}
+	
+	writes := make([]mongo.WriteModel, 0, len(s.pending)+len(s.deleted))
+	for key := range s.deleted {
+		writes = append(writes, mongo.NewDeleteOneModel().SetFilter(bson.M{"_id": key}))
+	}
+	for key, account := range s.pending {
+		document := toMongoAccount(&account)
+		document.UUID = key
+		writes = append(writes, mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": key}).SetReplacement(document).SetUpsert(true))
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	if _, err := s.accounts.BulkWrite(ctx, writes); err != nil {
+		return err
+	}
+	
+	s.pending = make(map[string]PlayerAccount)
+	s.deleted = make(map[string]bool)
+	return nil
+}
+
+func (s *MongoStorage) GetAccount(uuid string) (*PlayerAccount, error) {
+	s.mutex.Lock()
+	if account, exists := s.pending[uuid]; exists {
+		s.mutex.Unlock()
+		return &account, nil
+	}
+	deleted := s.deleted[uuid]
+	s.mutex.Unlock()
+	
+	if deleted {
+		return nil, nil
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	
+	var document mongoAccount
+	err := s.accounts.FindOne(ctx, bson.M{"_id": uuid}).Decode(&document)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	return document.account(), nil
+}
+
+func (s *MongoStorage) PutAccount(account *PlayerAccount) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	s.pending[account.UUID] = *account
+	delete(s.deleted, account.UUID)
+	return nil
+}
+
+func (s *MongoStorage) DeleteAccount(uuid string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	delete(s.pending, uuid)
+	s.deleted[uuid] = true
+	return nil
+}
+
+func (s *MongoStorage) ListAccounts() ([]*PlayerAccount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	
+	cursor, err := s.accounts.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	
+	accounts := make(map[string]*PlayerAccount)
+	for cursor.Next(ctx) {
+		var document mongoAccount
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		accounts[document.UUID] = document.account()
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+	
+	s.mutex.Lock()
+	for key := range s.deleted {
+		delete(accounts, key)
+	}
+	for key, account := range s.pending {
+		pending := account
+		accounts[key] = &pending
+	}
+	s.mutex.Unlock()
+	
+	result := make([]*PlayerAccount, 0, len(accounts))
+	for _, account := range accounts {
+		result = append(result, account)
+	}
+	
+	return result, nil
+}
+
+// FindAccount also sees accounts staged since the last Save.
+func (s *MongoStorage) FindAccount(username string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	
+	cursor, err := s.accounts.Find(ctx, bson.M{"username_key": strings.ToLower(username)},
+		options.Find().SetProjection(bson.M{"last_seen": 1}))
+	if err != nil {
+		return "", err
+	}
+	defer cursor.Close(ctx)
+	
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	found, foundSeen := "", time.Time{}
+	consider := func(uuid string, lastSeen time.Time) {
+		if found == "" || lastSeen.After(foundSeen) {
+			found, foundSeen = uuid, lastSeen
+		}
+	}
+	for cursor.Next(ctx) {
+		var document mongoAccount
+		if err := cursor.Decode(&document); err != nil {
+			return "", err
+		}
+		if _, staged := s.pending[document.UUID]; !staged && !s.deleted[document.UUID] {
+			consider(document.UUID, document.LastSeen)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return "", err
+	}
+	for uuid, account := range s.pending {
+		if strings.EqualFold(account.Username, username) {
+			consider(uuid, account.LastSeen)
+		}
+	}
+	
+	return found, nil
+}
+
+func (s *MongoStorage) CountAccounts() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	
+	count, err := s.accounts.CountDocuments(ctx, bson.M{})
+	return int(count), err
+}
+
+func (s *MongoStorage) AppendTransaction(transaction *Transaction) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	
+	_, err := s.transactions.InsertOne(ctx, toMongoTransaction(transaction))
+	return err
+}
+
+func (s *MongoStorage) ReassignTransactions(from, into string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	
+	cursor, err := s.transactions.Find(ctx, bson.M{"players": strings.ToLower(from)})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+	
+	writes := make([]mongo.WriteModel, 0)
+	for cursor.Next(ctx) {
+		var document mongoTransaction
+		if err := cursor.Decode(&document); err != nil {
+			return 0, err
+		}
+		transaction := document.transaction()
+		if !transaction.reassign(from, into) {
+			continue
+		}
+		replacement := toMongoTransaction(&transaction)
+		replacement.ObjectID = document.ObjectID
+		writes = append(writes, mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": document.ObjectID}).SetReplacement(replacement))
+	}
+	if err := cursor.Err(); err != nil {
+		return 0, err
+	}
+	if len(writes) == 0 {
+		return 0, nil
+	}
+	
+	result, err := s.transactions.BulkWrite(ctx, writes)
+	if err != nil {
+		return 0, err
+	}
+	return int(result.ModifiedCount), nil
+}
+
+func (s *MongoStorage) QueryTransactions(username string, filter TransactionFilter) ([]Transaction, error) {
+	query := bson.M{}
+	if username != "" {
+		query["players"] = strings.ToLower(username)
+	}
+	if filter.ID != "" {
+		query["tx_id"] = filter.ID
+	}
+	if !filter.Since.IsZero() || !filter.Until.IsZero() {
+		timestamp := bson.M{}
+		if !filter.Since.IsZero() {
+			timestamp["$gte"] = filter.Since
+		}
+		if !filter.Until.IsZero() {
+			timestamp["$lte"] = filter.Until
+		}
+		query["timestamp"] = timestamp
+	}
+	if len(filter.Types) > 0 {
+		query["type"] = bson.M{"$in": filter.Types}
+	}
+	if filter.MinAmount > 0 || filter.MaxAmount > 0 {
+		amount := bson.M{}
+		if filter.MinAmount > 0 {
+			amount["$gte"] = filter.MinAmount
+		}
+		if filter.MaxAmount > 0 {
+			amount["$lte"] = filter.MaxAmount
+		}
+		query["amount"] = amount
+	}
+	if filter.Reason != "" {
+		query["reason"] = primitive.Regex{Pattern: regexp.QuoteMeta(filter.Reason), Options: "i"}
+	}
+	
+	find := options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: -1}})
+	if filter.Offset > 0 {
+		find.SetSkip(int64(filter.Offset))
+	}
+	if filter.Limit > 0 {
+		find.SetLimit(int64(filter.Limit))
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	
+	cursor, err := s.transactions.Find(ctx, query, find)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	
+	transactions := make([]Transaction, 0)
+	for cursor.Next(ctx) {
+		var document mongoTransaction
+		if err := cursor.Decode(&document); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, document.transaction())
+	}
+	
+	return transactions, cursor.Err()
+}
+
+func (s *MongoStorage) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	
+	return s.client.Disconnect(ctx)
+}
+
+func toMongoAccount(account *PlayerAccount) mongoAccount {
+	return mongoAccount{
+		UUID:        account.UUID,
+		Username:    account.Username,
+		UsernameKey: strings.ToLower(account.Username),
+		Balance:     account.Balance,
+		LastSeen:    account.LastSeen,
+		Created:     account.Created,
+		TotalEarned: account.TotalEarned,
+		TotalSpent:  account.TotalSpent,
+		BankBalance: account.BankBalance,
+		Holds:       account.Holds,
+		Hidden:      account.Hidden,
+		Private:     account.PrivateBalance,
+	}
+}
+
+func (d *mongoAccount) account() *PlayerAccount {
+	return &PlayerAccount{
+		UUID:           d.UUID,
+		Username:       d.Username,
+		Balance:        d.Balance,
+		LastSeen:       d.LastSeen,
+		Created:        d.Created,
+		TotalEarned:    d.TotalEarned,
+		TotalSpent:     d.TotalSpent,
+		BankBalance:    d.BankBalance,
+		Holds:          d.Holds,
+		Hidden:         d.Hidden,
+		PrivateBalance: d.Private,
+	}
+}
+
+func toMongoTransaction(transaction *Transaction) mongoTransaction {
+	players := make([]string, 0, 2+len(transaction.Batch))
+	add := func(name string) {
+		if name == "" {
+			return
+		}
+		name = strings.ToLower(name)
+		for _, player := range players {
+			if player == name {
+				return
+			}
+		}
+		players = append(players, name)
+	}
+	add(transaction.From)
+	add(transaction.To)
+	for _, entry := range transaction.Batch {
+		add(entry.Player)
+	}
+	
+	return mongoTransaction{
+		ID:        transaction.ID,
+		From:      transaction.From,
+		To:        transaction.To,
+		Amount:    transaction.Amount,
+		Type:      transaction.Type,
+		Code:      transaction.Code,
+		Timestamp: transaction.Timestamp,
+		Reason:    transaction.Reason,
+		Previous:  transaction.Previous,
+		Batch:     transaction.Batch,
+		Postings:  transaction.Postings,
+		Players:   players,
+	}
+}
+
+func (d *mongoTransaction) transaction() Transaction {
+	return Transaction{
+		ID:        d.ID,
+		From:      d.From,
+		To:        d.To,
+		Amount:    d.Amount,
+		Type:      d.Type,
+		Code:      d.Code,
+		Timestamp: d.Timestamp,
+		Reason:    d.Reason,
+		Previous:  d.Previous,
+		Batch:     d.Batch,
+		Postings:  d.Postings,
+	}
+}
@@ -0,0 +1,38 @@
+package economy_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	
+	"github.com/percocets100/SimpleEconomy/SimpleEconomy/src/economy"
+	"github.com/percocets100/SimpleEconomy/SimpleEconomy/src/economy/storagetest"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TestMongoStorage runs against the server in ECONOMY_TEST_MONGODB_DSN, as
+// mongodb://host:27017. Each test gets its own database and drops it
+// afterwards.
+func TestMongoStorage(t *testing.T) {
+	uri := serverURL(t, "ECONOMY_TEST_MONGODB_DSN").String()
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	
+	storagetest.Run(t, func(t *testing.T) storagetest.Opener {
+		name := testDatabase(t)
+		t.Cleanup(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := client.Database(name).Drop(ctx); err != nil {
+				t.Logf("dropping %s: %v", name, err)
+			}
+		})
+		
+		config := economy.MongoConfig{URI: uri, Database: name}
+		return func() (economy.Storage, error) { return economy.NewMongoStorage(config) }
+	})
+}
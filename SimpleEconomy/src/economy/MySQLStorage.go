@@ -0,0 +1,637 @@
+package economy
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+	
+	"github.com/go-sql-driver/mysql"
+)
+
+// The username column holds the account's UUID; see migrateAccountKeys.
+const mysqlSchema = `CREATE TABLE IF NOT EXISTS accounts (
+	username        VARCHAR(64) NOT NULL PRIMARY KEY,
+	display_name    VARCHAR(64) NOT NULL,
+	balance         DOUBLE NOT NULL,
+	last_seen       DATETIME(6) NOT NULL,
+	total_earned    DOUBLE NOT NULL,
+	total_spent     DOUBLE NOT NULL,
+	bank_balance    DOUBLE NOT NULL DEFAULT 0,
+	holds           TEXT NULL,
+	created         DATETIME(6) NULL,
+	hidden          BOOLEAN NOT NULL DEFAULT FALSE,
+	private_balance BOOLEAN NOT NULL DEFAULT FALSE
+) ENGINE=InnoDB`
+
+// mysqlColumns lists columns added after the first release so Load can
+// upgrade older databases in place.
+var mysqlColumns = map[string]string{
+	"bank_balance":    "DOUBLE NOT NULL DEFAULT 0",
+	"holds":           "TEXT NULL",
+	"created":         "DATETIME(6) NULL",
+	"hidden":          "BOOLEAN NOT NULL DEFAULT FALSE",
+	"private_balance": "BOOLEAN NOT NULL DEFAULT FALSE",
+}
+
+var mysqlTransactionColumns = map[string]string{
+	"previous": "DOUBLE NULL",
+	"batch":    "TEXT NULL",
+	"code":     "VARCHAR(32) NOT NULL DEFAULT ''",
+	"postings": "TEXT NULL",
+	"tx_id":    "CHAR(26) NOT NULL DEFAULT ''",
+}
+
+const mysqlTransactionsSchema = `CREATE TABLE IF NOT EXISTS transactions (
+	id        BIGINT AUTO_INCREMENT PRIMARY KEY,
+	from_user VARCHAR(64) NOT NULL,
+	to_user   VARCHAR(64) NOT NULL,
+	amount    DOUBLE NOT NULL,
+	type      INT NOT NULL,
+	timestamp DATETIME(6) NOT NULL,
+	reason    VARCHAR(255) NOT NULL,
+	previous  DOUBLE NULL,
+	batch     TEXT NULL,
+	code      VARCHAR(32) NOT NULL DEFAULT '',
+	postings  TEXT NULL,
+	tx_id     CHAR(26) NOT NULL DEFAULT '',
+	INDEX idx_transactions_from (from_user, timestamp),
+	INDEX idx_transactions_to (to_user, timestamp),
+	INDEX idx_transactions_timestamp (timestamp)
+) ENGINE=InnoDB`
+
+const mysqlHistorySchema = `CREATE TABLE IF NOT EXISTS balance_history (
+	uuid    VARCHAR(64) NOT NULL,
+	day     CHAR(10) NOT NULL,
+	balance DOUBLE NOT NULL,
+	PRIMARY KEY (uuid, day),
+	INDEX idx_balance_history_day (day)
+) ENGINE=InnoDB`
+
+const mysqlHistoryUpsert = `INSERT INTO balance_history (uuid, day, balance) VALUES (?, ?, ?)
+ON DUPLICATE KEY UPDATE balance = VALUES(balance)`
+
+const mysqlRewardSchema = `CREATE TABLE IF NOT EXISTS reward_claims (
+	uuid     VARCHAR(64) PRIMARY KEY,
+	last_day CHAR(10) NOT NULL,
+	streak   INT NOT NULL
+) ENGINE=InnoDB`
+
+const mysqlEarningsSchema = `CREATE TABLE IF NOT EXISTS job_earnings (
+	uuid     VARCHAR(64) PRIMARY KEY,
+	day      CHAR(10) NOT NULL,
+	earnings TEXT NOT NULL
+) ENGINE=InnoDB`
+
+const mysqlAuditSchema = `CREATE TABLE IF NOT EXISTS audit_log (
+	id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+	timestamp   DATETIME(6) NOT NULL,
+	admin       VARCHAR(64) NOT NULL,
+	action      VARCHAR(32) NOT NULL,
+	target      VARCHAR(64) NOT NULL,
+	old_balance DOUBLE NULL,
+	new_balance DOUBLE NULL,
+	details     VARCHAR(255) NOT NULL,
+	INDEX idx_audit_admin (admin, timestamp),
+	INDEX idx_audit_target (target, timestamp)
+) ENGINE=InnoDB`
+
+const mysqlNotificationSchema = `CREATE TABLE IF NOT EXISTS pending_notifications (
+	id        BIGINT AUTO_INCREMENT PRIMARY KEY,
+	uuid      VARCHAR(64) NOT NULL,
+	type      INT NOT NULL,
+	from_user VARCHAR(64) NOT NULL,
+	reason    VARCHAR(255) NOT NULL,
+	amount    DOUBLE NOT NULL,
+	timestamp BIGINT NOT NULL,
+	INDEX idx_pending_notifications_uuid (uuid)
+) ENGINE=InnoDB`
+
+// mysqlIndexes lists indexes added after the first release.
+var mysqlIndexes = map[string]string{
+	"idx_accounts_display_name": "accounts (display_name)",
+}
+
+var mysqlTransactionIndexes = map[string]string{
+	"idx_transactions_tx_id": "transactions (tx_id)",
+}
+
+// mysqlSearchIndexes index transaction reasons for /eco search. The ngram
+// parser lets a phrase search find text inside words.
+var mysqlSearchIndexes = map[string]string{
+	"idx_transactions_reason": "transactions (reason) WITH PARSER ngram",
+}
+
+const mysqlSelect = `SELECT username, display_name, balance, last_seen, total_earned, total_spent, bank_balance, holds, created, hidden, private_balance FROM accounts`
+
+// Save never writes balances or holds: those only change through
+// UpdateAccounts, so a server flushing stale in-memory state cannot
+// overwrite another's committed transfer.
+const mysqlUpsert = `INSERT INTO accounts (username, display_name, balance, last_seen, total_earned, total_spent, bank_balance, holds, created, hidden, private_balance)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+	display_name = VALUES(display_name),
+	last_seen = GREATEST(last_seen, VALUES(last_seen)),
+	hidden = VALUES(hidden),
+	private_balance = VALUES(private_balance)`
+
+const mysqlInsertIgnore = `INSERT IGNORE INTO accounts (username, display_name, balance, last_seen, total_earned, total_spent, bank_balance, holds, created, hidden, private_balance)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+const mysqlLock = mysqlSelect + ` WHERE username = ? FOR UPDATE`
+
+const mysqlUpdateBalance = `UPDATE accounts SET balance = ?, total_earned = ?, total_spent = ?, bank_balance = ?, holds = ? WHERE username = ?`
+
+type MySQLStorage struct {
+	db      *sql.DB
+	pending map[string]PlayerAccount
+	mutex   sync.Mutex
+	
+	insertStmt *sql.Stmt
+	lockStmt   *sql.Stmt
+	updateStmt *sql.Stmt
+}
+
+func NewMySQLStorage(config MySQLConfig) (*MySQLStorage, error) {
+	dsn := mysql.Config{
+		User:                 config.User,
+		Passwd:               config.Password,
+		Net:                  "tcp",
+		Addr:                 fmt.Sprintf("%s:%d", config.Host, config.Port),
+		DBName:               config.Database,
+		ParseTime:            true,
+		AllowNativePasswords: true,
+	}
+	
+	db, err := sql.Open("mysql", dsn.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	
+	poolSize := config.PoolSize
+	if poolSize <= 0 {
+		poolSize = 10
+	}
+	db.SetMaxOpenConns(poolSize)
+	db.SetMaxIdleConns(poolSize)
+	db.SetConnMaxLifetime(5 * time.Minute)
+	
+	return &MySQLStorage{
+		db:      db,
+		pending: make(map[string]PlayerAccount),
+	}, nil
+}
+
+func (s *MySQLStorage) Load() error {
+	if _, err := s.db.Exec(mysqlSchema); err != nil {
+		return err
+	}
+	
+	if err := s.upgradeColumns("accounts", mysqlColumns); err != nil {
+		return err
+	}
+	
+	if err := s.upgradeIndexes("INDEX", mysqlIndexes); err != nil {
+		return err
+	}
+	
+	if _, err := s.db.Exec(mysqlTransactionsSchema); err != nil {
+		return err
+	}
+	
+	if err := s.upgradeColumns("transactions", mysqlTransactionColumns); err != nil {
+		return err
+	}
+	
+	if err := s.upgradeIndexes("INDEX", mysqlTransactionIndexes); err != nil {
+		return err
+	}
+	
+	if err := migrateSQLSchema(s.db, "mysql", s.backupForMigration); err != nil {
+		return err
+	}
+	
+	if err := s.upgradeIndexes("FULLTEXT INDEX", mysqlSearchIndexes); err != nil {
+		return err
+	}
+	
+	if _, err := s.db.Exec(mysqlHistorySchema); err != nil {
+		return err
+	}
+	
+	if _, err := s.db.Exec(mysqlRewardSchema); err != nil {
+		return err
+	}
+	
+	if _, err := s.db.Exec(mysqlEarningsSchema); err != nil {
+		return err
+	}
+	
+	if _, err := s.db.Exec(mysqlAuditSchema); err != nil {
+		return err
+	}
+	
+	if _, err := s.db.Exec(mysqlNotificationSchema); err != nil {
+		return err
+	}
+	
+	var err error
+	if s.insertStmt, err = s.db.Prepare(mysqlInsertIgnore); err != nil {
+		return err
+	}
+	if s.lockStmt, err = s.db.Prepare(mysqlLock); err != nil {
+		return err
+	}
+	if s.updateStmt, err = s.db.Prepare(mysqlUpdateBalance); err != nil {
+		return err
+	}
+	
+	return nil
+}
+
+// backupForMigration copies the accounts and transactions tables to
+// <table>_v<version>_backup before the schema is migrated.
+func (s *MySQLStorage) backupForMigration(version int) error {
+	for _, table := range []string{"accounts", "transactions"} {
+		backup := table + "_v" + strconv.Itoa(version) + "_backup"
+		if _, err := s.db.Exec(`DROP TABLE IF EXISTS ` + backup); err != nil {
+			return err
+		}
+		if _, err := s.db.Exec(`CREATE TABLE ` + backup + ` AS SELECT * FROM ` + table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MySQLStorage) upgradeColumns(table string, columns map[string]string) error {
+	for column, definition := range columns {
+		var count int
+		err := s.db.QueryRow(`SELECT COUNT(*) FROM information_schema.COLUMNS
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, table, column).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			if _, err := s.db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition); err != nil {
+				return err
+			}
+		}
+	}
+	
+	return nil
+}
+
+func (s *MySQLStorage) upgradeIndexes(kind string, indexes map[string]string) error {
+	for index, definition := range indexes {
+		var count int
+		err := s.db.QueryRow(`SELECT COUNT(*) FROM information_schema.STATISTICS
+			WHERE TABLE_SCHEMA = DATABASE() AND INDEX_NAME = ?`, index).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			if _, err := s.db.Exec(`CREATE ` + kind + ` ` + index + ` ON ` + definition); err != nil {
+				return err
+			}
+		}
+	}
+	
+	return nil
+}
+
+func (s *MySQLStorage) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if len(s.pending) == 0 {
+		return nil
+	}
+	
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	
+	stmt, err := tx.Prepare(mysqlUpsert)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	
+	for key, account := range s.pending {
+		holds, err := holdsColumn(account.Holds)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := stmt.Exec(key, account.Username, account.Balance, account.LastSeen,
+			account.TotalEarned, account.TotalSpent, account.BankBalance, holds, mysqlCreated(account.Created), account.Hidden, account.PrivateBalance); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	
+	s.pending = make(map[string]PlayerAccount)
+	return nil
+}
+
+func (s *MySQLStorage) GetAccount(uuid string) (*PlayerAccount, error) {
+	account, err := scanMySQLAccount(s.db.QueryRow(mysqlSelect+" WHERE username = ?", uuid))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	
+	return account, err
+}
+
+func (s *MySQLStorage) PutAccount(account *PlayerAccount) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	s.pending[account.UUID] = *account
+	return nil
+}
+
+// DeleteAccount removes the row immediately: other servers read it
+// directly, so there is nothing to stage.
+func (s *MySQLStorage) DeleteAccount(uuid string) error {
+	s.mutex.Lock()
+	delete(s.pending, uuid)
+	s.mutex.Unlock()
+	
+	_, err := s.db.Exec(`DELETE FROM accounts WHERE username = ?`, uuid)
+	return err
+}
+
+func (s *MySQLStorage) ListAccounts() ([]*PlayerAccount, error) {
+	rows, err := s.db.Query(mysqlSelect)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	accounts := make([]*PlayerAccount, 0)
+	for rows.Next() {
+		account, err := scanMySQLAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	
+	return accounts, rows.Err()
+}
+
+// FindAccount relies on the column's case-insensitive collation.
+func (s *MySQLStorage) FindAccount(username string) (string, error) {
+	var uuid string
+	err := s.db.QueryRow(`SELECT username FROM accounts WHERE display_name = ? ORDER BY last_seen DESC LIMIT 1`, username).Scan(&uuid)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	
+	return uuid, err
+}
+
+func (s *MySQLStorage) CountAccounts() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM accounts`).Scan(&count)
+	return count, err
+}
+
+// UpdateAccounts locks rows in lexical key order so two servers running
+// opposite transfers (A->B and B->A) cannot deadlock each other.
+func (s *MySQLStorage) UpdateAccounts(seeds []PlayerAccount, fn func(accounts []*PlayerAccount) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	insertStmt := tx.Stmt(s.insertStmt)
+	lockStmt := tx.Stmt(s.lockStmt)
+	updateStmt := tx.Stmt(s.updateStmt)
+	
+	keys := make([]string, len(seeds))
+	for i, seed := range seeds {
+		keys[i] = seed.UUID
+		holds, err := holdsColumn(seed.Holds)
+		if err != nil {
+			return err
+		}
+		if _, err := insertStmt.Exec(keys[i], seed.Username, seed.Balance, seed.LastSeen,
+			seed.TotalEarned, seed.TotalSpent, seed.BankBalance, holds, mysqlCreated(seed.Created), seed.Hidden, seed.PrivateBalance); err != nil {
+			return err
+		}
+	}
+	
+	ordered := append([]string(nil), keys...)
+	sort.Strings(ordered)
+	
+	locked := make(map[string]*PlayerAccount, len(ordered))
+	for _, key := range ordered {
+		if _, done := locked[key]; done {
+			continue
+		}
+		account, err := scanMySQLAccount(lockStmt.QueryRow(key))
+		if err != nil {
+			return err
+		}
+		locked[key] = account
+	}
+	
+	accounts := make([]*PlayerAccount, len(keys))
+	for i, key := range keys {
+		accounts[i] = locked[key]
+	}
+	
+	if err := fn(accounts); err != nil {
+		return err
+	}
+	
+	for key, account := range locked {
+		holds, err := holdsColumn(account.Holds)
+		if err != nil {
+			return err
+		}
+		if _, err := updateStmt.Exec(account.Balance, account.TotalEarned, account.TotalSpent, account.BankBalance, holds, key); err != nil {
+			return err
+		}
+	}
+	
+	return tx.Commit()
+}
+
+func (s *MySQLStorage) AppendTransaction(transaction *Transaction) error {
+	batch, err := batchColumn(transaction)
+	if err != nil {
+		return err
+	}
+	postings, err := postingsColumn(transaction)
+	if err != nil {
+		return err
+	}
+	
+	_, err = s.db.Exec(`INSERT INTO transactions (tx_id, from_user, to_user, amount, type, timestamp, reason, previous, batch, code, postings) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		transaction.ID, transaction.From, transaction.To, transaction.Amount, int(transaction.Type),
+		transaction.Timestamp, transaction.Reason, transaction.Previous, batch, string(transaction.Code), postings)
+	return err
+}
+
+// mysqlNgramSize is MySQL's default ngram_token_size. Shorter searches
+// cannot use the full-text index.
+const mysqlNgramSize = 2
+
+var mysqlDialect = transactionDialect{
+	timeArg: func(t time.Time) interface{} {
+		return t
+	},
+	reasonMatch: func(text string) (string, []interface{}) {
+		if utf8.RuneCountInString(text) < mysqlNgramSize {
+			return `reason LIKE ? ESCAPE '!'`, []interface{}{likePattern(text)}
+		}
+		// The phrase search narrows rows through the index; LIKE then
+		// keeps only those that really contain the text.
+		phrase := `"` + strings.ReplaceAll(text, `"`, " ") + `"`
+		return `MATCH (reason) AGAINST (? IN BOOLEAN MODE) AND reason LIKE ? ESCAPE '!'`, []interface{}{phrase, likePattern(text)}
+	},
+}
+
+func (s *MySQLStorage) ReassignTransactions(from, into string) (int, error) {
+	return reassignTransactionsSQL(s.db, from, into)
+}
+
+func (s *MySQLStorage) QueryTransactions(username string, filter TransactionFilter) ([]Transaction, error) {
+	query, args := buildTransactionQuery(username, filter, mysqlDialect)
+	
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	transactions := make([]Transaction, 0)
+	for rows.Next() {
+		var transaction Transaction
+		var batch, postings sql.NullString
+		if err := rows.Scan(&transaction.ID, &transaction.From, &transaction.To, &transaction.Amount,
+			&transaction.Type, &transaction.Timestamp, &transaction.Reason, &transaction.Previous, &batch, &transaction.Code, &postings); err != nil {
+			return nil, err
+		}
+		if err := scanBatch(batch, &transaction); err != nil {
+			return nil, err
+		}
+		if err := scanPostings(postings, &transaction); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	
+	return transactions, rows.Err()
+}
+
+func (s *MySQLStorage) RecordBalances(day string, balances map[string]Money) error {
+	return upsertBalanceHistory(s.db, mysqlHistoryUpsert, day, balances)
+}
+
+func (s *MySQLStorage) QueryBalances(uuid string, since string) ([]BalanceSnapshot, error) {
+	return queryBalanceHistory(s.db, uuid, since)
+}
+
+func (s *MySQLStorage) PruneBalances(before string) error {
+	return pruneBalanceHistory(s.db, before)
+}
+
+func (s *MySQLStorage) ClaimReward(uuid, day, yesterday string) (int, bool, error) {
+	return claimRewardSQL(s.db,
+		`INSERT IGNORE INTO reward_claims (uuid, last_day, streak) VALUES (?, '', 0)`,
+		`SELECT last_day, streak FROM reward_claims WHERE uuid = ? FOR UPDATE`,
+		uuid, day, yesterday)
+}
+
+func (s *MySQLStorage) RecordEarnings(uuid, day, job string, amount, jobCap, dailyCap Money) (Money, error) {
+	return recordEarningsSQL(s.db,
+		`INSERT IGNORE INTO job_earnings (uuid, day, earnings) VALUES (?, '', '{}')`,
+		`SELECT day, earnings FROM job_earnings WHERE uuid = ? FOR UPDATE`,
+		uuid, day, job, amount, jobCap, dailyCap)
+}
+
+func (s *MySQLStorage) Earnings(uuid, day string) (map[string]Money, error) {
+	return earningsSQL(s.db, uuid, day)
+}
+
+func (s *MySQLStorage) AppendAudit(entry *AuditEntry) error {
+	_, err := s.db.Exec(`INSERT INTO audit_log (timestamp, admin, action, target, old_balance, new_balance, details) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.Timestamp, entry.Admin, entry.Action, entry.Target, entry.Old, entry.New, clipReference(entry.Details))
+	return err
+}
+
+func (s *MySQLStorage) QueryAudit(name string, offset, limit int) ([]AuditEntry, error) {
+	query, args := buildAuditQuery(name, offset, limit)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		var entry AuditEntry
+		if err := rows.Scan(&entry.Timestamp, &entry.Admin, &entry.Action, &entry.Target, &entry.Old, &entry.New, &entry.Details); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	
+	return entries, rows.Err()
+}
+
+func (s *MySQLStorage) QueueNotification(uuid string, notification Notification) error {
+	return queueNotificationSQL(s.db, uuid, notification)
+}
+
+func (s *MySQLStorage) TakeNotifications(uuid string) ([]Notification, error) {
+	return takeNotificationsSQL(s.db, " FOR UPDATE", uuid)
+}
+
+func (s *MySQLStorage) Close() error {
+	for _, stmt := range []*sql.Stmt{s.insertStmt, s.lockStmt, s.updateStmt} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+	
+	return s.db.Close()
+}
+
+// mysqlCreated stores an unknown creation time as NULL.
+func mysqlCreated(created time.Time) interface{} {
+	if created.IsZero() {
+		return nil
+	}
+	return created
+}
+
+func scanMySQLAccount(row rowScanner) (*PlayerAccount, error) {
+	var account PlayerAccount
+	var holds sql.NullString
+	var created sql.NullTime
+	
+	if err := row.Scan(&account.UUID, &account.Username, &account.Balance, &account.LastSeen,
+		&account.TotalEarned, &account.TotalSpent, &account.BankBalance, &holds, &created, &account.Hidden, &account.PrivateBalance); err != nil {
+		return nil, err
+	}
+	account.Created = created.Time
+	
+	return &account, scanHolds(holds, &account)
+}
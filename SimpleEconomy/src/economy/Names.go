@@ -0,0 +1,145 @@
+package economy
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestDistance is how many edits a suggested name may be away from
+// the one typed.
+const maxSuggestDistance = 2
+
+// AmbiguousNameError is returned by ResolveName for a name that several
+// online players' names start with.
+type AmbiguousNameError struct {
+	Name    string
+	Matches []string
+}
+
+func (err *AmbiguousNameError) Error() string {
+	return ErrAmbiguousName.Error() + ": " + err.Name
+}
+
+func (err *AmbiguousNameError) Unwrap() error {
+	return ErrAmbiguousName
+}
+
+// ResolveName turns a player name typed as a command argument into the
+// account it means. A name with an account is taken as it is; otherwise it
+// may be the start of one online player's name. Failing both, it is taken
+// as typed if account_creation would open an account for it, and is an
+// UnknownAccountError if not.
+func (e *EconomyPlugin) ResolveName(input string) (string, error) {
+	if input == "" {
+		return "", ErrInvalidAccountName
+	}
+	if account, exists := e.lookupAccount(input); exists {
+		e.mutex.RLock()
+		defer e.mutex.RUnlock()
+		
+		return account.Username, nil
+	}
+	
+	if !IsVirtualAccount(input) {
+		matches := e.onlinePlayersMatching(input)
+		if len(matches) == 1 {
+			return matches[0], nil
+		}
+		if len(matches) > 1 {
+			return "", &AmbiguousNameError{Name: input, Matches: matches}
+		}
+	}
+	
+	if err := e.requireAccounts(input); err != nil {
+		return "", err
+	}
+	return input, nil
+}
+
+// onlinePlayersMatching returns the online players whose names start with
+// prefix, ignoring case, sorted.
+func (e *EconomyPlugin) onlinePlayersMatching(prefix string) []string {
+	online, _ := e.getOnlinePlayers()
+	prefix = strings.ToLower(prefix)
+	
+	seen := make(map[string]bool)
+	matches := make([]string, 0)
+	for _, name := range online {
+		key := strings.ToLower(name)
+		if strings.HasPrefix(key, prefix) && !seen[key] {
+			seen[key] = true
+			matches = append(matches, name)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return strings.ToLower(matches[i]) < strings.ToLower(matches[j])
+	})
+	
+	return matches
+}
+
+// completePlayers completes args' last word with online player names,
+// leaving out the sender.
+func (e *EconomyPlugin) completePlayers(ctx *CommandContext) []string {
+	names := e.onlinePlayersMatching(ctx.Args[len(ctx.Args)-1])
+	completions := make([]string, 0, len(names))
+	for _, name := range names {
+		if ctx.IsConsole() || !strings.EqualFold(name, ctx.Name()) {
+			completions = append(completions, name)
+		}
+	}
+	
+	return completions
+}
+
+// suggestAccount returns the loaded player account name closest to name,
+// or "" if none is within maxSuggestDistance edits.
+func (e *EconomyPlugin) suggestAccount(name string) string {
+	target := strings.ToLower(name)
+	best, bestDistance := "", maxSuggestDistance+1
+	
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	
+	for _, account := range e.playerData {
+		if account.virtual() {
+			continue
+		}
+		distance := editDistance(target, strings.ToLower(account.Username))
+		if distance < bestDistance || (distance == bestDistance && account.Username < best) {
+			best, bestDistance = account.Username, distance
+		}
+	}
+	
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	source, target := []rune(a), []rune(b)
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			substitute := previous[j-1]
+			if source[i-1] != target[j-1] {
+				substitute++
+			}
+			current[j] = substitute
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+	
+	return previous[len(target)]
+}
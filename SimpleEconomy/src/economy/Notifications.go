@@ -0,0 +1,356 @@
+package economy
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NotificationConfig controls telling players about money they receive.
+// Receipts below MinAmount are not reported.
+type NotificationConfig struct {
+	Enabled   bool  `json:"enabled"`
+	MinAmount Money `json:"min_amount"`
+}
+
+// PlayerMessenger sends a chat message to an online player. The host
+// server wires one in with SetPlayerMessenger.
+type PlayerMessenger interface {
+	SendMessage(player, message string)
+}
+
+func (e *EconomyPlugin) SetPlayerMessenger(messenger PlayerMessenger) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	
+	e.messenger = messenger
+}
+
+func (e *EconomyPlugin) getMessenger() PlayerMessenger {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	
+	return e.messenger
+}
+
+// receiptNotifier only remembers whether its hooks are registered.
+type receiptNotifier struct {
+	hooks sync.Once
+}
+
+// Notification is money a player received while they could not be told.
+type Notification struct {
+	Type      TransactionType `json:"type"`
+	From      string          `json:"from,omitempty"`
+	Reason    string          `json:"reason,omitempty"`
+	Amount    Money           `json:"amount"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// NotificationStore queues notifications for players until they next
+// join. A notification with the same type, sender and reason as one
+// already queued is added to it, so a long absence does not pile up one
+// entry per interest payout. TakeNotifications returns and removes every
+// queued notification, oldest first.
+type NotificationStore interface {
+	QueueNotification(uuid string, notification Notification) error
+	TakeNotifications(uuid string) ([]Notification, error)
+}
+
+func (n *Notification) merges(other *Notification) bool {
+	return n.Type == other.Type && strings.EqualFold(n.From, other.From) && n.Reason == other.Reason
+}
+
+// JSONNotificationStore keeps every account's queue in one file keyed by
+// UUID.
+type JSONNotificationStore struct {
+	path          string
+	notifications map[string][]Notification
+	mutex         sync.Mutex
+}
+
+func NewJSONNotificationStore(path string) *JSONNotificationStore {
+	return &JSONNotificationStore{path: path}
+}
+
+func (s *JSONNotificationStore) load() error {
+	if s.notifications != nil {
+		return nil
+	}
+	
+	notifications := make(map[string][]Notification)
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &notifications); err != nil {
+			return err
+		}
+	}
+	s.notifications = notifications
+	return nil
+}
+
+// write saves the queues, putting uuid's previous queue back if that
+// fails. It must be called with s.mutex held.
+func (s *JSONNotificationStore) write(uuid string, previous []Notification) error {
+	data, err := json.Marshal(s.notifications)
+	if err == nil {
+		err = writeFileAtomic(s.path, data, 0644)
+	}
+	if err != nil {
+		if previous != nil {
+			s.notifications[uuid] = previous
+		} else {
+			delete(s.notifications, uuid)
+		}
+	}
+	return err
+}
+
+func (s *JSONNotificationStore) QueueNotification(uuid string, notification Notification) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if err := s.load(); err != nil {
+		return err
+	}
+	
+	previous := s.notifications[uuid]
+	queue := append([]Notification(nil), previous...)
+	merged := false
+	for i := range queue {
+		if queue[i].merges(&notification) {
+			queue[i].Amount += notification.Amount
+			queue[i].Timestamp = notification.Timestamp
+			merged = true
+			break
+		}
+	}
+	if !merged {
+		queue = append(queue, notification)
+	}
+	s.notifications[uuid] = queue
+	
+	return s.write(uuid, previous)
+}
+
+func (s *JSONNotificationStore) TakeNotifications(uuid string) ([]Notification, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	
+	queue, exists := s.notifications[uuid]
+	if !exists {
+		return nil, nil
+	}
+	delete(s.notifications, uuid)
+	if err := s.write(uuid, queue); err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+// queueNotificationSQL serves the SQL backends, adding to a matching queued
+// notification when there is one.
+func queueNotificationSQL(db *sql.DB, uuid string, notification Notification) error {
+	result, err := db.Exec(`UPDATE pending_notifications SET amount = amount + ?, timestamp = ?
+		WHERE uuid = ? AND type = ? AND from_user = ? AND reason = ?`,
+		notification.Amount, notification.Timestamp.UnixNano(), uuid, int(notification.Type), notification.From, notification.Reason)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil || updated > 0 {
+		return err
+	}
+	
+	_, err = db.Exec(`INSERT INTO pending_notifications (uuid, type, from_user, reason, amount, timestamp) VALUES (?, ?, ?, ?, ?, ?)`,
+		uuid, int(notification.Type), notification.From, notification.Reason, notification.Amount, notification.Timestamp.UnixNano())
+	return err
+}
+
+// takeNotificationsSQL reads and deletes uuid's queue in one transaction.
+// lock is appended to the read to lock the rows where the database
+// supports it.
+func takeNotificationsSQL(db *sql.DB, lock, uuid string) ([]Notification, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	
+	rows, err := tx.Query(`SELECT type, from_user, reason, amount, timestamp FROM pending_notifications WHERE uuid = ? ORDER BY id`+lock, uuid)
+	if err != nil {
+		return nil, err
+	}
+	
+	notifications := make([]Notification, 0)
+	for rows.Next() {
+		var notification Notification
+		var timestamp int64
+		if err := rows.Scan(&notification.Type, &notification.From, &notification.Reason, &notification.Amount, &timestamp); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		notification.Timestamp = time.Unix(0, timestamp)
+		notifications = append(notifications, notification)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(notifications) == 0 {
+		return nil, nil
+	}
+	
+	if _, err := tx.Exec(`DELETE FROM pending_notifications WHERE uuid = ?`, uuid); err != nil {
+		return nil, err
+	}
+	return notifications, tx.Commit()
+}
+
+// registerNotificationHooks subscribes the receipt notifications to the
+// ledger. Like the fraud checks it only ever subscribes once per plugin.
+func (e *EconomyPlugin) registerNotificationHooks() {
+	e.notifier.hooks.Do(func() {
+		e.OnTransaction(func(transaction Transaction) {
+			if e.config.Notifications.Enabled {
+				e.notifyReceipt(&transaction)
+			}
+		})
+	})
+}
+
+// notifies reports whether a transaction is money a player should be told
+// they received: a payment, refund, delivered pending payment, returned
+// gift, admin or plugin deposit, interest or reward.
+func notifies(transaction *Transaction) bool {
+	switch transaction.Type {
+	case TRANSFER, ADD, INTEREST, REWARD, SALARY, JOB, PENDING_DELIVERY, SAVINGS_MATURED, GIFT_RETURN, REFUND:
+	default:
+		return false
+	}
+	if !isPlayerName(transaction.To) || strings.EqualFold(transaction.From, transaction.To) {
+		return false
+	}
+	return true
+}
+
+// notifyReceipt tells the recipient of a transaction about it, or the
+// payer for a wealth tax.
+func (e *EconomyPlugin) notifyReceipt(transaction *Transaction) {
+	player := transaction.To
+	if transaction.Type == TAX {
+		player = transaction.From
+	} else if !notifies(transaction) {
+		return
+	}
+	player, _ = splitScoped(player)
+	if transaction.Amount < e.config.Notifications.MinAmount {
+		return
+	}
+	
+	e.notifyPlayer(player, Notification{
+		Type:      transaction.Type,
+		From:      transaction.From,
+		Reason:    transaction.Reason,
+		Amount:    transaction.Amount,
+		Timestamp: transaction.Timestamp,
+	})
+}
+
+// notifyPlayer sends a notification at once when the player is online,
+// and queues it for their next join otherwise.
+func (e *EconomyPlugin) notifyPlayer(player string, notification Notification) {
+	if messenger := e.getMessenger(); messenger != nil && e.isOnline(player) {
+		messenger.SendMessage(player, e.describeNotification(&notification, false))
+		return
+	}
+	
+	uuid, exists := e.GetUUID(player)
+	if !exists {
+		return
+	}
+	if err := e.notifications.QueueNotification(uuid, notification); err != nil {
+		e.countStorageError("notifications")
+		e.logger.Warn("Failed to queue notification", "player", player, "error", err)
+	}
+}
+
+func (e *EconomyPlugin) isOnline(username string) bool {
+	online, ok := e.getOnlinePlayers()
+	if !ok {
+		return false
+	}
+	for _, player := range online {
+		if strings.EqualFold(player, username) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *EconomyPlugin) describeNotification(notification *Notification, offline bool) string {
+	key := "notify.received"
+	switch {
+	case notification.Type == INTEREST:
+		key = "notify.interest"
+	case notification.Type == TAX:
+		key = "notify.tax"
+	case notification.Type == TRANSFER && notification.From != "":
+		key = "notify.from"
+	}
+	if offline {
+		key += "_offline"
+	}
+	
+	reason := notification.Reason
+	if reason == "" {
+		reason = notification.Type.String()
+	}
+	return e.message(key, "amount", e.FormatMoney(notification.Amount), "player", notification.From, "reason", reason)
+}
+
+// takeNotifications removes the notifications queued under each of uuids
+// and returns them as messages.
+func (e *EconomyPlugin) takeNotifications(uuids ...string) []string {
+	messages := make([]string, 0)
+	for _, uuid := range uuids {
+		if uuid == "" {
+			continue
+		}
+		notifications, err := e.notifications.TakeNotifications(uuid)
+		if err != nil {
+			e.countStorageError("notifications")
+			e.logger.Warn("Failed to read queued notifications", "uuid", uuid, "error", err)
+			continue
+		}
+		for i := range notifications {
+			messages = append(messages, e.describeNotification(&notifications[i], true))
+		}
+	}
+	return messages
+}
+
+// deliverNotifications sends a joining player what they received while
+// offline, including anything queued for the placeholder account their
+// UUID was just linked to. Without a messenger the queue is kept for
+// /balance instead.
+func (e *EconomyPlugin) deliverNotifications(username string, uuids ...string) {
+	messenger := e.getMessenger()
+	if messenger == nil {
+		return
+	}
+	
+	for _, message := range e.takeNotifications(uuids...) {
+		messenger.SendMessage(username, message)
+	}
+}
@@ -0,0 +1,76 @@
+package economy
+
+import (
+	"fmt"
+	
+	"path/filepath"
+)
+
+// OpenOffline loads an existing data folder for maintenance while the
+// server is stopped, as economyctl does. No servers or background tasks
+// are started; Close saves the changes made and releases the storage.
+func OpenOffline(dataFolder string) (*EconomyPlugin, error) {
+	if findConfigFile(filepath.Join(dataFolder, "config")) == "" {
+		return nil, fmt.Errorf("%s is not a data folder: no config file", dataFolder)
+	}
+	
+	e := NewEconomyPlugin()
+	e.dataFolder = dataFolder
+	if err := e.openData(); err != nil {
+		return nil, err
+	}
+	
+	return e, nil
+}
+
+// Close saves pending changes and closes the storage opened by
+// OpenOffline.
+func (e *EconomyPlugin) Close() error {
+	_, err := e.savePlayerData()
+	if e.journal != nil {
+		e.journal.close()
+	}
+	if closeErr := e.storage.Close(); err == nil {
+		err = closeErr
+	}
+	e.closeLogFile()
+	
+	return err
+}
+
+// Accounts returns a copy of every account.
+func (e *EconomyPlugin) Accounts() []PlayerAccount {
+	return e.snapshotAccounts()
+}
+
+// GetAccount returns a copy of the account holding username.
+func (e *EconomyPlugin) GetAccount(username string) (PlayerAccount, bool) {
+	account, exists := e.lookupAccount(username)
+	if !exists {
+		return PlayerAccount{}, false
+	}
+	
+	return e.snapshotAccount(account), true
+}
+
+// SetBalance sets username's wallet balance, as /money set does, and
+// records reason in the ledger under the ID returned.
+func (e *EconomyPlugin) SetBalance(username string, amount Money, reason string) (string, error) {
+	if !e.hasAccount(username) {
+		return "", ErrAccountNotFound
+	}
+	
+	return e.setBalance(username, amount, reason)
+}
+
+// findAccount looks an account up by UUID or, failing that, by name. Only
+// a UUID reaches an account whose name another account has taken.
+func (e *EconomyPlugin) findAccount(identifier string) (*PlayerAccount, bool) {
+	if uuid := normalizeUUID(identifier); isUUID(uuid) {
+		if account, exists := e.loadAccount(uuid); exists {
+			return account, true
+		}
+	}
+	
+	return e.lookupAccount(identifier)
+}
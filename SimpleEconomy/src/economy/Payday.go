@@ -0,0 +1,91 @@
+package economy
+
+import (
+	"time"
+)
+
+// PaydayConfig pays every online player with an account a salary each
+// IntervalMinutes. Groups are checked in order and the first one whose
+// permission the player holds sets their salary, so list the best-paid
+// tier first; players in no group get Salary.
+type PaydayConfig struct {
+	Enabled         bool          `json:"enabled"`
+	IntervalMinutes int           `json:"interval_minutes"`
+	Salary          Money         `json:"salary"`
+	Groups          []PaydayGroup `json:"groups"`
+}
+
+// PaydayGroup is a salary tier. Permission defaults to
+// economy.payday.<name>.
+type PaydayGroup struct {
+	Name       string `json:"name"`
+	Permission string `json:"permission"`
+	Salary     Money  `json:"salary"`
+}
+
+func (g PaydayGroup) permission() string {
+	if g.Permission != "" {
+		return g.Permission
+	}
+	return "economy.payday." + g.Name
+}
+
+func (e *EconomyPlugin) startPayday() {
+	if !e.config.Payday.Enabled || e.config.Payday.IntervalMinutes <= 0 {
+		return
+	}
+	
+	e.runPeriodically(time.Duration(e.config.Payday.IntervalMinutes)*time.Minute, e.payday)
+}
+
+// payday credits each online player's salary, clamped so nobody is pushed
+// past max_balance. Players without an account are skipped rather than
+// created.
+func (e *EconomyPlugin) payday() {
+	online, ok := e.getOnlinePlayers()
+	if !ok {
+		e.logger.Warn("payday is enabled but no online player provider is registered")
+		return
+	}
+	
+	paid, total := 0, Money(0)
+	for _, username := range online {
+		uuid, exists := e.GetUUID(username)
+		if !exists {
+			continue
+		}
+		
+		salary := e.salaryFor(PlayerSender(username, uuid))
+		if headroom := e.config.MaxBalance - e.getBalance(username); salary > headroom {
+			salary = headroom
+		}
+		if salary <= 0 {
+			continue
+		}
+		salary, err := e.allowMint(username, "salary", salary)
+		if err != nil {
+			continue
+		}
+		
+		if _, err := e.credit(username, salary, SALARY, ReasonReward, "Payday"); err != nil {
+			e.logger.Warn("Failed to pay salary", "player", username, "error", err)
+			continue
+		}
+		paid++
+		total += salary
+	}
+	
+	if paid > 0 {
+		e.logger.Info("Paid salaries", "amount", total.String(), "accounts", paid)
+	}
+}
+
+func (e *EconomyPlugin) salaryFor(sender CommandSender) Money {
+	for _, group := range e.config.Payday.Groups {
+		if e.hasPermission(sender, group.permission()) {
+			return group.Salary
+		}
+	}
+	
+	return e.config.Payday.Salary
+}
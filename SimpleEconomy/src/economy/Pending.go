@@ -0,0 +1,302 @@
+package economy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PendingConfig lets a payment to a player it would take past
+// max_balance go through anyway: what does not fit waits as a pending
+// payment instead of the payment failing.
+type PendingConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// PendingPayment is money paid to To that To had no room for. It is
+// delivered, in part if that is all that fits, once they do.
+type PendingPayment struct {
+	ID      int       `json:"id"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Amount  Money     `json:"amount"`
+	Reason  string    `json:"reason"`
+	Created time.Time `json:"created"`
+}
+
+const pendingCheckInterval = time.Minute
+
+// pendingBook holds every undelivered PendingPayment. delivering marks the
+// players whose payments are being delivered. Like escrowBook's, its mutex
+// is never held while accounts are locked.
+type pendingBook struct {
+	mutex      sync.Mutex
+	payments   map[int]*PendingPayment
+	delivering map[string]bool
+	nextID     int
+}
+
+func pendingRef(id int) string {
+	return "pending#" + strconv.Itoa(id)
+}
+
+func (e *EconomyPlugin) pendingPaymentsPath() string {
+	return filepath.Join(e.dataFolder, "pending_payments.json")
+}
+
+func (e *EconomyPlugin) loadPendingPayments() {
+	e.pending.mutex.Lock()
+	defer e.pending.mutex.Unlock()
+	
+	e.pending.payments = make(map[int]*PendingPayment)
+	e.pending.delivering = make(map[string]bool)
+	e.pending.nextID = 1
+	
+	data, err := ioutil.ReadFile(e.pendingPaymentsPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Error("Failed to read pending payments", "error", err)
+		return
+	}
+	
+	if err := json.Unmarshal(data, &e.pending.payments); err != nil {
+		e.logger.Error("Failed to parse pending payments", "error", err)
+	}
+	for id := range e.pending.payments {
+		if id >= e.pending.nextID {
+			e.pending.nextID = id + 1
+		}
+	}
+}
+
+// savePendingPayments must be called with e.pending.mutex held.
+func (e *EconomyPlugin) savePendingPayments() {
+	data, err := json.MarshalIndent(e.pending.payments, "", "  ")
+	if err != nil {
+		e.logger.Error("Failed to marshal pending payments", "error", err)
+		return
+	}
+	
+	if err := writeFileAtomic(e.pendingPaymentsPath(), data, 0644); err != nil {
+		e.logger.Error("Failed to write pending payments", "error", err)
+		e.countStorageError("pending")
+	}
+}
+
+// queuePendingPayment holds amount for to. Callers have taken the money
+// from the payer already.
+func (e *EconomyPlugin) queuePendingPayment(from, to string, amount Money, reason string) PendingPayment {
+	e.pending.mutex.Lock()
+	defer e.pending.mutex.Unlock()
+	
+	payment := &PendingPayment{
+		ID:      e.pending.nextID,
+		From:    from,
+		To:      to,
+		Amount:  amount,
+		Reason:  reason,
+		Created: e.now(),
+	}
+	e.pending.nextID++
+	e.pending.payments[payment.ID] = payment
+	e.savePendingPayments()
+	
+	return *payment
+}
+
+// GetPendingPayments returns the payments waiting for username, oldest
+// first.
+func (e *EconomyPlugin) GetPendingPayments(username string) []PendingPayment {
+	e.pending.mutex.Lock()
+	defer e.pending.mutex.Unlock()
+	
+	payments := make([]PendingPayment, 0)
+	for _, payment := range e.pending.payments {
+		if strings.EqualFold(payment.To, username) {
+			payments = append(payments, *payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].ID < payments[j].ID
+	})
+	
+	return payments
+}
+
+// DeliverPendingPayments credits username with as much of their pending
+// payments as max_balance leaves room for, oldest first, and returns the
+// amount delivered.
+func (e *EconomyPlugin) DeliverPendingPayments(username string) (Money, error) {
+	key := strings.ToLower(username)
+	e.pending.mutex.Lock()
+	if e.pending.delivering[key] {
+		e.pending.mutex.Unlock()
+		return 0, nil
+	}
+	e.pending.delivering[key] = true
+	e.pending.mutex.Unlock()
+	
+	waiting := e.GetPendingPayments(username)
+	var oldBalance, total Money
+	var delivered []PendingPayment
+	var err error
+	if len(waiting) > 0 {
+		err = e.mutateAccounts([]string{username}, func(accounts []*PlayerAccount) error {
+			account := accounts[0]
+			oldBalance = account.Balance
+			
+			total, delivered = 0, nil
+			for _, part := range waiting {
+				room := e.config.MaxBalance - account.Balance
+				if room <= 0 {
+					break
+				}
+				if part.Amount > room {
+					part.Amount = room
+				}
+				
+				account.Balance += part.Amount
+				account.TotalEarned += part.Amount
+				total += part.Amount
+				delivered = append(delivered, part)
+			}
+			return nil
+		})
+	}
+	
+	e.pending.mutex.Lock()
+	delete(e.pending.delivering, key)
+	if err == nil && len(delivered) > 0 {
+		for _, part := range delivered {
+			payment, exists := e.pending.payments[part.ID]
+			if !exists {
+				continue
+			}
+			if payment.Amount -= part.Amount; payment.Amount <= 0 {
+				delete(e.pending.payments, part.ID)
+			}
+		}
+		e.savePendingPayments()
+	}
+	e.pending.mutex.Unlock()
+	if err != nil || total == 0 {
+		return 0, err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(username, oldBalance, oldBalance+total, PENDING_DELIVERY)
+	for _, payment := range delivered {
+		e.recordTransaction(&Transaction{
+			From:      pendingRef(payment.ID),
+			To:        username,
+			Amount:    payment.Amount,
+			Type:      PENDING_DELIVERY,
+			Timestamp: e.now(),
+			Reason:    payment.Reason,
+		})
+	}
+	
+	return total, nil
+}
+
+// reassignPendingPayments makes the payments waiting for from wait for
+// into instead.
+func (e *EconomyPlugin) reassignPendingPayments(from, into string) {
+	e.pending.mutex.Lock()
+	defer e.pending.mutex.Unlock()
+	
+	changed := false
+	for _, payment := range e.pending.payments {
+		if strings.EqualFold(payment.To, from) {
+			payment.To, changed = into, true
+		}
+	}
+	if changed {
+		e.savePendingPayments()
+	}
+}
+
+func (e *EconomyPlugin) startPendingDelivery() {
+	e.runPeriodically(pendingCheckInterval, e.deliverAllPendingPayments)
+}
+
+// deliverAllPendingPayments delivers what it can to every player with
+// payments waiting.
+func (e *EconomyPlugin) deliverAllPendingPayments() {
+	e.pending.mutex.Lock()
+	recipients := make(map[string]string)
+	for _, payment := range e.pending.payments {
+		recipients[strings.ToLower(payment.To)] = payment.To
+	}
+	e.pending.mutex.Unlock()
+	
+	for _, recipient := range recipients {
+		delivered, err := e.DeliverPendingPayments(recipient)
+		if err != nil {
+			e.logger.Warn("Failed to deliver pending payments", "player", recipient, "error", err)
+			continue
+		}
+		if delivered > 0 {
+			e.logger.Info("Delivered pending payments", "player", recipient, "amount", delivered.String())
+		}
+	}
+}
+
+func (e *EconomyPlugin) pendingCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) != 1 {
+		return e.message("pending.usage")
+	}
+	player := ctx.Name()
+	
+	switch strings.ToLower(args[0]) {
+	case "list":
+		payments := e.GetPendingPayments(player)
+		if len(payments) == 0 {
+			return e.message("pending.none")
+		}
+		var total Money
+		for _, payment := range payments {
+			total += payment.Amount
+		}
+		result := e.message("pending.header", "amount", e.FormatMoney(total))
+		for _, payment := range payments {
+			result += "\n" + e.message("pending.entry", "id", strconv.Itoa(payment.ID), "from", payment.From,
+				"amount", e.FormatMoney(payment.Amount), "reason", payment.Reason)
+		}
+		return result
+		
+	case "claim":
+		payments := e.GetPendingPayments(player)
+		if len(payments) == 0 {
+			return e.message("pending.none")
+		}
+		delivered, err := e.DeliverPendingPayments(player)
+		if err != nil {
+			return e.message("pending.failed", "error", e.describeError(err))
+		}
+		if delivered == 0 {
+			return e.message("pending.no_room", "max", e.FormatMoney(e.config.MaxBalance))
+		}
+		
+		var waiting Money
+		for _, payment := range e.GetPendingPayments(player) {
+			waiting += payment.Amount
+		}
+		if waiting > 0 {
+			return e.message("pending.claimed_some", "amount", e.FormatMoney(delivered), "waiting", e.FormatMoney(waiting))
+		}
+		return e.message("pending.claimed", "amount", e.FormatMoney(delivered))
+		
+	default:
+		return e.message("pending.usage")
+	}
+}
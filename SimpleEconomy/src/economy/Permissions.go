@@ -0,0 +1,88 @@
+package economy
+
+import "strings"
+
+// CommandSender is whoever runs a command: a player or the console.
+type CommandSender interface {
+	Name() string
+	UUID() string
+	IsConsole() bool
+}
+
+type consoleSender struct{}
+
+func (consoleSender) Name() string    { return "CONSOLE" }
+func (consoleSender) UUID() string    { return "" }
+func (consoleSender) IsConsole() bool { return true }
+
+// ConsoleSender is the server console. The default permission check grants
+// it every permission.
+var ConsoleSender CommandSender = consoleSender{}
+
+type playerSender struct {
+	name string
+	uuid string
+}
+
+func (p playerSender) Name() string    { return p.name }
+func (p playerSender) UUID() string    { return p.uuid }
+func (p playerSender) IsConsole() bool { return false }
+
+// PlayerSender returns a CommandSender for a connected player.
+func PlayerSender(name, uuid string) CommandSender {
+	return playerSender{name: name, uuid: normalizeUUID(uuid)}
+}
+
+// PermissionProvider decides whether a sender holds a permission node such
+// as economy.admin.give. The host server wires one in with
+// SetPermissionProvider; without one the console may do everything and
+// players only get the permissions plugin.yml grants by default.
+type PermissionProvider interface {
+	HasPermission(sender CommandSender, permission string) bool
+}
+
+// defaultPermissions are the nodes plugin.yml grants every player.
+var defaultPermissions = map[string]bool{
+	"economy.command.balance":         true,
+	"economy.command.balance.others":  true,
+	"economy.command.balance.private": true,
+	"economy.command.pay":             true,
+	"economy.command.top":             true,
+	"economy.command.stats":           true,
+	"economy.command.stats.others":    true,
+	"economy.command.transactions":    true,
+	"economy.command.receipt":         true,
+	"economy.command.bank":            true,
+	"economy.command.account":         true,
+	"economy.command.daily":           true,
+	"economy.command.earnings":        true,
+	"economy.command.loan":            true,
+	"economy.command.escrow":          true,
+	"economy.command.gift":            true,
+	"economy.command.pending":         true,
+	"economy.command.savings":         true,
+	"economy.command.voucher":         true,
+}
+
+func (e *EconomyPlugin) SetPermissionProvider(provider PermissionProvider) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	
+	e.permissions = provider
+}
+
+func (e *EconomyPlugin) hasPermission(sender CommandSender, permission string) bool {
+	if permission == "" {
+		return true
+	}
+	
+	e.mutex.RLock()
+	provider := e.permissions
+	e.mutex.RUnlock()
+	
+	if provider != nil {
+		return provider.HasPermission(sender, permission)
+	}
+	
+	return sender.IsConsole() || defaultPermissions[strings.ToLower(permission)]
+}
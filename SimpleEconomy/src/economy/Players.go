@@ -0,0 +1,28 @@
+package economy
+
+// OnlinePlayerProvider reports which players are currently connected. The
+// host server wires one in with SetOnlinePlayerProvider.
+type OnlinePlayerProvider interface {
+	OnlinePlayers() []string
+}
+
+func (e *EconomyPlugin) SetOnlinePlayerProvider(provider OnlinePlayerProvider) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	
+	e.onlinePlayers = provider
+}
+
+// getOnlinePlayers returns the connected players, or false when no provider
+// has been registered.
+func (e *EconomyPlugin) getOnlinePlayers() ([]string, bool) {
+	e.mutex.RLock()
+	provider := e.onlinePlayers
+	e.mutex.RUnlock()
+	
+	if provider == nil {
+		return nil, false
+	}
+	
+	return provider.OnlinePlayers(), true
+}
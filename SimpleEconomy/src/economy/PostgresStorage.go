@@ -0,0 +1,643 @@
+package economy
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"hash/fnv"
+	"log/slog"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/lib/pq"
+)
+
+// Names are citext so they compare case-insensitively, as MySQL's
+// collation does; the SQL helpers shared with the other backends rely on
+// it. The username column holds the account's UUID.
+var postgresSchema = []string{
+	`CREATE EXTENSION IF NOT EXISTS citext`,
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+	`CREATE TABLE IF NOT EXISTS accounts (
+		username        VARCHAR(64) PRIMARY KEY,
+		display_name    CITEXT NOT NULL,
+		balance         DOUBLE PRECISION NOT NULL,
+		last_seen       TIMESTAMPTZ NOT NULL,
+		total_earned    DOUBLE PRECISION NOT NULL,
+		total_spent     DOUBLE PRECISION NOT NULL,
+		bank_balance    DOUBLE PRECISION NOT NULL DEFAULT 0,
+		holds           TEXT,
+		created         TIMESTAMPTZ,
+		hidden          BOOLEAN NOT NULL DEFAULT FALSE,
+		private_balance BOOLEAN NOT NULL DEFAULT FALSE
+	)`,
+	`ALTER TABLE accounts ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE accounts ADD COLUMN IF NOT EXISTS private_balance BOOLEAN NOT NULL DEFAULT FALSE`,
+	`CREATE INDEX IF NOT EXISTS idx_accounts_display_name ON accounts (display_name)`,
+	`CREATE TABLE IF NOT EXISTS transactions (
+		id        BIGSERIAL PRIMARY KEY,
+		tx_id     VARCHAR(64) NOT NULL DEFAULT '',
+		from_user CITEXT NOT NULL,
+		to_user   CITEXT NOT NULL,
+		amount    DOUBLE PRECISION NOT NULL,
+		type      INTEGER NOT NULL,
+		timestamp TIMESTAMPTZ NOT NULL,
+		reason    VARCHAR(255) NOT NULL,
+		previous  DOUBLE PRECISION,
+		batch     CITEXT,
+		code      VARCHAR(32) NOT NULL DEFAULT '',
+		postings  TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_transactions_from ON transactions (from_user, timestamp)`,
+	`CREATE INDEX IF NOT EXISTS idx_transactions_to ON transactions (to_user, timestamp)`,
+	`CREATE INDEX IF NOT EXISTS idx_transactions_timestamp ON transactions (timestamp)`,
+	`CREATE INDEX IF NOT EXISTS idx_transactions_tx_id ON transactions (tx_id)`,
+	// A trigram index lets ILIKE '%text%' searches for /eco search use it.
+	`CREATE INDEX IF NOT EXISTS idx_transactions_reason ON transactions USING gin (reason gin_trgm_ops)`,
+	`CREATE TABLE IF NOT EXISTS balance_history (
+		uuid    VARCHAR(64) NOT NULL,
+		day     CHAR(10) NOT NULL,
+		balance DOUBLE PRECISION NOT NULL,
+		PRIMARY KEY (uuid, day)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_balance_history_day ON balance_history (day)`,
+	`CREATE TABLE IF NOT EXISTS reward_claims (
+		uuid     VARCHAR(64) PRIMARY KEY,
+		last_day CHAR(10) NOT NULL,
+		streak   INTEGER NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS job_earnings (
+		uuid     VARCHAR(64) PRIMARY KEY,
+		day      CHAR(10) NOT NULL,
+		earnings TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id          BIGSERIAL PRIMARY KEY,
+		timestamp   TIMESTAMPTZ NOT NULL,
+		admin       CITEXT NOT NULL,
+		action      VARCHAR(32) NOT NULL,
+		target      CITEXT NOT NULL,
+		old_balance DOUBLE PRECISION,
+		new_balance DOUBLE PRECISION,
+		details     VARCHAR(255) NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_admin ON audit_log (admin, timestamp)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_target ON audit_log (target, timestamp)`,
+	`CREATE TABLE IF NOT EXISTS pending_notifications (
+		id        BIGSERIAL PRIMARY KEY,
+		uuid      VARCHAR(64) NOT NULL,
+		type      INTEGER NOT NULL,
+		from_user VARCHAR(64) NOT NULL,
+		reason    VARCHAR(255) NOT NULL,
+		amount    DOUBLE PRECISION NOT NULL,
+		timestamp BIGINT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_pending_notifications_uuid ON pending_notifications (uuid)`,
+}
+
+const postgresSelect = `SELECT username, display_name, balance, last_seen, total_earned, total_spent, bank_balance, holds, created, hidden, private_balance FROM accounts`
+
+// Save never writes balances or holds, for the reason given on
+// mysqlUpsert.
+const postgresUpsert = `INSERT INTO accounts (username, display_name, balance, last_seen, total_earned, total_spent, bank_balance, holds, created, hidden, private_balance)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (username) DO UPDATE SET
+	display_name = EXCLUDED.display_name,
+	last_seen = GREATEST(accounts.last_seen, EXCLUDED.last_seen),
+	hidden = EXCLUDED.hidden,
+	private_balance = EXCLUDED.private_balance`
+
+const postgresInsertIgnore = `INSERT INTO accounts (username, display_name, balance, last_seen, total_earned, total_spent, bank_balance, holds, created, hidden, private_balance)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (username) DO NOTHING`
+
+const postgresUpdateBalance = `UPDATE accounts SET balance = ?, total_earned = ?, total_spent = ?, bank_balance = ?, holds = ? WHERE username = ?`
+
+// postgresChannel is the LISTEN/NOTIFY channel UpdateAccounts announces
+// changed accounts on. Each payload is the sending storage's instance ID
+// and the account's UUID, separated by a colon.
+const postgresChannel = "simpleeconomy_accounts"
+
+// PostgresStorage shares accounts between servers like MySQLStorage. It
+// also announces every committed balance change over LISTEN/NOTIFY, so
+// the other servers can refresh the account at once instead of rereading
+// it on every access, and serializes UpdateAccounts on advisory locks.
+type PostgresStorage struct {
+	db       *sql.DB
+	dsn      string
+	instance string
+	pending  map[string]PlayerAccount
+	mutex    sync.Mutex
+	listener *pq.Listener
+}
+
+func NewPostgresStorage(config PostgresConfig) (*PostgresStorage, error) {
+	query := url.Values{}
+	query.Set("sslmode", config.SSLMode)
+	dsn := (&url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(config.User, config.Password),
+		Host:     net.JoinHostPort(config.Host, strconv.Itoa(config.Port)),
+		Path:     "/" + config.Database,
+		RawQuery: query.Encode(),
+	}).String()
+	
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(postgresConnector{connector})
+	
+	poolSize := config.PoolSize
+	if poolSize <= 0 {
+		poolSize = 10
+	}
+	db.SetMaxOpenConns(poolSize)
+	db.SetMaxIdleConns(poolSize)
+	db.SetConnMaxLifetime(5 * time.Minute)
+	
+	instance := make([]byte, 8)
+	if _, err := rand.Read(instance); err != nil {
+		return nil, err
+	}
+	
+	return &PostgresStorage{
+		db:       db,
+		dsn:      dsn,
+		instance: hex.EncodeToString(instance),
+		pending:  make(map[string]PlayerAccount),
+	}, nil
+}
+
+func (s *PostgresStorage) Load() error {
+	for _, statement := range postgresSchema {
+		if _, err := s.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	
+	return migrateSQLSchema(s.db, "postgres", s.backupForMigration)
+}
+
+// backupForMigration copies the accounts and transactions tables to
+// <table>_v<version>_backup before the schema is migrated.
+func (s *PostgresStorage) backupForMigration(version int) error {
+	for _, table := range []string{"accounts", "transactions"} {
+		backup := table + "_v" + strconv.Itoa(version) + "_backup"
+		if _, err := s.db.Exec(`DROP TABLE IF EXISTS ` + backup); err != nil {
+			return err
+		}
+		if _, err := s.db.Exec(`CREATE TABLE ` + backup + ` AS SELECT * FROM ` + table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PostgresStorage) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if len(s.pending) == 0 {
+		return nil
+	}
+	
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	
+	stmt, err := tx.Prepare(postgresUpsert)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	
+	for key, account := range s.pending {
+		holds, err := holdsColumn(account.Holds)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := stmt.Exec(key, account.Username, account.Balance, account.LastSeen,
+			account.TotalEarned, account.TotalSpent, account.BankBalance, holds, mysqlCreated(account.Created), account.Hidden, account.PrivateBalance); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	
+	s.pending = make(map[string]PlayerAccount)
+	return nil
+}
+
+// The accounts table has the same columns as MySQL's, so rows scan with
+// scanMySQLAccount.
+func (s *PostgresStorage) GetAccount(uuid string) (*PlayerAccount, error) {
+	account, err := scanMySQLAccount(s.db.QueryRow(postgresSelect+" WHERE username = ?", uuid))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	
+	return account, err
+}
+
+func (s *PostgresStorage) PutAccount(account *PlayerAccount) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	s.pending[account.UUID] = *account
+	return nil
+}
+
+// DeleteAccount removes the row immediately, as MySQLStorage does.
+func (s *PostgresStorage) DeleteAccount(uuid string) error {
+	s.mutex.Lock()
+	delete(s.pending, uuid)
+	s.mutex.Unlock()
+	
+	_, err := s.db.Exec(`DELETE FROM accounts WHERE username = ?`, uuid)
+	return err
+}
+
+func (s *PostgresStorage) ListAccounts() ([]*PlayerAccount, error) {
+	rows, err := s.db.Query(postgresSelect)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	accounts := make([]*PlayerAccount, 0)
+	for rows.Next() {
+		account, err := scanMySQLAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	
+	return accounts, rows.Err()
+}
+
+func (s *PostgresStorage) FindAccount(username string) (string, error) {
+	var uuid string
+	err := s.db.QueryRow(`SELECT username FROM accounts WHERE display_name = ? ORDER BY last_seen DESC LIMIT 1`, username).Scan(&uuid)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	
+	return uuid, err
+}
+
+func (s *PostgresStorage) CountAccounts() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM accounts`).Scan(&count)
+	return count, err
+}
+
+// postgresLockKey is the advisory lock UpdateAccounts takes for an
+// account.
+func postgresLockKey(uuid string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(uuid))
+	return int64(hash.Sum64())
+}
+
+// UpdateAccounts takes a transaction-scoped advisory lock per account, in
+// ascending key order so two servers running opposite transfers cannot
+// deadlock, before creating or reading any row. The locks are released
+// when the transaction ends, and the changed accounts are announced on
+// postgresChannel as it commits.
+func (s *PostgresStorage) UpdateAccounts(seeds []PlayerAccount, fn func(accounts []*PlayerAccount) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	
+	lockKeys := make([]int64, 0, len(seeds))
+	seen := make(map[int64]bool, len(seeds))
+	for _, seed := range seeds {
+		if key := postgresLockKey(seed.UUID); !seen[key] {
+			seen[key] = true
+			lockKeys = append(lockKeys, key)
+		}
+	}
+	sort.Slice(lockKeys, func(i, j int) bool { return lockKeys[i] < lockKeys[j] })
+	for _, key := range lockKeys {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(?)`, key); err != nil {
+			return err
+		}
+	}
+	
+	locked := make(map[string]*PlayerAccount, len(seeds))
+	accounts := make([]*PlayerAccount, len(seeds))
+	for i, seed := range seeds {
+		if account, done := locked[seed.UUID]; done {
+			accounts[i] = account
+			continue
+		}
+		holds, err := holdsColumn(seed.Holds)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(postgresInsertIgnore, seed.UUID, seed.Username, seed.Balance, seed.LastSeen,
+			seed.TotalEarned, seed.TotalSpent, seed.BankBalance, holds, mysqlCreated(seed.Created), seed.Hidden, seed.PrivateBalance); err != nil {
+			return err
+		}
+		account, err := scanMySQLAccount(tx.QueryRow(postgresSelect+" WHERE username = ?", seed.UUID))
+		if err != nil {
+			return err
+		}
+		locked[seed.UUID] = account
+		accounts[i] = account
+	}
+	
+	if err := fn(accounts); err != nil {
+		return err
+	}
+	
+	for key, account := range locked {
+		holds, err := holdsColumn(account.Holds)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(postgresUpdateBalance, account.Balance, account.TotalEarned, account.TotalSpent, account.BankBalance, holds, key); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`SELECT pg_notify(?, ?)`, postgresChannel, s.instance+":"+key); err != nil {
+			return err
+		}
+	}
+	
+	return tx.Commit()
+}
+
+// WatchChanges listens on its own connection, which reconnects by itself.
+// Notifications sent while it was down are lost, so fn is called with ""
+// once it is back.
+func (s *PostgresStorage) WatchChanges(fn func(uuid string)) error {
+	listener := pq.NewListener(s.dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("Postgres change listener", "error", err)
+		}
+	})
+	if err := listener.Listen(postgresChannel); err != nil {
+		listener.Close()
+		return err
+	}
+	
+	s.mutex.Lock()
+	s.listener = listener
+	s.mutex.Unlock()
+	
+	go func() {
+		for {
+			select {
+			case notification, open := <-listener.Notify:
+				if !open {
+					return
+				}
+				if notification == nil {
+					fn("")
+					continue
+				}
+				instance, uuid, _ := strings.Cut(notification.Extra, ":")
+				if instance != s.instance {
+					fn(uuid)
+				}
+			case <-time.After(90 * time.Second):
+				// Ping so a silently dropped connection is noticed.
+				go listener.Ping()
+			}
+		}
+	}()
+	
+	return nil
+}
+
+func (s *PostgresStorage) AppendTransaction(transaction *Transaction) error {
+	batch, err := batchColumn(transaction)
+	if err != nil {
+		return err
+	}
+	postings, err := postingsColumn(transaction)
+	if err != nil {
+		return err
+	}
+	
+	_, err = s.db.Exec(`INSERT INTO transactions (tx_id, from_user, to_user, amount, type, timestamp, reason, previous, batch, code, postings) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		transaction.ID, transaction.From, transaction.To, transaction.Amount, int(transaction.Type),
+		transaction.Timestamp, transaction.Reason, transaction.Previous, batch, string(transaction.Code), postings)
+	return err
+}
+
+var postgresDialect = transactionDialect{
+	timeArg: func(t time.Time) interface{} {
+		return t
+	},
+	reasonMatch: func(text string) (string, []interface{}) {
+		return `reason ILIKE ? ESCAPE '!'`, []interface{}{likePattern(text)}
+	},
+}
+
+func (s *PostgresStorage) ReassignTransactions(from, into string) (int, error) {
+	return reassignTransactionsSQL(s.db, from, into)
+}
+
+func (s *PostgresStorage) QueryTransactions(username string, filter TransactionFilter) ([]Transaction, error) {
+	query, args := buildTransactionQuery(username, filter, postgresDialect)
+	
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	transactions := make([]Transaction, 0)
+	for rows.Next() {
+		var transaction Transaction
+		var batch, postings sql.NullString
+		if err := rows.Scan(&transaction.ID, &transaction.From, &transaction.To, &transaction.Amount,
+			&transaction.Type, &transaction.Timestamp, &transaction.Reason, &transaction.Previous, &batch, &transaction.Code, &postings); err != nil {
+			return nil, err
+		}
+		if err := scanBatch(batch, &transaction); err != nil {
+			return nil, err
+		}
+		if err := scanPostings(postings, &transaction); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	
+	return transactions, rows.Err()
+}
+
+func (s *PostgresStorage) RecordBalances(day string, balances map[string]Money) error {
+	return upsertBalanceHistory(s.db, `INSERT INTO balance_history (uuid, day, balance) VALUES (?, ?, ?)
+ON CONFLICT (uuid, day) DO UPDATE SET balance = EXCLUDED.balance`, day, balances)
+}
+
+func (s *PostgresStorage) QueryBalances(uuid string, since string) ([]BalanceSnapshot, error) {
+	return queryBalanceHistory(s.db, uuid, since)
+}
+
+func (s *PostgresStorage) PruneBalances(before string) error {
+	return pruneBalanceHistory(s.db, before)
+}
+
+func (s *PostgresStorage) ClaimReward(uuid, day, yesterday string) (int, bool, error) {
+	return claimRewardSQL(s.db,
+		`INSERT INTO reward_claims (uuid, last_day, streak) VALUES (?, '', 0) ON CONFLICT (uuid) DO NOTHING`,
+		`SELECT last_day, streak FROM reward_claims WHERE uuid = ? FOR UPDATE`,
+		uuid, day, yesterday)
+}
+
+func (s *PostgresStorage) RecordEarnings(uuid, day, job string, amount, jobCap, dailyCap Money) (Money, error) {
+	return recordEarningsSQL(s.db,
+		`INSERT INTO job_earnings (uuid, day, earnings) VALUES (?, '', '{}') ON CONFLICT (uuid) DO NOTHING`,
+		`SELECT day, earnings FROM job_earnings WHERE uuid = ? FOR UPDATE`,
+		uuid, day, job, amount, jobCap, dailyCap)
+}
+
+func (s *PostgresStorage) Earnings(uuid, day string) (map[string]Money, error) {
+	return earningsSQL(s.db, uuid, day)
+}
+
+func (s *PostgresStorage) AppendAudit(entry *AuditEntry) error {
+	_, err := s.db.Exec(`INSERT INTO audit_log (timestamp, admin, action, target, old_balance, new_balance, details) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.Timestamp, entry.Admin, entry.Action, entry.Target, entry.Old, entry.New, clipReference(entry.Details))
+	return err
+}
+
+func (s *PostgresStorage) QueryAudit(name string, offset, limit int) ([]AuditEntry, error) {
+	query, args := buildAuditQuery(name, offset, limit)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		var entry AuditEntry
+		if err := rows.Scan(&entry.Timestamp, &entry.Admin, &entry.Action, &entry.Target, &entry.Old, &entry.New, &entry.Details); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	
+	return entries, rows.Err()
+}
+
+func (s *PostgresStorage) QueueNotification(uuid string, notification Notification) error {
+	return queueNotificationSQL(s.db, uuid, notification)
+}
+
+func (s *PostgresStorage) TakeNotifications(uuid string) ([]Notification, error) {
+	return takeNotificationsSQL(s.db, " FOR UPDATE", uuid)
+}
+
+func (s *PostgresStorage) Close() error {
+	s.mutex.Lock()
+	listener := s.listener
+	s.listener = nil
+	s.mutex.Unlock()
+	
+	if listener != nil {
+		listener.Close()
+	}
+	return s.db.Close()
+}
+
+// postgresConnector wraps lib/pq's connections so the ? placeholders the
+// SQL helpers share with the other backends become $1, $2 and so on.
+type postgresConnector struct {
+	driver.Connector
+}
+
+func (c postgresConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return postgresConn{conn}, nil
+}
+
+type postgresConn struct {
+	driver.Conn
+}
+
+func (c postgresConn) Prepare(query string) (driver.Stmt, error) {
+	return c.Conn.Prepare(rebindPostgres(query))
+}
+
+func (c postgresConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Conn.(driver.ConnPrepareContext).PrepareContext(ctx, rebindPostgres(query))
+}
+
+func (c postgresConn) BeginTx(ctx context.Context, options driver.TxOptions) (driver.Tx, error) {
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, options)
+}
+
+func (c postgresConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, rebindPostgres(query), args)
+}
+
+func (c postgresConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, rebindPostgres(query), args)
+}
+
+// The rest keep the pool's view of lib/pq's connections as it would be
+// without the wrapper.
+
+func (c postgresConn) CheckNamedValue(value *driver.NamedValue) error {
+	return c.Conn.(driver.NamedValueChecker).CheckNamedValue(value)
+}
+
+func (c postgresConn) Ping(ctx context.Context) error {
+	return c.Conn.(driver.Pinger).Ping(ctx)
+}
+
+func (c postgresConn) ResetSession(ctx context.Context) error {
+	return c.Conn.(driver.SessionResetter).ResetSession(ctx)
+}
+
+func (c postgresConn) IsValid() bool {
+	return c.Conn.(driver.Validator).IsValid()
+}
+
+// rebindPostgres numbers the ? placeholders in query, leaving quoted
+// text alone.
+func rebindPostgres(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+	
+	var rebound strings.Builder
+	quoted, n := false, 0
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			rebound.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		rebound.WriteRune(r)
+	}
+	return rebound.String()
+}
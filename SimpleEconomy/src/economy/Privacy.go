@@ -0,0 +1,58 @@
+package economy
+
+import "strings"
+
+// SetBalancePrivate sets whether other players are refused when they ask
+// for username's balance, transactions or balance history. Players with
+// economy.privacy.bypass, and the console, still see them.
+func (e *EconomyPlugin) SetBalancePrivate(username string, private bool) error {
+	account, exists := e.lookupAccount(username)
+	if !exists {
+		return ErrAccountNotFound
+	}
+	
+	unlock := e.locks.lock(account)
+	account.PrivateBalance = private
+	unlock()
+	
+	e.markDirty(account)
+	return nil
+}
+
+// balancePrivate reports whether sender is refused username's balance.
+func (e *EconomyPlugin) balancePrivate(sender CommandSender, username string) bool {
+	if strings.EqualFold(sender.Name(), username) && !sender.IsConsole() || e.hasPermission(sender, "economy.privacy.bypass") {
+		return false
+	}
+	account, exists := e.lookupAccount(username)
+	if !exists {
+		return false
+	}
+	return e.snapshotAccount(account).PrivateBalance
+}
+
+func (e *EconomyPlugin) balancePrivacyCommand(ctx *CommandContext) string {
+	if ctx.IsConsole() {
+		return e.message("command.player_only")
+	}
+	if !e.hasPermission(ctx.Sender, "economy.command.balance.private") {
+		return e.message("command.no_permission")
+	}
+	
+	var private bool
+	switch strings.ToLower(ctx.Args[0]) {
+	case "on":
+		private = true
+	case "off":
+	default:
+		return e.message("balance.usage")
+	}
+	
+	if err := e.SetBalancePrivate(ctx.Name(), private); err != nil {
+		return e.describeError(err)
+	}
+	if private {
+		return e.message("balance.private_on")
+	}
+	return e.message("balance.private_off")
+}
@@ -0,0 +1,137 @@
+package economy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxQuestIDLength = 64
+
+// questBook remembers which quest rewards each account has been granted,
+// keyed by account UUID and then quest ID so that a rename keeps them.
+// paying marks claims whose reward is being credited. Its mutex is never
+// held while accounts are locked.
+type questBook struct {
+	mutex   sync.Mutex
+	claimed map[string]map[string]time.Time
+	paying  map[string]bool
+}
+
+func questKey(uuid, questID string) string {
+	return uuid + "/" + questID
+}
+
+func validQuestID(questID string) bool {
+	return strings.TrimSpace(questID) != "" && len(questID) <= maxQuestIDLength
+}
+
+func (e *EconomyPlugin) questsPath() string {
+	return filepath.Join(e.dataFolder, "quests.json")
+}
+
+func (e *EconomyPlugin) loadQuests() {
+	e.quests.mutex.Lock()
+	defer e.quests.mutex.Unlock()
+	
+	e.quests.claimed = make(map[string]map[string]time.Time)
+	e.quests.paying = make(map[string]bool)
+	
+	data, err := ioutil.ReadFile(e.questsPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Error("Failed to read quest claims", "error", err)
+		return
+	}
+	
+	if err := json.Unmarshal(data, &e.quests.claimed); err != nil {
+		e.logger.Error("Failed to parse quest claims", "error", err)
+	}
+}
+
+// saveQuests must be called with e.quests.mutex held.
+func (e *EconomyPlugin) saveQuests() {
+	data, err := json.MarshalIndent(e.quests.claimed, "", "  ")
+	if err != nil {
+		e.logger.Error("Failed to marshal quest claims", "error", err)
+		return
+	}
+	
+	if err := writeFileAtomic(e.questsPath(), data, 0644); err != nil {
+		e.logger.Error("Failed to write quest claims", "error", err)
+		e.countStorageError("quests")
+	}
+}
+
+// GrantReward pays player amount for completing questID and returns the
+// ID of the REWARD transaction recorded. Each quest pays a player once:
+// asking again returns ErrQuestClaimed, even across restarts. The supply
+// cap may cut the reward, or refuse it with ErrSupplyCapReached.
+// OnRewardGranted listeners run once the reward is paid.
+func (e *EconomyPlugin) GrantReward(player string, amount Money, questID string) (string, error) {
+	if !validQuestID(questID) {
+		return "", ErrInvalidQuest
+	}
+	amount = e.roundAmount(amount)
+	if amount <= 0 {
+		return "", ErrInvalidAmount
+	}
+	uuid, exists := e.GetUUID(player)
+	if !exists {
+		return "", ErrAccountNotFound
+	}
+	
+	key := questKey(uuid, questID)
+	e.quests.mutex.Lock()
+	_, claimed := e.quests.claimed[uuid][questID]
+	if claimed || e.quests.paying[key] {
+		e.quests.mutex.Unlock()
+		return "", ErrQuestClaimed
+	}
+	e.quests.paying[key] = true
+	e.quests.mutex.Unlock()
+	
+	amount, err := e.allowMint(player, "quest "+questID, amount)
+	var id string
+	if err == nil {
+		id, err = e.credit(player, amount, REWARD, ReasonReward, "Quest reward: "+questID)
+	}
+	
+	e.quests.mutex.Lock()
+	delete(e.quests.paying, key)
+	if err == nil {
+		if e.quests.claimed[uuid] == nil {
+			e.quests.claimed[uuid] = make(map[string]time.Time)
+		}
+		e.quests.claimed[uuid][questID] = e.now()
+		e.saveQuests()
+	}
+	e.quests.mutex.Unlock()
+	if err != nil {
+		return "", err
+	}
+	
+	e.fireRewardGranted(RewardGrantedEvent{Player: player, QuestID: questID, Amount: amount, TransactionID: id})
+	return id, nil
+}
+
+// HasClaimedReward reports whether player has been granted questID's
+// reward.
+func (e *EconomyPlugin) HasClaimedReward(player, questID string) bool {
+	uuid, exists := e.GetUUID(player)
+	if !exists {
+		return false
+	}
+	
+	e.quests.mutex.Lock()
+	defer e.quests.mutex.Unlock()
+	
+	_, claimed := e.quests.claimed[uuid][questID]
+	return claimed
+}
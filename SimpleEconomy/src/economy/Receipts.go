@@ -0,0 +1,50 @@
+package economy
+
+import (
+	"errors"
+	"strings"
+)
+
+// receiptCommand shows one transaction by ID. Players see their own
+// transactions; others' need economy.command.receipt.others and are
+// reported as not found otherwise, so IDs cannot be probed.
+func (e *EconomyPlugin) receiptCommand(ctx *CommandContext) string {
+	if len(ctx.Args) != 1 {
+		return e.message("receipt.usage")
+	}
+	
+	transaction, err := e.GetTransaction(strings.TrimPrefix(ctx.Args[0], "#"))
+	if errors.Is(err, ErrTransactionNotFound) {
+		return e.message("receipt.not_found")
+	}
+	if err != nil {
+		return e.message("receipt.failed", "error", e.describeError(err))
+	}
+	
+	from, _ := splitScoped(transaction.From)
+	to, _ := splitScoped(transaction.To)
+	own := strings.EqualFold(from, ctx.Name()) || strings.EqualFold(to, ctx.Name())
+	if !own && !e.hasPermission(ctx.Sender, "economy.command.receipt.others") {
+		return e.message("receipt.not_found")
+	}
+	
+	result := e.message("receipt.header", "id", transaction.ID) + "\n" +
+		e.message("receipt.entry",
+			"time", transaction.Timestamp.Format("2006-01-02 15:04:05"),
+			"type", transaction.Type.String(),
+			"from", transaction.From,
+			"to", transaction.To,
+			"amount", e.FormatMoney(transaction.Amount))
+	if transaction.Reason != "" {
+		result += "\n" + e.message("receipt.reason", "reason", transaction.Reason)
+	}
+	
+	e.refunds.mutex.Lock()
+	refund, refunded := e.refunds.refunded[transaction.ID]
+	e.refunds.mutex.Unlock()
+	if refunded {
+		result += "\n" + e.message("receipt.refunded", "id", refund)
+	}
+	
+	return result
+}
@@ -0,0 +1,305 @@
+package economy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Receivable is what a player still owes after a refund took more than
+// they could spend. It is collected from their wallet as money comes in
+// and paid on to Creditor.
+type Receivable struct {
+	ID       int       `json:"id"`
+	Debtor   string    `json:"debtor"`
+	Creditor string    `json:"creditor"`
+	Amount   Money     `json:"amount"`
+	Refund   string    `json:"refund"`
+	Created  time.Time `json:"created"`
+}
+
+const receivableCheckInterval = time.Minute
+
+// refundBook remembers which transactions have been refunded, by the ID
+// of their refund, and the receivables still open. refunding marks
+// transactions whose refund is being paid. Its mutex is never held while
+// accounts are locked.
+type refundBook struct {
+	mutex       sync.Mutex
+	refunded    map[string]string
+	refunding   map[string]bool
+	receivables map[int]*Receivable
+	nextID      int
+}
+
+type refundFile struct {
+	Refunded    map[string]string   `json:"refunded"`
+	Receivables map[int]*Receivable `json:"receivables"`
+}
+
+func (e *EconomyPlugin) refundsPath() string {
+	return filepath.Join(e.dataFolder, "refunds.json")
+}
+
+func (e *EconomyPlugin) loadRefunds() {
+	e.refunds.mutex.Lock()
+	defer e.refunds.mutex.Unlock()
+	
+	e.refunds.refunded = make(map[string]string)
+	e.refunds.refunding = make(map[string]bool)
+	e.refunds.receivables = make(map[int]*Receivable)
+	e.refunds.nextID = 1
+	
+	data, err := ioutil.ReadFile(e.refundsPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Error("Failed to read refunds", "error", err)
+		return
+	}
+	
+	stored := refundFile{Refunded: e.refunds.refunded, Receivables: e.refunds.receivables}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		e.logger.Error("Failed to parse refunds", "error", err)
+	}
+	if stored.Refunded != nil {
+		e.refunds.refunded = stored.Refunded
+	}
+	if stored.Receivables != nil {
+		e.refunds.receivables = stored.Receivables
+	}
+	for id := range e.refunds.receivables {
+		if id >= e.refunds.nextID {
+			e.refunds.nextID = id + 1
+		}
+	}
+}
+
+// saveRefunds must be called with e.refunds.mutex held.
+func (e *EconomyPlugin) saveRefunds() {
+	data, err := json.MarshalIndent(refundFile{Refunded: e.refunds.refunded, Receivables: e.refunds.receivables}, "", "  ")
+	if err != nil {
+		e.logger.Error("Failed to marshal refunds", "error", err)
+		return
+	}
+	
+	if err := writeFileAtomic(e.refundsPath(), data, 0644); err != nil {
+		e.logger.Error("Failed to write refunds", "error", err)
+		e.countStorageError("refunds")
+	}
+}
+
+// Refund reverses the PURCHASE or TRANSFER with ID transactionID, paying
+// its amount back from the recipient to the payer; fees are not returned.
+// A transaction can be refunded once. When the recipient cannot spend the
+// whole amount the payer gets what they have and the rest becomes a
+// Receivable. A purchase from the server is refunded by the server. It
+// returns the REFUND transaction recorded.
+func (e *EconomyPlugin) Refund(transactionID string) (Transaction, error) {
+	original, err := e.GetTransaction(transactionID)
+	if err != nil {
+		return Transaction{}, err
+	}
+	if original.Type != PURCHASE && original.Type != TRANSFER {
+		return Transaction{}, ErrNotRefundable
+	}
+	if !e.hasAccount(original.From) || (original.To != "" && !e.hasAccount(original.To)) {
+		return Transaction{}, ErrAccountNotFound
+	}
+	
+	payee, payer := original.From, original.To
+	usernames := []string{payee}
+	if payer != "" {
+		usernames = append(usernames, payer)
+	}
+	
+	refund := Transaction{
+		ID:        newTransactionID(e.now()),
+		From:      payer,
+		To:        payee,
+		Type:      REFUND,
+		Timestamp: e.now(),
+		Reason:    "Refund of " + original.ID,
+	}
+	e.refunds.mutex.Lock()
+	_, refunded := e.refunds.refunded[original.ID]
+	if refunded || e.refunds.refunding[original.ID] {
+		e.refunds.mutex.Unlock()
+		return Transaction{}, ErrAlreadyRefunded
+	}
+	e.refunds.refunding[original.ID] = true
+	e.refunds.mutex.Unlock()
+	
+	var payeeOld, payerOld, owed Money
+	err = e.mutateAccounts(usernames, func(accounts []*PlayerAccount) error {
+		refund.Amount = original.Amount
+		if len(accounts) > 1 {
+			if available := clampZero(accounts[1].spendable() - e.minBalance()); refund.Amount > available {
+				refund.Amount, owed = available, refund.Amount-available
+			}
+		}
+		if accounts[0].Balance+refund.Amount > e.config.MaxBalance {
+			return ErrMaxBalanceExceeded
+		}
+		
+		payeeOld = accounts[0].Balance
+		accounts[0].Balance += refund.Amount
+		accounts[0].TotalSpent = clampZero(accounts[0].TotalSpent - refund.Amount)
+		if len(accounts) > 1 {
+			payerOld = accounts[1].Balance
+			accounts[1].Balance -= refund.Amount
+			accounts[1].TotalEarned = clampZero(accounts[1].TotalEarned - refund.Amount)
+		}
+		return nil
+	})
+	
+	e.refunds.mutex.Lock()
+	delete(e.refunds.refunding, original.ID)
+	if err == nil {
+		e.refunds.refunded[original.ID] = refund.ID
+		if owed > 0 {
+			e.refunds.receivables[e.refunds.nextID] = &Receivable{
+				ID:       e.refunds.nextID,
+				Debtor:   payer,
+				Creditor: payee,
+				Amount:   owed,
+				Refund:   refund.ID,
+				Created:  refund.Timestamp,
+			}
+			e.refunds.nextID++
+		}
+		e.saveRefunds()
+	}
+	e.refunds.mutex.Unlock()
+	if err != nil {
+		return Transaction{}, err
+	}
+	
+	if owed > 0 {
+		refund.Reason += " (" + e.FormatMoney(owed) + " owed)"
+	}
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(payee, payeeOld, payeeOld+refund.Amount, REFUND)
+	if payer != "" {
+		e.fireBalanceChange(payer, payerOld, payerOld-refund.Amount, REFUND)
+	}
+	e.recordTransaction(&refund)
+	
+	return refund, nil
+}
+
+// GetReceivables returns the open receivables username owes or is owed,
+// oldest first.
+func (e *EconomyPlugin) GetReceivables(username string) []Receivable {
+	e.refunds.mutex.Lock()
+	defer e.refunds.mutex.Unlock()
+	
+	receivables := make([]Receivable, 0)
+	for _, receivable := range e.refunds.receivables {
+		if strings.EqualFold(receivable.Debtor, username) || strings.EqualFold(receivable.Creditor, username) {
+			receivables = append(receivables, *receivable)
+		}
+	}
+	sort.Slice(receivables, func(i, j int) bool {
+		return receivables[i].ID < receivables[j].ID
+	})
+	
+	return receivables
+}
+
+// reassignReceivables moves from's receivables, owed and owing, to into.
+func (e *EconomyPlugin) reassignReceivables(from, into string) {
+	e.refunds.mutex.Lock()
+	defer e.refunds.mutex.Unlock()
+	
+	changed := false
+	for _, receivable := range e.refunds.receivables {
+		if strings.EqualFold(receivable.Debtor, from) {
+			receivable.Debtor, changed = into, true
+		}
+		if strings.EqualFold(receivable.Creditor, from) {
+			receivable.Creditor, changed = into, true
+		}
+	}
+	if changed {
+		e.saveRefunds()
+	}
+}
+
+func (e *EconomyPlugin) startReceivableCollector() {
+	e.runPeriodically(receivableCheckInterval, e.collectReceivables)
+}
+
+// collectReceivables takes what each debtor can spend towards what they
+// owe, as far as the creditor has room under max_balance. Only this runs
+// receivables down, one at a time.
+func (e *EconomyPlugin) collectReceivables() {
+	e.refunds.mutex.Lock()
+	open := make([]Receivable, 0, len(e.refunds.receivables))
+	for _, receivable := range e.refunds.receivables {
+		open = append(open, *receivable)
+	}
+	e.refunds.mutex.Unlock()
+	
+	for _, receivable := range open {
+		if err := e.collectReceivable(receivable); err != nil {
+			e.logger.Warn("Failed to collect receivable", "receivable", receivable.ID, "error", err)
+		}
+	}
+}
+
+func (e *EconomyPlugin) collectReceivable(receivable Receivable) error {
+	debtor, creditor := receivable.Debtor, receivable.Creditor
+	var collected, debtorOld, creditorOld Money
+	err := e.mutateAccounts([]string{debtor, creditor}, func(accounts []*PlayerAccount) error {
+		collected = receivable.Amount
+		if available := clampZero(accounts[0].spendable() - e.minBalance()); collected > available {
+			collected = available
+		}
+		if room := clampZero(e.config.MaxBalance - accounts[1].Balance); collected > room {
+			collected = room
+		}
+		if collected <= 0 {
+			return nil
+		}
+		
+		debtorOld, creditorOld = accounts[0].Balance, accounts[1].Balance
+		accounts[0].Balance -= collected
+		accounts[0].TotalEarned = clampZero(accounts[0].TotalEarned - collected)
+		accounts[1].Balance += collected
+		accounts[1].TotalSpent = clampZero(accounts[1].TotalSpent - collected)
+		return nil
+	})
+	if err != nil || collected <= 0 {
+		return err
+	}
+	
+	e.refunds.mutex.Lock()
+	if open, exists := e.refunds.receivables[receivable.ID]; exists {
+		if open.Amount -= collected; open.Amount <= 0 {
+			delete(e.refunds.receivables, receivable.ID)
+		}
+		e.saveRefunds()
+	}
+	e.refunds.mutex.Unlock()
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(debtor, debtorOld, debtorOld-collected, REFUND)
+	e.fireBalanceChange(creditor, creditorOld, creditorOld+collected, REFUND)
+	e.recordTransaction(&Transaction{
+		From:      debtor,
+		To:        creditor,
+		Amount:    collected,
+		Type:      REFUND,
+		Timestamp: e.now(),
+		Reason:    "Refund " + receivable.Refund + " collected",
+	})
+	
+	return nil
+}
@@ -0,0 +1,235 @@
+package economy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReplayResult describes a finished ReplayLedger run. Divergences lists
+// the accounts whose stored balance is not the one the ledger implies.
+type ReplayResult struct {
+	Backend      string
+	Transactions int
+	Accounts     int
+	Duration     time.Duration
+	Divergences  []ReplayDivergence
+}
+
+// ReplayDivergence is one account the replay disagreed with. Missing is
+// set when the ledger names a player no account is stored for.
+type ReplayDivergence struct {
+	Player   string `json:"player"`
+	Stored   Money  `json:"stored"`
+	Replayed Money  `json:"replayed"`
+	Missing  bool   `json:"missing"`
+}
+
+// ledgerReplay accumulates the wallet balances a run of transactions
+// implies, keyed by lowercased name. Players start from their starting
+// balance unless a SET fixes their balance outright.
+type ledgerReplay struct {
+	e        *EconomyPlugin
+	balances map[string]Money
+	names    map[string]string
+	touched  []string
+}
+
+func (e *EconomyPlugin) newLedgerReplay() *ledgerReplay {
+	return &ledgerReplay{e: e, balances: make(map[string]Money), names: make(map[string]string)}
+}
+
+func (r *ledgerReplay) seen(name string) string {
+	key := strings.ToLower(name)
+	if _, exists := r.balances[key]; !exists {
+		r.balances[key] = r.e.startingBalance(OfflineUUID(name), name)
+	}
+	r.names[key] = name
+	r.touched = append(r.touched, key)
+	return key
+}
+
+// apply adds transaction's effect on wallets to the balances and returns
+// the keys of the players it named.
+func (r *ledgerReplay) apply(transaction *Transaction) []string {
+	r.touched = r.touched[:0]
+	from, to := transaction.From, transaction.To
+	
+	switch {
+	case transaction.Type == SET, transaction.Type == ROLLBACK && transaction.Previous != nil:
+		if to != "" {
+			key := strings.ToLower(to)
+			r.balances[key], r.names[key] = transaction.Amount, to
+			r.touched = append(r.touched, key)
+		}
+	case transaction.Type == BANK_DEPOSIT:
+		r.balances[r.seen(from)] -= transaction.Amount
+	case transaction.Type == BANK_WITHDRAW:
+		r.balances[r.seen(to)] += transaction.Amount
+	case transaction.Type == INTEREST && transaction.Reason == bankInterestReason:
+	case transaction.Type == OPENING:
+		// Players are already assumed to start from their starting
+		// balance.
+	case transaction.Type == MERGE:
+		// The merged account's history was moved over with it; only
+		// the balance it started with is new.
+		if !strings.EqualFold(from, to) {
+			r.balances[r.seen(to)] += r.e.startingBalance(OfflineUUID(from), from)
+		}
+	case transaction.Type == BATCH, transaction.Type == CONFISCATE:
+		for _, entry := range transaction.Batch {
+			r.balances[r.seen(entry.Player)] += entry.Amount
+		}
+	default:
+		if from != "" {
+			r.balances[r.seen(from)] -= transaction.Amount
+		}
+		if to != "" {
+			r.balances[r.seen(to)] += transaction.Amount
+		}
+	}
+	
+	return r.touched
+}
+
+// ReplayLedger reads the transaction ledger at path, a transactions.jsonl
+// file, and applies it oldest first to empty storage of the given backend
+// kept in a temporary folder, writing the accounts it produces and every
+// transaction as the plugin would. The balances replayed are then compared
+// with the accounts stored here. An empty backend uses the active one;
+// the database backends are refused, since replaying into the configured
+// database would mix with what it holds.
+//
+// The run is timed, so a ledger copied from a live server doubles as a
+// load test for a backend. Nothing in the data folder is changed.
+func (e *EconomyPlugin) ReplayLedger(path, backend string) (ReplayResult, error) {
+	if backend == "" {
+		backend = e.config.StorageBackend
+	}
+	backend, err := normalizeBackend(backend)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	if remoteBackend(backend) {
+		return ReplayResult{}, fmt.Errorf("%w: replay cannot use %s", ErrWrongBackend, backend)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ReplayResult{}, err
+	}
+	
+	transactions, err := NewJSONLinesLedger(path).QueryTransactions("", TransactionFilter{})
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	
+	dir, err := ioutil.TempDir("", "economy-replay-")
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	defer os.RemoveAll(dir)
+	
+	config := *e.config
+	config.StorageBackend = backend
+	target, err := newStorage(&config, dir)
+	if err != nil {
+		return ReplayResult{}, fmt.Errorf("open %s storage: %w", backend, err)
+	}
+	defer target.Close()
+	if err := target.Load(); err != nil {
+		return ReplayResult{}, fmt.Errorf("load %s storage: %w", backend, err)
+	}
+	ledger, ok := target.(TransactionStore)
+	if !ok {
+		ledger = NewJSONLinesLedger(filepath.Join(dir, "transactions.jsonl"))
+	}
+	
+	result := ReplayResult{Backend: backend}
+	replay := e.newLedgerReplay()
+	accounts := make(map[string]*PlayerAccount)
+	pending := make(map[string]bool)
+	started := time.Now()
+	
+	// QueryTransactions returns them newest first.
+	for i := len(transactions) - 1; i >= 0; i-- {
+		for _, key := range replay.apply(&transactions[i]) {
+			pending[key] = true
+		}
+		if err := ledger.AppendTransaction(&transactions[i]); err != nil {
+			return result, err
+		}
+		result.Transactions++
+		
+		if result.Transactions%migrateBatchSize == 0 || i == 0 {
+			if err := e.putReplayed(target, replay, accounts, pending); err != nil {
+				return result, err
+			}
+			pending = make(map[string]bool)
+		}
+	}
+	result.Duration = time.Since(started)
+	result.Accounts = len(accounts)
+	
+	stored, err := target.ListAccounts()
+	if err != nil {
+		return result, err
+	}
+	for _, account := range stored {
+		if expected := replay.balances[strings.ToLower(account.Username)]; account.Balance != expected {
+			return result, fmt.Errorf("%w: %s stored %s for %s but %s was written", ErrMigrationMismatch,
+				backend, account.Balance, account.Username, expected)
+		}
+	}
+	if len(stored) != len(accounts) {
+		return result, fmt.Errorf("%w: %s stored %d of %d accounts", ErrMigrationMismatch, backend, len(stored), len(accounts))
+	}
+	
+	keys := make([]string, 0, len(replay.balances))
+	for key := range replay.balances {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	
+	result.Divergences = make([]ReplayDivergence, 0)
+	for _, key := range keys {
+		name, replayed := replay.names[key], replay.balances[key]
+		account, exists := e.GetAccount(name)
+		switch {
+		case !exists:
+			result.Divergences = append(result.Divergences, ReplayDivergence{Player: name, Replayed: replayed, Missing: true})
+		case account.Balance != replayed:
+			result.Divergences = append(result.Divergences, ReplayDivergence{Player: account.Username, Stored: account.Balance, Replayed: replayed})
+		}
+	}
+	
+	e.logger.Info("Replayed ledger", "path", path, "backend", backend, "transactions", result.Transactions,
+		"accounts", result.Accounts, "divergences", len(result.Divergences), "duration", result.Duration)
+	return result, nil
+}
+
+// putReplayed writes the replayed balances of the players in pending to
+// target, keyed as the accounts stored here are, and saves it.
+func (e *EconomyPlugin) putReplayed(target Storage, replay *ledgerReplay, accounts map[string]*PlayerAccount, pending map[string]bool) error {
+	for key := range pending {
+		account := accounts[key]
+		if account == nil {
+			name := replay.names[key]
+			uuid := e.accountUUID(name)
+			if stored, exists := e.lookupAccount(name); exists {
+				uuid = e.accountKey(stored)
+			}
+			now := e.now()
+			account = &PlayerAccount{UUID: uuid, Username: name, Created: now, LastSeen: now}
+			accounts[key] = account
+		}
+		account.Balance = replay.balances[key]
+		if err := target.PutAccount(account); err != nil {
+			return err
+		}
+	}
+	
+	return target.Save()
+}
@@ -0,0 +1,243 @@
+package economy
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// prunePreviewLimit caps how many accounts a prune dry run lists.
+const prunePreviewLimit = 10
+
+// resetAccount puts a player's wallet back to their starting balance.
+func (e *EconomyPlugin) resetAccount(username string) (Money, error) {
+	account, exists := e.lookupAccount(username)
+	if !exists {
+		return 0, ErrAccountNotFound
+	}
+	
+	balance := e.startingBalance(e.accountKey(account), username)
+	_, err := e.assignBalance(username, balance, "Balance reset")
+	return balance, err
+}
+
+// resetAllAccounts puts every wallet back to its starting balance as one
+// batch.
+func (e *EconomyPlugin) resetAllAccounts() (BatchResult, error) {
+	accounts, err := e.filterAccounts(AccountFilter{})
+	if err != nil {
+		return BatchResult{}, err
+	}
+	
+	// Starting balances may consult the group resolver, so they are worked
+	// out before any account is locked.
+	starting := make(map[*PlayerAccount]Money, len(accounts))
+	for _, account := range accounts {
+		snapshot := e.snapshotAccount(account)
+		starting[account] = e.startingBalance(snapshot.UUID, snapshot.Username)
+	}
+	
+	return e.applyBatch(accounts, "Economy reset", func(locked []*PlayerAccount) ([]Money, error) {
+		changes := make([]Money, len(locked))
+		for i, account := range locked {
+			changes[i] = starting[accounts[i]] - account.Balance
+		}
+		return changes, nil
+	})
+}
+
+// pruneAccounts deletes the accounts not seen within inactive, or with
+// dryRun only reports them. Online players, virtual accounts, the fee
+// account and anyone with an open loan, receivable, money on hold, pending
+// payments, savings or a linked account are kept. The wallets removed are recorded
+// as one batch so the ledger still adds up.
+func (e *EconomyPlugin) pruneAccounts(inactive time.Duration, dryRun bool) ([]PlayerAccount, error) {
+	cutoff := e.now().Add(-inactive)
+	
+	online := make(map[string]bool)
+	if players, ok := e.getOnlinePlayers(); ok {
+		for _, player := range players {
+			online[strings.ToLower(player)] = true
+		}
+	}
+	
+	candidates := make([]PlayerAccount, 0)
+	for _, account := range e.snapshotAccounts() {
+		switch {
+		case !account.LastSeen.Before(cutoff):
+		case online[strings.ToLower(account.Username)], account.virtual():
+		case strings.EqualFold(account.Username, e.config.TransferFees.Recipient),
+			strings.EqualFold(account.Username, e.config.WealthTax.Recipient):
+		case len(e.GetLoans(account.Username)) > 0, len(e.GetReceivables(account.Username)) > 0, len(account.Holds) > 0, len(e.GetPendingPayments(account.Username)) > 0,
+			len(e.GetLinks(account.Username)) > 0, len(e.GetSavings(account.Username)) > 0:
+		default:
+			candidates = append(candidates, account)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].LastSeen.Before(candidates[j].LastSeen)
+	})
+	if dryRun || len(candidates) == 0 {
+		return candidates, nil
+	}
+	
+	if !e.shutdown.beginMutation() {
+		return nil, ErrShuttingDown
+	}
+	defer e.shutdown.endMutation()
+	
+	removed := make([]PlayerAccount, 0, len(candidates))
+	for _, candidate := range candidates {
+		account, exists := e.loadAccount(candidate.UUID)
+		if !exists {
+			continue
+		}
+		
+		// A player who joined since the scan keeps their account.
+		unlock := e.locks.lock(account)
+		e.mutex.Lock()
+		if e.playerData[candidate.UUID] == account && account.LastSeen.Before(cutoff) {
+			e.forgetAccount(account)
+			removed = append(removed, *account)
+		}
+		e.mutex.Unlock()
+		unlock()
+	}
+	if len(removed) == 0 {
+		return removed, nil
+	}
+	
+	e.invalidateTopPlayers()
+	e.logger.Info("Pruned inactive accounts", "accounts", len(removed), "inactive", inactive.String())
+	
+	var destroyed Money
+	entries := make([]BatchEntry, 0, len(removed))
+	legs := make([]Posting, 0, 2*len(removed))
+	for _, account := range removed {
+		if account.Balance > 0 {
+			entries = append(entries, BatchEntry{Player: account.Username, Amount: -account.Balance})
+			destroyed += account.Balance
+		}
+		legs = append(legs, Posting{Account: account.Username, Amount: -account.Balance},
+			Posting{Account: bankRef(account.Username), Amount: -account.BankBalance})
+	}
+	// Postings also take the banks and debts the batch leaves out.
+	postings := e.postings(legs...)
+	if len(entries) > 0 || len(postings) > 0 {
+		e.recordTransaction(&Transaction{
+			Amount:    destroyed,
+			Type:      BATCH,
+			Timestamp: e.now(),
+			Reason:    "Inactive accounts pruned (" + strconv.Itoa(len(entries)) + " accounts)",
+			Batch:     entries,
+			Postings:  postings,
+		})
+	}
+	
+	return removed, nil
+}
+
+// forgetAccount drops an account from memory and marks it for deletion
+// from storage on the next save. It must be called with the account's
+// lock and e.mutex held.
+func (e *EconomyPlugin) forgetAccount(account *PlayerAccount) {
+	uuid := account.UUID
+	delete(e.playerData, uuid)
+	delete(e.dirty, uuid)
+	e.removed[uuid] = true
+	if key := strings.ToLower(account.Username); e.names[key] == uuid {
+		delete(e.names, key)
+	}
+	e.cache.forget(uuid)
+}
+
+func (e *EconomyPlugin) resetCommand(ctx *CommandContext) string {
+	if len(ctx.Args) != 1 {
+		return e.message("reset.usage")
+	}
+	
+	username := ctx.Args[0]
+	if !e.hasAccount(username) {
+		return e.message("reset.failed", "error", e.describeError(ErrAccountNotFound))
+	}
+	before := e.peekBalance(username)
+	balance, err := e.resetAccount(username)
+	if err != nil {
+		return e.message("reset.failed", "error", e.describeError(err))
+	}
+	e.auditBalance(ctx, "reset", username, before, "")
+	
+	return e.message("reset.done", "player", username, "amount", e.FormatMoney(balance))
+}
+
+func (e *EconomyPlugin) resetAllCommand(ctx *CommandContext) string {
+	if len(ctx.Args) != 1 || ctx.Args[0] != "--confirm" {
+		return e.message("resetall.confirm")
+	}
+	
+	result, err := e.resetAllAccounts()
+	if err != nil {
+		return e.message("resetall.failed", "error", e.describeError(err))
+	}
+	e.logger.Info("Economy reset", "admin", ctx.Name(), "accounts", result.Accounts)
+	e.audit(ctx, "resetall", "", nil, nil, strconv.Itoa(result.Accounts)+" accounts")
+	
+	return e.message("resetall.done", "count", strconv.Itoa(result.Accounts))
+}
+
+func (e *EconomyPlugin) pruneCommand(ctx *CommandContext) string {
+	var inactive time.Duration
+	dryRun := false
+	for i := 0; i < len(ctx.Args); i++ {
+		switch ctx.Args[i] {
+		case "--dry-run":
+			dryRun = true
+		case "--inactive":
+			if i+1 == len(ctx.Args) {
+				return e.message("prune.usage")
+			}
+			i++
+			parsed, err := parseDuration(ctx.Args[i])
+			if err != nil || parsed <= 0 {
+				return e.message("prune.usage")
+			}
+			inactive = parsed
+		default:
+			return e.message("prune.usage")
+		}
+	}
+	if inactive == 0 {
+		return e.message("prune.usage")
+	}
+	
+	accounts, err := e.pruneAccounts(inactive, dryRun)
+	if err != nil {
+		return e.message("prune.failed", "error", e.describeError(err))
+	}
+	if len(accounts) == 0 {
+		return e.message("prune.none")
+	}
+	
+	var held Money
+	for _, account := range accounts {
+		held += account.Balance + account.BankBalance
+	}
+	count := strconv.Itoa(len(accounts))
+	if !dryRun {
+		e.audit(ctx, "prune", "", nil, nil, count+" accounts")
+		return e.message("prune.done", "count", count, "amount", e.FormatMoney(held))
+	}
+	
+	result := e.message("prune.preview", "count", count, "amount", e.FormatMoney(held))
+	for i, account := range accounts {
+		if i == prunePreviewLimit {
+			result += "\n" + e.message("prune.more", "count", strconv.Itoa(len(accounts)-i))
+			break
+		}
+		result += "\n" + e.message("prune.entry", "player", account.Username,
+			"seen", account.LastSeen.Format("2006-01-02"), "amount", e.FormatMoney(account.Balance+account.BankBalance))
+	}
+	
+	return result
+}
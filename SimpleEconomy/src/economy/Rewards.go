@@ -0,0 +1,222 @@
+package economy
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DailyRewardConfig pays Amount once per calendar day. Each consecutive
+// day adds StreakBonus percent of Amount, up to MaxStreak days; missing a
+// day starts the streak over. With AutoClaim the reward is paid when the
+// player joins instead of waiting for /daily.
+type DailyRewardConfig struct {
+	Enabled     bool    `json:"enabled"`
+	Amount      Money   `json:"amount"`
+	StreakBonus float64 `json:"streak_bonus_percent"`
+	MaxStreak   int     `json:"max_streak"`
+	AutoClaim   bool    `json:"auto_claim"`
+}
+
+// DailyReward describes a successful claim.
+type DailyReward struct {
+	Amount Money
+	Streak int
+}
+
+// RewardStore remembers the last day each account claimed its daily
+// reward. ClaimReward must be atomic: it records a claim for day and
+// returns the new streak, or claimed false if day was already claimed.
+// yesterday is passed in so the store need not know the calendar.
+type RewardStore interface {
+	ClaimReward(uuid, day, yesterday string) (streak int, claimed bool, err error)
+}
+
+type rewardClaim struct {
+	LastDay string `json:"last_day"`
+	Streak  int    `json:"streak"`
+}
+
+func (c rewardClaim) next(day, yesterday string) (rewardClaim, bool) {
+	if c.LastDay == day {
+		return c, false
+	}
+	if c.LastDay == yesterday {
+		return rewardClaim{LastDay: day, Streak: c.Streak + 1}, true
+	}
+	return rewardClaim{LastDay: day, Streak: 1}, true
+}
+
+// JSONRewardStore keeps every account's claim in one file keyed by UUID.
+type JSONRewardStore struct {
+	path   string
+	claims map[string]rewardClaim
+	mutex  sync.Mutex
+}
+
+func NewJSONRewardStore(path string) *JSONRewardStore {
+	return &JSONRewardStore{path: path}
+}
+
+func (s *JSONRewardStore) ClaimReward(uuid, day, yesterday string) (int, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if s.claims == nil {
+		claims := make(map[string]rewardClaim)
+		data, err := ioutil.ReadFile(s.path)
+		if err != nil && !os.IsNotExist(err) {
+			return 0, false, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &claims); err != nil {
+				return 0, false, err
+			}
+		}
+		s.claims = claims
+	}
+	
+	claim, claimed := s.claims[uuid].next(day, yesterday)
+	if !claimed {
+		return claim.Streak, false, nil
+	}
+	
+	previous, existed := s.claims[uuid]
+	s.claims[uuid] = claim
+	data, err := json.Marshal(s.claims)
+	if err == nil {
+		err = writeFileAtomic(s.path, data, 0644)
+	}
+	if err != nil {
+		if existed {
+			s.claims[uuid] = previous
+		} else {
+			delete(s.claims, uuid)
+		}
+		return 0, false, err
+	}
+	
+	return claim.Streak, true, nil
+}
+
+// ClaimDailyReward pays username today's reward if they have not had it
+// yet. It returns ErrRewardClaimed once the day's reward has been paid.
+func (e *EconomyPlugin) ClaimDailyReward(username string) (DailyReward, error) {
+	config := e.config.DailyReward
+	if !config.Enabled || config.Amount <= 0 {
+		return DailyReward{}, ErrRewardsDisabled
+	}
+	
+	uuid, exists := e.GetUUID(username)
+	if !exists {
+		return DailyReward{}, ErrAccountNotFound
+	}
+	
+	now := e.now()
+	streak, claimed, err := e.rewards.ClaimReward(uuid, now.Format(historyDayLayout), now.AddDate(0, 0, -1).Format(historyDayLayout))
+	if err != nil {
+		e.countStorageError("rewards")
+		return DailyReward{}, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	if !claimed {
+		return DailyReward{Streak: streak}, ErrRewardClaimed
+	}
+	
+	amount := e.rewardFor(streak)
+	if headroom := e.config.MaxBalance - e.getBalance(username); amount > headroom {
+		amount = headroom
+	}
+	if amount <= 0 {
+		return DailyReward{Streak: streak}, nil
+	}
+	amount, err = e.allowMint(username, "daily reward", amount)
+	if err != nil {
+		return DailyReward{Streak: streak}, err
+	}
+	
+	if _, err := e.credit(username, amount, REWARD, ReasonReward, fmt.Sprintf("Daily reward (day %d)", streak)); err != nil {
+		return DailyReward{Streak: streak}, err
+	}
+	
+	return DailyReward{Amount: amount, Streak: streak}, nil
+}
+
+func (e *EconomyPlugin) rewardFor(streak int) Money {
+	config := e.config.DailyReward
+	bonusDays := streak - 1
+	if config.MaxStreak > 0 && bonusDays > config.MaxStreak-1 {
+		bonusDays = config.MaxStreak - 1
+	}
+	
+	bonus := config.Amount.MulRate(config.StreakBonus / 100 * float64(bonusDays))
+	return e.roundAmount(config.Amount + bonus)
+}
+
+// autoClaimReward pays the daily reward on join when auto_claim is set.
+// Accounts touched by admins or other plugins never claim on their own.
+func (e *EconomyPlugin) autoClaimReward(username string) {
+	if !e.config.DailyReward.Enabled || !e.config.DailyReward.AutoClaim {
+		return
+	}
+	
+	reward, err := e.ClaimDailyReward(username)
+	if err != nil {
+		if !errors.Is(err, ErrRewardClaimed) {
+			e.logger.Warn("Failed to pay daily reward", "player", username, "error", err)
+		}
+		return
+	}
+	e.logger.Debug("Paid daily reward", "player", username, "amount", reward.Amount.String(), "streak", reward.Streak)
+}
+
+func (e *EconomyPlugin) dailyCommand(ctx *CommandContext) string {
+	reward, err := e.ClaimDailyReward(ctx.Name())
+	switch {
+	case errors.Is(err, ErrRewardClaimed):
+		now := e.now()
+		midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+		return e.message("daily.already_claimed", "time", strings.TrimSuffix(midnight.Sub(now).Round(time.Minute).String(), "0s"),
+			"streak", strconv.Itoa(reward.Streak))
+	case err != nil:
+		return e.message("daily.failed", "error", e.describeError(err))
+	}
+	
+	return e.message("daily.claimed", "amount", e.FormatMoney(reward.Amount), "streak", strconv.Itoa(reward.Streak))
+}
+
+// claimRewardSQL serves the SQL backends. The row is created first so the
+// locking read always has a row to lock.
+func claimRewardSQL(db *sql.DB, insertIgnore, lock string, uuid, day, yesterday string) (int, bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+	
+	if _, err := tx.Exec(insertIgnore, uuid); err != nil {
+		return 0, false, err
+	}
+	
+	var current rewardClaim
+	if err := tx.QueryRow(lock, uuid).Scan(&current.LastDay, &current.Streak); err != nil {
+		return 0, false, err
+	}
+	
+	claim, claimed := current.next(day, yesterday)
+	if !claimed {
+		return claim.Streak, false, nil
+	}
+	
+	if _, err := tx.Exec(`UPDATE reward_claims SET last_day = ?, streak = ? WHERE uuid = ?`, claim.LastDay, claim.Streak, uuid); err != nil {
+		return 0, false, err
+	}
+	
+	return claim.Streak, true, tx.Commit()
+}
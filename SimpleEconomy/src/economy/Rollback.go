@@ -0,0 +1,242 @@
+package economy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RollbackResult reports what RollbackTransactions undid. Shortfall is the
+// money that could not be taken back because the holder had spent it.
+type RollbackResult struct {
+	Reverted  int
+	Skipped   int
+	Shortfall Money
+}
+
+// RollbackTransactions undoes a player's ADD, REWARD, JOB, SUBTRACT, SET,
+// TRANSFER and FEE transactions recorded since the given time, newest
+// first. Each undo is itself recorded as a ROLLBACK transaction, and
+// transactions an earlier rollback already undid are left alone.
+func (e *EconomyPlugin) RollbackTransactions(player string, since time.Time) (RollbackResult, error) {
+	var result RollbackResult
+	if !e.hasAccount(player) {
+		return result, ErrAccountNotFound
+	}
+	if e.ledger == nil {
+		return result, ErrStorage
+	}
+	
+	transactions, err := e.ledger.QueryTransactions(player, TransactionFilter{Since: since})
+	if err != nil {
+		return result, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	
+	undone := make(map[string]bool)
+	for _, transaction := range transactions {
+		if transaction.Type == ROLLBACK {
+			undone[transaction.Reason] = true
+		}
+	}
+	
+	for i := range transactions {
+		transaction := &transactions[i]
+		if transaction.Type == ROLLBACK {
+			continue
+		}
+		if undone[rollbackReason(transaction)] {
+			result.Skipped++
+			continue
+		}
+		
+		var moved Money
+		switch transaction.Type {
+		case ADD, REWARD, JOB:
+			moved, err = e.reverseAmount(transaction, transaction.To, "")
+		case SUBTRACT:
+			moved, err = e.reverseAmount(transaction, "", transaction.From)
+		case TRANSFER, FEE, PURCHASE, HOLD_CAPTURE:
+			moved, err = e.reverseAmount(transaction, transaction.To, transaction.From)
+		case SET:
+			if transaction.Previous == nil {
+				result.Skipped++
+				continue
+			}
+			err = e.reverseSet(transaction)
+			moved = transaction.Amount
+		default:
+			result.Skipped++
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		
+		result.Reverted++
+		result.Shortfall += transaction.Amount - moved
+	}
+	
+	return result, nil
+}
+
+func rollbackReason(transaction *Transaction) string {
+	return fmt.Sprintf("Rollback of %s at %s", transaction.Type, transaction.Timestamp.Format(time.RFC3339Nano))
+}
+
+// reverseAmount moves up to transaction.Amount from one account back to
+// another; an empty name stands for money created or destroyed. Less is
+// moved when the source is short or the target would pass max_balance.
+func (e *EconomyPlugin) reverseAmount(transaction *Transaction, from, to string) (Money, error) {
+	usernames := make([]string, 0, 2)
+	if from != "" {
+		usernames = append(usernames, from)
+	}
+	if to != "" {
+		usernames = append(usernames, to)
+	}
+	
+	var fromOld, toOld, moved Money
+	err := e.mutateAccounts(usernames, func(accounts []*PlayerAccount) error {
+		var source, target *PlayerAccount
+		if from != "" {
+			source = accounts[0]
+		}
+		if to != "" {
+			target = accounts[len(accounts)-1]
+		}
+		
+		moved = transaction.Amount
+		if source != nil && source.spendable() < moved {
+			moved = clampZero(source.spendable())
+		}
+		if target != nil && e.config.MaxBalance-target.Balance < moved {
+			moved = e.config.MaxBalance - target.Balance
+		}
+		if moved <= 0 {
+			moved = 0
+			return nil
+		}
+		
+		if source != nil {
+			fromOld = source.Balance
+			source.Balance -= moved
+			source.TotalEarned = clampZero(source.TotalEarned - moved)
+		}
+		if target != nil {
+			toOld = target.Balance
+			target.Balance += moved
+			target.TotalSpent = clampZero(target.TotalSpent - moved)
+		}
+		return nil
+	})
+	if err != nil || moved == 0 {
+		return 0, err
+	}
+	
+	e.invalidateTopPlayers()
+	if from != "" {
+		e.fireBalanceChange(from, fromOld, fromOld-moved, ROLLBACK)
+	}
+	if to != "" {
+		e.fireBalanceChange(to, toOld, toOld+moved, ROLLBACK)
+	}
+	
+	e.recordTransaction(&Transaction{
+		From:      from,
+		To:        to,
+		Amount:    moved,
+		Type:      ROLLBACK,
+		Timestamp: e.now(),
+		Reason:    rollbackReason(transaction),
+	})
+	
+	return moved, nil
+}
+
+func (e *EconomyPlugin) reverseSet(transaction *Transaction) error {
+	restored := *transaction.Previous
+	if restored > e.config.MaxBalance {
+		restored = e.config.MaxBalance
+	}
+	
+	var oldBalance Money
+	err := e.mutateAccounts([]string{transaction.To}, func(accounts []*PlayerAccount) error {
+		oldBalance = accounts[0].Balance
+		accounts[0].Balance = restored
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(transaction.To, oldBalance, restored, ROLLBACK)
+	
+	e.recordTransaction(&Transaction{
+		To:        transaction.To,
+		Amount:    restored,
+		Type:      ROLLBACK,
+		Timestamp: e.now(),
+		Reason:    rollbackReason(transaction),
+		Previous:  &oldBalance,
+	})
+	
+	return nil
+}
+
+func clampZero(amount Money) Money {
+	if amount < 0 {
+		return 0
+	}
+	return amount
+}
+
+// parseDuration extends time.ParseDuration with d and w units, so admins
+// can write 7d instead of 168h.
+func parseDuration(text string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if count, ok := strings.CutSuffix(text, suffix); ok {
+			n, err := strconv.Atoi(count)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", text)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	
+	return time.ParseDuration(text)
+}
+
+func (e *EconomyPlugin) rollbackCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) < 2 {
+		return e.message("rollback.usage")
+	}
+	
+	player := args[0]
+	window, err := parseDuration(strings.ToLower(args[1]))
+	if err != nil || window <= 0 {
+		return e.message("rollback.bad_duration")
+	}
+	
+	since := e.now().Add(-window)
+	if !e.hasAccount(player) {
+		return e.message("rollback.failed", "error", e.describeError(ErrAccountNotFound))
+	}
+	before := e.peekBalance(player)
+	result, err := e.RollbackTransactions(player, since)
+	if err != nil {
+		return e.message("rollback.failed", "error", e.describeError(err))
+	}
+	e.auditBalance(ctx, "rollback", player, before, strconv.Itoa(result.Reverted)+" transactions since "+since.Format("2006-01-02 15:04"))
+	
+	response := e.message("rollback.success", "count", strconv.Itoa(result.Reverted), "player", player,
+		"since", since.Format("2006-01-02 15:04"), "skipped", strconv.Itoa(result.Skipped))
+	if result.Shortfall > 0 {
+		response += "\n" + e.message("rollback.shortfall", "amount", e.FormatMoney(result.Shortfall))
+	}
+	
+	return response
+}
@@ -0,0 +1,605 @@
+package economy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// rulesReloadInterval is how often rules.json is checked for changes.
+const rulesReloadInterval = 5 * time.Second
+
+// TransactionRule blocks every transfer its When expression matches, for
+// example
+//
+//	amount > 100000 && from.age < 1d && to.age < 1d
+//
+// Expressions compare amounts, balances, account ages and names with ==,
+// !=, <, <=, > and >=, and combine comparisons with &&, || and !. Message
+// replaces the generic refusal shown to the sender.
+type TransactionRule struct {
+	Name     string `json:"name"`
+	When     string `json:"when"`
+	Message  string `json:"message,omitempty"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// RuleViolation names the rule that blocked a transfer. It unwraps to
+// ErrBlockedByRule.
+type RuleViolation struct {
+	Rule    string
+	Message string
+}
+
+func (v *RuleViolation) Error() string {
+	return ErrBlockedByRule.Error() + " " + v.Rule
+}
+
+func (v *RuleViolation) Unwrap() error {
+	return ErrBlockedByRule
+}
+
+type compiledRule struct {
+	TransactionRule
+	condition ruleExpr
+}
+
+// ruleSet holds the rules in force and what rules.json looked like when
+// they were read, so the watcher can tell when it changes.
+type ruleSet struct {
+	mutex   sync.RWMutex
+	rules   []compiledRule
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+// ruleEnv is the transfer a rule is checked against. Balances are the
+// ones before the transfer.
+type ruleEnv struct {
+	amount, fee            Money
+	reason                 string
+	from, to               *PlayerAccount
+	fromBalance, toBalance Money
+	now                    time.Time
+}
+
+type ruleKind int
+
+const (
+	ruleMoney ruleKind = iota
+	ruleDuration
+	ruleString
+	ruleBool
+)
+
+func (k ruleKind) String() string {
+	switch k {
+	case ruleMoney:
+		return "amount"
+	case ruleDuration:
+		return "duration"
+	case ruleString:
+		return "text"
+	default:
+		return "true/false"
+	}
+}
+
+// ruleValue holds amounts and durations in number, as both are int64.
+type ruleValue struct {
+	number int64
+	text   string
+	flag   bool
+}
+
+type ruleVariable struct {
+	kind ruleKind
+	get  func(env *ruleEnv) ruleValue
+}
+
+var ruleVariables = buildRuleVariables()
+
+func buildRuleVariables() map[string]ruleVariable {
+	money := func(get func(env *ruleEnv) Money) ruleVariable {
+		return ruleVariable{ruleMoney, func(env *ruleEnv) ruleValue { return ruleValue{number: int64(get(env))} }}
+	}
+	
+	variables := map[string]ruleVariable{
+		"amount": money(func(env *ruleEnv) Money { return env.amount }),
+		"fee":    money(func(env *ruleEnv) Money { return env.fee }),
+		"reason": {ruleString, func(env *ruleEnv) ruleValue { return ruleValue{text: env.reason} }},
+	}
+	
+	sides := map[string]func(env *ruleEnv) (*PlayerAccount, Money){
+		"from": func(env *ruleEnv) (*PlayerAccount, Money) { return env.from, env.fromBalance },
+		"to":   func(env *ruleEnv) (*PlayerAccount, Money) { return env.to, env.toBalance },
+	}
+	for side, account := range sides {
+		account := account
+		variables[side+".name"] = ruleVariable{ruleString, func(env *ruleEnv) ruleValue {
+			a, _ := account(env)
+			return ruleValue{text: a.Username}
+		}}
+		variables[side+".balance"] = money(func(env *ruleEnv) Money {
+			_, balance := account(env)
+			return balance
+		})
+		variables[side+".earned"] = money(func(env *ruleEnv) Money {
+			a, _ := account(env)
+			return a.TotalEarned
+		})
+		variables[side+".spent"] = money(func(env *ruleEnv) Money {
+			a, _ := account(env)
+			return a.TotalSpent
+		})
+		variables[side+".age"] = ruleVariable{ruleDuration, func(env *ruleEnv) ruleValue {
+			a, _ := account(env)
+			return ruleValue{number: int64(a.age(env.now))}
+		}}
+		variables[side+".virtual"] = ruleVariable{ruleBool, func(env *ruleEnv) ruleValue {
+			a, _ := account(env)
+			return ruleValue{flag: a.virtual()}
+		}}
+	}
+	
+	return variables
+}
+
+// age is how long ago the account was opened. Accounts opened before
+// creation times were recorded count as older than any rule can ask about.
+func (a *PlayerAccount) age(now time.Time) time.Duration {
+	if a.Created.IsZero() {
+		return math.MaxInt64
+	}
+	return now.Sub(a.Created)
+}
+
+type ruleExpr interface {
+	eval(env *ruleEnv) ruleValue
+}
+
+type ruleLiteral struct {
+	value ruleValue
+}
+
+func (l *ruleLiteral) eval(env *ruleEnv) ruleValue {
+	return l.value
+}
+
+type ruleRef struct {
+	get func(env *ruleEnv) ruleValue
+}
+
+func (r *ruleRef) eval(env *ruleEnv) ruleValue {
+	return r.get(env)
+}
+
+type ruleNot struct {
+	operand ruleExpr
+}
+
+func (n *ruleNot) eval(env *ruleEnv) ruleValue {
+	return ruleValue{flag: !n.operand.eval(env).flag}
+}
+
+type ruleLogic struct {
+	and         bool
+	left, right ruleExpr
+}
+
+func (l *ruleLogic) eval(env *ruleEnv) ruleValue {
+	left := l.left.eval(env).flag
+	if left != l.and {
+		return ruleValue{flag: left}
+	}
+	return l.right.eval(env)
+}
+
+type ruleCompare struct {
+	op          string
+	kind        ruleKind
+	left, right ruleExpr
+}
+
+func (c *ruleCompare) eval(env *ruleEnv) ruleValue {
+	left, right := c.left.eval(env), c.right.eval(env)
+	
+	var order int
+	switch c.kind {
+	case ruleString:
+		if !strings.EqualFold(left.text, right.text) {
+			order = 1
+		}
+	case ruleBool:
+		if left.flag != right.flag {
+			order = 1
+		}
+	default:
+		switch {
+		case left.number < right.number:
+			order = -1
+		case left.number > right.number:
+			order = 1
+		}
+	}
+	
+	switch c.op {
+	case "==":
+		return ruleValue{flag: order == 0}
+	case "!=":
+		return ruleValue{flag: order != 0}
+	case "<":
+		return ruleValue{flag: order < 0}
+	case "<=":
+		return ruleValue{flag: order <= 0}
+	case ">":
+		return ruleValue{flag: order > 0}
+	default:
+		return ruleValue{flag: order >= 0}
+	}
+}
+
+type ruleToken struct {
+	text string
+	pos  int
+}
+
+var ruleOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"}
+
+// tokenizeRule splits an expression into tokens. Quoted strings keep
+// their quotes so the parser can tell them from names.
+func tokenizeRule(text string) ([]ruleToken, error) {
+	tokens := make([]ruleToken, 0)
+	for i := 0; i < len(text); {
+		c := rune(text[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+			continue
+		case c == '"' || c == '\'':
+			end := strings.IndexRune(text[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i+1)
+			}
+			tokens = append(tokens, ruleToken{text[i : i+end+2], i})
+			i += end + 2
+			continue
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_':
+			start := i
+			for i < len(text) && (unicode.IsLetter(rune(text[i])) || unicode.IsDigit(rune(text[i])) || strings.IndexByte("_.", text[i]) >= 0) {
+				i++
+			}
+			tokens = append(tokens, ruleToken{text[start:i], start})
+			continue
+		}
+		
+		matched := false
+		for _, op := range ruleOperators {
+			if strings.HasPrefix(text[i:], op) {
+				tokens = append(tokens, ruleToken{op, i})
+				i += len(op)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unexpected %q at %d", c, i+1)
+		}
+	}
+	return tokens, nil
+}
+
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+}
+
+func (p *ruleParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].text
+	}
+	return ""
+}
+
+func (p *ruleParser) errorf(format string, args ...interface{}) error {
+	where := "at end"
+	if p.pos < len(p.tokens) {
+		where = fmt.Sprintf("at %d", p.tokens[p.pos].pos+1)
+	}
+	return fmt.Errorf(format+" "+where, args...)
+}
+
+// compileRuleExpr parses a rule condition and checks its types, so a rule
+// that loads can always be evaluated.
+func compileRuleExpr(text string) (ruleExpr, error) {
+	tokens, err := tokenizeRule(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+	
+	p := &ruleParser{tokens: tokens}
+	expr, kind, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	if kind != ruleBool {
+		return nil, fmt.Errorf("condition gives %s, not true/false", kind)
+	}
+	return expr, nil
+}
+
+func (p *ruleParser) parseOr() (ruleExpr, ruleKind, error) {
+	return p.parseLogic(false, "||", "or", p.parseAnd)
+}
+
+func (p *ruleParser) parseAnd() (ruleExpr, ruleKind, error) {
+	return p.parseLogic(true, "&&", "and", p.parseNot)
+}
+
+func (p *ruleParser) parseLogic(and bool, op, word string, operand func() (ruleExpr, ruleKind, error)) (ruleExpr, ruleKind, error) {
+	left, kind, err := operand()
+	if err != nil {
+		return nil, 0, err
+	}
+	for next := p.peek(); next == op || strings.EqualFold(next, word); next = p.peek() {
+		if kind != ruleBool {
+			return nil, 0, p.errorf("%s needs true/false on its left", op)
+		}
+		p.pos++
+		right, rightKind, err := operand()
+		if err != nil {
+			return nil, 0, err
+		}
+		if rightKind != ruleBool {
+			return nil, 0, p.errorf("%s needs true/false on its right", op)
+		}
+		left = &ruleLogic{and: and, left: left, right: right}
+	}
+	return left, kind, nil
+}
+
+func (p *ruleParser) parseNot() (ruleExpr, ruleKind, error) {
+	if next := p.peek(); next == "!" || strings.EqualFold(next, "not") {
+		p.pos++
+		operand, kind, err := p.parseNot()
+		if err != nil {
+			return nil, 0, err
+		}
+		if kind != ruleBool {
+			return nil, 0, p.errorf("! needs true/false")
+		}
+		return &ruleNot{operand: operand}, ruleBool, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *ruleParser) parseCompare() (ruleExpr, ruleKind, error) {
+	left, kind, err := p.parseOperand()
+	if err != nil {
+		return nil, 0, err
+	}
+	
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return left, kind, nil
+	}
+	at := p.pos
+	p.pos++
+	
+	right, rightKind, err := p.parseOperand()
+	if err != nil {
+		return nil, 0, err
+	}
+	if kind != rightKind {
+		p.pos = at
+		return nil, 0, p.errorf("cannot compare %s with %s", kind, rightKind)
+	}
+	if (kind == ruleString || kind == ruleBool) && op != "==" && op != "!=" {
+		p.pos = at
+		return nil, 0, p.errorf("%s only supports == and !=", kind)
+	}
+	return &ruleCompare{op: op, kind: kind, left: left, right: right}, ruleBool, nil
+}
+
+func (p *ruleParser) parseOperand() (ruleExpr, ruleKind, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, 0, p.errorf("missing value")
+	}
+	token := p.tokens[p.pos].text
+	
+	if token == "(" {
+		p.pos++
+		expr, kind, err := p.parseOr()
+		if err != nil {
+			return nil, 0, err
+		}
+		if p.peek() != ")" {
+			return nil, 0, p.errorf("missing )")
+		}
+		p.pos++
+		return expr, kind, nil
+	}
+	
+	switch first := rune(token[0]); {
+	case first == '"' || first == '\'':
+		p.pos++
+		return &ruleLiteral{ruleValue{text: token[1 : len(token)-1]}}, ruleString, nil
+		
+	case unicode.IsDigit(first):
+		if strings.IndexFunc(token, unicode.IsLetter) >= 0 {
+			duration, err := parseDuration(strings.ToLower(token))
+			if err != nil {
+				return nil, 0, p.errorf("invalid duration %q", token)
+			}
+			p.pos++
+			return &ruleLiteral{ruleValue{number: int64(duration)}}, ruleDuration, nil
+		}
+		amount, err := ParseMoney(token)
+		if err != nil {
+			return nil, 0, p.errorf("invalid amount %q", token)
+		}
+		p.pos++
+		return &ruleLiteral{ruleValue{number: int64(amount)}}, ruleMoney, nil
+		
+	case unicode.IsLetter(first) || first == '_':
+		lower := strings.ToLower(token)
+		if lower == "true" || lower == "false" {
+			p.pos++
+			return &ruleLiteral{ruleValue{flag: lower == "true"}}, ruleBool, nil
+		}
+		variable, exists := ruleVariables[lower]
+		if !exists {
+			return nil, 0, p.errorf("unknown name %q", token)
+		}
+		p.pos++
+		return &ruleRef{get: variable.get}, variable.kind, nil
+	}
+	
+	return nil, 0, p.errorf("unexpected %q", token)
+}
+
+func compileRules(rules []TransactionRule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	names := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("a rule has no name")
+		}
+		if names[strings.ToLower(rule.Name)] {
+			return nil, fmt.Errorf("rule %q is defined twice", rule.Name)
+		}
+		names[strings.ToLower(rule.Name)] = true
+		
+		condition, err := compileRuleExpr(rule.When)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", rule.Name, err)
+		}
+		if !rule.Disabled {
+			compiled = append(compiled, compiledRule{TransactionRule: rule, condition: condition})
+		}
+	}
+	return compiled, nil
+}
+
+func (e *EconomyPlugin) rulesPath() string {
+	return filepath.Join(e.dataFolder, "rules.json")
+}
+
+// loadRules reads rules.json. A file with any invalid rule is rejected as
+// a whole and the rules already loaded stay in force, so a typo cannot
+// quietly lift a block.
+func (e *EconomyPlugin) loadRules() error {
+	e.rules.mutex.Lock()
+	defer e.rules.mutex.Unlock()
+	
+	info, err := os.Stat(e.rulesPath())
+	if os.IsNotExist(err) {
+		if e.rules.exists {
+			e.logger.Info("Transaction rules removed")
+		}
+		e.rules.rules, e.rules.exists = nil, false
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	e.rules.exists, e.rules.modTime, e.rules.size = true, info.ModTime(), info.Size()
+	
+	data, err := ioutil.ReadFile(e.rulesPath())
+	if err != nil {
+		return err
+	}
+	var rules []TransactionRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("parse rules.json: %w", err)
+	}
+	compiled, err := compileRules(rules)
+	if err != nil {
+		return err
+	}
+	
+	e.rules.rules = compiled
+	e.logger.Info("Loaded transaction rules", "rules", len(compiled))
+	return nil
+}
+
+// reloadRulesIfChanged reloads rules.json when it has been written,
+// created or deleted since it was last read.
+func (e *EconomyPlugin) reloadRulesIfChanged() {
+	info, err := os.Stat(e.rulesPath())
+	
+	e.rules.mutex.RLock()
+	changed := e.rules.exists != (err == nil)
+	if err == nil && !changed {
+		changed = !info.ModTime().Equal(e.rules.modTime) || info.Size() != e.rules.size
+	}
+	e.rules.mutex.RUnlock()
+	
+	if !changed {
+		return
+	}
+	if err := e.loadRules(); err != nil {
+		e.logger.Error("Failed to reload transaction rules, keeping the previous rules", "error", err)
+	}
+}
+
+func (e *EconomyPlugin) startRuleWatcher() {
+	e.runPeriodically(rulesReloadInterval, e.reloadRulesIfChanged)
+}
+
+// checkRules returns a *RuleViolation for the first rule env matches.
+func (e *EconomyPlugin) checkRules(env *ruleEnv) error {
+	e.rules.mutex.RLock()
+	rules := e.rules.rules
+	e.rules.mutex.RUnlock()
+	
+	for _, rule := range rules {
+		if rule.condition.eval(env).flag {
+			e.logger.Info("Transfer blocked by rule", "rule", rule.Name, "from", env.from.Username,
+				"to", env.to.Username, "amount", env.amount.String())
+			return &RuleViolation{Rule: rule.Name, Message: rule.Message}
+		}
+	}
+	return nil
+}
+
+func (e *EconomyPlugin) rulesCommand(ctx *CommandContext) string {
+	rules := e.Rules()
+	if len(rules) == 0 {
+		return e.message("rules.none")
+	}
+	
+	result := e.message("rules.header")
+	for _, rule := range rules {
+		result += "\n" + e.message("rules.entry", "name", rule.Name, "when", rule.When)
+	}
+	return result
+}
+
+// Rules returns the rules in force, in the order they are checked.
+func (e *EconomyPlugin) Rules() []TransactionRule {
+	e.rules.mutex.RLock()
+	defer e.rules.mutex.RUnlock()
+	
+	rules := make([]TransactionRule, len(e.rules.rules))
+	for i, rule := range e.rules.rules {
+		rules[i] = rule.TransactionRule
+	}
+	return rules
+}
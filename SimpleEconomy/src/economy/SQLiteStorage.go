@@ -0,0 +1,628 @@
+package economy
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	
+	_ "modernc.org/sqlite"
+)
+
+// The username column holds the account's UUID; see migrateAccountKeys.
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS accounts (
+	username        TEXT PRIMARY KEY,
+	display_name    TEXT NOT NULL,
+	balance         REAL NOT NULL,
+	last_seen       INTEGER NOT NULL,
+	total_earned    REAL NOT NULL,
+	total_spent     REAL NOT NULL,
+	bank_balance    REAL NOT NULL DEFAULT 0,
+	holds           TEXT,
+	created         INTEGER NOT NULL DEFAULT 0,
+	hidden          INTEGER NOT NULL DEFAULT 0,
+	private_balance INTEGER NOT NULL DEFAULT 0
+)`
+
+// sqliteColumns lists columns added after the first release so Load can
+// upgrade older databases in place.
+var sqliteColumns = map[string]string{
+	"bank_balance":    "REAL NOT NULL DEFAULT 0",
+	"holds":           "TEXT",
+	"created":         "INTEGER NOT NULL DEFAULT 0",
+	"hidden":          "INTEGER NOT NULL DEFAULT 0",
+	"private_balance": "INTEGER NOT NULL DEFAULT 0",
+}
+
+var sqliteTransactionColumns = map[string]string{
+	"previous": "REAL",
+	"batch":    "TEXT",
+	"code":     "TEXT NOT NULL DEFAULT ''",
+	"postings": "TEXT",
+	"tx_id":    "TEXT NOT NULL DEFAULT ''",
+}
+
+var sqliteTransactionsSchema = []string{
+	`CREATE TABLE IF NOT EXISTS transactions (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		from_user TEXT NOT NULL COLLATE NOCASE,
+		to_user   TEXT NOT NULL COLLATE NOCASE,
+		amount    REAL NOT NULL,
+		type      INTEGER NOT NULL,
+		timestamp INTEGER NOT NULL,
+		reason    TEXT NOT NULL,
+		previous  REAL,
+		batch     TEXT,
+		code      TEXT NOT NULL DEFAULT '',
+		postings  TEXT,
+		tx_id     TEXT NOT NULL DEFAULT ''
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_transactions_from ON transactions (from_user, timestamp)`,
+	`CREATE INDEX IF NOT EXISTS idx_transactions_to ON transactions (to_user, timestamp)`,
+	`CREATE INDEX IF NOT EXISTS idx_transactions_timestamp ON transactions (timestamp)`,
+}
+
+// sqliteSearchSchema indexes transaction reasons for /eco search. The
+// trigram tokenizer lets LIKE '%text%' use the index, and the triggers
+// keep it in step with the transactions table.
+var sqliteSearchSchema = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS transactions_search USING fts5(
+		reason, content='transactions', content_rowid='id', tokenize='trigram'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS transactions_search_insert AFTER INSERT ON transactions BEGIN
+		INSERT INTO transactions_search (rowid, reason) VALUES (new.id, new.reason);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS transactions_search_delete AFTER DELETE ON transactions BEGIN
+		INSERT INTO transactions_search (transactions_search, rowid, reason) VALUES ('delete', old.id, old.reason);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS transactions_search_update AFTER UPDATE OF reason ON transactions BEGIN
+		INSERT INTO transactions_search (transactions_search, rowid, reason) VALUES ('delete', old.id, old.reason);
+		INSERT INTO transactions_search (rowid, reason) VALUES (new.id, new.reason);
+	END`,
+}
+
+const sqliteHistorySchema = `CREATE TABLE IF NOT EXISTS balance_history (
+	uuid    TEXT NOT NULL,
+	day     TEXT NOT NULL,
+	balance REAL NOT NULL,
+	PRIMARY KEY (uuid, day)
+)`
+
+const sqliteHistoryUpsert = `INSERT INTO balance_history (uuid, day, balance) VALUES (?, ?, ?)
+ON CONFLICT(uuid, day) DO UPDATE SET balance = excluded.balance`
+
+const sqliteRewardSchema = `CREATE TABLE IF NOT EXISTS reward_claims (
+	uuid     TEXT PRIMARY KEY,
+	last_day TEXT NOT NULL,
+	streak   INTEGER NOT NULL
+)`
+
+const sqliteEarningsSchema = `CREATE TABLE IF NOT EXISTS job_earnings (
+	uuid     TEXT PRIMARY KEY,
+	day      TEXT NOT NULL,
+	earnings TEXT NOT NULL
+)`
+
+var sqliteAuditSchema = []string{
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp   INTEGER NOT NULL,
+		admin       TEXT NOT NULL COLLATE NOCASE,
+		action      TEXT NOT NULL,
+		target      TEXT NOT NULL COLLATE NOCASE,
+		old_balance REAL,
+		new_balance REAL,
+		details     TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_admin ON audit_log (admin, timestamp)`,
+	`CREATE INDEX IF NOT EXISTS idx_audit_target ON audit_log (target, timestamp)`,
+}
+
+var sqliteNotificationSchema = []string{
+	`CREATE TABLE IF NOT EXISTS pending_notifications (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		uuid      TEXT NOT NULL,
+		type      INTEGER NOT NULL,
+		from_user TEXT NOT NULL,
+		reason    TEXT NOT NULL,
+		amount    REAL NOT NULL,
+		timestamp INTEGER NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_pending_notifications_uuid ON pending_notifications (uuid)`,
+}
+
+const sqliteUpsert = `INSERT INTO accounts (username, display_name, balance, last_seen, total_earned, total_spent, bank_balance, holds, created, hidden, private_balance)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(username) DO UPDATE SET
+	display_name = excluded.display_name,
+	balance = excluded.balance,
+	last_seen = excluded.last_seen,
+	total_earned = excluded.total_earned,
+	total_spent = excluded.total_spent,
+	bank_balance = excluded.bank_balance,
+	holds = excluded.holds,
+	created = excluded.created,
+	hidden = excluded.hidden,
+	private_balance = excluded.private_balance`
+
+const sqliteNameIndex = `CREATE INDEX IF NOT EXISTS idx_accounts_display_name ON accounts (display_name COLLATE NOCASE)`
+
+const sqliteTransactionIDIndex = `CREATE INDEX IF NOT EXISTS idx_transactions_tx_id ON transactions (tx_id)`
+
+const sqliteSelect = `SELECT username, display_name, balance, last_seen, total_earned, total_spent, bank_balance, holds, created, hidden, private_balance FROM accounts`
+
+// SQLiteStorage writes only the accounts that changed since the last Save,
+// so saving cost scales with activity instead of with the player count.
+type SQLiteStorage struct {
+	path    string
+	db      *sql.DB
+	pending map[string]PlayerAccount
+	deleted map[string]bool
+	mutex   sync.Mutex
+}
+
+func NewSQLiteStorage(path string) (*SQLiteStorage, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	
+	return &SQLiteStorage{
+		path:    path,
+		db:      db,
+		pending: make(map[string]PlayerAccount),
+		deleted: make(map[string]bool),
+	}, nil
+}
+
+func (s *SQLiteStorage) Load() error {
+	if _, err := s.db.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	
+	if err := s.upgradeColumns("accounts", sqliteColumns); err != nil {
+		return err
+	}
+	
+	if _, err := s.db.Exec(sqliteNameIndex); err != nil {
+		return err
+	}
+	
+	for _, statement := range sqliteTransactionsSchema {
+		if _, err := s.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	
+	if err := s.upgradeColumns("transactions", sqliteTransactionColumns); err != nil {
+		return err
+	}
+	
+	if _, err := s.db.Exec(sqliteTransactionIDIndex); err != nil {
+		return err
+	}
+	
+	if err := migrateSQLSchema(s.db, s.path, s.backupForMigration); err != nil {
+		return err
+	}
+	
+	if err := s.createSearchIndex(); err != nil {
+		return err
+	}
+	
+	if _, err := s.db.Exec(sqliteHistorySchema); err != nil {
+		return err
+	}
+	
+	if _, err := s.db.Exec(sqliteRewardSchema); err != nil {
+		return err
+	}
+	
+	if _, err := s.db.Exec(sqliteEarningsSchema); err != nil {
+		return err
+	}
+	
+	for _, statement := range sqliteAuditSchema {
+		if _, err := s.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	
+	for _, statement := range sqliteNotificationSchema {
+		if _, err := s.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	
+	return nil
+}
+
+// backupForMigration copies the database next to itself before its schema
+// is migrated.
+func (s *SQLiteStorage) backupForMigration(version int) error {
+	path := schemaBackupPath(s.path, version)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	_, err := s.db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
+
+func (s *SQLiteStorage) upgradeColumns(table string, columns map[string]string) error {
+	rows, err := s.db.Query(`PRAGMA table_info(` + table + `)`)
+	if err != nil {
+		return err
+	}
+	
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, primaryKey int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	
+	for column, definition := range columns {
+		if existing[column] {
+			continue
+		}
+		if _, err := s.db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition); err != nil {
+			return err
+		}
+	}
+	
+	return nil
+}
+
+func (s *SQLiteStorage) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	if len(s.pending) == 0 && len(s.deleted) == 0 {
+		return nil
+	}
+	
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	
+	for key := range s.deleted {
+		if _, err := tx.Exec(`DELETE FROM accounts WHERE username = ?`, key); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	
+	stmt, err := tx.Prepare(sqliteUpsert)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	
+	for key, account := range s.pending {
+		holds, err := holdsColumn(account.Holds)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := stmt.Exec(key, account.Username, account.Balance, account.LastSeen.Unix(),
+			account.TotalEarned, account.TotalSpent, account.BankBalance, holds, createdColumn(account.Created), account.Hidden, account.PrivateBalance); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	
+	s.pending = make(map[string]PlayerAccount)
+	s.deleted = make(map[string]bool)
+	return nil
+}
+
+func (s *SQLiteStorage) GetAccount(uuid string) (*PlayerAccount, error) {
+	s.mutex.Lock()
+	if account, exists := s.pending[uuid]; exists {
+		s.mutex.Unlock()
+		return &account, nil
+	}
+	deleted := s.deleted[uuid]
+	s.mutex.Unlock()
+	
+	if deleted {
+		return nil, nil
+	}
+	
+	account, err := scanSQLiteAccount(s.db.QueryRow(sqliteSelect+" WHERE username = ?", uuid))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	
+	return account, err
+}
+
+func (s *SQLiteStorage) PutAccount(account *PlayerAccount) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	s.pending[account.UUID] = *account
+	delete(s.deleted, account.UUID)
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteAccount(uuid string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	delete(s.pending, uuid)
+	s.deleted[uuid] = true
+	return nil
+}
+
+func (s *SQLiteStorage) ListAccounts() ([]*PlayerAccount, error) {
+	rows, err := s.db.Query(sqliteSelect)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	accounts := make(map[string]*PlayerAccount)
+	for rows.Next() {
+		account, err := scanSQLiteAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts[account.UUID] = account
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	
+	s.mutex.Lock()
+	for key := range s.deleted {
+		delete(accounts, key)
+	}
+	for key, account := range s.pending {
+		pending := account
+		accounts[key] = &pending
+	}
+	s.mutex.Unlock()
+	
+	result := make([]*PlayerAccount, 0, len(accounts))
+	for _, account := range accounts {
+		result = append(result, account)
+	}
+	
+	return result, nil
+}
+
+// FindAccount also sees accounts staged since the last Save.
+func (s *SQLiteStorage) FindAccount(username string) (string, error) {
+	rows, err := s.db.Query(`SELECT username, last_seen FROM accounts WHERE display_name = ? COLLATE NOCASE`, username)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	
+	found, foundSeen := "", int64(0)
+	consider := func(uuid string, lastSeen int64) {
+		if found == "" || lastSeen > foundSeen {
+			found, foundSeen = uuid, lastSeen
+		}
+	}
+	for rows.Next() {
+		var uuid string
+		var lastSeen int64
+		if err := rows.Scan(&uuid, &lastSeen); err != nil {
+			return "", err
+		}
+		if _, staged := s.pending[uuid]; !staged && !s.deleted[uuid] {
+			consider(uuid, lastSeen)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	for uuid, account := range s.pending {
+		if strings.EqualFold(account.Username, username) {
+			consider(uuid, account.LastSeen.Unix())
+		}
+	}
+	
+	return found, nil
+}
+
+func (s *SQLiteStorage) CountAccounts() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM accounts`).Scan(&count)
+	return count, err
+}
+
+func (s *SQLiteStorage) AppendTransaction(transaction *Transaction) error {
+	batch, err := batchColumn(transaction)
+	if err != nil {
+		return err
+	}
+	postings, err := postingsColumn(transaction)
+	if err != nil {
+		return err
+	}
+	
+	_, err = s.db.Exec(`INSERT INTO transactions (tx_id, from_user, to_user, amount, type, timestamp, reason, previous, batch, code, postings) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		transaction.ID, transaction.From, transaction.To, transaction.Amount, int(transaction.Type),
+		transaction.Timestamp.UnixNano(), transaction.Reason, transaction.Previous, batch, string(transaction.Code), postings)
+	return err
+}
+
+// createSearchIndex sets up the reason index, filling it from the
+// existing ledger the first time.
+func (s *SQLiteStorage) createSearchIndex() error {
+	var existing int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'transactions_search'`).Scan(&existing); err != nil {
+		return err
+	}
+	
+	for _, statement := range sqliteSearchSchema {
+		if _, err := s.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	
+	if existing == 0 {
+		_, err := s.db.Exec(`INSERT INTO transactions_search (transactions_search) VALUES ('rebuild')`)
+		return err
+	}
+	return nil
+}
+
+var sqliteDialect = transactionDialect{
+	timeArg: func(t time.Time) interface{} {
+		return t.UnixNano()
+	},
+	reasonMatch: func(text string) (string, []interface{}) {
+		return `id IN (SELECT rowid FROM transactions_search WHERE reason LIKE ? ESCAPE '!')`, []interface{}{likePattern(text)}
+	},
+}
+
+func (s *SQLiteStorage) ReassignTransactions(from, into string) (int, error) {
+	return reassignTransactionsSQL(s.db, from, into)
+}
+
+func (s *SQLiteStorage) QueryTransactions(username string, filter TransactionFilter) ([]Transaction, error) {
+	query, args := buildTransactionQuery(username, filter, sqliteDialect)
+	
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	transactions := make([]Transaction, 0)
+	for rows.Next() {
+		var transaction Transaction
+		var timestamp int64
+		var batch, postings sql.NullString
+		if err := rows.Scan(&transaction.ID, &transaction.From, &transaction.To, &transaction.Amount,
+			&transaction.Type, &timestamp, &transaction.Reason, &transaction.Previous, &batch, &transaction.Code, &postings); err != nil {
+			return nil, err
+		}
+		if err := scanBatch(batch, &transaction); err != nil {
+			return nil, err
+		}
+		if err := scanPostings(postings, &transaction); err != nil {
+			return nil, err
+		}
+		transaction.Timestamp = time.Unix(0, timestamp)
+		transactions = append(transactions, transaction)
+	}
+	
+	return transactions, rows.Err()
+}
+
+func (s *SQLiteStorage) RecordBalances(day string, balances map[string]Money) error {
+	return upsertBalanceHistory(s.db, sqliteHistoryUpsert, day, balances)
+}
+
+func (s *SQLiteStorage) QueryBalances(uuid string, since string) ([]BalanceSnapshot, error) {
+	return queryBalanceHistory(s.db, uuid, since)
+}
+
+func (s *SQLiteStorage) PruneBalances(before string) error {
+	return pruneBalanceHistory(s.db, before)
+}
+
+func (s *SQLiteStorage) ClaimReward(uuid, day, yesterday string) (int, bool, error) {
+	return claimRewardSQL(s.db,
+		`INSERT OR IGNORE INTO reward_claims (uuid, last_day, streak) VALUES (?, '', 0)`,
+		`SELECT last_day, streak FROM reward_claims WHERE uuid = ?`,
+		uuid, day, yesterday)
+}
+
+func (s *SQLiteStorage) RecordEarnings(uuid, day, job string, amount, jobCap, dailyCap Money) (Money, error) {
+	return recordEarningsSQL(s.db,
+		`INSERT OR IGNORE INTO job_earnings (uuid, day, earnings) VALUES (?, '', '{}')`,
+		`SELECT day, earnings FROM job_earnings WHERE uuid = ?`,
+		uuid, day, job, amount, jobCap, dailyCap)
+}
+
+func (s *SQLiteStorage) Earnings(uuid, day string) (map[string]Money, error) {
+	return earningsSQL(s.db, uuid, day)
+}
+
+func (s *SQLiteStorage) AppendAudit(entry *AuditEntry) error {
+	_, err := s.db.Exec(`INSERT INTO audit_log (timestamp, admin, action, target, old_balance, new_balance, details) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.Timestamp.UnixNano(), entry.Admin, entry.Action, entry.Target, entry.Old, entry.New, entry.Details)
+	return err
+}
+
+func (s *SQLiteStorage) QueryAudit(name string, offset, limit int) ([]AuditEntry, error) {
+	query, args := buildAuditQuery(name, offset, limit)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	entries := make([]AuditEntry, 0)
+	for rows.Next() {
+		var entry AuditEntry
+		var timestamp int64
+		if err := rows.Scan(&timestamp, &entry.Admin, &entry.Action, &entry.Target, &entry.Old, &entry.New, &entry.Details); err != nil {
+			return nil, err
+		}
+		entry.Timestamp = time.Unix(0, timestamp)
+		entries = append(entries, entry)
+	}
+	
+	return entries, rows.Err()
+}
+
+func (s *SQLiteStorage) QueueNotification(uuid string, notification Notification) error {
+	return queueNotificationSQL(s.db, uuid, notification)
+}
+
+func (s *SQLiteStorage) TakeNotifications(uuid string) ([]Notification, error) {
+	return takeNotificationsSQL(s.db, "", uuid)
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+// createdColumn stores an unknown creation time as 0.
+func createdColumn(created time.Time) int64 {
+	if created.IsZero() {
+		return 0
+	}
+	return created.Unix()
+}
+
+func scanSQLiteAccount(row rowScanner) (*PlayerAccount, error) {
+	var account PlayerAccount
+	var lastSeen, created int64
+	var holds sql.NullString
+	
+	if err := row.Scan(&account.UUID, &account.Username, &account.Balance, &lastSeen,
+		&account.TotalEarned, &account.TotalSpent, &account.BankBalance, &holds, &created, &account.Hidden, &account.PrivateBalance); err != nil {
+		return nil, err
+	}
+	account.LastSeen = time.Unix(lastSeen, 0)
+	if created != 0 {
+		account.Created = time.Unix(created, 0)
+	}
+	
+	return &account, scanHolds(holds, &account)
+}
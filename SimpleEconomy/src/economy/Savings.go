@@ -0,0 +1,418 @@
+package economy
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SavingsConfig offers savings deposits that lock money for one of Terms
+// in return for interest on maturity. Taking a deposit out early forfeits
+// the interest and EarlyPenalty percent of the deposit.
+type SavingsConfig struct {
+	Enabled      bool          `json:"enabled"`
+	Terms        []SavingsTerm `json:"terms"`
+	EarlyPenalty float64       `json:"early_withdraw_penalty"`
+	MinDeposit   Money         `json:"min_deposit"`
+	MaxDeposits  int           `json:"max_deposits"`
+}
+
+// SavingsTerm is a lock period and the percent it pays over the period.
+type SavingsTerm struct {
+	Days int     `json:"days"`
+	Rate float64 `json:"rate"`
+}
+
+// SavingsDeposit is money a player has locked away until Matures, when
+// Amount and Interest are paid into their wallet.
+type SavingsDeposit struct {
+	ID       int       `json:"id"`
+	Player   string    `json:"player"`
+	Amount   Money     `json:"amount"`
+	Interest Money     `json:"interest"`
+	Created  time.Time `json:"created"`
+	Matures  time.Time `json:"matures"`
+}
+
+const savingsCheckInterval = time.Minute
+
+// savingsBook holds every open SavingsDeposit. opening counts each
+// player's deposits still being paid in, and closing marks deposits being
+// paid out. Its mutex is never held while accounts are locked.
+type savingsBook struct {
+	mutex    sync.Mutex
+	deposits map[int]*SavingsDeposit
+	opening  map[string]int
+	closing  map[int]bool
+	nextID   int
+}
+
+func savingsRef(id int) string {
+	return "savings#" + strconv.Itoa(id)
+}
+
+func (e *EconomyPlugin) savingsPath() string {
+	return filepath.Join(e.dataFolder, "savings.json")
+}
+
+func (e *EconomyPlugin) loadSavings() {
+	e.savings.mutex.Lock()
+	defer e.savings.mutex.Unlock()
+	
+	e.savings.deposits = make(map[int]*SavingsDeposit)
+	e.savings.opening = make(map[string]int)
+	e.savings.closing = make(map[int]bool)
+	e.savings.nextID = 1
+	
+	data, err := ioutil.ReadFile(e.savingsPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Error("Failed to read savings", "error", err)
+		return
+	}
+	
+	if err := json.Unmarshal(data, &e.savings.deposits); err != nil {
+		e.logger.Error("Failed to parse savings", "error", err)
+	}
+	for id := range e.savings.deposits {
+		if id >= e.savings.nextID {
+			e.savings.nextID = id + 1
+		}
+	}
+}
+
+// saveSavings must be called with e.savings.mutex held.
+func (e *EconomyPlugin) saveSavings() {
+	data, err := json.MarshalIndent(e.savings.deposits, "", "  ")
+	if err != nil {
+		e.logger.Error("Failed to marshal savings", "error", err)
+		return
+	}
+	
+	if err := writeFileAtomic(e.savingsPath(), data, 0644); err != nil {
+		e.logger.Error("Failed to write savings", "error", err)
+		e.countStorageError("savings")
+	}
+}
+
+// savingsTerm finds the term lasting days, or the first term for zero.
+func (e *EconomyPlugin) savingsTerm(days int) (SavingsTerm, error) {
+	terms := e.config.Savings.Terms
+	if days == 0 && len(terms) > 0 {
+		return terms[0], nil
+	}
+	for _, term := range terms {
+		if term.Days == days {
+			return term, nil
+		}
+	}
+	return SavingsTerm{}, ErrInvalidSavingsTerm
+}
+
+// CreateSavingsDeposit locks amount from player's wallet for the term
+// lasting days, or the first term if days is zero.
+func (e *EconomyPlugin) CreateSavingsDeposit(player string, amount Money, days int) (SavingsDeposit, error) {
+	config := e.config.Savings
+	if !config.Enabled {
+		return SavingsDeposit{}, ErrSavingsDisabled
+	}
+	if amount <= 0 || amount < config.MinDeposit {
+		return SavingsDeposit{}, ErrInvalidAmount
+	}
+	term, err := e.savingsTerm(days)
+	if err != nil {
+		return SavingsDeposit{}, err
+	}
+	if !e.hasAccount(player) {
+		return SavingsDeposit{}, ErrAccountNotFound
+	}
+	
+	key := strings.ToLower(player)
+	e.savings.mutex.Lock()
+	open := e.savings.opening[key]
+	for _, deposit := range e.savings.deposits {
+		if strings.EqualFold(deposit.Player, player) {
+			open++
+		}
+	}
+	if config.MaxDeposits > 0 && open >= config.MaxDeposits {
+		e.savings.mutex.Unlock()
+		return SavingsDeposit{}, ErrSavingsLimit
+	}
+	e.savings.opening[key]++
+	e.savings.mutex.Unlock()
+	
+	var oldBalance Money
+	err = e.mutateAccounts([]string{player}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if account.spendable()-amount < e.minBalance() {
+			return ErrInsufficientFunds
+		}
+		
+		oldBalance = account.Balance
+		account.Balance -= amount
+		return nil
+	})
+	
+	var created SavingsDeposit
+	e.savings.mutex.Lock()
+	if e.savings.opening[key]--; e.savings.opening[key] <= 0 {
+		delete(e.savings.opening, key)
+	}
+	if err == nil {
+		now := e.now()
+		deposit := &SavingsDeposit{
+			ID:       e.savings.nextID,
+			Player:   player,
+			Amount:   amount,
+			Interest: e.roundAmount(amount.MulRate(term.Rate / 100)),
+			Created:  now,
+			Matures:  now.AddDate(0, 0, term.Days),
+		}
+		e.savings.nextID++
+		e.savings.deposits[deposit.ID] = deposit
+		e.saveSavings()
+		created = *deposit
+	}
+	e.savings.mutex.Unlock()
+	if err != nil {
+		return SavingsDeposit{}, err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(player, oldBalance, oldBalance-amount, SAVINGS_DEPOSIT)
+	e.recordTransaction(&Transaction{
+		From:      player,
+		To:        savingsRef(created.ID),
+		Amount:    amount,
+		Type:      SAVINGS_DEPOSIT,
+		Timestamp: e.now(),
+		Reason:    "Savings deposit for " + strconv.Itoa(int(created.Matures.Sub(created.Created).Hours()/24)) + " days",
+	})
+	
+	return created, nil
+}
+
+// GetSavings returns player's open savings deposits, oldest first.
+func (e *EconomyPlugin) GetSavings(player string) []SavingsDeposit {
+	e.savings.mutex.Lock()
+	defer e.savings.mutex.Unlock()
+	
+	deposits := make([]SavingsDeposit, 0)
+	for _, deposit := range e.savings.deposits {
+		if strings.EqualFold(deposit.Player, player) {
+			deposits = append(deposits, *deposit)
+		}
+	}
+	sort.Slice(deposits, func(i, j int) bool {
+		return deposits[i].ID < deposits[j].ID
+	})
+	
+	return deposits
+}
+
+// WithdrawSavings pays savings deposit id out to player. A matured deposit
+// pays its interest too, as far as the supply cap allows; one that has not
+// matured yet is returned less the early withdrawal penalty. It returns the
+// amount paid out.
+func (e *EconomyPlugin) WithdrawSavings(player string, id int) (Money, error) {
+	e.savings.mutex.Lock()
+	deposit, exists := e.savings.deposits[id]
+	var snapshot SavingsDeposit
+	if exists {
+		snapshot = *deposit
+	}
+	e.savings.mutex.Unlock()
+	
+	if !exists || !strings.EqualFold(snapshot.Player, player) {
+		return 0, ErrSavingsNotFound
+	}
+	return e.closeSavings(snapshot)
+}
+
+func (e *EconomyPlugin) closeSavings(deposit SavingsDeposit) (Money, error) {
+	e.savings.mutex.Lock()
+	_, exists := e.savings.deposits[deposit.ID]
+	if !exists || e.savings.closing[deposit.ID] {
+		e.savings.mutex.Unlock()
+		return 0, ErrSavingsNotFound
+	}
+	e.savings.closing[deposit.ID] = true
+	e.savings.mutex.Unlock()
+	
+	matured := !e.now().Before(deposit.Matures)
+	payout := deposit.Amount
+	transactionType, reason := SAVINGS_WITHDRAW, "Savings withdrawn early"
+	if matured {
+		transactionType, reason = SAVINGS_MATURED, "Savings matured"
+		if interest, err := e.allowMint(deposit.Player, "savings interest", deposit.Interest); err == nil {
+			payout += interest
+		}
+	} else {
+		penalty := e.roundAmount(deposit.Amount.MulRate(e.config.Savings.EarlyPenalty / 100))
+		payout = clampZero(payout - penalty)
+	}
+	
+	var oldBalance Money
+	err := e.mutateAccounts([]string{deposit.Player}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if account.Balance+payout > e.config.MaxBalance {
+			return ErrMaxBalanceExceeded
+		}
+		oldBalance = account.Balance
+		account.Balance += payout
+		if payout > deposit.Amount {
+			account.TotalEarned += payout - deposit.Amount
+		}
+		return nil
+	})
+	
+	e.savings.mutex.Lock()
+	delete(e.savings.closing, deposit.ID)
+	if err == nil {
+		delete(e.savings.deposits, deposit.ID)
+		e.saveSavings()
+	}
+	e.savings.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(deposit.Player, oldBalance, oldBalance+payout, transactionType)
+	e.recordTransaction(&Transaction{
+		From:      savingsRef(deposit.ID),
+		To:        deposit.Player,
+		Amount:    payout,
+		Type:      transactionType,
+		Timestamp: e.now(),
+		Reason:    reason,
+		Postings: e.postings(
+			Posting{Account: savingsRef(deposit.ID), Amount: -deposit.Amount},
+			Posting{Account: deposit.Player, Amount: payout},
+		),
+	})
+	
+	return payout, nil
+}
+
+// reassignSavings moves from's savings deposits to into.
+func (e *EconomyPlugin) reassignSavings(from, into string) {
+	e.savings.mutex.Lock()
+	defer e.savings.mutex.Unlock()
+	
+	changed := false
+	for _, deposit := range e.savings.deposits {
+		if strings.EqualFold(deposit.Player, from) {
+			deposit.Player, changed = into, true
+		}
+	}
+	if changed {
+		e.saveSavings()
+	}
+}
+
+func (e *EconomyPlugin) startSavingsMaturity() {
+	e.runPeriodically(savingsCheckInterval, e.payMaturedSavings)
+}
+
+// payMaturedSavings pays out every matured deposit. One that would take
+// its owner past max_balance waits until they have room.
+func (e *EconomyPlugin) payMaturedSavings() {
+	now := e.now()
+	matured := make([]SavingsDeposit, 0)
+	
+	e.savings.mutex.Lock()
+	for _, deposit := range e.savings.deposits {
+		if !now.Before(deposit.Matures) {
+			matured = append(matured, *deposit)
+		}
+	}
+	e.savings.mutex.Unlock()
+	
+	for _, deposit := range matured {
+		payout, err := e.closeSavings(deposit)
+		if errors.Is(err, ErrMaxBalanceExceeded) {
+			continue
+		}
+		if err != nil {
+			e.logger.Warn("Failed to pay out savings", "deposit", deposit.ID, "error", err)
+			continue
+		}
+		e.logger.Info("Paid out matured savings", "deposit", deposit.ID, "player", deposit.Player, "amount", payout.String())
+	}
+}
+
+func (e *EconomyPlugin) savingsCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) == 0 {
+		return e.message("savings.usage")
+	}
+	player := ctx.Name()
+	
+	switch strings.ToLower(args[0]) {
+	case "deposit":
+		if len(args) != 2 && len(args) != 3 {
+			return e.message("savings.usage")
+		}
+		amount, err := e.parseAmount(args[1])
+		if err != nil {
+			return e.message("error.invalid_amount")
+		}
+		days := 0
+		if len(args) == 3 {
+			if days, err = strconv.Atoi(args[2]); err != nil || days <= 0 {
+				return e.message("savings.bad_days")
+			}
+		}
+		deposit, err := e.CreateSavingsDeposit(player, amount, days)
+		if err != nil {
+			return e.message("savings.failed", "error", e.describeError(err))
+		}
+		return e.message("savings.deposited", "id", strconv.Itoa(deposit.ID), "amount", e.FormatMoney(deposit.Amount),
+			"interest", e.FormatMoney(deposit.Interest), "matures", deposit.Matures.Format("2006-01-02 15:04"))
+		
+	case "withdraw":
+		if len(args) != 2 {
+			return e.message("savings.usage")
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(args[1], "#"))
+		if err != nil {
+			return e.message("savings.bad_id")
+		}
+		payout, err := e.WithdrawSavings(player, id)
+		if err != nil {
+			return e.message("savings.failed", "error", e.describeError(err))
+		}
+		return e.message("savings.withdrawn", "id", strconv.Itoa(id), "amount", e.FormatMoney(payout))
+		
+	case "list":
+		deposits := e.GetSavings(player)
+		if len(deposits) == 0 {
+			result := e.message("savings.none")
+			for _, term := range e.config.Savings.Terms {
+				result += "\n" + e.message("savings.term", "days", strconv.Itoa(term.Days),
+					"rate", strconv.FormatFloat(term.Rate, 'f', -1, 64))
+			}
+			return result
+		}
+		result := e.message("savings.header")
+		for _, deposit := range deposits {
+			result += "\n" + e.message("savings.entry", "id", strconv.Itoa(deposit.ID), "amount", e.FormatMoney(deposit.Amount),
+				"interest", e.FormatMoney(deposit.Interest), "matures", deposit.Matures.Format("2006-01-02 15:04"))
+		}
+		return result
+		
+	default:
+		return e.message("savings.usage")
+	}
+}
@@ -0,0 +1,209 @@
+package economy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backgroundTasks tracks the goroutines started for periodic jobs so that
+// OnDisable can stop them and wait before the final save.
+type backgroundTasks struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (e *EconomyPlugin) startBackgroundTasks() {
+	e.background.ctx, e.background.cancel = context.WithCancel(context.Background())
+	
+	if e.config.AutoSaveSeconds > 0 {
+		interval := time.Duration(e.config.AutoSaveSeconds) * time.Second
+		e.runPeriodically(interval, e.autoSave)
+	}
+	
+	if e.transactionFile != nil {
+		e.runPeriodically(logFlushInterval, e.transactionFile.Flush)
+	}
+	
+	e.startInterestScheduler()
+	e.startPayday()
+	e.startLoanCollector()
+	e.startEscrowExpiry()
+	e.startGiftExpiry()
+	e.startPendingDelivery()
+	e.startReceivableCollector()
+	e.startAllowances()
+	e.startSavingsMaturity()
+	e.startVoucherExpiry()
+	e.startLeaderboardRefresher()
+	e.startBalanceHistory()
+	e.startBackupScheduler()
+	e.startDiscordNotifier()
+	e.startAccountEviction()
+	e.startRuleWatcher()
+	e.startHealthReports()
+	e.startWealthTax()
+}
+
+func (e *EconomyPlugin) stopBackgroundTasks() {
+	if e.background.cancel == nil {
+		return
+	}
+	
+	e.background.cancel()
+	e.background.wg.Wait()
+	e.background.cancel = nil
+}
+
+// runPeriodically calls fn every interval until the background context is
+// cancelled. Ticks that arrive while fn is still running are dropped.
+func (e *EconomyPlugin) runPeriodically(interval time.Duration, fn func()) {
+	ctx := e.background.ctx
+	
+	e.background.wg.Add(1)
+	go func() {
+		defer e.background.wg.Done()
+		
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+}
+
+func (e *EconomyPlugin) autoSave() {
+	if flushed, _ := e.savePlayerData(); flushed > 0 {
+		e.logger.Info("Auto-saved accounts", "accounts", flushed)
+	}
+}
+
+// cronSchedule is a parsed five-field cron expression (minute, hour, day
+// of month, month, day of week). Each field is a bit set of the values it
+// matches.
+type cronSchedule struct {
+	fields [5]uint64
+	domAny bool
+	dowAny bool
+}
+
+var cronBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+var cronAliases = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// parseCronSchedule accepts *, single values, ranges (1-5), steps (*/15,
+// 0-30/10) and comma-separated lists of those, plus the @hourly, @daily,
+// @weekly and @monthly shorthands.
+func parseCronSchedule(spec string) (*cronSchedule, error) {
+	if alias, ok := cronAliases[strings.ToLower(strings.TrimSpace(spec))]; ok {
+		spec = alias
+	}
+	
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron schedule %q must have 5 fields", spec)
+	}
+	
+	schedule := &cronSchedule{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	for i, field := range fields {
+		bits, err := parseCronField(field, cronBounds[i][0], cronBounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron schedule %q: %v", spec, err)
+		}
+		schedule.fields[i] = bits
+	}
+	
+	// Sunday may be written as 0 or 7.
+	if schedule.fields[4]&(1<<7) != 0 {
+		schedule.fields[4] |= 1
+	}
+	
+	return schedule, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if base, stepText, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step, part = n, base
+		}
+		
+		low, high := min, max
+		if part != "*" {
+			lowText, highText, isRange := strings.Cut(part, "-")
+			var err error
+			if low, err = strconv.Atoi(lowText); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(highText); err != nil {
+					return 0, fmt.Errorf("invalid range %q", part)
+				}
+			} else if step > 1 {
+				high = max
+			}
+		}
+		
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	
+	return bits, nil
+}
+
+// matches reports whether t falls in a minute the schedule selects. As in
+// cron, a restricted day of month and day of week match if either does.
+func (c *cronSchedule) matches(t time.Time) bool {
+	has := func(field, value int) bool {
+		return c.fields[field]&(1<<uint(value)) != 0
+	}
+	
+	if !has(0, t.Minute()) || !has(1, t.Hour()) || !has(3, int(t.Month())) {
+		return false
+	}
+	
+	dom, dow := has(2, t.Day()), has(4, int(t.Weekday()))
+	if !c.domAny && !c.dowAny {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// runOnSchedule calls fn once in every minute schedule matches until the
+// background context is cancelled.
+func (e *EconomyPlugin) runOnSchedule(schedule *cronSchedule, fn func()) {
+	var last time.Time
+	e.runPeriodically(30*time.Second, func() {
+		minute := e.now().Truncate(time.Minute)
+		if minute.Equal(last) || !schedule.matches(minute) {
+			return
+		}
+		last = minute
+		fn()
+	})
+}
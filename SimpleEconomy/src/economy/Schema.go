@@ -0,0 +1,200 @@
+package economy
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// storageSchemaVersion is the version of the stored data format this
+// build writes. Data marked with a later version is refused rather than
+// misread, and data marked with an earlier one is upgraded at startup.
+const storageSchemaVersion = 2
+
+// schemaMigration upgrades stored data from the version before it to
+// version. Data written before versioning is treated as version 0, so
+// every step must leave data already in its format alone.
+type schemaMigration struct {
+	version     int
+	description string
+}
+
+// schemaMigrations lists every format change in order. A backend applies
+// each step in its own way; see migrateSQLSchema and JSONStorage.
+var schemaMigrations = []schemaMigration{
+	{1, "key accounts by UUID instead of name"},
+	{2, "round amounts stored in floating point to fixed-point precision"},
+}
+
+// migrateSchema brings data in store at version from up to
+// storageSchemaVersion. When there is anything to do, backup runs once
+// first with the version found, then apply for each step still missing,
+// in order. It returns the
+// version reached, which is earlier than storageSchemaVersion only along
+// with an error.
+func migrateSchema(store string, from int, backup func(version int) error, apply func(step schemaMigration) error) (int, error) {
+	if err := checkSchemaVersion(store, from); err != nil {
+		return from, err
+	}
+	if from == storageSchemaVersion {
+		return from, nil
+	}
+	
+	if backup != nil {
+		if err := backup(from); err != nil {
+			return from, fmt.Errorf("back up %s before migrating: %w", store, err)
+		}
+	}
+	
+	for _, step := range schemaMigrations {
+		if step.version <= from {
+			continue
+		}
+		slog.Info("Migrating stored data", "store", store, "version", step.version, "step", step.description)
+		if err := apply(step); err != nil {
+			return from, fmt.Errorf("migrate %s to version %d (%s): %w", store, step.version, step.description, err)
+		}
+		from = step.version
+	}
+	
+	return from, nil
+}
+
+// checkSchemaVersion refuses data written by a newer build.
+func checkSchemaVersion(store string, version int) error {
+	if version > storageSchemaVersion {
+		return fmt.Errorf("%w: %s is at version %d, this build reads up to %d", ErrSchemaTooNew,
+			store, version, storageSchemaVersion)
+	}
+	return nil
+}
+
+const sqlSchemaVersionTable = `CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`
+
+// roundedColumns are the floating point amount columns that version 2
+// rounds to what Money can hold.
+var roundedColumns = map[string][]string{
+	"accounts":     {"balance", "total_earned", "total_spent", "bank_balance"},
+	"transactions": {"amount", "previous"},
+}
+
+// migrateSQLSchema upgrades the accounts and transactions tables, which
+// must exist already, for both SQL backends. A database without a
+// version is new when both tables are empty and needs no migrating.
+func migrateSQLSchema(db *sql.DB, store string, backup func(version int) error) error {
+	if _, err := db.Exec(sqlSchemaVersionTable); err != nil {
+		return err
+	}
+	
+	version := 0
+	err := db.QueryRow(`SELECT version FROM schema_version`).Scan(&version)
+	if err == sql.ErrNoRows {
+		var rows int
+		if err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM accounts) + (SELECT COUNT(*) FROM transactions)`).Scan(&rows); err != nil {
+			return err
+		}
+		if rows == 0 {
+			return setSQLSchemaVersion(db, storageSchemaVersion)
+		}
+	} else if err != nil {
+		return err
+	}
+	
+	_, err = migrateSchema(store, version, backup, func(step schemaMigration) error {
+		switch step.version {
+		case 1:
+			if err := migrateAccountKeys(db); err != nil {
+				return err
+			}
+		case 2:
+			if err := roundSQLAmounts(db); err != nil {
+				return err
+			}
+		}
+		return setSQLSchemaVersion(db, step.version)
+	})
+	return err
+}
+
+func setSQLSchemaVersion(db *sql.DB, version int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM schema_version`); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version) VALUES (?)`, version); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// roundSQLAmounts rounds away the float64 noise older builds left in
+// amounts, such as 0.30000000000000004, so SQL comparisons and sums agree
+// with Money.
+func roundSQLAmounts(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, table := range []string{"accounts", "transactions"} {
+		query := `UPDATE ` + table + ` SET `
+		for i, column := range roundedColumns[table] {
+			if i > 0 {
+				query += `, `
+			}
+			query += column + ` = ROUND(` + column + `, ` + strconv.Itoa(moneyDigits) + `)`
+		}
+		if _, err := tx.Exec(query); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// schemaBackupPath names the copy of path taken before migrating it from
+// version.
+func schemaBackupPath(path string, version int) string {
+	return path + ".v" + strconv.Itoa(version) + ".bak"
+}
+
+// schemaFile is the version marker kept by file-based stores that have no
+// header of their own to put it in.
+type schemaFile struct {
+	SchemaVersion int `json:"schema_version"`
+}
+
+// readSchemaFile returns the version recorded at path, 0 when there is
+// none yet.
+func readSchemaFile(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	
+	var marker schemaFile
+	if err := json.Unmarshal(data, &marker); err != nil {
+		return 0, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+	}
+	return marker.SchemaVersion, nil
+}
+
+func writeSchemaFile(path string, version int) error {
+	data, err := json.Marshal(schemaFile{SchemaVersion: version})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
@@ -0,0 +1,162 @@
+package economy
+
+import (
+	"sort"
+	"strings"
+)
+
+// scopeSeparator joins a player's name to the scope of one of their scoped
+// accounts, as in "steve@skyblock", and their UUID to it in the account
+// key.
+const scopeSeparator = "@"
+
+// GlobalScope names the economy of unscoped accounts, which every player
+// has whether or not scopes are configured.
+const GlobalScope = "global"
+
+// ScopeConfig splits balances into separate economies, such as survival
+// and skyblock, by keeping a player's balance in each scope in an account
+// of its own. Worlds maps each scope to the worlds that share it. Server
+// is the scope of this server, used in worlds not listed and by API calls
+// that name no scope; empty is the global economy.
+type ScopeConfig struct {
+	Server string              `json:"server"`
+	Worlds map[string][]string `json:"worlds"`
+}
+
+// WorldSender is implemented by command senders that know which world
+// they are in, so that their commands use the world's scope.
+type WorldSender interface {
+	World() string
+}
+
+func validScopeName(scope string) bool {
+	if scope == "" || len(scope) > 24 {
+		return false
+	}
+	for _, r := range scope {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ScopedName returns the name of the account holding player's balance in
+// scope. The empty and global scopes return player itself, as do virtual
+// and already scoped names.
+func ScopedName(player, scope string) string {
+	scope = strings.ToLower(scope)
+	if scope == "" || scope == GlobalScope || IsVirtualAccount(player) || strings.Contains(player, scopeSeparator) {
+		return player
+	}
+	return player + scopeSeparator + scope
+}
+
+// splitScoped returns the player and scope of a scoped account name, or
+// name and no scope for any other.
+func splitScoped(name string) (player, scope string) {
+	if i := strings.LastIndex(name, scopeSeparator); i > 0 && !IsVirtualAccount(name) {
+		return name[:i], name[i+len(scopeSeparator):]
+	}
+	return name, ""
+}
+
+// inScope resolves the optional scope the API methods take: none, or an
+// empty one, means this server's scope.
+func (e *EconomyPlugin) inScope(player string, scope []string) string {
+	if len(scope) > 0 && scope[0] != "" {
+		return ScopedName(player, scope[0])
+	}
+	return ScopedName(player, e.config.Scopes.Server)
+}
+
+// worldScope returns the scope world belongs to.
+func (e *EconomyPlugin) worldScope(world string) string {
+	for scope, worlds := range e.config.Scopes.Worlds {
+		for _, listed := range worlds {
+			if strings.EqualFold(listed, world) {
+				return scope
+			}
+		}
+	}
+	return e.config.Scopes.Server
+}
+
+// senderScope returns the scope commands from sender act in.
+func (e *EconomyPlugin) senderScope(sender CommandSender) string {
+	if located, ok := sender.(WorldSender); ok {
+		return e.worldScope(located.World())
+	}
+	return e.config.Scopes.Server
+}
+
+// scopes returns every scope configured, besides the global one.
+func (e *EconomyPlugin) scopes() []string {
+	scopes := make([]string, 0, len(e.config.Scopes.Worlds)+1)
+	if server := e.config.Scopes.Server; server != "" && server != GlobalScope {
+		scopes = append(scopes, server)
+	}
+	for scope := range e.config.Scopes.Worlds {
+		if scope != GlobalScope && scope != e.config.Scopes.Server {
+			scopes = append(scopes, scope)
+		}
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+// accountUUID returns the key a new account for username is stored under.
+// A scoped account is keyed by its player's UUID, or by the offline UUID
+// of their name until they have one, so it follows them as they rename.
+func (e *EconomyPlugin) accountUUID(username string) string {
+	player, scope := splitScoped(username)
+	if scope == "" {
+		return OfflineUUID(username)
+	}
+	uuid, exists := e.GetUUID(player)
+	if !exists {
+		uuid = OfflineUUID(player)
+	}
+	return uuid + scopeSeparator + scope
+}
+
+// joinScopes brings the scoped accounts of a player who joined with uuid
+// as username up to date: those keyed by the offline UUID of their name
+// move to uuid, and all are renamed to username.
+func (e *EconomyPlugin) joinScopes(uuid, username string) {
+	for _, scope := range e.scopes() {
+		key := uuid + scopeSeparator + scope
+		account, exists := e.loadAccount(key)
+		if !exists {
+			if account, exists = e.loadAccount(OfflineUUID(username) + scopeSeparator + scope); !exists {
+				continue
+			}
+		}
+		name := ScopedName(username, scope)
+		
+		unlock := e.locks.lock(account)
+		e.mutex.Lock()
+		if account.UUID != key && e.playerData[key] == nil {
+			delete(e.playerData, account.UUID)
+			delete(e.dirty, account.UUID)
+			e.removed[account.UUID] = true
+			account.UUID = key
+			e.playerData[key] = account
+			e.cache.touch(key)
+		}
+		if account.UUID == key {
+			if e.names[strings.ToLower(account.Username)] == key {
+				delete(e.names, strings.ToLower(account.Username))
+			}
+			account.Username = name
+			account.LastSeen = e.now()
+			e.names[strings.ToLower(name)] = key
+			e.dirty[key] = true
+		}
+		e.mutex.Unlock()
+		unlock()
+	}
+}
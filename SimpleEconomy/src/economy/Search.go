@@ -0,0 +1,149 @@
+package economy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchPageSize is how many transactions /eco search shows per page.
+const searchPageSize = 10
+
+// TransactionSearch is a ledger search: the player involved, if any, and
+// the filter for everything else.
+type TransactionSearch struct {
+	Player string
+	Filter TransactionFilter
+}
+
+// parseTransactionSearch reads /eco search terms such as
+//
+//	player:Bob min:1000000 since:2026-10-06 until:2026-10-06 refund
+//
+// Words without a key are searched for in the reason. Dates cover the
+// whole day; since also accepts a duration like 7d.
+func parseTransactionSearch(terms []string, now time.Time) (TransactionSearch, int, error) {
+	var search TransactionSearch
+	page := 1
+	words := make([]string, 0)
+	
+	for _, term := range terms {
+		key, value, ok := strings.Cut(term, ":")
+		if !ok || value == "" {
+			words = append(words, term)
+			continue
+		}
+		
+		var err error
+		switch strings.ToLower(key) {
+		case "player":
+			search.Player = value
+		case "reason":
+			words = append(words, value)
+		case "min":
+			search.Filter.MinAmount, err = ParseMoney(value)
+		case "max":
+			search.Filter.MaxAmount, err = ParseMoney(value)
+		case "type":
+			t, known := parseTransactionType(value)
+			if !known {
+				return search, 0, fmt.Errorf("unknown transaction type %q", value)
+			}
+			search.Filter.Types = append(search.Filter.Types, t)
+		case "since":
+			search.Filter.Since, err = parseSearchTime(value, now, false)
+		case "until":
+			search.Filter.Until, err = parseSearchTime(value, now, true)
+		case "page":
+			page, err = strconv.Atoi(value)
+			if err == nil && page < 1 {
+				err = fmt.Errorf("page must be at least 1")
+			}
+		default:
+			words = append(words, term)
+			continue
+		}
+		if err != nil {
+			return search, 0, fmt.Errorf("invalid %s: %q", strings.ToLower(key), value)
+		}
+	}
+	
+	search.Filter.Reason = strings.Join(words, " ")
+	return search, page, nil
+}
+
+// parseSearchTime reads a date, or for since a duration back from now.
+// until is the end of the given day.
+func parseSearchTime(value string, now time.Time, end bool) (time.Time, error) {
+	if day, err := time.ParseInLocation(historyDayLayout, value, now.Location()); err == nil {
+		if end {
+			return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+		}
+		return day, nil
+	}
+	if end {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	
+	duration, err := parseDuration(strings.ToLower(value))
+	if err != nil || duration <= 0 {
+		return time.Time{}, fmt.Errorf("invalid date or duration %q", value)
+	}
+	return now.Add(-duration), nil
+}
+
+// SearchTransactions runs a ledger search. The SQL backends answer reason
+// searches from a full-text index rather than reading the whole ledger.
+func (e *EconomyPlugin) SearchTransactions(search TransactionSearch) []Transaction {
+	return e.GetTransactions(search.Player, search.Filter)
+}
+
+func (e *EconomyPlugin) searchCommand(ctx *CommandContext) string {
+	if len(ctx.Args) == 0 {
+		return e.message("search.usage")
+	}
+	
+	search, page, err := parseTransactionSearch(ctx.Args, e.now())
+	if err != nil {
+		return e.message("search.invalid", "error", err.Error())
+	}
+	
+	search.Filter.Offset = (page - 1) * searchPageSize
+	search.Filter.Limit = searchPageSize + 1
+	transactions := e.SearchTransactions(search)
+	
+	query := strings.Join(ctx.Args, " ")
+	if len(transactions) == 0 {
+		return e.message("search.empty", "query", query, "page", strconv.Itoa(page))
+	}
+	
+	hasMore := len(transactions) > searchPageSize
+	if hasMore {
+		transactions = transactions[:searchPageSize]
+	}
+	
+	result := e.message("search.header", "query", query, "page", strconv.Itoa(page))
+	for _, transaction := range transactions {
+		result += "\n" + e.message("transactions.entry",
+			"time", transaction.Timestamp.Format("2006-01-02 15:04"),
+			"type", transaction.Type.String(),
+			"from", transaction.From,
+			"to", transaction.To,
+			"amount", e.FormatMoney(transaction.Amount),
+			"reason", transaction.Reason)
+	}
+	
+	if hasMore {
+		next := make([]string, 0, len(ctx.Args)+1)
+		for _, term := range ctx.Args {
+			if !strings.HasPrefix(strings.ToLower(term), "page:") {
+				next = append(next, term)
+			}
+		}
+		next = append(next, "page:"+strconv.Itoa(page+1))
+		result += "\n" + e.message("search.next_page", "next", strings.Join(next, " "))
+	}
+	
+	return result
+}
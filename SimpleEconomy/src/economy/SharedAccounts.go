@@ -0,0 +1,397 @@
+package economy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SharedAccount is a named treasury that several players pay into. The
+// owner may always withdraw; members may withdraw up to their daily limit.
+type SharedAccount struct {
+	Name    string                   `json:"name"`
+	Owner   string                   `json:"owner"`
+	Balance Money                    `json:"balance"`
+	Members map[string]*SharedMember `json:"members"`
+	Created time.Time                `json:"created"`
+}
+
+type SharedMember struct {
+	Username           string    `json:"username"`
+	DailyWithdrawLimit Money     `json:"daily_withdraw_limit"`
+	WithdrawnToday     Money     `json:"withdrawn_today"`
+	WithdrawDay        time.Time `json:"withdraw_day"`
+}
+
+// sharedAccounts holds every SharedAccount. Its mutex is only ever taken
+// after account shard locks, never before.
+type sharedAccounts struct {
+	mutex    sync.Mutex
+	accounts map[string]*SharedAccount
+}
+
+func sharedAccountRef(name string) string {
+	return "@" + strings.ToLower(name)
+}
+
+func (e *EconomyPlugin) sharedAccountsPath() string {
+	return filepath.Join(e.dataFolder, "shared_accounts.json")
+}
+
+func (e *EconomyPlugin) loadSharedAccounts() {
+	e.shared.mutex.Lock()
+	defer e.shared.mutex.Unlock()
+	
+	e.shared.accounts = make(map[string]*SharedAccount)
+	
+	data, err := ioutil.ReadFile(e.sharedAccountsPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Error("Failed to read shared accounts", "error", err)
+		return
+	}
+	
+	if err := json.Unmarshal(data, &e.shared.accounts); err != nil {
+		e.logger.Error("Failed to parse shared accounts", "error", err)
+	}
+}
+
+// saveSharedAccounts must be called with e.shared.mutex held.
+func (e *EconomyPlugin) saveSharedAccounts() {
+	data, err := json.MarshalIndent(e.shared.accounts, "", "  ")
+	if err != nil {
+		e.logger.Error("Failed to marshal shared accounts", "error", err)
+		return
+	}
+	
+	if err := writeFileAtomic(e.sharedAccountsPath(), data, 0644); err != nil {
+		e.logger.Error("Failed to write shared accounts", "error", err)
+		e.countStorageError("shared")
+	}
+}
+
+func (e *EconomyPlugin) CreateSharedAccount(name, owner string) error {
+	if name == "" || strings.ContainsAny(name, " @") {
+		return ErrInvalidAccountName
+	}
+	
+	e.shared.mutex.Lock()
+	defer e.shared.mutex.Unlock()
+	
+	key := strings.ToLower(name)
+	if _, exists := e.shared.accounts[key]; exists {
+		return ErrSharedAccountExists
+	}
+	
+	e.shared.accounts[key] = &SharedAccount{
+		Name:    name,
+		Owner:   owner,
+		Members: make(map[string]*SharedMember),
+		Created: e.now(),
+	}
+	e.saveSharedAccounts()
+	
+	return nil
+}
+
+// InviteSharedMember adds or updates a member. Only the owner may invite.
+func (e *EconomyPlugin) InviteSharedMember(name, inviter, member string, dailyLimit Money) error {
+	if dailyLimit < 0 {
+		return ErrInvalidAmount
+	}
+	
+	e.shared.mutex.Lock()
+	defer e.shared.mutex.Unlock()
+	
+	account, exists := e.shared.accounts[strings.ToLower(name)]
+	if !exists {
+		return ErrSharedAccountNotFound
+	}
+	if !strings.EqualFold(account.Owner, inviter) {
+		return ErrNotAuthorized
+	}
+	
+	account.Members[strings.ToLower(member)] = &SharedMember{
+		Username:           member,
+		DailyWithdrawLimit: dailyLimit,
+	}
+	e.saveSharedAccounts()
+	
+	return nil
+}
+
+func (e *EconomyPlugin) GetSharedAccount(name string) (SharedAccount, error) {
+	e.shared.mutex.Lock()
+	defer e.shared.mutex.Unlock()
+	
+	account, exists := e.shared.accounts[strings.ToLower(name)]
+	if !exists {
+		return SharedAccount{}, ErrSharedAccountNotFound
+	}
+	
+	snapshot := *account
+	snapshot.Members = make(map[string]*SharedMember, len(account.Members))
+	for key, member := range account.Members {
+		copied := *member
+		snapshot.Members[key] = &copied
+	}
+	
+	return snapshot, nil
+}
+
+// SharedDeposit moves money from a player's wallet into a shared account.
+// Anyone who belongs to the account may deposit.
+func (e *EconomyPlugin) SharedDeposit(name, username string, amount Money) error {
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	
+	err := e.mutateAccounts([]string{username}, func(accounts []*PlayerAccount) error {
+		e.shared.mutex.Lock()
+		defer e.shared.mutex.Unlock()
+		
+		shared, exists := e.shared.accounts[strings.ToLower(name)]
+		if !exists {
+			return ErrSharedAccountNotFound
+		}
+		if !shared.isMember(username) {
+			return ErrNotAuthorized
+		}
+		
+		account := accounts[0]
+		if account.spendable() < amount {
+			return ErrInsufficientFunds
+		}
+		
+		account.Balance -= amount
+		account.TotalSpent += amount
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	
+	e.shared.mutex.Lock()
+	shared, exists := e.shared.accounts[strings.ToLower(name)]
+	if exists {
+		shared.Balance += amount
+		e.saveSharedAccounts()
+	}
+	e.shared.mutex.Unlock()
+	if !exists {
+		// The account went away between the check and the credit, for
+		// example through a backup restore, so the money goes back.
+		err = e.mutateAccounts([]string{username}, func(accounts []*PlayerAccount) error {
+			accounts[0].Balance += amount
+			accounts[0].TotalSpent = clampZero(accounts[0].TotalSpent - amount)
+			return nil
+		})
+		if err != nil {
+			e.logger.Error("Failed to return money for vanished shared account", "player", username, "account", name, "amount", amount.String(), "error", err)
+		}
+		return ErrSharedAccountNotFound
+	}
+	
+	e.invalidateTopPlayers()
+	e.recordTransaction(&Transaction{
+		From:      username,
+		To:        sharedAccountRef(name),
+		Amount:    amount,
+		Type:      SHARED_DEPOSIT,
+		Timestamp: e.now(),
+		Reason:    "Shared account deposit",
+	})
+	
+	return nil
+}
+
+// SharedWithdraw moves money from a shared account into a player's wallet,
+// enforcing the member's daily withdraw limit.
+func (e *EconomyPlugin) SharedWithdraw(name, username string, amount Money) error {
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	
+	shared, member, err := e.reserveSharedWithdraw(name, username, amount)
+	if err != nil {
+		return err
+	}
+	
+	err = e.mutateAccounts([]string{username}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if account.Balance+amount > e.config.MaxBalance {
+			return ErrMaxBalanceExceeded
+		}
+		
+		account.Balance += amount
+		account.TotalEarned += amount
+		return nil
+	})
+	
+	e.shared.mutex.Lock()
+	if err != nil {
+		shared.Balance += amount
+		if member != nil {
+			member.WithdrawnToday = clampZero(member.WithdrawnToday - amount)
+		}
+	} else {
+		e.saveSharedAccounts()
+	}
+	e.shared.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	
+	e.invalidateTopPlayers()
+	e.recordTransaction(&Transaction{
+		From:      sharedAccountRef(name),
+		To:        username,
+		Amount:    amount,
+		Type:      SHARED_WITHDRAW,
+		Timestamp: e.now(),
+		Reason:    "Shared account withdrawal",
+	})
+	
+	return nil
+}
+
+// reserveSharedWithdraw takes amount out of the shared account name for
+// username, counting it against their daily withdraw limit unless they
+// own it. It returns the account and, for a member, their entry, so that
+// a withdrawal that then fails can put the money back.
+func (e *EconomyPlugin) reserveSharedWithdraw(name, username string, amount Money) (*SharedAccount, *SharedMember, error) {
+	e.shared.mutex.Lock()
+	defer e.shared.mutex.Unlock()
+	
+	shared, exists := e.shared.accounts[strings.ToLower(name)]
+	if !exists {
+		return nil, nil, ErrSharedAccountNotFound
+	}
+	
+	member, isMember := shared.Members[strings.ToLower(username)]
+	isOwner := strings.EqualFold(shared.Owner, username)
+	if !isOwner && !isMember {
+		return nil, nil, ErrNotAuthorized
+	}
+	
+	if shared.Balance < amount {
+		return nil, nil, ErrInsufficientFunds
+	}
+	
+	if isOwner {
+		member = nil
+	} else {
+		today := e.now().Truncate(24 * time.Hour)
+		if !member.WithdrawDay.Equal(today) {
+			member.WithdrawDay = today
+			member.WithdrawnToday = 0
+		}
+		if member.WithdrawnToday+amount > member.DailyWithdrawLimit {
+			return nil, nil, ErrWithdrawLimitExceeded
+		}
+		member.WithdrawnToday += amount
+	}
+	
+	shared.Balance -= amount
+	return shared, member, nil
+}
+
+func (a *SharedAccount) isMember(username string) bool {
+	if strings.EqualFold(a.Owner, username) {
+		return true
+	}
+	
+	_, exists := a.Members[strings.ToLower(username)]
+	return exists
+}
+
+func (e *EconomyPlugin) accountCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) > 0 && strings.EqualFold(args[0], "link") {
+		return e.linkCommand(ctx.withArgs("link", args[1:]))
+	}
+	if len(args) < 2 {
+		return e.message("account.usage")
+	}
+	
+	player := ctx.Name()
+	action, name := strings.ToLower(args[0]), args[1]
+	
+	switch action {
+	case "create":
+		if err := e.CreateSharedAccount(name, player); err != nil {
+			return e.message("account.create_failed", "error", e.describeError(err))
+		}
+		return e.message("account.created", "name", name)
+		
+	case "invite":
+		if len(args) < 3 {
+			return e.message("account.invite_usage")
+		}
+		var limit Money
+		if len(args) > 3 {
+			parsed, err := e.parseAmount(args[3])
+			if err != nil {
+				return e.message("account.invalid_limit")
+			}
+			limit = parsed
+		}
+		if err := e.InviteSharedMember(name, player, args[2], limit); err != nil {
+			return e.message("account.invite_failed", "error", e.describeError(err))
+		}
+		return e.message("account.invited", "player", args[2], "name", name, "limit", e.FormatMoney(limit))
+		
+	case "deposit", "withdraw":
+		if len(args) < 3 {
+			return e.message("account.amount_usage", "action", action)
+		}
+		amount, err := e.parseAmount(args[2])
+		if err != nil {
+			return e.message("error.invalid_amount")
+		}
+		
+		if action == "deposit" {
+			if err := e.SharedDeposit(name, player, amount); err != nil {
+				return e.message("account.deposit_failed", "error", e.describeError(err))
+			}
+			return e.message("account.deposit", "amount", e.FormatMoney(amount), "name", name)
+		}
+		
+		if err := e.SharedWithdraw(name, player, amount); err != nil {
+			return e.message("account.withdraw_failed", "error", e.describeError(err))
+		}
+		return e.message("account.withdraw", "amount", e.FormatMoney(amount), "name", name)
+		
+	case "log":
+		if _, err := e.GetSharedAccount(name); err != nil {
+			return e.describeError(err)
+		}
+		logArgs := []string{sharedAccountRef(name)}
+		if len(args) > 2 {
+			logArgs = append(logArgs, args[2])
+		}
+		return e.transactionsCommand(ctx.withArgs("transactions", logArgs))
+		
+	case "info":
+		account, err := e.GetSharedAccount(name)
+		if err != nil {
+			return e.describeError(err)
+		}
+		result := e.message("account.info", "name", account.Name, "owner", account.Owner,
+			"amount", e.FormatMoney(account.Balance)) + "\n"
+		for _, member := range account.Members {
+			result += e.message("account.info_member", "player", member.Username,
+				"limit", e.FormatMoney(member.DailyWithdrawLimit)) + "\n"
+		}
+		return result
+		
+	default:
+		return e.message("account.invalid")
+	}
+}
@@ -0,0 +1,88 @@
+package economy
+
+import "strings"
+
+// maxReferenceLength keeps references quoted in transaction reasons
+// within the SQL ledgers' reason column.
+const maxReferenceLength = 200
+
+func clipReference(text string) string {
+	if len(text) <= maxReferenceLength {
+		return text
+	}
+	return strings.ToValidUTF8(text[:maxReferenceLength], "")
+}
+
+// ChargeForPurchase moves amount from buyer to seller in one step and
+// records it as a PURCHASE of itemRef, so a shop never takes the money
+// without paying the owner. An empty seller buys from the server, which
+// destroys the money. It returns the ID of the PURCHASE, which Refund
+// takes.
+func (e *EconomyPlugin) ChargeForPurchase(buyer, seller string, amount Money, itemRef string) (string, error) {
+	amount = e.roundAmount(amount)
+	if amount <= 0 {
+		return "", ErrInvalidAmount
+	}
+	if strings.EqualFold(buyer, seller) {
+		return "", ErrSelfTransfer
+	}
+	if !e.hasAccount(buyer) {
+		return "", ErrAccountNotFound
+	}
+	itemRef = clipReference(itemRef)
+	
+	ev := PurchaseEvent{Buyer: buyer, Seller: seller, Amount: amount, Item: itemRef}
+	if e.firePurchase(ev) {
+		return "", ErrPurchaseCancelled
+	}
+	
+	usernames := []string{buyer}
+	if seller != "" {
+		usernames = append(usernames, seller)
+	}
+	
+	if err := e.chargeSpend(buyer, amount); err != nil {
+		return "", err
+	}
+	
+	var buyerOld, sellerOld Money
+	err := e.mutateAccounts(usernames, func(accounts []*PlayerAccount) error {
+		if accounts[0].spendable() < amount {
+			return ErrInsufficientFunds
+		}
+		if len(accounts) > 1 && accounts[1].Balance+amount > e.config.MaxBalance {
+			return ErrMaxBalanceExceeded
+		}
+		buyerOld = accounts[0].Balance
+		accounts[0].Balance -= amount
+		accounts[0].TotalSpent += amount
+		if len(accounts) > 1 {
+			sellerOld = accounts[1].Balance
+			accounts[1].Balance += amount
+			accounts[1].TotalEarned += amount
+		}
+		return nil
+	})
+	if err != nil {
+		e.refundSpend(buyer, amount)
+		return "", err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(buyer, buyerOld, buyerOld-amount, PURCHASE)
+	if seller != "" {
+		e.fireBalanceChange(seller, sellerOld, sellerOld+amount, PURCHASE)
+	}
+	
+	id := e.recordTransaction(&Transaction{
+		From:      buyer,
+		To:        seller,
+		Amount:    amount,
+		Type:      PURCHASE,
+		Timestamp: e.now(),
+		Reason:    "Purchase: " + itemRef,
+	})
+	e.firePurchaseCompleted(ev)
+	
+	return id, nil
+}
@@ -0,0 +1,112 @@
+package economy
+
+import (
+	"sync"
+	"time"
+)
+
+// shutdownTimeout bounds how long Disable waits for running commands.
+const shutdownTimeout = 10 * time.Second
+
+// shutdownGate lets Disable finish the work already running before the
+// final save. Commands enter it and are turned away once it drains. Every
+// mutation holds mutations for reading, so closing waits for the ones in
+// flight and refuses any that arrive later, which would miss the save.
+type shutdownGate struct {
+	mutex    sync.Mutex
+	draining bool
+	running  *sync.WaitGroup
+	
+	mutations sync.RWMutex
+	closed    bool
+}
+
+// enter admits a command, returning the function to call when it is done,
+// or false once draining has begun.
+func (g *shutdownGate) enter() (func(), bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	
+	if g.draining {
+		return nil, false
+	}
+	if g.running == nil {
+		g.running = &sync.WaitGroup{}
+	}
+	running := g.running
+	running.Add(1)
+	
+	return running.Done, true
+}
+
+// drain turns new commands away and waits up to timeout for the running
+// ones, reporting whether they all finished.
+func (g *shutdownGate) drain(timeout time.Duration) bool {
+	g.mutex.Lock()
+	g.draining = true
+	running := g.running
+	g.mutex.Unlock()
+	
+	if running == nil {
+		return true
+	}
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+	
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (g *shutdownGate) beginMutation() bool {
+	g.mutations.RLock()
+	if g.closed {
+		g.mutations.RUnlock()
+		return false
+	}
+	return true
+}
+
+func (g *shutdownGate) endMutation() {
+	g.mutations.RUnlock()
+}
+
+// closeMutations waits for mutations in flight and refuses later ones.
+func (g *shutdownGate) closeMutations() {
+	g.mutations.Lock()
+	g.closed = true
+	g.mutations.Unlock()
+}
+
+// pauseMutations waits for mutations in flight and holds later ones until
+// the returned function is called. Called with close set, it refuses them
+// from then on, as closeMutations does.
+func (g *shutdownGate) pauseMutations() func(close bool) {
+	g.mutations.Lock()
+	return func(close bool) {
+		if close {
+			g.closed = true
+		}
+		g.mutations.Unlock()
+	}
+}
+
+// open admits commands and mutations again, for a plugin enabled after
+// being disabled. Commands that outlived the last drain keep their own
+// WaitGroup.
+func (g *shutdownGate) open() {
+	g.mutex.Lock()
+	g.draining = false
+	g.running = nil
+	g.mutex.Unlock()
+	
+	g.mutations.Lock()
+	g.closed = false
+	g.mutations.Unlock()
+}
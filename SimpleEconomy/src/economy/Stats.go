@@ -0,0 +1,293 @@
+package economy
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statsWindow is how far back /eco stats looks for money flows.
+const statsWindow = 24 * time.Hour
+
+// EconomyStats describes how wallet money is spread across players and
+// how it moved during the last statsWindow.
+type EconomyStats struct {
+	Players int
+	Total   Money
+	Average Money
+	Median  Money
+	
+	// Gini is 0 when every player holds the same and approaches 1 as one
+	// player holds everything.
+	Gini float64
+	
+	// Top1, Top10 and Bottom50 are the shares of all money held by the
+	// richest 1% and 10% of players and the poorest half.
+	Top1     float64
+	Top10    float64
+	Bottom50 float64
+	
+	Created   Money
+	Destroyed Money
+	
+	TopEarner  string
+	Earned     Money
+	TopSpender string
+	Spent      Money
+}
+
+// Stats works out the current wealth distribution and the last day's
+// money flows from the ledger. Hidden accounts are left out of both, though
+// money they create or destroy still counts.
+func (e *EconomyPlugin) Stats() EconomyStats {
+	accounts := e.snapshotAccounts()
+	balances := make([]Money, 0, len(accounts))
+	hidden := make(map[string]bool)
+	for _, account := range accounts {
+		if e.hiddenAccount(&account) {
+			hidden[strings.ToLower(account.Username)] = true
+		} else if !account.virtual() {
+			balances = append(balances, account.Balance)
+		}
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i] < balances[j] })
+	
+	stats := EconomyStats{Players: len(balances)}
+	var weighted float64
+	for i, balance := range balances {
+		stats.Total += balance
+		weighted += float64(i+1) * balance.Float64()
+	}
+	
+	if n := len(balances); n > 0 {
+		stats.Average = stats.Total / Money(n)
+		stats.Median = balances[n/2]
+		if n%2 == 0 {
+			stats.Median = (balances[n/2-1] + balances[n/2]) / 2
+		}
+		
+		if total := stats.Total.Float64(); total > 0 {
+			stats.Gini = 2*weighted/(float64(n)*total) - float64(n+1)/float64(n)
+			stats.Top1 = wealthShare(balances, 0.01, true, total)
+			stats.Top10 = wealthShare(balances, 0.10, true, total)
+			stats.Bottom50 = wealthShare(balances, 0.50, false, total)
+		}
+	}
+	
+	flows := newPlayerFlows()
+	for _, transaction := range e.GetTransactions("", TransactionFilter{Since: e.now().Add(-statsWindow)}) {
+		if created := moneyCreated(&transaction); created > 0 {
+			stats.Created += created
+		} else {
+			stats.Destroyed -= created
+		}
+		flows.add(&transaction)
+	}
+	for name := range hidden {
+		delete(flows.earned, name)
+		delete(flows.spent, name)
+	}
+	var earner, spender string
+	earner, stats.Earned = largest(flows.earned)
+	spender, stats.Spent = largest(flows.spent)
+	stats.TopEarner, stats.TopSpender = flows.names[earner], flows.names[spender]
+	
+	return stats
+}
+
+// playerFlows adds up what players earned and spent across transactions,
+// keyed by lower-cased name. names keeps the spelling first seen.
+type playerFlows struct {
+	earned map[string]Money
+	spent  map[string]Money
+	names  map[string]string
+}
+
+func newPlayerFlows() *playerFlows {
+	return &playerFlows{
+		earned: make(map[string]Money),
+		spent:  make(map[string]Money),
+		names:  make(map[string]string),
+	}
+}
+
+func (f *playerFlows) record(totals map[string]Money, player string, amount Money) {
+	key := strings.ToLower(player)
+	if _, exists := f.names[key]; !exists {
+		f.names[key] = player
+	}
+	totals[key] += amount
+}
+
+func (f *playerFlows) add(transaction *Transaction) {
+	// SETs, rollbacks and merges correct balances and openings record
+	// them rather than earn or spend, and a bank move has the same player
+	// on both sides.
+	if transaction.Type == SET || transaction.Type == ROLLBACK || transaction.Type == MERGE || transaction.Type == OPENING || isPlayerName(transaction.From) && transaction.From == transaction.To {
+		return
+	}
+	if transaction.Type == CONFISCATE {
+		// The batch only has wallets; the banned player lost Amount,
+		// bank included.
+		f.record(f.spent, transaction.From, transaction.Amount)
+		for _, entry := range transaction.Batch {
+			if entry.Amount > 0 && isPlayerName(entry.Player) {
+				f.record(f.earned, entry.Player, entry.Amount)
+			}
+		}
+		return
+	}
+	if isPlayerName(transaction.To) {
+		f.record(f.earned, transaction.To, transaction.Amount)
+	}
+	if isPlayerName(transaction.From) {
+		f.record(f.spent, transaction.From, transaction.Amount)
+	}
+	for _, entry := range transaction.Batch {
+		if entry.Amount > 0 {
+			f.record(f.earned, entry.Player, entry.Amount)
+		} else {
+			f.record(f.spent, entry.Player, -entry.Amount)
+		}
+	}
+}
+
+// wealthShare returns the share of total held by the given fraction of
+// players, at least one, from the top or bottom of sorted balances.
+func wealthShare(balances []Money, fraction float64, top bool, total float64) float64 {
+	count := int(math.Ceil(float64(len(balances)) * fraction))
+	held := balances[:count]
+	if top {
+		held = balances[len(balances)-count:]
+	}
+	
+	var sum Money
+	for _, balance := range held {
+		sum += balance
+	}
+	return sum.Float64() / total
+}
+
+// moneyCreated returns how much a transaction added to the money supply,
+// negative when it removed money. Moves between players, banks, shared
+// accounts, escrows and vouchers change nothing.
+func moneyCreated(transaction *Transaction) Money {
+	switch transaction.Type {
+	case SET, ROLLBACK:
+		if transaction.Previous != nil {
+			return transaction.Amount - *transaction.Previous
+		}
+	case BATCH:
+		var net Money
+		for _, entry := range transaction.Batch {
+			net += entry.Amount
+		}
+		return net
+	case CONFISCATE:
+		if transaction.From == transaction.To {
+			return 0
+		}
+		net := -transaction.Amount
+		for _, entry := range transaction.Batch {
+			if entry.Amount > 0 {
+				net += entry.Amount
+			}
+		}
+		return net
+	}
+	
+	switch {
+	case transaction.From == "" && transaction.To != "":
+		return transaction.Amount
+	case transaction.To == "" && transaction.From != "":
+		return -transaction.Amount
+	default:
+		return 0
+	}
+}
+
+// isPlayerName reports whether a ledger name is a player rather than a
+// virtual account, shared account, escrow or voucher reference.
+func isPlayerName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, "@") && !strings.Contains(name, "#")
+}
+
+func largest(totals map[string]Money) (string, Money) {
+	name, amount := "", Money(0)
+	for candidate, total := range totals {
+		if total > amount || (total == amount && candidate < name) {
+			name, amount = candidate, total
+		}
+	}
+	return name, amount
+}
+
+func (e *EconomyPlugin) playerStatsCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) > 1 || len(args) == 0 && ctx.IsConsole() {
+		return e.message("stats.usage")
+	}
+	
+	username := ctx.Name()
+	if len(args) > 0 {
+		username = args[0]
+	}
+	if !strings.EqualFold(username, ctx.Name()) && !e.hasPermission(ctx.Sender, "economy.command.stats.others") {
+		return e.message("command.no_permission")
+	}
+	
+	account, exists := e.lookupAccount(username)
+	if !exists {
+		return e.message("stats.failed", "error", e.describeError(ErrAccountNotFound))
+	}
+	snapshot := e.snapshotAccount(account)
+	
+	result := e.message("stats.header", "player", snapshot.Username)
+	for _, category := range leaderboardCategories {
+		rank, err := e.GetRank(username, category)
+		if err != nil {
+			return e.message("stats.failed", "error", e.describeError(err))
+		}
+		place := e.message("stats.unranked")
+		if rank > 0 {
+			place = e.message("stats.rank", "rank", strconv.Itoa(rank))
+		}
+		result += "\n" + e.message("stats."+string(category), "amount", e.FormatMoney(category.value(&snapshot)), "rank", place)
+	}
+	result += "\n" + e.message("stats.net", "amount", e.FormatMoney(snapshot.TotalEarned-snapshot.TotalSpent))
+	
+	return result
+}
+
+func (e *EconomyPlugin) statsReport() string {
+	stats := e.Stats()
+	percent := func(share float64) string {
+		return strconv.FormatFloat(share*100, 'f', 1, 64) + "%"
+	}
+	
+	report := e.message("economy.stats", "players", strconv.Itoa(stats.Players),
+		"total", e.FormatMoney(stats.Total), "average", e.FormatMoney(stats.Average))
+	if stats.Players == 0 {
+		return report
+	}
+	
+	report += "\n" + e.message("economy.stats_distribution", "median", e.FormatMoney(stats.Median),
+		"gini", strconv.FormatFloat(stats.Gini, 'f', 3, 64), "top1", percent(stats.Top1),
+		"top10", percent(stats.Top10), "bottom50", percent(stats.Bottom50))
+	report += "\n" + e.message("economy.stats_flow", "created", e.FormatMoney(stats.Created),
+		"destroyed", e.FormatMoney(stats.Destroyed))
+	if supply := e.CurrentSupply(); supply.Cap > 0 {
+		report += "\n" + e.message("economy.stats_supply", "supply", e.FormatMoney(supply.Supply),
+			"cap", e.FormatMoney(supply.Cap), "remaining", e.FormatMoney(supply.Remaining()))
+	}
+	if stats.TopEarner != "" {
+		report += "\n" + e.message("economy.stats_earner", "player", stats.TopEarner, "amount", e.FormatMoney(stats.Earned))
+	}
+	if stats.TopSpender != "" {
+		report += "\n" + e.message("economy.stats_spender", "player", stats.TopSpender, "amount", e.FormatMoney(stats.Spent))
+	}
+	
+	return report
+}
@@ -0,0 +1,173 @@
+package economy
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Storage persists player accounts keyed by UUID. PutAccount and
+// DeleteAccount stage a change and Save makes every staged change durable.
+type Storage interface {
+	Load() error
+	Save() error
+	GetAccount(uuid string) (*PlayerAccount, error)
+	PutAccount(account *PlayerAccount) error
+	DeleteAccount(uuid string) error
+	ListAccounts() ([]*PlayerAccount, error)
+	Close() error
+}
+
+// AtomicStorage is implemented by backends that several servers share.
+// UpdateAccounts locks the rows for seeds (inserting any that are missing),
+// hands fn the stored state in the same order, and commits fn's changes
+// only if it returns nil.
+type AtomicStorage interface {
+	Storage
+	UpdateAccounts(seeds []PlayerAccount, fn func(accounts []*PlayerAccount) error) error
+}
+
+// LazyStorage is implemented by backends that can find an account by name
+// without listing them all. With one the plugin can load accounts on first
+// use instead of at startup, see AccountCacheConfig. FindAccount returns
+// the UUID of the most recently seen account stored under username, or ""
+// if there is none; CountAccounts counts the saved accounts.
+type LazyStorage interface {
+	Storage
+	FindAccount(username string) (string, error)
+	CountAccounts() (int, error)
+}
+
+// ChangeNotifier is implemented by shared backends that can tell a server
+// when another one changed an account. WatchChanges calls fn with the
+// account's UUID after each change, or with "" when changes may have been
+// missed. While it watches, accounts are refreshed as changes arrive
+// instead of on every access.
+type ChangeNotifier interface {
+	AtomicStorage
+	WatchChanges(fn func(uuid string)) error
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// migrateAccountKeys rekeys accounts rows written before accounts had
+// UUIDs, when the username column held the lowercased name.
+func migrateAccountKeys(db *sql.DB) error {
+	rows, err := db.Query(`SELECT username, display_name FROM accounts`)
+	if err != nil {
+		return err
+	}
+	
+	legacy := make(map[string]string)
+	for rows.Next() {
+		var key, displayName string
+		if err := rows.Scan(&key, &displayName); err != nil {
+			rows.Close()
+			return err
+		}
+		if !isUUID(key) {
+			legacy[key] = OfflineUUID(displayName)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	
+	if len(legacy) == 0 {
+		return nil
+	}
+	
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for key, uuid := range legacy {
+		if _, err := tx.Exec(`UPDATE accounts SET username = ? WHERE username = ?`, uuid, key); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	
+	slog.Info("Migrated name-keyed accounts to UUIDs", "accounts", len(legacy))
+	return nil
+}
+
+// writeFileAtomic replaces path with data through a synced temporary file
+// in the same directory, so a crash leaves either the old or the new
+// content and never a torn mix of both.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+	
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := os.Chmod(tempPath, perm); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	
+	// Sync the directory so the rename itself survives a power loss.
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	
+	return nil
+}
+
+func newStorage(config *Config, dataFolder string) (Storage, error) {
+	switch strings.ToLower(config.StorageBackend) {
+	case "", "json":
+		return NewJSONStorage(filepath.Join(dataFolder, "players.json")), nil
+		
+	case "files":
+		return NewFileStorage(filepath.Join(dataFolder, "players")), nil
+		
+	case "sqlite":
+		return NewSQLiteStorage(filepath.Join(dataFolder, "players.db"))
+		
+	case "bolt":
+		return NewBoltStorage(filepath.Join(dataFolder, "players.bolt"), config.Bolt), nil
+		
+	case "mysql":
+		return NewMySQLStorage(config.MySQL)
+		
+	case "postgres":
+		return NewPostgresStorage(config.Postgres)
+		
+	case "mongodb":
+		return NewMongoStorage(config.Mongo)
+		
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", config.StorageBackend)
+	}
+}
@@ -0,0 +1,44 @@
+package economy_test
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+)
+
+// serverURL returns the server address in the environment variable name,
+// skipping t when it is unset. The database server backends are only
+// tested against a server someone has pointed them at.
+func serverURL(t *testing.T, name string) *url.URL {
+	value := os.Getenv(name)
+	if value == "" {
+		t.Skip(name + " is not set")
+	}
+	server, err := url.Parse(value)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return server
+}
+
+// serverPort returns server's port, or fallback when it names none.
+func serverPort(server *url.URL, fallback int) int {
+	port, err := strconv.Atoi(server.Port())
+	if err != nil {
+		return fallback
+	}
+	return port
+}
+
+// testDatabase returns a database name no other test uses, so tests
+// sharing one server start empty.
+func testDatabase(t *testing.T) string {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	return "economy_test_" + hex.EncodeToString(suffix)
+}
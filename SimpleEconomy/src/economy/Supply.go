@@ -0,0 +1,128 @@
+package economy
+
+import (
+	"sync"
+	"time"
+)
+
+// SupplyCapConfig caps the money in circulation, wallets and banks
+// together. Admin gives, interest and job earnings that would take the
+// supply past Max are rejected, or with Scale cut down to what still
+// fits. A zero Max is no cap.
+type SupplyCapConfig struct {
+	Max   Money `json:"max"`
+	Scale bool  `json:"scale"`
+}
+
+// SupplyStatus is the money in circulation and the cap on it, which is
+// zero when there is none.
+type SupplyStatus struct {
+	Supply Money
+	Cap    Money
+}
+
+// Remaining is how much more may be created under the cap.
+func (s SupplyStatus) Remaining() Money {
+	return clampZero(s.Cap - s.Supply)
+}
+
+const (
+	// supplyRefreshInterval is how long a counted supply is trusted before
+	// the accounts are summed again. Money minted in between is added to
+	// it, so the cap holds between counts on a single server.
+	supplyRefreshInterval = 5 * time.Second
+	
+	supplyWarningInterval = 10 * time.Minute
+)
+
+type supplyTracker struct {
+	mutex   sync.Mutex
+	total   Money
+	counted time.Time
+	warned  time.Time
+}
+
+// circulatingSupply sums every wallet and bank balance.
+func (e *EconomyPlugin) circulatingSupply() Money {
+	var total Money
+	for _, account := range e.snapshotAccounts() {
+		total += account.Balance + account.BankBalance
+	}
+	return total
+}
+
+// CurrentSupply counts the money in circulation now.
+func (e *EconomyPlugin) CurrentSupply() SupplyStatus {
+	total := e.circulatingSupply()
+	
+	e.supply.mutex.Lock()
+	e.supply.total, e.supply.counted = total, e.now()
+	e.supply.mutex.Unlock()
+	
+	return SupplyStatus{Supply: total, Cap: e.config.SupplyCap.Max}
+}
+
+// allowMint returns how much of amount, about to be created for player
+// by what, the supply cap lets through. It is amount when that fits, the
+// part that fits when supply_cap.scale is set, or ErrSupplyCapReached.
+// Callers must not hold account locks.
+func (e *EconomyPlugin) allowMint(player, what string, amount Money) (Money, error) {
+	limit := e.config.SupplyCap.Max
+	if limit <= 0 || amount <= 0 {
+		return amount, nil
+	}
+	
+	now := e.now()
+	e.supply.mutex.Lock()
+	if now.Sub(e.supply.counted) >= supplyRefreshInterval {
+		e.supply.total, e.supply.counted = e.circulatingSupply(), now
+	}
+	allowed, warn := amount, false
+	if remaining := clampZero(limit - e.supply.total); amount > remaining {
+		allowed = 0
+		if e.config.SupplyCap.Scale {
+			allowed = remaining.Truncate(e.decimalPlaces())
+		}
+		if warn = now.Sub(e.supply.warned) >= supplyWarningInterval; warn {
+			e.supply.warned = now
+		}
+	}
+	if allowed > 0 {
+		e.supply.total += allowed
+	}
+	e.supply.mutex.Unlock()
+	
+	if warn {
+		e.warnSupplyCap(now, player, what, amount, allowed)
+	}
+	if allowed <= 0 {
+		return 0, ErrSupplyCapReached
+	}
+	return allowed, nil
+}
+
+// warnSupplyCap raises an alert for admins that the cap cut or blocked a
+// payout. allowMint raises one at most every supplyWarningInterval.
+func (e *EconomyPlugin) warnSupplyCap(now time.Time, player, what string, amount, allowed Money) {
+	detail := e.message("supply.blocked", "what", what, "player", player, "amount", e.FormatMoney(amount),
+		"cap", e.FormatMoney(e.config.SupplyCap.Max))
+	if allowed > 0 {
+		detail = e.message("supply.scaled", "what", what, "player", player, "amount", e.FormatMoney(amount),
+			"allowed", e.FormatMoney(allowed), "cap", e.FormatMoney(e.config.SupplyCap.Max))
+	}
+	e.raiseAlert(FraudAlert{Kind: AlertSupplyCap, Players: []string{player}, Detail: detail, Time: now})
+}
+
+// grant gives player amount as an admin, as far as the supply cap allows,
+// and returns what was given and the transaction ID.
+func (e *EconomyPlugin) grant(player string, amount Money, reason string) (Money, string, error) {
+	allowed, err := e.allowMint(player, "admin give", amount)
+	if err != nil {
+		return 0, "", err
+	}
+	id, err := e.addMoney(player, allowed, reason)
+	if err != nil {
+		return 0, "", err
+	}
+	return allowed, id, nil
+}
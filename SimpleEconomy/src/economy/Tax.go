@@ -0,0 +1,207 @@
+package economy
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WealthTaxConfig collects Percent of every account's wealth, wallet and
+// bank together, above Threshold on Schedule, a cron expression. The tax
+// goes to Recipient, or is destroyed when it is empty. Players in any of
+// ExemptGroups, as the group resolver reports them, pay nothing. Online
+// players who will be taxed are warned WarningHours beforehand.
+type WealthTaxConfig struct {
+	Enabled      bool     `json:"enabled"`
+	Schedule     string   `json:"schedule"`
+	Threshold    Money    `json:"threshold"`
+	Percent      float64  `json:"percent"`
+	Recipient    string   `json:"recipient"`
+	ExemptGroups []string `json:"exempt_groups"`
+	WarningHours int      `json:"warning_hours"`
+}
+
+func (e *EconomyPlugin) startWealthTax() {
+	config := e.config.WealthTax
+	if !config.Enabled || config.Percent <= 0 {
+		return
+	}
+	
+	schedule, err := parseCronSchedule(config.Schedule)
+	if err != nil {
+		e.logger.Warn("Wealth tax disabled", "error", err)
+		return
+	}
+	
+	e.runOnSchedule(schedule, e.collectWealthTax)
+	
+	if config.WarningHours > 0 {
+		warning := time.Duration(config.WarningHours) * time.Hour
+		var last time.Time
+		e.runPeriodically(30*time.Second, func() {
+			due := e.now().Truncate(time.Minute).Add(warning)
+			if due.Equal(last) || !schedule.matches(due) {
+				return
+			}
+			last = due
+			e.warnWealthTax(due)
+		})
+	}
+}
+
+// wealthTax returns what an account owes: Percent of its wallet and bank
+// balances above the threshold.
+func (e *EconomyPlugin) wealthTax(account *PlayerAccount) Money {
+	taxable := account.Balance + account.BankBalance - e.config.WealthTax.Threshold
+	if taxable <= 0 {
+		return 0
+	}
+	return e.roundAmount(taxable.MulRate(e.config.WealthTax.Percent / 100))
+}
+
+// taxExempt reports whether an account pays no wealth tax: virtual
+// accounts, the tax's own recipient and players in an exempt group.
+func (e *EconomyPlugin) taxExempt(account *PlayerAccount) bool {
+	config := e.config.WealthTax
+	if account.virtual() || strings.EqualFold(account.Username, config.Recipient) {
+		return true
+	}
+	return e.inAnyGroup(account.UUID, account.Username, config.ExemptGroups)
+}
+
+// warnWealthTax tells online players about the tax they will pay at due,
+// going by their balances now.
+func (e *EconomyPlugin) warnWealthTax(due time.Time) {
+	messenger := e.getMessenger()
+	if messenger == nil {
+		return
+	}
+	online, ok := e.getOnlinePlayers()
+	if !ok {
+		e.logger.Warn("wealth_tax.warning_hours is set but no online player provider is registered")
+		return
+	}
+	
+	for _, username := range online {
+		account, exists := e.lookupAccount(username)
+		if !exists {
+			continue
+		}
+		snapshot := e.snapshotAccount(account)
+		if e.taxExempt(&snapshot) {
+			continue
+		}
+		if tax := e.wealthTax(&snapshot); tax > 0 {
+			messenger.SendMessage(username, e.message("tax.warning", "amount", e.FormatMoney(tax),
+				"time", due.Format("2006-01-02 15:04"), "threshold", e.FormatMoney(e.config.WealthTax.Threshold)))
+		}
+	}
+}
+
+// collectWealthTax taxes every account above the threshold.
+func (e *EconomyPlugin) collectWealthTax() {
+	taxed, total := 0, Money(0)
+	for _, account := range e.snapshotAccounts() {
+		if e.taxExempt(&account) || e.wealthTax(&account) <= 0 {
+			continue
+		}
+		
+		collected, err := e.taxAccount(account.Username)
+		if err != nil {
+			e.logger.Warn("Failed to collect wealth tax", "player", account.Username, "error", err)
+			continue
+		}
+		if collected > 0 {
+			taxed++
+			total += collected
+		}
+	}
+	
+	if taxed > 0 {
+		e.logger.Info("Collected wealth tax", "amount", total.String(), "accounts", taxed)
+	}
+}
+
+// taxAccount collects one account's wealth tax, worked out again under
+// its lock. It comes out of the wallet first, leaving held money alone,
+// and then the bank; whatever neither covers is let off.
+func (e *EconomyPlugin) taxAccount(username string) (Money, error) {
+	usernames := []string{username}
+	recipient := e.config.WealthTax.Recipient
+	if recipient != "" {
+		usernames = append(usernames, recipient)
+	}
+	
+	var walletOld, recipientOld, tax, fromWallet, received Money
+	err := e.mutateAccounts(usernames, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		tax = e.wealthTax(account)
+		
+		fromWallet = tax
+		if spendable := account.spendable(); fromWallet > spendable {
+			fromWallet = spendable
+		}
+		if fromWallet < 0 {
+			fromWallet = 0
+		}
+		fromBank := tax - fromWallet
+		if fromBank > account.BankBalance {
+			fromBank = account.BankBalance
+		}
+		tax = fromWallet + fromBank
+		if tax <= 0 {
+			return nil
+		}
+		
+		walletOld = account.Balance
+		account.Balance -= fromWallet
+		account.BankBalance -= fromBank
+		account.TotalSpent += tax
+		
+		if len(accounts) > 1 {
+			collector := accounts[1]
+			recipientOld = collector.Balance
+			received = tax
+			if room := e.config.MaxBalance - collector.Balance; room < received {
+				received = room
+			}
+			if received < 0 {
+				received = 0
+			}
+			collector.Balance += received
+			collector.TotalEarned += received
+		}
+		return nil
+	})
+	if err != nil || tax <= 0 {
+		return 0, err
+	}
+	
+	e.invalidateTopPlayers()
+	if fromWallet > 0 {
+		e.fireBalanceChange(username, walletOld, walletOld-fromWallet, TAX)
+	}
+	if received > 0 {
+		e.fireBalanceChange(recipient, recipientOld, recipientOld+received, TAX)
+	}
+	
+	transaction := &Transaction{
+		From:      username,
+		Amount:    tax,
+		Type:      TAX,
+		Code:      ReasonTax,
+		Timestamp: e.now(),
+		Reason:    "Wealth tax (" + strconv.FormatFloat(e.config.WealthTax.Percent, 'f', -1, 64) + "% above " + e.FormatMoney(e.config.WealthTax.Threshold) + ")",
+	}
+	if received > 0 {
+		transaction.To = recipient
+	}
+	transaction.Postings = e.postings(
+		Posting{Account: username, Amount: -fromWallet},
+		Posting{Account: bankRef(username), Amount: fromWallet - tax},
+		Posting{Account: recipient, Amount: received},
+	)
+	e.recordTransaction(transaction)
+	
+	return tax, nil
+}
@@ -0,0 +1,35 @@
+package economy
+
+import (
+	"crypto/md5"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// OfflineUUID returns the name-based UUID an offline-mode server assigns to
+// username. Accounts created before their owner's real UUID is known use it
+// as a placeholder until OnPlayerJoin adopts them.
+func OfflineUUID(username string) string {
+	sum := md5.Sum([]byte("OfflinePlayer:" + username))
+	sum[6] = sum[6]&0x0f | 0x30
+	sum[8] = sum[8]&0x3f | 0x80
+	
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func isUUID(value string) bool {
+	return uuidPattern.MatchString(value)
+}
+
+func normalizeUUID(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// isPlaceholder reports whether account is still keyed by the offline UUID
+// derived from its own name.
+func isPlaceholder(account *PlayerAccount) bool {
+	return account.UUID == OfflineUUID(account.Username)
+}
@@ -0,0 +1,218 @@
+package economy
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DataIssue is one problem VerifyData found with a stored account.
+// Repaired is set when VerifyData was asked to fix it and did.
+type DataIssue struct {
+	UUID     string `json:"uuid"`
+	Player   string `json:"player"`
+	Problem  string `json:"problem"`
+	Repaired bool   `json:"repaired"`
+}
+
+// VerifyData checks every stored account for impossible balances, bad
+// UUIDs, names held by more than one account and wallets that disagree
+// with the ledger. It reads the storage directly, since loading keeps only
+// one account per name.
+//
+// With repair set, unrepresentable and negative amounts are zeroed,
+// balances above max_balance are clamped and accounts sharing a name are
+// merged into the one most recently seen. Ledger mismatches are only
+// reported: the ledger may not reach back to when an account was created.
+func (e *EconomyPlugin) VerifyData(repair bool) ([]DataIssue, error) {
+	accounts, err := e.storage.ListAccounts()
+	if err != nil {
+		return nil, err
+	}
+	
+	issues := make([]DataIssue, 0)
+	report := func(account *PlayerAccount, problem string, repaired bool) {
+		issues = append(issues, DataIssue{UUID: account.UUID, Player: account.Username, Problem: problem, Repaired: repaired})
+	}
+	
+	byName := make(map[string][]*PlayerAccount)
+	for _, account := range accounts {
+		if !isUUID(account.UUID) {
+			report(account, "invalid uuid", false)
+		}
+		if account.Username == "" {
+			report(account, "missing username", false)
+		} else {
+			key := strings.ToLower(account.Username)
+			byName[key] = append(byName[key], account)
+		}
+		
+		if problems := accountProblems(account, e.minBalance(), e.config.MaxBalance); len(problems) > 0 {
+			repaired := repair && e.repairAccount(account.UUID)
+			for _, problem := range problems {
+				report(account, problem, repaired)
+			}
+		}
+	}
+	
+	for key, holders := range byName {
+		if len(holders) < 2 {
+			continue
+		}
+		kept, _ := e.lookupAccount(key)
+		for _, account := range holders {
+			repaired := false
+			if repair && kept != nil && account.UUID != e.accountKey(kept) {
+				if err := e.MergeAccounts(account.UUID, e.accountKey(kept)); err != nil {
+					e.logger.Warn("Failed to merge duplicate account", "player", account.Username, "uuid", account.UUID, "error", err)
+				} else {
+					repaired = true
+				}
+			}
+			report(account, "name shared by "+strconv.Itoa(len(holders))+" accounts", repaired)
+		}
+	}
+	
+	// Repairs have changed the loaded accounts and the ledger by now, so
+	// this compares against those rather than what was stored.
+	ledger := e.ledgerBalances()
+	for _, account := range e.snapshotAccounts() {
+		expected, known := ledger[strings.ToLower(account.Username)]
+		if !known || len(byName[strings.ToLower(account.Username)]) > 1 {
+			continue
+		}
+		if expected != account.Balance && account.Balance != invalidMoney {
+			report(&account, "balance "+account.Balance.String()+" but ledger implies "+expected.String(), false)
+		}
+	}
+	
+	sort.SliceStable(issues, func(i, j int) bool {
+		return strings.ToLower(issues[i].Player) < strings.ToLower(issues[j].Player)
+	})
+	
+	return issues, nil
+}
+
+// accountProblems lists the amounts on account that no operation could
+// have produced.
+func accountProblems(account *PlayerAccount, minBalance, maxBalance Money) []string {
+	var problems []string
+	amounts := []struct {
+		name  string
+		value Money
+		floor Money
+	}{
+		{"balance", account.Balance, minBalance},
+		{"bank balance", account.BankBalance, 0},
+		{"total earned", account.TotalEarned, 0},
+		{"total spent", account.TotalSpent, 0},
+	}
+	for _, amount := range amounts {
+		switch {
+		case amount.value == invalidMoney:
+			problems = append(problems, amount.name+" is not a number")
+		case amount.value < amount.floor:
+			problems = append(problems, "negative "+amount.name+" "+amount.value.String())
+		}
+	}
+	if account.Balance > maxBalance {
+		problems = append(problems, "balance "+account.Balance.String()+" above max_balance")
+	}
+	
+	return problems
+}
+
+// repairAccount zeroes invalid and negative amounts on the account with
+// uuid, keeping any overdraft min_balance allows, and clamps its balance
+// to max_balance, recording any balance change
+// as a SET.
+func (e *EconomyPlugin) repairAccount(uuid string) bool {
+	account, exists := e.loadAccount(uuid)
+	if !exists {
+		return false
+	}
+	
+	var oldBalance, newBalance Money
+	var username string
+	err := e.mutateLoaded([]*PlayerAccount{account}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		username, oldBalance = account.Username, account.Balance
+		for _, amount := range []*Money{&account.Balance, &account.BankBalance, &account.TotalEarned, &account.TotalSpent} {
+			if *amount < 0 {
+				*amount = 0
+			}
+		}
+		if oldBalance < 0 && oldBalance >= e.minBalance() {
+			account.Balance = oldBalance
+		}
+		if account.Balance > e.config.MaxBalance {
+			account.Balance = e.config.MaxBalance
+		}
+		newBalance = account.Balance
+		return nil
+	})
+	if err != nil {
+		e.logger.Warn("Failed to repair account", "uuid", uuid, "error", err)
+		return false
+	}
+	
+	if newBalance != oldBalance {
+		e.invalidateTopPlayers()
+		e.recordTransaction(&Transaction{
+			To:        username,
+			Amount:    newBalance,
+			Type:      SET,
+			Timestamp: e.now(),
+			Reason:    "Repaired by verify",
+			Previous:  &oldBalance,
+		})
+	}
+	e.logger.Info("Repaired account", "player", username, "uuid", uuid)
+	
+	return true
+}
+
+// ledgerBalances replays the whole ledger, oldest first, and returns the
+// wallet balance it implies for every player named in it.
+func (e *EconomyPlugin) ledgerBalances() map[string]Money {
+	transactions := e.GetTransactions("", TransactionFilter{})
+	replay := e.newLedgerReplay()
+	for i := len(transactions) - 1; i >= 0; i-- {
+		replay.apply(&transactions[i])
+	}
+	
+	return replay.balances
+}
+
+func (e *EconomyPlugin) verifyCommand(ctx *CommandContext) string {
+	repair := len(ctx.Args) > 0 && strings.EqualFold(ctx.Args[0], "repair")
+	if len(ctx.Args) > 0 && !repair {
+		return e.message("verify.usage")
+	}
+	
+	issues, err := e.VerifyData(repair)
+	if err != nil {
+		return e.message("verify.failed", "error", err.Error())
+	}
+	if len(issues) == 0 {
+		return e.message("verify.ok")
+	}
+	
+	repaired := 0
+	result := e.message("verify.header", "count", strconv.Itoa(len(issues))) + "\n"
+	for _, issue := range issues {
+		key := "verify.entry"
+		if issue.Repaired {
+			key = "verify.entry_repaired"
+			repaired++
+		}
+		result += e.message(key, "player", issue.Player, "uuid", issue.UUID, "problem", issue.Problem) + "\n"
+	}
+	if repair {
+		result += e.message("verify.repaired", "count", strconv.Itoa(repaired))
+	} else {
+		result += e.message("verify.hint")
+	}
+	
+	return result
+}
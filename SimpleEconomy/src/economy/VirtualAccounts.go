@@ -0,0 +1,126 @@
+package economy
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// virtualPrefix marks account names that belong to the server or another
+// plugin rather than a player, such as "#server" or "#towny:spawn".
+const virtualPrefix = "#"
+
+// IsVirtualAccount reports whether name is a virtual account name.
+// Virtual accounts hold money like any other account but start empty, earn
+// no interest, are never pruned and stay off /top unless
+// top_include_virtual is set.
+func IsVirtualAccount(name string) bool {
+	return len(name) > len(virtualPrefix) && strings.HasPrefix(name, virtualPrefix)
+}
+
+func validVirtualName(name string) bool {
+	if !IsVirtualAccount(name) || len(name) > 64 {
+		return false
+	}
+	for _, r := range name[len(virtualPrefix):] {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_', r == '-', r == ':', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (a *PlayerAccount) virtual() bool {
+	return IsVirtualAccount(a.Username)
+}
+
+// CreateVirtualAccount opens an empty virtual account. Fees, taxes and
+// payments sent to a virtual name open it on first use as well.
+func (e *EconomyPlugin) CreateVirtualAccount(name string) error {
+	if !validVirtualName(name) {
+		return ErrInvalidAccountName
+	}
+	if e.hasAccount(name) {
+		return ErrAccountExists
+	}
+	e.createAccount(name)
+	
+	return nil
+}
+
+// VirtualAccounts returns a copy of every virtual account, by name.
+func (e *EconomyPlugin) VirtualAccounts() []PlayerAccount {
+	accounts := make([]PlayerAccount, 0)
+	for _, account := range e.snapshotAccounts() {
+		if account.virtual() {
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return strings.ToLower(accounts[i].Username) < strings.ToLower(accounts[j].Username)
+	})
+	return accounts
+}
+
+func (e *EconomyPlugin) virtualCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) == 0 {
+		return e.message("virtual.usage")
+	}
+	
+	switch strings.ToLower(args[0]) {
+	case "list":
+		accounts := e.VirtualAccounts()
+		if len(accounts) == 0 {
+			return e.message("virtual.none")
+		}
+		result := e.message("virtual.header")
+		for _, account := range accounts {
+			result += "\n" + e.message("virtual.entry", "name", account.Username, "amount", e.FormatMoney(account.Balance))
+		}
+		return result
+		
+	case "create":
+		if len(args) != 2 {
+			return e.message("virtual.usage")
+		}
+		err := e.CreateVirtualAccount(args[1])
+		if errors.Is(err, ErrInvalidAccountName) {
+			return e.message("virtual.invalid_name")
+		}
+		if err != nil {
+			return e.message("virtual.failed", "error", e.describeError(err))
+		}
+		e.audit(ctx, "virtual create", args[1], nil, nil, "")
+		return e.message("virtual.created", "name", args[1])
+		
+	case "pay":
+		if len(args) != 4 {
+			return e.message("virtual.usage")
+		}
+		name, player := args[1], args[2]
+		if !IsVirtualAccount(name) {
+			return e.message("virtual.invalid_name")
+		}
+		amount, err := e.parseAmount(args[3])
+		if err != nil {
+			return e.message("error.invalid_amount")
+		}
+		if err := e.requireAccounts(player); err != nil {
+			return e.message("virtual.failed", "error", e.describeError(err))
+		}
+		before := e.peekBalance(player)
+		if _, err := e.Transfer(name, player, amount, "Paid out by "+ctx.Name()); err != nil {
+			return e.message("virtual.failed", "error", e.describeError(err))
+		}
+		e.auditBalance(ctx, "virtual pay", player, before, e.FormatMoney(amount)+" from "+name)
+		e.logger.Info("Paid from virtual account", "admin", ctx.Name(), "account", name, "player", player, "amount", amount.String())
+		return e.message("virtual.paid", "name", name, "player", player, "amount", e.FormatMoney(amount))
+		
+	default:
+		return e.message("virtual.usage")
+	}
+}
@@ -0,0 +1,202 @@
+package economy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// voteMemory is how long a vote is remembered for spotting replays.
+const voteMemory = 7 * 24 * time.Hour
+
+// VoteRewardConfig pays Reward for every vote a vote listener reports.
+// A player's nth vote of the day is paid DailyMultipliers[n-1] times
+// Reward, the last multiplier applying to every vote past the list.
+type VoteRewardConfig struct {
+	Enabled          bool      `json:"enabled"`
+	Reward           Money     `json:"reward"`
+	DailyMultipliers []float64 `json:"daily_multipliers"`
+}
+
+// Vote is one vote as a Votifier-style listener receives it from a
+// server list. Timestamp is passed on as the service sent it.
+type Vote struct {
+	Service   string `json:"service"`
+	Player    string `json:"player"`
+	Address   string `json:"address,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// VoteReward describes a paid vote: the amount, the player's vote count
+// today including this one, and the REWARD transaction recorded.
+type VoteReward struct {
+	Amount        Money  `json:"amount"`
+	Count         int    `json:"count"`
+	TransactionID string `json:"transaction_id"`
+}
+
+type voteCount struct {
+	Day   string `json:"day"`
+	Count int    `json:"count"`
+}
+
+// voteBook remembers the votes paid in the last voteMemory, keyed by
+// service, player and timestamp, and each player's votes today. Its mutex
+// is never held while accounts are locked.
+type voteBook struct {
+	mutex  sync.Mutex
+	seen   map[string]time.Time
+	counts map[string]voteCount
+}
+
+type voteFile struct {
+	Seen   map[string]time.Time `json:"seen"`
+	Counts map[string]voteCount `json:"counts"`
+}
+
+func (v Vote) key() string {
+	return strings.ToLower(v.Service) + "|" + strings.ToLower(v.Player) + "|" + v.Timestamp
+}
+
+func (e *EconomyPlugin) votesPath() string {
+	return filepath.Join(e.dataFolder, "votes.json")
+}
+
+func (e *EconomyPlugin) loadVotes() {
+	e.votes.mutex.Lock()
+	defer e.votes.mutex.Unlock()
+	
+	e.votes.seen = make(map[string]time.Time)
+	e.votes.counts = make(map[string]voteCount)
+	
+	data, err := ioutil.ReadFile(e.votesPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Error("Failed to read votes", "error", err)
+		return
+	}
+	
+	stored := voteFile{Seen: e.votes.seen, Counts: e.votes.counts}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		e.logger.Error("Failed to parse votes", "error", err)
+	}
+	if stored.Seen != nil {
+		e.votes.seen = stored.Seen
+	}
+	if stored.Counts != nil {
+		e.votes.counts = stored.Counts
+	}
+}
+
+// saveVotes must be called with e.votes.mutex held. It forgets votes
+// older than voteMemory and counts from before today first.
+func (e *EconomyPlugin) saveVotes() {
+	now := e.now()
+	today := now.Format(historyDayLayout)
+	for key, received := range e.votes.seen {
+		if now.Sub(received) > voteMemory {
+			delete(e.votes.seen, key)
+		}
+	}
+	for player, count := range e.votes.counts {
+		if count.Day != today {
+			delete(e.votes.counts, player)
+		}
+	}
+	
+	data, err := json.MarshalIndent(voteFile{Seen: e.votes.seen, Counts: e.votes.counts}, "", "  ")
+	if err != nil {
+		e.logger.Error("Failed to marshal votes", "error", err)
+		return
+	}
+	
+	if err := writeFileAtomic(e.votesPath(), data, 0644); err != nil {
+		e.logger.Error("Failed to write votes", "error", err)
+		e.countStorageError("votes")
+	}
+}
+
+// voteMultiplier is what the count-th vote of the day is paid, times the
+// reward.
+func (e *EconomyPlugin) voteMultiplier(count int) float64 {
+	multipliers := e.config.VoteRewards.DailyMultipliers
+	if len(multipliers) == 0 {
+		return 1
+	}
+	if count > len(multipliers) {
+		count = len(multipliers)
+	}
+	return multipliers[count-1]
+}
+
+// RecordVote pays the vote reward for vote. A vote with the same service,
+// player and timestamp as one already paid is a replay and returns
+// ErrVoteDuplicate. The reward is cut to what fits under max_balance and
+// the supply cap; when the cap leaves nothing, ErrSupplyCapReached is
+// returned and the vote is not counted.
+func (e *EconomyPlugin) RecordVote(vote Vote) (VoteReward, error) {
+	config := e.config.VoteRewards
+	if !config.Enabled || config.Reward <= 0 {
+		return VoteReward{}, ErrVotesDisabled
+	}
+	if strings.TrimSpace(vote.Service) == "" || strings.TrimSpace(vote.Timestamp) == "" || vote.Player == "" {
+		return VoteReward{}, ErrInvalidVote
+	}
+	if err := e.requireAccounts(vote.Player); err != nil {
+		return VoteReward{}, err
+	}
+	
+	key, player, today := vote.key(), strings.ToLower(vote.Player), e.now().Format(historyDayLayout)
+	e.votes.mutex.Lock()
+	if _, seen := e.votes.seen[key]; seen {
+		e.votes.mutex.Unlock()
+		return VoteReward{}, ErrVoteDuplicate
+	}
+	count := 1
+	if previous := e.votes.counts[player]; previous.Day == today {
+		count = previous.Count + 1
+	}
+	e.votes.seen[key] = e.now()
+	e.votes.counts[player] = voteCount{Day: today, Count: count}
+	e.votes.mutex.Unlock()
+	
+	reward := VoteReward{Amount: e.roundAmount(config.Reward.MulRate(e.voteMultiplier(count))), Count: count}
+	if headroom := e.config.MaxBalance - e.getBalance(vote.Player); reward.Amount > headroom {
+		reward.Amount = headroom
+	}
+	var err error
+	if reward.Amount > 0 {
+		reward.Amount, err = e.allowMint(vote.Player, "vote on "+clipReference(vote.Service), reward.Amount)
+	}
+	if err == nil && reward.Amount > 0 {
+		reward.TransactionID, err = e.credit(vote.Player, reward.Amount, REWARD, ReasonReward, "Vote on "+clipReference(vote.Service))
+	}
+	
+	e.votes.mutex.Lock()
+	if err != nil {
+		delete(e.votes.seen, key)
+		if current := e.votes.counts[player]; current.Day == today && current.Count > 0 {
+			e.votes.counts[player] = voteCount{Day: today, Count: current.Count - 1}
+		}
+	} else {
+		e.saveVotes()
+	}
+	e.votes.mutex.Unlock()
+	if err != nil {
+		return VoteReward{}, err
+	}
+	
+	e.logger.Debug("Paid vote reward", "player", vote.Player, "service", vote.Service, "amount", reward.Amount.String(), "count", count)
+	if messenger := e.getMessenger(); messenger != nil && reward.Amount > 0 {
+		messenger.SendMessage(vote.Player, e.message("vote.rewarded", "service", vote.Service,
+			"amount", e.FormatMoney(reward.Amount), "count", strconv.Itoa(count)))
+	}
+	return reward, nil
+}
@@ -0,0 +1,470 @@
+package economy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VoucherConfig controls banknotes: balance withdrawn into a one-time code
+// that anyone holding it can redeem. Vouchers not redeemed within
+// ExpiryDays are refunded to whoever created them.
+type VoucherConfig struct {
+	Enabled    bool  `json:"enabled"`
+	ExpiryDays int   `json:"expiry_days"`
+	MinAmount  Money `json:"min_amount"`
+}
+
+// Voucher is an issued code. Only the code's nonce is stored; its
+// signature is recomputed from voucher.key on redeem, so a copy of
+// vouchers.json alone cannot be used to redeem anything.
+type Voucher struct {
+	ID         int       `json:"id"`
+	Issuer     string    `json:"issuer"`
+	Amount     Money     `json:"amount"`
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires"`
+	RedeemedBy string    `json:"redeemed_by,omitempty"`
+	Redeemed   time.Time `json:"redeemed,omitempty"`
+}
+
+const (
+	voucherPartBytes     = 5
+	voucherCheckInterval = time.Hour
+)
+
+var voucherEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// voucherBook holds every Voucher keyed by nonce. busy marks the nonces
+// of vouchers being created, redeemed or refunded. Its mutex is never held
+// while accounts are locked.
+type voucherBook struct {
+	mutex    sync.Mutex
+	key      []byte
+	vouchers map[string]*Voucher
+	busy     map[string]bool
+	nextID   int
+}
+
+func voucherRef(id int) string {
+	return "voucher#" + strconv.Itoa(id)
+}
+
+func (e *EconomyPlugin) vouchersPath() string {
+	return filepath.Join(e.dataFolder, "vouchers.json")
+}
+
+func (e *EconomyPlugin) loadVouchers() {
+	e.vouchers.mutex.Lock()
+	defer e.vouchers.mutex.Unlock()
+	
+	e.vouchers.vouchers = make(map[string]*Voucher)
+	e.vouchers.busy = make(map[string]bool)
+	e.vouchers.nextID = 1
+	
+	key, err := loadVoucherKey(filepath.Join(e.dataFolder, "voucher.key"))
+	if err != nil {
+		e.logger.Error("Failed to load voucher key, vouchers cannot be used", "error", err)
+		return
+	}
+	e.vouchers.key = key
+	
+	data, err := ioutil.ReadFile(e.vouchersPath())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Error("Failed to read vouchers", "error", err)
+		return
+	}
+	
+	if err := json.Unmarshal(data, &e.vouchers.vouchers); err != nil {
+		e.logger.Error("Failed to parse vouchers", "error", err)
+	}
+	for _, voucher := range e.vouchers.vouchers {
+		if voucher.ID >= e.vouchers.nextID {
+			e.vouchers.nextID = voucher.ID + 1
+		}
+	}
+}
+
+// loadVoucherKey reads the signing key, creating one on first start.
+func loadVoucherKey(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		return hex.DecodeString(strings.TrimSpace(string(data)))
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, []byte(hex.EncodeToString(key)), 0600); err != nil {
+		return nil, err
+	}
+	
+	return key, nil
+}
+
+// saveVouchers must be called with e.vouchers.mutex held.
+func (e *EconomyPlugin) saveVouchers() error {
+	data, err := json.MarshalIndent(e.vouchers.vouchers, "", "  ")
+	if err != nil {
+		return err
+	}
+	
+	if err := writeFileAtomic(e.vouchersPath(), data, 0644); err != nil {
+		e.logger.Error("Failed to write vouchers", "error", err)
+		e.countStorageError("vouchers")
+		return err
+	}
+	return nil
+}
+
+// voucherSignature signs a nonce together with its amount, so neither can
+// be changed without invalidating the code.
+func (e *EconomyPlugin) voucherSignature(nonce []byte, amount Money) []byte {
+	mac := hmac.New(sha256.New, e.vouchers.key)
+	mac.Write(nonce)
+	binary.Write(mac, binary.BigEndian, int64(amount))
+	return mac.Sum(nil)[:voucherPartBytes]
+}
+
+// formatVoucherCode renders nonce and signature as XXXX-XXXX-XXXX-XXXX.
+func formatVoucherCode(nonce, signature []byte) string {
+	raw := voucherEncoding.EncodeToString(append(append([]byte{}, nonce...), signature...))
+	parts := make([]string, 0, len(raw)/4)
+	for i := 0; i < len(raw); i += 4 {
+		parts = append(parts, raw[i:i+4])
+	}
+	return strings.Join(parts, "-")
+}
+
+func parseVoucherCode(code string) (nonce, signature []byte, ok bool) {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	raw, err := voucherEncoding.DecodeString(code)
+	if err != nil || len(raw) != 2*voucherPartBytes {
+		return nil, nil, false
+	}
+	return raw[:voucherPartBytes], raw[voucherPartBytes:], true
+}
+
+// CreateVoucher withdraws amount from player's wallet into a one-time
+// code for a banknote item or a gift.
+func (e *EconomyPlugin) CreateVoucher(player string, amount Money) (string, error) {
+	config := e.config.Vouchers
+	if !config.Enabled || e.vouchers.key == nil {
+		return "", ErrVouchersDisabled
+	}
+	if amount <= 0 || amount < config.MinAmount {
+		return "", ErrInvalidAmount
+	}
+	if !e.hasAccount(player) {
+		return "", ErrAccountNotFound
+	}
+	
+	nonce := make([]byte, voucherPartBytes)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	
+	key := voucherEncoding.EncodeToString(nonce)
+	e.vouchers.mutex.Lock()
+	if _, exists := e.vouchers.vouchers[key]; exists || e.vouchers.busy[key] {
+		e.vouchers.mutex.Unlock()
+		return "", ErrVoucherInvalid
+	}
+	e.vouchers.busy[key] = true
+	e.vouchers.mutex.Unlock()
+	
+	var oldBalance Money
+	err := e.mutateAccounts([]string{player}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if account.spendable() < amount {
+			return ErrInsufficientFunds
+		}
+		
+		oldBalance = account.Balance
+		account.Balance -= amount
+		account.TotalSpent += amount
+		return nil
+	})
+	
+	if err != nil {
+		e.vouchers.mutex.Lock()
+		delete(e.vouchers.busy, key)
+		e.vouchers.mutex.Unlock()
+		return "", err
+	}
+	
+	e.vouchers.mutex.Lock()
+	delete(e.vouchers.busy, key)
+	now := e.now()
+	id := e.vouchers.nextID
+	e.vouchers.vouchers[key] = &Voucher{
+		ID:      id,
+		Issuer:  player,
+		Amount:  amount,
+		Created: now,
+		Expires: now.AddDate(0, 0, config.ExpiryDays),
+	}
+	saveErr := e.saveVouchers()
+	if saveErr != nil {
+		delete(e.vouchers.vouchers, key)
+	} else {
+		e.vouchers.nextID++
+	}
+	e.vouchers.mutex.Unlock()
+	if saveErr != nil {
+		// A voucher that is not on disk would be lost on restart, so the
+		// money goes back.
+		err = e.mutateAccounts([]string{player}, func(accounts []*PlayerAccount) error {
+			accounts[0].Balance += amount
+			accounts[0].TotalSpent = clampZero(accounts[0].TotalSpent - amount)
+			return nil
+		})
+		if err != nil {
+			e.logger.Error("Failed to return money for unsaved voucher", "player", player, "amount", amount.String(), "error", err)
+		}
+		return "", ErrStorage
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(player, oldBalance, oldBalance-amount, VOUCHER_CREATE)
+	e.recordTransaction(&Transaction{
+		From:      player,
+		To:        voucherRef(id),
+		Amount:    amount,
+		Type:      VOUCHER_CREATE,
+		Timestamp: e.now(),
+		Reason:    "Voucher created",
+	})
+	
+	return formatVoucherCode(nonce, e.voucherSignature(nonce, amount)), nil
+}
+
+// RedeemVoucher credits a voucher's amount to player. A code can be
+// redeemed once: nobody else can redeem it while it is being paid out, and
+// it is marked redeemed once the payment commits.
+func (e *EconomyPlugin) RedeemVoucher(player, code string) (Money, error) {
+	if !e.config.Vouchers.Enabled || e.vouchers.key == nil {
+		return 0, ErrVouchersDisabled
+	}
+	
+	nonce, signature, ok := parseVoucherCode(code)
+	if !ok {
+		return 0, ErrVoucherInvalid
+	}
+	key := voucherEncoding.EncodeToString(nonce)
+	
+	voucher, err := e.claimVoucher(key, func(stored *Voucher) error {
+		if !hmac.Equal(signature, e.voucherSignature(nonce, stored.Amount)) {
+			return ErrVoucherInvalid
+		}
+		if stored.RedeemedBy != "" {
+			return ErrVoucherRedeemed
+		}
+		if e.now().After(stored.Expires) {
+			return ErrVoucherExpired
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	
+	var oldBalance Money
+	err = e.mutateAccounts([]string{player}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		if account.Balance+voucher.Amount > e.config.MaxBalance {
+			return ErrMaxBalanceExceeded
+		}
+		
+		oldBalance = account.Balance
+		account.Balance += voucher.Amount
+		account.TotalEarned += voucher.Amount
+		return nil
+	})
+	
+	e.vouchers.mutex.Lock()
+	delete(e.vouchers.busy, key)
+	if stored, exists := e.vouchers.vouchers[key]; exists && err == nil {
+		stored.RedeemedBy, stored.Redeemed = player, e.now()
+		e.saveVouchers()
+	}
+	e.vouchers.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(player, oldBalance, oldBalance+voucher.Amount, VOUCHER_REDEEM)
+	e.recordTransaction(&Transaction{
+		From:      voucherRef(voucher.ID),
+		To:        player,
+		Amount:    voucher.Amount,
+		Type:      VOUCHER_REDEEM,
+		Timestamp: e.now(),
+		Reason:    "Voucher from " + voucher.Issuer + " redeemed",
+	})
+	
+	return voucher.Amount, nil
+}
+
+func (e *EconomyPlugin) startVoucherExpiry() {
+	if !e.config.Vouchers.Enabled {
+		return
+	}
+	
+	e.runPeriodically(voucherCheckInterval, e.expireVouchers)
+}
+
+// expireVouchers refunds unredeemed vouchers past their expiry to their
+// issuers and forgets redeemed ones once they would have expired.
+func (e *EconomyPlugin) expireVouchers() {
+	now := e.now()
+	expired := make([]string, 0)
+	
+	e.vouchers.mutex.Lock()
+	pruned := false
+	for key, voucher := range e.vouchers.vouchers {
+		if !now.After(voucher.Expires) {
+			continue
+		}
+		if voucher.RedeemedBy != "" {
+			delete(e.vouchers.vouchers, key)
+			pruned = true
+			continue
+		}
+		expired = append(expired, key)
+	}
+	if pruned {
+		e.saveVouchers()
+	}
+	e.vouchers.mutex.Unlock()
+	
+	for _, key := range expired {
+		if err := e.refundVoucher(key); err != nil {
+			e.logger.Warn("Failed to refund expired voucher", "error", err)
+		}
+	}
+}
+
+func (e *EconomyPlugin) refundVoucher(key string) error {
+	voucher, err := e.claimVoucher(key, func(stored *Voucher) error {
+		if stored.RedeemedBy != "" {
+			return ErrVoucherRedeemed
+		}
+		return nil
+	})
+	if errors.Is(err, ErrVoucherInvalid) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	issuer := voucher.Issuer
+	
+	var oldBalance, credited Money
+	err = e.mutateAccounts([]string{issuer}, func(accounts []*PlayerAccount) error {
+		account := accounts[0]
+		credited = voucher.Amount
+		if room := e.config.MaxBalance - account.Balance; credited > room {
+			credited = room
+		}
+		
+		oldBalance = account.Balance
+		account.Balance += credited
+		return nil
+	})
+	
+	e.vouchers.mutex.Lock()
+	delete(e.vouchers.busy, key)
+	if err == nil {
+		delete(e.vouchers.vouchers, key)
+		e.saveVouchers()
+	}
+	e.vouchers.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	
+	e.invalidateTopPlayers()
+	e.fireBalanceChange(issuer, oldBalance, oldBalance+credited, VOUCHER_REFUND)
+	e.recordTransaction(&Transaction{
+		From:      voucherRef(voucher.ID),
+		To:        issuer,
+		Amount:    credited,
+		Type:      VOUCHER_REFUND,
+		Timestamp: e.now(),
+		Reason:    "Expired voucher refunded",
+	})
+	
+	return nil
+}
+
+// claimVoucher marks the voucher with nonce key busy if check allows, and
+// returns it. Callers clear the mark once they have paid it out or failed
+// to. A voucher that does not exist or is already busy is
+// ErrVoucherInvalid.
+func (e *EconomyPlugin) claimVoucher(key string, check func(stored *Voucher) error) (Voucher, error) {
+	e.vouchers.mutex.Lock()
+	defer e.vouchers.mutex.Unlock()
+	
+	stored, exists := e.vouchers.vouchers[key]
+	if !exists || e.vouchers.busy[key] {
+		return Voucher{}, ErrVoucherInvalid
+	}
+	if err := check(stored); err != nil {
+		return Voucher{}, err
+	}
+	e.vouchers.busy[key] = true
+	return *stored, nil
+}
+
+func (e *EconomyPlugin) voucherCommand(ctx *CommandContext) string {
+	args := ctx.Args
+	if len(args) < 2 {
+		return e.message("voucher.usage")
+	}
+	
+	player := ctx.Name()
+	switch strings.ToLower(args[0]) {
+	case "create":
+		amount, err := e.parseAmount(args[1])
+		if err != nil {
+			return e.message("error.invalid_amount")
+		}
+		code, err := e.CreateVoucher(player, amount)
+		if err != nil {
+			return e.message("voucher.create_failed", "error", e.describeError(err))
+		}
+		return e.message("voucher.created", "amount", e.FormatMoney(amount), "code", code,
+			"days", strconv.Itoa(e.config.Vouchers.ExpiryDays))
+		
+	case "redeem":
+		amount, err := e.RedeemVoucher(player, strings.Join(args[1:], ""))
+		if err != nil {
+			return e.message("voucher.redeem_failed", "error", e.describeError(err))
+		}
+		return e.message("voucher.redeemed", "amount", e.FormatMoney(amount))
+		
+	default:
+		return e.message("voucher.usage")
+	}
+}
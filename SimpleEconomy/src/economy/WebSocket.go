@@ -0,0 +1,279 @@
+package economy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	
+	"golang.org/x/net/websocket"
+)
+
+const (
+	eventBalanceChange  = "balance_change"
+	eventTransfer       = "transfer"
+	eventAccountCreated = "account_created"
+	
+	// eventClientBuffer is how many events a connection may fall behind
+	// before it is dropped rather than slowing down the transaction path.
+	eventClientBuffer = 256
+	eventWriteTimeout = 10 * time.Second
+)
+
+var eventNames = []string{eventBalanceChange, eventTransfer, eventAccountCreated}
+
+type apiEvent struct {
+	Event     string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+	
+	players []string
+}
+
+type apiBalanceChange struct {
+	Player     string `json:"player"`
+	OldBalance Money  `json:"old_balance"`
+	NewBalance Money  `json:"new_balance"`
+	Type       string `json:"type"`
+}
+
+type apiAccountCreated struct {
+	Player  string `json:"player"`
+	UUID    string `json:"uuid"`
+	Balance Money  `json:"balance"`
+}
+
+// eventFilter selects the events a connection receives. Empty sets match
+// everything.
+type eventFilter struct {
+	Players []string `json:"players"`
+	Events  []string `json:"events"`
+}
+
+func parseEventFilter(players, events string) (eventFilter, error) {
+	var filter eventFilter
+	if players != "" {
+		filter.Players = strings.Split(players, ",")
+	}
+	if events != "" {
+		filter.Events = strings.Split(events, ",")
+	}
+	
+	return filter, filter.validate()
+}
+
+func (f eventFilter) validate() error {
+	for _, name := range f.Events {
+		known := false
+		for _, event := range eventNames {
+			known = known || name == event
+		}
+		if !known {
+			return fmt.Errorf("unknown event %q", name)
+		}
+	}
+	
+	return nil
+}
+
+func (f eventFilter) matches(ev *apiEvent) bool {
+	if len(f.Events) > 0 {
+		found := false
+		for _, name := range f.Events {
+			found = found || name == ev.Event
+		}
+		if !found {
+			return false
+		}
+	}
+	
+	if len(f.Players) == 0 {
+		return true
+	}
+	for _, player := range f.Players {
+		for _, involved := range ev.players {
+			if involved != "" && strings.EqualFold(player, involved) {
+				return true
+			}
+		}
+	}
+	
+	return false
+}
+
+// eventHub fans events out to the connected WebSocket clients.
+type eventHub struct {
+	mutex   sync.Mutex
+	clients map[*eventClient]struct{}
+	hooks   sync.Once
+}
+
+type eventClient struct {
+	conn   *websocket.Conn
+	send   chan *apiEvent
+	mutex  sync.Mutex
+	filter eventFilter
+}
+
+// registerEventHooks subscribes the hub to the plugin's events. Listeners
+// cannot be removed, so this only ever happens once per plugin.
+func (e *EconomyPlugin) registerEventHooks() {
+	e.events.hooks.Do(func() {
+		e.OnBalanceChange(func(ev BalanceChangeEvent) {
+			e.events.publish(&apiEvent{
+				Event:     eventBalanceChange,
+				Timestamp: e.now(),
+				Data: apiBalanceChange{
+					Player:     ev.Username,
+					OldBalance: ev.OldBalance,
+					NewBalance: ev.NewBalance,
+					Type:       ev.Type.String(),
+				},
+				players: []string{ev.Username},
+			})
+		})
+		
+		e.OnTransaction(func(transaction Transaction) {
+			if transaction.Type != TRANSFER {
+				return
+			}
+			e.events.publish(&apiEvent{
+				Event:     eventTransfer,
+				Timestamp: transaction.Timestamp,
+				Data: apiTransaction{
+					From:      transaction.From,
+					To:        transaction.To,
+					Amount:    transaction.Amount,
+					Type:      transaction.Type.String(),
+					Timestamp: transaction.Timestamp,
+					Reason:    transaction.Reason,
+				},
+				players: []string{transaction.From, transaction.To},
+			})
+		})
+		
+		e.OnAccountCreated(func(ev AccountCreatedEvent) {
+			e.events.publish(&apiEvent{
+				Event:     eventAccountCreated,
+				Timestamp: e.now(),
+				Data: apiAccountCreated{
+					Player:  ev.Username,
+					UUID:    ev.UUID,
+					Balance: ev.Balance,
+				},
+				players: []string{ev.Username},
+			})
+		})
+	})
+}
+
+// handleEvents upgrades the request to a WebSocket that streams events as
+// JSON. The player and events query parameters take comma-separated lists,
+// and the client can replace its filter by sending one as JSON.
+func (e *EconomyPlugin) handleEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter, err := parseEventFilter(query.Get("player"), query.Get("events"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+		return
+	}
+	
+	websocket.Server{Handler: func(conn *websocket.Conn) {
+		e.serveEvents(conn, filter)
+	}}.ServeHTTP(w, r)
+}
+
+func (e *EconomyPlugin) serveEvents(conn *websocket.Conn, filter eventFilter) {
+	// The HTTP server's deadlines still apply to the hijacked connection.
+	conn.SetDeadline(time.Time{})
+	
+	client := &eventClient{conn: conn, send: make(chan *apiEvent, eventClientBuffer), filter: filter}
+	e.events.add(client)
+	defer e.events.remove(client)
+	
+	go client.writeEvents()
+	
+	for {
+		var update eventFilter
+		if err := websocket.JSON.Receive(conn, &update); err != nil {
+			return
+		}
+		if err := update.validate(); err != nil {
+			continue
+		}
+		
+		client.mutex.Lock()
+		client.filter = update
+		client.mutex.Unlock()
+	}
+}
+
+func (c *eventClient) writeEvents() {
+	for ev := range c.send {
+		c.conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+		if err := websocket.JSON.Send(c.conn, ev); err != nil {
+			c.conn.Close()
+			return
+		}
+	}
+	c.conn.Close()
+}
+
+func (h *eventHub) add(client *eventClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	
+	if h.clients == nil {
+		h.clients = make(map[*eventClient]struct{})
+	}
+	h.clients[client] = struct{}{}
+}
+
+func (h *eventHub) remove(client *eventClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	
+	h.drop(client)
+}
+
+// drop closes client's queue, which ends its writer and the connection.
+// Callers hold h.mutex.
+func (h *eventHub) drop(client *eventClient) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	delete(h.clients, client)
+	close(client.send)
+}
+
+// publish queues ev for every matching client. A client whose queue is
+// full is disconnected.
+func (h *eventHub) publish(ev *apiEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	
+	for client := range h.clients {
+		client.mutex.Lock()
+		matches := client.filter.matches(ev)
+		client.mutex.Unlock()
+		if !matches {
+			continue
+		}
+		
+		select {
+		case client.send <- ev:
+		default:
+			h.drop(client)
+		}
+	}
+}
+
+func (h *eventHub) closeAll() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	
+	for client := range h.clients {
+		h.drop(client)
+	}
+}
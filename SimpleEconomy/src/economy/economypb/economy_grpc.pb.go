@@ -0,0 +1,253 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             v5.29.3
+// source: economy.proto
+
+package economypb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Economy_GetBalance_FullMethodName         = "/simpleeconomy.v1.Economy/GetBalance"
+	Economy_Transfer_FullMethodName           = "/simpleeconomy.v1.Economy/Transfer"
+	Economy_Top_FullMethodName                = "/simpleeconomy.v1.Economy/Top"
+	Economy_StreamTransactions_FullMethodName = "/simpleeconomy.v1.Economy/StreamTransactions"
+)
+
+// EconomyClient is the client API for Economy service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Economy exposes balances and transfers to external services. Amounts are
+// decimal strings such as "12.5" so no precision is lost between languages.
+// Every call needs the configured API token in the "authorization" metadata,
+// as "Bearer <token>".
+type EconomyClient interface {
+	GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error)
+	Transfer(ctx context.Context, in *TransferRequest, opts ...grpc.CallOption) (*TransferResponse, error)
+	Top(ctx context.Context, in *TopRequest, opts ...grpc.CallOption) (*TopResponse, error)
+	// StreamTransactions sends matching transactions as they are recorded.
+	// When since is set, transactions recorded from then on are sent first.
+	StreamTransactions(ctx context.Context, in *StreamTransactionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Transaction], error)
+}
+
+type economyClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEconomyClient(cc grpc.ClientConnInterface) EconomyClient {
+	return &economyClient{cc}
+}
+
+func (c *economyClient) GetBalance(ctx context.Context, in *GetBalanceRequest, opts ...grpc.CallOption) (*Balance, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Balance)
+	err := c.cc.Invoke(ctx, Economy_GetBalance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *economyClient) Transfer(ctx context.Context, in *TransferRequest, opts ...grpc.CallOption) (*TransferResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransferResponse)
+	err := c.cc.Invoke(ctx, Economy_Transfer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *economyClient) Top(ctx context.Context, in *TopRequest, opts ...grpc.CallOption) (*TopResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TopResponse)
+	err := c.cc.Invoke(ctx, Economy_Top_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *economyClient) StreamTransactions(ctx context.Context, in *StreamTransactionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Transaction], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Economy_ServiceDesc.Streams[0], Economy_StreamTransactions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamTransactionsRequest, Transaction]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Economy_StreamTransactionsClient = grpc.ServerStreamingClient[Transaction]
+
+// EconomyServer is the server API for Economy service.
+// All implementations must embed UnimplementedEconomyServer
+// for forward compatibility.
+//
+// Economy exposes balances and transfers to external services. Amounts are
+// decimal strings such as "12.5" so no precision is lost between languages.
+// Every call needs the configured API token in the "authorization" metadata,
+// as "Bearer <token>".
+type EconomyServer interface {
+	GetBalance(context.Context, *GetBalanceRequest) (*Balance, error)
+	Transfer(context.Context, *TransferRequest) (*TransferResponse, error)
+	Top(context.Context, *TopRequest) (*TopResponse, error)
+	// StreamTransactions sends matching transactions as they are recorded.
+	// When since is set, transactions recorded from then on are sent first.
+	StreamTransactions(*StreamTransactionsRequest, grpc.ServerStreamingServer[Transaction]) error
+	mustEmbedUnimplementedEconomyServer()
+}
+
+// UnimplementedEconomyServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedEconomyServer struct{}
+
+func (UnimplementedEconomyServer) GetBalance(context.Context, *GetBalanceRequest) (*Balance, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetBalance not implemented")
+}
+func (UnimplementedEconomyServer) Transfer(context.Context, *TransferRequest) (*TransferResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Transfer not implemented")
+}
+func (UnimplementedEconomyServer) Top(context.Context, *TopRequest) (*TopResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Top not implemented")
+}
+func (UnimplementedEconomyServer) StreamTransactions(*StreamTransactionsRequest, grpc.ServerStreamingServer[Transaction]) error {
+	return status.Error(codes.Unimplemented, "method StreamTransactions not implemented")
+}
+func (UnimplementedEconomyServer) mustEmbedUnimplementedEconomyServer() {}
+func (UnimplementedEconomyServer) testEmbeddedByValue()                 {}
+
+// UnsafeEconomyServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EconomyServer will
+// result in compilation errors.
+type UnsafeEconomyServer interface {
+	mustEmbedUnimplementedEconomyServer()
+}
+
+func RegisterEconomyServer(s grpc.ServiceRegistrar, srv EconomyServer) {
+	// If the following call panics, it indicates UnimplementedEconomyServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Economy_ServiceDesc, srv)
+}
+
+func _Economy_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EconomyServer).GetBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Economy_GetBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EconomyServer).GetBalance(ctx, req.(*GetBalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Economy_Transfer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransferRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EconomyServer).Transfer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Economy_Transfer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EconomyServer).Transfer(ctx, req.(*TransferRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Economy_Top_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EconomyServer).Top(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Economy_Top_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EconomyServer).Top(ctx, req.(*TopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Economy_StreamTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamTransactionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EconomyServer).StreamTransactions(m, &grpc.GenericServerStream[StreamTransactionsRequest, Transaction]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Economy_StreamTransactionsServer = grpc.ServerStreamingServer[Transaction]
+
+// Economy_ServiceDesc is the grpc.ServiceDesc for Economy service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Economy_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "simpleeconomy.v1.Economy",
+	HandlerType: (*EconomyServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetBalance",
+			Handler:    _Economy_GetBalance_Handler,
+		},
+		{
+			MethodName: "Transfer",
+			Handler:    _Economy_Transfer_Handler,
+		},
+		{
+			MethodName: "Top",
+			Handler:    _Economy_Top_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamTransactions",
+			Handler:       _Economy_StreamTransactions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "economy.proto",
+}
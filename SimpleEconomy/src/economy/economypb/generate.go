@@ -0,0 +1,5 @@
+// Package economypb holds the gRPC service definition for the economy API.
+// Regenerate it after editing economy.proto.
+package economypb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative economy.proto
@@ -0,0 +1,1011 @@
+// Package economytest provides Fake, an in-memory economy.Economy for
+// testing plugins that depend on SimpleEconomy without a data folder:
+//
+//	fake := economytest.New()
+//	fake.SetBalance("steve", economy.MoneyFromFloat(100))
+//	shop := NewShop(fake)
+//	shop.Buy("steve", "diamond")
+//	fake.AssertCalled(t, "Withdraw", "steve", economy.MoneyFromFloat(25))
+//
+// Money moves between accounts as the plugin would move it, but none of
+// its config applies: there are no fees, limits, caps or events.
+package economytest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	
+	"github.com/percocets100/SimpleEconomy/SimpleEconomy/src/economy"
+)
+
+// ErrNotSupported is returned by the few methods the Fake does not model.
+var ErrNotSupported = errors.New("economytest: not supported by the fake")
+
+// Call is one method call the Fake received, with its arguments in order.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+type fakeAccount struct {
+	name    string
+	balance economy.Money
+	earned  economy.Money
+	spent   economy.Money
+	holds   []economy.Hold
+	virtual bool
+	hidden  bool
+}
+
+func (a *fakeAccount) held() economy.Money {
+	held := economy.Money(0)
+	for _, hold := range a.holds {
+		held += hold.Amount
+	}
+	return held
+}
+
+// Fake is an in-memory economy.Economy that records every call made to
+// it. The zero value is not usable; create one with New.
+type Fake struct {
+	mutex        sync.Mutex
+	accounts     map[string]*fakeAccount
+	transactions []economy.Transaction
+	refunded     map[string]bool
+	escrows      map[int]economy.Escrow
+	vouchers     map[string]economy.Money
+	earnings     map[string]map[string]economy.Money
+	quests       map[string]bool
+	failures     map[string]error
+	calls        []Call
+	nextID       int
+}
+
+var _ economy.Economy = (*Fake)(nil)
+
+// New returns an empty Fake.
+func New() *Fake {
+	return &Fake{
+		accounts: make(map[string]*fakeAccount),
+		refunded: make(map[string]bool),
+		escrows:  make(map[int]economy.Escrow),
+		vouchers: make(map[string]economy.Money),
+		earnings: make(map[string]map[string]economy.Money),
+		quests:   make(map[string]bool),
+		failures: make(map[string]error),
+	}
+}
+
+// SetBalance preloads player's balance, opening their account if needed.
+// It is not recorded as a call.
+func (f *Fake) SetBalance(player string, balance economy.Money) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.open(player).balance = balance
+}
+
+// Balance returns player's balance, or zero without an account. It is
+// not recorded as a call.
+func (f *Fake) Balance(player string) economy.Money {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if account := f.accounts[strings.ToLower(player)]; account != nil {
+		return account.balance
+	}
+	return 0
+}
+
+// FailWith makes method return err, without changing anything, until it
+// is called again with a nil err.
+func (f *Fake) FailWith(method string, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err == nil {
+		delete(f.failures, method)
+	} else {
+		f.failures[method] = err
+	}
+}
+
+// Calls returns every call received, oldest first.
+func (f *Fake) Calls() []Call {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	return append([]Call(nil), f.calls...)
+}
+
+// CallsTo returns the calls received to method, oldest first.
+func (f *Fake) CallsTo(method string) []Call {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	calls := make([]Call, 0)
+	for _, call := range f.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Transactions returns the transactions recorded, oldest first.
+func (f *Fake) Transactions() []economy.Transaction {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	return append([]economy.Transaction(nil), f.transactions...)
+}
+
+// Reset forgets the calls received, keeping accounts and transactions.
+func (f *Fake) Reset() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.calls = nil
+}
+
+// AssertCalled fails t unless method was called with arguments starting
+// with args. Trailing arguments left out, such as reasons, match anything.
+func (f *Fake) AssertCalled(t testing.TB, method string, args ...interface{}) {
+	t.Helper()
+	calls := f.CallsTo(method)
+	for _, call := range calls {
+		if len(call.Args) >= len(args) && reflect.DeepEqual(call.Args[:len(args)], args) {
+			return
+		}
+	}
+	t.Errorf("economytest: no call %s(%s); calls to it were %v", method, formatArgs(args), calls)
+}
+
+// AssertNotCalled fails t if method was called at all.
+func (f *Fake) AssertNotCalled(t testing.TB, method string) {
+	t.Helper()
+	if calls := f.CallsTo(method); len(calls) > 0 {
+		t.Errorf("economytest: %s was called %d times: %v", method, len(calls), calls)
+	}
+}
+
+func formatArgs(args []interface{}) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = fmt.Sprintf("%#v", arg)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// begin records a call and returns the error FailWith set for it. It
+// must be called with f.mutex held.
+func (f *Fake) begin(method string, args ...interface{}) error {
+	f.calls = append(f.calls, Call{Method: method, Args: args})
+	return f.failures[method]
+}
+
+func scoped(player string, scope []string) string {
+	if len(scope) > 0 {
+		return economy.ScopedName(player, scope[0])
+	}
+	return player
+}
+
+func (f *Fake) open(name string) *fakeAccount {
+	key := strings.ToLower(name)
+	account := f.accounts[key]
+	if account == nil {
+		account = &fakeAccount{name: name, virtual: economy.IsVirtualAccount(name)}
+		f.accounts[key] = account
+	}
+	return account
+}
+
+func (f *Fake) lookup(name string) (*fakeAccount, error) {
+	if account := f.accounts[strings.ToLower(name)]; account != nil {
+		return account, nil
+	}
+	return nil, economy.ErrAccountNotFound
+}
+
+func (f *Fake) record(transaction economy.Transaction) string {
+	f.nextID++
+	transaction.ID = fmt.Sprintf("FAKE%06d", f.nextID)
+	transaction.Timestamp = time.Now()
+	f.transactions = append(f.transactions, transaction)
+	return transaction.ID
+}
+
+// move takes amount from from and gives it to to; either may be empty
+// for money entering or leaving the economy. It opens to if needed.
+func (f *Fake) move(from, to string, amount economy.Money, kind economy.TransactionType, code economy.ReasonCode, reason string) (string, error) {
+	if amount <= 0 {
+		return "", economy.ErrInvalidAmount
+	}
+	if from != "" && strings.EqualFold(from, to) {
+		return "", economy.ErrSelfTransfer
+	}
+	var payer *fakeAccount
+	if from != "" {
+		var err error
+		if payer, err = f.lookup(from); err != nil {
+			return "", err
+		}
+		if payer.balance-payer.held() < amount {
+			return "", economy.ErrInsufficientFunds
+		}
+	}
+	
+	if payer != nil {
+		payer.balance -= amount
+		payer.spent += amount
+	}
+	if to != "" {
+		payee := f.open(to)
+		payee.balance += amount
+		payee.earned += amount
+	}
+	return f.record(economy.Transaction{From: from, To: to, Amount: amount, Type: kind, Code: code, Reason: reason}), nil
+}
+
+func (f *Fake) GetBalance(username string, scope ...string) (economy.Money, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("GetBalance", username, scope); err != nil {
+		return 0, err
+	}
+	account, err := f.lookup(scoped(username, scope))
+	if err != nil {
+		return 0, err
+	}
+	return account.balance, nil
+}
+
+func (f *Fake) Deposit(username string, amount economy.Money, reason string, scope ...string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("Deposit", username, amount, reason, scope); err != nil {
+		return "", err
+	}
+	return f.move("", scoped(username, scope), amount, economy.ADD, "", reason)
+}
+
+func (f *Fake) Withdraw(username string, amount economy.Money, reason string, scope ...string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("Withdraw", username, amount, reason, scope); err != nil {
+		return "", err
+	}
+	return f.move(scoped(username, scope), "", amount, economy.SUBTRACT, "", reason)
+}
+
+func (f *Fake) DepositWithCode(username string, amount economy.Money, code economy.ReasonCode, reason string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("DepositWithCode", username, amount, code, reason); err != nil {
+		return "", err
+	}
+	return f.move("", username, amount, economy.ADD, code, reason)
+}
+
+func (f *Fake) WithdrawWithCode(username string, amount economy.Money, code economy.ReasonCode, reason string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("WithdrawWithCode", username, amount, code, reason); err != nil {
+		return "", err
+	}
+	return f.move(username, "", amount, economy.SUBTRACT, code, reason)
+}
+
+func (f *Fake) Transfer(from, to string, amount economy.Money, reason string, scope ...string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("Transfer", from, to, amount, reason, scope); err != nil {
+		return "", err
+	}
+	return f.move(scoped(from, scope), scoped(to, scope), amount, economy.TRANSFER, "", reason)
+}
+
+func (f *Fake) HasAccount(username string, scope ...string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.begin("HasAccount", username, scope)
+	_, err := f.lookup(scoped(username, scope))
+	return err == nil
+}
+
+func (f *Fake) FormatMoney(amount economy.Money) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.begin("FormatMoney", amount)
+	return "$" + amount.String()
+}
+
+func (f *Fake) GetBalanceHistory(username string, since time.Time) ([]economy.BalanceSnapshot, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("GetBalanceHistory", username, since); err != nil {
+		return nil, err
+	}
+	account, err := f.lookup(username)
+	if err != nil {
+		return nil, err
+	}
+	day := time.Now().Truncate(24 * time.Hour)
+	return []economy.BalanceSnapshot{{Day: day, Balance: account.balance}}, nil
+}
+
+// GetDebt is always zero: the Fake has no loans.
+func (f *Fake) GetDebt(username string) (economy.Money, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("GetDebt", username); err != nil {
+		return 0, err
+	}
+	_, err := f.lookup(username)
+	return 0, err
+}
+
+func (f *Fake) GetLoans(username string) []economy.Loan {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.begin("GetLoans", username)
+	return []economy.Loan{}
+}
+
+func (f *Fake) CreateEscrow(from, to string, amount economy.Money, expiry time.Duration) (economy.Escrow, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("CreateEscrow", from, to, amount, expiry); err != nil {
+		return economy.Escrow{}, err
+	}
+	if _, err := f.move(from, "", amount, economy.ESCROW_HOLD, "", "Escrow to "+to); err != nil {
+		return economy.Escrow{}, err
+	}
+	f.nextID++
+	escrow := economy.Escrow{ID: f.nextID, From: from, To: to, Amount: amount, Created: time.Now()}
+	if expiry > 0 {
+		escrow.Expires = escrow.Created.Add(expiry)
+	}
+	f.escrows[escrow.ID] = escrow
+	return escrow, nil
+}
+
+func (f *Fake) settleEscrow(method string, id int, kind economy.TransactionType, payee func(economy.Escrow) string) (economy.Escrow, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin(method, id); err != nil {
+		return economy.Escrow{}, err
+	}
+	escrow, exists := f.escrows[id]
+	if !exists {
+		return economy.Escrow{}, economy.ErrEscrowNotFound
+	}
+	delete(f.escrows, id)
+	f.move("", payee(escrow), escrow.Amount, kind, "", "Escrow #"+strconv.Itoa(id))
+	return escrow, nil
+}
+
+func (f *Fake) ReleaseEscrow(id int) (economy.Escrow, error) {
+	return f.settleEscrow("ReleaseEscrow", id, economy.ESCROW_RELEASE, func(escrow economy.Escrow) string { return escrow.To })
+}
+
+func (f *Fake) CancelEscrow(id int) (economy.Escrow, error) {
+	return f.settleEscrow("CancelEscrow", id, economy.ESCROW_REFUND, func(escrow economy.Escrow) string { return escrow.From })
+}
+
+func (f *Fake) CreateVoucher(player string, amount economy.Money) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("CreateVoucher", player, amount); err != nil {
+		return "", err
+	}
+	if _, err := f.move(player, "", amount, economy.VOUCHER_CREATE, "", "Voucher"); err != nil {
+		return "", err
+	}
+	code := fmt.Sprintf("FAKE-%04d", len(f.vouchers)+1)
+	f.vouchers[code] = amount
+	return code, nil
+}
+
+func (f *Fake) RedeemVoucher(player, code string) (economy.Money, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("RedeemVoucher", player, code); err != nil {
+		return 0, err
+	}
+	amount, exists := f.vouchers[code]
+	if !exists {
+		return 0, economy.ErrVoucherInvalid
+	}
+	if amount == 0 {
+		return 0, economy.ErrVoucherRedeemed
+	}
+	f.vouchers[code] = 0
+	_, err := f.move("", player, amount, economy.VOUCHER_REDEEM, "", "Voucher "+code)
+	return amount, err
+}
+
+// players returns the keys of the player accounts, sorted; the filter
+// is ignored, since the Fake knows nobody online.
+func (f *Fake) players() []string {
+	keys := make([]string, 0, len(f.accounts))
+	for key, account := range f.accounts {
+		if !account.virtual {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *Fake) GiveAll(amount economy.Money, filter economy.AccountFilter, reason string) (economy.BatchResult, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("GiveAll", amount, filter, reason); err != nil {
+		return economy.BatchResult{}, err
+	}
+	if amount <= 0 {
+		return economy.BatchResult{}, economy.ErrInvalidAmount
+	}
+	var result economy.BatchResult
+	for _, key := range f.players() {
+		f.move("", f.accounts[key].name, amount, economy.BATCH, "", reason)
+		result.Accounts++
+		result.Amount += amount
+	}
+	return result, nil
+}
+
+// TakeAll takes what it can from each account, short of money on hold.
+func (f *Fake) TakeAll(amount economy.Money, filter economy.AccountFilter, reason string) (economy.BatchResult, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("TakeAll", amount, filter, reason); err != nil {
+		return economy.BatchResult{}, err
+	}
+	if amount <= 0 {
+		return economy.BatchResult{}, economy.ErrInvalidAmount
+	}
+	var result economy.BatchResult
+	for _, key := range f.players() {
+		account := f.accounts[key]
+		taken := amount
+		if available := account.balance - account.held(); taken > available {
+			taken = available
+		}
+		if taken <= 0 {
+			continue
+		}
+		f.move(account.name, "", taken, economy.BATCH, "", reason)
+		result.Accounts++
+		result.Amount += taken
+	}
+	return result, nil
+}
+
+// MultiTransfer makes every transfer or, if any would fail, none.
+func (f *Fake) MultiTransfer(requests []economy.TransferRequest, reason string) (economy.BatchResult, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("MultiTransfer", requests, reason); err != nil {
+		return economy.BatchResult{}, err
+	}
+	spending := make(map[string]economy.Money)
+	for _, request := range requests {
+		if request.Amount <= 0 {
+			return economy.BatchResult{}, economy.ErrInvalidAmount
+		}
+		payer, err := f.lookup(request.From)
+		if err != nil {
+			return economy.BatchResult{}, err
+		}
+		key := strings.ToLower(request.From)
+		spending[key] += request.Amount
+		if payer.balance-payer.held() < spending[key] {
+			return economy.BatchResult{}, economy.ErrInsufficientFunds
+		}
+	}
+	
+	var result economy.BatchResult
+	for _, request := range requests {
+		if _, err := f.move(request.From, request.To, request.Amount, economy.TRANSFER, "", reason); err != nil {
+			return result, err
+		}
+		result.Accounts++
+		result.Amount += request.Amount
+	}
+	return result, nil
+}
+
+// ChargeForPurchase pays amount from buyer to seller; an empty seller is
+// the server.
+func (f *Fake) ChargeForPurchase(buyer, seller string, amount economy.Money, itemRef string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("ChargeForPurchase", buyer, seller, amount, itemRef); err != nil {
+		return "", err
+	}
+	return f.move(buyer, seller, amount, economy.PURCHASE, "", itemRef)
+}
+
+func (f *Fake) Refund(transactionID string) (economy.Transaction, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("Refund", transactionID); err != nil {
+		return economy.Transaction{}, err
+	}
+	original, err := f.transaction(transactionID)
+	if err != nil {
+		return economy.Transaction{}, err
+	}
+	if original.Type != economy.PURCHASE && original.Type != economy.TRANSFER {
+		return economy.Transaction{}, economy.ErrNotRefundable
+	}
+	if f.refunded[transactionID] {
+		return economy.Transaction{}, economy.ErrAlreadyRefunded
+	}
+	id, err := f.move(original.To, original.From, original.Amount, economy.REFUND, "", "Refund of "+transactionID)
+	if err != nil {
+		return economy.Transaction{}, err
+	}
+	f.refunded[transactionID] = true
+	return f.transaction(id)
+}
+
+func (f *Fake) transaction(id string) (economy.Transaction, error) {
+	for _, transaction := range f.transactions {
+		if transaction.ID == id {
+			return transaction, nil
+		}
+	}
+	return economy.Transaction{}, economy.ErrTransactionNotFound
+}
+
+func (f *Fake) GetTransaction(id string) (economy.Transaction, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("GetTransaction", id); err != nil {
+		return economy.Transaction{}, err
+	}
+	return f.transaction(id)
+}
+
+func (f *Fake) PlaceHold(player string, amount economy.Money, reason string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("PlaceHold", player, amount, reason); err != nil {
+		return "", err
+	}
+	if amount <= 0 {
+		return "", economy.ErrInvalidAmount
+	}
+	account, err := f.lookup(player)
+	if err != nil {
+		return "", err
+	}
+	if account.balance-account.held() < amount {
+		return "", economy.ErrInsufficientFunds
+	}
+	f.nextID++
+	hold := economy.Hold{ID: fmt.Sprintf("hold%d", f.nextID), Amount: amount, Reason: reason, Created: time.Now()}
+	account.holds = append(account.holds, hold)
+	return hold.ID, nil
+}
+
+// liftHold must be called with f.mutex held.
+func (f *Fake) liftHold(player, id string) (*fakeAccount, economy.Money, error) {
+	account, err := f.lookup(player)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i, hold := range account.holds {
+		if hold.ID == id {
+			account.holds = append(account.holds[:i], account.holds[i+1:]...)
+			return account, hold.Amount, nil
+		}
+	}
+	return nil, 0, economy.ErrHoldNotFound
+}
+
+func (f *Fake) ReleaseHold(player, id string) (economy.Money, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("ReleaseHold", player, id); err != nil {
+		return 0, err
+	}
+	_, amount, err := f.liftHold(player, id)
+	return amount, err
+}
+
+func (f *Fake) CaptureHold(player, id, to string) (economy.Money, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("CaptureHold", player, id, to); err != nil {
+		return 0, err
+	}
+	account, amount, err := f.liftHold(player, id)
+	if err != nil {
+		return 0, err
+	}
+	_, err = f.move(account.name, to, amount, economy.HOLD_CAPTURE, "", "Hold "+id)
+	return amount, err
+}
+
+func (f *Fake) GetHolds(player string) []economy.Hold {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.begin("GetHolds", player)
+	if account := f.accounts[strings.ToLower(player)]; account != nil {
+		return append([]economy.Hold{}, account.holds...)
+	}
+	return []economy.Hold{}
+}
+
+// EarnFromJob pays the whole amount; the Fake has no daily caps.
+func (f *Fake) EarnFromJob(player, job string, amount economy.Money) (economy.Money, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("EarnFromJob", player, job, amount); err != nil {
+		return 0, err
+	}
+	if _, err := f.move("", player, amount, economy.JOB, "", "Job: "+job); err != nil {
+		return 0, err
+	}
+	key := strings.ToLower(player)
+	if f.earnings[key] == nil {
+		f.earnings[key] = make(map[string]economy.Money)
+	}
+	f.earnings[key][job] += amount
+	return amount, nil
+}
+
+func (f *Fake) GetEarnings(player string) (map[string]economy.Money, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("GetEarnings", player); err != nil {
+		return nil, err
+	}
+	earnings := make(map[string]economy.Money)
+	for job, amount := range f.earnings[strings.ToLower(player)] {
+		earnings[job] = amount
+	}
+	return earnings, nil
+}
+
+func (f *Fake) Flows(since time.Time) []economy.MoneyFlow {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.begin("Flows", since)
+	return []economy.MoneyFlow{}
+}
+
+func (f *Fake) CreateVirtualAccount(name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("CreateVirtualAccount", name); err != nil {
+		return err
+	}
+	if !economy.IsVirtualAccount(name) {
+		return economy.ErrInvalidAccountName
+	}
+	if _, err := f.lookup(name); err == nil {
+		return economy.ErrAccountExists
+	}
+	f.open(name)
+	return nil
+}
+
+func (f *Fake) CreateAccount(username string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("CreateAccount", username); err != nil {
+		return err
+	}
+	if _, err := f.lookup(username); err == nil {
+		return economy.ErrAccountExists
+	}
+	f.open(username)
+	return nil
+}
+
+func (f *Fake) snapshot(account *fakeAccount) economy.PlayerAccount {
+	return economy.PlayerAccount{
+		Username:    account.name,
+		Balance:     account.balance,
+		TotalEarned: account.earned,
+		TotalSpent:  account.spent,
+		Holds:       append([]economy.Hold(nil), account.holds...),
+		Hidden:      account.hidden,
+	}
+}
+
+func (f *Fake) VirtualAccounts() []economy.PlayerAccount {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.begin("VirtualAccounts")
+	accounts := make([]economy.PlayerAccount, 0)
+	for _, account := range f.accounts {
+		if account.virtual {
+			accounts = append(accounts, f.snapshot(account))
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Username < accounts[j].Username })
+	return accounts
+}
+
+// ranking must be called with f.mutex held.
+func (f *Fake) ranking(category economy.LeaderboardCategory) ([]economy.PlayerAccount, error) {
+	var value func(*fakeAccount) economy.Money
+	switch category {
+	case economy.TopBalance:
+		value = func(a *fakeAccount) economy.Money { return a.balance }
+	case economy.TopEarned:
+		value = func(a *fakeAccount) economy.Money { return a.earned }
+	case economy.TopSpent:
+		value = func(a *fakeAccount) economy.Money { return a.spent }
+	default:
+		return nil, economy.ErrInvalidLeaderboard
+	}
+	
+	keys := make([]string, 0, len(f.accounts))
+	for _, key := range f.players() {
+		if !f.accounts[key].hidden {
+			keys = append(keys, key)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return value(f.accounts[keys[i]]) > value(f.accounts[keys[j]])
+	})
+	accounts := make([]economy.PlayerAccount, len(keys))
+	for i, key := range keys {
+		accounts[i] = f.snapshot(f.accounts[key])
+	}
+	return accounts, nil
+}
+
+func (f *Fake) GetLeaderboard(category economy.LeaderboardCategory) ([]economy.PlayerAccount, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("GetLeaderboard", category); err != nil {
+		return nil, err
+	}
+	return f.ranking(category)
+}
+
+func (f *Fake) GetRank(username string, category economy.LeaderboardCategory) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("GetRank", username, category); err != nil {
+		return 0, err
+	}
+	if _, err := f.lookup(username); err != nil {
+		return 0, err
+	}
+	accounts, err := f.ranking(category)
+	if err != nil {
+		return 0, err
+	}
+	for i, account := range accounts {
+		if strings.EqualFold(account.Username, username) {
+			return i + 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (f *Fake) SetAccountHidden(username string, hidden bool) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("SetAccountHidden", username, hidden); err != nil {
+		return err
+	}
+	account, err := f.lookup(username)
+	if err != nil {
+		return err
+	}
+	account.hidden = hidden
+	return nil
+}
+
+func (f *Fake) Rules() []economy.TransactionRule {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.begin("Rules")
+	return []economy.TransactionRule{}
+}
+
+func (f *Fake) MoneySupply(days int) []economy.SupplyPoint {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.begin("MoneySupply", days)
+	return []economy.SupplyPoint{{Day: time.Now().Truncate(24 * time.Hour), Total: f.supply()}}
+}
+
+func (f *Fake) supply() economy.Money {
+	total := economy.Money(0)
+	for _, account := range f.accounts {
+		total += account.balance
+	}
+	return total
+}
+
+func (f *Fake) CurrentSupply() economy.SupplyStatus {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.begin("CurrentSupply")
+	return economy.SupplyStatus{Supply: f.supply()}
+}
+
+// SearchTransactions honours the player, types, time range and paging of
+// search, newest first; the other filters are ignored.
+func (f *Fake) SearchTransactions(search economy.TransactionSearch) []economy.Transaction {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.begin("SearchTransactions", search)
+	filter := search.Filter
+	matched := make([]economy.Transaction, 0)
+	for i := len(f.transactions) - 1; i >= 0; i-- {
+		transaction := f.transactions[i]
+		if search.Player != "" && !strings.EqualFold(transaction.From, search.Player) && !strings.EqualFold(transaction.To, search.Player) {
+			continue
+		}
+		if len(filter.Types) > 0 && !hasType(filter.Types, transaction.Type) {
+			continue
+		}
+		if (!filter.Since.IsZero() && transaction.Timestamp.Before(filter.Since)) ||
+			(!filter.Until.IsZero() && !transaction.Timestamp.Before(filter.Until)) {
+			continue
+		}
+		matched = append(matched, transaction)
+	}
+	
+	if filter.Offset >= len(matched) {
+		return []economy.Transaction{}
+	}
+	matched = matched[filter.Offset:]
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[:filter.Limit]
+	}
+	return matched
+}
+
+func hasType(types []economy.TransactionType, kind economy.TransactionType) bool {
+	for _, candidate := range types {
+		if candidate == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Fake) HealthReport(day time.Time) economy.HealthReport {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.begin("HealthReport", day)
+	return economy.HealthReport{Day: day, Supply: f.supply(), Transactions: len(f.transactions)}
+}
+
+func (f *Fake) WithdrawAsItems(player string, amount economy.Money) ([]economy.ItemStack, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("WithdrawAsItems", player, amount); err != nil {
+		return nil, err
+	}
+	return nil, ErrNotSupported
+}
+
+func (f *Fake) DepositItems(player string, items []economy.ItemStack) (economy.Money, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("DepositItems", player, items); err != nil {
+		return 0, err
+	}
+	return 0, ErrNotSupported
+}
+
+// PenalizeOnDeath records the call but takes nothing, since the Fake has no
+// death_penalty config.
+func (f *Fake) PenalizeOnDeath(player string) (economy.Money, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("PenalizeOnDeath", player); err != nil {
+		return 0, err
+	}
+	if _, err := f.lookup(player); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+func (f *Fake) GrantReward(player string, amount economy.Money, questID string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("GrantReward", player, amount, questID); err != nil {
+		return "", err
+	}
+	if questID == "" {
+		return "", economy.ErrInvalidQuest
+	}
+	if _, err := f.lookup(player); err != nil {
+		return "", err
+	}
+	key := strings.ToLower(player) + "/" + questID
+	if f.quests[key] {
+		return "", economy.ErrQuestClaimed
+	}
+	id, err := f.move("", player, amount, economy.REWARD, economy.ReasonReward, "Quest reward: "+questID)
+	if err != nil {
+		return "", err
+	}
+	f.quests[key] = true
+	return id, nil
+}
+
+func (f *Fake) HasClaimedReward(player, questID string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	f.begin("HasClaimedReward", player, questID)
+	return f.quests[strings.ToLower(player)+"/"+questID]
+}
+
+func (f *Fake) RecordVote(vote economy.Vote) (economy.VoteReward, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	
+	if err := f.begin("RecordVote", vote); err != nil {
+		return economy.VoteReward{}, err
+	}
+	return economy.VoteReward{}, ErrNotSupported
+}
@@ -0,0 +1,493 @@
+// Package storagetest is the acceptance suite every economy.Storage
+// implementation must pass, the plugin's own backends and third-party
+// ones alike. A backend's test calls Run with a Factory:
+//
+//	func TestStorage(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storagetest.Opener {
+//			path := filepath.Join(t.TempDir(), "players.db")
+//			return func() (economy.Storage, error) { return economy.NewSQLiteStorage(path) }
+//		})
+//	}
+//
+// Backends that also implement economy.AtomicStorage, economy.LazyStorage
+// or economy.TransactionStore are held to those contracts too.
+package storagetest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	
+	"github.com/percocets100/SimpleEconomy/SimpleEconomy/src/economy"
+)
+
+// Opener opens the storage at one location. Each call returns a new
+// handle onto the same data, as restarting the server would.
+type Opener func() (economy.Storage, error)
+
+// Factory prepares an empty location for one test and returns its
+// Opener. Locations must not share data with each other.
+type Factory func(t *testing.T) Opener
+
+// Run runs every conformance test against the storage factory makes.
+// With -short the large-dataset tests use fewer records.
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, open Opener)
+	}{
+		{"CRUD", testCRUD},
+		{"Persistence", testPersistence},
+		{"CrashRecovery", testCrashRecovery},
+		{"ConcurrentWrites", testConcurrentWrites},
+		{"ConcurrentTransfers", testConcurrentTransfers},
+		{"LargeDataset", testLargeDataset},
+		{"LazyLookup", testLazyLookup},
+		{"TransactionPagination", testTransactionPagination},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, factory(t))
+		})
+	}
+}
+
+func testUUID(n int) string {
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", n)
+}
+
+func testAccount(n int) *economy.PlayerAccount {
+	seen := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(n) * time.Minute)
+	return &economy.PlayerAccount{
+		UUID:           testUUID(n),
+		Username:       fmt.Sprintf("player%d", n),
+		Balance:        economy.Money(n) * 10000,
+		LastSeen:       seen,
+		Created:        seen,
+		TotalEarned:    economy.Money(n) * 20000,
+		TotalSpent:     economy.Money(n) * 5000,
+		BankBalance:    economy.Money(n) * 30000,
+		Hidden:         n%2 == 0,
+		PrivateBalance: n%3 == 0,
+	}
+}
+
+// openLoaded opens and loads the storage, closing it when the test ends.
+func openLoaded(t *testing.T, open Opener) economy.Storage {
+	t.Helper()
+	storage, err := open()
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	if err := storage.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	return storage
+}
+
+// sameAccount reports how got differs from want. Times need only agree
+// to the second, as not every backend keeps more.
+func sameAccount(got, want *economy.PlayerAccount) error {
+	switch {
+	case got == nil:
+		return fmt.Errorf("account %s missing", want.UUID)
+	case got.UUID != want.UUID || got.Username != want.Username:
+		return fmt.Errorf("got %s/%s, want %s/%s", got.UUID, got.Username, want.UUID, want.Username)
+	case got.Balance != want.Balance || got.BankBalance != want.BankBalance:
+		return fmt.Errorf("%s: balances %s/%s, want %s/%s", want.UUID, got.Balance, got.BankBalance, want.Balance, want.BankBalance)
+	case got.TotalEarned != want.TotalEarned || got.TotalSpent != want.TotalSpent:
+		return fmt.Errorf("%s: totals %s/%s, want %s/%s", want.UUID, got.TotalEarned, got.TotalSpent, want.TotalEarned, want.TotalSpent)
+	case got.LastSeen.Truncate(time.Second).Unix() != want.LastSeen.Truncate(time.Second).Unix():
+		return fmt.Errorf("%s: last seen %s, want %s", want.UUID, got.LastSeen, want.LastSeen)
+	case got.Hidden != want.Hidden:
+		return fmt.Errorf("%s: hidden %t, want %t", want.UUID, got.Hidden, want.Hidden)
+	case got.PrivateBalance != want.PrivateBalance:
+		return fmt.Errorf("%s: private balance %t, want %t", want.UUID, got.PrivateBalance, want.PrivateBalance)
+	}
+	return nil
+}
+
+func mustGet(t *testing.T, storage economy.Storage, want *economy.PlayerAccount) {
+	t.Helper()
+	got, err := storage.GetAccount(want.UUID)
+	if err != nil {
+		t.Fatalf("get %s: %v", want.UUID, err)
+	}
+	if err := sameAccount(got, want); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustPut(t *testing.T, storage economy.Storage, account *economy.PlayerAccount) {
+	t.Helper()
+	if err := storage.PutAccount(account); err != nil {
+		t.Fatalf("put %s: %v", account.UUID, err)
+	}
+}
+
+func mustSave(t *testing.T, storage economy.Storage) {
+	t.Helper()
+	if err := storage.Save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+}
+
+func testCRUD(t *testing.T, open Opener) {
+	storage := openLoaded(t, open)
+	
+	accounts, err := storage.ListAccounts()
+	if err != nil || len(accounts) != 0 {
+		t.Fatalf("new storage lists %d accounts, %v", len(accounts), err)
+	}
+	if missing, err := storage.GetAccount(testUUID(99)); missing != nil || err != nil {
+		t.Fatalf("missing account returned %v, %v; want nil, nil", missing, err)
+	}
+	
+	first, second := testAccount(1), testAccount(2)
+	mustPut(t, storage, first)
+	mustPut(t, storage, second)
+	mustGet(t, storage, first)
+	
+	// The storage must keep its own copy of what was put.
+	first.Balance = 0
+	mustGet(t, storage, testAccount(1))
+	
+	updated := testAccount(1)
+	updated.Balance, updated.Username = 12345, "renamed"
+	mustPut(t, storage, updated)
+	mustGet(t, storage, updated)
+	mustSave(t, storage)
+	
+	if err := storage.DeleteAccount(second.UUID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if deleted, err := storage.GetAccount(second.UUID); deleted != nil || err != nil {
+		t.Fatalf("deleted account returned %v, %v", deleted, err)
+	}
+	if err := storage.DeleteAccount(testUUID(99)); err != nil {
+		t.Fatalf("deleting a missing account: %v", err)
+	}
+	mustSave(t, storage)
+	
+	accounts, err = storage.ListAccounts()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(accounts) != 1 {
+		t.Fatalf("listed %d accounts after delete, want 1", len(accounts))
+	}
+	if err := sameAccount(accounts[0], updated); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testPersistence(t *testing.T, open Opener) {
+	storage := openLoaded(t, open)
+	for n := 1; n <= 3; n++ {
+		mustPut(t, storage, testAccount(n))
+	}
+	mustSave(t, storage)
+	if err := storage.DeleteAccount(testUUID(2)); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	mustSave(t, storage)
+	if err := storage.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	
+	reopened := openLoaded(t, open)
+	mustGet(t, reopened, testAccount(1))
+	mustGet(t, reopened, testAccount(3))
+	if deleted, _ := reopened.GetAccount(testUUID(2)); deleted != nil {
+		t.Fatal("deleted account came back after reopening")
+	}
+	if accounts, err := reopened.ListAccounts(); err != nil || len(accounts) != 2 {
+		t.Fatalf("reopened storage lists %d accounts, %v; want 2", len(accounts), err)
+	}
+}
+
+// testCrashRecovery reopens the storage without closing it first, as
+// after a crash. Whatever was saved must be there; changes made after the
+// last save may be lost, but must not be half applied. Backends that lock
+// their file, such as bolt, cannot be opened twice in one process; for
+// those the first is closed without saving, which loses the same staged
+// changes a crash would.
+func testCrashRecovery(t *testing.T, open Opener) {
+	storage := openLoaded(t, open)
+	mustPut(t, storage, testAccount(1))
+	mustPut(t, storage, testAccount(2))
+	mustSave(t, storage)
+	
+	unsaved := testAccount(2)
+	unsaved.Balance, unsaved.TotalEarned = 777, 777
+	mustPut(t, storage, unsaved)
+	mustPut(t, storage, testAccount(3))
+	
+	recovered, err := open()
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { recovered.Close() })
+	if err := recovered.Load(); err != nil {
+		storage.Close()
+		recovered = openLoaded(t, open)
+	}
+	mustGet(t, recovered, testAccount(1))
+	
+	got, err := recovered.GetAccount(testUUID(2))
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if sameAccount(got, testAccount(2)) != nil && sameAccount(got, unsaved) != nil {
+		t.Fatalf("account 2 is neither the saved nor the unsaved version: %+v", got)
+	}
+	if got, err := recovered.GetAccount(testUUID(3)); err != nil || (got != nil && sameAccount(got, testAccount(3)) != nil) {
+		t.Fatalf("unsaved account 3 recovered as %+v, %v", got, err)
+	}
+}
+
+// testConcurrentWrites has writers race on shared accounts while readers
+// list them. Each account must end as one writer left it, never a mix.
+func testConcurrentWrites(t *testing.T, open Opener) {
+	storage := openLoaded(t, open)
+	const writers, accounts, rounds = 8, 5, 20
+	
+	var group sync.WaitGroup
+	errs := make(chan error, writers*2)
+	for w := 0; w < writers; w++ {
+		group.Add(2)
+		go func(w int) {
+			defer group.Done()
+			for r := 0; r < rounds; r++ {
+				account := testAccount(1 + r%accounts)
+				account.Balance = economy.Money(w*1000 + r)
+				account.TotalEarned = account.Balance
+				if err := storage.PutAccount(account); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+		go func() {
+			defer group.Done()
+			for r := 0; r < rounds; r++ {
+				if _, err := storage.ListAccounts(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	group.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent access: %v", err)
+	}
+	mustSave(t, storage)
+	
+	listed, err := storage.ListAccounts()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(listed) != accounts {
+		t.Fatalf("listed %d accounts, want %d", len(listed), accounts)
+	}
+	for _, account := range listed {
+		if account.Balance != account.TotalEarned {
+			t.Fatalf("%s mixes two writes: balance %s, earned %s", account.UUID, account.Balance, account.TotalEarned)
+		}
+	}
+}
+
+// testConcurrentTransfers moves money between accounts from many
+// goroutines through UpdateAccounts, which must neither lose nor create
+// any. It is skipped for backends that are not economy.AtomicStorage.
+func testConcurrentTransfers(t *testing.T, open Opener) {
+	atomic, ok := openLoaded(t, open).(economy.AtomicStorage)
+	if !ok {
+		t.Skip("not an AtomicStorage")
+	}
+	const accounts, workers, transfers = 4, 8, 25
+	
+	seeds := make([]economy.PlayerAccount, accounts)
+	total := economy.Money(0)
+	for n := range seeds {
+		seeds[n] = *testAccount(n + 1)
+		total += seeds[n].Balance
+		mustPut(t, atomic, &seeds[n])
+	}
+	mustSave(t, atomic)
+	
+	var group sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		group.Add(1)
+		go func(w int) {
+			defer group.Done()
+			for i := 0; i < transfers; i++ {
+				from, to := seeds[(w+i)%accounts], seeds[(w+i+1)%accounts]
+				err := atomic.UpdateAccounts([]economy.PlayerAccount{from, to}, func(pair []*economy.PlayerAccount) error {
+					amount := economy.Money(100)
+					if pair[0].Balance < amount {
+						return nil
+					}
+					pair[0].Balance -= amount
+					pair[1].Balance += amount
+					return nil
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	group.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("transfer: %v", err)
+	}
+	
+	listed, err := atomic.ListAccounts()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	sum := economy.Money(0)
+	for _, account := range listed {
+		sum += account.Balance
+	}
+	if sum != total {
+		t.Fatalf("accounts hold %s after the transfers, want %s", sum, total)
+	}
+}
+
+func largeCount(t *testing.T, n int) int {
+	if testing.Short() {
+		return n / 10
+	}
+	return n
+}
+
+func testLargeDataset(t *testing.T, open Opener) {
+	storage := openLoaded(t, open)
+	count := largeCount(t, 5000)
+	for n := 1; n <= count; n++ {
+		mustPut(t, storage, testAccount(n))
+		if n%1000 == 0 {
+			mustSave(t, storage)
+		}
+	}
+	mustSave(t, storage)
+	if err := storage.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	
+	reopened := openLoaded(t, open)
+	listed, err := reopened.ListAccounts()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(listed) != count {
+		t.Fatalf("listed %d accounts, want %d", len(listed), count)
+	}
+	seen := make(map[string]bool, count)
+	for _, account := range listed {
+		if seen[account.UUID] {
+			t.Fatalf("%s listed twice", account.UUID)
+		}
+		seen[account.UUID] = true
+	}
+	mustGet(t, reopened, testAccount(count))
+}
+
+func testLazyLookup(t *testing.T, open Opener) {
+	lazy, ok := openLoaded(t, open).(economy.LazyStorage)
+	if !ok {
+		t.Skip("not a LazyStorage")
+	}
+	
+	older, newer := testAccount(1), testAccount(2)
+	newer.Username = older.Username
+	mustPut(t, lazy, older)
+	mustPut(t, lazy, newer)
+	mustPut(t, lazy, testAccount(3))
+	mustSave(t, lazy)
+	
+	if uuid, err := lazy.FindAccount(older.Username); err != nil || uuid != newer.UUID {
+		t.Fatalf("FindAccount found %q, %v; want the most recently seen %s", uuid, err, newer.UUID)
+	}
+	if uuid, err := lazy.FindAccount("nobody"); err != nil || uuid != "" {
+		t.Fatalf("FindAccount of a missing name found %q, %v", uuid, err)
+	}
+	if count, err := lazy.CountAccounts(); err != nil || count != 3 {
+		t.Fatalf("CountAccounts is %d, %v; want 3", count, err)
+	}
+}
+
+// testTransactionPagination pages through a large ledger, which must come
+// back newest first with every transaction exactly once. It is skipped
+// for backends that are not economy.TransactionStore.
+func testTransactionPagination(t *testing.T, open Opener) {
+	ledger, ok := openLoaded(t, open).(economy.TransactionStore)
+	if !ok {
+		t.Skip("not a TransactionStore")
+	}
+	
+	count := largeCount(t, 2000)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < count; i++ {
+		from, to := "player1", "player2"
+		if i%2 == 1 {
+			from, to = to, "player3"
+		}
+		err := ledger.AppendTransaction(&economy.Transaction{
+			ID:        fmt.Sprintf("TX%08d", i),
+			From:      from,
+			To:        to,
+			Amount:    economy.Money(i+1) * 100,
+			Type:      economy.TRANSFER,
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+			Reason:    "storagetest",
+		})
+		if err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	
+	const pageSize = 150
+	seen := make(map[string]bool, count)
+	var previous time.Time
+	for offset := 0; ; offset += pageSize {
+		page, err := ledger.QueryTransactions("", economy.TransactionFilter{Offset: offset, Limit: pageSize})
+		if err != nil {
+			t.Fatalf("query at %d: %v", offset, err)
+		}
+		for _, transaction := range page {
+			if seen[transaction.ID] {
+				t.Fatalf("%s returned twice", transaction.ID)
+			}
+			seen[transaction.ID] = true
+			if !previous.IsZero() && transaction.Timestamp.After(previous) {
+				t.Fatalf("%s is newer than the transaction before it", transaction.ID)
+			}
+			previous = transaction.Timestamp
+		}
+		if len(page) < pageSize {
+			break
+		}
+	}
+	if len(seen) != count {
+		t.Fatalf("paged through %d transactions, want %d", len(seen), count)
+	}
+	
+	involving, err := ledger.QueryTransactions("player3", economy.TransactionFilter{})
+	if err != nil {
+		t.Fatalf("query by player: %v", err)
+	}
+	if len(involving) != count/2 {
+		t.Fatalf("found %d transactions naming player3, want %d", len(involving), count/2)
+	}
+}
@@ -0,0 +1,358 @@
+// Command economyctl inspects and edits SimpleEconomy data while the
+// server is stopped. Running it against a live server's data will lose
+// whichever side saves first.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	
+	"github.com/percocets100/SimpleEconomy/SimpleEconomy/src/economy"
+)
+
+const usage = `Usage: economyctl [-data folder] <command> [arguments]
+
+Commands:
+  list [-sort name|balance] [-limit n]   list accounts
+  show <player>                          show one account
+  set <player> <amount>                  set a wallet balance
+  merge <from> <into>                    merge two accounts (names or UUIDs)
+  migrate <from> <to>                    move accounts and transactions to another backend
+  verify [-repair]                       check stored accounts for problems
+  export [-format csv|json] [-o file]    write a report of every account
+  replay [-backend name] <ledger>        replay a transactions.jsonl and compare balances
+  bench [-backend name] [flags]          measure transfer throughput on empty storage
+`
+
+func main() {
+	dataFolder := flag.String("data", "plugins/EconomyPocketmine", "plugin data folder")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	
+	plugin, err := economy.OpenOffline(*dataFolder)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "economyctl:", err)
+		os.Exit(1)
+	}
+	
+	code := run(plugin, flag.Arg(0), flag.Args()[1:])
+	if err := plugin.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "economyctl: closing storage:", err)
+		code = 1
+	}
+	os.Exit(code)
+}
+
+func run(plugin *economy.EconomyPlugin, command string, args []string) int {
+	var err error
+	switch command {
+	case "list":
+		err = listCommand(plugin, args)
+	case "show":
+		err = showCommand(plugin, args)
+	case "set":
+		err = setCommand(plugin, args)
+	case "merge":
+		err = mergeCommand(plugin, args)
+	case "migrate":
+		err = migrateCommand(plugin, args)
+	case "verify":
+		return verifyCommand(plugin, args)
+	case "export":
+		err = exportCommand(plugin, args)
+	case "replay":
+		return replayCommand(plugin, args)
+	case "bench":
+		err = benchCommand(plugin, args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "economyctl:", err)
+		return 1
+	}
+	return 0
+}
+
+func sortedAccounts(plugin *economy.EconomyPlugin, byBalance bool) []economy.PlayerAccount {
+	accounts := plugin.Accounts()
+	sort.Slice(accounts, func(i, j int) bool {
+		if byBalance && accounts[i].Balance != accounts[j].Balance {
+			return accounts[i].Balance > accounts[j].Balance
+		}
+		return strings.ToLower(accounts[i].Username) < strings.ToLower(accounts[j].Username)
+	})
+	
+	return accounts
+}
+
+func listCommand(plugin *economy.EconomyPlugin, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	sortBy := flags.String("sort", "name", "sort by name or balance")
+	limit := flags.Int("limit", 0, "show at most this many accounts")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *sortBy != "name" && *sortBy != "balance" {
+		return fmt.Errorf("unknown sort %q", *sortBy)
+	}
+	
+	accounts := sortedAccounts(plugin, *sortBy == "balance")
+	if *limit > 0 && len(accounts) > *limit {
+		accounts = accounts[:*limit]
+	}
+	
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "PLAYER\tUUID\tBALANCE\tBANK\tLAST SEEN")
+	for _, account := range accounts {
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\n", account.Username, account.UUID, account.Balance,
+			account.BankBalance, account.LastSeen.Format("2006-01-02 15:04"))
+	}
+	return out.Flush()
+}
+
+func showCommand(plugin *economy.EconomyPlugin, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: show <player>")
+	}
+	
+	account, exists := plugin.GetAccount(args[0])
+	if !exists {
+		return economy.ErrAccountNotFound
+	}
+	
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(out, "Player:\t%s\n", account.Username)
+	fmt.Fprintf(out, "UUID:\t%s\n", account.UUID)
+	fmt.Fprintf(out, "Balance:\t%s\n", account.Balance)
+	fmt.Fprintf(out, "Bank:\t%s\n", account.BankBalance)
+	fmt.Fprintf(out, "Earned:\t%s\n", account.TotalEarned)
+	fmt.Fprintf(out, "Spent:\t%s\n", account.TotalSpent)
+	fmt.Fprintf(out, "Last seen:\t%s\n", account.LastSeen.Format(time.RFC3339))
+	return out.Flush()
+}
+
+func setCommand(plugin *economy.EconomyPlugin, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: set <player> <amount>")
+	}
+	
+	amount, err := economy.ParseMoney(args[1])
+	if err != nil {
+		return err
+	}
+	id, err := plugin.SetBalance(args[0], amount, "Set with economyctl")
+	if err != nil {
+		return err
+	}
+	
+	fmt.Printf("Set %s's balance to %s (receipt #%s)\n", args[0], amount, id)
+	return nil
+}
+
+func mergeCommand(plugin *economy.EconomyPlugin, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: merge <from> <into>")
+	}
+	
+	if err := plugin.MergeAccounts(args[0], args[1]); err != nil {
+		return err
+	}
+	
+	fmt.Printf("Merged %s into %s\n", args[0], args[1])
+	return nil
+}
+
+func migrateCommand(plugin *economy.EconomyPlugin, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: migrate <from> <to>")
+	}
+	
+	result, err := plugin.MigrateStorage(args[0], args[1], func(stage string, done int) {
+		fmt.Printf("  %d %s copied\n", done, stage)
+	})
+	if err != nil {
+		return err
+	}
+	
+	fmt.Printf("Migrated %d accounts holding %s and %d transactions from %s to %s\n",
+		result.Accounts, result.Supply, result.Transactions, result.From, result.To)
+	return nil
+}
+
+// verifyCommand exits 1 when problems remain so it can gate scripts.
+func verifyCommand(plugin *economy.EconomyPlugin, args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "fix what can be fixed automatically")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	
+	issues, err := plugin.VerifyData(*repair)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "economyctl:", err)
+		return 1
+	}
+	if len(issues) == 0 {
+		fmt.Println("No problems found")
+		return 0
+	}
+	
+	remaining := 0
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "PLAYER\tUUID\tPROBLEM\tREPAIRED")
+	for _, issue := range issues {
+		repaired := "no"
+		if issue.Repaired {
+			repaired = "yes"
+		} else {
+			remaining++
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", issue.Player, issue.UUID, issue.Problem, repaired)
+	}
+	out.Flush()
+	fmt.Printf("%d problems found, %d remaining\n", len(issues), remaining)
+	if remaining > 0 {
+		return 1
+	}
+	return 0
+}
+
+// replayCommand exits 1 when balances diverge, as verifyCommand does for
+// problems.
+func replayCommand(plugin *economy.EconomyPlugin, args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	backend := flags.String("backend", "", "storage backend to replay into (default: the active one)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: replay [-backend name] <ledger>")
+		return 2
+	}
+	
+	result, err := plugin.ReplayLedger(flags.Arg(0), *backend)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "economyctl:", err)
+		return 1
+	}
+	rate := 0.0
+	if result.Duration > 0 {
+		rate = float64(result.Transactions) / result.Duration.Seconds()
+	}
+	fmt.Printf("Replayed %d transactions into %d %s accounts in %s (%.0f/s)\n",
+		result.Transactions, result.Accounts, result.Backend, result.Duration.Round(time.Millisecond), rate)
+	if len(result.Divergences) == 0 {
+		fmt.Println("Every stored balance matches the ledger")
+		return 0
+	}
+	
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "PLAYER\tSTORED\tREPLAYED")
+	for _, divergence := range result.Divergences {
+		stored := divergence.Stored.String()
+		if divergence.Missing {
+			stored = "no account"
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\n", divergence.Player, stored, divergence.Replayed)
+	}
+	out.Flush()
+	fmt.Printf("%d balances diverge from the ledger\n", len(result.Divergences))
+	return 1
+}
+
+func benchCommand(plugin *economy.EconomyPlugin, args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	var options economy.BenchOptions
+	flags.StringVar(&options.Backend, "backend", "", "storage backend to load (default: the active one)")
+	flags.IntVar(&options.Accounts, "accounts", 1000, "accounts to create")
+	flags.IntVar(&options.Workers, "workers", 8, "concurrent workers")
+	flags.IntVar(&options.Rate, "rate", 0, "operations per second across all workers, 0 for no limit")
+	flags.DurationVar(&options.Duration, "duration", 10*time.Second, "how long to run")
+	flags.Float64Var(&options.ReadShare, "reads", 0.1, "fraction of operations that read the leaderboard")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	
+	result, err := plugin.Bench(options)
+	if err != nil {
+		return err
+	}
+	
+	fmt.Printf("%s, %d accounts, %d workers, %s\n", result.Options.Backend, result.Options.Accounts,
+		result.Options.Workers, result.Elapsed.Round(time.Millisecond))
+	out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(out, "OPERATION\tCOUNT\tFAILED\tPER SEC\tP50\tP99\tMAX")
+	for _, row := range []struct {
+		name  string
+		stats economy.BenchStats
+	}{{"transfer", result.Transfers}, {"leaderboard", result.Reads}} {
+		fmt.Fprintf(out, "%s\t%d\t%d\t%.0f\t%s\t%s\t%s\n", row.name, row.stats.Count, row.stats.Failed,
+			row.stats.Throughput, row.stats.P50, row.stats.P99, row.stats.Max)
+	}
+	out.Flush()
+	fmt.Printf("%.0f allocs/op, %.0f B/op\n", result.AllocsPerOp, result.BytesPerOp)
+	return nil
+}
+
+func exportCommand(plugin *economy.EconomyPlugin, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "csv", "csv or json")
+	path := flags.String("o", "", "output file (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	
+	var out io.Writer = os.Stdout
+	if *path != "" {
+		file, err := os.Create(*path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	
+	accounts := sortedAccounts(plugin, true)
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(accounts)
+		
+	case "csv":
+		writer := csv.NewWriter(out)
+		writer.Write([]string{"uuid", "username", "balance", "bank_balance", "total_earned", "total_spent", "last_seen"})
+		for _, account := range accounts {
+			writer.Write([]string{
+				account.UUID,
+				account.Username,
+				account.Balance.String(),
+				account.BankBalance.String(),
+				account.TotalEarned.String(),
+				account.TotalSpent.String(),
+				account.LastSeen.Format(time.RFC3339),
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+		
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}